@@ -0,0 +1,52 @@
+package clock
+
+import "time"
+
+// Timer defines the operations of a single-shot timer created by a clock
+type Timer interface {
+	C() <-chan time.Time
+	// Reset re-arms the timer to fire after the duration, dropping a pending tick
+	Reset(d time.Duration)
+	Stop()
+}
+
+type systemClock struct{}
+
+// NewSystemClock creates the clock reading the system time, used outside the tests
+func NewSystemClock() *systemClock {
+	return &systemClock{}
+}
+
+// Now returns the current system time
+func (clk *systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewTimer creates a timer firing after the duration
+func (clk *systemClock) NewTimer(d time.Duration) Timer {
+	return &systemTimer{timer: time.NewTimer(d)}
+}
+
+// IsInterfaceNil returns true if there is no value under the interface
+func (clk *systemClock) IsInterfaceNil() bool {
+	return clk == nil
+}
+
+type systemTimer struct {
+	timer *time.Timer
+}
+
+// C returns the channel the ticks are sent on
+func (st *systemTimer) C() <-chan time.Time {
+	return st.timer.C
+}
+
+// Reset re-arms the timer
+func (st *systemTimer) Reset(d time.Duration) {
+	st.timer.Reset(d)
+}
+
+// Stop stops the timer
+func (st *systemTimer) Stop() {
+	st.timer.Stop()
+}
@@ -0,0 +1,138 @@
+package clock
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ManualClock is a clock moving only when advanced, so the tests can step through the timed behaviors instead of
+// sleeping. It is safe for concurrent use.
+type ManualClock struct {
+	mut     sync.Mutex
+	now     time.Time
+	armed   map[*manualTimer]time.Time
+	changed chan struct{}
+}
+
+// NewManualClock creates a manual clock set at the provided time
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{
+		now:     now,
+		armed:   make(map[*manualTimer]time.Time),
+		changed: make(chan struct{}),
+	}
+}
+
+// Now returns the time of the clock
+func (clk *ManualClock) Now() time.Time {
+	clk.mut.Lock()
+	defer clk.mut.Unlock()
+
+	return clk.now
+}
+
+// NewTimer creates a timer firing when the clock is advanced past the duration
+func (clk *ManualClock) NewTimer(d time.Duration) Timer {
+	timer := &manualTimer{
+		clock: clk,
+		c:     make(chan time.Time, 1),
+	}
+	timer.Reset(d)
+
+	return timer
+}
+
+// Advance moves the clock forward, firing the timers due by the new time
+func (clk *ManualClock) Advance(d time.Duration) {
+	clk.mut.Lock()
+	defer clk.mut.Unlock()
+
+	clk.now = clk.now.Add(d)
+	for timer, deadline := range clk.armed {
+		if !deadline.After(clk.now) {
+			clk.fire(timer)
+		}
+	}
+	clk.notify()
+}
+
+// WaitTimers blocks until at least numTimers timers are armed or the context is done. An armed timer means its owner
+// is waiting for the clock, so the test can advance it without racing the owner.
+func (clk *ManualClock) WaitTimers(ctx context.Context, numTimers int) error {
+	for {
+		clk.mut.Lock()
+		numArmed := len(clk.armed)
+		changed := clk.changed
+		clk.mut.Unlock()
+
+		if numArmed >= numTimers {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// IsInterfaceNil returns true if there is no value under the interface
+func (clk *ManualClock) IsInterfaceNil() bool {
+	return clk == nil
+}
+
+// fire sends the tick of the timer and disarms it, should be called under mutex
+func (clk *ManualClock) fire(timer *manualTimer) {
+	delete(clk.armed, timer)
+	select {
+	case timer.c <- clk.now:
+	default:
+	}
+}
+
+// notify wakes up the WaitTimers calls, should be called under mutex
+func (clk *ManualClock) notify() {
+	close(clk.changed)
+	clk.changed = make(chan struct{})
+}
+
+type manualTimer struct {
+	clock *ManualClock
+	c     chan time.Time
+}
+
+// C returns the channel the ticks are sent on
+func (mt *manualTimer) C() <-chan time.Time {
+	return mt.c
+}
+
+// Reset re-arms the timer, firing it right away for a non-positive duration
+func (mt *manualTimer) Reset(d time.Duration) {
+	clk := mt.clock
+	clk.mut.Lock()
+	defer clk.mut.Unlock()
+
+	select {
+	case <-mt.c:
+	default:
+	}
+
+	if d <= 0 {
+		clk.fire(mt)
+	} else {
+		clk.armed[mt] = clk.now.Add(d)
+	}
+	clk.notify()
+}
+
+// Stop disarms the timer
+func (mt *manualTimer) Stop() {
+	clk := mt.clock
+	clk.mut.Lock()
+	defer clk.mut.Unlock()
+
+	delete(clk.armed, mt)
+	clk.notify()
+}
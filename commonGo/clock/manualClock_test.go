@@ -0,0 +1,81 @@
+package clock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSystemClock(t *testing.T) {
+	t.Parallel()
+
+	clk := NewSystemClock()
+	assert.False(t, clk.IsInterfaceNil())
+	assert.WithinDuration(t, time.Now(), clk.Now(), time.Second)
+
+	timer := clk.NewTimer(time.Millisecond)
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "the timer did not fire")
+	}
+}
+
+func TestManualClock_Advance(t *testing.T) {
+	t.Parallel()
+
+	start := time.Unix(1700000000, 0)
+	clk := NewManualClock(start)
+	assert.False(t, clk.IsInterfaceNil())
+	assert.Equal(t, start, clk.Now())
+
+	timer := clk.NewTimer(time.Minute)
+	clk.Advance(59 * time.Second)
+	assert.Len(t, timer.C(), 0)
+
+	clk.Advance(time.Second)
+	require.Len(t, timer.C(), 1)
+	assert.Equal(t, start.Add(time.Minute), <-timer.C())
+
+	timer.Reset(time.Second)
+	timer.Stop()
+	clk.Advance(time.Hour)
+	assert.Len(t, timer.C(), 0)
+
+	timer.Reset(0)
+	assert.Len(t, timer.C(), 1)
+	timer.Reset(time.Second)
+	assert.Len(t, timer.C(), 0, "the pending tick should be dropped")
+}
+
+func TestManualClock_WaitTimers(t *testing.T) {
+	t.Parallel()
+
+	clk := NewManualClock(time.Unix(1700000000, 0))
+	ticks := make(chan time.Time)
+	go func() {
+		timer := clk.NewTimer(time.Second)
+		defer timer.Stop()
+
+		for i := 0; i < 3; i++ {
+			ticks <- <-timer.C()
+			timer.Reset(time.Second)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for i := 1; i <= 3; i++ {
+		require.Nil(t, clk.WaitTimers(ctx, 1))
+		clk.Advance(time.Second)
+		assert.Equal(t, time.Unix(1700000000+int64(i), 0), <-ticks)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, clk.WaitTimers(ctx, 2))
+}
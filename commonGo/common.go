@@ -6,6 +6,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/iulianpascalau/api-monitoring/commonGo/clock"
 	"github.com/joho/godotenv"
 	logger "github.com/multiversx/mx-chain-logger-go"
 	"github.com/multiversx/mx-chain-logger-go/file"
@@ -60,15 +61,20 @@ func ReadEnvFile(envFile string, m map[string]*EnvValue) error {
 // CronJobStarter is able to start a go routine that periodically calls the provided handler. The time between calls is
 // provided as timeToCall
 func CronJobStarter(ctx context.Context, handler func(ctx context.Context), timeToCall time.Duration) {
+	CronJobStarterWithClock(ctx, clock.NewSystemClock(), handler, timeToCall)
+}
+
+// CronJobStarterWithClock is the CronJobStarter measuring the time between calls on the provided clock
+func CronJobStarterWithClock(ctx context.Context, clk Clock, handler func(ctx context.Context), timeToCall time.Duration) {
 	go func() {
-		timer := time.NewTimer(timeToCall)
+		timer := clk.NewTimer(timeToCall)
 		defer timer.Stop()
 
 		handler(ctx)
 
 		for {
 			select {
-			case <-timer.C:
+			case <-timer.C():
 				handler(ctx)
 				timer.Reset(timeToCall)
 			case <-ctx.Done():
@@ -77,3 +83,28 @@ func CronJobStarter(ctx context.Context, handler func(ctx context.Context), time
 		}
 	}()
 }
+
+// AlignedCronJobStarter is the CronJobStarterWithClock calling the handler on the wall-clock boundaries, the multiples of
+// timeToCall since the Unix epoch (e.g. every minute at :00), instead of relative to its start. The first call waits
+// for the next boundary and the boundaries missed by a slow handler are skipped.
+func AlignedCronJobStarter(ctx context.Context, clk Clock, handler func(ctx context.Context), timeToCall time.Duration) {
+	go func() {
+		timer := clk.NewTimer(untilNextBoundary(clk.Now(), timeToCall))
+		defer timer.Stop()
+
+		for {
+			select {
+			case <-timer.C():
+				handler(ctx)
+				timer.Reset(untilNextBoundary(clk.Now(), timeToCall))
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// untilNextBoundary returns the time left until the next multiple of the interval since the Unix epoch
+func untilNextBoundary(now time.Time, interval time.Duration) time.Duration {
+	return interval - time.Duration(now.UnixNano()%int64(interval))
+}
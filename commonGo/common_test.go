@@ -6,7 +6,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/iulianpascalau/api-monitoring/commonGo/clock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCronJob(t *testing.T) {
@@ -44,4 +46,67 @@ func TestCronJob(t *testing.T) {
 
 		assert.Equal(t, uint64(4), atomic.LoadUint64(&counter))
 	})
+	t.Run("manual clock should trigger the calls", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		counter := uint64(0)
+		handler := func(ctx context.Context) {
+			atomic.AddUint64(&counter, 1)
+		}
+
+		clk := clock.NewManualClock(time.Unix(1700000000, 0))
+		CronJobStarterWithClock(ctx, clk, handler, time.Minute)
+
+		waitCtx, cancelWait := context.WithTimeout(ctx, 5*time.Second)
+		defer cancelWait()
+		for i := 0; i < 3; i++ {
+			require.Nil(t, clk.WaitTimers(waitCtx, 1))
+			clk.Advance(time.Minute)
+		}
+		require.Nil(t, clk.WaitTimers(waitCtx, 1))
+
+		assert.Equal(t, uint64(4), atomic.LoadUint64(&counter))
+	})
+}
+
+func TestAlignedCronJobStarter(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	calledAt := make(chan time.Time, 10)
+	boundary := time.Unix(1700000040, 0)
+	clk := clock.NewManualClock(boundary.Add(-25 * time.Second))
+	handler := func(ctx context.Context) {
+		calledAt <- clk.Now()
+	}
+	AlignedCronJobStarter(ctx, clk, handler, time.Minute)
+
+	waitCtx, cancelWait := context.WithTimeout(ctx, 5*time.Second)
+	defer cancelWait()
+	require.Nil(t, clk.WaitTimers(waitCtx, 1))
+	clk.Advance(24 * time.Second)
+	assert.Len(t, calledAt, 0)
+
+	clk.Advance(time.Second)
+	assert.Equal(t, boundary, <-calledAt)
+
+	// a late wake up is aligned back on the next boundary
+	require.Nil(t, clk.WaitTimers(waitCtx, 1))
+	clk.Advance(70 * time.Second)
+	assert.Equal(t, boundary.Add(70*time.Second), <-calledAt)
+	require.Nil(t, clk.WaitTimers(waitCtx, 1))
+	clk.Advance(50 * time.Second)
+	assert.Equal(t, boundary.Add(2*time.Minute), <-calledAt)
+}
+
+func TestUntilNextBoundary(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, 20*time.Second, untilNextBoundary(time.Unix(1700000040-20, 0), time.Minute))
+	assert.Equal(t, time.Minute, untilNextBoundary(time.Unix(1700000040, 0), time.Minute))
+	assert.Equal(t, 500*time.Millisecond, untilNextBoundary(time.Unix(1700000040, 0).Add(-500*time.Millisecond), time.Minute))
+	assert.Equal(t, 4*time.Minute, untilNextBoundary(time.Unix(1700000100-240, 0), 5*time.Minute))
 }
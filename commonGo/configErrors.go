@@ -0,0 +1,47 @@
+package commonGo
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ErrInvalidConfig signals that the config file contains invalid values
+var ErrInvalidConfig = errors.New("invalid config")
+
+// ConfigErrors collects all the problems found while validating a config file, so they can be reported at once
+type ConfigErrors struct {
+	problems []string
+}
+
+// Add records a problem
+func (ce *ConfigErrors) Add(format string, args ...interface{}) {
+	ce.problems = append(ce.problems, fmt.Sprintf(format, args...))
+}
+
+// Merge records the problems of another validation, prefixed, e.g. with the name of the section it validated
+func (ce *ConfigErrors) Merge(prefix string, other *ConfigErrors) {
+	for _, problem := range other.problems {
+		ce.problems = append(ce.problems, prefix+problem)
+	}
+}
+
+// Err returns nil if no problem was recorded, otherwise an error wrapping ErrInvalidConfig that lists all the problems
+func (ce *ConfigErrors) Err() error {
+	if len(ce.problems) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%w, %d problem(s) found:\n  - %s", ErrInvalidConfig, len(ce.problems), strings.Join(ce.problems, "\n  - "))
+}
+
+// IsHTTPURL returns true if the provided value is an absolute http or https URL
+func IsHTTPURL(value string) bool {
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return false
+	}
+
+	return (parsed.Scheme == "http" || parsed.Scheme == "https") && len(parsed.Host) > 0
+}
@@ -0,0 +1,56 @@
+package commonGo
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigErrors(t *testing.T) {
+	t.Parallel()
+
+	t.Run("no problems should return nil", func(t *testing.T) {
+		t.Parallel()
+
+		errs := &ConfigErrors{}
+		assert.Nil(t, errs.Err())
+	})
+	t.Run("should list all the problems", func(t *testing.T) {
+		t.Parallel()
+
+		errs := &ConfigErrors{}
+		errs.Add("Name is empty")
+		errs.Add("QueryIntervalInSeconds must be at least %d, got %d", 1, 0)
+
+		err := errs.Err()
+		assert.True(t, errors.Is(err, ErrInvalidConfig))
+		assert.Equal(t, "invalid config, 2 problem(s) found:\n  - Name is empty\n  - QueryIntervalInSeconds must be at least 1, got 0", err.Error())
+	})
+	t.Run("should merge the prefixed problems", func(t *testing.T) {
+		t.Parallel()
+
+		sectionErrs := &ConfigErrors{}
+		sectionErrs.Add("Endpoints[0]: Name is empty")
+
+		errs := &ConfigErrors{}
+		errs.Add("ListenAddress is empty")
+		errs.Merge("DirectProbes.", sectionErrs)
+		errs.Merge("Other.", &ConfigErrors{})
+
+		err := errs.Err()
+		assert.Equal(t, "invalid config, 2 problem(s) found:\n  - ListenAddress is empty\n  - DirectProbes.Endpoints[0]: Name is empty", err.Error())
+	})
+}
+
+func TestIsHTTPURL(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, IsHTTPURL("http://127.0.0.1:8080/node/status"))
+	assert.True(t, IsHTTPURL("https://aaa.bbb.com/report"))
+	assert.False(t, IsHTTPURL(""))
+	assert.False(t, IsHTTPURL("/report"))
+	assert.False(t, IsHTTPURL("ftp://aaa.bbb.com"))
+	assert.False(t, IsHTTPURL("http://"))
+	assert.False(t, IsHTTPURL("http://[::1"))
+}
@@ -0,0 +1,166 @@
+package commonGo
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ApplyEnvOverrides overwrites the fields of the provided config (pointer to struct) with the values of the matching
+// environment variables. The variable names are built from the prefix and the upper snake case field names, joined
+// by underscores: e.g. ListenAddress -> <PREFIX>_LISTEN_ADDRESS, Alarms.SystemSelfCheck.Hour ->
+// <PREFIX>_ALARMS_SYSTEM_SELF_CHECK_HOUR. The elements of the slices of structs already defined in the config file
+// are addressed by their index (<PREFIX>_ENDPOINTS_0_URL), the string slices are comma separated and the string maps
+// are given as comma separated key=value pairs. Returns the names of the applied variables.
+func ApplyEnvOverrides(cfg interface{}, prefix string, lookup func(key string) (string, bool)) ([]string, error) {
+	value := reflect.ValueOf(cfg)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("env overrides: expected a pointer to a struct, got %T", cfg)
+	}
+
+	applied := make([]string, 0)
+	err := applyEnvOverridesOnStruct(value.Elem(), strings.ToUpper(prefix), lookup, &applied)
+
+	return applied, err
+}
+
+func applyEnvOverridesOnStruct(value reflect.Value, prefix string, lookup func(key string) (string, bool), applied *[]string) error {
+	valueType := value.Type()
+	for i := 0; i < value.NumField(); i++ {
+		field := valueType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		err := applyEnvOverridesOnValue(value.Field(i), prefix+"_"+toUpperSnakeCase(field.Name), lookup, applied)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func applyEnvOverridesOnValue(value reflect.Value, key string, lookup func(key string) (string, bool), applied *[]string) error {
+	switch value.Kind() {
+	case reflect.Struct:
+		return applyEnvOverridesOnStruct(value, key, lookup, applied)
+	case reflect.Slice:
+		if value.Type().Elem().Kind() == reflect.Struct {
+			for i := 0; i < value.Len(); i++ {
+				err := applyEnvOverridesOnStruct(value.Index(i), key+"_"+strconv.Itoa(i), lookup, applied)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+
+	envValue, found := lookup(key)
+	if !found {
+		return nil
+	}
+
+	err := setValueFromString(value, envValue)
+	if err != nil {
+		return fmt.Errorf("%w for environment variable %s", err, key)
+	}
+	*applied = append(*applied, key)
+
+	return nil
+}
+
+func setValueFromString(value reflect.Value, str string) error {
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(str)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(str)
+		if err != nil {
+			return err
+		}
+		value.SetBool(parsed)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(str, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(str, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(str, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetFloat(parsed)
+	case reflect.Ptr:
+		// the optional values, e.g. a threshold for which 0 is a valid value
+		elem := reflect.New(value.Type().Elem())
+		err := setValueFromString(elem.Elem(), str)
+		if err != nil {
+			return err
+		}
+		value.Set(elem)
+	case reflect.Slice:
+		if value.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported slice type %s", value.Type())
+		}
+		items := splitList(str)
+		value.Set(reflect.ValueOf(items).Convert(value.Type()))
+	case reflect.Map:
+		if value.Type().Key().Kind() != reflect.String || value.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported map type %s", value.Type())
+		}
+		m := reflect.MakeMap(value.Type())
+		for _, pair := range splitList(str) {
+			key, val, found := strings.Cut(pair, "=")
+			if !found {
+				return fmt.Errorf("invalid key=value pair %q", pair)
+			}
+			m.SetMapIndex(reflect.ValueOf(strings.TrimSpace(key)), reflect.ValueOf(strings.TrimSpace(val)))
+		}
+		value.Set(m)
+	default:
+		return fmt.Errorf("unsupported type %s", value.Type())
+	}
+
+	return nil
+}
+
+func splitList(str string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(str, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) > 0 {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// toUpperSnakeCase converts a Go field name to the environment variable style (PushoverURL -> PUSHOVER_URL)
+func toUpperSnakeCase(name string) string {
+	runes := []rune(name)
+	builder := strings.Builder{}
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextIsLower) {
+				builder.WriteRune('_')
+			}
+		}
+		builder.WriteRune(unicode.ToUpper(r))
+	}
+
+	return builder.String()
+}
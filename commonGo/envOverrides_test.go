@@ -0,0 +1,130 @@
+package commonGo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testSubConfig struct {
+	Enabled     bool
+	PushoverURL string
+	Hour        int
+}
+
+type testItemConfig struct {
+	Name string
+	Tags map[string]string
+}
+
+type testConfig struct {
+	ListenAddress          string
+	QueryIntervalInSeconds uint32
+	MaxFileSizeInMB        uint32
+	Ratio                  float64
+	MinRatio               *float64
+	Hosts                  []string
+	Alarms                 testSubConfig
+	Endpoints              []testItemConfig
+	unexported             string
+}
+
+func TestToUpperSnakeCase(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "LISTEN_ADDRESS", toUpperSnakeCase("ListenAddress"))
+	assert.Equal(t, "PUSHOVER_URL", toUpperSnakeCase("PushoverURL"))
+	assert.Equal(t, "URL", toUpperSnakeCase("URL"))
+	assert.Equal(t, "MAX_FILE_SIZE_IN_MB", toUpperSnakeCase("MaxFileSizeInMB"))
+	assert.Equal(t, "HTTP_PORT", toUpperSnakeCase("HTTPPort"))
+	assert.Equal(t, "NAME", toUpperSnakeCase("Name"))
+}
+
+func TestApplyEnvOverrides(t *testing.T) {
+	t.Parallel()
+
+	t.Run("not a struct pointer should error", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := ApplyEnvOverrides(testConfig{}, "APP", func(key string) (string, bool) { return "", false })
+		assert.NotNil(t, err)
+	})
+	t.Run("invalid value should error", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := &testConfig{}
+		env := map[string]string{"APP_QUERY_INTERVAL_IN_SECONDS": "-1"}
+		_, err := ApplyEnvOverrides(cfg, "APP", func(key string) (string, bool) {
+			value, found := env[key]
+			return value, found
+		})
+		require.NotNil(t, err)
+		assert.Contains(t, err.Error(), "APP_QUERY_INTERVAL_IN_SECONDS")
+	})
+	t.Run("should override the values", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := &testConfig{
+			ListenAddress:          "0.0.0.0:8080",
+			QueryIntervalInSeconds: 60,
+			Alarms: testSubConfig{
+				Hour: 12,
+			},
+			Endpoints:  []testItemConfig{{Name: "a"}, {Name: "b"}},
+			unexported: "unchanged",
+		}
+		env := map[string]string{
+			"APP_LISTEN_ADDRESS":          "127.0.0.1:9090",
+			"APP_MAX_FILE_SIZE_IN_MB":     "10",
+			"APP_RATIO":                   "0.5",
+			"APP_MIN_RATIO":               "0",
+			"APP_HOSTS":                   "h1, h2,",
+			"APP_ALARMS_ENABLED":          "true",
+			"APP_ALARMS_PUSHOVER_URL":     "https://pushover",
+			"APP_ENDPOINTS_1_NAME":        "c",
+			"APP_ENDPOINTS_1_TAGS":        "shard=0, vm = VM1",
+			"APP_ENDPOINTS_5_NAME":        "ignored",
+			"APP_UNEXPORTED":              "ignored",
+			"OTHER_APP_LISTEN_ADDRESS":    "ignored",
+			"APP_ALARMS_SYSTEM_SELF_HOUR": "ignored",
+		}
+		applied, err := ApplyEnvOverrides(cfg, "app", func(key string) (string, bool) {
+			value, found := env[key]
+			return value, found
+		})
+		require.Nil(t, err)
+
+		minRatio := 0.0
+		expectedCfg := &testConfig{
+			ListenAddress:          "127.0.0.1:9090",
+			QueryIntervalInSeconds: 60,
+			MaxFileSizeInMB:        10,
+			Ratio:                  0.5,
+			MinRatio:               &minRatio,
+			Hosts:                  []string{"h1", "h2"},
+			Alarms: testSubConfig{
+				Enabled:     true,
+				PushoverURL: "https://pushover",
+				Hour:        12,
+			},
+			Endpoints: []testItemConfig{
+				{Name: "a"},
+				{Name: "c", Tags: map[string]string{"shard": "0", "vm": "VM1"}},
+			},
+			unexported: "unchanged",
+		}
+		assert.Equal(t, expectedCfg, cfg)
+		assert.Equal(t, []string{
+			"APP_LISTEN_ADDRESS",
+			"APP_MAX_FILE_SIZE_IN_MB",
+			"APP_RATIO",
+			"APP_MIN_RATIO",
+			"APP_HOSTS",
+			"APP_ALARMS_ENABLED",
+			"APP_ALARMS_PUSHOVER_URL",
+			"APP_ENDPOINTS_1_NAME",
+			"APP_ENDPOINTS_1_TAGS",
+		}, applied)
+	})
+}
@@ -0,0 +1,6 @@
+package faults
+
+import "errors"
+
+// ErrInjected is the default error returned by the injected faults
+var ErrInjected = errors.New("injected fault")
@@ -0,0 +1,128 @@
+package faults
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Failure points of the services, hit before the guarded operation
+const (
+	// PointAgentReport is hit before each report sent by the agent, an injected error drops the report
+	PointAgentReport = "agent.report"
+	// PointStorageWrite is hit before each metric value written by the aggregation service, an injected error fails
+	// the write as a database error would
+	PointStorageWrite = "aggregation.storage.write"
+)
+
+// Fault defines the failure injected in a point
+type Fault struct {
+	// Latency delays the operation, or until its context is done
+	Latency time.Duration
+	// Err fails the operation after the latency, nil lets it continue
+	Err error
+	// Times is the number of hits the fault is injected in, 0 injects it until cleared
+	Times int
+}
+
+// Injector holds the faults injected in the failure points and counts their hits, so the tests can wait for an
+// operation to happen instead of sleeping. It is safe for concurrent use. No fault is injected until set.
+type Injector struct {
+	mut     sync.Mutex
+	faults  map[string]*Fault
+	hits    map[string]int
+	changed chan struct{}
+}
+
+// NewInjector creates an injector without any fault
+func NewInjector() *Injector {
+	return &Injector{
+		faults:  make(map[string]*Fault),
+		hits:    make(map[string]int),
+		changed: make(chan struct{}),
+	}
+}
+
+// Set injects the fault in the point, replacing the previous one
+func (injector *Injector) Set(point string, fault Fault) {
+	injector.mut.Lock()
+	defer injector.mut.Unlock()
+
+	injector.faults[point] = &fault
+}
+
+// Clear removes the fault of the point
+func (injector *Injector) Clear(point string) {
+	injector.mut.Lock()
+	defer injector.mut.Unlock()
+
+	delete(injector.faults, point)
+}
+
+// Inject records a hit of the point and applies its fault, if any: waits the latency and returns the error
+func (injector *Injector) Inject(ctx context.Context, point string) error {
+	injector.mut.Lock()
+	injector.hits[point]++
+	close(injector.changed)
+	injector.changed = make(chan struct{})
+
+	fault, found := injector.faults[point]
+	if !found {
+		injector.mut.Unlock()
+		return nil
+	}
+	applied := *fault
+	if fault.Times > 0 {
+		fault.Times--
+		if fault.Times == 0 {
+			delete(injector.faults, point)
+		}
+	}
+	injector.mut.Unlock()
+
+	if applied.Latency > 0 {
+		timer := time.NewTimer(applied.Latency)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return applied.Err
+}
+
+// Hits returns the number of times the point was hit
+func (injector *Injector) Hits(point string) int {
+	injector.mut.Lock()
+	defer injector.mut.Unlock()
+
+	return injector.hits[point]
+}
+
+// WaitHits blocks until the point was hit at least numHits times or the context is done
+func (injector *Injector) WaitHits(ctx context.Context, point string, numHits int) error {
+	for {
+		injector.mut.Lock()
+		hits := injector.hits[point]
+		changed := injector.changed
+		injector.mut.Unlock()
+
+		if hits >= numHits {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// IsInterfaceNil returns true if there is no value under the interface
+func (injector *Injector) IsInterfaceNil() bool {
+	return injector == nil
+}
@@ -0,0 +1,82 @@
+package faults
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInjector_Inject(t *testing.T) {
+	t.Parallel()
+
+	t.Run("no fault should count the hits", func(t *testing.T) {
+		t.Parallel()
+
+		injector := NewInjector()
+		assert.False(t, injector.IsInterfaceNil())
+		assert.Nil(t, injector.Inject(context.Background(), PointAgentReport))
+		assert.Nil(t, injector.Inject(context.Background(), PointAgentReport))
+		assert.Equal(t, 2, injector.Hits(PointAgentReport))
+		assert.Zero(t, injector.Hits(PointStorageWrite))
+	})
+	t.Run("fault should be injected the given number of times", func(t *testing.T) {
+		t.Parallel()
+
+		injector := NewInjector()
+		injector.Set(PointAgentReport, Fault{Err: ErrInjected, Times: 2})
+		assert.Equal(t, ErrInjected, injector.Inject(context.Background(), PointAgentReport))
+		assert.Nil(t, injector.Inject(context.Background(), PointStorageWrite))
+		assert.Equal(t, ErrInjected, injector.Inject(context.Background(), PointAgentReport))
+		assert.Nil(t, injector.Inject(context.Background(), PointAgentReport))
+	})
+	t.Run("fault should be injected until cleared", func(t *testing.T) {
+		t.Parallel()
+
+		expectedErr := errors.New("database is locked")
+		injector := NewInjector()
+		injector.Set(PointStorageWrite, Fault{Err: expectedErr})
+		for i := 0; i < 5; i++ {
+			assert.Equal(t, expectedErr, injector.Inject(context.Background(), PointStorageWrite))
+		}
+		injector.Clear(PointStorageWrite)
+		assert.Nil(t, injector.Inject(context.Background(), PointStorageWrite))
+	})
+	t.Run("latency should delay the operation", func(t *testing.T) {
+		t.Parallel()
+
+		injector := NewInjector()
+		injector.Set(PointAgentReport, Fault{Latency: 50 * time.Millisecond})
+		start := time.Now()
+		assert.Nil(t, injector.Inject(context.Background(), PointAgentReport))
+		assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		injector.Set(PointAgentReport, Fault{Latency: time.Hour})
+		assert.Equal(t, context.Canceled, injector.Inject(ctx, PointAgentReport))
+	})
+}
+
+func TestInjector_WaitHits(t *testing.T) {
+	t.Parallel()
+
+	injector := NewInjector()
+	go func() {
+		for i := 0; i < 3; i++ {
+			_ = injector.Inject(context.Background(), PointAgentReport)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.Nil(t, injector.WaitHits(ctx, PointAgentReport, 3))
+	assert.Equal(t, 3, injector.Hits(PointAgentReport))
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, injector.WaitHits(ctx, PointStorageWrite, 1))
+}
@@ -0,0 +1,57 @@
+package commonGo
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HealthCheckPath is the path of the health endpoint of both services
+const HealthCheckPath = "/healthz"
+
+const maxHealthCheckBodySize = 1024
+
+// LocalHealthCheckURL returns the URL the local health endpoint is reached at, for a service listening on the
+// provided address. The wildcard and the empty hosts are replaced by the loopback address.
+func LocalHealthCheckURL(listenAddress string, basePath string) (string, error) {
+	host, port, err := net.SplitHostPort(listenAddress)
+	if err != nil {
+		return "", fmt.Errorf("invalid listen address %q: %w", listenAddress, err)
+	}
+	if len(host) == 0 || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+
+	return "http://" + net.JoinHostPort(host, port) + strings.TrimSuffix(basePath, "/") + HealthCheckPath, nil
+}
+
+// CheckHealth queries the health endpoint, returning an error if it can not be reached or does not respond with a
+// 2xx status code. It backs the healthcheck subcommands used by the Docker HEALTHCHECK directives.
+func CheckHealth(url string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxHealthCheckBodySize))
+		return fmt.Errorf("unhealthy, status code %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package commonGo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalHealthCheckURL(t *testing.T) {
+	t.Parallel()
+
+	url, err := LocalHealthCheckURL(":8090", "")
+	require.Nil(t, err)
+	assert.Equal(t, "http://127.0.0.1:8090/healthz", url)
+
+	url, err = LocalHealthCheckURL("0.0.0.0:8090", "/monitoring/")
+	require.Nil(t, err)
+	assert.Equal(t, "http://127.0.0.1:8090/monitoring/healthz", url)
+
+	url, err = LocalHealthCheckURL("[::]:9090", "")
+	require.Nil(t, err)
+	assert.Equal(t, "http://127.0.0.1:9090/healthz", url)
+
+	url, err = LocalHealthCheckURL("[::1]:9090", "")
+	require.Nil(t, err)
+	assert.Equal(t, "http://[::1]:9090/healthz", url)
+
+	_, err = LocalHealthCheckURL("localhost", "")
+	assert.NotNil(t, err)
+}
+
+func TestCheckHealth(t *testing.T) {
+	t.Parallel()
+
+	status := atomic.Int32{}
+	status.Store(http.StatusOK)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(status.Load()))
+		_, _ = w.Write([]byte(`{"status":"draining"}`))
+	}))
+	defer server.Close()
+
+	assert.Nil(t, CheckHealth(server.URL, time.Second))
+
+	status.Store(http.StatusServiceUnavailable)
+	err := CheckHealth(server.URL, time.Second)
+	require.NotNil(t, err)
+	assert.Equal(t, `unhealthy, status code 503: {"status":"draining"}`, err.Error())
+
+	server.Close()
+	assert.NotNil(t, CheckHealth(server.URL, time.Second))
+}
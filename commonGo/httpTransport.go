@@ -0,0 +1,107 @@
+package commonGo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// the dialer settings of http.DefaultTransport
+const (
+	defaultDialTimeout   = 30 * time.Second
+	defaultDialKeepAlive = 30 * time.Second
+)
+
+// IP families of the outbound connections
+const (
+	IPFamilyAny  = ""
+	IPFamilyIPv4 = "ipv4"
+	IPFamilyIPv6 = "ipv6"
+)
+
+// ErrInvalidProxyURL signals a proxy URL without a host or with an unsupported scheme
+var ErrInvalidProxyURL = errors.New("invalid proxy URL")
+
+// ErrInvalidIPFamily signals an IP family other than ipv4 or ipv6
+var ErrInvalidIPFamily = errors.New("invalid IP family")
+
+// ArgsHTTPTransport defines the DTO struct for the NewHTTPTransport constructor function
+type ArgsHTTPTransport struct {
+	// ProxyURL is the http://, https://, socks5:// or socks5h:// proxy of the requests. Empty uses the HTTP_PROXY,
+	// HTTPS_PROXY and NO_PROXY environment variables.
+	ProxyURL string
+	// IPFamily restricts the connections to ipv4 or ipv6, empty allows both
+	IPFamily string
+	// DisableKeepAlives opens a new connection for each request
+	DisableKeepAlives bool
+}
+
+// NewHTTPTransport creates an HTTP transport with the settings of http.DefaultTransport, going through the proxy and
+// connecting over the IP family of the provided arguments. With a proxy, the IP family applies to the connection to
+// the proxy.
+func NewHTTPTransport(args ArgsHTTPTransport) (*http.Transport, error) {
+	network, err := dialNetwork(args.IPFamily)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DisableKeepAlives = args.DisableKeepAlives
+	if len(args.ProxyURL) > 0 {
+		proxyURL, errParse := ParseProxyURL(args.ProxyURL)
+		if errParse != nil {
+			return nil, errParse
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	if network != "tcp" {
+		dialer := &net.Dialer{
+			Timeout:   defaultDialTimeout,
+			KeepAlive: defaultDialKeepAlive,
+		}
+		transport.DialContext = func(ctx context.Context, _ string, address string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, address)
+		}
+	}
+
+	return transport, nil
+}
+
+// ParseProxyURL parses a proxy URL with the http, https, socks5 or socks5h scheme
+func ParseProxyURL(value string) (*url.URL, error) {
+	parsed, err := url.Parse(value)
+	if err != nil || len(parsed.Host) == 0 {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidProxyURL, value)
+	}
+
+	switch parsed.Scheme {
+	case "http", "https", "socks5", "socks5h":
+		return parsed, nil
+	default:
+		return nil, fmt.Errorf("%w: %q, the scheme must be http, https, socks5 or socks5h", ErrInvalidProxyURL, value)
+	}
+}
+
+// IsValidIPFamily returns true if the value is empty, ipv4 or ipv6
+func IsValidIPFamily(value string) bool {
+	_, err := dialNetwork(value)
+
+	return err == nil
+}
+
+func dialNetwork(ipFamily string) (string, error) {
+	switch ipFamily {
+	case IPFamilyAny:
+		return "tcp", nil
+	case IPFamilyIPv4:
+		return "tcp4", nil
+	case IPFamilyIPv6:
+		return "tcp6", nil
+	default:
+		return "", fmt.Errorf("%w: %q, use %s or %s", ErrInvalidIPFamily, ipFamily, IPFamilyIPv4, IPFamilyIPv6)
+	}
+}
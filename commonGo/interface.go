@@ -1,6 +1,10 @@
 package commonGo
 
-import "time"
+import (
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/commonGo/clock"
+)
 
 // FileLoggingHandler will handle log file rotation
 type FileLoggingHandler interface {
@@ -8,3 +12,10 @@ type FileLoggingHandler interface {
 	Close() error
 	IsInterfaceNil() bool
 }
+
+// Clock defines the time source of the periodic jobs
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) clock.Timer
+	IsInterfaceNil() bool
+}
@@ -0,0 +1,114 @@
+package commonGo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	logger "github.com/multiversx/mx-chain-logger-go"
+)
+
+// Log output formats
+const (
+	LogFormatConsole = "console"
+	LogFormatJSON    = "json"
+)
+
+// the fields set by the JSON formatter, a log argument with one of these names is prefixed with argKeyPrefix
+var reservedLogFields = map[string]struct{}{
+	"time":      {},
+	"level":     {},
+	"service":   {},
+	"component": {},
+	"message":   {},
+}
+
+const argKeyPrefix = "arg_"
+
+// SetLogFormat replaces the standard output log observer with one writing the requested format. The service name is
+// added to each JSON log line, so the lines of the agents and of the aggregation service can be told apart once
+// ingested by a log aggregator.
+func SetLogFormat(format string, service string) error {
+	switch format {
+	case "", LogFormatConsole:
+		return nil
+	case LogFormatJSON:
+		err := logger.RemoveLogObserver(os.Stdout)
+		if err != nil {
+			return err
+		}
+
+		return logger.AddLogObserver(os.Stdout, NewJSONLogFormatter(service))
+	default:
+		return fmt.Errorf("unsupported log format %q, use %q or %q", format, LogFormatConsole, LogFormatJSON)
+	}
+}
+
+type jsonLogFormatter struct {
+	service string
+}
+
+// NewJSONLogFormatter creates a log formatter writing each log line as a JSON object with the time, level, service,
+// component (the logger name) and message fields, followed by the log arguments. The argument names are converted to
+// lower snake case, e.g. "num values" becomes "num_values".
+func NewJSONLogFormatter(service string) *jsonLogFormatter {
+	return &jsonLogFormatter{
+		service: service,
+	}
+}
+
+// Output converts the log line into a JSON object, terminated by a new line
+func (formatter *jsonLogFormatter) Output(line logger.LogLineHandler) []byte {
+	if line == nil {
+		return nil
+	}
+
+	buff := &bytes.Buffer{}
+	buff.WriteByte('{')
+	writeJSONField(buff, "time", time.Unix(0, line.GetTimestamp()).UTC().Format(time.RFC3339Nano), true)
+	writeJSONField(buff, "level", strings.ToLower(strings.TrimSpace(logger.LogLevel(line.GetLogLevel()).String())), false)
+	writeJSONField(buff, "service", formatter.service, false)
+	writeJSONField(buff, "component", line.GetLoggerName(), false)
+	writeJSONField(buff, "message", line.GetMessage(), false)
+
+	args := line.GetArgs()
+	for i := 1; i < len(args); i += 2 {
+		writeJSONField(buff, logArgKey(args[i-1]), args[i], false)
+	}
+	buff.WriteString("}\n")
+
+	return buff.Bytes()
+}
+
+func logArgKey(name string) string {
+	key := strings.ToLower(strings.Join(strings.Fields(name), "_"))
+	if len(key) == 0 {
+		key = "arg"
+	}
+	_, reserved := reservedLogFields[key]
+	if reserved {
+		key = argKeyPrefix + key
+	}
+
+	return key
+}
+
+func writeJSONField(buff *bytes.Buffer, key string, value string, first bool) {
+	if !first {
+		buff.WriteByte(',')
+	}
+
+	keyBytes, _ := json.Marshal(key)
+	valueBytes, _ := json.Marshal(value)
+	buff.Write(keyBytes)
+	buff.WriteByte(':')
+	buff.Write(valueBytes)
+}
+
+// IsInterfaceNil returns true if there is no value under the interface
+func (formatter *jsonLogFormatter) IsInterfaceNil() bool {
+	return formatter == nil
+}
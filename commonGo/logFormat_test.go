@@ -0,0 +1,52 @@
+package commonGo
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	logger "github.com/multiversx/mx-chain-logger-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONLogFormatter_Output(t *testing.T) {
+	t.Parallel()
+
+	formatter := NewJSONLogFormatter("agent")
+	assert.False(t, formatter.IsInterfaceNil())
+	assert.Nil(t, formatter.Output(nil))
+
+	timestamp := time.Date(2026, 1, 2, 3, 4, 5, 6000000, time.UTC)
+	line := &logger.LogLineWrapper{}
+	line.LoggerName = "reporter"
+	line.Message = `report "failed"`
+	line.LogLevel = int32(logger.LogWarning)
+	line.Timestamp = timestamp.UnixNano()
+	line.Args = []string{"metric", "VM1.Node1.nonce", "num values", "3", "message", "shadowed", "odd"}
+
+	output := formatter.Output(line)
+	require.True(t, len(output) > 0)
+	assert.Equal(t, byte('\n'), output[len(output)-1])
+
+	fields := make(map[string]string)
+	require.Nil(t, json.Unmarshal(output, &fields))
+	assert.Equal(t, map[string]string{
+		"time":        "2026-01-02T03:04:05.006Z",
+		"level":       "warn",
+		"service":     "agent",
+		"component":   "reporter",
+		"message":     `report "failed"`,
+		"metric":      "VM1.Node1.nonce",
+		"num_values":  "3",
+		"arg_message": "shadowed",
+	}, fields)
+}
+
+func TestSetLogFormat(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, SetLogFormat("", "agent"))
+	assert.Nil(t, SetLogFormat(LogFormatConsole, "agent"))
+	assert.Equal(t, `unsupported log format "xml", use "console" or "json"`, SetLogFormat("xml", "agent").Error())
+}
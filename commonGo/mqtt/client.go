@@ -0,0 +1,496 @@
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	logger "github.com/multiversx/mx-chain-logger-go"
+)
+
+var log = logger.GetOrCreate("mqtt")
+
+const (
+	defaultPort      = "1883"
+	defaultTLSPort   = "8883"
+	defaultKeepAlive = 30 * time.Second
+	defaultTimeout   = 10 * time.Second
+)
+
+// MessageHandler is called for each message received on the subscribed topics
+type MessageHandler func(topic string, payload []byte)
+
+// ArgsClient defines the DTO struct for the NewClient constructor function
+type ArgsClient struct {
+	// BrokerURL is the broker address: tcp:// or mqtt:// for plain connections, ssl://, tls:// or mqtts:// for TLS
+	BrokerURL string
+	ClientID  string
+	Username  string
+	Password  string
+	KeepAlive time.Duration
+	Timeout   time.Duration
+	// OnMessage receives the messages of the subscribed topics, it is called from the connection read loop
+	OnMessage MessageHandler
+}
+
+// client is a minimal MQTT 3.1.1 client supporting QoS 0 and 1 publishing and subscribing, over a single connection
+type client struct {
+	address   string
+	useTLS    bool
+	serverTLS string
+	clientID  string
+	username  string
+	password  string
+	keepAlive time.Duration
+	timeout   time.Duration
+	onMessage MessageHandler
+
+	mutConn      sync.Mutex
+	conn         net.Conn
+	done         chan struct{}
+	mutPending   sync.Mutex
+	pending      map[uint16]chan *packet
+	lastPacketID uint16
+}
+
+// NewClient creates a new MQTT client. Connect has to be called before publishing or subscribing.
+func NewClient(args ArgsClient) (*client, error) {
+	address, useTLS, err := parseBrokerURL(args.BrokerURL)
+	if err != nil {
+		return nil, err
+	}
+	if len(args.ClientID) == 0 {
+		return nil, errEmptyClientID
+	}
+
+	c := &client{
+		address:   address,
+		useTLS:    useTLS,
+		clientID:  args.ClientID,
+		username:  args.Username,
+		password:  args.Password,
+		keepAlive: args.KeepAlive,
+		timeout:   args.Timeout,
+		onMessage: args.OnMessage,
+		pending:   make(map[uint16]chan *packet),
+	}
+	c.serverTLS, _, _ = net.SplitHostPort(address)
+	if c.keepAlive <= 0 {
+		c.keepAlive = defaultKeepAlive
+	}
+	if c.timeout <= 0 {
+		c.timeout = defaultTimeout
+	}
+
+	return c, nil
+}
+
+func parseBrokerURL(brokerURL string) (string, bool, error) {
+	brokerURL = strings.TrimSpace(brokerURL)
+	if len(brokerURL) == 0 {
+		return "", false, errEmptyBrokerURL
+	}
+
+	parsed, err := url.Parse(brokerURL)
+	if err != nil {
+		return "", false, fmt.Errorf("%w: %s", errInvalidBrokerURL, err.Error())
+	}
+
+	useTLS := false
+	port := defaultPort
+	switch parsed.Scheme {
+	case "tcp", "mqtt":
+	case "ssl", "tls", "mqtts":
+		useTLS = true
+		port = defaultTLSPort
+	default:
+		return "", false, fmt.Errorf("%w: unsupported scheme %q", errInvalidBrokerURL, parsed.Scheme)
+	}
+	if len(parsed.Hostname()) == 0 {
+		return "", false, fmt.Errorf("%w: empty host", errInvalidBrokerURL)
+	}
+	if len(parsed.Port()) > 0 {
+		port = parsed.Port()
+	}
+
+	return net.JoinHostPort(parsed.Hostname(), port), useTLS, nil
+}
+
+// Connect establishes a new connection to the broker, closing the previous one, if any
+func (c *client) Connect(ctx context.Context) error {
+	c.closeConnection()
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(conn)
+	err = c.handshake(conn, reader)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+
+	done := make(chan struct{})
+	c.mutConn.Lock()
+	c.conn = conn
+	c.done = done
+	c.mutConn.Unlock()
+
+	go c.readLoop(conn, reader, done)
+	go c.keepAliveLoop(conn, done)
+
+	log.Debug("connected to the MQTT broker", "address", c.address, "client ID", c.clientID)
+
+	return nil
+}
+
+func (c *client) dial(ctx context.Context) (net.Conn, error) {
+	if !c.useTLS {
+		dialer := &net.Dialer{}
+		return dialer.DialContext(ctx, "tcp", c.address)
+	}
+
+	dialer := &tls.Dialer{
+		Config: &tls.Config{
+			ServerName: c.serverTLS,
+			MinVersion: tls.VersionTLS12,
+		},
+	}
+
+	return dialer.DialContext(ctx, "tcp", c.address)
+}
+
+func (c *client) handshake(conn net.Conn, reader *bufio.Reader) error {
+	_ = conn.SetDeadline(time.Now().Add(c.timeout))
+	defer func() {
+		_ = conn.SetDeadline(time.Time{})
+	}()
+
+	data, err := encodeConnect(c.clientID, c.username, c.password, uint16(c.keepAlive.Seconds()))
+	if err != nil {
+		return err
+	}
+	_, err = conn.Write(data)
+	if err != nil {
+		return err
+	}
+
+	p, err := readPacket(reader)
+	if err != nil {
+		return err
+	}
+	if p.packetType != packetConnAck || len(p.body) < 2 {
+		return fmt.Errorf("%w: expected CONNACK, got type %d", errUnexpectedPacket, p.packetType)
+	}
+	if p.body[1] != 0 {
+		return fmt.Errorf("%w, return code %d", errConnectionRefused, p.body[1])
+	}
+
+	return nil
+}
+
+// readLoop dispatches the incoming packets until the connection is closed
+func (c *client) readLoop(conn net.Conn, reader *bufio.Reader, done chan struct{}) {
+	defer func() {
+		c.dropConnection(conn, done)
+	}()
+
+	for {
+		p, err := readPacket(reader)
+		if err != nil {
+			return
+		}
+
+		switch p.packetType {
+		case packetPublish:
+			c.handlePublish(conn, p)
+		case packetPubAck, packetSubAck:
+			c.notifyPending(p)
+		case packetPingResp:
+		default:
+			log.Debug("ignored unexpected MQTT packet", "type", p.packetType)
+		}
+	}
+}
+
+func (c *client) handlePublish(conn net.Conn, p *packet) {
+	topic, packetID, payload, err := decodePublish(p)
+	if err != nil {
+		log.Warn("received malformed MQTT message", "error", err)
+		return
+	}
+
+	if packetID != 0 {
+		ack, _ := encodePacketID(packetPubAck, packetID)
+		_ = c.write(conn, ack)
+	}
+	if c.onMessage != nil {
+		c.onMessage(topic, payload)
+	}
+}
+
+func (c *client) notifyPending(p *packet) {
+	packetID, err := readPacketID(p)
+	if err != nil {
+		return
+	}
+
+	c.mutPending.Lock()
+	ch, found := c.pending[packetID]
+	delete(c.pending, packetID)
+	c.mutPending.Unlock()
+
+	if found {
+		ch <- p
+	}
+}
+
+func (c *client) keepAliveLoop(conn net.Conn, done chan struct{}) {
+	// ping well before the broker considers the connection lost (1.5 keep-alive periods)
+	ticker := time.NewTicker(c.keepAlive / 2)
+	defer ticker.Stop()
+
+	ping, _ := encodePacket(packetPingReq, 0, nil)
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			err := c.write(conn, ping)
+			if err != nil {
+				_ = conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// Publish sends the message on the topic. With QoS 1 it waits for the broker acknowledgement.
+func (c *client) Publish(ctx context.Context, topic string, payload []byte, qos byte) error {
+	if len(topic) == 0 {
+		return errEmptyTopic
+	}
+	if !IsValidPublishTopic(topic) {
+		return errWildcardInPublished
+	}
+	if qos > 1 {
+		return errInvalidQoS
+	}
+
+	conn, done, err := c.getConnection()
+	if err != nil {
+		return err
+	}
+
+	if qos == 0 {
+		data, errEncode := encodePublish(topic, payload, qos, 0)
+		if errEncode != nil {
+			return errEncode
+		}
+
+		return c.write(conn, data)
+	}
+
+	packetID, ack := c.registerPending()
+	defer c.unregisterPending(packetID)
+
+	data, err := encodePublish(topic, payload, qos, packetID)
+	if err != nil {
+		return err
+	}
+	err = c.write(conn, data)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.waitAcknowledge(ctx, ack, done)
+
+	return err
+}
+
+// Subscribe subscribes to the topic filter, waiting for the broker acknowledgement
+func (c *client) Subscribe(ctx context.Context, topicFilter string, qos byte) error {
+	if len(topicFilter) == 0 {
+		return errEmptyTopic
+	}
+	if qos > 1 {
+		return errInvalidQoS
+	}
+
+	conn, done, err := c.getConnection()
+	if err != nil {
+		return err
+	}
+
+	packetID, ack := c.registerPending()
+	defer c.unregisterPending(packetID)
+
+	data, err := encodeSubscribe(topicFilter, qos, packetID)
+	if err != nil {
+		return err
+	}
+	err = c.write(conn, data)
+	if err != nil {
+		return err
+	}
+
+	p, err := c.waitAcknowledge(ctx, ack, done)
+	if err != nil {
+		return err
+	}
+	if p.packetType != packetSubAck || len(p.body) < 3 {
+		return fmt.Errorf("%w: expected SUBACK, got type %d", errUnexpectedPacket, p.packetType)
+	}
+	if p.body[2] == subAckFailureCode {
+		return fmt.Errorf("%w: %s", errSubscriptionFailed, topicFilter)
+	}
+
+	return nil
+}
+
+func (c *client) waitAcknowledge(ctx context.Context, ack chan *packet, done chan struct{}) (*packet, error) {
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+
+	select {
+	case p := <-ack:
+		return p, nil
+	case <-done:
+		return nil, errConnectionClosed
+	case <-timer.C:
+		return nil, errAcknowledgeTimeout
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *client) registerPending() (uint16, chan *packet) {
+	c.mutPending.Lock()
+	defer c.mutPending.Unlock()
+
+	// the packet IDs are non-zero 16 bits values
+	c.lastPacketID++
+	if c.lastPacketID == 0 {
+		c.lastPacketID = 1
+	}
+
+	ack := make(chan *packet, 1)
+	c.pending[c.lastPacketID] = ack
+
+	return c.lastPacketID, ack
+}
+
+func (c *client) unregisterPending(packetID uint16) {
+	c.mutPending.Lock()
+	defer c.mutPending.Unlock()
+
+	delete(c.pending, packetID)
+}
+
+func (c *client) write(conn net.Conn, data []byte) error {
+	c.mutConn.Lock()
+	defer c.mutConn.Unlock()
+
+	_ = conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	_, err := conn.Write(data)
+
+	return err
+}
+
+func (c *client) getConnection() (net.Conn, chan struct{}, error) {
+	c.mutConn.Lock()
+	defer c.mutConn.Unlock()
+
+	if c.conn == nil {
+		return nil, nil, errNotConnected
+	}
+
+	return c.conn, c.done, nil
+}
+
+// IsConnected returns true if the client holds a live connection to the broker
+func (c *client) IsConnected() bool {
+	c.mutConn.Lock()
+	defer c.mutConn.Unlock()
+
+	return c.conn != nil
+}
+
+// Done returns a channel that is closed when the current connection is lost. It returns a closed channel when the
+// client is not connected.
+func (c *client) Done() <-chan struct{} {
+	c.mutConn.Lock()
+	defer c.mutConn.Unlock()
+
+	if c.done == nil {
+		closed := make(chan struct{})
+		close(closed)
+		return closed
+	}
+
+	return c.done
+}
+
+// dropConnection forgets the connection, if it is still the current one
+func (c *client) dropConnection(conn net.Conn, done chan struct{}) {
+	c.mutConn.Lock()
+	defer c.mutConn.Unlock()
+
+	_ = conn.Close()
+	if c.conn == conn {
+		c.conn = nil
+		c.done = nil
+	}
+	close(done)
+}
+
+func (c *client) closeConnection() {
+	c.mutConn.Lock()
+	conn := c.conn
+	done := c.done
+	c.mutConn.Unlock()
+	if conn == nil {
+		return
+	}
+
+	disconnect, _ := encodePacket(packetDisconnect, 0, nil)
+	_ = c.write(conn, disconnect)
+	_ = conn.Close()
+
+	// wait for the read loop to release the connection, so a new one can be established
+	<-done
+}
+
+// Close disconnects from the broker
+func (c *client) Close() error {
+	c.closeConnection()
+
+	return nil
+}
+
+// IsInterfaceNil returns true if there is no value under the interface
+func (c *client) IsInterfaceNil() bool {
+	return c == nil
+}
+
+// IsValidBrokerURL returns true if the value is a broker URL the client can connect to
+func IsValidBrokerURL(brokerURL string) bool {
+	_, _, err := parseBrokerURL(brokerURL)
+	return err == nil
+}
+
+// IsValidPublishTopic returns true if the value can be used as a published topic (not empty, without wildcards)
+func IsValidPublishTopic(topic string) bool {
+	return len(topic) > 0 && !strings.ContainsAny(topic, "+#")
+}
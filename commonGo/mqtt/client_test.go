@@ -0,0 +1,359 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// brokerMock is a minimal MQTT broker: it accepts the connections, acknowledges the subscriptions and routes the
+// published messages to the matching subscribers
+type brokerMock struct {
+	listener      net.Listener
+	mut           sync.Mutex
+	subscriptions map[net.Conn][]string
+	usernames     []string
+	refuseCode    byte
+	rejectTopics  map[string]struct{}
+}
+
+func newBrokerMock(t *testing.T) *brokerMock {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+
+	broker := &brokerMock{
+		listener:      listener,
+		subscriptions: make(map[net.Conn][]string),
+		rejectTopics:  make(map[string]struct{}),
+	}
+	go broker.acceptLoop()
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+
+	return broker
+}
+
+func (broker *brokerMock) url() string {
+	return "tcp://" + broker.listener.Addr().String()
+}
+
+func (broker *brokerMock) acceptLoop() {
+	for {
+		conn, err := broker.listener.Accept()
+		if err != nil {
+			return
+		}
+		go broker.serve(conn)
+	}
+}
+
+func (broker *brokerMock) serve(conn net.Conn) {
+	defer func() {
+		broker.mut.Lock()
+		delete(broker.subscriptions, conn)
+		broker.mut.Unlock()
+		_ = conn.Close()
+	}()
+
+	reader := bufio.NewReader(conn)
+	for {
+		p, err := readPacket(reader)
+		if err != nil {
+			return
+		}
+
+		switch p.packetType {
+		case packetConnect:
+			broker.handleConnect(conn, p)
+		case packetSubscribe:
+			packetID, _ := readPacketID(p)
+			topicFilter, _, _ := readString(p.body[2:])
+			code := byte(0)
+			broker.mut.Lock()
+			_, reject := broker.rejectTopics[topicFilter]
+			if reject {
+				code = subAckFailureCode
+			} else {
+				broker.subscriptions[conn] = append(broker.subscriptions[conn], topicFilter)
+			}
+			broker.mut.Unlock()
+
+			body := append(encodeUint16(packetID), code)
+			data, _ := encodePacket(packetSubAck, 0, body)
+			_, _ = conn.Write(data)
+		case packetPublish:
+			topic, packetID, payload, _ := decodePublish(p)
+			if packetID != 0 {
+				data, _ := encodePacketID(packetPubAck, packetID)
+				_, _ = conn.Write(data)
+			}
+			broker.route(topic, payload)
+		case packetPingReq:
+			data, _ := encodePacket(packetPingResp, 0, nil)
+			_, _ = conn.Write(data)
+		case packetDisconnect:
+			return
+		}
+	}
+}
+
+func (broker *brokerMock) handleConnect(conn net.Conn, p *packet) {
+	// protocol name, level, flags and keep-alive precede the client ID
+	_, rest, _ := readString(p.body)
+	flags := rest[1]
+	_, rest, _ = readString(rest[4:])
+	if flags&0x80 != 0 {
+		username, _, _ := readString(rest)
+		broker.mut.Lock()
+		broker.usernames = append(broker.usernames, username)
+		broker.mut.Unlock()
+	}
+
+	broker.mut.Lock()
+	code := broker.refuseCode
+	broker.mut.Unlock()
+
+	data, _ := encodePacket(packetConnAck, 0, []byte{0, code})
+	_, _ = conn.Write(data)
+}
+
+func (broker *brokerMock) route(topic string, payload []byte) {
+	broker.mut.Lock()
+	defer broker.mut.Unlock()
+
+	for conn, filters := range broker.subscriptions {
+		for _, filter := range filters {
+			if topicMatches(filter, topic) {
+				data, _ := encodePublish(topic, payload, 0, 0)
+				_, _ = conn.Write(data)
+				break
+			}
+		}
+	}
+}
+
+func (broker *brokerMock) closeConnections() {
+	broker.mut.Lock()
+	defer broker.mut.Unlock()
+
+	for conn := range broker.subscriptions {
+		_ = conn.Close()
+	}
+}
+
+func topicMatches(filter string, topic string) bool {
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+	for i, level := range filterLevels {
+		if level == "#" {
+			return true
+		}
+		if i >= len(topicLevels) {
+			return false
+		}
+		if level != "+" && level != topicLevels[i] {
+			return false
+		}
+	}
+
+	return len(filterLevels) == len(topicLevels)
+}
+
+func encodeUint16(value uint16) []byte {
+	return []byte{byte(value >> 8), byte(value)}
+}
+
+type receivedMessages struct {
+	mut      sync.Mutex
+	messages map[string][]string
+}
+
+func (received *receivedMessages) handle(topic string, payload []byte) {
+	received.mut.Lock()
+	defer received.mut.Unlock()
+
+	received.messages[topic] = append(received.messages[topic], string(payload))
+}
+
+func (received *receivedMessages) get(topic string) []string {
+	received.mut.Lock()
+	defer received.mut.Unlock()
+
+	return append([]string{}, received.messages[topic]...)
+}
+
+func TestNewClient(t *testing.T) {
+	t.Parallel()
+
+	t.Run("empty broker URL should error", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := NewClient(ArgsClient{ClientID: "id"})
+		assert.Nil(t, c)
+		assert.Equal(t, errEmptyBrokerURL, err)
+	})
+	t.Run("unsupported scheme should error", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := NewClient(ArgsClient{BrokerURL: "http://127.0.0.1", ClientID: "id"})
+		assert.Nil(t, c)
+		assert.ErrorIs(t, err, errInvalidBrokerURL)
+	})
+	t.Run("empty client ID should error", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := NewClient(ArgsClient{BrokerURL: "tcp://127.0.0.1"})
+		assert.Nil(t, c)
+		assert.Equal(t, errEmptyClientID, err)
+	})
+	t.Run("should apply the default ports", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := NewClient(ArgsClient{BrokerURL: "mqtt://broker.local", ClientID: "id"})
+		require.Nil(t, err)
+		assert.False(t, c.IsInterfaceNil())
+		assert.Equal(t, "broker.local:1883", c.address)
+		assert.False(t, c.useTLS)
+
+		c, err = NewClient(ArgsClient{BrokerURL: "mqtts://broker.local", ClientID: "id"})
+		require.Nil(t, err)
+		assert.Equal(t, "broker.local:8883", c.address)
+		assert.True(t, c.useTLS)
+		assert.Equal(t, "broker.local", c.serverTLS)
+	})
+}
+
+func TestClient_PublishSubscribe(t *testing.T) {
+	t.Parallel()
+
+	broker := newBrokerMock(t)
+	ctx := context.Background()
+
+	received := &receivedMessages{messages: make(map[string][]string)}
+	subscriber, err := NewClient(ArgsClient{
+		BrokerURL: broker.url(),
+		ClientID:  "subscriber",
+		Username:  "aggregation",
+		Password:  "secret",
+		Timeout:   time.Second,
+		OnMessage: received.handle,
+	})
+	require.Nil(t, err)
+
+	err = subscriber.Subscribe(ctx, "reports/+", 1)
+	assert.Equal(t, errNotConnected, err)
+
+	require.Nil(t, subscriber.Connect(ctx))
+	assert.True(t, subscriber.IsConnected())
+	require.Nil(t, subscriber.Subscribe(ctx, "reports/+", 1))
+
+	publisher, err := NewClient(ArgsClient{
+		BrokerURL: broker.url(),
+		ClientID:  "publisher",
+		Timeout:   time.Second,
+	})
+	require.Nil(t, err)
+	require.Nil(t, publisher.Connect(ctx))
+
+	assert.Equal(t, errWildcardInPublished, publisher.Publish(ctx, "reports/#", nil, 0))
+	assert.Equal(t, errInvalidQoS, publisher.Publish(ctx, "reports/vm1", nil, 2))
+	require.Nil(t, publisher.Publish(ctx, "reports/vm1", []byte("qos 1"), 1))
+	require.Nil(t, publisher.Publish(ctx, "reports/vm2", []byte(strings.Repeat("a", 300)), 0))
+	require.Nil(t, publisher.Publish(ctx, "other/vm1", []byte("not routed"), 0))
+
+	require.Eventually(t, func() bool {
+		return len(received.get("reports/vm1")) == 1 && len(received.get("reports/vm2")) == 1
+	}, time.Second, time.Millisecond*10)
+	assert.Equal(t, "qos 1", received.get("reports/vm1")[0])
+	assert.Len(t, received.get("reports/vm2")[0], 300)
+	assert.Empty(t, received.get("other/vm1"))
+
+	broker.mut.Lock()
+	assert.Equal(t, []string{"aggregation"}, broker.usernames)
+	broker.mut.Unlock()
+
+	// the connection loss is signaled
+	broker.closeConnections()
+	select {
+	case <-subscriber.Done():
+	case <-time.After(time.Second):
+		assert.Fail(t, "connection loss not signaled")
+	}
+	require.Eventually(t, func() bool {
+		return !subscriber.IsConnected()
+	}, time.Second, time.Millisecond*10)
+
+	require.Nil(t, publisher.Close())
+	assert.False(t, publisher.IsConnected())
+	assert.Equal(t, errNotConnected, publisher.Publish(ctx, "reports/vm1", nil, 0))
+	require.Nil(t, subscriber.Close())
+}
+
+func TestClient_BrokerRejections(t *testing.T) {
+	t.Parallel()
+
+	t.Run("refused connection should error", func(t *testing.T) {
+		t.Parallel()
+
+		broker := newBrokerMock(t)
+		broker.refuseCode = 5 // not authorized
+
+		c, _ := NewClient(ArgsClient{BrokerURL: broker.url(), ClientID: "id", Timeout: time.Second})
+		err := c.Connect(context.Background())
+		assert.ErrorIs(t, err, errConnectionRefused)
+		assert.False(t, c.IsConnected())
+	})
+	t.Run("rejected subscription should error", func(t *testing.T) {
+		t.Parallel()
+
+		broker := newBrokerMock(t)
+		broker.rejectTopics["forbidden/#"] = struct{}{}
+
+		c, _ := NewClient(ArgsClient{BrokerURL: broker.url(), ClientID: "id", Timeout: time.Second})
+		require.Nil(t, c.Connect(context.Background()))
+		defer func() {
+			_ = c.Close()
+		}()
+
+		err := c.Subscribe(context.Background(), "forbidden/#", 0)
+		assert.ErrorIs(t, err, errSubscriptionFailed)
+	})
+}
+
+func TestRemainingLength(t *testing.T) {
+	t.Parallel()
+
+	expectedSizes := map[int]int{
+		0:               1,
+		127:             1,
+		128:             2,
+		16383:           2,
+		16384:           3,
+		2097151:         3,
+		2097152:         4,
+		maxRemainingLen: 4,
+	}
+	for length, expectedSize := range expectedSizes {
+		encoded := appendRemainingLength(nil, length)
+		assert.Len(t, encoded, expectedSize)
+		if length > 16384 {
+			continue
+		}
+
+		data := append([]byte{packetPingReq << 4}, encoded...)
+		data = append(data, make([]byte, length)...)
+		p, err := readPacket(bufio.NewReader(bytes.NewReader(data)))
+		require.Nil(t, err)
+		assert.Equal(t, packetPingReq, p.packetType)
+		assert.Len(t, p.body, length)
+	}
+}
@@ -0,0 +1,20 @@
+package mqtt
+
+import "errors"
+
+var (
+	errEmptyBrokerURL      = errors.New("empty broker URL")
+	errInvalidBrokerURL    = errors.New("invalid broker URL")
+	errEmptyClientID       = errors.New("empty client ID")
+	errInvalidQoS          = errors.New("invalid QoS, only 0 and 1 are supported")
+	errNotConnected        = errors.New("not connected to the MQTT broker")
+	errConnectionRefused   = errors.New("MQTT broker refused the connection")
+	errSubscriptionFailed  = errors.New("MQTT broker rejected the subscription")
+	errMalformedPacket     = errors.New("malformed MQTT packet")
+	errPacketTooLarge      = errors.New("MQTT packet too large")
+	errAcknowledgeTimeout  = errors.New("timeout waiting for the MQTT broker acknowledgement")
+	errConnectionClosed    = errors.New("MQTT connection closed")
+	errUnexpectedPacket    = errors.New("unexpected MQTT packet")
+	errEmptyTopic          = errors.New("empty topic")
+	errWildcardInPublished = errors.New("the published topic can not contain wildcards")
+)
@@ -0,0 +1,183 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+)
+
+// MQTT 3.1.1 control packet types
+const (
+	packetConnect     byte = 1
+	packetConnAck     byte = 2
+	packetPublish     byte = 3
+	packetPubAck      byte = 4
+	packetSubscribe   byte = 8
+	packetSubAck      byte = 9
+	packetPingReq     byte = 12
+	packetPingResp    byte = 13
+	packetDisconnect  byte = 14
+	protocolLevel311  byte = 4
+	maxRemainingLen        = 268435455
+	subAckFailureCode byte = 0x80
+)
+
+// packet is a decoded MQTT control packet: the type, the fixed header flags and the remaining bytes
+type packet struct {
+	packetType byte
+	flags      byte
+	body       []byte
+}
+
+func encodePacket(packetType byte, flags byte, body []byte) ([]byte, error) {
+	if len(body) > maxRemainingLen {
+		return nil, errPacketTooLarge
+	}
+
+	buff := make([]byte, 0, len(body)+5)
+	buff = append(buff, packetType<<4|flags&0x0F)
+	buff = appendRemainingLength(buff, len(body))
+
+	return append(buff, body...), nil
+}
+
+// appendRemainingLength appends the variable length encoding: 7 bits per byte, the high bit marks a continuation
+func appendRemainingLength(buff []byte, length int) []byte {
+	for {
+		digit := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			digit |= 0x80
+		}
+		buff = append(buff, digit)
+		if length == 0 {
+			return buff
+		}
+	}
+}
+
+func readPacket(reader *bufio.Reader) (*packet, error) {
+	header, err := reader.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	length := 0
+	multiplier := 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return nil, errMalformedPacket
+		}
+
+		digit, errRead := reader.ReadByte()
+		if errRead != nil {
+			return nil, errRead
+		}
+		length += int(digit&0x7F) * multiplier
+		multiplier *= 128
+		if digit&0x80 == 0 {
+			break
+		}
+	}
+
+	body := make([]byte, length)
+	_, err = io.ReadFull(reader, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &packet{
+		packetType: header >> 4,
+		flags:      header & 0x0F,
+		body:       body,
+	}, nil
+}
+
+func appendString(buff []byte, value string) []byte {
+	buff = binary.BigEndian.AppendUint16(buff, uint16(len(value)))
+	return append(buff, value...)
+}
+
+func readString(body []byte) (string, []byte, error) {
+	if len(body) < 2 {
+		return "", nil, errMalformedPacket
+	}
+	length := int(binary.BigEndian.Uint16(body))
+	if len(body) < 2+length {
+		return "", nil, errMalformedPacket
+	}
+
+	return string(body[2 : 2+length]), body[2+length:], nil
+}
+
+func encodeConnect(clientID string, username string, password string, keepAliveInSec uint16) ([]byte, error) {
+	flags := byte(0x02) // clean session
+	if len(username) > 0 {
+		flags |= 0x80
+	}
+	if len(password) > 0 {
+		flags |= 0x40
+	}
+
+	body := appendString(nil, "MQTT")
+	body = append(body, protocolLevel311, flags)
+	body = binary.BigEndian.AppendUint16(body, keepAliveInSec)
+	body = appendString(body, clientID)
+	if len(username) > 0 {
+		body = appendString(body, username)
+	}
+	if len(password) > 0 {
+		body = appendString(body, password)
+	}
+
+	return encodePacket(packetConnect, 0, body)
+}
+
+func encodePublish(topic string, payload []byte, qos byte, packetID uint16) ([]byte, error) {
+	body := appendString(nil, topic)
+	if qos > 0 {
+		body = binary.BigEndian.AppendUint16(body, packetID)
+	}
+	body = append(body, payload...)
+
+	return encodePacket(packetPublish, qos<<1, body)
+}
+
+// decodePublish returns the topic, the packet ID (0 for QoS 0) and the payload of a PUBLISH packet
+func decodePublish(p *packet) (string, uint16, []byte, error) {
+	topic, rest, err := readString(p.body)
+	if err != nil {
+		return "", 0, nil, err
+	}
+
+	qos := (p.flags >> 1) & 0x03
+	if qos == 0 {
+		return topic, 0, rest, nil
+	}
+	if len(rest) < 2 {
+		return "", 0, nil, errMalformedPacket
+	}
+
+	return topic, binary.BigEndian.Uint16(rest), rest[2:], nil
+}
+
+func encodeSubscribe(topicFilter string, qos byte, packetID uint16) ([]byte, error) {
+	body := binary.BigEndian.AppendUint16(nil, packetID)
+	body = appendString(body, topicFilter)
+	body = append(body, qos)
+
+	// the SUBSCRIBE fixed header flags are reserved and must be 0010
+	return encodePacket(packetSubscribe, 0x02, body)
+}
+
+func encodePacketID(packetType byte, packetID uint16) ([]byte, error) {
+	return encodePacket(packetType, 0, binary.BigEndian.AppendUint16(nil, packetID))
+}
+
+func readPacketID(p *packet) (uint16, error) {
+	if len(p.body) < 2 {
+		return 0, errMalformedPacket
+	}
+
+	return binary.BigEndian.Uint16(p.body), nil
+}
@@ -0,0 +1,60 @@
+package commonGo
+
+import (
+	"bytes"
+	"io"
+	"mime"
+
+	"github.com/ugorji/go/codec"
+)
+
+// Media types of the request and response bodies
+const (
+	ContentTypeJSON    = "application/json"
+	ContentTypeMsgPack = "application/msgpack"
+	// ContentTypeMsgPackLegacy is the unregistered media type still sent by many MessagePack clients
+	ContentTypeMsgPackLegacy = "application/x-msgpack"
+)
+
+// msgPackHandle encodes the strings with the str family of the MessagePack spec and decodes them back in strings. The
+// struct fields are named after their json tags, so the same DTOs serve both encodings.
+var msgPackHandle = newMsgPackHandle()
+
+func newMsgPackHandle() *codec.MsgpackHandle {
+	handle := &codec.MsgpackHandle{}
+	handle.WriteExt = true
+	handle.RawToString = true
+
+	return handle
+}
+
+// MarshalMsgPack returns the MessagePack encoding of the value
+func MarshalMsgPack(value interface{}) ([]byte, error) {
+	buff := bytes.Buffer{}
+	err := codec.NewEncoder(&buff, msgPackHandle).Encode(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return buff.Bytes(), nil
+}
+
+// UnmarshalMsgPack decodes the MessagePack encoded data in the value
+func UnmarshalMsgPack(data []byte, value interface{}) error {
+	return DecodeMsgPack(bytes.NewReader(data), value)
+}
+
+// DecodeMsgPack decodes the MessagePack encoded value read from the reader
+func DecodeMsgPack(reader io.Reader, value interface{}) error {
+	return codec.NewDecoder(reader, msgPackHandle).Decode(value)
+}
+
+// IsMsgPackContentType returns true if the Content-Type (or Accept) value is one of the MessagePack media types
+func IsMsgPackContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return mediaType == ContentTypeMsgPack || mediaType == ContentTypeMsgPackLegacy
+}
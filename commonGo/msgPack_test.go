@@ -0,0 +1,62 @@
+package commonGo
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type msgPackTestDTO struct {
+	Value    string            `json:"value"`
+	Interval int               `json:"interval,omitempty"`
+	Tags     map[string]string `json:"tags,omitempty"`
+}
+
+func TestMsgPack(t *testing.T) {
+	t.Run("round trip should work", func(t *testing.T) {
+		original := map[string]msgPackTestDTO{
+			"VM1.Node1.nonce": {Value: "12345", Interval: 30, Tags: map[string]string{"vm": "VM1"}},
+			"VM1.Active":      {Value: "true"},
+		}
+
+		data, err := MarshalMsgPack(original)
+		require.Nil(t, err)
+
+		decoded := make(map[string]msgPackTestDTO)
+		err = UnmarshalMsgPack(data, &decoded)
+		require.Nil(t, err)
+		assert.Equal(t, original, decoded)
+	})
+	t.Run("fields are named after the json tags", func(t *testing.T) {
+		data, err := MarshalMsgPack(msgPackTestDTO{Value: "1"})
+		require.Nil(t, err)
+
+		decoded := make(map[string]interface{})
+		err = UnmarshalMsgPack(data, &decoded)
+		require.Nil(t, err)
+		assert.Equal(t, map[string]interface{}{"value": "1"}, decoded)
+	})
+	t.Run("smaller than json", func(t *testing.T) {
+		dto := msgPackTestDTO{Value: "12345", Interval: 30, Tags: map[string]string{"vm": "VM1"}}
+		data, err := MarshalMsgPack(dto)
+		require.Nil(t, err)
+		jsonData, err := json.Marshal(dto)
+		require.Nil(t, err)
+		assert.Less(t, len(data), len(jsonData))
+	})
+	t.Run("invalid data should error", func(t *testing.T) {
+		decoded := msgPackTestDTO{}
+		err := UnmarshalMsgPack([]byte{0xc1}, &decoded)
+		assert.NotNil(t, err)
+	})
+}
+
+func TestIsMsgPackContentType(t *testing.T) {
+	assert.True(t, IsMsgPackContentType("application/msgpack"))
+	assert.True(t, IsMsgPackContentType("application/x-msgpack"))
+	assert.True(t, IsMsgPackContentType("application/msgpack; charset=binary"))
+	assert.False(t, IsMsgPackContentType("application/json"))
+	assert.False(t, IsMsgPackContentType(""))
+}
@@ -0,0 +1,12 @@
+package release
+
+import "errors"
+
+// ErrInvalidKey signals an invalid ed25519 key
+var ErrInvalidKey = errors.New("invalid ed25519 key")
+
+// ErrInvalidSignature signals a release binary whose signature does not match
+var ErrInvalidSignature = errors.New("invalid release signature")
+
+// ErrInvalidVersion signals a version that can not be ordered against the release versions
+var ErrInvalidVersion = errors.New("invalid release version")
@@ -0,0 +1,87 @@
+package release
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// Manifest describes the latest agent release, it is published next to the binaries and polled by the agents
+type Manifest struct {
+	Version string `json:"version"`
+	// Binaries are indexed by platform, e.g. linux-amd64
+	Binaries map[string]Binary `json:"binaries"`
+}
+
+// Binary describes the release binary of a platform
+type Binary struct {
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"`
+	// Signature is the base64 ed25519 signature of the message returned by SignedMessage
+	Signature string `json:"signature"`
+}
+
+// Platform returns the platform of the running binary, the key of its release binary in the manifest
+func Platform() string {
+	return runtime.GOOS + "-" + runtime.GOARCH
+}
+
+// SignedMessage returns the message signed for a release binary. The version and the platform are part of it, so a
+// signed binary can not be served as another version (e.g. an older, vulnerable one) or for another platform.
+func SignedMessage(version string, platform string, sha256Hex string) []byte {
+	return []byte(fmt.Sprintf("api-monitoring-agent:%s:%s:%s", version, platform, strings.ToLower(sha256Hex)))
+}
+
+// GenerateKey returns a new hex encoded ed25519 key pair for signing the releases
+func GenerateKey() (publicKeyHex string, privateKeyHex string, err error) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+
+	return hex.EncodeToString(publicKey), hex.EncodeToString(privateKey.Seed()), nil
+}
+
+// ParsePublicKey decodes a hex encoded ed25519 public key
+func ParsePublicKey(publicKeyHex string) (ed25519.PublicKey, error) {
+	publicKey, err := hex.DecodeString(strings.TrimSpace(publicKeyHex))
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return nil, ErrInvalidKey
+	}
+
+	return publicKey, nil
+}
+
+// ParsePrivateKey decodes a hex encoded ed25519 private key seed, as returned by GenerateKey
+func ParsePrivateKey(privateKeyHex string) (ed25519.PrivateKey, error) {
+	seed, err := hex.DecodeString(strings.TrimSpace(privateKeyHex))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, ErrInvalidKey
+	}
+
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// Sign returns the base64 signature of a release binary
+func Sign(privateKey ed25519.PrivateKey, version string, platform string, sha256Hex string) string {
+	signature := ed25519.Sign(privateKey, SignedMessage(version, platform, sha256Hex))
+
+	return base64.StdEncoding.EncodeToString(signature)
+}
+
+// Verify checks the signature of a release binary
+func Verify(publicKey ed25519.PublicKey, version string, platform string, binary Binary) error {
+	signature, err := base64.StdEncoding.DecodeString(binary.Signature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if !ed25519.Verify(publicKey, SignedMessage(version, platform, binary.SHA256), signature) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
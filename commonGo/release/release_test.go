@@ -0,0 +1,66 @@
+package release
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	t.Parallel()
+
+	publicKeyHex, privateKeyHex, err := GenerateKey()
+	require.NoError(t, err)
+	publicKey, err := ParsePublicKey(publicKeyHex)
+	require.NoError(t, err)
+	privateKey, err := ParsePrivateKey(privateKeyHex)
+	require.NoError(t, err)
+
+	binary := Binary{
+		URL:    "https://releases.example.com/agent-linux-amd64",
+		SHA256: "ABCDEF0123",
+	}
+	binary.Signature = Sign(privateKey, "v1.2.0", "linux-amd64", binary.SHA256)
+	assert.NoError(t, Verify(publicKey, "v1.2.0", "linux-amd64", binary))
+
+	// the checksum is not case sensitive
+	binary.SHA256 = "abcdef0123"
+	assert.NoError(t, Verify(publicKey, "v1.2.0", "linux-amd64", binary))
+
+	// the signature is bound to the version and to the platform
+	assert.Equal(t, ErrInvalidSignature, Verify(publicKey, "v1.1.0", "linux-amd64", binary))
+	assert.Equal(t, ErrInvalidSignature, Verify(publicKey, "v1.2.0", "linux-arm64", binary))
+
+	tampered := binary
+	tampered.SHA256 = "abcdef0124"
+	assert.Equal(t, ErrInvalidSignature, Verify(publicKey, "v1.2.0", "linux-amd64", tampered))
+	tampered = binary
+	tampered.Signature = "not base64!"
+	assert.Equal(t, ErrInvalidSignature, Verify(publicKey, "v1.2.0", "linux-amd64", tampered))
+
+	otherPublicKeyHex, _, err := GenerateKey()
+	require.NoError(t, err)
+	otherPublicKey, err := ParsePublicKey(otherPublicKeyHex)
+	require.NoError(t, err)
+	assert.Equal(t, ErrInvalidSignature, Verify(otherPublicKey, "v1.2.0", "linux-amd64", binary))
+}
+
+func TestParseKeys(t *testing.T) {
+	t.Parallel()
+
+	_, err := ParsePublicKey("not hex")
+	assert.Equal(t, ErrInvalidKey, err)
+	_, err = ParsePublicKey("abcd")
+	assert.Equal(t, ErrInvalidKey, err)
+	_, err = ParsePrivateKey("abcd")
+	assert.Equal(t, ErrInvalidKey, err)
+
+	publicKeyHex, privateKeyHex, err := GenerateKey()
+	require.NoError(t, err)
+	_, err = ParsePublicKey(" " + publicKeyHex + "\n")
+	assert.NoError(t, err)
+	privateKey, err := ParsePrivateKey(privateKeyHex + "\n")
+	require.NoError(t, err)
+	assert.Len(t, privateKey, 64)
+}
@@ -0,0 +1,58 @@
+package release
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// versionFormat matches the versions the binaries are built with (git describe --tags --long --dirty), e.g.
+// v1.2.3-5-g1a2b3c4-dirty, the number of commits since the tag ranking the builds of the same tag
+var versionFormat = regexp.MustCompile(`^v?(\d+)(?:\.(\d+))?(?:\.(\d+))?(?:-(\d+)-g[0-9a-f]+)?(?:-dirty)?$`)
+
+// CompareVersions returns -1, 0 or 1 when the version a is older than, the same as or newer than the version b. The
+// versions that can not be ordered (e.g. a development build) are reported as an error.
+func CompareVersions(a string, b string) (int, error) {
+	partsA, err := parseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	partsB, err := parseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range partsA {
+		if partsA[i] < partsB[i] {
+			return -1, nil
+		}
+		if partsA[i] > partsB[i] {
+			return 1, nil
+		}
+	}
+
+	return 0, nil
+}
+
+// parseVersion returns the major, minor, patch and commits since the tag numbers of a version
+func parseVersion(version string) ([4]uint64, error) {
+	parts := [4]uint64{}
+	matches := versionFormat.FindStringSubmatch(strings.TrimSpace(version))
+	if matches == nil {
+		return parts, fmt.Errorf("%w: %q", ErrInvalidVersion, version)
+	}
+
+	for i, match := range matches[1:] {
+		if len(match) == 0 {
+			continue
+		}
+		value, err := strconv.ParseUint(match, 10, 64)
+		if err != nil {
+			return parts, fmt.Errorf("%w: %q", ErrInvalidVersion, version)
+		}
+		parts[i] = value
+	}
+
+	return parts, nil
+}
@@ -0,0 +1,39 @@
+package release
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareVersions(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		a        string
+		b        string
+		expected int
+	}{
+		{a: "v1.2.3", b: "v1.2.3", expected: 0},
+		{a: "v1.2.3", b: "v1.2.4", expected: -1},
+		{a: "v1.10.0", b: "v1.9.9", expected: 1},
+		{a: "v2", b: "v1.9.9", expected: 1},
+		{a: "1.2", b: "v1.2.0", expected: 0},
+		{a: "v1.2.3-0-g1a2b3c4", b: "v1.2.3-5-g5d6e7f8", expected: -1},
+		{a: "v1.2.3-5-g5d6e7f8-dirty", b: "v1.2.3-5-g5d6e7f8", expected: 0},
+		{a: "v1.2.4-0-g1a2b3c4", b: "v1.2.3-12-g5d6e7f8", expected: 1},
+	}
+	for _, testCase := range testCases {
+		result, err := CompareVersions(testCase.a, testCase.b)
+		assert.Nil(t, err, testCase.a+" vs "+testCase.b)
+		assert.Equal(t, testCase.expected, result, testCase.a+" vs "+testCase.b)
+	}
+
+	for _, invalid := range []string{"", "undefined", "v1.2.3-rc1", "v1.2.3.4", "heads/main"} {
+		_, err := CompareVersions(invalid, "v1.0.0")
+		assert.True(t, errors.Is(err, ErrInvalidVersion), invalid)
+		_, err = CompareVersions("v1.0.0", invalid)
+		assert.True(t, errors.Is(err, ErrInvalidVersion), invalid)
+	}
+}
@@ -0,0 +1,30 @@
+package commonGo
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+)
+
+// RequestIDHeader carries the ID correlating the logs of the agents and of the aggregation service for a request
+const RequestIDHeader = "X-Request-ID"
+
+const (
+	requestIDSize      = 8
+	maxRequestIDLength = 64
+)
+
+var requestIDRegex = regexp.MustCompile(`^[A-Za-z0-9._:-]+$`)
+
+// NewRequestID returns a random request ID
+func NewRequestID() string {
+	buff := make([]byte, requestIDSize)
+	_, _ = rand.Read(buff)
+
+	return hex.EncodeToString(buff)
+}
+
+// IsValidRequestID returns true if the request ID received from a peer is safe to log and echo back
+func IsValidRequestID(requestID string) bool {
+	return len(requestID) <= maxRequestIDLength && requestIDRegex.MatchString(requestID)
+}
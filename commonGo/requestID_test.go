@@ -0,0 +1,23 @@
+package commonGo
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	t.Parallel()
+
+	id := NewRequestID()
+	assert.Len(t, id, 2*requestIDSize)
+	assert.NotEqual(t, id, NewRequestID())
+	assert.True(t, IsValidRequestID(id))
+
+	assert.True(t, IsValidRequestID("b7c0e1f2-6a3d-4d1e-9f2a-1c2b3d4e5f60"))
+	assert.False(t, IsValidRequestID(""))
+	assert.False(t, IsValidRequestID("id with spaces"))
+	assert.False(t, IsValidRequestID("id\nforged log line"))
+	assert.False(t, IsValidRequestID(strings.Repeat("a", maxRequestIDLength+1)))
+}
@@ -15,6 +15,9 @@ import (
 	"time"
 
 	"github.com/iulianpascalau/api-monitoring/commonGo"
+	"github.com/iulianpascalau/api-monitoring/commonGo/clock"
+	"github.com/iulianpascalau/api-monitoring/commonGo/faults"
+	agentCommon "github.com/iulianpascalau/api-monitoring/services/agent/common"
 	agentCfg "github.com/iulianpascalau/api-monitoring/services/agent/config"
 	agentFactory "github.com/iulianpascalau/api-monitoring/services/agent/factory"
 	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
@@ -45,6 +48,14 @@ func createMockEnvFileContents() map[string]*commonGo.EnvValue {
 	}
 }
 
+// waitHits blocks until the fault point was hit at least numHits times, instead of sleeping a guessed duration
+func waitHits(t *testing.T, injector *faults.Injector, point string, numHits int) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	require.NoError(t, injector.WaitHits(ctx, point, numHits), "point %s hit %d times", point, injector.Hits(point))
+}
+
 func TestE2EFlow(t *testing.T) {
 	log.Info("======== 1. Start a mock target API that the Agent will monitor")
 	mockAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -103,6 +114,7 @@ func TestE2EFlow(t *testing.T) {
 	agentHandler, err := agentFactory.NewComponentsHandler(
 		"test-service-key",
 		agentConfig,
+		agentCommon.BuildInfo{Version: "v1.0.0-e2e", Commit: "e2e-commit"},
 	)
 	require.NoError(t, err)
 
@@ -222,6 +234,34 @@ func TestE2EFlow(t *testing.T) {
 		_ = respHistoryAfter.Body.Close()
 	}()
 	require.Equal(t, http.StatusNotFound, respHistoryAfter.StatusCode)
+
+	log.Info("======== 6.g. Verify the agent build info")
+	reqAgents, err := http.NewRequest(http.MethodGet, aggURL+"/api/agents", nil)
+	require.NoError(t, err)
+	reqAgents.Header.Set("Authorization", "Bearer "+loginData.Token)
+
+	respAgents, err := client.Do(reqAgents)
+	require.NoError(t, err)
+	defer func() {
+		_ = respAgents.Body.Close()
+	}()
+	require.Equal(t, http.StatusOK, respAgents.StatusCode)
+
+	var agentsData struct {
+		Agents []struct {
+			Name       string `json:"name"`
+			Version    string `json:"version"`
+			Commit     string `json:"commit"`
+			ConfigHash string `json:"configHash"`
+		} `json:"agents"`
+	}
+	err = json.NewDecoder(respAgents.Body).Decode(&agentsData)
+	require.NoError(t, err)
+	require.Len(t, agentsData.Agents, 1)
+	require.Equal(t, "e2e-agent", agentsData.Agents[0].Name)
+	require.Equal(t, "v1.0.0-e2e", agentsData.Agents[0].Version)
+	require.Equal(t, "e2e-commit", agentsData.Agents[0].Commit)
+	require.Equal(t, agentConfig.Hash(), agentsData.Agents[0].ConfigHash)
 }
 
 func TestE2EFlowWithDataTrim(t *testing.T) {
@@ -282,18 +322,29 @@ func TestE2EFlowWithDataTrim(t *testing.T) {
 		},
 	}
 
-	agentHandler, err := agentFactory.NewComponentsHandler(
+	// the agent clock is behind, so the polled values get distinct timestamps in the past of the server
+	agentClock := clock.NewManualClock(time.Now().Add(-10 * time.Minute))
+	agentHandler, err := agentFactory.NewComponentsHandlerWithTestHooks(
 		"test-service-key",
 		agentConfig,
+		agentCommon.BuildInfo{},
+		agentFactory.TestHooks{Clock: agentClock},
 	)
 	require.NoError(t, err)
 
 	agentHandler.Start()
 	defer agentHandler.Close()
 
-	log.Info("======== 5. Wait for agent to poll the mockAPI and report to Aggregation, about 10 seconds...")
-	// Agent queries every 1s, we'll wait about 10.5s to ensure at least 10 queries done but only 5 values should remain in the DB
-	time.Sleep(10500 * time.Millisecond)
+	log.Info("======== 5. Advance the agent clock 10 times, the agent polls the mockAPI and reports to Aggregation each time")
+	// the initial query and 10 more are done but only 5 values should remain in the DB
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	for i := 0; i < 10; i++ {
+		// the timer is armed once the previous cycle ended
+		require.NoError(t, agentClock.WaitTimers(ctx, 1))
+		agentClock.Advance(time.Second)
+	}
+	require.NoError(t, agentClock.WaitTimers(ctx, 1))
 
 	log.Info("======== 6. Test the Aggregation API using HTTP calls")
 	log.Info("======== 6.a. Login to get JWT")
@@ -457,6 +508,7 @@ func TestE2EFlowWith2Agents(t *testing.T) {
 	agent1Handler, err := agentFactory.NewComponentsHandler(
 		"test-service-key",
 		agent1Config,
+		agentCommon.BuildInfo{},
 	)
 	require.NoError(t, err)
 
@@ -483,6 +535,7 @@ func TestE2EFlowWith2Agents(t *testing.T) {
 	agent2Handler, err := agentFactory.NewComponentsHandler(
 		"test-service-key",
 		agent2Config,
+		agentCommon.BuildInfo{},
 	)
 	require.NoError(t, err)
 
@@ -683,6 +736,7 @@ func TestE2EFlowForAlarms(t *testing.T) {
 	agentHandler, err := agentFactory.NewComponentsHandler(
 		"test-service-key",
 		agentConfig,
+		agentCommon.BuildInfo{},
 	)
 	require.NoError(t, err)
 
@@ -707,3 +761,157 @@ func TestE2EFlowForAlarms(t *testing.T) {
 	assert.Contains(t, <-receivedMessages, "e2e-agent.Active -> Host appears offline called by API monitoring app")
 	assert.Contains(t, <-receivedMessages, "Application closing called by API monitoring app")
 }
+
+func TestE2EFlowWithStorageFaults(t *testing.T) {
+	log.Info("======== 1. Start a mock target API that the Agent will monitor")
+	mockAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status": "ok", "latency": 12}`))
+	}))
+	defer mockAPI.Close()
+
+	log.Info("======== 2. Start Aggregation Service with the metric writes failing")
+	injector := faults.NewInjector()
+	injector.Set(faults.PointStorageWrite, faults.Fault{Err: faults.ErrInjected})
+
+	aggregationConfig := aggCfg.Config{
+		ListenAddress:    "127.0.0.1:0",
+		RetentionSeconds: 3600,
+	}
+	aggregationHandler, err := aggFactory.NewComponentsHandlerWithTestHooks(
+		filepath.Join(t.TempDir(), "e2e_sqlite.db"),
+		createMockEnvFileContents(),
+		aggregationConfig,
+		log,
+		"e2e-version",
+		aggFactory.TestHooks{FaultInjector: injector},
+	)
+	require.NoError(t, err)
+
+	aggregationHandler.Start()
+	defer aggregationHandler.Close()
+
+	_, port, err := net.SplitHostPort(aggregationHandler.GetServer().Address())
+	require.NoError(t, err)
+	aggURL := fmt.Sprintf("http://127.0.0.1:%s", port)
+
+	log.Info("======== 3. Start Agent Service sharing the fault injector")
+	agentConfig := agentCfg.Config{
+		Name:                   "e2e-agent",
+		QueryIntervalInSeconds: 1,
+		ReportEndpoint:         aggURL + "/api/report",
+		ReportTimeoutInSeconds: 5,
+		Endpoints: []agentCfg.EndpointConfig{
+			{
+				Name:           "mock-api",
+				URL:            mockAPI.URL,
+				Value:          "status",
+				Type:           "string",
+				NumAggregation: 1,
+			},
+		},
+	}
+	agentHandler, err := agentFactory.NewComponentsHandlerWithTestHooks(
+		"test-service-key",
+		agentConfig,
+		agentCommon.BuildInfo{},
+		agentFactory.TestHooks{FaultInjector: injector},
+	)
+	require.NoError(t, err)
+
+	agentHandler.Start()
+	defer agentHandler.Close()
+
+	log.Info("======== 4. Wait for the first report to complete, its values can not be saved")
+	// the reports are sent sequentially, the second one starts after the first one was handled
+	waitHits(t, injector, faults.PointAgentReport, 2)
+	assert.Positive(t, injector.Hits(faults.PointStorageWrite))
+	_, err = aggregationHandler.GetStore().GetMetricHistory(context.Background(), "mock-api")
+	require.Equal(t, common.ErrMetricNotFound, err)
+
+	log.Info("======== 5. Recover the database and wait for a complete report")
+	injector.Clear(faults.PointStorageWrite)
+	waitHits(t, injector, faults.PointAgentReport, injector.Hits(faults.PointAgentReport)+2)
+
+	history, err := aggregationHandler.GetStore().GetMetricHistory(context.Background(), "mock-api")
+	require.NoError(t, err)
+	require.NotEmpty(t, history.History)
+	assert.Equal(t, "ok", history.History[0].Value)
+}
+
+func TestE2EFlowWithDroppedReports(t *testing.T) {
+	log.Info("======== 1. Start a mock target API returning an incrementing nonce")
+	globalNonce := uint64(0)
+	mockAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		currentNonce := atomic.AddUint64(&globalNonce, 1)
+		_, _ = fmt.Fprintf(w, `{"nonce": %d, "latency": 12}`, currentNonce)
+	}))
+	defer mockAPI.Close()
+
+	log.Info("======== 2. Start Aggregation Service")
+	aggregationConfig := aggCfg.Config{
+		ListenAddress:    "127.0.0.1:0",
+		RetentionSeconds: 3600,
+	}
+	aggregationHandler, err := aggFactory.NewComponentsHandler(
+		filepath.Join(t.TempDir(), "e2e_sqlite.db"),
+		createMockEnvFileContents(),
+		aggregationConfig,
+		log,
+		"e2e-version",
+	)
+	require.NoError(t, err)
+
+	aggregationHandler.Start()
+	defer aggregationHandler.Close()
+
+	_, port, err := net.SplitHostPort(aggregationHandler.GetServer().Address())
+	require.NoError(t, err)
+	aggURL := fmt.Sprintf("http://127.0.0.1:%s", port)
+
+	log.Info("======== 3. Start Agent Service buffering the failed reports, the first 2 reports are dropped")
+	injector := faults.NewInjector()
+	injector.Set(faults.PointAgentReport, faults.Fault{Err: faults.ErrInjected, Times: 2})
+
+	agentConfig := agentCfg.Config{
+		Name:                   "e2e-agent",
+		QueryIntervalInSeconds: 1,
+		ReportEndpoint:         aggURL + "/api/report",
+		ReportTimeoutInSeconds: 5,
+		ReportBuffer:           agentCfg.ReportBufferConfig{Enabled: true},
+		Endpoints: []agentCfg.EndpointConfig{
+			{
+				Name:           "mock-api",
+				URL:            mockAPI.URL,
+				Value:          "nonce",
+				Type:           "uint64",
+				NumAggregation: 10,
+			},
+		},
+	}
+	agentHandler, err := agentFactory.NewComponentsHandlerWithTestHooks(
+		"test-service-key",
+		agentConfig,
+		agentCommon.BuildInfo{},
+		agentFactory.TestHooks{FaultInjector: injector},
+	)
+	require.NoError(t, err)
+
+	agentHandler.Start()
+	defer agentHandler.Close()
+
+	log.Info("======== 4. Wait for the third report and the replay of the dropped ones")
+	// hits: 2 dropped reports, the third report, the 2 replays, then the fourth report starts after the replays ended
+	waitHits(t, injector, faults.PointAgentReport, 6)
+
+	history, err := aggregationHandler.GetStore().GetMetricHistory(context.Background(), "mock-api")
+	require.NoError(t, err)
+	values := make([]string, 0, len(history.History))
+	for _, value := range history.History {
+		values = append(values, value.Value)
+	}
+	require.GreaterOrEqual(t, len(values), 3)
+	// the replayed reports keep their polling timestamps, so the history has no gap
+	assert.Equal(t, []string{"1", "2", "3"}, values[:3])
+}
@@ -0,0 +1,16 @@
+// Publishes the web build under a URL prefix (e.g. EXPO_PUBLIC_BASE_PATH=/monitoring) when the aggregation service
+// is served behind a reverse proxy. The prefix must match the BasePath of the aggregation config.
+module.exports = ({ config }) => {
+    const basePath = process.env.EXPO_PUBLIC_BASE_PATH;
+    if (!basePath) {
+        return config;
+    }
+
+    return {
+        ...config,
+        experiments: {
+            ...config.experiments,
+            baseUrl: basePath,
+        },
+    };
+};
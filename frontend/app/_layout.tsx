@@ -1,7 +1,7 @@
 import { Slot, useRouter, useSegments } from 'expo-router';
 import { useEffect, useState, createContext, useContext } from 'react';
 import { QueryClient, QueryClientProvider } from '@tanstack/react-query';
-import { getAuthToken, setAuthToken, setOnAuthErrorCallback } from '../lib/api';
+import { apiClient, getAuthToken, setAuthToken, setOnAuthErrorCallback } from '../lib/api';
 import { ActivityIndicator, View, useColorScheme as useDeviceColorScheme, Platform } from 'react-native';
 import { ThemeProvider, DarkTheme, DefaultTheme } from '@react-navigation/native';
 import AsyncStorage from '@react-native-async-storage/async-storage';
@@ -100,6 +100,8 @@ function AuthProvider({ children }: { children: React.ReactNode }) {
           setToken(newToken);
         },
         signOut: async () => {
+          // revoke the session server side, the local token is dropped regardless
+          await apiClient.post('/auth/logout').catch(() => undefined);
           await setAuthToken(null);
           setToken(null);
         },
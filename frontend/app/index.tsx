@@ -1,17 +1,27 @@
 import { View, Text, StyleSheet, Dimensions, Platform, useWindowDimensions, TouchableOpacity, SafeAreaView, ScrollView, RefreshControl, ActivityIndicator, Linking } from 'react-native';
-import { useQuery } from '@tanstack/react-query';
-import { apiClient } from '../lib/api';
+import { useQuery, useQueryClient } from '@tanstack/react-query';
+import { apiClient, fetchPanelGroups } from '../lib/api';
 import { useAuth } from './_layout';
 import { Link, useRouter } from 'expo-router';
-import { useMemo, useState } from 'react';
+import { useState } from 'react';
 import { LineChart } from 'react-native-chart-kit';
 import { Ionicons } from '@expo/vector-icons';
 
-import { Metric, MetricGroup } from '../lib/types';
+import { Dashboard, Metric, MetricGroup, MetricStatus, PanelMetric } from '../lib/types';
 
 // Initial screenWidth fallback if needed (using 800 as safe default if window is not available)
 const INITIAL_SCREEN_WIDTH = Dimensions.get("window")?.width || 800;
 
+type HistoryPoint = { value: string, recordedAt: number };
+type HistoryData = { history: HistoryPoint[] };
+
+// the colors of the statuses evaluated by the server against the thresholds of the metrics
+const statusColors: Record<MetricStatus, string> = {
+    ok: '#10b981',
+    warn: '#f59e0b',
+    crit: '#ef4444',
+};
+
 // A sub-component to fetch and render the graph for a specific metric
 function MetricGraph({ metric }: { metric: Metric }) {
     const { width: rawWidth } = useWindowDimensions();
@@ -21,15 +31,32 @@ function MetricGraph({ metric }: { metric: Metric }) {
     // We'll subtract 80 to have a small safe margin
     const chartWidth = Math.min(Math.max(windowWidth - 80, 100), 500);
     const { theme } = useAuth();
-    const { data, isLoading, error } = useQuery<{ history: { value: string, recordedAt: number }[] }>({
-        queryKey: ['metrics-history', metric.name],
+    const queryClient = useQueryClient();
+    const queryKey = ['metrics-history', metric.name];
+    const { data, isLoading, error } = useQuery<HistoryData>({
+        queryKey,
         queryFn: async () => {
             const parts = encodeURIComponent(metric.name);
-            const res = await apiClient.get(`/metrics/${parts}/history`);
-            return res.data;
+            const cached = queryClient.getQueryData<HistoryData>(queryKey);
+            const previous = cached?.history ?? [];
+            if (previous.length === 0) {
+                const res = await apiClient.get(`/metrics/${parts}/history`);
+                return res.data;
+            }
+
+            // Only fetch the samples recorded after the last one we have and slide the window over them
+            const first = previous[0].recordedAt;
+            const last = previous[previous.length - 1].recordedAt;
+            const res = await apiClient.get(`/metrics/${parts}/history`, { params: { since: last } });
+            const newer: HistoryPoint[] = res.data.history ?? [];
+            if (newer.length === 0) {
+                return { ...res.data, history: previous };
+            }
+            const windowStart = newer[newer.length - 1].recordedAt - (last - first);
+            return { ...res.data, history: [...previous, ...newer].filter((h) => h.recordedAt >= windowStart) };
         },
-        // Only refetch history rarely or if user explicitly wants it to avoid spamming the backend
-        staleTime: 60000,
+        // The refetches are incremental so they are cheap for the backend
+        staleTime: 30000,
     });
 
     if (isLoading) {
@@ -95,23 +122,25 @@ export default function DashboardScreen() {
     const windowWidth = rawWidth || INITIAL_SCREEN_WIDTH;
     const isMobile = windowWidth < 600;
 
-    const { data, isLoading, refetch, isRefetching } = useQuery<{ metrics: Metric[] }>({
-        queryKey: ['metrics'],
+    // null shows all the metrics, otherwise only the ones selected by the dashboard
+    const [selectedDashboardId, setSelectedDashboardId] = useState<number | null>(null);
+
+    const { data: dashboardsData } = useQuery<{ dashboards: Dashboard[] }>({
+        queryKey: ['dashboards'],
         queryFn: async () => {
-            const res = await apiClient.get('/metrics');
+            const res = await apiClient.get('/dashboards');
             return res.data;
         },
         enabled: !!token,
-        refetchInterval: 30000,
     });
 
-    const { data: panelConfigs } = useQuery<Record<string, number>>({
-        queryKey: ['panel-configs'],
-        queryFn: async () => {
-            const res = await apiClient.get('/config/panels');
-            return res.data;
-        },
+    const dashboards = dashboardsData?.dashboards ?? [];
+
+    const { data: groupedMetrics = [], isLoading, refetch, isRefetching } = useQuery<MetricGroup[]>({
+        queryKey: ['panels', selectedDashboardId],
+        queryFn: () => fetchPanelGroups(selectedDashboardId !== null ? { dashboard: selectedDashboardId } : undefined),
         enabled: !!token,
+        refetchInterval: 30000,
     });
 
     const { data: generalConfig } = useQuery<{ numSecondsToConsiderStale: number }>({
@@ -132,62 +161,29 @@ export default function DashboardScreen() {
     });
 
     const staleThreshold = generalConfig?.numSecondsToConsiderStale || 300;
+    // the metrics reported less often than the stale threshold are stale after missing a few reports
+    const metricStaleThreshold = (metric: Metric) => Math.max(staleThreshold, 3 * (metric.expectedInterval ?? 0));
 
-    const groupedMetrics = useMemo(() => {
-        if (!data?.metrics) return [];
-
-        const groups: Record<string, MetricGroup> = {};
-
-        data.metrics.forEach((metric) => {
-            const parts = metric.name.split('.');
-            const vmName = parts[0];
-
-            if (!groups[vmName]) {
-                groups[vmName] = { vmName, heartbeat: null, metrics: [] };
-            }
+    const renderMetric = (metric: PanelMetric) => {
 
-            if (metric.name === `${vmName}.Active`) {
-                groups[vmName].heartbeat = metric;
-            } else {
-                groups[vmName].metrics.push(metric);
-            }
-        });
-
-        return Object.values(groups).sort((a, b) => {
-            const orderA = panelConfigs ? (panelConfigs[a.vmName] ?? 0) : 0;
-            const orderB = panelConfigs ? (panelConfigs[b.vmName] ?? 0) : 0;
-
-            if (orderA !== orderB) return orderA - orderB;
-            return a.vmName.localeCompare(b.vmName);
-        }).map(group => ({
-            ...group,
-            metrics: group.metrics.sort((a, b) => {
-                if (a.displayOrder !== b.displayOrder) return a.displayOrder - b.displayOrder;
-                return a.name.localeCompare(b.name);
-            })
-        }));
-    }, [data, panelConfigs]);
-
-    const renderMetric = (metric: Metric) => {
-        const parts = metric.name.split('.');
-        const shortName = parts.slice(1).join('.');
-
-        const isStale = (Date.now() / 1000) - metric.recordedAt > staleThreshold;
-        const showsGraph = metric.type === 'uint64' && metric.numAggregation > 1;
+        const isStale = (Date.now() / 1000) - metric.recordedAt > metricStaleThreshold(metric);
+        const showsGraph = (metric.type === 'uint64' || metric.type === 'float64') && metric.numAggregation > 1;
 
         return (
             <View key={metric.name} style={[styles.metricRow, showsGraph && { flexDirection: 'column', alignItems: 'stretch' }]}>
                 <View style={[styles.metricLabelContainer, showsGraph && { marginBottom: 12 }]}>
-                    <Text style={[styles.metricLabel, isDark && styles.textDark]}>{shortName}</Text>
-                    {isStale && <Text style={styles.staleBadge}>STALE</Text>}
+                    <Text style={[styles.metricLabel, isDark && styles.textDark]}>{metric.label}</Text>
+                    {metric.stale ? (
+                        <Text style={styles.staleBadge}>AGENT DOWN</Text>
+                    ) : isStale && <Text style={styles.staleBadge}>STALE</Text>}
                 </View>
 
                 {showsGraph ? (
                     <MetricGraph metric={metric} />
                 ) : metric.type === 'bool' ? (
-                    <View style={[styles.dot, { backgroundColor: metric.value === 'true' && !isStale ? '#10b981' : '#ef4444' }]} />
+                    <View style={[styles.dot, { backgroundColor: (metric.status ? metric.status === 'ok' : metric.value === 'true') && !isStale ? statusColors.ok : statusColors.crit }]} />
                 ) : (
-                    <Text style={[styles.metricValue, isDark && styles.textValueDark]}>{metric.value}</Text>
+                    <Text style={[styles.metricValue, isDark && styles.textValueDark, metric.status && { color: statusColors[metric.status] }]}>{metric.value}</Text>
                 )}
             </View>
         );
@@ -229,17 +225,38 @@ export default function DashboardScreen() {
                     </TouchableOpacity>
                 </View>
             </View>
+            {dashboards.length > 0 && (
+                <ScrollView horizontal style={[styles.dashboardTabs, isDark && styles.headerDark]} contentContainerStyle={styles.dashboardTabsContent}>
+                    {[{ id: null, name: 'All' } as { id: number | null; name: string }, ...dashboards].map((dashboard) => {
+                        const isSelected = dashboard.id === selectedDashboardId;
+                        return (
+                            <TouchableOpacity
+                                key={dashboard.id ?? 'all'}
+                                onPress={() => setSelectedDashboardId(dashboard.id)}
+                                style={[styles.dashboardTab, isSelected && styles.dashboardTabSelected]}
+                            >
+                                <Text style={[styles.dashboardTabText, isDark && styles.textDark, isSelected && styles.dashboardTabTextSelected]}>
+                                    {dashboard.name}
+                                </Text>
+                            </TouchableOpacity>
+                        );
+                    })}
+                </ScrollView>
+            )}
             <View style={styles.metricsContainer}>
-                {isLoading && !data && (
+                {isLoading && (
                     <Text style={styles.loadingText}>Loading metrics...</Text>
                 )}
 
                 {groupedMetrics.map((group) => {
                     let isHeartbeatActive = false;
                     let maxRecordedAt = 0;
+                    let groupStaleThreshold = staleThreshold;
+                    const interval = group.heartbeat?.expectedInterval ?? 0;
 
                     if (group.heartbeat) {
-                        const isStale = (Date.now() / 1000) - group.heartbeat.recordedAt > staleThreshold;
+                        groupStaleThreshold = metricStaleThreshold(group.heartbeat);
+                        const isStale = (Date.now() / 1000) - group.heartbeat.recordedAt > groupStaleThreshold;
                         isHeartbeatActive = group.heartbeat.value === 'true' && !isStale;
                         maxRecordedAt = group.heartbeat.recordedAt;
                     }
@@ -258,9 +275,11 @@ export default function DashboardScreen() {
                         isLastUpdatedStale = true;
                     } else {
                         const diffSec = Math.floor((Date.now() / 1000) - maxRecordedAt);
-                        if (diffSec > staleThreshold) {
+                        if (diffSec > groupStaleThreshold) {
                             lastUpdatedText = 'not updated recently';
                             isLastUpdatedStale = true;
+                        } else if (interval > 0 && diffSec >= 2 * interval) {
+                            lastUpdatedText = `last updated ${Math.floor(diffSec / interval)} intervals ago`;
                         } else if (diffSec < 60) {
                             lastUpdatedText = `updated ${Math.max(0, diffSec)} sec ago`;
                         } else {
@@ -343,6 +362,36 @@ const styles = StyleSheet.create({
         borderBottomWidth: 1,
         borderBottomColor: '#e5e7eb',
     },
+    dashboardTabs: {
+        flexGrow: 0,
+        backgroundColor: 'white',
+        borderBottomWidth: 1,
+        borderBottomColor: '#e5e7eb',
+    },
+    dashboardTabsContent: {
+        paddingHorizontal: 16,
+        paddingVertical: 8,
+    },
+    dashboardTab: {
+        marginRight: 8,
+        paddingHorizontal: 14,
+        paddingVertical: 6,
+        borderRadius: 12,
+        borderWidth: 1,
+        borderColor: '#d1d5db',
+    },
+    dashboardTabSelected: {
+        backgroundColor: '#2563eb',
+        borderColor: '#2563eb',
+    },
+    dashboardTabText: {
+        fontSize: 14,
+        color: '#374151',
+    },
+    dashboardTabTextSelected: {
+        color: 'white',
+        fontWeight: '600',
+    },
     title: {
         fontSize: 20,
         fontWeight: 'bold',
@@ -12,6 +12,9 @@ export default function LoginScreen() {
     const [error, setError] = useState('');
     const [loading, setLoading] = useState(false);
     const [version, setVersion] = useState('');
+    const [oidcEnabled, setOidcEnabled] = useState(false);
+    const [otp, setOtp] = useState('');
+    const [otpRequired, setOtpRequired] = useState(false);
     const router = useRouter();
     const { signIn, theme, toggleTheme } = useAuth();
     const isDark = theme === 'dark';
@@ -20,7 +23,7 @@ export default function LoginScreen() {
         setError('');
         setLoading(true);
         try {
-            const response = await apiClient.post('/auth/login', { username, password });
+            const response = await apiClient.post('/auth/login', { username, password, otp });
             if (response.data.token) {
                 signIn(response.data.token);
                 router.replace('/');
@@ -30,6 +33,9 @@ export default function LoginScreen() {
             if (!err.response) {
                 setError(`Network error: ${err.message}. Check if server is up and reachable at ${API_BASE_URL}`);
             } else {
+                if (err.response?.data?.totpRequired) {
+                    setOtpRequired(true);
+                }
                 setError(err.response?.data?.error || 'Failed to login');
             }
         } finally {
@@ -39,10 +45,40 @@ export default function LoginScreen() {
 
     useEffect(() => {
         apiClient.get('/app-info')
-            .then(res => setVersion(res.data.version))
+            .then(res => {
+                setVersion(res.data.version);
+                setOidcEnabled(!!res.data.oidcEnabled);
+            })
             .catch(err => console.error('Failed to fetch version:', err));
     }, []);
 
+    // the OIDC callback redirects back here with the session token (or the error) in the URL fragment
+    useEffect(() => {
+        if (Platform.OS !== 'web' || !window.location.hash) {
+            return;
+        }
+        const params = new URLSearchParams(window.location.hash.substring(1));
+        window.history.replaceState(null, '', window.location.pathname);
+        const token = params.get('token');
+        if (token) {
+            signIn(token);
+            router.replace('/');
+            return;
+        }
+        if (params.get('error')) {
+            setError(`SSO login failed: ${params.get('error')}`);
+        }
+    }, []);
+
+    const handleOIDCLogin = () => {
+        const loginURL = `${API_BASE_URL}/auth/oidc/login`;
+        if (Platform.OS === 'web') {
+            window.location.href = loginURL;
+        } else {
+            Linking.openURL(loginURL);
+        }
+    };
+
     return (
         <View style={[styles.container, isDark && styles.containerDark, Platform.OS === 'web' && { flex: undefined, minHeight: '100vh' } as any]}>
             <TouchableOpacity onPress={toggleTheme} style={styles.themeToggle}>
@@ -88,6 +124,20 @@ export default function LoginScreen() {
                     </TouchableOpacity>
                 </View>
 
+                {otpRequired ? (
+                    <TextInput
+                        style={[styles.input, isDark && styles.inputDark]}
+                        placeholder="Authenticator or recovery code"
+                        placeholderTextColor={isDark ? '#9ca3af' : '#6b7280'}
+                        value={otp}
+                        onChangeText={setOtp}
+                        autoCapitalize="none"
+                        autoFocus
+                        onSubmitEditing={handleLogin}
+                        returnKeyType="go"
+                    />
+                ) : null}
+
                 <TouchableOpacity
                     style={styles.button}
                     onPress={handleLogin}
@@ -100,6 +150,15 @@ export default function LoginScreen() {
                     )}
                 </TouchableOpacity>
 
+                {oidcEnabled ? (
+                    <TouchableOpacity
+                        style={[styles.button, styles.ssoButton]}
+                        onPress={handleOIDCLogin}
+                    >
+                        <Text style={styles.buttonText}>Sign in with SSO</Text>
+                    </TouchableOpacity>
+                ) : null}
+
                 <View style={{ marginTop: 24, alignItems: 'center' }}>
                     <Text style={{ fontSize: 12, color: isDark ? '#9ca3af' : '#6b7280' }}>
                         Backend {version} | <Text
@@ -176,6 +235,10 @@ const styles = StyleSheet.create({
         borderRadius: 8,
         alignItems: 'center',
     },
+    ssoButton: {
+        marginTop: 12,
+        backgroundColor: '#4b5563',
+    },
     buttonText: {
         color: 'white',
         fontSize: 16,
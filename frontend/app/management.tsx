@@ -1,11 +1,10 @@
 import { View, Text, StyleSheet, TouchableOpacity, SafeAreaView, ScrollView, ActivityIndicator, Alert, Platform } from 'react-native';
 import { useQuery, useMutation, useQueryClient } from '@tanstack/react-query';
-import { apiClient } from '../lib/api';
+import { apiClient, fetchPanelGroups } from '../lib/api';
 import { useAuth } from './_layout';
-import { useMemo } from 'react';
 import { Ionicons } from '@expo/vector-icons';
 import { useRouter } from 'expo-router';
-import { Metric, MetricGroup } from '../lib/types';
+import { MetricGroup } from '../lib/types';
 
 export default function ManagementScreen() {
     const { token, theme } = useAuth();
@@ -13,21 +12,9 @@ export default function ManagementScreen() {
     const router = useRouter();
     const queryClient = useQueryClient();
 
-    const { data: metricsData, isLoading: isLoadingMetrics } = useQuery<{ metrics: Metric[] }>({
-        queryKey: ['metrics'],
-        queryFn: async () => {
-            const res = await apiClient.get('/metrics');
-            return res.data;
-        },
-        enabled: !!token,
-    });
-
-    const { data: panelConfigs, isLoading: isLoadingPanels } = useQuery<Record<string, number>>({
-        queryKey: ['panel-configs'],
-        queryFn: async () => {
-            const res = await apiClient.get('/config/panels');
-            return res.data;
-        },
+    const { data: groupedMetrics = [], isLoading: isLoadingMetrics } = useQuery<MetricGroup[]>({
+        queryKey: ['panels'],
+        queryFn: () => fetchPanelGroups(),
         enabled: !!token,
     });
 
@@ -36,7 +23,7 @@ export default function ManagementScreen() {
             await apiClient.delete(`/metrics/${name}`);
         },
         onSuccess: () => {
-            queryClient.invalidateQueries({ queryKey: ['metrics'] });
+            queryClient.invalidateQueries({ queryKey: ['panels'] });
         },
     });
 
@@ -45,7 +32,7 @@ export default function ManagementScreen() {
             await apiClient.post('/config/panels', { name, order });
         },
         onSuccess: () => {
-            queryClient.invalidateQueries({ queryKey: ['panel-configs'] });
+            queryClient.invalidateQueries({ queryKey: ['panels'] });
         },
     });
 
@@ -54,7 +41,7 @@ export default function ManagementScreen() {
             await apiClient.post('/config/metrics/order', { name, order });
         },
         onSuccess: () => {
-            queryClient.invalidateQueries({ queryKey: ['metrics'] });
+            queryClient.invalidateQueries({ queryKey: ['panels'] });
         },
     });
 
@@ -63,46 +50,10 @@ export default function ManagementScreen() {
             await apiClient.post('/config/metrics/alarm', { name, enabled });
         },
         onSuccess: () => {
-            queryClient.invalidateQueries({ queryKey: ['metrics'] });
+            queryClient.invalidateQueries({ queryKey: ['panels'] });
         },
     });
 
-    const groupedMetrics = useMemo(() => {
-        if (!metricsData?.metrics) return [];
-
-        const groups: Record<string, MetricGroup> = {};
-
-        metricsData.metrics.forEach((metric) => {
-            const parts = metric.name.split('.');
-            const vmName = parts[0];
-
-            if (!groups[vmName]) {
-                groups[vmName] = { vmName, heartbeat: null, metrics: [] };
-            }
-
-            if (metric.name === `${vmName}.Active`) {
-                groups[vmName].heartbeat = metric;
-            } else {
-                groups[vmName].metrics.push(metric);
-            }
-        });
-
-        const sortedGroups = Object.values(groups).sort((a, b) => {
-            const orderA = panelConfigs ? (panelConfigs[a.vmName] ?? 0) : 0;
-            const orderB = panelConfigs ? (panelConfigs[b.vmName] ?? 0) : 0;
-            if (orderA !== orderB) return orderA - orderB;
-            return a.vmName.localeCompare(b.vmName);
-        });
-
-        return sortedGroups.map(group => ({
-            ...group,
-            metrics: group.metrics.sort((a, b) => {
-                if (a.displayOrder !== b.displayOrder) return a.displayOrder - b.displayOrder;
-                return a.name.localeCompare(b.name);
-            })
-        }));
-    }, [metricsData, panelConfigs]);
-
     const handleMovePanel = (index: number, direction: 'up' | 'down') => {
         const panels = groupedMetrics.map(g => g.vmName);
         const name = panels[index];
@@ -156,7 +107,7 @@ export default function ManagementScreen() {
         }
     };
 
-    if (isLoadingMetrics || isLoadingPanels) {
+    if (isLoadingMetrics) {
         return (
             <SafeAreaView style={[styles.container, isDark && styles.bgDark]}>
                 <ActivityIndicator size="large" color="#3b82f6" />
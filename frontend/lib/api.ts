@@ -2,6 +2,7 @@ import axios from "axios";
 import AsyncStorage from "@react-native-async-storage/async-storage";
 import { Platform } from "react-native";
 import Constants from 'expo-constants';
+import { MetricGroup, Panel } from "./types";
 
 // For physical devices on the LAN, we need the host's LAN IP.
 // Expo Constants.expoConfig.hostUri typically looks like "192.168.0.x:8081"
@@ -28,9 +29,12 @@ const getHostIp = () => {
     return 'localhost';
 };
 
+// URL prefix of the web build published behind a reverse proxy, e.g. /monitoring
+const BASE_PATH = (process.env.EXPO_PUBLIC_BASE_PATH || '').replace(/\/$/, '');
+
 export const API_BASE_URL = __DEV__
     ? `http://${getHostIp()}:8080/api`
-    : "/api";
+    : `${BASE_PATH}/api`;
 
 console.log(`[API] Base URL configured to: ${API_BASE_URL}`);
 
@@ -74,3 +78,17 @@ apiClient.interceptors.response.use(
         return Promise.reject(error);
     }
 );
+
+// fetchPanelGroups returns the metric groups rendered as panels, in display order, the metrics of each node following
+// the metrics of the agent, labeled with the node name
+export const fetchPanelGroups = async (params?: Record<string, unknown>): Promise<MetricGroup[]> => {
+    const res = await apiClient.get<{ panels: Panel[] }>('/panels', { params });
+    return res.data.panels.map((panel) => ({
+        vmName: panel.name,
+        heartbeat: panel.heartbeat,
+        metrics: [
+            ...panel.metrics,
+            ...panel.nodes.flatMap((node) => node.metrics.map((metric) => ({ ...metric, label: `${node.name}.${metric.label}` }))),
+        ],
+    }));
+};
@@ -6,10 +6,56 @@ export interface Metric {
     recordedAt: number;
     displayOrder: number;
     isAlarmEnabled?: boolean;
+    gapMode?: string;
+    tags?: Record<string, string>;
+    // the number of seconds between two values, as reported by the agent, 0 if unknown
+    expectedInterval?: number;
+    // true when the heartbeat of the reporting agent is stale (agent down)
+    stale?: boolean;
+    thresholds?: MetricThresholds;
+    // the displayed value evaluated by the server against the thresholds, missing without thresholds
+    status?: MetricStatus;
+}
+
+export type MetricStatus = 'ok' | 'warn' | 'crit';
+
+export interface MetricThresholds {
+    warn?: number;
+    crit?: number;
+    below?: boolean;
+    expected?: string[];
 }
 
 export interface MetricGroup {
     vmName: string;
     heartbeat: Metric | null;
-    metrics: Metric[];
+    metrics: PanelMetric[];
+}
+
+// a metric of a panel, labeled with its name relative to the panel and the node
+export interface PanelMetric extends Metric {
+    label: string;
+}
+
+export interface PanelNode {
+    name: string;
+    metrics: PanelMetric[];
+}
+
+// the panels are grouped and ordered by the backend (GET /api/panels)
+export interface Panel {
+    name: string;
+    displayOrder: number;
+    // set for the panels grouping only the metrics assigned with PUT /api/config/panels/:panel/metrics
+    custom: boolean;
+    heartbeat: Metric | null;
+    metrics: PanelMetric[];
+    nodes: PanelNode[];
+}
+
+export interface Dashboard {
+    id: number;
+    name: string;
+    panels: string[];
+    metrics: string[];
 }
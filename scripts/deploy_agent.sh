@@ -45,7 +45,7 @@ ensure_go_installed
 GO_CMD="go"
 
 cd ./services/agent
-$GO_CMD build -v -ldflags="-X main.appVersion=$(git describe --tags --long --dirty)" -o agent main.go
+$GO_CMD build -v -ldflags="-X main.appVersion=$(git describe --tags --long --dirty) -X main.commitID=$(git rev-parse HEAD)" -o agent main.go
 if [ $? -ne 0 ]; then
     echo "Agent binary build failed!"
     exit 1
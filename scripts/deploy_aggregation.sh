@@ -48,6 +48,9 @@ if [ -d "$FRONTEND_DIR" ]; then
     npx expo export --platform web
     echo "Frontend build successful."
     cd "$PROJECT_DIR"
+    # Embed the build in the aggregation binary
+    find ./services/aggregation/webui/dist -mindepth 1 ! -name README.md -delete
+    cp -r "$FRONTEND_DIR/dist/." ./services/aggregation/webui/dist/
 else
     echo "Warning: Frontend directory not found at $FRONTEND_DIR, skipping frontend build."
 fi
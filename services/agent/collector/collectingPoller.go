@@ -0,0 +1,78 @@
+package collector
+
+import (
+	"context"
+	"sync"
+
+	"github.com/iulianpascalau/api-monitoring/services/agent/common"
+	"github.com/iulianpascalau/api-monitoring/services/agent/config"
+	"github.com/multiversx/mx-chain-core-go/core/check"
+	logger "github.com/multiversx/mx-chain-logger-go"
+)
+
+var log = logger.GetOrCreate("collector")
+
+// collectingPoller adds the metrics of the collectors to the results of the endpoints polling
+type collectingPoller struct {
+	poller     Poller
+	collectors []Collector
+}
+
+// NewCollectingPoller creates a poller running the collectors next to the polling of the endpoints
+func NewCollectingPoller(poller Poller, collectors []Collector) (*collectingPoller, error) {
+	if check.IfNil(poller) {
+		return nil, errNilPoller
+	}
+	for _, collector := range collectors {
+		if check.IfNil(collector) {
+			return nil, errNilCollector
+		}
+	}
+
+	return &collectingPoller{
+		poller:     poller,
+		collectors: collectors,
+	}, nil
+}
+
+// PollAll polls the endpoints while the collectors run, all bound by the context. The failing collectors are logged
+// and only the metrics they collected before the failure are reported. The endpoints results win the name clashes.
+func (cp *collectingPoller) PollAll(ctx context.Context, endpoints []config.EndpointConfig) map[string]common.MetricResult {
+	collected := make([]map[string]common.MetricResult, len(cp.collectors))
+	wg := sync.WaitGroup{}
+	for i, collector := range cp.collectors {
+		wg.Add(1)
+		go func(index int, collector Collector) {
+			defer wg.Done()
+
+			results, err := collector.Collect(ctx)
+			if err != nil {
+				log.Warn("collector failed", "collector", collector.Name(), "num collected", len(results), "error", err)
+			}
+			collected[index] = results
+		}(i, collector)
+	}
+
+	results := cp.poller.PollAll(ctx, endpoints)
+	if results == nil {
+		results = make(map[string]common.MetricResult)
+	}
+	wg.Wait()
+
+	for _, collectorResults := range collected {
+		for name, result := range collectorResults {
+			_, exists := results[name]
+			if exists {
+				continue
+			}
+			results[name] = result
+		}
+	}
+
+	return results
+}
+
+// IsInterfaceNil returns true if the value under the interface is nil
+func (cp *collectingPoller) IsInterfaceNil() bool {
+	return cp == nil
+}
@@ -0,0 +1,62 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/services/agent/common"
+	"github.com/iulianpascalau/api-monitoring/services/agent/config"
+	"github.com/iulianpascalau/api-monitoring/services/agent/testsCommon"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCollectingPoller(t *testing.T) {
+	t.Parallel()
+
+	poller, err := NewCollectingPoller(nil, nil)
+	assert.Nil(t, poller)
+	assert.Equal(t, errNilPoller, err)
+
+	var nilCollector *testsCommon.CollectorStub
+	poller, err = NewCollectingPoller(&testsCommon.PollerStub{}, []Collector{&testsCommon.CollectorStub{}, nilCollector})
+	assert.Nil(t, poller)
+	assert.Equal(t, errNilCollector, err)
+
+	poller, err = NewCollectingPoller(&testsCommon.PollerStub{}, []Collector{&testsCommon.CollectorStub{}})
+	assert.NoError(t, err)
+	assert.False(t, poller.IsInterfaceNil())
+}
+
+func TestCollectingPoller_PollAll(t *testing.T) {
+	t.Parallel()
+
+	endpoints := []config.EndpointConfig{{Name: "VM1.Node1.nonce"}}
+	inner := &testsCommon.PollerStub{
+		PollAllHandler: func(ctx context.Context, polledEndpoints []config.EndpointConfig) map[string]common.MetricResult {
+			assert.Equal(t, endpoints, polledEndpoints)
+			return map[string]common.MetricResult{"VM1.Node1.nonce": {Value: "10"}}
+		},
+	}
+	collectors := []Collector{
+		&testsCommon.CollectorStub{
+			CollectHandler: func(ctx context.Context) (map[string]common.MetricResult, error) {
+				return map[string]common.MetricResult{"VM1.ipmi.fan.Fan1": {Value: "5400"}, "VM1.Node1.nonce": {Value: "0"}}, nil
+			},
+		},
+		// the metrics collected before the failure are reported
+		&testsCommon.CollectorStub{
+			CollectHandler: func(ctx context.Context) (map[string]common.MetricResult, error) {
+				return map[string]common.MetricResult{"VM1.ipmi.psu.PS1": {Value: "true"}}, errors.New("expected error")
+			},
+		},
+	}
+	poller, _ := NewCollectingPoller(inner, collectors)
+
+	results := poller.PollAll(context.Background(), endpoints)
+	assert.Equal(t, map[string]common.MetricResult{
+		"VM1.Node1.nonce":   {Value: "10"},
+		"VM1.ipmi.fan.Fan1": {Value: "5400"},
+		"VM1.ipmi.psu.PS1":  {Value: "true"},
+	}, results)
+}
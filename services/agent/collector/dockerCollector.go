@@ -0,0 +1,263 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/agent/common"
+	"github.com/iulianpascalau/api-monitoring/services/agent/config"
+	"github.com/multiversx/mx-chain-core-go/core/check"
+)
+
+const (
+	defaultDockerSocketPath = "/var/run/docker.sock"
+	defaultDockerTimeout    = 10 * time.Second
+	// dockerAPIHost is ignored by the unix socket dialer, the requests only need a well formed URL
+	dockerAPIHost = "http://docker"
+	// dockerStateMissing is reported as the state of the containers the Docker Engine does not know
+	dockerStateMissing = "missing"
+	maxDockerBodySize  = 1 << 20
+)
+
+// errDockerContainerNotFound is returned by the inspect call of the containers the Docker Engine does not know
+var errDockerContainerNotFound = errors.New("container not found")
+
+// dockerInspect is the subset of the GET /containers/{name}/json response used by the collector
+type dockerInspect struct {
+	State struct {
+		Status string `json:"Status"`
+	} `json:"State"`
+	RestartCount uint64 `json:"RestartCount"`
+}
+
+// dockerCPUStats is the subset of the cpu_stats and precpu_stats objects of the stats response
+type dockerCPUStats struct {
+	CPUUsage struct {
+		TotalUsage  uint64   `json:"total_usage"`
+		PercpuUsage []uint64 `json:"percpu_usage"`
+	} `json:"cpu_usage"`
+	SystemUsage uint64 `json:"system_cpu_usage"`
+	OnlineCPUs  uint32 `json:"online_cpus"`
+}
+
+// dockerStats is the subset of the GET /containers/{name}/stats?stream=false response used by the collector
+type dockerStats struct {
+	CPUStats    dockerCPUStats `json:"cpu_stats"`
+	PreCPUStats dockerCPUStats `json:"precpu_stats"`
+	MemoryStats struct {
+		Usage uint64            `json:"usage"`
+		Stats map[string]uint64 `json:"stats"`
+	} `json:"memory_stats"`
+}
+
+// ArgsDockerCollector defines the DTO struct for the NewDockerCollector constructor function
+type ArgsDockerCollector struct {
+	Config config.DockerConfig
+	// Prefix of the metric names, e.g. VM1.docker
+	Prefix string
+	Clock  Clock
+}
+
+// dockerCollector reads the state, restart count, CPU and memory usage of the configured containers from the Docker
+// Engine API, served on a unix socket
+type dockerCollector struct {
+	cfg     config.DockerConfig
+	prefix  string
+	clock   Clock
+	client  *http.Client
+	timeout time.Duration
+}
+
+// NewDockerCollector creates the collector of the containers health
+func NewDockerCollector(args ArgsDockerCollector) (*dockerCollector, error) {
+	if len(args.Prefix) == 0 {
+		return nil, errEmptyPrefix
+	}
+	if args.Config.NumAggregation < 1 {
+		return nil, fmt.Errorf("%w: %d", errInvalidNumAggregation, args.Config.NumAggregation)
+	}
+	if check.IfNil(args.Clock) {
+		return nil, errNilClock
+	}
+
+	socketPath := args.Config.SocketPath
+	if len(socketPath) == 0 {
+		socketPath = defaultDockerSocketPath
+	}
+	dialer := &net.Dialer{}
+	collector := &dockerCollector{
+		cfg:    args.Config,
+		prefix: args.Prefix,
+		clock:  args.Clock,
+		client: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return dialer.DialContext(ctx, "unix", socketPath)
+				},
+			},
+		},
+		timeout: time.Duration(args.Config.TimeoutInSeconds) * time.Second,
+	}
+	if collector.timeout == 0 {
+		collector.timeout = defaultDockerTimeout
+	}
+
+	return collector, nil
+}
+
+// Collect reads the containers concurrently, as the Docker Engine samples the CPU usage for about a second before
+// answering each stats call. A failed container does not prevent reading the others.
+func (collector *dockerCollector) Collect(ctx context.Context) (map[string]common.MetricResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, collector.timeout)
+	defer cancel()
+
+	results := make(map[string]common.MetricResult)
+	var errs []error
+	mut := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for _, container := range collector.cfg.Containers {
+		wg.Add(1)
+		go func(container string) {
+			defer wg.Done()
+
+			containerResults, err := collector.collectContainer(ctx, container)
+
+			mut.Lock()
+			defer mut.Unlock()
+			for name, result := range containerResults {
+				results[name] = result
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to read the container %s: %w", container, err))
+			}
+		}(container)
+	}
+	wg.Wait()
+
+	return results, errors.Join(errs...)
+}
+
+// collectContainer reports the state and the restart count of the container and, while it is running, its CPU and
+// memory usage. An unknown container is reported with the missing state.
+func (collector *dockerCollector) collectContainer(ctx context.Context, container string) (map[string]common.MetricResult, error) {
+	prefix := collector.prefix + "." + cleanMetricSegment(container)
+	results := make(map[string]common.MetricResult, 4)
+
+	inspect := &dockerInspect{}
+	err := collector.get(ctx, "/containers/"+url.PathEscape(container)+"/json", inspect)
+	if errors.Is(err, errDockerContainerNotFound) {
+		collector.addResult(results, prefix+".state", "string", dockerStateMissing)
+		return results, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	collector.addResult(results, prefix+".state", "string", inspect.State.Status)
+	collector.addResult(results, prefix+".restartCount", "uint64", strconv.FormatUint(inspect.RestartCount, 10))
+	if inspect.State.Status != "running" {
+		return results, nil
+	}
+
+	stats := &dockerStats{}
+	err = collector.get(ctx, "/containers/"+url.PathEscape(container)+"/stats?stream=false", stats)
+	if err != nil {
+		return results, err
+	}
+
+	collector.addResult(results, prefix+".cpuPercent", "float64", strconv.FormatFloat(cpuPercent(stats), 'f', 2, 64))
+	collector.addResult(results, prefix+".memoryBytes", "uint64", strconv.FormatUint(memoryUsage(stats), 10))
+
+	return results, nil
+}
+
+func (collector *dockerCollector) get(ctx context.Context, path string, response interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, dockerAPIHost+path, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := collector.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDockerBodySize))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return errDockerContainerNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
+	}
+
+	return json.Unmarshal(body, response)
+}
+
+func (collector *dockerCollector) addResult(results map[string]common.MetricResult, name string, metricType string, value string) {
+	results[name] = common.MetricResult{
+		Config: config.EndpointConfig{
+			Name:           name,
+			Type:           metricType,
+			NumAggregation: collector.cfg.NumAggregation,
+		},
+		Value:    value,
+		PolledAt: collector.clock.Now().Unix(),
+	}
+}
+
+// cpuPercent computes the CPU usage between the two samples of the stats call the way the docker stats command does,
+// 100% standing for one fully used core
+func cpuPercent(stats *dockerStats) float64 {
+	if stats.CPUStats.CPUUsage.TotalUsage < stats.PreCPUStats.CPUUsage.TotalUsage ||
+		stats.CPUStats.SystemUsage <= stats.PreCPUStats.SystemUsage {
+		return 0
+	}
+
+	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage - stats.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(stats.CPUStats.SystemUsage - stats.PreCPUStats.SystemUsage)
+	onlineCPUs := float64(stats.CPUStats.OnlineCPUs)
+	if onlineCPUs == 0 {
+		onlineCPUs = float64(len(stats.CPUStats.CPUUsage.PercpuUsage))
+	}
+
+	return cpuDelta / systemDelta * onlineCPUs * 100
+}
+
+// memoryUsage returns the used memory without the reclaimable page cache, as the docker stats command does: the
+// total_inactive_file counter on cgroup v1 and the inactive_file one on cgroup v2
+func memoryUsage(stats *dockerStats) uint64 {
+	usage := stats.MemoryStats.Usage
+	for _, counter := range []string{"total_inactive_file", "inactive_file"} {
+		inactive, found := stats.MemoryStats.Stats[counter]
+		if found && inactive < usage {
+			return usage - inactive
+		}
+	}
+
+	return usage
+}
+
+// Name returns the collector name
+func (collector *dockerCollector) Name() string {
+	return "docker"
+}
+
+// IsInterfaceNil returns true if the value under the interface is nil
+func (collector *dockerCollector) IsInterfaceNil() bool {
+	return collector == nil
+}
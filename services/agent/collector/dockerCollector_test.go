@@ -0,0 +1,171 @@
+package collector
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/commonGo/clock"
+	"github.com/iulianpascalau/api-monitoring/services/agent/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var dockerResponses = map[string]string{
+	"/containers/observer-0/json": `{"State": {"Status": "running", "Running": true}, "RestartCount": 3}`,
+	"/containers/observer-0/stats": `{
+		"cpu_stats": {"cpu_usage": {"total_usage": 3000000000}, "system_cpu_usage": 20000000000, "online_cpus": 4},
+		"precpu_stats": {"cpu_usage": {"total_usage": 2000000000}, "system_cpu_usage": 16000000000, "online_cpus": 4},
+		"memory_stats": {"usage": 1073741824, "stats": {"inactive_file": 73741824}}
+	}`,
+	"/containers/validator/json": `{"State": {"Status": "exited", "Running": false}, "RestartCount": 7}`,
+}
+
+func startDockerServer(t *testing.T) string {
+	socketPath := filepath.Join(t.TempDir(), "docker.sock")
+	listener, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/containers/broken/json" {
+			http.Error(w, `{"message": "server error"}`, http.StatusInternalServerError)
+			return
+		}
+		response, found := dockerResponses[r.URL.Path]
+		if !found {
+			http.Error(w, `{"message": "No such container"}`, http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(response))
+	}))
+	server.Listener = listener
+	server.Start()
+	t.Cleanup(server.Close)
+
+	return socketPath
+}
+
+func createDockerArgs() ArgsDockerCollector {
+	return ArgsDockerCollector{
+		Config: config.DockerConfig{
+			Enabled:        true,
+			Containers:     []string{"observer-0", "validator"},
+			NumAggregation: 100,
+		},
+		Prefix: "VM1.docker",
+		Clock:  clock.NewManualClock(time.Unix(1700000000, 0)),
+	}
+}
+
+func TestNewDockerCollector(t *testing.T) {
+	t.Parallel()
+
+	args := createDockerArgs()
+	args.Prefix = ""
+	collector, err := NewDockerCollector(args)
+	assert.Nil(t, collector)
+	assert.Equal(t, errEmptyPrefix, err)
+
+	args = createDockerArgs()
+	args.Config.NumAggregation = 0
+	collector, err = NewDockerCollector(args)
+	assert.Nil(t, collector)
+	assert.ErrorIs(t, err, errInvalidNumAggregation)
+
+	args = createDockerArgs()
+	args.Clock = nil
+	collector, err = NewDockerCollector(args)
+	assert.Nil(t, collector)
+	assert.Equal(t, errNilClock, err)
+
+	collector, err = NewDockerCollector(createDockerArgs())
+	require.NoError(t, err)
+	assert.False(t, collector.IsInterfaceNil())
+	assert.Equal(t, "docker", collector.Name())
+	assert.Equal(t, defaultDockerTimeout, collector.timeout)
+}
+
+func TestDockerCollector_Collect(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should report the containers state and usage", func(t *testing.T) {
+		t.Parallel()
+
+		args := createDockerArgs()
+		args.Config.SocketPath = startDockerServer(t)
+		args.Config.Containers = append(args.Config.Containers, "observer.1")
+		collector, err := NewDockerCollector(args)
+		require.NoError(t, err)
+
+		results, err := collector.Collect(context.Background())
+		require.NoError(t, err)
+
+		expectedValues := map[string]string{
+			"VM1.docker.observer-0.state":        "running",
+			"VM1.docker.observer-0.restartCount": "3",
+			"VM1.docker.observer-0.cpuPercent":   "100.00",
+			"VM1.docker.observer-0.memoryBytes":  "1000000000",
+			"VM1.docker.validator.state":         "exited",
+			"VM1.docker.validator.restartCount":  "7",
+			"VM1.docker.observer_1.state":        "missing",
+		}
+		require.Len(t, results, len(expectedValues))
+		for name, expectedValue := range expectedValues {
+			assert.Equal(t, expectedValue, results[name].Value, name)
+			assert.Equal(t, name, results[name].Config.Name)
+			assert.Equal(t, 100, results[name].Config.NumAggregation)
+			assert.Equal(t, int64(1700000000), results[name].PolledAt)
+		}
+		assert.Equal(t, "string", results["VM1.docker.observer-0.state"].Config.Type)
+		assert.Equal(t, "uint64", results["VM1.docker.observer-0.restartCount"].Config.Type)
+		assert.Equal(t, "float64", results["VM1.docker.observer-0.cpuPercent"].Config.Type)
+		assert.Equal(t, "uint64", results["VM1.docker.observer-0.memoryBytes"].Config.Type)
+	})
+	t.Run("a failed container should not prevent reading the others", func(t *testing.T) {
+		t.Parallel()
+
+		args := createDockerArgs()
+		args.Config.SocketPath = startDockerServer(t)
+		args.Config.Containers = []string{"broken", "validator"}
+		collector, err := NewDockerCollector(args)
+		require.NoError(t, err)
+
+		results, err := collector.Collect(context.Background())
+		require.NotNil(t, err)
+		assert.Contains(t, err.Error(), "failed to read the container broken: unexpected status code 500")
+		assert.Len(t, results, 2)
+		assert.Equal(t, "exited", results["VM1.docker.validator.state"].Value)
+	})
+	t.Run("an unreachable socket should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createDockerArgs()
+		args.Config.SocketPath = filepath.Join(t.TempDir(), "missing.sock")
+		collector, err := NewDockerCollector(args)
+		require.NoError(t, err)
+
+		results, err := collector.Collect(context.Background())
+		assert.NotNil(t, err)
+		assert.Empty(t, results)
+	})
+}
+
+func TestCPUPercent(t *testing.T) {
+	t.Parallel()
+
+	stats := &dockerStats{}
+	stats.CPUStats.CPUUsage.TotalUsage = 1500
+	stats.CPUStats.CPUUsage.PercpuUsage = []uint64{750, 750}
+	stats.CPUStats.SystemUsage = 10000
+	stats.PreCPUStats.CPUUsage.TotalUsage = 1000
+	stats.PreCPUStats.SystemUsage = 9000
+	assert.Equal(t, 100.0, cpuPercent(stats))
+
+	// the first sample of a just started container has no previous system usage delta
+	stats.PreCPUStats.SystemUsage = 10000
+	assert.Equal(t, 0.0, cpuPercent(stats))
+}
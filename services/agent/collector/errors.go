@@ -0,0 +1,11 @@
+package collector
+
+import "errors"
+
+var (
+	errNilPoller             = errors.New("nil poller")
+	errNilCollector          = errors.New("nil collector")
+	errNilClock              = errors.New("nil clock")
+	errEmptyPrefix           = errors.New("empty metrics prefix")
+	errInvalidNumAggregation = errors.New("invalid number of aggregated values")
+)
@@ -0,0 +1,34 @@
+package collector
+
+import (
+	"context"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/agent/common"
+	"github.com/iulianpascalau/api-monitoring/services/agent/config"
+)
+
+// Poller defines the component polling the configured endpoints
+type Poller interface {
+	PollAll(ctx context.Context, endpoints []config.EndpointConfig) map[string]common.MetricResult
+	IsInterfaceNil() bool
+}
+
+// Collector defines a source of metrics that are not polled from an endpoint (e.g. the hardware sensors), collected
+// every polling cycle
+type Collector interface {
+	// Collect returns the collected metrics by name. The metrics collected before a failure are returned together
+	// with the error.
+	Collect(ctx context.Context) (map[string]common.MetricResult, error)
+	Name() string
+	IsInterfaceNil() bool
+}
+
+// Clock defines the time source of the collected values timestamps
+type Clock interface {
+	Now() time.Time
+	IsInterfaceNil() bool
+}
+
+// CommandRunner runs an external command, with the extra environment variables, and returns its standard output
+type CommandRunner func(ctx context.Context, env []string, name string, args ...string) ([]byte, error)
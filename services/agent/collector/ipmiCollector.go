@@ -0,0 +1,233 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/agent/common"
+	"github.com/iulianpascalau/api-monitoring/services/agent/config"
+	"github.com/multiversx/mx-chain-core-go/core/check"
+)
+
+const (
+	defaultIpmitoolPath = "ipmitool"
+	defaultIPMITimeout  = 10 * time.Second
+	// ipmiPasswordEnv carries the BMC password to ipmitool (-E), so it is not visible in the process list
+	ipmiPasswordEnv = "IPMI_PASSWORD"
+)
+
+// the metric kinds of the sensors
+const (
+	sensorKindTemperature = "temperature"
+	sensorKindFan         = "fan"
+	sensorKindPSU         = "psu"
+)
+
+// ipmiSensorTypes are the SDR sensor types read, in order, with the kind of metric they are reported as
+var ipmiSensorTypes = []struct {
+	sdrType string
+	kind    string
+}{
+	{sdrType: "Temperature", kind: sensorKindTemperature},
+	{sdrType: "Fan", kind: sensorKindFan},
+	{sdrType: "Power Supply", kind: sensorKindPSU},
+}
+
+// psuFailureMarkers are the fragments of the power supply readings telling a failed or unplugged unit
+var psuFailureMarkers = []string{"fail", "lost", "error"}
+
+var metricSegmentCleaner = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// ArgsIPMICollector defines the DTO struct for the NewIPMICollector constructor function
+type ArgsIPMICollector struct {
+	Config config.IPMIConfig
+	// Prefix of the metric names, e.g. VM1.ipmi
+	Prefix string
+	Clock  Clock
+	// RunCommand runs ipmitool, nil executes it
+	RunCommand CommandRunner
+}
+
+// ipmiCollector reads the temperature, fan and power supply sensors of the BMC with ipmitool
+type ipmiCollector struct {
+	cfg        config.IPMIConfig
+	prefix     string
+	clock      Clock
+	runCommand CommandRunner
+	sensors    map[string]struct{}
+	timeout    time.Duration
+}
+
+// NewIPMICollector creates the collector of the hardware health sensors
+func NewIPMICollector(args ArgsIPMICollector) (*ipmiCollector, error) {
+	if len(args.Prefix) == 0 {
+		return nil, errEmptyPrefix
+	}
+	if args.Config.NumAggregation < 1 {
+		return nil, fmt.Errorf("%w: %d", errInvalidNumAggregation, args.Config.NumAggregation)
+	}
+	if check.IfNil(args.Clock) {
+		return nil, errNilClock
+	}
+
+	collector := &ipmiCollector{
+		cfg:        args.Config,
+		prefix:     args.Prefix,
+		clock:      args.Clock,
+		runCommand: args.RunCommand,
+		sensors:    make(map[string]struct{}, len(args.Config.Sensors)),
+		timeout:    time.Duration(args.Config.TimeoutInSeconds) * time.Second,
+	}
+	if collector.runCommand == nil {
+		collector.runCommand = runCommand
+	}
+	if len(collector.cfg.IpmitoolPath) == 0 {
+		collector.cfg.IpmitoolPath = defaultIpmitoolPath
+	}
+	if collector.timeout == 0 {
+		collector.timeout = defaultIPMITimeout
+	}
+	for _, sensor := range args.Config.Sensors {
+		collector.sensors[sensor] = struct{}{}
+	}
+
+	return collector, nil
+}
+
+// Collect reads the sensors of each type. A failed ipmitool call does not prevent reading the other types.
+func (collector *ipmiCollector) Collect(ctx context.Context) (map[string]common.MetricResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, collector.timeout)
+	defer cancel()
+
+	results := make(map[string]common.MetricResult)
+	var errs []error
+	for _, sensorType := range ipmiSensorTypes {
+		output, err := collector.runCommand(ctx, collector.env(), collector.cfg.IpmitoolPath, collector.args(sensorType.sdrType)...)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to read the %s sensors: %w", sensorType.sdrType, err))
+			continue
+		}
+
+		collector.addSensors(results, sensorType.kind, string(output))
+	}
+
+	return results, errors.Join(errs...)
+}
+
+func (collector *ipmiCollector) args(sdrType string) []string {
+	args := make([]string, 0, 9)
+	if len(collector.cfg.Host) > 0 {
+		args = append(args, "-I", "lanplus", "-H", collector.cfg.Host, "-U", collector.cfg.Username, "-E")
+	}
+
+	return append(args, "sdr", "type", sdrType)
+}
+
+func (collector *ipmiCollector) env() []string {
+	if len(collector.cfg.Host) == 0 {
+		return nil
+	}
+
+	return []string{ipmiPasswordEnv + "=" + collector.cfg.Password}
+}
+
+// addSensors parses the sdr lines, formatted as: name | sensor ID | status | entity ID | reading. The sensors without
+// a reading are skipped.
+func (collector *ipmiCollector) addSensors(results map[string]common.MetricResult, kind string, output string) {
+	polledAt := collector.clock.Now().Unix()
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, "|")
+		if len(fields) < 5 {
+			continue
+		}
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		sensor, status, reading := fields[0], fields[2], fields[4]
+		if len(collector.sensors) > 0 {
+			_, found := collector.sensors[sensor]
+			if !found {
+				continue
+			}
+		}
+
+		value, metricType, ok := sensorValue(kind, status, reading)
+		if !ok {
+			continue
+		}
+		name := collector.prefix + "." + kind + "." + cleanMetricSegment(sensor)
+		results[name] = common.MetricResult{
+			Config: config.EndpointConfig{
+				Name:           name,
+				Type:           metricType,
+				NumAggregation: collector.cfg.NumAggregation,
+			},
+			Value:    value,
+			PolledAt: polledAt,
+		}
+	}
+}
+
+// sensorValue returns the numeric reading (e.g. 45 out of "45 degrees C") of the temperatures and fans and the health
+// of the power supplies
+func sensorValue(kind string, status string, reading string) (string, string, bool) {
+	if status == "ns" {
+		return "", "", false
+	}
+
+	if kind == sensorKindPSU {
+		healthy := status == "ok"
+		lowerReading := strings.ToLower(reading)
+		for _, marker := range psuFailureMarkers {
+			healthy = healthy && !strings.Contains(lowerReading, marker)
+		}
+
+		return strconv.FormatBool(healthy), "bool", true
+	}
+
+	fields := strings.Fields(reading)
+	if len(fields) == 0 {
+		return "", "", false
+	}
+	_, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return "", "", false
+	}
+
+	return fields[0], "float64", true
+}
+
+// cleanMetricSegment turns a sensor or container name into a metric name segment
+func cleanMetricSegment(name string) string {
+	return strings.Trim(metricSegmentCleaner.ReplaceAllString(name, "_"), "_")
+}
+
+// Name returns the collector name
+func (collector *ipmiCollector) Name() string {
+	return "ipmi"
+}
+
+func runCommand(ctx context.Context, env []string, name string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = append(os.Environ(), env...)
+
+	output, err := cmd.Output()
+	exitErr := &exec.ExitError{}
+	if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+	}
+
+	return output, err
+}
+
+// IsInterfaceNil returns true if the value under the interface is nil
+func (collector *ipmiCollector) IsInterfaceNil() bool {
+	return collector == nil
+}
@@ -0,0 +1,158 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/commonGo/clock"
+	"github.com/iulianpascalau/api-monitoring/services/agent/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var ipmiOutputs = map[string]string{
+	"Temperature": `Inlet Temp       | 04h | ok  |  7.1 | 23 degrees C
+CPU1 Temp        | 0Eh | ok  |  3.1 | 45 degrees C
+CPU2 Temp        | 0Fh | ns  |  3.2 | No Reading
+`,
+	"Fan": `Fan1 RPM         | 30h | ok  |  7.1 | 5400 RPM
+Fan2 RPM         | 31h | ok  |  7.1 | Disabled
+`,
+	"Power Supply": `PS1 Status       | 60h | ok  | 10.1 | Presence detected
+PS2 Status       | 61h | ok  | 10.2 | Presence detected, Power Supply AC lost
+PS3 Status       | 62h | cr  | 10.3 | Presence detected
+`,
+}
+
+func createIPMIArgs() ArgsIPMICollector {
+	return ArgsIPMICollector{
+		Config: config.IPMIConfig{Enabled: true, NumAggregation: 100},
+		Prefix: "VM1.ipmi",
+		Clock:  clock.NewManualClock(time.Unix(1700000000, 0)),
+		RunCommand: func(ctx context.Context, env []string, name string, args ...string) ([]byte, error) {
+			return []byte(ipmiOutputs[args[len(args)-1]]), nil
+		},
+	}
+}
+
+func TestNewIPMICollector(t *testing.T) {
+	t.Parallel()
+
+	args := createIPMIArgs()
+	args.Prefix = ""
+	collector, err := NewIPMICollector(args)
+	assert.Nil(t, collector)
+	assert.Equal(t, errEmptyPrefix, err)
+
+	args = createIPMIArgs()
+	args.Config.NumAggregation = 0
+	collector, err = NewIPMICollector(args)
+	assert.Nil(t, collector)
+	assert.ErrorIs(t, err, errInvalidNumAggregation)
+
+	args = createIPMIArgs()
+	args.Clock = nil
+	collector, err = NewIPMICollector(args)
+	assert.Nil(t, collector)
+	assert.Equal(t, errNilClock, err)
+
+	collector, err = NewIPMICollector(createIPMIArgs())
+	assert.NoError(t, err)
+	assert.False(t, collector.IsInterfaceNil())
+	assert.Equal(t, "ipmi", collector.Name())
+}
+
+func TestIPMICollector_Collect(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should report the sensors with a reading", func(t *testing.T) {
+		t.Parallel()
+
+		collector, _ := NewIPMICollector(createIPMIArgs())
+		results, err := collector.Collect(context.Background())
+		require.NoError(t, err)
+
+		values := make(map[string]string, len(results))
+		for name, result := range results {
+			assert.Equal(t, name, result.Config.Name)
+			assert.Equal(t, 100, result.Config.NumAggregation)
+			assert.Equal(t, int64(1700000000), result.PolledAt)
+			values[name] = result.Value
+		}
+		assert.Equal(t, map[string]string{
+			"VM1.ipmi.temperature.Inlet_Temp": "23",
+			"VM1.ipmi.temperature.CPU1_Temp":  "45",
+			"VM1.ipmi.fan.Fan1_RPM":           "5400",
+			"VM1.ipmi.psu.PS1_Status":         "true",
+			"VM1.ipmi.psu.PS2_Status":         "false",
+			"VM1.ipmi.psu.PS3_Status":         "false",
+		}, values)
+		assert.Equal(t, "float64", results["VM1.ipmi.fan.Fan1_RPM"].Config.Type)
+		assert.Equal(t, "bool", results["VM1.ipmi.psu.PS1_Status"].Config.Type)
+	})
+	t.Run("should only report the configured sensors", func(t *testing.T) {
+		t.Parallel()
+
+		args := createIPMIArgs()
+		args.Config.Sensors = []string{"CPU1 Temp", "PS2 Status"}
+		collector, _ := NewIPMICollector(args)
+		results, err := collector.Collect(context.Background())
+		require.NoError(t, err)
+		assert.Len(t, results, 2)
+		assert.Equal(t, "45", results["VM1.ipmi.temperature.CPU1_Temp"].Value)
+		assert.Equal(t, "false", results["VM1.ipmi.psu.PS2_Status"].Value)
+	})
+	t.Run("should read a remote BMC with the password in the environment", func(t *testing.T) {
+		t.Parallel()
+
+		args := createIPMIArgs()
+		args.Config.IpmitoolPath = "/usr/sbin/ipmitool"
+		args.Config.Host = "10.0.0.10"
+		args.Config.Username = "monitor"
+		args.Config.Password = "secret"
+		args.RunCommand = func(ctx context.Context, env []string, name string, args ...string) ([]byte, error) {
+			assert.Equal(t, "/usr/sbin/ipmitool", name)
+			assert.Equal(t, []string{"-I", "lanplus", "-H", "10.0.0.10", "-U", "monitor", "-E", "sdr", "type"}, args[:len(args)-1])
+			assert.Equal(t, []string{"IPMI_PASSWORD=secret"}, env)
+			_, hasDeadline := ctx.Deadline()
+			assert.True(t, hasDeadline)
+			return nil, nil
+		}
+		collector, _ := NewIPMICollector(args)
+		results, err := collector.Collect(context.Background())
+		require.NoError(t, err)
+		assert.Empty(t, results)
+	})
+	t.Run("a failed sensor type should not prevent reading the others", func(t *testing.T) {
+		t.Parallel()
+
+		expectedErr := errors.New("expected error")
+		args := createIPMIArgs()
+		args.RunCommand = func(ctx context.Context, env []string, name string, args ...string) ([]byte, error) {
+			if args[len(args)-1] == "Fan" {
+				return nil, expectedErr
+			}
+			return []byte(ipmiOutputs[args[len(args)-1]]), nil
+		}
+		collector, _ := NewIPMICollector(args)
+		results, err := collector.Collect(context.Background())
+		assert.ErrorIs(t, err, expectedErr)
+		assert.ErrorContains(t, err, "failed to read the Fan sensors")
+		assert.Len(t, results, 5)
+		assert.NotContains(t, results, "VM1.ipmi.fan.Fan1_RPM")
+	})
+}
+
+func TestIPMICollector_RunCommand(t *testing.T) {
+	t.Parallel()
+
+	args := createIPMIArgs()
+	args.RunCommand = nil
+	args.Config.IpmitoolPath = "/missing/ipmitool"
+	collector, _ := NewIPMICollector(args)
+	results, err := collector.Collect(context.Background())
+	assert.Error(t, err)
+	assert.Empty(t, results)
+}
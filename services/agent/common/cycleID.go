@@ -0,0 +1,17 @@
+package common
+
+import "context"
+
+type cycleIDContextKey struct{}
+
+// WithCycleID returns a copy of the context carrying the ID of the polling cycle being reported
+func WithCycleID(ctx context.Context, cycleID string) context.Context {
+	return context.WithValue(ctx, cycleIDContextKey{}, cycleID)
+}
+
+// CycleIDFromContext returns the ID of the polling cycle the context belongs to, empty if not set
+func CycleIDFromContext(ctx context.Context) string {
+	cycleID, _ := ctx.Value(cycleIDContextKey{}).(string)
+
+	return cycleID
+}
@@ -1,21 +1,104 @@
 package common
 
-import "github.com/iulianpascalau/api-monitoring/services/agent/config"
+import (
+	"encoding/json"
+
+	"github.com/iulianpascalau/api-monitoring/services/agent/config"
+)
 
 // MetricResult holds the extracted value for a specific endpoint configuration
 type MetricResult struct {
 	Config config.EndpointConfig
 	Value  string
+	// Stale is set when the poll failed and Value is the last good one, see EndpointConfig.FailuresBeforeStale
+	Stale bool
+	// PolledAt is the unix timestamp of the poll that produced the value, 0 if unknown
+	PolledAt int64
 }
 
 // ReportPayload is the paylod to be sent to the reporting aggregation service
 type ReportPayload struct {
 	Metrics map[string]MetricPayload `json:"metrics"`
+	Agent   *AgentInfo               `json:"agent,omitempty"`
+	// CycleID identifies the polling cycle, the server drops the reports of an already accepted cycle (e.g. retries)
+	CycleID string `json:"cycleId,omitempty"`
+	// SentAt is the agent unix timestamp of the report, the server measures the agent clock skew with it
+	SentAt int64 `json:"sentAt,omitempty"`
+}
+
+// BuildInfo holds the build details of the agent binary, set at build time
+type BuildInfo struct {
+	Version string
+	Commit  string
+}
+
+// AgentInfo identifies the agent build and configuration in the reports, so the outdated agents can be spotted
+type AgentInfo struct {
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	Commit     string `json:"commit"`
+	ConfigHash string `json:"configHash"`
+}
+
+// MQTTReportPayload is the report published on the MQTT topic. As there are no message headers in MQTT 3.1.1, the
+// service API key travels inside the message.
+type MQTTReportPayload struct {
+	ApiKey string `json:"apiKey"`
+	ReportPayload
 }
 
 // MetricPayload defines a recorded metric value
 type MetricPayload struct {
-	Value          string `json:"value"`
-	Type           string `json:"type"`
-	NumAggregation int    `json:"numAggregation"`
+	Value          string            `json:"value"`
+	Type           string            `json:"type"`
+	NumAggregation int               `json:"numAggregation"`
+	Tags           map[string]string `json:"tags,omitempty"`
+	// Interval is the number of seconds between two values of the metric, 0 if unknown
+	Interval int `json:"interval,omitempty"`
+	// Stale is set when the value is the last good one, re-sent because the endpoint poll failed
+	Stale bool `json:"stale,omitempty"`
+	// RecordedAt is the agent unix timestamp of the poll, the server stamps the value on reception if 0
+	RecordedAt int64 `json:"recordedAt,omitempty"`
+}
+
+// PayloadTraceEntry defines a single traced report exchange between the agent and the aggregation service
+type PayloadTraceEntry struct {
+	Timestamp  int64             `json:"timestamp"`
+	Endpoint   string            `json:"endpoint"`
+	Headers    map[string]string `json:"headers"`
+	Payload    json.RawMessage   `json:"payload"`
+	StatusCode int               `json:"statusCode"`
+	Error      string            `json:"error,omitempty"`
+	DurationMs int64             `json:"durationMs"`
+}
+
+// AgentStats holds the runtime statistics of the agent, as exposed by the local health listener
+type AgentStats struct {
+	StartedAt              int64             `json:"startedAt"`
+	NumPolls               uint64            `json:"numPolls"`
+	NumPollErrors          uint64            `json:"numPollErrors"`
+	LastPollAt             int64             `json:"lastPollAt"`
+	LastPollDurationMs     int64             `json:"lastPollDurationMs"`
+	LastPollResults        map[string]string `json:"lastPollResults"`
+	LastPollFailed         []string          `json:"lastPollFailed"`
+	NumReports             uint64            `json:"numReports"`
+	NumReportErrors        uint64            `json:"numReportErrors"`
+	LastReportAt           int64             `json:"lastReportAt"`
+	LastSuccessfulReportAt int64             `json:"lastSuccessfulReportAt"`
+	LastReportError        string            `json:"lastReportError,omitempty"`
+}
+
+// LogsPayload is the payload carrying the shipped agent warnings and errors to the aggregation service
+type LogsPayload struct {
+	Entries []LogEntry `json:"entries"`
+}
+
+// LogEntry defines a warning or an error logged by the agent
+type LogEntry struct {
+	// Timestamp is the unix time the entry was logged at
+	Timestamp int64             `json:"timestamp"`
+	Level     string            `json:"level"`
+	Component string            `json:"component"`
+	Message   string            `json:"message"`
+	Fields    map[string]string `json:"fields,omitempty"`
 }
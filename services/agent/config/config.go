@@ -1,28 +1,330 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/pelletier/go-toml/v2"
 )
 
 // EndpointConfig defines a single metric polling rule
 type EndpointConfig struct {
-	Name           string `toml:"Name"`
-	URL            string `toml:"URL"`
-	Value          string `toml:"Value"`
-	Type           string `toml:"Type"`
-	NumAggregation int    `toml:"NumAggregation"`
+	Name           string            `toml:"Name"`
+	URL            string            `toml:"URL"`
+	Value          string            `toml:"Value"`
+	Type           string            `toml:"Type"`
+	NumAggregation int               `toml:"NumAggregation"`
+	Tags           map[string]string `toml:"Tags"`
+	// FailuresBeforeStale is the number of consecutive failed polls for which the last good value is re-sent, flagged
+	// as stale, before the metric is omitted from the reports. 0 omits it on the first failure.
+	FailuresBeforeStale int `toml:"FailuresBeforeStale"`
+	// TimeoutInSeconds overrides the poll timeout of the endpoint, 0 uses QueryIntervalInSeconds
+	TimeoutInSeconds uint32 `toml:"TimeoutInSeconds"`
+	// Retries is the number of times a poll failing with a connection error or a 5xx status code is retried in the
+	// same cycle, within the endpoint timeout
+	Retries uint32 `toml:"Retries"`
+	// RetryBackoffInMilliseconds is the delay before the first retry, doubled before each next one, defaults to 500
+	RetryBackoffInMilliseconds uint32 `toml:"RetryBackoffInMilliseconds"`
+	// Validation defines the checks of the polled value, reported as the derived <Name>.ok bool metric
+	Validation ValidationConfig `toml:"Validation"`
+	// Transforms are applied in order on the extracted value, before the validation and the report
+	Transforms []TransformConfig `toml:"Transforms"`
+	// Kind is json (the default, Value is a gjson path) or html (Value is a CSS selector of the page)
+	Kind string `toml:"Kind"`
+	// Attribute is read from the element matched by the CSS selector of an html endpoint, empty reads its text
+	Attribute string `toml:"Attribute"`
+	// DependsOn defines a first request, its extracted value replaces the DependencyPlaceholder of the endpoint URL
+	DependsOn DependencyConfig `toml:"DependsOn"`
+	// Preset expands the endpoint into a standard metric set, see ExpandPresets
+	Preset string `toml:"Preset"`
+	// BLSKeys maps the node aliases to the BLS keys queried by the mx-api-validators preset
+	BLSKeys map[string]string `toml:"BLSKeys"`
+	// TraceTimings reports the DNS lookup, TCP connect, TLS handshake and time to first byte durations of the endpoint
+	// request as the derived <Name>.dnsMs, <Name>.connectMs, <Name>.tlsMs and <Name>.ttfbMs metrics
+	TraceTimings bool `toml:"TraceTimings"`
+	// ProxyURL overrides the Network.ProxyURL of the agent for this endpoint
+	ProxyURL string `toml:"ProxyURL"`
+	// UserAgent replaces the default Go User-Agent header of the requests, some WAFs block the latter
+	UserAgent string `toml:"UserAgent"`
+	// QueryParams are added to the query of the requests, replacing the parameters of the URL with the same name
+	QueryParams map[string]string `toml:"QueryParams"`
+	// Cookies are sent with the requests
+	Cookies map[string]string `toml:"Cookies"`
+}
+
+// Suffixes of the timing metrics of the endpoints with TraceTimings
+const (
+	TimingDNSSuffix     = ".dnsMs"
+	TimingConnectSuffix = ".connectMs"
+	TimingTLSSuffix     = ".tlsMs"
+	TimingTTFBSuffix    = ".ttfbMs"
+)
+
+// TimingSuffixes lists the suffixes of the timing metrics
+var TimingSuffixes = []string{TimingDNSSuffix, TimingConnectSuffix, TimingTLSSuffix, TimingTTFBSuffix}
+
+// DependencyPlaceholder is replaced in the endpoint URL by the value extracted by its DependsOn request
+const DependencyPlaceholder = "{value}"
+
+// DependencyConfig defines the first request of a two-step endpoint, e.g. fetching the current epoch before the stats
+// of that epoch
+type DependencyConfig struct {
+	URL string `toml:"URL"`
+	// Value is the gjson path extracted from the JSON response
+	Value string `toml:"Value"`
+}
+
+// IsEnabled returns true if the endpoint depends on a first request
+func (cfg DependencyConfig) IsEnabled() bool {
+	return len(cfg.URL) > 0
+}
+
+// Endpoint kinds
+const (
+	EndpointKindJSON = "json"
+	EndpointKindHTML = "html"
+)
+
+// Value transform types
+const (
+	TransformMultiply   = "multiply"
+	TransformDivide     = "divide"
+	TransformRound      = "round"
+	TransformMap        = "map"
+	TransformTrimSuffix = "trim-suffix"
+)
+
+// TransformConfig defines a step of the value transformation pipeline of an endpoint
+type TransformConfig struct {
+	// Type is one of multiply, divide, round, map or trim-suffix
+	Type string `toml:"Type"`
+	// Value is the factor of multiply and divide, the suffix of trim-suffix
+	Value string `toml:"Value"`
+	// Decimals is the number of decimals kept by round
+	Decimals uint32 `toml:"Decimals"`
+	// Mapping replaces the values by the numbers they map to, a value missing from it fails the poll
+	Mapping map[string]string `toml:"Mapping"`
+}
+
+// ValidationSuffix is appended to the endpoint name to form the name of the derived validation metric
+const ValidationSuffix = ".ok"
+
+// ValidationConfig defines the checks the agent applies on a polled value. All the set checks must pass.
+type ValidationConfig struct {
+	// MustEqual is the expected value, compared as a string
+	MustEqual string   `toml:"MustEqual"`
+	MinValue  *float64 `toml:"MinValue"`
+	MaxValue  *float64 `toml:"MaxValue"`
+	// Regex must match the value
+	Regex string `toml:"Regex"`
+}
+
+// IsEnabled returns true if at least one check is set
+func (cfg ValidationConfig) IsEnabled() bool {
+	return len(cfg.MustEqual) > 0 || cfg.MinValue != nil || cfg.MaxValue != nil || len(cfg.Regex) > 0
 }
 
 // Config maps to the config.toml file for the monitor agent
 type Config struct {
-	Name                   string           `toml:"Name"`
-	QueryIntervalInSeconds uint32           `toml:"QueryIntervalInSeconds"`
-	ReportEndpoint         string           `toml:"ReportEndpoint"`
-	ReportTimeoutInSeconds uint32           `toml:"ReportTimeoutInSeconds"`
-	Endpoints              []EndpointConfig `toml:"Endpoints"`
+	Name                     string             `toml:"Name"`
+	QueryIntervalInSeconds   uint32             `toml:"QueryIntervalInSeconds"`
+	AlignPollsToWallClock    bool               `toml:"AlignPollsToWallClock"`
+	PollSpreadInSeconds      uint32             `toml:"PollSpreadInSeconds"`
+	PollJitterInMilliseconds uint32             `toml:"PollJitterInMilliseconds"`
+	MaxConcurrentPolls       uint32             `toml:"MaxConcurrentPolls"`
+	ReportEndpoint           string             `toml:"ReportEndpoint"`
+	ReportTimeoutInSeconds   uint32             `toml:"ReportTimeoutInSeconds"`
+	ReportChecksum           bool               `toml:"ReportChecksum"`
+	ReportTransport          string             `toml:"ReportTransport"`
+	ReportEncoding           string             `toml:"ReportEncoding"`
+	Failover                 FailoverConfig     `toml:"Failover"`
+	FanOut                   FanOutConfig       `toml:"FanOut"`
+	MQTT                     MQTTConfig         `toml:"MQTT"`
+	PayloadTrace             PayloadTraceConfig `toml:"PayloadTrace"`
+	HealthServer             HealthServerConfig `toml:"HealthServer"`
+	SelfUpdate               SelfUpdateConfig   `toml:"SelfUpdate"`
+	LogShipping              LogShippingConfig  `toml:"LogShipping"`
+	ReportBuffer             ReportBufferConfig `toml:"ReportBuffer"`
+	Network                  NetworkConfig      `toml:"Network"`
+	IPMI                     IPMIConfig         `toml:"IPMI"`
+	Docker                   DockerConfig       `toml:"Docker"`
+	Endpoints                []EndpointConfig   `toml:"Endpoints"`
+}
+
+// Report transports
+const (
+	ReportTransportHTTP = "http"
+	ReportTransportMQTT = "mqtt"
+)
+
+// Report encodings of the HTTP reports
+const (
+	ReportEncodingJSON    = "json"
+	ReportEncodingMsgPack = "msgpack"
+)
+
+// FailoverConfig defines the replica aggregation services the HTTP reports fail over to when ReportEndpoint (the
+// primary) rejects them or can not be reached
+type FailoverConfig struct {
+	Enabled bool `toml:"Enabled"`
+	// Endpoints are the report endpoints of the replicas, tried in order
+	Endpoints []string `toml:"Endpoints"`
+	// PrimaryHealthCheckURL is polled while the reports go to a replica. Empty derives <scheme>://<host>/healthz from
+	// ReportEndpoint.
+	PrimaryHealthCheckURL string `toml:"PrimaryHealthCheckURL"`
+	// HealthCheckIntervalInSeconds defaults to 30
+	HealthCheckIntervalInSeconds uint32 `toml:"HealthCheckIntervalInSeconds"`
+	// NumHealthyChecksToFailBack is the number of consecutive successful health checks of the primary after which the
+	// reports go back to it, defaults to 3
+	NumHealthyChecksToFailBack uint32 `toml:"NumHealthyChecksToFailBack"`
+}
+
+// FanOutConfig defines the additional aggregation services every HTTP report is sent to, in parallel with
+// ReportEndpoint. A report cycle is successful if at least one of them accepted the report.
+type FanOutConfig struct {
+	Enabled   bool     `toml:"Enabled"`
+	Endpoints []string `toml:"Endpoints"`
+}
+
+// MQTTConfig defines the MQTT broker the reports are published to when ReportTransport is "mqtt"
+type MQTTConfig struct {
+	// BrokerURL uses the tcp:// (or mqtt://) scheme for plain connections and ssl:// (or mqtts://) for TLS
+	BrokerURL string `toml:"BrokerURL"`
+	Topic     string `toml:"Topic"`
+	// QoS is 0 (at most once) or 1 (at least once)
+	QoS      uint8  `toml:"QoS"`
+	ClientID string `toml:"ClientID"`
+	Username string `toml:"Username"`
+	Password string `toml:"Password"`
+	// KeepAliveInSeconds defaults to 30
+	KeepAliveInSeconds uint32 `toml:"KeepAliveInSeconds"`
+}
+
+// PayloadTraceConfig defines the debug option of recording the sent report payloads in rotating trace files
+type PayloadTraceConfig struct {
+	Enabled         bool   `toml:"Enabled"`
+	Directory       string `toml:"Directory"`
+	MaxFileSizeInMB uint32 `toml:"MaxFileSizeInMB"`
+	MaxNumFiles     uint32 `toml:"MaxNumFiles"`
+}
+
+// HealthServerConfig defines the optional local HTTP listener exposing the agent's /healthz and /stats endpoints
+type HealthServerConfig struct {
+	Enabled       bool   `toml:"Enabled"`
+	ListenAddress string `toml:"ListenAddress"`
+	// MaxReportAgeInSeconds is the maximum time since the last successful report for which /healthz reports the
+	// agent as healthy. 0 defaults to 3 query intervals.
+	MaxReportAgeInSeconds uint32 `toml:"MaxReportAgeInSeconds"`
+	// ProfilingEnabled also exposes the Go runtime profiling (pprof) endpoints under /debug/pprof/ on this listener
+	ProfilingEnabled bool `toml:"ProfilingEnabled"`
+}
+
+// SelfUpdateConfig defines the optional updater installing the signed agent releases published in a manifest
+type SelfUpdateConfig struct {
+	Enabled bool `toml:"Enabled"`
+	// ManifestURL is the URL of the release manifest JSON, the relative binary URLs are resolved against it
+	ManifestURL string `toml:"ManifestURL"`
+	// PublicKey is the hex encoded ed25519 key the releases are signed with
+	PublicKey string `toml:"PublicKey"`
+	// CheckIntervalInMinutes defaults to 60
+	CheckIntervalInMinutes uint32 `toml:"CheckIntervalInMinutes"`
+}
+
+// LogShippingConfig defines the optional forwarding of the agent warnings and errors to the aggregation service
+type LogShippingConfig struct {
+	Enabled bool `toml:"Enabled"`
+	// Endpoint receives the log entries. Empty derives the agents/<Name>/logs URL from ReportEndpoint, it is required
+	// with the MQTT report transport.
+	Endpoint string `toml:"Endpoint"`
+	// FlushIntervalInSeconds defaults to 30
+	FlushIntervalInSeconds uint32 `toml:"FlushIntervalInSeconds"`
+	// BufferSize is the maximum number of entries kept between two flushes, defaults to 100
+	BufferSize uint32 `toml:"BufferSize"`
+}
+
+// ReportBufferConfig defines the optional buffering of the reports that could not be sent, replayed once the
+// aggregation service accepts the reports again
+type ReportBufferConfig struct {
+	Enabled bool `toml:"Enabled"`
+	// MaxMemoryInMB caps the estimated memory of the buffered reports, the oldest are dropped first. Defaults to 8
+	MaxMemoryInMB uint32 `toml:"MaxMemoryInMB"`
+}
+
+// NetworkConfig defines how the outbound requests of the agent, the polls and the reports, reach their targets
+type NetworkConfig struct {
+	// ProxyURL is the http://, https://, socks5:// or socks5h:// proxy of the requests, e.g. a bastion host. Empty
+	// uses the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+	ProxyURL string `toml:"ProxyURL"`
+	// IPFamily restricts the connections to ipv4 or ipv6, empty allows both. With a proxy, it applies to the
+	// connection to the proxy.
+	IPFamily string `toml:"IPFamily"`
+}
+
+// IPMIConfig defines the hardware health sensors of a bare-metal server (temperatures, fan speeds and power supplies),
+// read with ipmitool every polling cycle and reported as the <Prefix>.temperature.<sensor>, <Prefix>.fan.<sensor> and
+// <Prefix>.psu.<sensor> metrics
+type IPMIConfig struct {
+	Enabled bool `toml:"Enabled"`
+	// Prefix of the metric names, empty uses <Name>.ipmi
+	Prefix string `toml:"Prefix"`
+	// IpmitoolPath defaults to ipmitool, looked up in the PATH
+	IpmitoolPath string `toml:"IpmitoolPath"`
+	// Host is the address of a remote BMC, read over the lanplus interface. Empty reads the local BMC.
+	Host     string `toml:"Host"`
+	Username string `toml:"Username"`
+	Password string `toml:"Password"`
+	// Sensors restricts the reported sensors to the listed names (as printed by ipmitool), empty reports all of them
+	Sensors        []string `toml:"Sensors"`
+	NumAggregation int      `toml:"NumAggregation"`
+	// TimeoutInSeconds bounds the ipmitool calls of a cycle, defaults to 10
+	TimeoutInSeconds uint32 `toml:"TimeoutInSeconds"`
+}
+
+// DockerConfig defines the containers whose state, restart count, CPU and memory usage are read from the local Docker
+// Engine API every polling cycle and reported as the <Prefix>.<container>.state, .restartCount, .cpuPercent and
+// .memoryBytes metrics
+type DockerConfig struct {
+	Enabled bool `toml:"Enabled"`
+	// SocketPath is the unix socket of the Docker Engine API, defaults to /var/run/docker.sock
+	SocketPath string `toml:"SocketPath"`
+	// Prefix of the metric names, empty uses <Name>.docker
+	Prefix string `toml:"Prefix"`
+	// Containers are the names (or IDs) of the reported containers
+	Containers     []string `toml:"Containers"`
+	NumAggregation int      `toml:"NumAggregation"`
+	// TimeoutInSeconds bounds the Docker API calls of a cycle, defaults to 10
+	TimeoutInSeconds uint32 `toml:"TimeoutInSeconds"`
+}
+
+// PollDelay returns the longest delay of an endpoint poll from the start of the polling round
+func (cfg Config) PollDelay() time.Duration {
+	return time.Duration(cfg.PollSpreadInSeconds)*time.Second + time.Duration(cfg.PollJitterInMilliseconds)*time.Millisecond
+}
+
+// PollTimeout returns the longest poll timeout of the endpoints, the ones without their own use the query interval
+func (cfg Config) PollTimeout() time.Duration {
+	timeout := time.Duration(cfg.QueryIntervalInSeconds) * time.Second
+	for _, endpoint := range cfg.Endpoints {
+		endpointTimeout := time.Duration(endpoint.TimeoutInSeconds) * time.Second
+		if endpointTimeout > timeout {
+			timeout = endpointTimeout
+		}
+	}
+
+	return timeout
+}
+
+// Hash returns a short fingerprint of the effective configuration, telling apart the agents running with different
+// configurations
+func (cfg Config) Hash() string {
+	data, _ := json.Marshal(cfg)
+	hash := sha256.Sum256(data)
+
+	return hex.EncodeToString(hash[:8])
 }
 
 // LoadConfig parses a TOML file into the Config struct
@@ -37,6 +339,7 @@ func LoadConfig(filepath string) (*Config, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode config file: %w", err)
 	}
+	cfg.ExpandPresets()
 
 	return &cfg, nil
 }
@@ -0,0 +1,222 @@
+package config
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Endpoint presets
+const (
+	PresetMXObserver      = "mx-observer"
+	PresetMXValidator     = "mx-validator"
+	PresetMXProxy         = "mx-proxy"
+	PresetMXElasticsearch = "mx-elasticsearch"
+	PresetMXAPIValidators = "mx-api-validators"
+)
+
+// presetMetric is a metric of a preset bundle, its path is appended to the base URL of the preset endpoint
+type presetMetric struct {
+	suffix         string
+	path           string
+	value          string
+	metricType     string
+	numAggregation int
+	transforms     []TransformConfig
+}
+
+const (
+	nodeStatusPath          = "/node/status"
+	metachainStatusPath     = "/network/status/4294967295"
+	elasticsearchHealthPath = "/_cluster/health"
+	apiNodePath             = "/nodes/" + blsKeyPlaceholder
+	blsKeyPlaceholder       = "{blsKey}"
+	blsKeyLength            = 192
+	denomination            = "1000000000000000000"
+)
+
+func newPresetMetric(suffix string, path string, value string, metricType string, numAggregation int) presetMetric {
+	return presetMetric{
+		suffix:         suffix,
+		path:           path,
+		value:          value,
+		metricType:     metricType,
+		numAggregation: numAggregation,
+	}
+}
+
+func nodeMetric(suffix string, metric string, metricType string, numAggregation int) presetMetric {
+	return newPresetMetric(suffix, nodeStatusPath, "data.metrics."+metric, metricType, numAggregation)
+}
+
+func metachainMetric(suffix string, metric string, numAggregation int) presetMetric {
+	return newPresetMetric(suffix, metachainStatusPath, "data.status."+metric, "uint64", numAggregation)
+}
+
+func elasticsearchMetric(suffix string, field string, metricType string) presetMetric {
+	return newPresetMetric(suffix, elasticsearchHealthPath, field, metricType, 10)
+}
+
+// syncedMetric negates erd_is_syncing, which is 1 while the node is syncing
+func syncedMetric() presetMetric {
+	metric := nodeMetric("synced", "erd_is_syncing", "uint64", 10)
+	metric.transforms = []TransformConfig{
+		{Type: TransformMap, Mapping: map[string]string{"0": "1", "1": "0"}},
+	}
+
+	return metric
+}
+
+// stakeMetric converts the denominated amount reported by the API to EGLD
+func stakeMetric(suffix string, field string) presetMetric {
+	metric := newPresetMetric(suffix, apiNodePath, field, "float64", 10)
+	metric.transforms = []TransformConfig{
+		{Type: TransformDivide, Value: denomination},
+	}
+
+	return metric
+}
+
+func observerMetrics() []presetMetric {
+	return []presetMetric{
+		nodeMetric("nonce", "erd_nonce", "uint64", 100),
+		nodeMetric("epoch", "erd_epoch_number", "uint64", 1),
+		syncedMetric(),
+		nodeMetric("peers", "erd_num_connected_peers", "uint64", 10),
+		nodeMetric("shard", "erd_shard_id", "uint64", 1),
+	}
+}
+
+var presets = map[string][]presetMetric{
+	PresetMXObserver: observerMetrics(),
+	PresetMXValidator: append(observerMetrics(),
+		nodeMetric("peerType", "erd_peer_type", "string", 1),
+		nodeMetric("consensus", "erd_count_consensus", "uint64", 10),
+		nodeMetric("leader", "erd_count_leader", "uint64", 10),
+		nodeMetric("acceptedBlocks", "erd_count_accepted_blocks", "uint64", 10),
+	),
+	PresetMXProxy: {
+		metachainMetric("nonce", "erd_nonce", 100),
+		metachainMetric("epoch", "erd_epoch_number", 1),
+		metachainMetric("round", "erd_current_round", 10),
+	},
+	PresetMXElasticsearch: {
+		elasticsearchMetric("health", "status", "string"),
+		elasticsearchMetric("nodes", "number_of_nodes", "uint64"),
+		elasticsearchMetric("unassignedShards", "unassigned_shards", "uint64"),
+	},
+	// queries the MultiversX API, or a proxy exposing its /nodes route, once for each of the BLSKeys
+	PresetMXAPIValidators: {
+		newPresetMetric("rating", apiNodePath, "rating", "float64", 10),
+		newPresetMetric("tempRating", apiNodePath, "tempRating", "float64", 10),
+		stakeMetric("stake", "stake"),
+	},
+}
+
+// PresetNames returns the sorted names of the supported presets
+func PresetNames() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// ExpandPresets replaces each endpoint declaring a known preset with the metrics of its bundle, see
+// ExpandPresetEndpoints
+func (cfg *Config) ExpandPresets() {
+	cfg.Endpoints = ExpandPresetEndpoints(cfg.Endpoints)
+}
+
+// ExpandPresetEndpoints replaces each endpoint declaring a known preset with the metrics of its bundle. The Name of
+// the preset endpoint is the prefix of the metric names and its URL is the base URL of the queried API. The tags, the
+// timeout, the retries and the stale settings are copied to all the metrics, a non-zero NumAggregation overrides the
+// preset defaults. The mx-api-validators preset is expanded once for each of the BLSKeys, the alias of the key is
+// added to the metric names. The endpoints with an unknown preset or invalid BLS keys are kept, so the validation
+// reports them.
+func ExpandPresetEndpoints(presetEndpoints []EndpointConfig) []EndpointConfig {
+	endpoints := make([]EndpointConfig, 0, len(presetEndpoints))
+	for _, endpoint := range presetEndpoints {
+		metrics, found := presets[endpoint.Preset]
+		if !found || len(blsKeysProblem(endpoint)) > 0 {
+			endpoints = append(endpoints, endpoint)
+			continue
+		}
+		if endpoint.Preset != PresetMXAPIValidators {
+			endpoints = append(endpoints, expandPreset(endpoint, endpoint.Name, "", metrics)...)
+			continue
+		}
+
+		aliases := make([]string, 0, len(endpoint.BLSKeys))
+		for alias := range endpoint.BLSKeys {
+			aliases = append(aliases, alias)
+		}
+		sort.Strings(aliases)
+		for _, alias := range aliases {
+			prefix := endpoint.Name + "." + alias
+			endpoints = append(endpoints, expandPreset(endpoint, prefix, endpoint.BLSKeys[alias], metrics)...)
+		}
+	}
+
+	return endpoints
+}
+
+func expandPreset(endpoint EndpointConfig, prefix string, blsKey string, metrics []presetMetric) []EndpointConfig {
+	baseURL := strings.TrimRight(endpoint.URL, "/")
+	endpoints := make([]EndpointConfig, 0, len(metrics))
+	for _, metric := range metrics {
+		numAggregation := metric.numAggregation
+		if endpoint.NumAggregation > 0 {
+			numAggregation = endpoint.NumAggregation
+		}
+
+		endpoints = append(endpoints, EndpointConfig{
+			Name:                       prefix + "." + metric.suffix,
+			URL:                        baseURL + strings.ReplaceAll(metric.path, blsKeyPlaceholder, blsKey),
+			Value:                      metric.value,
+			Type:                       metric.metricType,
+			NumAggregation:             numAggregation,
+			Tags:                       endpoint.Tags,
+			FailuresBeforeStale:        endpoint.FailuresBeforeStale,
+			TimeoutInSeconds:           endpoint.TimeoutInSeconds,
+			Retries:                    endpoint.Retries,
+			RetryBackoffInMilliseconds: endpoint.RetryBackoffInMilliseconds,
+			Transforms:                 metric.transforms,
+			TraceTimings:               endpoint.TraceTimings,
+			ProxyURL:                   endpoint.ProxyURL,
+			UserAgent:                  endpoint.UserAgent,
+			QueryParams:                endpoint.QueryParams,
+			Cookies:                    endpoint.Cookies,
+		})
+	}
+
+	return endpoints
+}
+
+// blsKeysProblem returns the reason the BLSKeys of the mx-api-validators preset are not valid, or an empty string
+func blsKeysProblem(endpoint EndpointConfig) string {
+	if endpoint.Preset != PresetMXAPIValidators {
+		return ""
+	}
+	if len(endpoint.BLSKeys) == 0 {
+		return "BLSKeys is empty"
+	}
+
+	aliases := make([]string, 0, len(endpoint.BLSKeys))
+	for alias := range endpoint.BLSKeys {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	for _, alias := range aliases {
+		key := endpoint.BLSKeys[alias]
+		_, err := hex.DecodeString(key)
+		if err != nil || len(key) != blsKeyLength {
+			return fmt.Sprintf("BLSKeys.%s is not a hex encoded BLS key of %d characters", alias, blsKeyLength)
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,168 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testBLSKey = strings.Repeat("ab", blsKeyLength/2)
+
+func TestConfig_ExpandPresets(t *testing.T) {
+	t.Parallel()
+
+	t.Run("observer preset should expand", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := createValidConfig()
+		cfg.Endpoints = append(cfg.Endpoints, EndpointConfig{
+			Name:    "VM1.Node2",
+			URL:     "http://127.0.0.1:8081/",
+			Preset:  PresetMXObserver,
+			Tags:    map[string]string{"shard": "1"},
+			Retries: 2,
+			Validation: ValidationConfig{
+				MustEqual: "ignored",
+			},
+		})
+		cfg.ExpandPresets()
+
+		require.Len(t, cfg.Endpoints, 6)
+		assert.Equal(t, "VM1.Node1.nonce", cfg.Endpoints[0].Name)
+		nonce := cfg.Endpoints[1]
+		assert.Equal(t, "VM1.Node2.nonce", nonce.Name)
+		assert.Equal(t, "http://127.0.0.1:8081/node/status", nonce.URL)
+		assert.Equal(t, "data.metrics.erd_nonce", nonce.Value)
+		assert.Equal(t, "uint64", nonce.Type)
+		assert.Equal(t, 100, nonce.NumAggregation)
+		assert.Equal(t, map[string]string{"shard": "1"}, nonce.Tags)
+		assert.Equal(t, uint32(2), nonce.Retries)
+		assert.Empty(t, nonce.Preset)
+		assert.False(t, nonce.Validation.IsEnabled())
+
+		synced := cfg.Endpoints[3]
+		assert.Equal(t, "VM1.Node2.synced", synced.Name)
+		require.Len(t, synced.Transforms, 1)
+		assert.Equal(t, TransformMap, synced.Transforms[0].Type)
+		assert.Nil(t, cfg.Validate())
+	})
+	t.Run("NumAggregation should override the preset defaults", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := createValidConfig()
+		cfg.Endpoints = []EndpointConfig{
+			{Name: "VM1.Proxy", URL: "https://gateway.multiversx.com", Preset: PresetMXProxy, NumAggregation: 5},
+		}
+		cfg.ExpandPresets()
+
+		require.Len(t, cfg.Endpoints, 3)
+		for _, endpoint := range cfg.Endpoints {
+			assert.Equal(t, 5, endpoint.NumAggregation)
+			assert.Equal(t, "https://gateway.multiversx.com/network/status/4294967295", endpoint.URL)
+		}
+		assert.Nil(t, cfg.Validate())
+	})
+	t.Run("all the presets should expand into valid endpoints", func(t *testing.T) {
+		t.Parallel()
+
+		for _, preset := range PresetNames() {
+			cfg := createValidConfig()
+			cfg.Endpoints = []EndpointConfig{
+				{
+					Name:    "VM1.Service",
+					URL:     "http://127.0.0.1:8080",
+					Preset:  preset,
+					BLSKeys: map[string]string{"Node1": testBLSKey},
+				},
+			}
+			cfg.ExpandPresets()
+
+			assert.NotEmpty(t, cfg.Endpoints, preset)
+			assert.Nil(t, cfg.Validate(), preset)
+		}
+	})
+	t.Run("api validators preset should expand for each BLS key", func(t *testing.T) {
+		t.Parallel()
+
+		otherKey := strings.Repeat("cd", blsKeyLength/2)
+		cfg := createValidConfig()
+		cfg.Endpoints = []EndpointConfig{
+			{
+				Name:    "Validators",
+				URL:     "https://api.multiversx.com",
+				Preset:  PresetMXAPIValidators,
+				BLSKeys: map[string]string{"Node2": otherKey, "Node1": testBLSKey},
+			},
+		}
+		cfg.ExpandPresets()
+
+		require.Len(t, cfg.Endpoints, 6)
+		assert.Equal(t, "Validators.Node1.rating", cfg.Endpoints[0].Name)
+		assert.Equal(t, "https://api.multiversx.com/nodes/"+testBLSKey, cfg.Endpoints[0].URL)
+		assert.Equal(t, "rating", cfg.Endpoints[0].Value)
+		assert.Equal(t, "Validators.Node1.tempRating", cfg.Endpoints[1].Name)
+		stake := cfg.Endpoints[2]
+		assert.Equal(t, "Validators.Node1.stake", stake.Name)
+		assert.Equal(t, []TransformConfig{{Type: TransformDivide, Value: denomination}}, stake.Transforms)
+		assert.Equal(t, "Validators.Node2.rating", cfg.Endpoints[3].Name)
+		assert.Equal(t, "https://api.multiversx.com/nodes/"+otherKey, cfg.Endpoints[3].URL)
+		assert.Nil(t, cfg.Validate())
+	})
+	t.Run("api validators preset with invalid BLS keys should not validate", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := createValidConfig()
+		cfg.Endpoints = []EndpointConfig{
+			{Name: "Validators", URL: "https://api.multiversx.com", Preset: PresetMXAPIValidators},
+			{
+				Name:    "Validators2",
+				URL:     "https://api.multiversx.com",
+				Preset:  PresetMXAPIValidators,
+				BLSKeys: map[string]string{"Node1": "abcd"},
+			},
+		}
+		cfg.ExpandPresets()
+
+		require.Len(t, cfg.Endpoints, 2)
+		err := cfg.Validate()
+		require.NotNil(t, err)
+		assert.Contains(t, err.Error(), "Endpoints[0] (Validators): BLSKeys is empty")
+		assert.Contains(t, err.Error(),
+			"Endpoints[1] (Validators2): BLSKeys.Node1 is not a hex encoded BLS key of 192 characters")
+	})
+	t.Run("unknown preset should not validate", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := createValidConfig()
+		cfg.Endpoints = []EndpointConfig{{Name: "VM1.Node1", URL: "http://127.0.0.1:8080", Preset: "mx-unknown"}}
+		cfg.ExpandPresets()
+
+		require.Len(t, cfg.Endpoints, 1)
+		err := cfg.Validate()
+		require.NotNil(t, err)
+		assert.Contains(t, err.Error(), `Endpoints[0] (VM1.Node1): Preset "mx-unknown" is not supported, use one of `+
+			"mx-api-validators, mx-elasticsearch, mx-observer, mx-proxy, mx-validator")
+		assert.Contains(t, err.Error(), "1 problem(s) found")
+	})
+	t.Run("LoadConfig should expand the presets", func(t *testing.T) {
+		t.Parallel()
+
+		configFile := filepath.Join(t.TempDir(), "config.toml")
+		contents := `
+[[Endpoints]]
+    Name = "VM1.Node1"
+    URL = "http://127.0.0.1:8080"
+    Preset = "mx-validator"
+`
+		require.Nil(t, os.WriteFile(configFile, []byte(contents), 0600))
+
+		cfg, err := LoadConfig(configFile)
+		require.Nil(t, err)
+		require.Len(t, cfg.Endpoints, 9)
+		assert.Equal(t, "VM1.Node1.peerType", cfg.Endpoints[5].Name)
+	})
+}
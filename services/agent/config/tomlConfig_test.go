@@ -2,6 +2,7 @@ package config
 
 import (
 	"testing"
+	"time"
 
 	"github.com/pelletier/go-toml"
 	"github.com/stretchr/testify/assert"
@@ -15,6 +16,13 @@ Name = "VM1"
 QueryIntervalInSeconds = 60
 ReportEndpoint = "https://aaa.bbb.com/report"
 ReportTimeoutInSeconds = 10
+ReportChecksum = true
+
+[PayloadTrace]
+    Enabled = true
+    Directory = "trace"
+    MaxFileSizeInMB = 10
+    MaxNumFiles = 5
 
 [[Endpoints]]
     Name = "VM1.Node1.nonce"
@@ -22,6 +30,7 @@ ReportTimeoutInSeconds = 10
     Value = "erd_nonce"
     Type = "uint64"
     NumAggregation = 100
+    Tags = { shard = "0" }
 
 [[Endpoints]]
     Name = "VM1.Node2.nonce"
@@ -43,6 +52,13 @@ ReportTimeoutInSeconds = 10
 		QueryIntervalInSeconds: 60,
 		ReportEndpoint:         "https://aaa.bbb.com/report",
 		ReportTimeoutInSeconds: 10,
+		ReportChecksum:         true,
+		PayloadTrace: PayloadTraceConfig{
+			Enabled:         true,
+			Directory:       "trace",
+			MaxFileSizeInMB: 10,
+			MaxNumFiles:     5,
+		},
 		Endpoints: []EndpointConfig{
 			{
 				Name:           "VM1.Node1.nonce",
@@ -50,6 +66,7 @@ ReportTimeoutInSeconds = 10
 				Value:          "erd_nonce",
 				Type:           "uint64",
 				NumAggregation: 100,
+				Tags:           map[string]string{"shard": "0"},
 			},
 			{
 				Name:           "VM1.Node2.nonce",
@@ -74,3 +91,34 @@ ReportTimeoutInSeconds = 10
 	assert.Nil(t, err)
 	assert.Equal(t, expectedCfg, cfg)
 }
+
+func TestConfig_Hash(t *testing.T) {
+	t.Parallel()
+
+	cfg := Config{
+		Name:                   "VM1",
+		QueryIntervalInSeconds: 60,
+	}
+	hash := cfg.Hash()
+	assert.Len(t, hash, 16)
+	assert.Equal(t, hash, cfg.Hash())
+
+	cfg.QueryIntervalInSeconds = 30
+	assert.NotEqual(t, hash, cfg.Hash())
+}
+
+func TestConfig_PollTimeoutAndDelay(t *testing.T) {
+	t.Parallel()
+
+	cfg := Config{
+		QueryIntervalInSeconds:   60,
+		PollSpreadInSeconds:      10,
+		PollJitterInMilliseconds: 500,
+		Endpoints:                []EndpointConfig{{TimeoutInSeconds: 5}},
+	}
+	assert.Equal(t, time.Minute, cfg.PollTimeout())
+	assert.Equal(t, 10500*time.Millisecond, cfg.PollDelay())
+
+	cfg.QueryIntervalInSeconds = 2
+	assert.Equal(t, 5*time.Second, cfg.PollTimeout())
+}
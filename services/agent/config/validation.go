@@ -0,0 +1,450 @@
+package config
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/iulianpascalau/api-monitoring/commonGo"
+	"github.com/iulianpascalau/api-monitoring/commonGo/mqtt"
+	"github.com/iulianpascalau/api-monitoring/commonGo/release"
+)
+
+const (
+	minQueryIntervalInSeconds = 1
+	maxQueryIntervalInSeconds = 86400
+	minReportTimeoutInSeconds = 1
+	maxReportTimeoutInSeconds = 300
+	minNumAggregation         = 1
+	maxMQTTQoS                = 1
+	maxEndpointRetries        = 10
+	maxTransformDecimals      = 18
+	maxReportBufferMemoryInMB = 1024
+	endpointProblemPrefix     = "Endpoints[%d] (%s): "
+)
+
+var supportedMetricTypes = map[string]struct{}{
+	"uint64":  {},
+	"float64": {},
+	"string":  {},
+	"bool":    {},
+}
+
+// Validate checks the config values, returning all the problems found as a single human-readable error
+func (cfg Config) Validate() error {
+	errs := &commonGo.ConfigErrors{}
+
+	if len(strings.TrimSpace(cfg.Name)) == 0 {
+		errs.Add("Name is empty")
+	}
+	if cfg.QueryIntervalInSeconds < minQueryIntervalInSeconds || cfg.QueryIntervalInSeconds > maxQueryIntervalInSeconds {
+		errs.Add("QueryIntervalInSeconds must be between %d and %d, got %d",
+			minQueryIntervalInSeconds, maxQueryIntervalInSeconds, cfg.QueryIntervalInSeconds)
+	}
+	if cfg.PollDelay() > 0 && cfg.PollDelay() >= time.Duration(cfg.QueryIntervalInSeconds)*time.Second {
+		errs.Add("PollSpreadInSeconds plus PollJitterInMilliseconds must be less than QueryIntervalInSeconds, got %s",
+			cfg.PollDelay())
+	}
+	switch cfg.ReportTransport {
+	case "", ReportTransportHTTP:
+		if !commonGo.IsHTTPURL(cfg.ReportEndpoint) {
+			errs.Add("ReportEndpoint %q is not a valid http(s) URL", cfg.ReportEndpoint)
+		}
+		cfg.validateFailover(errs)
+		cfg.validateFanOut(errs)
+		if cfg.ReportEncoding != "" && cfg.ReportEncoding != ReportEncodingJSON && cfg.ReportEncoding != ReportEncodingMsgPack {
+			errs.Add("ReportEncoding %q is not supported, use %q or %q", cfg.ReportEncoding, ReportEncodingJSON,
+				ReportEncodingMsgPack)
+		}
+	case ReportTransportMQTT:
+		cfg.validateMQTT(errs)
+		if cfg.ReportEncoding != "" && cfg.ReportEncoding != ReportEncodingJSON {
+			errs.Add("ReportEncoding %q is not supported by the %q transport", cfg.ReportEncoding, ReportTransportMQTT)
+		}
+	default:
+		errs.Add("ReportTransport %q is not supported, use %q or %q", cfg.ReportTransport, ReportTransportHTTP, ReportTransportMQTT)
+	}
+	if cfg.ReportTimeoutInSeconds < minReportTimeoutInSeconds || cfg.ReportTimeoutInSeconds > maxReportTimeoutInSeconds {
+		errs.Add("ReportTimeoutInSeconds must be between %d and %d, got %d",
+			minReportTimeoutInSeconds, maxReportTimeoutInSeconds, cfg.ReportTimeoutInSeconds)
+	}
+
+	if cfg.PayloadTrace.Enabled {
+		if len(strings.TrimSpace(cfg.PayloadTrace.Directory)) == 0 {
+			errs.Add("PayloadTrace.Directory is empty")
+		}
+		if cfg.PayloadTrace.MaxFileSizeInMB == 0 {
+			errs.Add("PayloadTrace.MaxFileSizeInMB must be at least 1")
+		}
+		if cfg.PayloadTrace.MaxNumFiles == 0 {
+			errs.Add("PayloadTrace.MaxNumFiles must be at least 1")
+		}
+	}
+
+	if cfg.HealthServer.Enabled {
+		_, _, err := net.SplitHostPort(cfg.HealthServer.ListenAddress)
+		if err != nil {
+			errs.Add("HealthServer.ListenAddress %q is not a valid host:port address", cfg.HealthServer.ListenAddress)
+		}
+	}
+
+	if cfg.SelfUpdate.Enabled {
+		if !commonGo.IsHTTPURL(cfg.SelfUpdate.ManifestURL) {
+			errs.Add("SelfUpdate.ManifestURL %q is not a valid http(s) URL", cfg.SelfUpdate.ManifestURL)
+		}
+		_, err := release.ParsePublicKey(cfg.SelfUpdate.PublicKey)
+		if err != nil {
+			errs.Add("SelfUpdate.PublicKey is not a hex encoded ed25519 public key")
+		}
+	}
+
+	cfg.ValidateNetwork(errs)
+
+	if cfg.LogShipping.Enabled {
+		if len(cfg.LogShipping.Endpoint) > 0 && !commonGo.IsHTTPURL(cfg.LogShipping.Endpoint) {
+			errs.Add("LogShipping.Endpoint %q is not a valid http(s) URL", cfg.LogShipping.Endpoint)
+		}
+		if len(cfg.LogShipping.Endpoint) == 0 && cfg.ReportTransport == ReportTransportMQTT {
+			errs.Add("LogShipping.Endpoint is required with the %q report transport", ReportTransportMQTT)
+		}
+	}
+
+	if cfg.ReportBuffer.Enabled && cfg.ReportBuffer.MaxMemoryInMB > maxReportBufferMemoryInMB {
+		errs.Add("ReportBuffer.MaxMemoryInMB must be at most %d, got %d", maxReportBufferMemoryInMB,
+			cfg.ReportBuffer.MaxMemoryInMB)
+	}
+
+	cfg.validateIPMI(errs)
+	cfg.validateDocker(errs)
+	cfg.ValidateEndpoints(errs)
+
+	return errs.Err()
+}
+
+func (cfg Config) validateIPMI(errs *commonGo.ConfigErrors) {
+	if !cfg.IPMI.Enabled {
+		return
+	}
+
+	if cfg.IPMI.NumAggregation < minNumAggregation {
+		errs.Add("IPMI.NumAggregation must be at least %d, got %d", minNumAggregation, cfg.IPMI.NumAggregation)
+	}
+	if len(cfg.IPMI.Host) > 0 && len(cfg.IPMI.Username) == 0 {
+		errs.Add("IPMI.Username is required with IPMI.Host")
+	}
+	if len(cfg.IPMI.Host) == 0 && (len(cfg.IPMI.Username) > 0 || len(cfg.IPMI.Password) > 0) {
+		errs.Add("IPMI.Username and IPMI.Password are only used with IPMI.Host")
+	}
+	if cfg.IPMI.TimeoutInSeconds > cfg.QueryIntervalInSeconds {
+		errs.Add("IPMI.TimeoutInSeconds can not be greater than QueryIntervalInSeconds, got %d", cfg.IPMI.TimeoutInSeconds)
+	}
+}
+
+func (cfg Config) validateDocker(errs *commonGo.ConfigErrors) {
+	if !cfg.Docker.Enabled {
+		return
+	}
+
+	if len(cfg.Docker.Containers) == 0 {
+		errs.Add("Docker.Containers is empty")
+	}
+	names := make(map[string]int, len(cfg.Docker.Containers))
+	for i, container := range cfg.Docker.Containers {
+		if len(strings.TrimSpace(container)) == 0 {
+			errs.Add("Docker.Containers[%d] is empty", i)
+			continue
+		}
+		firstIndex, found := names[container]
+		if found {
+			errs.Add("Docker.Containers[%d] %q is already listed at Docker.Containers[%d]", i, container, firstIndex)
+		} else {
+			names[container] = i
+		}
+	}
+	if cfg.Docker.NumAggregation < minNumAggregation {
+		errs.Add("Docker.NumAggregation must be at least %d, got %d", minNumAggregation, cfg.Docker.NumAggregation)
+	}
+	if cfg.Docker.TimeoutInSeconds > cfg.QueryIntervalInSeconds {
+		errs.Add("Docker.TimeoutInSeconds can not be greater than QueryIntervalInSeconds, got %d", cfg.Docker.TimeoutInSeconds)
+	}
+}
+
+func (cfg Config) validateFailover(errs *commonGo.ConfigErrors) {
+	if !cfg.Failover.Enabled {
+		return
+	}
+
+	if len(cfg.Failover.Endpoints) == 0 {
+		errs.Add("Failover.Endpoints is empty")
+	}
+	for i, endpoint := range cfg.Failover.Endpoints {
+		if !commonGo.IsHTTPURL(endpoint) {
+			errs.Add("Failover.Endpoints[%d] %q is not a valid http(s) URL", i, endpoint)
+		}
+		if endpoint == cfg.ReportEndpoint {
+			errs.Add("Failover.Endpoints[%d] %q is the same as ReportEndpoint", i, endpoint)
+		}
+	}
+	if len(cfg.Failover.PrimaryHealthCheckURL) > 0 && !commonGo.IsHTTPURL(cfg.Failover.PrimaryHealthCheckURL) {
+		errs.Add("Failover.PrimaryHealthCheckURL %q is not a valid http(s) URL", cfg.Failover.PrimaryHealthCheckURL)
+	}
+}
+
+func (cfg Config) validateFanOut(errs *commonGo.ConfigErrors) {
+	if !cfg.FanOut.Enabled {
+		return
+	}
+
+	if cfg.Failover.Enabled {
+		errs.Add("FanOut and Failover can not be enabled at the same time")
+	}
+	if len(cfg.FanOut.Endpoints) == 0 {
+		errs.Add("FanOut.Endpoints is empty")
+	}
+	for i, endpoint := range cfg.FanOut.Endpoints {
+		if !commonGo.IsHTTPURL(endpoint) {
+			errs.Add("FanOut.Endpoints[%d] %q is not a valid http(s) URL", i, endpoint)
+		}
+		if endpoint == cfg.ReportEndpoint {
+			errs.Add("FanOut.Endpoints[%d] %q is the same as ReportEndpoint", i, endpoint)
+		}
+	}
+}
+
+func (cfg Config) validateMQTT(errs *commonGo.ConfigErrors) {
+	if !mqtt.IsValidBrokerURL(cfg.MQTT.BrokerURL) {
+		errs.Add("MQTT.BrokerURL %q is not a valid tcp://, mqtt://, ssl:// or mqtts:// URL", cfg.MQTT.BrokerURL)
+	}
+	if !mqtt.IsValidPublishTopic(cfg.MQTT.Topic) {
+		errs.Add("MQTT.Topic %q must not be empty and can not contain wildcards", cfg.MQTT.Topic)
+	}
+	if cfg.MQTT.QoS > maxMQTTQoS {
+		errs.Add("MQTT.QoS must be 0 or 1, got %d", cfg.MQTT.QoS)
+	}
+}
+
+// ValidateNetwork records the problems of the network settings
+func (cfg Config) ValidateNetwork(errs *commonGo.ConfigErrors) {
+	if len(cfg.Network.ProxyURL) > 0 {
+		_, err := commonGo.ParseProxyURL(cfg.Network.ProxyURL)
+		if err != nil {
+			errs.Add("Network.ProxyURL %q is not a valid http, https, socks5 or socks5h URL", cfg.Network.ProxyURL)
+		}
+	}
+	if !commonGo.IsValidIPFamily(cfg.Network.IPFamily) {
+		errs.Add("Network.IPFamily %q is not supported, use %q or %q", cfg.Network.IPFamily,
+			commonGo.IPFamilyIPv4, commonGo.IPFamilyIPv6)
+	}
+}
+
+// derivedMetric is a metric reported next to the value of an endpoint
+type derivedMetric struct {
+	index int
+	kind  string
+}
+
+// ValidateEndpoints records the problems of the endpoint definitions, it only reads QueryIntervalInSeconds from the
+// rest of the config
+func (cfg Config) ValidateEndpoints(errs *commonGo.ConfigErrors) {
+	names := make(map[string]int, len(cfg.Endpoints))
+	derivedNames := make(map[string]derivedMetric)
+	for i, endpoint := range cfg.Endpoints {
+		if endpoint.Validation.IsEnabled() {
+			derivedNames[endpoint.Name+ValidationSuffix] = derivedMetric{index: i, kind: "validation"}
+		}
+		if endpoint.TraceTimings {
+			for _, suffix := range TimingSuffixes {
+				derivedNames[endpoint.Name+suffix] = derivedMetric{index: i, kind: "timing"}
+			}
+		}
+	}
+	for i, endpoint := range cfg.Endpoints {
+		derived, isDerivedName := derivedNames[endpoint.Name]
+		if len(strings.TrimSpace(endpoint.Name)) == 0 {
+			errs.Add("Endpoints[%d]: Name is empty", i)
+		} else if isDerivedName {
+			errs.Add("Endpoints[%d]: Name %q is already used by the %s metric of Endpoints[%d]",
+				i, endpoint.Name, derived.kind, derived.index)
+		} else {
+			firstIndex, found := names[endpoint.Name]
+			if found {
+				errs.Add("Endpoints[%d]: Name %q is already used by Endpoints[%d]", i, endpoint.Name, firstIndex)
+			} else {
+				names[endpoint.Name] = i
+			}
+		}
+
+		if len(endpoint.Preset) > 0 {
+			problem := blsKeysProblem(endpoint)
+			_, found := presets[endpoint.Preset]
+			if !found {
+				problem = fmt.Sprintf("Preset %q is not supported, use one of %s",
+					endpoint.Preset, strings.Join(PresetNames(), ", "))
+			}
+			errs.Add(endpointProblemPrefix+"%s", i, endpoint.Name, problem)
+			continue
+		}
+		if !commonGo.IsHTTPURL(endpoint.URL) {
+			errs.Add(endpointProblemPrefix+"URL %q is not a valid http(s) URL", i, endpoint.Name, endpoint.URL)
+		}
+		if len(strings.TrimSpace(endpoint.Value)) == 0 {
+			errs.Add(endpointProblemPrefix+"Value is empty", i, endpoint.Name)
+		} else {
+			cfg.validateEndpointKind(errs, i, endpoint)
+		}
+		_, supported := supportedMetricTypes[endpoint.Type]
+		if !supported {
+			errs.Add(endpointProblemPrefix+"Type %q is not supported, use one of uint64, float64, string or bool",
+				i, endpoint.Name, endpoint.Type)
+		}
+		if endpoint.NumAggregation < minNumAggregation {
+			errs.Add(endpointProblemPrefix+"NumAggregation must be at least %d, got %d",
+				i, endpoint.Name, minNumAggregation, endpoint.NumAggregation)
+		}
+		if endpoint.TimeoutInSeconds > cfg.QueryIntervalInSeconds {
+			errs.Add(endpointProblemPrefix+"TimeoutInSeconds can not exceed QueryIntervalInSeconds, got %d",
+				i, endpoint.Name, endpoint.TimeoutInSeconds)
+		}
+		if endpoint.Retries > maxEndpointRetries {
+			errs.Add(endpointProblemPrefix+"Retries can not exceed %d, got %d",
+				i, endpoint.Name, maxEndpointRetries, endpoint.Retries)
+		}
+		if len(endpoint.ProxyURL) > 0 {
+			_, err := commonGo.ParseProxyURL(endpoint.ProxyURL)
+			if err != nil {
+				errs.Add(endpointProblemPrefix+"ProxyURL %q is not a valid http, https, socks5 or socks5h URL",
+					i, endpoint.Name, endpoint.ProxyURL)
+			}
+		}
+		cfg.validateEndpointDependency(errs, i, endpoint)
+		cfg.validateEndpointDecoration(errs, i, endpoint)
+		cfg.validateEndpointValidation(errs, i, endpoint)
+		cfg.validateEndpointTransforms(errs, i, endpoint)
+		if endpoint.FailuresBeforeStale < 0 {
+			errs.Add(endpointProblemPrefix+"FailuresBeforeStale can not be negative, got %d",
+				i, endpoint.Name, endpoint.FailuresBeforeStale)
+		}
+	}
+}
+
+func (cfg Config) validateEndpointKind(errs *commonGo.ConfigErrors, i int, endpoint EndpointConfig) {
+	switch endpoint.Kind {
+	case "", EndpointKindJSON:
+		if len(endpoint.Attribute) > 0 {
+			errs.Add(endpointProblemPrefix+"Attribute is only supported by the %q kind", i, endpoint.Name, EndpointKindHTML)
+		}
+	case EndpointKindHTML:
+		_, err := cascadia.Compile(endpoint.Value)
+		if err != nil {
+			errs.Add(endpointProblemPrefix+"Value %q is not a valid CSS selector: %v", i, endpoint.Name, endpoint.Value, err)
+		}
+	default:
+		errs.Add(endpointProblemPrefix+"Kind %q is not supported, use %q or %q",
+			i, endpoint.Name, endpoint.Kind, EndpointKindJSON, EndpointKindHTML)
+	}
+}
+
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func (cfg Config) validateEndpointDecoration(errs *commonGo.ConfigErrors, i int, endpoint EndpointConfig) {
+	if strings.ContainsAny(endpoint.UserAgent, "\r\n") {
+		errs.Add(endpointProblemPrefix+"UserAgent can not contain line breaks", i, endpoint.Name)
+	}
+	for name := range endpoint.QueryParams {
+		if len(name) == 0 {
+			errs.Add(endpointProblemPrefix+"QueryParams contains an empty name", i, endpoint.Name)
+		}
+	}
+	for _, name := range sortedKeys(endpoint.Cookies) {
+		cookie := http.Cookie{Name: name, Value: endpoint.Cookies[name]}
+		if cookie.Valid() != nil {
+			errs.Add(endpointProblemPrefix+"Cookies.%s is not a valid cookie", i, endpoint.Name, name)
+		}
+	}
+}
+
+func (cfg Config) validateEndpointDependency(errs *commonGo.ConfigErrors, i int, endpoint EndpointConfig) {
+	dependency := endpoint.DependsOn
+	hasPlaceholder := strings.Contains(endpoint.URL, DependencyPlaceholder)
+	if !dependency.IsEnabled() {
+		if len(dependency.Value) > 0 || hasPlaceholder {
+			errs.Add(endpointProblemPrefix+"DependsOn.URL is empty", i, endpoint.Name)
+		}
+		return
+	}
+
+	if !commonGo.IsHTTPURL(dependency.URL) {
+		errs.Add(endpointProblemPrefix+"DependsOn.URL %q is not a valid http(s) URL", i, endpoint.Name, dependency.URL)
+	}
+	if len(strings.TrimSpace(dependency.Value)) == 0 {
+		errs.Add(endpointProblemPrefix+"DependsOn.Value is empty", i, endpoint.Name)
+	}
+	if !hasPlaceholder {
+		errs.Add(endpointProblemPrefix+"URL must contain the %s placeholder of the DependsOn value",
+			i, endpoint.Name, DependencyPlaceholder)
+	}
+}
+
+func (cfg Config) validateEndpointValidation(errs *commonGo.ConfigErrors, i int, endpoint EndpointConfig) {
+	validation := endpoint.Validation
+	if validation.MinValue != nil && validation.MaxValue != nil && *validation.MinValue > *validation.MaxValue {
+		errs.Add(endpointProblemPrefix+"Validation.MinValue %v is greater than Validation.MaxValue %v",
+			i, endpoint.Name, *validation.MinValue, *validation.MaxValue)
+	}
+	if len(validation.Regex) > 0 {
+		_, err := regexp.Compile(validation.Regex)
+		if err != nil {
+			errs.Add(endpointProblemPrefix+"Validation.Regex %q is not valid: %v", i, endpoint.Name, validation.Regex, err)
+		}
+	}
+}
+
+func (cfg Config) validateEndpointTransforms(errs *commonGo.ConfigErrors, i int, endpoint EndpointConfig) {
+	for j, transform := range endpoint.Transforms {
+		prefix := fmt.Sprintf(endpointProblemPrefix+"Transforms[%d]: ", i, endpoint.Name, j)
+		switch transform.Type {
+		case TransformMultiply, TransformDivide:
+			factor, ok := new(big.Rat).SetString(transform.Value)
+			if !ok {
+				errs.Add("%sValue %q is not a number", prefix, transform.Value)
+			} else if transform.Type == TransformDivide && factor.Sign() == 0 {
+				errs.Add("%sValue can not be 0", prefix)
+			}
+		case TransformRound:
+			if transform.Decimals > maxTransformDecimals {
+				errs.Add("%sDecimals can not exceed %d, got %d", prefix, maxTransformDecimals, transform.Decimals)
+			}
+		case TransformMap:
+			if len(transform.Mapping) == 0 {
+				errs.Add("%sMapping is empty", prefix)
+			}
+			for key, value := range transform.Mapping {
+				_, ok := new(big.Rat).SetString(value)
+				if !ok {
+					errs.Add("%sMapping of %q is not a number: %q", prefix, key, value)
+				}
+			}
+		case TransformTrimSuffix:
+			if len(transform.Value) == 0 {
+				errs.Add("%sValue is empty", prefix)
+			}
+		default:
+			errs.Add("%sType %q is not supported, use one of %s, %s, %s, %s or %s", prefix, transform.Type,
+				TransformMultiply, TransformDivide, TransformRound, TransformMap, TransformTrimSuffix)
+		}
+	}
+}
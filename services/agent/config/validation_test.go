@@ -0,0 +1,492 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/commonGo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createValidConfig() Config {
+	return Config{
+		Name:                   "VM1",
+		QueryIntervalInSeconds: 60,
+		ReportEndpoint:         "https://aaa.bbb.com/report",
+		ReportTimeoutInSeconds: 10,
+		Endpoints: []EndpointConfig{
+			{
+				Name:           "VM1.Node1.nonce",
+				URL:            "http://127.0.0.1:8080/node/status",
+				Value:          "data.metrics.erd_nonce",
+				Type:           "uint64",
+				NumAggregation: 100,
+			},
+		},
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("example config should be valid", func(t *testing.T) {
+		t.Parallel()
+
+		cfg, err := LoadConfig("../config.toml.example")
+		require.Nil(t, err)
+		assert.Nil(t, cfg.Validate())
+	})
+	t.Run("valid config should not error", func(t *testing.T) {
+		t.Parallel()
+
+		assert.Nil(t, createValidConfig().Validate())
+	})
+	t.Run("should report all the problems", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := createValidConfig()
+		cfg.Name = " "
+		cfg.QueryIntervalInSeconds = 0
+		cfg.ReportEndpoint = "/report"
+		cfg.ReportTimeoutInSeconds = 1000
+		cfg.PayloadTrace = PayloadTraceConfig{Enabled: true}
+		cfg.HealthServer = HealthServerConfig{Enabled: true, ListenAddress: "localhost"}
+		cfg.Endpoints = append(cfg.Endpoints,
+			EndpointConfig{
+				Name:                "VM1.Node1.nonce",
+				URL:                 "127.0.0.1:8080",
+				Type:                "int",
+				NumAggregation:      0,
+				FailuresBeforeStale: -1,
+				TimeoutInSeconds:    1000,
+				Retries:             11,
+			},
+			EndpointConfig{
+				URL:            "http://127.0.0.1:8080/node/status",
+				Value:          "data.metrics.erd_nonce",
+				Type:           "uint64",
+				NumAggregation: 1,
+			},
+		)
+
+		err := cfg.Validate()
+		require.NotNil(t, err)
+		assert.True(t, errors.Is(err, commonGo.ErrInvalidConfig))
+
+		expectedProblems := []string{
+			"Name is empty",
+			"QueryIntervalInSeconds must be between 1 and 86400, got 0",
+			`ReportEndpoint "/report" is not a valid http(s) URL`,
+			"ReportTimeoutInSeconds must be between 1 and 300, got 1000",
+			"PayloadTrace.Directory is empty",
+			"PayloadTrace.MaxFileSizeInMB must be at least 1",
+			"PayloadTrace.MaxNumFiles must be at least 1",
+			`HealthServer.ListenAddress "localhost" is not a valid host:port address`,
+			`Endpoints[1]: Name "VM1.Node1.nonce" is already used by Endpoints[0]`,
+			`Endpoints[1] (VM1.Node1.nonce): URL "127.0.0.1:8080" is not a valid http(s) URL`,
+			"Endpoints[1] (VM1.Node1.nonce): Value is empty",
+			`Endpoints[1] (VM1.Node1.nonce): Type "int" is not supported`,
+			"Endpoints[1] (VM1.Node1.nonce): NumAggregation must be at least 1, got 0",
+			"Endpoints[1] (VM1.Node1.nonce): FailuresBeforeStale can not be negative, got -1",
+			"Endpoints[1] (VM1.Node1.nonce): TimeoutInSeconds can not exceed QueryIntervalInSeconds, got 1000",
+			"Endpoints[1] (VM1.Node1.nonce): Retries can not exceed 10, got 11",
+			"Endpoints[2]: Name is empty",
+		}
+		for _, problem := range expectedProblems {
+			assert.Contains(t, err.Error(), problem)
+		}
+		assert.Contains(t, err.Error(), "17 problem(s) found")
+	})
+	t.Run("should validate the failover endpoints", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := createValidConfig()
+		cfg.Failover = FailoverConfig{
+			Enabled:   true,
+			Endpoints: []string{"https://ccc.ddd.com/report"},
+		}
+		assert.Nil(t, cfg.Validate())
+
+		cfg.Failover = FailoverConfig{
+			Enabled:               true,
+			Endpoints:             []string{"ccc.ddd.com", cfg.ReportEndpoint},
+			PrimaryHealthCheckURL: "/healthz",
+		}
+		err := cfg.Validate()
+		require.NotNil(t, err)
+		assert.Contains(t, err.Error(), `Failover.Endpoints[0] "ccc.ddd.com" is not a valid http(s) URL`)
+		assert.Contains(t, err.Error(), `Failover.Endpoints[1] "https://aaa.bbb.com/report" is the same as ReportEndpoint`)
+		assert.Contains(t, err.Error(), `Failover.PrimaryHealthCheckURL "/healthz" is not a valid http(s) URL`)
+		assert.Contains(t, err.Error(), "3 problem(s) found")
+
+		cfg.Failover = FailoverConfig{Enabled: true}
+		err = cfg.Validate()
+		require.NotNil(t, err)
+		assert.Contains(t, err.Error(), "Failover.Endpoints is empty")
+	})
+	t.Run("should validate the IPMI sensors", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := createValidConfig()
+		cfg.IPMI = IPMIConfig{Enabled: true, NumAggregation: 100}
+		assert.Nil(t, cfg.Validate())
+		cfg.IPMI = IPMIConfig{Enabled: true, Host: "10.0.0.10", Username: "monitor", Password: "secret", NumAggregation: 100}
+		assert.Nil(t, cfg.Validate())
+
+		cfg.IPMI = IPMIConfig{Enabled: true, Host: "10.0.0.10", TimeoutInSeconds: 61}
+		err := cfg.Validate()
+		require.NotNil(t, err)
+		assert.Contains(t, err.Error(), "IPMI.NumAggregation must be at least 1, got 0")
+		assert.Contains(t, err.Error(), "IPMI.Username is required with IPMI.Host")
+		assert.Contains(t, err.Error(), "IPMI.TimeoutInSeconds can not be greater than QueryIntervalInSeconds, got 61")
+		assert.Contains(t, err.Error(), "3 problem(s) found")
+
+		cfg.IPMI = IPMIConfig{Enabled: true, Password: "secret", NumAggregation: 100}
+		err = cfg.Validate()
+		require.NotNil(t, err)
+		assert.Contains(t, err.Error(), "IPMI.Username and IPMI.Password are only used with IPMI.Host")
+	})
+	t.Run("should validate the Docker containers", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := createValidConfig()
+		cfg.Docker = DockerConfig{Enabled: true, Containers: []string{"observer-0", "validator"}, NumAggregation: 100}
+		assert.Nil(t, cfg.Validate())
+
+		cfg.Docker = DockerConfig{Enabled: true, Containers: []string{"validator", " ", "validator"}, TimeoutInSeconds: 61}
+		err := cfg.Validate()
+		require.NotNil(t, err)
+		assert.Contains(t, err.Error(), "Docker.Containers[1] is empty")
+		assert.Contains(t, err.Error(), `Docker.Containers[2] "validator" is already listed at Docker.Containers[0]`)
+		assert.Contains(t, err.Error(), "Docker.NumAggregation must be at least 1, got 0")
+		assert.Contains(t, err.Error(), "Docker.TimeoutInSeconds can not be greater than QueryIntervalInSeconds, got 61")
+		assert.Contains(t, err.Error(), "4 problem(s) found")
+
+		cfg.Docker = DockerConfig{Enabled: true, NumAggregation: 100}
+		err = cfg.Validate()
+		require.NotNil(t, err)
+		assert.Contains(t, err.Error(), "Docker.Containers is empty")
+	})
+	t.Run("should validate the self update", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := createValidConfig()
+		cfg.SelfUpdate = SelfUpdateConfig{
+			Enabled:     true,
+			ManifestURL: "https://releases.example.com/agent/manifest.json",
+			PublicKey:   "d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a",
+		}
+		assert.Nil(t, cfg.Validate())
+
+		cfg.SelfUpdate = SelfUpdateConfig{
+			Enabled:     true,
+			ManifestURL: "releases.example.com",
+			PublicKey:   "d75a98",
+		}
+		err := cfg.Validate()
+		require.NotNil(t, err)
+		assert.Contains(t, err.Error(), `SelfUpdate.ManifestURL "releases.example.com" is not a valid http(s) URL`)
+		assert.Contains(t, err.Error(), "SelfUpdate.PublicKey is not a hex encoded ed25519 public key")
+		assert.Contains(t, err.Error(), "2 problem(s) found")
+	})
+	t.Run("should validate the endpoint validations", func(t *testing.T) {
+		t.Parallel()
+
+		minValue := 10.0
+		maxValue := 20.0
+		cfg := createValidConfig()
+		cfg.Endpoints[0].Validation = ValidationConfig{MinValue: &minValue, MaxValue: &maxValue, Regex: "^[0-9]+$"}
+		assert.Nil(t, cfg.Validate())
+
+		cfg.Endpoints[0].Validation = ValidationConfig{MinValue: &maxValue, MaxValue: &minValue, Regex: "("}
+		validationEndpoint := cfg.Endpoints[0]
+		validationEndpoint.Name = cfg.Endpoints[0].Name + ValidationSuffix
+		validationEndpoint.Validation = ValidationConfig{}
+		cfg.Endpoints = append(cfg.Endpoints, validationEndpoint)
+		err := cfg.Validate()
+		require.NotNil(t, err)
+		assert.Contains(t, err.Error(), "Validation.MinValue 20 is greater than Validation.MaxValue 10")
+		assert.Contains(t, err.Error(), `Validation.Regex "(" is not valid`)
+		assert.Contains(t, err.Error(), fmt.Sprintf("Name %q is already used by the validation metric of Endpoints[0]",
+			validationEndpoint.Name))
+		assert.Contains(t, err.Error(), "3 problem(s) found")
+	})
+	t.Run("should not allow the names of the timing metrics", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := createValidConfig()
+		cfg.Endpoints[0].TraceTimings = true
+		assert.Nil(t, cfg.Validate())
+
+		timingEndpoint := cfg.Endpoints[0]
+		timingEndpoint.Name = cfg.Endpoints[0].Name + TimingTTFBSuffix
+		timingEndpoint.TraceTimings = false
+		cfg.Endpoints = append(cfg.Endpoints, timingEndpoint)
+		err := cfg.Validate()
+		require.NotNil(t, err)
+		assert.Contains(t, err.Error(), fmt.Sprintf("Name %q is already used by the timing metric of Endpoints[0]",
+			timingEndpoint.Name))
+		assert.Contains(t, err.Error(), "1 problem(s) found")
+	})
+	t.Run("should validate the endpoint transforms", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := createValidConfig()
+		cfg.Endpoints[0].Transforms = []TransformConfig{
+			{Type: TransformTrimSuffix, Value: " EGLD"},
+			{Type: TransformMap, Mapping: map[string]string{"active": "1"}},
+			{Type: TransformMultiply, Value: "2.5"},
+			{Type: TransformDivide, Value: "1000000000000000000"},
+			{Type: TransformRound, Decimals: 4},
+		}
+		assert.Nil(t, cfg.Validate())
+
+		cfg.Endpoints[0].Transforms = []TransformConfig{
+			{Type: TransformTrimSuffix},
+			{Type: TransformMap, Mapping: map[string]string{"active": "yes"}},
+			{Type: TransformMultiply, Value: "x"},
+			{Type: TransformDivide, Value: "0"},
+			{Type: TransformRound, Decimals: 19},
+			{Type: TransformMap},
+			{Type: "sqrt"},
+		}
+		err := cfg.Validate()
+		require.NotNil(t, err)
+		expectedProblems := []string{
+			"Endpoints[0] (VM1.Node1.nonce): Transforms[0]: Value is empty",
+			`Endpoints[0] (VM1.Node1.nonce): Transforms[1]: Mapping of "active" is not a number: "yes"`,
+			`Endpoints[0] (VM1.Node1.nonce): Transforms[2]: Value "x" is not a number`,
+			"Endpoints[0] (VM1.Node1.nonce): Transforms[3]: Value can not be 0",
+			"Endpoints[0] (VM1.Node1.nonce): Transforms[4]: Decimals can not exceed 18, got 19",
+			"Endpoints[0] (VM1.Node1.nonce): Transforms[5]: Mapping is empty",
+			`Endpoints[0] (VM1.Node1.nonce): Transforms[6]: Type "sqrt" is not supported`,
+		}
+		for _, problem := range expectedProblems {
+			assert.Contains(t, err.Error(), problem)
+		}
+		assert.Contains(t, err.Error(), "7 problem(s) found")
+	})
+	t.Run("should validate the endpoint kind", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := createValidConfig()
+		cfg.Endpoints[0].Kind = EndpointKindHTML
+		cfg.Endpoints[0].Value = "table.stats td.nonce"
+		cfg.Endpoints[0].Attribute = "data-value"
+		assert.Nil(t, cfg.Validate())
+
+		cfg.Endpoints[0].Value = "td["
+		jsonEndpoint := createValidConfig().Endpoints[0]
+		jsonEndpoint.Name = "VM1.Node1.json"
+		jsonEndpoint.Attribute = "data-value"
+		xmlEndpoint := createValidConfig().Endpoints[0]
+		xmlEndpoint.Name = "VM1.Node1.xml"
+		xmlEndpoint.Kind = "xml"
+		cfg.Endpoints = append(cfg.Endpoints, jsonEndpoint, xmlEndpoint)
+		err := cfg.Validate()
+		require.NotNil(t, err)
+		assert.Contains(t, err.Error(), `Endpoints[0] (VM1.Node1.nonce): Value "td[" is not a valid CSS selector`)
+		assert.Contains(t, err.Error(), `Attribute is only supported by the "html" kind`)
+		assert.Contains(t, err.Error(), `Kind "xml" is not supported, use "json" or "html"`)
+		assert.Contains(t, err.Error(), "3 problem(s) found")
+	})
+	t.Run("should validate the endpoint dependency", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := createValidConfig()
+		cfg.Endpoints[0].URL = "http://127.0.0.1:8080/validator/statistics?epoch={value}"
+		cfg.Endpoints[0].DependsOn = DependencyConfig{
+			URL:   "http://127.0.0.1:8080/network/status",
+			Value: "data.status.erd_epoch_number",
+		}
+		assert.Nil(t, cfg.Validate())
+
+		cfg.Endpoints[0].URL = "http://127.0.0.1:8080/validator/statistics"
+		cfg.Endpoints[0].DependsOn = DependencyConfig{URL: "/network/status"}
+		err := cfg.Validate()
+		require.NotNil(t, err)
+		assert.Contains(t, err.Error(), `DependsOn.URL "/network/status" is not a valid http(s) URL`)
+		assert.Contains(t, err.Error(), "DependsOn.Value is empty")
+		assert.Contains(t, err.Error(), "URL must contain the {value} placeholder of the DependsOn value")
+		assert.Contains(t, err.Error(), "3 problem(s) found")
+
+		cfg.Endpoints[0].URL = "http://127.0.0.1:8080/validator/statistics?epoch={value}"
+		cfg.Endpoints[0].DependsOn = DependencyConfig{}
+		err = cfg.Validate()
+		require.NotNil(t, err)
+		assert.Contains(t, err.Error(), "Endpoints[0] (VM1.Node1.nonce): DependsOn.URL is empty")
+	})
+	t.Run("should validate the poll spread", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := createValidConfig()
+		cfg.QueryIntervalInSeconds = 60
+		cfg.PollSpreadInSeconds = 50
+		cfg.PollJitterInMilliseconds = 9999
+		assert.Nil(t, cfg.Validate())
+
+		cfg.PollJitterInMilliseconds = 10000
+		err := cfg.Validate()
+		require.NotNil(t, err)
+		assert.Contains(t, err.Error(),
+			"PollSpreadInSeconds plus PollJitterInMilliseconds must be less than QueryIntervalInSeconds, got 1m0s")
+	})
+	t.Run("should validate the log shipping", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := createValidConfig()
+		cfg.LogShipping = LogShippingConfig{
+			Enabled: true,
+		}
+		assert.Nil(t, cfg.Validate())
+
+		cfg.LogShipping.Endpoint = "https://aaa.bbb.com/api/agents/VM1/logs"
+		assert.Nil(t, cfg.Validate())
+
+		cfg.LogShipping.Endpoint = "aaa.bbb.com"
+		err := cfg.Validate()
+		require.NotNil(t, err)
+		assert.Contains(t, err.Error(), `LogShipping.Endpoint "aaa.bbb.com" is not a valid http(s) URL`)
+
+		cfg.ReportTransport = ReportTransportMQTT
+		cfg.MQTT = MQTTConfig{
+			BrokerURL: "tcp://127.0.0.1:1883",
+			Topic:     "monitoring/reports/VM1",
+		}
+		cfg.LogShipping.Endpoint = ""
+		err = cfg.Validate()
+		require.NotNil(t, err)
+		assert.Contains(t, err.Error(), `LogShipping.Endpoint is required with the "mqtt" report transport`)
+		assert.Contains(t, err.Error(), "1 problem(s) found")
+	})
+	t.Run("should validate the report buffer", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := createValidConfig()
+		cfg.ReportBuffer = ReportBufferConfig{
+			Enabled:       true,
+			MaxMemoryInMB: 1024,
+		}
+		assert.Nil(t, cfg.Validate())
+
+		cfg.ReportBuffer.MaxMemoryInMB = 1025
+		err := cfg.Validate()
+		require.NotNil(t, err)
+		assert.Contains(t, err.Error(), "ReportBuffer.MaxMemoryInMB must be at most 1024, got 1025")
+		assert.Contains(t, err.Error(), "1 problem(s) found")
+	})
+	t.Run("should validate the fan-out endpoints", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := createValidConfig()
+		cfg.FanOut = FanOutConfig{
+			Enabled:   true,
+			Endpoints: []string{"https://ccc.ddd.com/report"},
+		}
+		assert.Nil(t, cfg.Validate())
+
+		cfg.FanOut.Endpoints = []string{"ccc.ddd.com", cfg.ReportEndpoint}
+		cfg.Failover = FailoverConfig{
+			Enabled:   true,
+			Endpoints: []string{"https://eee.fff.com/report"},
+		}
+		err := cfg.Validate()
+		require.NotNil(t, err)
+		assert.Contains(t, err.Error(), "FanOut and Failover can not be enabled at the same time")
+		assert.Contains(t, err.Error(), `FanOut.Endpoints[0] "ccc.ddd.com" is not a valid http(s) URL`)
+		assert.Contains(t, err.Error(), `FanOut.Endpoints[1] "https://aaa.bbb.com/report" is the same as ReportEndpoint`)
+		assert.Contains(t, err.Error(), "3 problem(s) found")
+	})
+	t.Run("should validate the network settings", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := createValidConfig()
+		cfg.Network = NetworkConfig{ProxyURL: "socks5h://bastion:1080", IPFamily: "ipv6"}
+		cfg.Endpoints[0].ProxyURL = "http://10.0.0.1:3128"
+		assert.Nil(t, cfg.Validate())
+
+		cfg.Network = NetworkConfig{ProxyURL: "bastion:1080", IPFamily: "IPv6"}
+		cfg.Endpoints[0].ProxyURL = "ftp://10.0.0.1"
+		err := cfg.Validate()
+		require.NotNil(t, err)
+		assert.Contains(t, err.Error(), `Network.ProxyURL "bastion:1080" is not a valid http, https, socks5 or socks5h URL`)
+		assert.Contains(t, err.Error(), `Network.IPFamily "IPv6" is not supported, use "ipv4" or "ipv6"`)
+		assert.Contains(t, err.Error(), `ProxyURL "ftp://10.0.0.1" is not a valid http, https, socks5 or socks5h URL`)
+		assert.Contains(t, err.Error(), "3 problem(s) found")
+	})
+	t.Run("should validate the request decoration", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := createValidConfig()
+		cfg.Endpoints[0].UserAgent = "Mozilla/5.0 (monitoring)"
+		cfg.Endpoints[0].QueryParams = map[string]string{"shard": "1"}
+		cfg.Endpoints[0].Cookies = map[string]string{"session": "abc"}
+		assert.Nil(t, cfg.Validate())
+
+		cfg.Endpoints[0].UserAgent = "agent\r\nX-Injected: 1"
+		cfg.Endpoints[0].QueryParams = map[string]string{"": "1"}
+		cfg.Endpoints[0].Cookies = map[string]string{"bad name": "abc"}
+		err := cfg.Validate()
+		require.NotNil(t, err)
+		assert.Contains(t, err.Error(), "UserAgent can not contain line breaks")
+		assert.Contains(t, err.Error(), "QueryParams contains an empty name")
+		assert.Contains(t, err.Error(), "Cookies.bad name is not a valid cookie")
+		assert.Contains(t, err.Error(), "3 problem(s) found")
+	})
+	t.Run("should validate the MQTT transport", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := createValidConfig()
+		cfg.ReportEndpoint = ""
+		cfg.ReportTransport = ReportTransportMQTT
+		cfg.MQTT = MQTTConfig{
+			BrokerURL: "tcp://127.0.0.1:1883",
+			Topic:     "monitoring/reports/VM1",
+			QoS:       1,
+		}
+		assert.Nil(t, cfg.Validate())
+
+		cfg.MQTT = MQTTConfig{
+			BrokerURL: "http://127.0.0.1:1883",
+			Topic:     "monitoring/reports/+",
+			QoS:       2,
+		}
+		err := cfg.Validate()
+		require.NotNil(t, err)
+		assert.Contains(t, err.Error(), `MQTT.BrokerURL "http://127.0.0.1:1883" is not a valid`)
+		assert.Contains(t, err.Error(), `MQTT.Topic "monitoring/reports/+" must not be empty and can not contain wildcards`)
+		assert.Contains(t, err.Error(), "MQTT.QoS must be 0 or 1, got 2")
+		assert.Contains(t, err.Error(), "3 problem(s) found")
+
+		cfg.ReportTransport = "grpc"
+		err = cfg.Validate()
+		require.NotNil(t, err)
+		assert.Contains(t, err.Error(), `ReportTransport "grpc" is not supported, use "http" or "mqtt"`)
+	})
+	t.Run("should validate the report encoding", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := createValidConfig()
+		cfg.ReportEncoding = ReportEncodingMsgPack
+		assert.Nil(t, cfg.Validate())
+
+		cfg.ReportEncoding = "protobuf"
+		err := cfg.Validate()
+		require.NotNil(t, err)
+		assert.Contains(t, err.Error(), `ReportEncoding "protobuf" is not supported, use "json" or "msgpack"`)
+
+		cfg.ReportEndpoint = ""
+		cfg.ReportTransport = ReportTransportMQTT
+		cfg.ReportEncoding = ReportEncodingMsgPack
+		cfg.MQTT = MQTTConfig{
+			BrokerURL: "tcp://127.0.0.1:1883",
+			Topic:     "monitoring/reports/VM1",
+			QoS:       1,
+		}
+		err = cfg.Validate()
+		require.NotNil(t, err)
+		assert.Contains(t, err.Error(), `ReportEncoding "msgpack" is not supported by the "mqtt" transport`)
+	})
+}
@@ -3,8 +3,12 @@ package engine
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strconv"
 	"time"
 
+	"github.com/iulianpascalau/api-monitoring/commonGo"
+	"github.com/iulianpascalau/api-monitoring/services/agent/common"
 	"github.com/iulianpascalau/api-monitoring/services/agent/config"
 	"github.com/multiversx/mx-chain-core-go/core/check"
 	logger "github.com/multiversx/mx-chain-logger-go"
@@ -12,26 +16,62 @@ import (
 
 var log = logger.GetOrCreate("engine")
 
+const minPollTimeout = 30 * time.Second
+
+// endpointState holds the last good result of an endpoint and the number of consecutive failed polls since
+type endpointState struct {
+	lastResult  common.MetricResult
+	numFailures int
+}
+
 // agentEngine orchestrates polling and reporting at configured intervals
 type agentEngine struct {
 	config   config.Config
 	poller   Poller
 	reporter Reporter
+	stats    StatsRecorder
+	clock    Clock
+	states   map[string]*endpointState
+	// validators are indexed by endpoint name
+	validators map[string]*valueValidator
 }
 
 // NewAgentEngine creates a new engine instance
-func NewAgentEngine(cfg config.Config, p Poller, r Reporter) (*agentEngine, error) {
+func NewAgentEngine(cfg config.Config, p Poller, r Reporter, s StatsRecorder, clk Clock) (*agentEngine, error) {
 	if check.IfNil(p) {
 		return nil, errors.New("nil poller")
 	}
 	if check.IfNil(r) {
 		return nil, errors.New("nil reporter")
 	}
+	if check.IfNil(s) {
+		return nil, errors.New("nil stats recorder")
+	}
+	if check.IfNil(clk) {
+		return nil, errors.New("nil clock")
+	}
+
+	validators := make(map[string]*valueValidator)
+	for _, endpoint := range cfg.Endpoints {
+		if !endpoint.Validation.IsEnabled() {
+			continue
+		}
+
+		validator, err := newValueValidator(endpoint.Validation)
+		if err != nil {
+			return nil, fmt.Errorf("invalid validation of endpoint %s: %w", endpoint.Name, err)
+		}
+		validators[endpoint.Name] = validator
+	}
 
 	return &agentEngine{
-		config:   cfg,
-		poller:   p,
-		reporter: r,
+		config:     cfg,
+		poller:     p,
+		reporter:   r,
+		stats:      s,
+		clock:      clk,
+		states:     make(map[string]*endpointState),
+		validators: validators,
 	}, nil
 }
 
@@ -40,20 +80,104 @@ func (e *agentEngine) Process(ctx context.Context) {
 	log.Debug("waking up to poll endpoints", "count", len(e.config.Endpoints))
 
 	// 1. Poll all endpoints concurrently
-	pollCtx, cancelPoll := context.WithTimeout(ctx, 30*time.Second) // Prevent indefinite hanging
+	// Prevent indefinite hanging, the staggered polls get the same time after their delay
+	pollCtx, cancelPoll := context.WithTimeout(ctx, e.pollTimeout())
 	defer cancelPoll()
+	pollStart := e.clock.Now()
 	results := e.poller.PollAll(pollCtx, e.config.Endpoints)
+	e.stats.RecordPoll(e.config.Endpoints, results, e.clock.Now().Sub(pollStart))
 
 	log.Debug("finished polling", "successful_results", len(results))
+	validationResults := e.validateResults(results)
+	results = e.addStaleResults(results)
+	for name, result := range validationResults {
+		results[name] = result
+	}
 
 	// 2. Report them to aggregation backend
 	reportCtx, cancelReport := context.WithTimeout(ctx, 10*time.Second)
 	defer cancelReport()
+	// all the attempts of sending this report (retries, replicas) carry the same cycle ID, so the server drops the
+	// duplicates
+	reportCtx = common.WithCycleID(reportCtx, commonGo.NewRequestID())
 
 	err := e.reporter.Report(reportCtx, results)
+	e.stats.RecordReport(err)
 	if err != nil {
-		log.Warn("failed to report metrics, they will be discarded", "error", err)
+		log.Warn("failed to report metrics", "error", err)
+	}
+}
+
+// pollTimeout bounds a polling round: at least 30 seconds, or the longest endpoint timeout, after the longest delay
+func (e *agentEngine) pollTimeout() time.Duration {
+	timeout := minPollTimeout
+	if e.config.PollTimeout() > timeout {
+		timeout = e.config.PollTimeout()
+	}
+
+	return timeout + e.config.PollDelay()
+}
+
+// addStaleResults re-sends, flagged as stale, the last good value of the endpoints whose poll failed fewer than
+// FailuresBeforeStale consecutive times, so a transient failure does not remove the metric from the report
+func (e *agentEngine) addStaleResults(results map[string]common.MetricResult) map[string]common.MetricResult {
+	allResults := make(map[string]common.MetricResult, len(results))
+	for name, result := range results {
+		allResults[name] = result
+	}
+
+	for _, endpoint := range e.config.Endpoints {
+		state, found := e.states[endpoint.Name]
+		result, polled := results[endpoint.Name]
+		if polled {
+			e.states[endpoint.Name] = &endpointState{lastResult: result}
+			continue
+		}
+		if !found {
+			continue
+		}
+
+		state.numFailures++
+		if state.numFailures > endpoint.FailuresBeforeStale {
+			delete(e.states, endpoint.Name)
+			continue
+		}
+
+		staleResult := state.lastResult
+		staleResult.Stale = true
+		allResults[endpoint.Name] = staleResult
+		log.Debug("re-sending the last good value", "metric", endpoint.Name, "num failures", state.numFailures)
 	}
+
+	return allResults
+}
+
+// validateResults returns the derived <name>.ok bool metrics of the endpoints declaring a validation. A failed poll
+// fails the validation.
+func (e *agentEngine) validateResults(results map[string]common.MetricResult) map[string]common.MetricResult {
+	validationResults := make(map[string]common.MetricResult, len(e.validators))
+	for _, endpoint := range e.config.Endpoints {
+		validator, found := e.validators[endpoint.Name]
+		if !found {
+			continue
+		}
+
+		result, polled := results[endpoint.Name]
+		isValid := polled && validator.isValid(result.Value)
+		name := endpoint.Name + config.ValidationSuffix
+		validationResults[name] = common.MetricResult{
+			Config: config.EndpointConfig{
+				Name:           name,
+				Type:           "bool",
+				NumAggregation: endpoint.NumAggregation,
+				Tags:           endpoint.Tags,
+			},
+			Value:    strconv.FormatBool(isValid),
+			PolledAt: result.PolledAt,
+		}
+	}
+
+	return validationResults
 }
 
 // IsInterfaceNil returns true if the value under the interface is nil
@@ -1,18 +1,25 @@
 package engine
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
 
+	"github.com/iulianpascalau/api-monitoring/commonGo"
+	"github.com/iulianpascalau/api-monitoring/commonGo/clock"
+	"github.com/iulianpascalau/api-monitoring/services/agent/common"
 	"github.com/iulianpascalau/api-monitoring/services/agent/config"
 	"github.com/iulianpascalau/api-monitoring/services/agent/testsCommon"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewAgentEngine(t *testing.T) {
 	t.Parallel()
 
 	t.Run("nil poller should error", func(t *testing.T) {
-		engine, err := NewAgentEngine(config.Config{}, nil, &testsCommon.ReporterStub{})
+		engine, err := NewAgentEngine(config.Config{}, nil, &testsCommon.ReporterStub{}, &testsCommon.StatsRecorderStub{}, clock.NewSystemClock())
 
 		assert.Nil(t, engine)
 		assert.True(t, engine.IsInterfaceNil())
@@ -20,18 +27,220 @@ func TestNewAgentEngine(t *testing.T) {
 		assert.Contains(t, err.Error(), "nil poller")
 	})
 	t.Run("nil reporter should error", func(t *testing.T) {
-		engine, err := NewAgentEngine(config.Config{}, &testsCommon.PollerStub{}, nil)
+		engine, err := NewAgentEngine(config.Config{}, &testsCommon.PollerStub{}, nil, &testsCommon.StatsRecorderStub{}, clock.NewSystemClock())
 
 		assert.Nil(t, engine)
 		assert.True(t, engine.IsInterfaceNil())
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "nil reporter")
 	})
+	t.Run("nil stats recorder should error", func(t *testing.T) {
+		engine, err := NewAgentEngine(config.Config{}, &testsCommon.PollerStub{}, &testsCommon.ReporterStub{}, nil, clock.NewSystemClock())
+
+		assert.Nil(t, engine)
+		assert.True(t, engine.IsInterfaceNil())
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "nil stats recorder")
+	})
+	t.Run("nil clock should error", func(t *testing.T) {
+		engine, err := NewAgentEngine(config.Config{}, &testsCommon.PollerStub{}, &testsCommon.ReporterStub{}, &testsCommon.StatsRecorderStub{}, nil)
+
+		assert.Nil(t, engine)
+		assert.True(t, engine.IsInterfaceNil())
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "nil clock")
+	})
 	t.Run("should work", func(t *testing.T) {
-		engine, err := NewAgentEngine(config.Config{}, &testsCommon.PollerStub{}, &testsCommon.ReporterStub{})
+		engine, err := NewAgentEngine(config.Config{}, &testsCommon.PollerStub{}, &testsCommon.ReporterStub{}, &testsCommon.StatsRecorderStub{}, clock.NewSystemClock())
 
 		assert.NotNil(t, engine)
 		assert.False(t, engine.IsInterfaceNil())
 		assert.Nil(t, err)
 	})
 }
+
+func TestAgentEngine_Process(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.Config{
+		Endpoints: []config.EndpointConfig{{Name: "VM1.Node1.nonce"}},
+	}
+	results := map[string]common.MetricResult{
+		"VM1.Node1.nonce": {Value: "10"},
+	}
+	expectedErr := errors.New("expected error")
+
+	var recordedResults map[string]common.MetricResult
+	var recordedDuration time.Duration
+	var recordedErr error
+	statsRecorder := &testsCommon.StatsRecorderStub{
+		RecordPollHandler: func(endpoints []config.EndpointConfig, res map[string]common.MetricResult, duration time.Duration) {
+			assert.Equal(t, cfg.Endpoints, endpoints)
+			recordedResults = res
+			recordedDuration = duration
+		},
+		RecordReportHandler: func(err error) {
+			recordedErr = err
+		},
+	}
+	clk := clock.NewManualClock(time.Unix(1700000000, 0))
+	poller := &testsCommon.PollerStub{
+		PollAllHandler: func(ctx context.Context, endpoints []config.EndpointConfig) map[string]common.MetricResult {
+			clk.Advance(1500 * time.Millisecond)
+			return results
+		},
+	}
+	reporter := &testsCommon.ReporterStub{
+		ReportHandler: func(ctx context.Context, res map[string]common.MetricResult) error {
+			return expectedErr
+		},
+	}
+
+	engine, _ := NewAgentEngine(cfg, poller, reporter, statsRecorder, clk)
+	engine.Process(context.Background())
+
+	assert.Equal(t, results, recordedResults)
+	assert.Equal(t, 1500*time.Millisecond, recordedDuration)
+	assert.Equal(t, expectedErr, recordedErr)
+}
+
+func TestAgentEngine_ProcessSetsTheCycleID(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.Config{
+		Endpoints: []config.EndpointConfig{{Name: "VM1.Node1.nonce"}},
+	}
+	cycleIDs := make([]string, 0)
+	reporter := &testsCommon.ReporterStub{
+		ReportHandler: func(ctx context.Context, res map[string]common.MetricResult) error {
+			cycleIDs = append(cycleIDs, common.CycleIDFromContext(ctx))
+			return nil
+		},
+	}
+
+	engine, _ := NewAgentEngine(cfg, &testsCommon.PollerStub{}, reporter, &testsCommon.StatsRecorderStub{}, clock.NewSystemClock())
+	engine.Process(context.Background())
+	engine.Process(context.Background())
+
+	require.Len(t, cycleIDs, 2)
+	assert.True(t, commonGo.IsValidRequestID(cycleIDs[0]))
+	assert.NotEqual(t, cycleIDs[0], cycleIDs[1])
+}
+
+func TestAgentEngine_ProcessResendsTheLastGoodValues(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.Config{
+		Endpoints: []config.EndpointConfig{
+			{Name: "VM1.Node1.nonce", FailuresBeforeStale: 2},
+			{Name: "VM1.Node2.nonce"},
+		},
+	}
+	polled := make(map[string]common.MetricResult)
+	poller := &testsCommon.PollerStub{
+		PollAllHandler: func(ctx context.Context, endpoints []config.EndpointConfig) map[string]common.MetricResult {
+			return polled
+		},
+	}
+	var reported map[string]common.MetricResult
+	reporter := &testsCommon.ReporterStub{
+		ReportHandler: func(ctx context.Context, res map[string]common.MetricResult) error {
+			reported = res
+			return nil
+		},
+	}
+	var recordedResults map[string]common.MetricResult
+	statsRecorder := &testsCommon.StatsRecorderStub{
+		RecordPollHandler: func(endpoints []config.EndpointConfig, res map[string]common.MetricResult, duration time.Duration) {
+			recordedResults = res
+		},
+	}
+	engine, _ := NewAgentEngine(cfg, poller, reporter, statsRecorder, clock.NewSystemClock())
+
+	polled = map[string]common.MetricResult{
+		"VM1.Node1.nonce": {Config: cfg.Endpoints[0], Value: "10"},
+		"VM1.Node2.nonce": {Config: cfg.Endpoints[1], Value: "20"},
+	}
+	engine.Process(context.Background())
+	assert.Equal(t, polled, reported)
+
+	// the first 2 consecutive failures re-send the last good value, the endpoint without a threshold is omitted
+	polled = map[string]common.MetricResult{}
+	for i := 0; i < 2; i++ {
+		engine.Process(context.Background())
+		assert.Equal(t, map[string]common.MetricResult{
+			"VM1.Node1.nonce": {Config: cfg.Endpoints[0], Value: "10", Stale: true},
+		}, reported)
+		assert.Empty(t, recordedResults)
+	}
+
+	engine.Process(context.Background())
+	assert.Empty(t, reported)
+
+	// a successful poll resets the failures count
+	polled = map[string]common.MetricResult{
+		"VM1.Node1.nonce": {Config: cfg.Endpoints[0], Value: "11"},
+	}
+	engine.Process(context.Background())
+	assert.Equal(t, polled, reported)
+
+	polled = map[string]common.MetricResult{}
+	engine.Process(context.Background())
+	assert.Equal(t, map[string]common.MetricResult{
+		"VM1.Node1.nonce": {Config: cfg.Endpoints[0], Value: "11", Stale: true},
+	}, reported)
+}
+
+func TestAgentEngine_ProcessReportsTheValidations(t *testing.T) {
+	t.Parallel()
+
+	minValue := 10.0
+	cfg := config.Config{
+		Endpoints: []config.EndpointConfig{
+			{Name: "VM1.Node1.nonce", NumAggregation: 5, Validation: config.ValidationConfig{MinValue: &minValue}},
+			{Name: "VM1.Node2.nonce", Validation: config.ValidationConfig{MinValue: &minValue}},
+			{Name: "VM1.Node3.nonce", Validation: config.ValidationConfig{MinValue: &minValue}},
+			{Name: "VM1.Node4.nonce"},
+		},
+	}
+	polled := map[string]common.MetricResult{
+		"VM1.Node1.nonce": {Config: cfg.Endpoints[0], Value: "10"},
+		"VM1.Node2.nonce": {Config: cfg.Endpoints[1], Value: "9"},
+		"VM1.Node4.nonce": {Config: cfg.Endpoints[3], Value: "1"},
+	}
+	poller := &testsCommon.PollerStub{
+		PollAllHandler: func(ctx context.Context, endpoints []config.EndpointConfig) map[string]common.MetricResult {
+			return polled
+		},
+	}
+	var reported map[string]common.MetricResult
+	reporter := &testsCommon.ReporterStub{
+		ReportHandler: func(ctx context.Context, res map[string]common.MetricResult) error {
+			reported = res
+			return nil
+		},
+	}
+	engine, err := NewAgentEngine(cfg, poller, reporter, &testsCommon.StatsRecorderStub{}, clock.NewSystemClock())
+	assert.Nil(t, err)
+
+	engine.Process(context.Background())
+	assert.Len(t, reported, 6)
+	assert.Equal(t, "true", reported["VM1.Node1.nonce.ok"].Value)
+	assert.Equal(t, "bool", reported["VM1.Node1.nonce.ok"].Config.Type)
+	assert.Equal(t, 5, reported["VM1.Node1.nonce.ok"].Config.NumAggregation)
+	assert.Equal(t, "false", reported["VM1.Node2.nonce.ok"].Value)
+	// a failed poll fails the validation
+	assert.Equal(t, "false", reported["VM1.Node3.nonce.ok"].Value)
+	assert.NotContains(t, reported, "VM1.Node4.nonce.ok")
+}
+
+func TestNewAgentEngine_InvalidValidation(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.Config{
+		Endpoints: []config.EndpointConfig{{Name: "VM1.Node1.version", Validation: config.ValidationConfig{Regex: "("}}},
+	}
+	engine, err := NewAgentEngine(cfg, &testsCommon.PollerStub{}, &testsCommon.ReporterStub{}, &testsCommon.StatsRecorderStub{}, clock.NewSystemClock())
+	assert.Nil(t, engine)
+	assert.Contains(t, err.Error(), "invalid validation of endpoint VM1.Node1.version")
+}
@@ -2,6 +2,7 @@ package engine
 
 import (
 	"context"
+	"time"
 
 	"github.com/iulianpascalau/api-monitoring/services/agent/common"
 	"github.com/iulianpascalau/api-monitoring/services/agent/config"
@@ -24,3 +25,16 @@ type Reporter interface {
 
 	IsInterfaceNil() bool
 }
+
+// StatsRecorder defines the component that records the outcome of the polling rounds and of the reports
+type StatsRecorder interface {
+	RecordPoll(endpoints []config.EndpointConfig, results map[string]common.MetricResult, duration time.Duration)
+	RecordReport(err error)
+	IsInterfaceNil() bool
+}
+
+// Clock defines the time source of the engine
+type Clock interface {
+	Now() time.Time
+	IsInterfaceNil() bool
+}
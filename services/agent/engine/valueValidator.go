@@ -0,0 +1,59 @@
+package engine
+
+import (
+	"regexp"
+	"strconv"
+
+	"github.com/iulianpascalau/api-monitoring/services/agent/config"
+)
+
+// valueValidator applies the checks of an endpoint on its polled values
+type valueValidator struct {
+	mustEqual string
+	minValue  *float64
+	maxValue  *float64
+	regex     *regexp.Regexp
+}
+
+func newValueValidator(cfg config.ValidationConfig) (*valueValidator, error) {
+	validator := &valueValidator{
+		mustEqual: cfg.MustEqual,
+		minValue:  cfg.MinValue,
+		maxValue:  cfg.MaxValue,
+	}
+	if len(cfg.Regex) > 0 {
+		var err error
+		validator.regex, err = regexp.Compile(cfg.Regex)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return validator, nil
+}
+
+// isValid returns true if the value passes all the checks. A value that is not a number fails the range checks.
+func (validator *valueValidator) isValid(value string) bool {
+	if len(validator.mustEqual) > 0 && value != validator.mustEqual {
+		return false
+	}
+	if validator.regex != nil && !validator.regex.MatchString(value) {
+		return false
+	}
+	if validator.minValue == nil && validator.maxValue == nil {
+		return true
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return false
+	}
+	if validator.minValue != nil && number < *validator.minValue {
+		return false
+	}
+	if validator.maxValue != nil && number > *validator.maxValue {
+		return false
+	}
+
+	return true
+}
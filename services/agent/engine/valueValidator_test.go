@@ -0,0 +1,62 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/services/agent/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValueValidator_IsValid(t *testing.T) {
+	t.Parallel()
+
+	minValue := 10.0
+	maxValue := 20.0
+
+	t.Run("must equal", func(t *testing.T) {
+		t.Parallel()
+
+		validator, err := newValueValidator(config.ValidationConfig{MustEqual: "true"})
+		require.Nil(t, err)
+		assert.True(t, validator.isValid("true"))
+		assert.False(t, validator.isValid("false"))
+	})
+	t.Run("range", func(t *testing.T) {
+		t.Parallel()
+
+		validator, err := newValueValidator(config.ValidationConfig{MinValue: &minValue, MaxValue: &maxValue})
+		require.Nil(t, err)
+		assert.True(t, validator.isValid("10"))
+		assert.True(t, validator.isValid("15.5"))
+		assert.True(t, validator.isValid("20"))
+		assert.False(t, validator.isValid("9.99"))
+		assert.False(t, validator.isValid("21"))
+		assert.False(t, validator.isValid("not a number"))
+
+		validator, err = newValueValidator(config.ValidationConfig{MinValue: &minValue})
+		require.Nil(t, err)
+		assert.True(t, validator.isValid("1000"))
+		assert.False(t, validator.isValid("1"))
+	})
+	t.Run("regex", func(t *testing.T) {
+		t.Parallel()
+
+		validator, err := newValueValidator(config.ValidationConfig{Regex: "^v1\\.[0-9]+"})
+		require.Nil(t, err)
+		assert.True(t, validator.isValid("v1.6.2"))
+		assert.False(t, validator.isValid("v2.0.0"))
+
+		_, err = newValueValidator(config.ValidationConfig{Regex: "("})
+		assert.NotNil(t, err)
+	})
+	t.Run("all the checks must pass", func(t *testing.T) {
+		t.Parallel()
+
+		validator, err := newValueValidator(config.ValidationConfig{Regex: "^1", MaxValue: &maxValue})
+		require.Nil(t, err)
+		assert.True(t, validator.isValid("15"))
+		assert.False(t, validator.isValid("150"))
+		assert.False(t, validator.isValid("5"))
+	})
+}
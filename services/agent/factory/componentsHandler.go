@@ -2,46 +2,482 @@ package factory
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"net/url"
 	"sync"
 	"time"
 
 	"github.com/iulianpascalau/api-monitoring/commonGo"
+	"github.com/iulianpascalau/api-monitoring/commonGo/clock"
+	"github.com/iulianpascalau/api-monitoring/commonGo/mqtt"
+	"github.com/iulianpascalau/api-monitoring/commonGo/release"
+	"github.com/iulianpascalau/api-monitoring/services/agent/collector"
+	"github.com/iulianpascalau/api-monitoring/services/agent/common"
 	"github.com/iulianpascalau/api-monitoring/services/agent/config"
 	"github.com/iulianpascalau/api-monitoring/services/agent/engine"
+	"github.com/iulianpascalau/api-monitoring/services/agent/health"
+	"github.com/iulianpascalau/api-monitoring/services/agent/logshipper"
 	"github.com/iulianpascalau/api-monitoring/services/agent/poller"
 	"github.com/iulianpascalau/api-monitoring/services/agent/reporter"
+	"github.com/iulianpascalau/api-monitoring/services/agent/tracer"
+	"github.com/iulianpascalau/api-monitoring/services/agent/updater"
+	"github.com/multiversx/mx-chain-core-go/core/check"
+	logger "github.com/multiversx/mx-chain-logger-go"
+)
+
+var log = logger.GetOrCreate("factory")
+
+const (
+	mqttClientIDPrefix         = "agent-"
+	defaultUpdateCheckInterval = time.Hour
+	defaultLogFlushInterval    = 30 * time.Second
+	defaultLogBufferSize       = 100
+	defaultReportBufferInMB    = 8
+	bytesInMB                  = 1024 * 1024
+	ipmiPrefixSuffix           = ".ipmi"
+	dockerPrefixSuffix         = ".docker"
 )
 
 type componentsHandler struct {
-	poller        engine.Poller
-	reporter      engine.Reporter
-	engine        Engine
-	mutCancel     sync.Mutex
-	cancel        func()
-	queryInterval time.Duration
+	poller              engine.Poller
+	reporter            Reporter
+	tracer              PayloadTracer
+	healthServer        HealthServer
+	engine              Engine
+	updater             Updater
+	updateCheckInterval time.Duration
+	updateInstalled     chan string
+	logShipper          LogShipper
+	logFlushInterval    time.Duration
+	mutCancel           sync.Mutex
+	cancel              func()
+	queryInterval       time.Duration
+	alignPolls          bool
+	clock               Clock
 }
 
 // NewComponentsHandler creates a new components handler
 func NewComponentsHandler(
 	serviceKeyApi string,
 	cfg config.Config,
+	buildInfo common.BuildInfo,
 ) (*componentsHandler, error) {
-	poll := poller.NewHTTPPoller(time.Duration(cfg.QueryIntervalInSeconds) * time.Second)
-	rep := reporter.NewHTTPReporter(cfg.ReportEndpoint, serviceKeyApi, cfg.Name, time.Duration(cfg.ReportTimeoutInSeconds)*time.Second)
+	return NewComponentsHandlerWithTestHooks(serviceKeyApi, cfg, buildInfo, TestHooks{})
+}
+
+// NewComponentsHandlerWithTestHooks creates a new components handler wired to the test hooks, so the tests can exercise
+// the retry and timing paths deterministically
+func NewComponentsHandlerWithTestHooks(
+	serviceKeyApi string,
+	cfg config.Config,
+	buildInfo common.BuildInfo,
+	hooks TestHooks,
+) (*componentsHandler, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	clk := hooks.Clock
+	if check.IfNil(clk) {
+		clk = clock.NewSystemClock()
+	}
+
+	poll, err := createPoller(cfg, clk)
+	if err != nil {
+		return nil, err
+	}
+	enginePoller, err := createCollectingPoller(cfg, poll, clk)
+	if err != nil {
+		return nil, err
+	}
+
+	// the reports and the shipped logs reach the aggregation service through the same network settings as the polls
+	transport, err := commonGo.NewHTTPTransport(commonGo.ArgsHTTPTransport{
+		ProxyURL: cfg.Network.ProxyURL,
+		IPFamily: cfg.Network.IPFamily,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	payloadTracer, err := createPayloadTracer(cfg.PayloadTrace)
+	if err != nil {
+		return nil, err
+	}
+
+	agentInfo := &common.AgentInfo{
+		Name:       cfg.Name,
+		Version:    buildInfo.Version,
+		Commit:     buildInfo.Commit,
+		ConfigHash: cfg.Hash(),
+	}
+	log.Debug("agent info", "version", agentInfo.Version, "commit", agentInfo.Commit, "config hash", agentInfo.ConfigHash)
+
+	rep, err := createReporter(serviceKeyApi, cfg, payloadTracer, agentInfo, transport)
+	if err != nil {
+		_ = payloadTracer.Close()
+		return nil, err
+	}
+	rep, err = createFaultyReporter(rep, hooks.FaultInjector)
+	if err != nil {
+		_ = payloadTracer.Close()
+		return nil, err
+	}
+	rep, err = createBufferedReporter(cfg, rep)
+	if err != nil {
+		_ = payloadTracer.Close()
+		return nil, err
+	}
+
+	statsTracker := health.NewStatsTracker()
+	eng, err := engine.NewAgentEngine(cfg, enginePoller, rep, statsTracker, clk)
+	if err != nil {
+		_ = payloadTracer.Close()
+		return nil, err
+	}
 
-	eng, err := engine.NewAgentEngine(cfg, poll, rep)
+	healthServer, err := createHealthServer(cfg, statsTracker)
 	if err != nil {
+		_ = payloadTracer.Close()
 		return nil, err
 	}
 
+	agentUpdater, err := createUpdater(cfg.SelfUpdate, buildInfo.Version)
+	if err != nil {
+		_ = payloadTracer.Close()
+		return nil, err
+	}
+	updateCheckInterval := time.Duration(cfg.SelfUpdate.CheckIntervalInMinutes) * time.Minute
+	if updateCheckInterval == 0 {
+		updateCheckInterval = defaultUpdateCheckInterval
+	}
+
+	shipper, err := createLogShipper(serviceKeyApi, cfg, transport)
+	if err != nil {
+		_ = payloadTracer.Close()
+		return nil, err
+	}
+	logFlushInterval := time.Duration(cfg.LogShipping.FlushIntervalInSeconds) * time.Second
+	if logFlushInterval == 0 {
+		logFlushInterval = defaultLogFlushInterval
+	}
+
 	return &componentsHandler{
-		poller:        poll,
-		reporter:      rep,
-		engine:        eng,
-		queryInterval: time.Duration(cfg.QueryIntervalInSeconds) * time.Second,
+		poller:              enginePoller,
+		reporter:            rep,
+		tracer:              payloadTracer,
+		healthServer:        healthServer,
+		engine:              eng,
+		updater:             agentUpdater,
+		updateCheckInterval: updateCheckInterval,
+		updateInstalled:     make(chan string, 1),
+		logShipper:          shipper,
+		logFlushInterval:    logFlushInterval,
+		queryInterval:       time.Duration(cfg.QueryIntervalInSeconds) * time.Second,
+		alignPolls:          cfg.AlignPollsToWallClock,
+		clock:               clk,
 	}, nil
 }
 
+// CreatePoller creates the poller of the configured endpoints
+func CreatePoller(cfg config.Config) (EndpointPoller, error) {
+	return createPoller(cfg, clock.NewSystemClock())
+}
+
+func createPoller(cfg config.Config, clk Clock) (EndpointPoller, error) {
+	argsPoller := poller.ArgsHTTPPoller{
+		Timeout:        time.Duration(cfg.QueryIntervalInSeconds) * time.Second,
+		Spread:         time.Duration(cfg.PollSpreadInSeconds) * time.Second,
+		Jitter:         time.Duration(cfg.PollJitterInMilliseconds) * time.Millisecond,
+		MaxConcurrency: int(cfg.MaxConcurrentPolls),
+		ProxyURL:       cfg.Network.ProxyURL,
+		IPFamily:       cfg.Network.IPFamily,
+		Clock:          clk,
+	}
+
+	return poller.NewHTTPPoller(argsPoller)
+}
+
+// createCollectingPoller adds the enabled collectors to the polling of the endpoints
+func createCollectingPoller(cfg config.Config, poll EndpointPoller, clk Clock) (engine.Poller, error) {
+	collectors := make([]collector.Collector, 0)
+	if cfg.IPMI.Enabled {
+		prefix := cfg.IPMI.Prefix
+		if len(prefix) == 0 {
+			prefix = cfg.Name + ipmiPrefixSuffix
+		}
+		ipmiCollector, err := collector.NewIPMICollector(collector.ArgsIPMICollector{
+			Config: cfg.IPMI,
+			Prefix: prefix,
+			Clock:  clk,
+		})
+		if err != nil {
+			return nil, err
+		}
+		collectors = append(collectors, ipmiCollector)
+		log.Debug("enabled the IPMI sensors collector", "prefix", prefix, "host", cfg.IPMI.Host)
+	}
+	if cfg.Docker.Enabled {
+		prefix := cfg.Docker.Prefix
+		if len(prefix) == 0 {
+			prefix = cfg.Name + dockerPrefixSuffix
+		}
+		dockerCollector, err := collector.NewDockerCollector(collector.ArgsDockerCollector{
+			Config: cfg.Docker,
+			Prefix: prefix,
+			Clock:  clk,
+		})
+		if err != nil {
+			return nil, err
+		}
+		collectors = append(collectors, dockerCollector)
+		log.Debug("enabled the Docker containers collector", "prefix", prefix, "num containers", len(cfg.Docker.Containers))
+	}
+	if len(collectors) == 0 {
+		return poll, nil
+	}
+
+	return collector.NewCollectingPoller(poll, collectors)
+}
+
+func createReporter(
+	serviceKeyApi string,
+	cfg config.Config,
+	payloadTracer PayloadTracer,
+	agentInfo *common.AgentInfo,
+	transport http.RoundTripper,
+) (Reporter, error) {
+	timeout := time.Duration(cfg.ReportTimeoutInSeconds) * time.Second
+	useMsgPack := cfg.ReportEncoding == config.ReportEncodingMsgPack
+	queryInterval := time.Duration(cfg.QueryIntervalInSeconds) * time.Second
+	if cfg.ReportTransport != config.ReportTransportMQTT && cfg.FanOut.Enabled {
+		log.Info("reports are sent to several endpoints", "primary", cfg.ReportEndpoint,
+			"others", cfg.FanOut.Endpoints)
+
+		argsReporter := reporter.ArgsFanOutReporter{
+			Endpoints:     append([]string{cfg.ReportEndpoint}, cfg.FanOut.Endpoints...),
+			ApiKey:        serviceKeyApi,
+			AgentID:       cfg.Name,
+			Timeout:       timeout,
+			Tracer:        payloadTracer,
+			UseChecksum:   cfg.ReportChecksum,
+			UseMsgPack:    useMsgPack,
+			QueryInterval: queryInterval,
+			AgentInfo:     agentInfo,
+			Transport:     transport,
+		}
+
+		return reporter.NewFanOutReporter(argsReporter)
+	}
+	if cfg.ReportTransport != config.ReportTransportMQTT && cfg.Failover.Enabled {
+		log.Info("reports fail over to the replica endpoints", "primary", cfg.ReportEndpoint,
+			"replicas", cfg.Failover.Endpoints)
+
+		argsReporter := reporter.ArgsFailoverReporter{
+			Endpoints:                  append([]string{cfg.ReportEndpoint}, cfg.Failover.Endpoints...),
+			ApiKey:                     serviceKeyApi,
+			AgentID:                    cfg.Name,
+			Timeout:                    timeout,
+			Tracer:                     payloadTracer,
+			UseChecksum:                cfg.ReportChecksum,
+			UseMsgPack:                 useMsgPack,
+			QueryInterval:              queryInterval,
+			AgentInfo:                  agentInfo,
+			PrimaryHealthCheckURL:      cfg.Failover.PrimaryHealthCheckURL,
+			HealthCheckInterval:        time.Duration(cfg.Failover.HealthCheckIntervalInSeconds) * time.Second,
+			NumHealthyChecksToFailBack: int(cfg.Failover.NumHealthyChecksToFailBack),
+			Transport:                  transport,
+		}
+
+		return reporter.NewFailoverReporter(argsReporter)
+	}
+	if cfg.ReportTransport != config.ReportTransportMQTT {
+		argsReporter := reporter.ArgsHTTPReporter{
+			Endpoint:      cfg.ReportEndpoint,
+			ApiKey:        serviceKeyApi,
+			AgentID:       cfg.Name,
+			Timeout:       timeout,
+			Tracer:        payloadTracer,
+			UseChecksum:   cfg.ReportChecksum,
+			UseMsgPack:    useMsgPack,
+			QueryInterval: queryInterval,
+			AgentInfo:     agentInfo,
+			Transport:     transport,
+		}
+
+		return reporter.NewHTTPReporter(argsReporter)
+	}
+
+	clientID := cfg.MQTT.ClientID
+	if len(clientID) == 0 {
+		clientID = mqttClientIDPrefix + cfg.Name
+	}
+	argsClient := mqtt.ArgsClient{
+		BrokerURL: cfg.MQTT.BrokerURL,
+		ClientID:  clientID,
+		Username:  cfg.MQTT.Username,
+		Password:  cfg.MQTT.Password,
+		KeepAlive: time.Duration(cfg.MQTT.KeepAliveInSeconds) * time.Second,
+		Timeout:   timeout,
+	}
+	client, err := mqtt.NewClient(argsClient)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("reports are published over MQTT", "broker", cfg.MQTT.BrokerURL, "topic", cfg.MQTT.Topic)
+
+	argsReporter := reporter.ArgsMQTTReporter{
+		Client:        client,
+		Topic:         cfg.MQTT.Topic,
+		QoS:           cfg.MQTT.QoS,
+		ApiKey:        serviceKeyApi,
+		AgentID:       cfg.Name,
+		Tracer:        payloadTracer,
+		QueryInterval: queryInterval,
+		AgentInfo:     agentInfo,
+	}
+
+	return reporter.NewMQTTReporter(argsReporter)
+}
+
+func createPayloadTracer(cfg config.PayloadTraceConfig) (PayloadTracer, error) {
+	if !cfg.Enabled {
+		return tracer.NewDisabledPayloadTracer(), nil
+	}
+
+	log.Info("payload tracing is enabled", "directory", cfg.Directory)
+
+	argsPayloadTracer := tracer.ArgsPayloadTracer{
+		Directory:       cfg.Directory,
+		MaxFileSizeInMB: cfg.MaxFileSizeInMB,
+		MaxNumFiles:     cfg.MaxNumFiles,
+	}
+
+	return tracer.NewPayloadTracer(argsPayloadTracer)
+}
+
+func createHealthServer(cfg config.Config, statsProvider health.StatsProvider) (HealthServer, error) {
+	if !cfg.HealthServer.Enabled {
+		return health.NewDisabledHealthServer(), nil
+	}
+
+	maxReportAge := time.Duration(cfg.HealthServer.MaxReportAgeInSeconds) * time.Second
+	if maxReportAge == 0 {
+		maxReportAge = 3 * time.Duration(cfg.QueryIntervalInSeconds) * time.Second
+	}
+
+	argsHealthServer := health.ArgsHealthServer{
+		ListenAddress:    cfg.HealthServer.ListenAddress,
+		StatsProvider:    statsProvider,
+		MaxReportAge:     maxReportAge,
+		ProfilingEnabled: cfg.HealthServer.ProfilingEnabled,
+	}
+
+	return health.NewHealthServer(argsHealthServer)
+}
+
+func createUpdater(cfg config.SelfUpdateConfig, currentVersion string) (Updater, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	publicKey, err := release.ParsePublicKey(cfg.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("self update is enabled", "manifest URL", cfg.ManifestURL)
+
+	argsUpdater := updater.ArgsUpdater{
+		ManifestURL:    cfg.ManifestURL,
+		PublicKey:      publicKey,
+		CurrentVersion: currentVersion,
+	}
+
+	return updater.NewUpdater(argsUpdater)
+}
+
+// createFaultyReporter wraps the reporter in one injecting the failures, if an injector is provided. It is wrapped
+// before the buffering, so the dropped reports are buffered and replayed as the failed ones.
+func createFaultyReporter(rep Reporter, injector FaultInjector) (Reporter, error) {
+	if check.IfNil(injector) {
+		return rep, nil
+	}
+
+	log.Warn("the fault injection is enabled, the reports can be dropped")
+
+	return reporter.NewFaultyReporter(rep, injector)
+}
+
+// createBufferedReporter wraps the reporter in one buffering the failed reports, if enabled
+func createBufferedReporter(cfg config.Config, rep Reporter) (Reporter, error) {
+	if !cfg.ReportBuffer.Enabled {
+		return rep, nil
+	}
+
+	maxMemoryInMB := int(cfg.ReportBuffer.MaxMemoryInMB)
+	if maxMemoryInMB == 0 {
+		maxMemoryInMB = defaultReportBufferInMB
+	}
+	log.Info("the failed reports are buffered", "max memory in MB", maxMemoryInMB)
+
+	argsReporter := reporter.ArgsBufferedReporter{
+		Reporter:         rep,
+		AgentID:          cfg.Name,
+		MaxMemoryInBytes: maxMemoryInMB * bytesInMB,
+	}
+
+	return reporter.NewBufferedReporter(argsReporter)
+}
+
+func createLogShipper(serviceKeyApi string, cfg config.Config, transport http.RoundTripper) (LogShipper, error) {
+	if !cfg.LogShipping.Enabled {
+		return nil, nil
+	}
+
+	endpoint, err := logShippingEndpoint(cfg)
+	if err != nil {
+		return nil, err
+	}
+	bufferSize := int(cfg.LogShipping.BufferSize)
+	if bufferSize == 0 {
+		bufferSize = defaultLogBufferSize
+	}
+
+	log.Info("the warnings and the errors are shipped to the aggregation service", "endpoint", endpoint)
+
+	argsLogShipper := logshipper.ArgsLogShipper{
+		Endpoint:   endpoint,
+		ApiKey:     serviceKeyApi,
+		Timeout:    time.Duration(cfg.ReportTimeoutInSeconds) * time.Second,
+		BufferSize: bufferSize,
+		Transport:  transport,
+	}
+
+	return logshipper.NewLogShipper(argsLogShipper)
+}
+
+// logShippingEndpoint returns the configured endpoint, or the agents/<name>/logs URL next to the report endpoint
+func logShippingEndpoint(cfg config.Config) (string, error) {
+	if len(cfg.LogShipping.Endpoint) > 0 {
+		return cfg.LogShipping.Endpoint, nil
+	}
+
+	reportURL, err := url.Parse(cfg.ReportEndpoint)
+	if err != nil {
+		return "", err
+	}
+	logsURL, err := reportURL.Parse(fmt.Sprintf("agents/%s/logs", url.PathEscape(cfg.Name)))
+	if err != nil {
+		return "", err
+	}
+
+	return logsURL.String(), nil
+}
+
 // GetPoller returns the poller component
 func (ch *componentsHandler) GetPoller() engine.Poller {
 	return ch.poller
@@ -66,10 +502,48 @@ func (ch *componentsHandler) Start() {
 		return
 	}
 
+	err := ch.healthServer.Start()
+	if err != nil {
+		log.Error("failed to start the health server", "error", err)
+	}
+
 	var ctx context.Context
 	ctx, ch.cancel = context.WithCancel(context.Background())
 
-	commonGo.CronJobStarter(ctx, ch.engine.Process, ch.queryInterval)
+	if ch.alignPolls {
+		log.Info("the poll cycles are aligned to the wall clock", "interval", ch.queryInterval)
+		commonGo.AlignedCronJobStarter(ctx, ch.clock, ch.engine.Process, ch.queryInterval)
+	} else {
+		commonGo.CronJobStarterWithClock(ctx, ch.clock, ch.engine.Process, ch.queryInterval)
+	}
+	if !check.IfNil(ch.updater) {
+		commonGo.CronJobStarterWithClock(ctx, ch.clock, ch.checkForUpdate, ch.updateCheckInterval)
+	}
+	if !check.IfNil(ch.logShipper) {
+		commonGo.CronJobStarterWithClock(ctx, ch.clock, ch.logShipper.Flush, ch.logFlushInterval)
+	}
+}
+
+func (ch *componentsHandler) checkForUpdate(ctx context.Context) {
+	version, err := ch.updater.CheckAndUpdate(ctx)
+	if err != nil {
+		log.Warn("failed to update the agent", "error", err)
+		return
+	}
+	if len(version) == 0 {
+		return
+	}
+
+	select {
+	case ch.updateInstalled <- version:
+	default:
+	}
+}
+
+// UpdateInstalled returns the channel receiving the version of the installed agent release, the agent must restart to
+// run it
+func (ch *componentsHandler) UpdateInstalled() <-chan string {
+	return ch.updateInstalled
 }
 
 // Close closes the inner components
@@ -77,6 +551,13 @@ func (ch *componentsHandler) Close() {
 	ch.mutCancel.Lock()
 	defer ch.mutCancel.Unlock()
 
+	_ = ch.tracer.Close()
+	_ = ch.healthServer.Close()
+	_ = ch.reporter.Close()
+	if !check.IfNil(ch.logShipper) {
+		_ = ch.logShipper.Close()
+	}
+
 	if ch.cancel == nil {
 		return
 	}
@@ -1,11 +1,20 @@
 package factory
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/iulianpascalau/api-monitoring/commonGo"
+	"github.com/iulianpascalau/api-monitoring/commonGo/clock"
+	"github.com/iulianpascalau/api-monitoring/commonGo/faults"
+	"github.com/iulianpascalau/api-monitoring/services/agent/common"
 	"github.com/iulianpascalau/api-monitoring/services/agent/config"
+	"github.com/iulianpascalau/api-monitoring/services/agent/testsCommon"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewComponentsHandler(t *testing.T) {
@@ -16,10 +25,12 @@ func TestNewComponentsHandler(t *testing.T) {
 		config.Config{
 			Name:                   "vm1",
 			QueryIntervalInSeconds: 1,
-			ReportEndpoint:         "/report",
+			ReportEndpoint:         "http://127.0.0.1/report",
 			ReportTimeoutInSeconds: 1,
 			Endpoints:              nil,
-		})
+		},
+		common.BuildInfo{},
+	)
 
 	assert.NotNil(t, handler)
 	assert.Nil(t, err)
@@ -27,6 +38,24 @@ func TestNewComponentsHandler(t *testing.T) {
 	handler.Close()
 }
 
+func TestNewComponentsHandlerInvalidConfig(t *testing.T) {
+	t.Parallel()
+
+	handler, err := NewComponentsHandler(
+		"service-key",
+		config.Config{
+			Name:                   "vm1",
+			QueryIntervalInSeconds: 0,
+			ReportEndpoint:         "http://127.0.0.1/report",
+			ReportTimeoutInSeconds: 1,
+		},
+		common.BuildInfo{},
+	)
+
+	assert.Nil(t, handler)
+	assert.True(t, errors.Is(err, commonGo.ErrInvalidConfig))
+}
+
 func TestComponentsHandlerMethods(t *testing.T) {
 	t.Parallel()
 
@@ -35,10 +64,12 @@ func TestComponentsHandlerMethods(t *testing.T) {
 		config.Config{
 			Name:                   "vm1",
 			QueryIntervalInSeconds: 1,
-			ReportEndpoint:         "/report",
+			ReportEndpoint:         "http://127.0.0.1/report",
 			ReportTimeoutInSeconds: 1,
 			Endpoints:              nil,
-		})
+		},
+		common.BuildInfo{},
+	)
 
 	handler.Start()
 
@@ -53,3 +84,374 @@ func TestComponentsHandlerMethods(t *testing.T) {
 
 	handler.Close()
 }
+
+func TestNewComponentsHandlerWithMQTTTransport(t *testing.T) {
+	t.Parallel()
+
+	handler, err := NewComponentsHandler(
+		"service-key",
+		config.Config{
+			Name:                   "vm1",
+			QueryIntervalInSeconds: 1,
+			ReportTimeoutInSeconds: 1,
+			ReportTransport:        config.ReportTransportMQTT,
+			MQTT: config.MQTTConfig{
+				BrokerURL: "tcp://127.0.0.1:1883",
+				Topic:     "monitoring/reports/vm1",
+			},
+		},
+		common.BuildInfo{},
+	)
+	require.Nil(t, err)
+
+	reporter := handler.GetReporter()
+	assert.Equal(t, "*reporter.mqttReporter", fmt.Sprintf("%T", reporter))
+
+	handler.Close()
+}
+
+func TestNewComponentsHandlerWithFailover(t *testing.T) {
+	t.Parallel()
+
+	handler, err := NewComponentsHandler(
+		"service-key",
+		config.Config{
+			Name:                   "vm1",
+			QueryIntervalInSeconds: 1,
+			ReportEndpoint:         "https://aaa.bbb.com/report",
+			ReportTimeoutInSeconds: 1,
+			Failover: config.FailoverConfig{
+				Enabled:   true,
+				Endpoints: []string{"https://ccc.ddd.com/report"},
+			},
+		},
+		common.BuildInfo{},
+	)
+	require.Nil(t, err)
+
+	reporter := handler.GetReporter()
+	assert.Equal(t, "*reporter.failoverReporter", fmt.Sprintf("%T", reporter))
+
+	handler.Close()
+}
+
+func TestNewComponentsHandlerWithFanOut(t *testing.T) {
+	t.Parallel()
+
+	handler, err := NewComponentsHandler(
+		"service-key",
+		config.Config{
+			Name:                   "vm1",
+			QueryIntervalInSeconds: 1,
+			ReportEndpoint:         "https://aaa.bbb.com/report",
+			ReportTimeoutInSeconds: 1,
+			FanOut: config.FanOutConfig{
+				Enabled:   true,
+				Endpoints: []string{"https://ccc.ddd.com/report"},
+			},
+		},
+		common.BuildInfo{},
+	)
+	require.Nil(t, err)
+
+	reporter := handler.GetReporter()
+	assert.Equal(t, "*reporter.fanOutReporter", fmt.Sprintf("%T", reporter))
+
+	handler.Close()
+}
+
+func TestNewComponentsHandlerWithPayloadTrace(t *testing.T) {
+	t.Parallel()
+
+	t.Run("invalid trace config should error", func(t *testing.T) {
+		handler, err := NewComponentsHandler(
+			"service-key",
+			config.Config{
+				Name:                   "vm1",
+				QueryIntervalInSeconds: 1,
+				ReportEndpoint:         "http://127.0.0.1/report",
+				ReportTimeoutInSeconds: 1,
+				PayloadTrace: config.PayloadTraceConfig{
+					Enabled: true,
+				},
+			},
+			common.BuildInfo{},
+		)
+
+		assert.Nil(t, handler)
+		assert.NotNil(t, err)
+	})
+	t.Run("should work", func(t *testing.T) {
+		handler, err := NewComponentsHandler(
+			"service-key",
+			config.Config{
+				Name:                   "vm1",
+				QueryIntervalInSeconds: 1,
+				ReportEndpoint:         "http://127.0.0.1/report",
+				ReportTimeoutInSeconds: 1,
+				PayloadTrace: config.PayloadTraceConfig{
+					Enabled:         true,
+					Directory:       t.TempDir(),
+					MaxFileSizeInMB: 1,
+					MaxNumFiles:     1,
+				},
+			},
+			common.BuildInfo{},
+		)
+
+		assert.NotNil(t, handler)
+		assert.Nil(t, err)
+		assert.Equal(t, "*tracer.payloadTracer", fmt.Sprintf("%T", handler.tracer))
+
+		handler.Close()
+	})
+}
+
+func TestNewComponentsHandlerWithHealthServer(t *testing.T) {
+	t.Parallel()
+
+	t.Run("invalid health server config should error", func(t *testing.T) {
+		handler, err := NewComponentsHandler(
+			"service-key",
+			config.Config{
+				Name:                   "vm1",
+				QueryIntervalInSeconds: 1,
+				ReportEndpoint:         "http://127.0.0.1/report",
+				ReportTimeoutInSeconds: 1,
+				HealthServer: config.HealthServerConfig{
+					Enabled: true,
+				},
+			},
+			common.BuildInfo{},
+		)
+
+		assert.Nil(t, handler)
+		assert.NotNil(t, err)
+	})
+	t.Run("should work", func(t *testing.T) {
+		handler, err := NewComponentsHandler(
+			"service-key",
+			config.Config{
+				Name:                   "vm1",
+				QueryIntervalInSeconds: 1,
+				ReportEndpoint:         "http://127.0.0.1/report",
+				ReportTimeoutInSeconds: 1,
+				HealthServer: config.HealthServerConfig{
+					Enabled:       true,
+					ListenAddress: "127.0.0.1:0",
+				},
+			},
+			common.BuildInfo{},
+		)
+
+		assert.NotNil(t, handler)
+		assert.Nil(t, err)
+		assert.Equal(t, "*health.healthServer", fmt.Sprintf("%T", handler.healthServer))
+
+		handler.Start()
+		handler.Close()
+	})
+}
+
+func TestNewComponentsHandlerWithSelfUpdate(t *testing.T) {
+	t.Parallel()
+
+	handler, err := NewComponentsHandler(
+		"service-key",
+		config.Config{
+			Name:                   "vm1",
+			QueryIntervalInSeconds: 1,
+			ReportEndpoint:         "http://127.0.0.1/report",
+			ReportTimeoutInSeconds: 1,
+			SelfUpdate: config.SelfUpdateConfig{
+				Enabled:     true,
+				ManifestURL: "https://releases.example.com/agent/manifest.json",
+				PublicKey:   "d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a",
+			},
+		},
+		common.BuildInfo{Version: "v1.0.0"},
+	)
+	require.Nil(t, err)
+	assert.Equal(t, "*updater.updater", fmt.Sprintf("%T", handler.updater))
+	assert.Equal(t, defaultUpdateCheckInterval, handler.updateCheckInterval)
+
+	handler.Close()
+}
+
+func TestComponentsHandler_CheckForUpdate(t *testing.T) {
+	t.Parallel()
+
+	handler, err := NewComponentsHandler(
+		"service-key",
+		config.Config{
+			Name:                   "vm1",
+			QueryIntervalInSeconds: 1,
+			ReportEndpoint:         "http://127.0.0.1/report",
+			ReportTimeoutInSeconds: 1,
+		},
+		common.BuildInfo{},
+	)
+	require.Nil(t, err)
+	assert.Nil(t, handler.updater)
+	defer handler.Close()
+
+	installedVersion := ""
+	handler.updater = &testsCommon.UpdaterStub{
+		CheckAndUpdateHandler: func(ctx context.Context) (string, error) {
+			return installedVersion, nil
+		},
+	}
+
+	handler.checkForUpdate(context.Background())
+	assert.Empty(t, handler.UpdateInstalled())
+
+	installedVersion = "v1.1.0"
+	handler.checkForUpdate(context.Background())
+	handler.checkForUpdate(context.Background())
+	assert.Len(t, handler.UpdateInstalled(), 1)
+	assert.Equal(t, "v1.1.0", <-handler.UpdateInstalled())
+}
+
+func TestComponentsHandler_LogShipping(t *testing.T) {
+	t.Parallel()
+
+	handler, err := NewComponentsHandler(
+		"service-key",
+		config.Config{
+			Name:                   "vm 1",
+			QueryIntervalInSeconds: 1,
+			ReportEndpoint:         "http://127.0.0.1/api/report",
+			ReportTimeoutInSeconds: 1,
+			LogShipping: config.LogShippingConfig{
+				Enabled: true,
+			},
+		},
+		common.BuildInfo{},
+	)
+	require.Nil(t, err)
+	assert.Equal(t, "*logshipper.logShipper", fmt.Sprintf("%T", handler.logShipper))
+	assert.Equal(t, defaultLogFlushInterval, handler.logFlushInterval)
+
+	handler.Close()
+}
+
+func TestComponentsHandler_ReportBuffer(t *testing.T) {
+	t.Parallel()
+
+	handler, err := NewComponentsHandler(
+		"service-key",
+		config.Config{
+			Name:                   "vm 1",
+			QueryIntervalInSeconds: 1,
+			ReportEndpoint:         "http://127.0.0.1/api/report",
+			ReportTimeoutInSeconds: 1,
+			ReportBuffer: config.ReportBufferConfig{
+				Enabled: true,
+			},
+		},
+		common.BuildInfo{},
+	)
+	require.Nil(t, err)
+	assert.Equal(t, "*reporter.bufferedReporter", fmt.Sprintf("%T", handler.reporter))
+
+	handler.Close()
+}
+
+func TestComponentsHandler_IPMICollector(t *testing.T) {
+	t.Parallel()
+
+	handler, err := NewComponentsHandler(
+		"service-key",
+		config.Config{
+			Name:                   "vm1",
+			QueryIntervalInSeconds: 1,
+			ReportEndpoint:         "http://127.0.0.1/api/report",
+			ReportTimeoutInSeconds: 1,
+			IPMI: config.IPMIConfig{
+				Enabled:        true,
+				NumAggregation: 100,
+			},
+		},
+		common.BuildInfo{},
+	)
+	require.Nil(t, err)
+	assert.Equal(t, "*collector.collectingPoller", fmt.Sprintf("%T", handler.poller))
+
+	handler.Close()
+}
+
+func TestComponentsHandler_DockerCollector(t *testing.T) {
+	t.Parallel()
+
+	handler, err := NewComponentsHandler(
+		"service-key",
+		config.Config{
+			Name:                   "vm1",
+			QueryIntervalInSeconds: 1,
+			ReportEndpoint:         "http://127.0.0.1/api/report",
+			ReportTimeoutInSeconds: 1,
+			Docker: config.DockerConfig{
+				Enabled:        true,
+				Containers:     []string{"observer-0"},
+				NumAggregation: 100,
+			},
+		},
+		common.BuildInfo{},
+	)
+	require.Nil(t, err)
+	assert.Equal(t, "*collector.collectingPoller", fmt.Sprintf("%T", handler.poller))
+
+	handler.Close()
+}
+
+func TestLogShippingEndpoint(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.Config{
+		Name:           "vm 1",
+		ReportEndpoint: "https://aaa.bbb.com/monitoring/api/report",
+	}
+	endpoint, err := logShippingEndpoint(cfg)
+	require.Nil(t, err)
+	assert.Equal(t, "https://aaa.bbb.com/monitoring/api/agents/vm%201/logs", endpoint)
+
+	cfg.LogShipping.Endpoint = "https://ccc.ddd.com/api/agents/vm1/logs"
+	endpoint, err = logShippingEndpoint(cfg)
+	require.Nil(t, err)
+	assert.Equal(t, "https://ccc.ddd.com/api/agents/vm1/logs", endpoint)
+}
+
+func TestComponentsHandler_AlignedPolls(t *testing.T) {
+	t.Parallel()
+
+	boundary := time.Unix(1700000040, 0)
+	clk := clock.NewManualClock(boundary.Add(-10 * time.Second))
+	injector := faults.NewInjector()
+	handler, err := NewComponentsHandlerWithTestHooks(
+		"service-key",
+		config.Config{
+			Name:                   "vm1",
+			QueryIntervalInSeconds: 60,
+			AlignPollsToWallClock:  true,
+			ReportEndpoint:         "http://127.0.0.1:1/report",
+			ReportTimeoutInSeconds: 1,
+		},
+		common.BuildInfo{},
+		TestHooks{FaultInjector: injector, Clock: clk},
+	)
+	require.Nil(t, err)
+	handler.Start()
+	defer handler.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.Nil(t, clk.WaitTimers(ctx, 1))
+	clk.Advance(9 * time.Second)
+	assert.Zero(t, injector.Hits(faults.PointAgentReport), "the first cycle should wait for the boundary")
+
+	clk.Advance(time.Second)
+	require.Nil(t, injector.WaitHits(ctx, faults.PointAgentReport, 1))
+	require.Nil(t, clk.WaitTimers(ctx, 1))
+	clk.Advance(time.Minute)
+	require.Nil(t, injector.WaitHits(ctx, faults.PointAgentReport, 2))
+}
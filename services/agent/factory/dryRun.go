@@ -0,0 +1,79 @@
+package factory
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/agent/config"
+)
+
+type dryRunResult struct {
+	value    string
+	err      error
+	duration time.Duration
+}
+
+// DryRun polls each endpoint once, concurrently, and writes the extracted values and the failures to the writer, in
+// the config order. Nothing is reported. Returns an error if at least one endpoint failed.
+func DryRun(ctx context.Context, poller EndpointPoller, endpoints []config.EndpointConfig, writer io.Writer) error {
+	results := make([]dryRunResult, len(endpoints))
+	wg := sync.WaitGroup{}
+	for i := range endpoints {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			start := time.Now()
+			value, err := poller.Poll(ctx, endpoints[i])
+			if err == nil {
+				err = checkValueType(value, endpoints[i].Type)
+			}
+			results[i] = dryRunResult{
+				value:    value,
+				err:      err,
+				duration: time.Since(start).Round(time.Millisecond),
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	numFailed := 0
+	for i, result := range results {
+		if result.err != nil {
+			numFailed++
+			_, _ = fmt.Fprintf(writer, "FAIL %s (%s): %v [%s]\n", endpoints[i].Name, endpoints[i].Type, result.err, result.duration)
+			continue
+		}
+		_, _ = fmt.Fprintf(writer, "OK   %s (%s) = %s [%s]\n", endpoints[i].Name, endpoints[i].Type, result.value, result.duration)
+	}
+	_, _ = fmt.Fprintf(writer, "%d/%d endpoints polled successfully\n", len(endpoints)-numFailed, len(endpoints))
+
+	if numFailed > 0 {
+		return fmt.Errorf("%w: %d of %d", errEndpointsFailed, numFailed, len(endpoints))
+	}
+
+	return nil
+}
+
+// checkValueType returns an error if the value can not be parsed as the declared metric type, which the aggregation
+// service would reject
+func checkValueType(value string, metricType string) error {
+	var err error
+	switch metricType {
+	case "uint64":
+		_, err = strconv.ParseUint(value, 10, 64)
+	case "float64":
+		_, err = strconv.ParseFloat(value, 64)
+	case "bool":
+		_, err = strconv.ParseBool(value)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %q is not a %s", errInvalidValueType, value, metricType)
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package factory
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/services/agent/config"
+	"github.com/iulianpascalau/api-monitoring/services/agent/testsCommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDryRun(t *testing.T) {
+	t.Parallel()
+
+	values := map[string]string{
+		"VM1.Node1.nonce": "42",
+		"VM1.Node1.epoch": "3.5",
+		"VM1.Ratio":       "0.75",
+		"VM1.Version":     "v1.7.0",
+	}
+	poller := &testsCommon.PollerStub{
+		PollHandler: func(ctx context.Context, endpoint config.EndpointConfig) (string, error) {
+			value, found := values[endpoint.Name]
+			if !found {
+				return "", errors.New("connection refused")
+			}
+			return value, nil
+		},
+	}
+
+	t.Run("all endpoints polled should not error", func(t *testing.T) {
+		t.Parallel()
+
+		output := &bytes.Buffer{}
+		err := DryRun(context.Background(), poller, []config.EndpointConfig{
+			{Name: "VM1.Node1.nonce", Type: "uint64"},
+			{Name: "VM1.Version", Type: "string"},
+		}, output)
+		require.Nil(t, err)
+		assert.Contains(t, output.String(), "OK   VM1.Node1.nonce (uint64) = 42")
+		assert.Contains(t, output.String(), "OK   VM1.Version (string) = v1.7.0")
+		assert.Contains(t, output.String(), "2/2 endpoints polled successfully")
+	})
+	t.Run("failed endpoints and invalid values should error", func(t *testing.T) {
+		t.Parallel()
+
+		output := &bytes.Buffer{}
+		err := DryRun(context.Background(), poller, []config.EndpointConfig{
+			{Name: "VM1.Node1.epoch", Type: "uint64"},
+			{Name: "VM1.Ratio", Type: "float64"},
+			{Name: "VM1.Active", Type: "bool"},
+		}, output)
+		require.True(t, errors.Is(err, errEndpointsFailed))
+		assert.Contains(t, output.String(), "FAIL VM1.Node1.epoch (uint64): invalid value type: \"3.5\" is not a uint64")
+		assert.Contains(t, output.String(), "OK   VM1.Ratio (float64) = 0.75")
+		assert.Contains(t, output.String(), "FAIL VM1.Active (bool): connection refused")
+		assert.Contains(t, output.String(), "1/3 endpoints polled successfully")
+	})
+}
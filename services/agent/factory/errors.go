@@ -0,0 +1,7 @@
+package factory
+
+import "errors"
+
+var errEndpointsFailed = errors.New("endpoints failed")
+
+var errInvalidValueType = errors.New("invalid value type")
@@ -1,9 +1,78 @@
 package factory
 
-import "context"
+import (
+	"context"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/commonGo/clock"
+	"github.com/iulianpascalau/api-monitoring/services/agent/common"
+	"github.com/iulianpascalau/api-monitoring/services/agent/config"
+)
+
+// EndpointPoller defines the operations of the component polling the endpoints, all at once or one by one
+type EndpointPoller interface {
+	PollAll(ctx context.Context, endpoints []config.EndpointConfig) map[string]common.MetricResult
+	Poll(ctx context.Context, endpoint config.EndpointConfig) (string, error)
+	IsInterfaceNil() bool
+}
 
 // Engine defines the agent's operations
 type Engine interface {
 	Process(ctx context.Context)
 	IsInterfaceNil() bool
 }
+
+// PayloadTracer defines the operations of a component able to record the exchanged report payloads
+type PayloadTracer interface {
+	Trace(entry common.PayloadTraceEntry)
+	Close() error
+	IsInterfaceNil() bool
+}
+
+// HealthServer defines the operations of the local listener exposing the agent's health and stats
+type HealthServer interface {
+	Start() error
+	Close() error
+	IsInterfaceNil() bool
+}
+
+// Reporter defines the operations of a component able to push the polled metrics to the aggregation service
+type Reporter interface {
+	Report(ctx context.Context, results map[string]common.MetricResult) error
+	Close() error
+	IsInterfaceNil() bool
+}
+
+// Updater defines the operations of a component able to install the newer agent releases
+type Updater interface {
+	CheckAndUpdate(ctx context.Context) (string, error)
+	IsInterfaceNil() bool
+}
+
+// LogShipper defines the operations of a component able to forward the agent warnings and errors
+type LogShipper interface {
+	Flush(ctx context.Context)
+	Close() error
+	IsInterfaceNil() bool
+}
+
+// FaultInjector defines the operations of a component injecting failures in the named points, used by the tests
+type FaultInjector interface {
+	Inject(ctx context.Context, point string) error
+	IsInterfaceNil() bool
+}
+
+// Clock defines the time source of the agent: the polling cycles, the polled values timestamps and the periodic jobs
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) clock.Timer
+	IsInterfaceNil() bool
+}
+
+// TestHooks are the components replaced by the tests, the nil ones use the production behavior
+type TestHooks struct {
+	// FaultInjector injects the failures in the reports, nil injects nothing
+	FaultInjector FaultInjector
+	// Clock drives the polling cycles and the periodic jobs, nil uses the system clock
+	Clock Clock
+}
@@ -0,0 +1,24 @@
+package health
+
+type disabledHealthServer struct {
+}
+
+// NewDisabledHealthServer creates a health server that does nothing
+func NewDisabledHealthServer() *disabledHealthServer {
+	return &disabledHealthServer{}
+}
+
+// Start does nothing and returns nil
+func (server *disabledHealthServer) Start() error {
+	return nil
+}
+
+// Close does nothing and returns nil
+func (server *disabledHealthServer) Close() error {
+	return nil
+}
+
+// IsInterfaceNil returns true if there is no value under the interface
+func (server *disabledHealthServer) IsInterfaceNil() bool {
+	return server == nil
+}
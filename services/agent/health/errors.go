@@ -0,0 +1,10 @@
+package health
+
+import "errors"
+
+var (
+	errEmptyListenAddress   = errors.New("empty listen address")
+	errNilStatsProvider     = errors.New("nil stats provider")
+	errInvalidMaxReportAge  = errors.New("invalid max report age")
+	errServerAlreadyStarted = errors.New("health server already started")
+)
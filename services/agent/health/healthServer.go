@@ -0,0 +1,167 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"sync"
+	"time"
+
+	"github.com/multiversx/mx-chain-core-go/core/check"
+	logger "github.com/multiversx/mx-chain-logger-go"
+)
+
+var log = logger.GetOrCreate("health")
+
+const shutdownTimeout = 5 * time.Second
+
+// ArgsHealthServer defines the DTO struct for the NewHealthServer constructor function
+type ArgsHealthServer struct {
+	ListenAddress string
+	StatsProvider StatsProvider
+	// MaxReportAge is the maximum time since the last successful report for which the agent is reported as healthy
+	MaxReportAge time.Duration
+	// ProfilingEnabled mounts the Go runtime profiling (pprof) handlers under /debug/pprof/
+	ProfilingEnabled bool
+}
+
+// healthServer is the local HTTP listener exposing the /healthz and /stats endpoints and, optionally, the pprof ones
+type healthServer struct {
+	listenAddress string
+	statsProvider StatsProvider
+	maxReportAge  time.Duration
+	mut           sync.Mutex
+	httpServer    *http.Server
+	listener      net.Listener
+}
+
+// NewHealthServer creates a new health server instance
+func NewHealthServer(args ArgsHealthServer) (*healthServer, error) {
+	if len(args.ListenAddress) == 0 {
+		return nil, errEmptyListenAddress
+	}
+	if check.IfNil(args.StatsProvider) {
+		return nil, errNilStatsProvider
+	}
+	if args.MaxReportAge <= 0 {
+		return nil, errInvalidMaxReportAge
+	}
+
+	hs := &healthServer{
+		listenAddress: args.ListenAddress,
+		statsProvider: args.StatsProvider,
+		maxReportAge:  args.MaxReportAge,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", hs.handleHealthz)
+	mux.HandleFunc("/stats", hs.handleStats)
+	if args.ProfilingEnabled {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+	hs.httpServer = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: shutdownTimeout,
+	}
+
+	return hs, nil
+}
+
+// Start opens the listening socket and serves the requests on a separate go routine
+func (hs *healthServer) Start() error {
+	hs.mut.Lock()
+	defer hs.mut.Unlock()
+
+	if hs.listener != nil {
+		return errServerAlreadyStarted
+	}
+
+	listener, err := net.Listen("tcp", hs.listenAddress)
+	if err != nil {
+		return err
+	}
+	hs.listener = listener
+
+	log.Info("health server started", "address", listener.Addr().String())
+
+	go func() {
+		errServe := hs.httpServer.Serve(listener)
+		if errServe != nil && errServe != http.ErrServerClosed {
+			log.Error("health server stopped", "error", errServe)
+		}
+	}()
+
+	return nil
+}
+
+// Address returns the address the server listens on, useful when started on a random port
+func (hs *healthServer) Address() string {
+	hs.mut.Lock()
+	defer hs.mut.Unlock()
+
+	if hs.listener == nil {
+		return ""
+	}
+
+	return hs.listener.Addr().String()
+}
+
+func (hs *healthServer) handleHealthz(w http.ResponseWriter, _ *http.Request) {
+	stats := hs.statsProvider.Stats()
+
+	// before the first successful report, the agent is given the same grace period counted from its start
+	lastReport := stats.LastSuccessfulReportAt
+	if lastReport == 0 {
+		lastReport = stats.StartedAt
+	}
+	age := time.Since(time.Unix(lastReport, 0))
+	if age > hs.maxReportAge {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]interface{}{
+			"status":                 "unhealthy",
+			"lastSuccessfulReportAt": stats.LastSuccessfulReportAt,
+			"lastReportError":        stats.LastReportError,
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":                 "ok",
+		"lastSuccessfulReportAt": stats.LastSuccessfulReportAt,
+	})
+}
+
+func (hs *healthServer) handleStats(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, hs.statsProvider.Stats())
+}
+
+func writeJSON(w http.ResponseWriter, status int, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// Close stops the server
+func (hs *healthServer) Close() error {
+	hs.mut.Lock()
+	defer hs.mut.Unlock()
+
+	if hs.listener == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	return hs.httpServer.Shutdown(ctx)
+}
+
+// IsInterfaceNil returns true if the value under the interface is nil
+func (hs *healthServer) IsInterfaceNil() bool {
+	return hs == nil
+}
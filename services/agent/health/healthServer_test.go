@@ -0,0 +1,147 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/agent/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type statsProviderStub struct {
+	stats common.AgentStats
+}
+
+func (stub *statsProviderStub) Stats() common.AgentStats {
+	return stub.stats
+}
+
+func (stub *statsProviderStub) IsInterfaceNil() bool {
+	return stub == nil
+}
+
+func createMockArgsHealthServer() ArgsHealthServer {
+	return ArgsHealthServer{
+		ListenAddress: "127.0.0.1:0",
+		StatsProvider: &statsProviderStub{},
+		MaxReportAge:  time.Minute,
+	}
+}
+
+func TestNewHealthServer(t *testing.T) {
+	t.Parallel()
+
+	t.Run("empty listen address should error", func(t *testing.T) {
+		args := createMockArgsHealthServer()
+		args.ListenAddress = ""
+
+		server, err := NewHealthServer(args)
+		assert.Nil(t, server)
+		assert.True(t, server.IsInterfaceNil())
+		assert.Equal(t, errEmptyListenAddress, err)
+	})
+	t.Run("nil stats provider should error", func(t *testing.T) {
+		args := createMockArgsHealthServer()
+		args.StatsProvider = nil
+
+		server, err := NewHealthServer(args)
+		assert.Nil(t, server)
+		assert.Equal(t, errNilStatsProvider, err)
+	})
+	t.Run("invalid max report age should error", func(t *testing.T) {
+		args := createMockArgsHealthServer()
+		args.MaxReportAge = 0
+
+		server, err := NewHealthServer(args)
+		assert.Nil(t, server)
+		assert.Equal(t, errInvalidMaxReportAge, err)
+	})
+	t.Run("should work", func(t *testing.T) {
+		server, err := NewHealthServer(createMockArgsHealthServer())
+		assert.NotNil(t, server)
+		assert.False(t, server.IsInterfaceNil())
+		assert.Nil(t, err)
+		assert.Empty(t, server.Address())
+		assert.Nil(t, server.Close())
+	})
+}
+
+func TestHealthServer_Endpoints(t *testing.T) {
+	t.Parallel()
+
+	provider := &statsProviderStub{}
+	args := createMockArgsHealthServer()
+	args.StatsProvider = provider
+	args.ProfilingEnabled = true
+
+	server, _ := NewHealthServer(args)
+	require.Nil(t, server.Start())
+	defer func() {
+		_ = server.Close()
+	}()
+	assert.Equal(t, errServerAlreadyStarted, server.Start())
+
+	baseURL := "http://" + server.Address()
+	getJSON := func(path string, response interface{}) int {
+		resp, err := http.Get(baseURL + path)
+		require.Nil(t, err)
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(response))
+
+		return resp.StatusCode
+	}
+
+	now := time.Now().Unix()
+	provider.stats = common.AgentStats{
+		StartedAt:              now - 3600,
+		NumReports:             10,
+		LastSuccessfulReportAt: now - 10,
+		LastPollResults:        map[string]string{"VM1.Node1.nonce": "10"},
+	}
+
+	health := make(map[string]interface{})
+	assert.Equal(t, http.StatusOK, getJSON("/healthz", &health))
+	assert.Equal(t, "ok", health["status"])
+
+	stats := common.AgentStats{}
+	assert.Equal(t, http.StatusOK, getJSON("/stats", &stats))
+	assert.Equal(t, provider.stats, stats)
+
+	// reports failing for longer than the max report age
+	provider.stats.LastSuccessfulReportAt = now - 120
+	provider.stats.LastReportError = "connection refused"
+	health = make(map[string]interface{})
+	assert.Equal(t, http.StatusServiceUnavailable, getJSON("/healthz", &health))
+	assert.Equal(t, "unhealthy", health["status"])
+	assert.Equal(t, "connection refused", health["lastReportError"])
+
+	// freshly started agent, no report sent yet
+	provider.stats = common.AgentStats{StartedAt: now}
+	health = make(map[string]interface{})
+	assert.Equal(t, http.StatusOK, getJSON("/healthz", &health))
+
+	resp, err := http.Get(baseURL + "/debug/pprof/goroutine?debug=1")
+	require.Nil(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestHealthServer_ProfilingDisabled(t *testing.T) {
+	t.Parallel()
+
+	server, _ := NewHealthServer(createMockArgsHealthServer())
+	require.Nil(t, server.Start())
+	defer func() {
+		_ = server.Close()
+	}()
+
+	resp, err := http.Get("http://" + server.Address() + "/debug/pprof/goroutine?debug=1")
+	require.Nil(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
@@ -0,0 +1,9 @@
+package health
+
+import "github.com/iulianpascalau/api-monitoring/services/agent/common"
+
+// StatsProvider defines the component able to provide the agent's runtime statistics
+type StatsProvider interface {
+	Stats() common.AgentStats
+	IsInterfaceNil() bool
+}
@@ -0,0 +1,92 @@
+package health
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/agent/common"
+	"github.com/iulianpascalau/api-monitoring/services/agent/config"
+)
+
+// statsTracker accumulates the poll and report outcomes of the agent
+type statsTracker struct {
+	mut   sync.RWMutex
+	stats common.AgentStats
+}
+
+// NewStatsTracker creates a new stats tracker instance
+func NewStatsTracker() *statsTracker {
+	return &statsTracker{
+		stats: common.AgentStats{
+			StartedAt:       time.Now().Unix(),
+			LastPollResults: make(map[string]string),
+			LastPollFailed:  make([]string, 0),
+		},
+	}
+}
+
+// RecordPoll records the outcome of a polling round. The endpoints missing from the results are counted as poll errors.
+func (st *statsTracker) RecordPoll(endpoints []config.EndpointConfig, results map[string]common.MetricResult, duration time.Duration) {
+	lastPollResults := make(map[string]string, len(results))
+	for name, result := range results {
+		lastPollResults[name] = result.Value
+	}
+
+	lastPollFailed := make([]string, 0)
+	for _, endpoint := range endpoints {
+		_, found := results[endpoint.Name]
+		if !found {
+			lastPollFailed = append(lastPollFailed, endpoint.Name)
+		}
+	}
+	sort.Strings(lastPollFailed)
+
+	st.mut.Lock()
+	defer st.mut.Unlock()
+
+	st.stats.NumPolls++
+	st.stats.NumPollErrors += uint64(len(lastPollFailed))
+	st.stats.LastPollAt = time.Now().Unix()
+	st.stats.LastPollDurationMs = duration.Milliseconds()
+	st.stats.LastPollResults = lastPollResults
+	st.stats.LastPollFailed = lastPollFailed
+}
+
+// RecordReport records the outcome of a report sent to the aggregation service
+func (st *statsTracker) RecordReport(err error) {
+	st.mut.Lock()
+	defer st.mut.Unlock()
+
+	now := time.Now().Unix()
+	st.stats.NumReports++
+	st.stats.LastReportAt = now
+	if err != nil {
+		st.stats.NumReportErrors++
+		st.stats.LastReportError = err.Error()
+		return
+	}
+
+	st.stats.LastSuccessfulReportAt = now
+	st.stats.LastReportError = ""
+}
+
+// Stats returns a copy of the accumulated statistics
+func (st *statsTracker) Stats() common.AgentStats {
+	st.mut.RLock()
+	defer st.mut.RUnlock()
+
+	stats := st.stats
+	stats.LastPollResults = make(map[string]string, len(st.stats.LastPollResults))
+	for name, value := range st.stats.LastPollResults {
+		stats.LastPollResults[name] = value
+	}
+	stats.LastPollFailed = append(make([]string, 0, len(st.stats.LastPollFailed)), st.stats.LastPollFailed...)
+
+	return stats
+}
+
+// IsInterfaceNil returns true if the value under the interface is nil
+func (st *statsTracker) IsInterfaceNil() bool {
+	return st == nil
+}
@@ -0,0 +1,59 @@
+package health
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/agent/common"
+	"github.com/iulianpascalau/api-monitoring/services/agent/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatsTracker(t *testing.T) {
+	t.Parallel()
+
+	tracker := NewStatsTracker()
+	assert.False(t, tracker.IsInterfaceNil())
+
+	stats := tracker.Stats()
+	assert.NotZero(t, stats.StartedAt)
+	assert.Zero(t, stats.NumPolls)
+	assert.Empty(t, stats.LastPollResults)
+
+	endpoints := []config.EndpointConfig{
+		{Name: "VM1.Node2.nonce"},
+		{Name: "VM1.Node1.nonce"},
+		{Name: "VM1.Node1.epoch"},
+	}
+	results := map[string]common.MetricResult{
+		"VM1.Node1.nonce": {Value: "10"},
+	}
+	tracker.RecordPoll(endpoints, results, 1500*time.Millisecond)
+	tracker.RecordReport(errors.New("expected error"))
+
+	stats = tracker.Stats()
+	assert.Equal(t, uint64(1), stats.NumPolls)
+	assert.Equal(t, uint64(2), stats.NumPollErrors)
+	assert.NotZero(t, stats.LastPollAt)
+	assert.Equal(t, int64(1500), stats.LastPollDurationMs)
+	assert.Equal(t, map[string]string{"VM1.Node1.nonce": "10"}, stats.LastPollResults)
+	assert.Equal(t, []string{"VM1.Node1.epoch", "VM1.Node2.nonce"}, stats.LastPollFailed)
+	assert.Equal(t, uint64(1), stats.NumReports)
+	assert.Equal(t, uint64(1), stats.NumReportErrors)
+	assert.NotZero(t, stats.LastReportAt)
+	assert.Zero(t, stats.LastSuccessfulReportAt)
+	assert.Equal(t, "expected error", stats.LastReportError)
+
+	// the returned stats are a copy
+	stats.LastPollResults["VM1.Node1.nonce"] = "11"
+	assert.Equal(t, "10", tracker.Stats().LastPollResults["VM1.Node1.nonce"])
+
+	tracker.RecordReport(nil)
+
+	stats = tracker.Stats()
+	assert.Equal(t, uint64(2), stats.NumReports)
+	assert.Equal(t, uint64(1), stats.NumReportErrors)
+	assert.NotZero(t, stats.LastSuccessfulReportAt)
+	assert.Empty(t, stats.LastReportError)
+}
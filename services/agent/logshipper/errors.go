@@ -0,0 +1,9 @@
+package logshipper
+
+import "errors"
+
+var (
+	errInvalidEndpoint   = errors.New("invalid log shipping endpoint")
+	errInvalidBufferSize = errors.New("invalid log shipping buffer size")
+	errUnexpectedStatus  = errors.New("unexpected status code")
+)
@@ -0,0 +1,213 @@
+package logshipper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/commonGo"
+	"github.com/iulianpascalau/api-monitoring/services/agent/common"
+	logger "github.com/multiversx/mx-chain-logger-go"
+)
+
+var log = logger.GetOrCreate("logshipper")
+
+const (
+	defaultTimeout = 10 * time.Second
+	// maxEntriesPerRequest is the maximum number of entries the aggregation service accepts in a request
+	maxEntriesPerRequest = 100
+)
+
+// ArgsLogShipper defines the DTO struct for the NewLogShipper constructor function
+type ArgsLogShipper struct {
+	// Endpoint is the aggregation service URL receiving the entries, e.g. https://host/api/agents/VM1/logs
+	Endpoint string
+	ApiKey   string
+	Timeout  time.Duration
+	// BufferSize is the maximum number of entries kept between two flushes, the oldest are dropped first
+	BufferSize int
+	// Transport sends the requests, nil uses http.DefaultTransport
+	Transport http.RoundTripper
+}
+
+// logShipper observes the agent log output and forwards the warnings and the errors to the aggregation service. It is
+// both the writer and the formatter of its log observer: the formatter captures the entries, the writer discards the
+// (empty) formatted output.
+type logShipper struct {
+	endpoint   string
+	apiKey     string
+	client     *http.Client
+	bufferSize int
+
+	mut        sync.Mutex
+	entries    []common.LogEntry
+	numDropped int
+}
+
+// NewLogShipper creates a new log shipper and registers it as a log observer
+func NewLogShipper(args ArgsLogShipper) (*logShipper, error) {
+	if !commonGo.IsHTTPURL(args.Endpoint) {
+		return nil, fmt.Errorf("%w: %q", errInvalidEndpoint, args.Endpoint)
+	}
+	if args.BufferSize < 1 {
+		return nil, fmt.Errorf("%w: %d", errInvalidBufferSize, args.BufferSize)
+	}
+
+	shipper := &logShipper{
+		endpoint:   args.Endpoint,
+		apiKey:     args.ApiKey,
+		client:     &http.Client{Timeout: args.Timeout, Transport: args.Transport},
+		bufferSize: args.BufferSize,
+	}
+	if shipper.client.Timeout <= 0 {
+		shipper.client.Timeout = defaultTimeout
+	}
+
+	err := logger.AddLogObserver(shipper, shipper)
+	if err != nil {
+		return nil, err
+	}
+
+	return shipper, nil
+}
+
+// Output captures the warnings and the errors, it returns no formatted output. It must not log: it is called while
+// the log subsystem holds its observers lock.
+func (shipper *logShipper) Output(line logger.LogLineHandler) []byte {
+	if line == nil || line.GetLogLevel() < int32(logger.LogWarning) {
+		return nil
+	}
+
+	entry := common.LogEntry{
+		Timestamp: time.Unix(0, line.GetTimestamp()).Unix(),
+		Level:     strings.ToLower(strings.TrimSpace(logger.LogLevel(line.GetLogLevel()).String())),
+		Component: line.GetLoggerName(),
+		Message:   line.GetMessage(),
+	}
+	args := line.GetArgs()
+	for i := 1; i < len(args); i += 2 {
+		if entry.Fields == nil {
+			entry.Fields = make(map[string]string, len(args)/2)
+		}
+		entry.Fields[args[i-1]] = args[i]
+	}
+
+	shipper.mut.Lock()
+	shipper.entries = append(shipper.entries, entry)
+	shipper.trimBuffer()
+	shipper.mut.Unlock()
+
+	return nil
+}
+
+// trimBuffer drops the oldest entries above the buffer size, the mutex must be held
+func (shipper *logShipper) trimBuffer() {
+	numExtra := len(shipper.entries) - shipper.bufferSize
+	if numExtra <= 0 {
+		return
+	}
+
+	shipper.entries = append(shipper.entries[:0], shipper.entries[numExtra:]...)
+	shipper.numDropped += numExtra
+}
+
+// Write discards the formatted output, the entries are captured by Output
+func (shipper *logShipper) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+// Flush sends the buffered entries to the aggregation service. The entries that could not be sent are kept for the
+// next flush. The failures are only logged at the debug level, so they are not shipped in turn.
+func (shipper *logShipper) Flush(ctx context.Context) {
+	shipper.mut.Lock()
+	numDropped := shipper.numDropped
+	shipper.numDropped = 0
+	shipper.mut.Unlock()
+	if numDropped > 0 {
+		log.Debug("log entries dropped, the buffer was full", "num entries", numDropped)
+	}
+
+	for {
+		batch := shipper.takeBatch()
+		if len(batch) == 0 {
+			return
+		}
+
+		err := shipper.send(ctx, batch)
+		if err != nil {
+			log.Debug("failed to ship the log entries", "endpoint", shipper.endpoint, "num entries", len(batch),
+				"error", err)
+			shipper.putBack(batch)
+			return
+		}
+	}
+}
+
+func (shipper *logShipper) takeBatch() []common.LogEntry {
+	shipper.mut.Lock()
+	defer shipper.mut.Unlock()
+
+	numEntries := len(shipper.entries)
+	if numEntries > maxEntriesPerRequest {
+		numEntries = maxEntriesPerRequest
+	}
+	batch := make([]common.LogEntry, numEntries)
+	copy(batch, shipper.entries)
+	shipper.entries = append(shipper.entries[:0], shipper.entries[numEntries:]...)
+
+	return batch
+}
+
+// putBack returns the unsent entries in front of the ones logged in the meantime
+func (shipper *logShipper) putBack(batch []common.LogEntry) {
+	shipper.mut.Lock()
+	defer shipper.mut.Unlock()
+
+	shipper.entries = append(batch, shipper.entries...)
+	shipper.trimBuffer()
+}
+
+func (shipper *logShipper) send(ctx context.Context, batch []common.LogEntry) error {
+	body, err := json.Marshal(common.LogsPayload{Entries: batch})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, shipper.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Api-Key", shipper.apiKey)
+	req.Header.Set(commonGo.RequestIDHeader, commonGo.NewRequestID())
+
+	resp, err := shipper.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%w %d, request ID %s", errUnexpectedStatus, resp.StatusCode,
+			req.Header.Get(commonGo.RequestIDHeader))
+	}
+
+	return nil
+}
+
+// Close stops observing the log output
+func (shipper *logShipper) Close() error {
+	return logger.RemoveLogObserver(shipper)
+}
+
+// IsInterfaceNil returns true if there is no value under the interface
+func (shipper *logShipper) IsInterfaceNil() bool {
+	return shipper == nil
+}
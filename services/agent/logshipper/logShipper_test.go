@@ -0,0 +1,187 @@
+package logshipper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/agent/common"
+	logger "github.com/multiversx/mx-chain-logger-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type logsServerMock struct {
+	mut        sync.Mutex
+	statusCode int
+	apiKeys    []string
+	payloads   []common.LogsPayload
+}
+
+func (mock *logsServerMock) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	mock.mut.Lock()
+	defer mock.mut.Unlock()
+
+	if mock.statusCode != http.StatusOK {
+		w.WriteHeader(mock.statusCode)
+		return
+	}
+
+	payload := common.LogsPayload{}
+	_ = json.NewDecoder(r.Body).Decode(&payload)
+	mock.payloads = append(mock.payloads, payload)
+	mock.apiKeys = append(mock.apiKeys, r.Header.Get("X-Api-Key"))
+}
+
+func (mock *logsServerMock) setStatusCode(statusCode int) {
+	mock.mut.Lock()
+	mock.statusCode = statusCode
+	mock.mut.Unlock()
+}
+
+func (mock *logsServerMock) entries() []common.LogEntry {
+	mock.mut.Lock()
+	defer mock.mut.Unlock()
+
+	entries := make([]common.LogEntry, 0)
+	for _, payload := range mock.payloads {
+		entries = append(entries, payload.Entries...)
+	}
+
+	return entries
+}
+
+func createLogsServer(t *testing.T) (*logsServerMock, string) {
+	mock := &logsServerMock{statusCode: http.StatusOK}
+	server := httptest.NewServer(mock)
+	t.Cleanup(server.Close)
+
+	return mock, server.URL + "/api/agents/VM1/logs"
+}
+
+func createShipper(t *testing.T, endpoint string, bufferSize int) *logShipper {
+	shipper, err := NewLogShipper(ArgsLogShipper{
+		Endpoint:   endpoint,
+		ApiKey:     "key",
+		BufferSize: bufferSize,
+	})
+	require.Nil(t, err)
+	t.Cleanup(func() {
+		_ = shipper.Close()
+	})
+
+	return shipper
+}
+
+func createLine(level logger.LogLevel, message string, args ...string) *logger.LogLineWrapper {
+	line := &logger.LogLineWrapper{}
+	line.LoggerName = "poller"
+	line.LogLevel = int32(level)
+	line.Message = message
+	line.Args = args
+	line.Timestamp = time.Unix(1000, 0).UnixNano()
+
+	return line
+}
+
+func TestNewLogShipper(t *testing.T) {
+	t.Run("invalid endpoint should error", func(t *testing.T) {
+		shipper, err := NewLogShipper(ArgsLogShipper{Endpoint: "ftp://host/logs", BufferSize: 10})
+		assert.Nil(t, shipper)
+		assert.True(t, errors.Is(err, errInvalidEndpoint))
+	})
+	t.Run("invalid buffer size should error", func(t *testing.T) {
+		shipper, err := NewLogShipper(ArgsLogShipper{Endpoint: "https://host/logs"})
+		assert.Nil(t, shipper)
+		assert.True(t, errors.Is(err, errInvalidBufferSize))
+	})
+	t.Run("should work", func(t *testing.T) {
+		shipper := createShipper(t, "https://host/logs", 10)
+		assert.False(t, shipper.IsInterfaceNil())
+		assert.Equal(t, defaultTimeout, shipper.client.Timeout)
+	})
+}
+
+func TestLogShipper_ShipsTheWarningsAndErrors(t *testing.T) {
+	mock, endpoint := createLogsServer(t)
+	shipper := createShipper(t, endpoint, 10)
+
+	testLog := logger.GetOrCreate("logshipper/test")
+	testLog.Info("not shipped")
+	testLog.Warn("endpoint failed", "metric", "VM1.Active", "error", "timeout")
+	testLog.Error("report failed")
+
+	shipper.Flush(context.Background())
+
+	shipped := make([]common.LogEntry, 0)
+	for _, entry := range mock.entries() {
+		if entry.Component == "logshipper/test" {
+			shipped = append(shipped, entry)
+		}
+	}
+	require.Len(t, shipped, 2)
+	assert.Equal(t, "warn", shipped[0].Level)
+	assert.Equal(t, "endpoint failed", shipped[0].Message)
+	assert.Equal(t, map[string]string{"metric": "VM1.Active", "error": "timeout"}, shipped[0].Fields)
+	assert.InDelta(t, time.Now().Unix(), shipped[0].Timestamp, 5)
+	assert.Equal(t, "error", shipped[1].Level)
+	assert.Equal(t, "report failed", shipped[1].Message)
+	assert.Equal(t, "key", mock.apiKeys[0])
+
+	// the observer is removed on close
+	require.Nil(t, shipper.Close())
+	testLog.Warn("after close")
+	assert.Empty(t, shipper.takeBatch())
+}
+
+func TestLogShipper_Output(t *testing.T) {
+	shipper := createShipper(t, "https://host/logs", 2)
+
+	assert.Nil(t, shipper.Output(nil))
+	assert.Nil(t, shipper.Output(createLine(logger.LogDebug, "debug")))
+	assert.Nil(t, shipper.Output(createLine(logger.LogWarning, "first", "name", "VM1")))
+	assert.Nil(t, shipper.Output(createLine(logger.LogError, "second")))
+	assert.Nil(t, shipper.Output(createLine(logger.LogError, "third")))
+
+	// the oldest entry is dropped once the buffer is full
+	batch := shipper.takeBatch()
+	require.Len(t, batch, 2)
+	assert.Equal(t, "second", batch[0].Message)
+	assert.Equal(t, "third", batch[1].Message)
+	assert.Equal(t, int64(1000), batch[0].Timestamp)
+	assert.Equal(t, "poller", batch[0].Component)
+	assert.Equal(t, 1, shipper.numDropped)
+}
+
+func TestLogShipper_FlushKeepsTheUnsentEntries(t *testing.T) {
+	mock, endpoint := createLogsServer(t)
+	shipper := createShipper(t, endpoint, 3*maxEntriesPerRequest)
+	// the real log lines of the other components are not part of this test
+	_ = shipper.Close()
+
+	mock.setStatusCode(http.StatusServiceUnavailable)
+	for i := 0; i < maxEntriesPerRequest+10; i++ {
+		shipper.Output(createLine(logger.LogWarning, fmt.Sprintf("message %d", i)))
+	}
+	shipper.Flush(context.Background())
+	assert.Empty(t, mock.entries())
+
+	mock.setStatusCode(http.StatusOK)
+	shipper.Output(createLine(logger.LogError, "last"))
+	shipper.Flush(context.Background())
+
+	entries := mock.entries()
+	require.Len(t, entries, maxEntriesPerRequest+11)
+	assert.Equal(t, "message 0", entries[0].Message)
+	assert.Equal(t, "last", entries[maxEntriesPerRequest+10].Message)
+	mock.mut.Lock()
+	assert.Len(t, mock.payloads, 2)
+	mock.mut.Unlock()
+	assert.Empty(t, shipper.takeBatch())
+}
@@ -1,14 +1,18 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
 	"runtime"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/iulianpascalau/api-monitoring/commonGo"
+	"github.com/iulianpascalau/api-monitoring/services/agent/common"
 	"github.com/iulianpascalau/api-monitoring/services/agent/config"
 	"github.com/iulianpascalau/api-monitoring/services/agent/factory"
 	"github.com/multiversx/mx-chain-core-go/core/check"
@@ -19,11 +23,13 @@ import (
 const (
 	defaultLogsPath      = "logs"
 	logFilePrefix        = "agent"
+	logServiceName       = "agent"
 	logFileLifeSpanInSec = 86400 // 24h
 	logFileLifeSpanInMB  = 1024  // 1GB
 	configFile           = "./config.toml"
 	envFile              = "./.env"
 	envServiceKey        = "SERVICE_KEY"
+	envOverridesPrefix   = "AGENT" // e.g. AGENT_REPORT_ENDPOINT overrides ReportEndpoint
 )
 
 // appVersion should be populated at build time using ldflags
@@ -32,17 +38,23 @@ const (
 //
 //	go build -v -ldflags="-X main.appVersion=$(git describe --all | cut -c7-32)
 var appVersion = "undefined"
+
+// commitID should be populated at build time using ldflags, e.g. -X main.commitID=$(git rev-parse HEAD)
+var commitID = "undefined"
 var fileLogging commonGo.FileLoggingHandler
 
 var (
 	proxyHelpTemplate = `NAME:
    {{.Name}} - {{.Usage}}
 USAGE:
-   {{.HelpName}} {{if .VisibleFlags}}[global options]{{end}}
+   {{.HelpName}} {{if .VisibleFlags}}[global options]{{end}} [command [command options]]
    {{if len .Authors}}
 AUTHOR:
    {{range .Authors}}{{ . }}{{end}}
    {{end}}{{if .Commands}}
+COMMANDS:
+   {{range .VisibleCommands}}{{join .Names ", "}}{{"\t"}}{{.Usage}}
+   {{end}}
 GLOBAL OPTIONS:
    {{range .VisibleFlags}}{{.}}
    {{end}}
@@ -51,7 +63,7 @@ VERSION:
    {{end}}
 `
 
-	log = logger.GetOrCreate("proxy")
+	log = logger.GetOrCreate("main")
 
 	// logLevel defines the logger level
 	logLevel = cli.StringFlag{
@@ -62,6 +74,14 @@ VERSION:
 			" log level.",
 		Value: "*:" + logger.LogInfo.String(),
 	}
+	// logFormat defines the format of the logs written to the standard output
+	logFormat = cli.StringFlag{
+		Name: "log-format",
+		Usage: "This flag specifies the `format` of the logs written to the standard output: console or json. The json " +
+			"format writes a JSON object per line, for the log aggregators (Loki, Elasticsearch). The log file keeps " +
+			"the plain format.",
+		Value: commonGo.LogFormatConsole,
+	}
 	// logFile is used when the log output needs to be logged in a file
 	logSaveFile = cli.BoolFlag{
 		Name:  "log-save",
@@ -74,6 +94,18 @@ VERSION:
 		Value: "",
 	}
 
+	// healthCheckURL overrides the health endpoint queried by the healthcheck subcommand
+	healthCheckURL = cli.StringFlag{
+		Name:  "url",
+		Usage: "The health endpoint `URL`. Defaults to the /healthz endpoint of the [HealthServer] listener.",
+	}
+	// healthCheckTimeout bounds the health endpoint query of the healthcheck subcommand
+	healthCheckTimeout = cli.DurationFlag{
+		Name:  "timeout",
+		Usage: "The `duration` after which the health check fails.",
+		Value: 5 * time.Second,
+	}
+
 	envFileContents = map[string]*commonGo.EnvValue{
 		envServiceKey: {Value: "", Required: true},
 	}
@@ -87,6 +119,7 @@ func main() {
 	app.Usage = "This is the entry point for starting a new agent that can collect metrics from defined API URLs and send them to a centralized URL"
 	app.Flags = []cli.Flag{
 		logLevel,
+		logFormat,
 		logSaveFile,
 		workingDirectory,
 	}
@@ -98,6 +131,22 @@ func main() {
 	}
 
 	app.Action = run
+	app.Commands = []cli.Command{
+		{
+			Name: "healthcheck",
+			Usage: "Query the local health endpoint and exit with 0 if the agent is healthy, 1 otherwise, for the " +
+				"Docker HEALTHCHECK directives. Requires the [HealthServer] to be enabled.",
+			Flags:  []cli.Flag{healthCheckURL, healthCheckTimeout},
+			Action: healthCheck,
+		},
+		{
+			Name:    "validate",
+			Aliases: []string{"dry-run"},
+			Usage: "Load and validate the config, poll each endpoint once, print the extracted values and the failures " +
+				"and exit with 0 if all endpoints were polled successfully, 1 otherwise. Nothing is reported.",
+			Action: validate,
+		},
+	}
 
 	defer func() {
 		if fileLogging != nil {
@@ -112,6 +161,52 @@ func main() {
 	}
 }
 
+func healthCheck(ctx *cli.Context) error {
+	url := ctx.String(healthCheckURL.Name)
+	if len(url) == 0 {
+		cfg, err := config.LoadConfig(configFile)
+		if err != nil {
+			return err
+		}
+		_, err = commonGo.ApplyEnvOverrides(cfg, envOverridesPrefix, os.LookupEnv)
+		if err != nil {
+			return err
+		}
+		if !cfg.HealthServer.Enabled {
+			return errors.New("the health server is disabled, enable [HealthServer] in the config")
+		}
+
+		url, err = commonGo.LocalHealthCheckURL(cfg.HealthServer.ListenAddress, "")
+		if err != nil {
+			return err
+		}
+	}
+
+	return commonGo.CheckHealth(url, ctx.Duration(healthCheckTimeout.Name))
+}
+
+func validate(_ *cli.Context) error {
+	cfg, err := config.LoadConfig(configFile)
+	if err != nil {
+		return err
+	}
+	_, err = commonGo.ApplyEnvOverrides(cfg, envOverridesPrefix, os.LookupEnv)
+	if err != nil {
+		return err
+	}
+	err = cfg.Validate()
+	if err != nil {
+		return err
+	}
+
+	poller, err := factory.CreatePoller(*cfg)
+	if err != nil {
+		return err
+	}
+
+	return factory.DryRun(context.Background(), poller, cfg.Endpoints, os.Stdout)
+}
+
 func run(ctx *cli.Context) error {
 	saveLogFile := ctx.GlobalBool(logSaveFile.Name)
 	workingDir := ctx.GlobalString(workingDirectory.Name)
@@ -121,6 +216,11 @@ func run(ctx *cli.Context) error {
 		return err
 	}
 
+	err = commonGo.SetLogFormat(ctx.GlobalString(logFormat.Name), logServiceName)
+	if err != nil {
+		return err
+	}
+
 	fileLogging, err = commonGo.AttachFileLogger(log, defaultLogsPath, logFilePrefix, saveLogFile, workingDir)
 	if err != nil {
 		return err
@@ -135,7 +235,7 @@ func run(ctx *cli.Context) error {
 		}
 	}
 
-	log.Info("Starting agent", "version", appVersion, "pid", os.Getpid())
+	log.Info("Starting agent", "version", appVersion, "commit", commitID, "pid", os.Getpid())
 
 	err = commonGo.ReadEnvFile(envFile, envFileContents)
 	if err != nil {
@@ -147,8 +247,31 @@ func run(ctx *cli.Context) error {
 		return err
 	}
 
+	overrides, err := commonGo.ApplyEnvOverrides(cfg, envOverridesPrefix, os.LookupEnv)
+	if err != nil {
+		return err
+	}
+	if len(overrides) > 0 {
+		log.Info("config values overridden from the environment", "variables", strings.Join(overrides, ", "))
+	}
+
+	err = cfg.Validate()
+	if err != nil {
+		return err
+	}
+
 	serviceKey := envFileContents[envServiceKey].Value
-	components, err := factory.NewComponentsHandler(serviceKey, *cfg)
+	buildInfo := common.BuildInfo{
+		Version: appVersion,
+		Commit:  commitID,
+	}
+	components, err := factory.NewComponentsHandler(serviceKey, *cfg, buildInfo)
+	if err != nil {
+		return err
+	}
+
+	// resolved before any update moves the running binary aside
+	executablePath, err := os.Executable()
 	if err != nil {
 		return err
 	}
@@ -160,10 +283,23 @@ func run(ctx *cli.Context) error {
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
 
-	<-sigs
+	installedVersion := ""
+	select {
+	case <-sigs:
+	case installedVersion = <-components.UpdateInstalled():
+	}
 
 	log.Info("Application closing, calling Close on all subcomponents...")
 	components.Close()
 
-	return nil
+	if len(installedVersion) == 0 {
+		return nil
+	}
+
+	log.Info("restarting the agent to run the installed release", "version", installedVersion)
+	if fileLogging != nil {
+		_ = fileLogging.Close()
+	}
+
+	return restart(executablePath)
 }
@@ -1,6 +1,9 @@
 package poller
 
-import "net/http"
+import (
+	"errors"
+	"net/http"
+)
 
 type errStatusNotOK int
 
@@ -13,3 +16,25 @@ type errPathNotFound string
 func (e errPathNotFound) Error() string {
 	return "JSON path not found in response: " + string(e)
 }
+
+type errSelectorNotFound string
+
+func (e errSelectorNotFound) Error() string {
+	return "CSS selector not found in page: " + string(e)
+}
+
+type errNotANumber string
+
+func (e errNotANumber) Error() string {
+	return "value is not a number: " + string(e)
+}
+
+type errUnmappedValue string
+
+func (e errUnmappedValue) Error() string {
+	return "value is missing from the mapping: " + string(e)
+}
+
+var errDivisionByZero = errors.New("division by zero")
+
+var errTransformFailed = errors.New("value transform failed")
@@ -0,0 +1,48 @@
+package poller
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/iulianpascalau/api-monitoring/services/agent/config"
+	"github.com/tidwall/gjson"
+)
+
+// extractValue reads the endpoint value from the response body, according to the endpoint kind
+func extractValue(body []byte, ep config.EndpointConfig) (string, error) {
+	if ep.Kind == config.EndpointKindHTML {
+		return extractHTMLValue(body, ep.Value, ep.Attribute)
+	}
+
+	// Use gjson to extract the path (e.g. "data.status.erd_nonce")
+	result := gjson.GetBytes(body, ep.Value)
+	if !result.Exists() {
+		return "", errPathNotFound(ep.Value)
+	}
+
+	return result.String(), nil
+}
+
+// extractHTMLValue returns the trimmed text, or the attribute, of the first element matching the CSS selector
+func extractHTMLValue(body []byte, selector string, attribute string) (string, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+
+	selection := doc.Find(selector).First()
+	if selection.Length() == 0 {
+		return "", errSelectorNotFound(selector)
+	}
+	if len(attribute) == 0 {
+		return strings.TrimSpace(selection.Text()), nil
+	}
+
+	val, found := selection.Attr(attribute)
+	if !found {
+		return "", errSelectorNotFound(selector + " [" + attribute + "]")
+	}
+
+	return strings.TrimSpace(val), nil
+}
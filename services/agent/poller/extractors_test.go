@@ -0,0 +1,83 @@
+package poller
+
+import (
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/services/agent/config"
+	"github.com/stretchr/testify/assert"
+)
+
+const testPage = `<html><body>
+<div id="stats">
+	<span class="nonce" data-value="123456">
+		123,456
+	</span>
+	<span class="nonce">7</span>
+</div>
+</body></html>`
+
+func TestExtractValue(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		body        string
+		ep          config.EndpointConfig
+		expected    string
+		expectedErr error
+	}{
+		{
+			name:     "json path",
+			body:     `{"data": {"nonce": 12}}`,
+			ep:       config.EndpointConfig{Value: "data.nonce"},
+			expected: "12",
+		},
+		{
+			name:     "explicit json kind",
+			body:     `{"data": {"nonce": 12}}`,
+			ep:       config.EndpointConfig{Value: "data.nonce", Kind: config.EndpointKindJSON},
+			expected: "12",
+		},
+		{
+			name:        "missing json path",
+			body:        `{"data": {}}`,
+			ep:          config.EndpointConfig{Value: "data.nonce"},
+			expectedErr: errPathNotFound("data.nonce"),
+		},
+		{
+			name:     "text of the first match",
+			body:     testPage,
+			ep:       config.EndpointConfig{Value: "#stats .nonce", Kind: config.EndpointKindHTML},
+			expected: "123,456",
+		},
+		{
+			name:     "attribute of the first match",
+			body:     testPage,
+			ep:       config.EndpointConfig{Value: "#stats .nonce", Kind: config.EndpointKindHTML, Attribute: "data-value"},
+			expected: "123456",
+		},
+		{
+			name:        "missing selector",
+			body:        testPage,
+			ep:          config.EndpointConfig{Value: "#stats .epoch", Kind: config.EndpointKindHTML},
+			expectedErr: errSelectorNotFound("#stats .epoch"),
+		},
+		{
+			name:        "missing attribute",
+			body:        testPage,
+			ep:          config.EndpointConfig{Value: "#stats .nonce", Kind: config.EndpointKindHTML, Attribute: "title"},
+			expectedErr: errSelectorNotFound("#stats .nonce [title]"),
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			val, err := extractValue([]byte(tt.body), tt.ep)
+			assert.Equal(t, tt.expectedErr, err)
+			assert.Equal(t, tt.expected, val)
+		})
+	}
+}
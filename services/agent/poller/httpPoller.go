@@ -2,30 +2,120 @@ package poller
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
+	"math/rand"
 	"net/http"
+	"net/url"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/iulianpascalau/api-monitoring/commonGo"
+	"github.com/iulianpascalau/api-monitoring/commonGo/clock"
 	"github.com/iulianpascalau/api-monitoring/services/agent/common"
 	"github.com/iulianpascalau/api-monitoring/services/agent/config"
+	"github.com/multiversx/mx-chain-core-go/core/check"
 	logger "github.com/multiversx/mx-chain-logger-go"
-	"github.com/tidwall/gjson"
 )
 
 var log = logger.GetOrCreate("poller")
 
+const defaultRetryBackoff = 500 * time.Millisecond
+
+// ArgsHTTPPoller defines the DTO struct for the NewHTTPPoller constructor function
+type ArgsHTTPPoller struct {
+	// Timeout is the poll timeout of the endpoints without their own TimeoutInSeconds
+	Timeout time.Duration
+	// Spread staggers the endpoint polls evenly over this window, instead of firing them all at once
+	Spread time.Duration
+	// Jitter adds a random delay, up to this value, to each endpoint poll
+	Jitter time.Duration
+	// MaxConcurrency bounds the number of simultaneous endpoint polls, 0 means unlimited
+	MaxConcurrency int
+	// ProxyURL is the proxy of the endpoints without their own ProxyURL, empty uses the proxy environment variables
+	ProxyURL string
+	// IPFamily restricts the connections to ipv4 or ipv6, empty allows both
+	IPFamily string
+	// Clock timestamps the polled values, nil uses the system clock
+	Clock Clock
+}
+
+// clientKey identifies the HTTP clients of the poller. The traced polls do not reuse the connections, so each one
+// measures the DNS lookup and the handshakes.
+type clientKey struct {
+	proxyURL string
+	traced   bool
+}
+
 type httpPoller struct {
-	client *http.Client
+	proxyURL   string
+	ipFamily   string
+	mutClients sync.Mutex
+	clients    map[clientKey]*http.Client
+	timeout    time.Duration
+	spread     time.Duration
+	jitter     time.Duration
+	semaphore  chan struct{}
+	clock      Clock
 }
 
-// NewHTTPPoller creates a new HTTP-based poller with a default timeout
-func NewHTTPPoller(timeout time.Duration) *httpPoller {
-	return &httpPoller{
-		client: &http.Client{
-			Timeout: timeout,
-		},
+// NewHTTPPoller creates a new HTTP-based poller
+func NewHTTPPoller(args ArgsHTTPPoller) (*httpPoller, error) {
+	p := &httpPoller{
+		proxyURL: args.ProxyURL,
+		ipFamily: args.IPFamily,
+		clients:  make(map[clientKey]*http.Client),
+		timeout:  args.Timeout,
+		spread:   args.Spread,
+		jitter:   args.Jitter,
+		clock:    args.Clock,
+	}
+	if check.IfNil(p.clock) {
+		p.clock = clock.NewSystemClock()
+	}
+	if args.MaxConcurrency > 0 {
+		p.semaphore = make(chan struct{}, args.MaxConcurrency)
 	}
+
+	// fails early on an invalid proxy or IP family
+	_, err := p.clientFor(config.EndpointConfig{}, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// clientFor returns the HTTP client of the endpoint, going through its proxy, created on first use
+func (p *httpPoller) clientFor(ep config.EndpointConfig, traced bool) (*http.Client, error) {
+	key := clientKey{proxyURL: p.proxyURL, traced: traced}
+	if len(ep.ProxyURL) > 0 {
+		key.proxyURL = ep.ProxyURL
+	}
+
+	p.mutClients.Lock()
+	defer p.mutClients.Unlock()
+
+	client, found := p.clients[key]
+	if found {
+		return client, nil
+	}
+
+	transport, err := commonGo.NewHTTPTransport(commonGo.ArgsHTTPTransport{
+		ProxyURL:          key.proxyURL,
+		IPFamily:          p.ipFamily,
+		DisableKeepAlives: traced,
+	})
+	if err != nil {
+		return nil, err
+	}
+	client = &http.Client{Transport: transport}
+	p.clients[key] = client
+
+	return client, nil
 }
 
 // PollAll performs concurrent HTTP GETs to all configured endpoints and extracts exactly the JSON sub-path.
@@ -35,59 +125,245 @@ func (p *httpPoller) PollAll(ctx context.Context, endpoints []config.EndpointCon
 	var wg sync.WaitGroup
 
 	wg.Add(len(endpoints))
-	for _, ep := range endpoints {
-		go func(endpoint config.EndpointConfig) {
+	for i, ep := range endpoints {
+		go func(endpoint config.EndpointConfig, delay time.Duration) {
 			defer wg.Done()
 
-			val, err := p.pollEndpoint(ctx, endpoint)
+			if !waitDelay(ctx, delay) || !p.acquire(ctx) {
+				log.Warn("endpoint poll cancelled", "metric", endpoint.Name, "error", ctx.Err())
+				return
+			}
+			defer p.release()
+
+			var timings *requestTimings
+			if endpoint.TraceTimings {
+				timings = &requestTimings{}
+			}
+			val, err := p.pollEndpoint(ctx, endpoint, timings)
 			if err != nil {
-				log.Warn("endpoint poll failed", "name", endpoint.Name, "url", endpoint.URL, "error", err)
+				log.Warn("endpoint poll failed", "metric", endpoint.Name, "url", endpoint.URL, "error", err)
 				return // Omits from report
 			}
 
+			polledAt := p.clock.Now().Unix()
 			mu.Lock()
 			results[endpoint.Name] = common.MetricResult{
-				Config: endpoint,
-				Value:  val,
+				Config:   endpoint,
+				Value:    val,
+				PolledAt: polledAt,
+			}
+			for name, result := range timings.results(endpoint, polledAt) {
+				results[name] = result
 			}
 			mu.Unlock()
-		}(ep)
+		}(ep, p.pollDelay(i, len(endpoints)))
 	}
 
 	wg.Wait()
 	return results
 }
 
-func (p *httpPoller) pollEndpoint(ctx context.Context, ep config.EndpointConfig) (string, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ep.URL, nil)
+// Poll polls a single endpoint right away, with its retries, returning the extracted value or the reason of the failure
+func (p *httpPoller) Poll(ctx context.Context, endpoint config.EndpointConfig) (string, error) {
+	return p.pollEndpoint(ctx, endpoint, nil)
+}
+
+// pollDelay returns the delay of the i-th endpoint poll: its slot in the spread window plus the random jitter, so the
+// polls do not all hit the monitored node APIs at the same instant
+func (p *httpPoller) pollDelay(i int, numEndpoints int) time.Duration {
+	delay := time.Duration(0)
+	if p.spread > 0 {
+		delay = p.spread * time.Duration(i) / time.Duration(numEndpoints)
+	}
+	if p.jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(p.jitter)))
+	}
+
+	return delay
+}
+
+// acquire waits for a free polling slot, it returns false if the context ends first
+func (p *httpPoller) acquire(ctx context.Context) bool {
+	if p.semaphore == nil {
+		return true
+	}
+
+	select {
+	case p.semaphore <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (p *httpPoller) release() {
+	if p.semaphore == nil {
+		return
+	}
+
+	<-p.semaphore
+}
+
+func waitDelay(ctx context.Context, delay time.Duration) bool {
+	if delay <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// pollEndpoint polls the endpoint, with the retries, the timings of the last attempt are recorded if not nil
+func (p *httpPoller) pollEndpoint(ctx context.Context, ep config.EndpointConfig, timings *requestTimings) (string, error) {
+	timeout := p.timeout
+	if ep.TimeoutInSeconds > 0 {
+		timeout = time.Duration(ep.TimeoutInSeconds) * time.Second
+	}
+	if timeout > 0 {
+		var cancel func()
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	backoff := time.Duration(ep.RetryBackoffInMilliseconds) * time.Millisecond
+	if backoff == 0 {
+		backoff = defaultRetryBackoff
+	}
+	for attempt := uint32(0); ; attempt++ {
+		val, err := p.pollEndpointOnce(ctx, ep, timings)
+		if err == nil || attempt >= ep.Retries || !isTransient(err) {
+			return val, err
+		}
+
+		log.Debug("endpoint poll failed, retrying", "metric", ep.Name, "attempt", attempt+1, "error", err)
+		if !waitDelay(ctx, backoff<<attempt) {
+			return "", err
+		}
+	}
+}
+
+// isTransient returns true for the errors a retry may fix: the connection errors and the 5xx status codes. The
+// missing JSON paths or CSS selectors and the failed value transforms are not.
+func isTransient(err error) bool {
+	var statusErr errStatusNotOK
+	if errors.As(err, &statusErr) {
+		return statusErr >= http.StatusInternalServerError
+	}
+	var pathErr errPathNotFound
+	var selectorErr errSelectorNotFound
+
+	return !errors.As(err, &pathErr) && !errors.As(err, &selectorErr) && !errors.Is(err, errTransformFailed)
+}
+
+func (p *httpPoller) pollEndpointOnce(ctx context.Context, ep config.EndpointConfig, timings *requestTimings) (string, error) {
+	endpointURL, err := p.resolveURL(ctx, ep)
+	if err != nil {
+		return "", err
+	}
+
+	client, err := p.clientFor(ep, timings != nil)
+	if err != nil {
+		return "", err
+	}
+	body, err := p.fetch(timings.trace(ctx), client, endpointURL, ep)
+	if err != nil {
+		return "", err
+	}
+
+	val, err := extractValue(body, ep)
 	if err != nil {
 		return "", err
 	}
 
-	resp, err := p.client.Do(req)
+	return applyTransforms(val, ep.Transforms)
+}
+
+// resolveURL returns the endpoint URL, with the placeholder replaced by the value of its DependsOn request
+func (p *httpPoller) resolveURL(ctx context.Context, ep config.EndpointConfig) (string, error) {
+	if !ep.DependsOn.IsEnabled() {
+		return ep.URL, nil
+	}
+
+	client, err := p.clientFor(ep, false)
 	if err != nil {
 		return "", err
 	}
+	// the dependency is sent with the User-Agent and the cookies of the endpoint, its query is left as configured
+	dependencyEndpoint := ep
+	dependencyEndpoint.QueryParams = nil
+	body, err := p.fetch(ctx, client, ep.DependsOn.URL, dependencyEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("%w, dependency %s", err, ep.DependsOn.URL)
+	}
+	val, err := extractValue(body, config.EndpointConfig{Value: ep.DependsOn.Value})
+	if err != nil {
+		return "", fmt.Errorf("%w, dependency %s", err, ep.DependsOn.URL)
+	}
+
+	return replacePlaceholder(ep.URL, val), nil
+}
+
+// replacePlaceholder escapes the value for the part of the URL holding the placeholder, the path or the query
+func replacePlaceholder(endpointURL string, val string) string {
+	path, query, hasQuery := strings.Cut(endpointURL, "?")
+	path = strings.ReplaceAll(path, config.DependencyPlaceholder, url.PathEscape(val))
+	if !hasQuery {
+		return path
+	}
+
+	return path + "?" + strings.ReplaceAll(query, config.DependencyPlaceholder, url.QueryEscape(val))
+}
+
+func (p *httpPoller) fetch(ctx context.Context, client *http.Client, address string, ep config.EndpointConfig) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, address, nil)
+	if err != nil {
+		return nil, err
+	}
+	decorateRequest(req, ep)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
 	defer func() {
 		_ = resp.Body.Close()
 	}()
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return "", errStatusNotOK(resp.StatusCode)
+		return nil, errStatusNotOK(resp.StatusCode)
 	}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
-	}
+	return io.ReadAll(resp.Body)
+}
 
-	// Use gjson to extract the path (e.g. "data.status.erd_nonce")
-	result := gjson.GetBytes(body, ep.Value)
-	if !result.Exists() {
-		return "", errPathNotFound(ep.Value)
+// decorateRequest sets the User-Agent, the query parameters and the cookies of the endpoint on the request
+func decorateRequest(req *http.Request, ep config.EndpointConfig) {
+	if len(ep.UserAgent) > 0 {
+		req.Header.Set("User-Agent", ep.UserAgent)
+	}
+	if len(ep.QueryParams) > 0 {
+		query := req.URL.Query()
+		for name, value := range ep.QueryParams {
+			query.Set(name, value)
+		}
+		req.URL.RawQuery = query.Encode()
 	}
 
-	return result.String(), nil
+	names := make([]string, 0, len(ep.Cookies))
+	for name := range ep.Cookies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		req.AddCookie(&http.Cookie{Name: name, Value: ep.Cookies[name]})
+	}
 }
 
 // IsInterfaceNil returns true if the value under the interface is nil
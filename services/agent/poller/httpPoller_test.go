@@ -2,15 +2,76 @@ package poller
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/iulianpascalau/api-monitoring/commonGo"
 	"github.com/iulianpascalau/api-monitoring/services/agent/config"
 	"github.com/stretchr/testify/require"
 )
 
+func createPoller(t *testing.T, args ArgsHTTPPoller) *httpPoller {
+	poller, err := NewHTTPPoller(args)
+	require.Nil(t, err)
+
+	return poller
+}
+
+func TestNewHTTPPoller(t *testing.T) {
+	poller, err := NewHTTPPoller(ArgsHTTPPoller{ProxyURL: "ftp://bastion"})
+	require.Nil(t, poller)
+	require.True(t, errors.Is(err, commonGo.ErrInvalidProxyURL))
+
+	poller, err = NewHTTPPoller(ArgsHTTPPoller{IPFamily: "ipv5"})
+	require.Nil(t, poller)
+	require.True(t, errors.Is(err, commonGo.ErrInvalidIPFamily))
+
+	poller, err = NewHTTPPoller(ArgsHTTPPoller{ProxyURL: "socks5://bastion:1080", IPFamily: commonGo.IPFamilyIPv6})
+	require.Nil(t, err)
+	require.NotNil(t, poller)
+}
+
+func TestHTTPPoller_PollAllThroughProxy(t *testing.T) {
+	var mut sync.Mutex
+	proxiedHosts := make([]string, 0)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mut.Lock()
+		proxiedHosts = append(proxiedHosts, r.Host)
+		mut.Unlock()
+		_, _ = w.Write([]byte(`{"data": {"nonce": 42}}`))
+	}))
+	defer proxy.Close()
+	endpointProxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data": {"nonce": 43}}`))
+	}))
+	defer endpointProxy.Close()
+
+	endpoints := []config.EndpointConfig{
+		{Name: "VM1.nonce", URL: "http://node1.internal:8080/node/status", Value: "data.nonce", Type: "uint64"},
+		{
+			Name:     "VM2.nonce",
+			URL:      "http://node2.internal:8080/node/status",
+			Value:    "data.nonce",
+			Type:     "uint64",
+			ProxyURL: endpointProxy.URL,
+		},
+	}
+
+	poller := createPoller(t, ArgsHTTPPoller{Timeout: time.Second, ProxyURL: proxy.URL, IPFamily: commonGo.IPFamilyIPv4})
+	results := poller.PollAll(context.Background(), endpoints)
+
+	require.Len(t, results, 2)
+	require.Equal(t, "42", results["VM1.nonce"].Value)
+	require.Equal(t, "43", results["VM2.nonce"].Value)
+	require.Equal(t, []string{"node1.internal:8080"}, proxiedHosts)
+}
+
 func TestHTTPPoller_PollAll(t *testing.T) {
 	// 1. Setup mock endpoint for successfully extracting JSON path
 	successServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -41,7 +102,7 @@ func TestHTTPPoller_PollAll(t *testing.T) {
 	}
 
 	// 1s timeout to trip Node3
-	poller := NewHTTPPoller(1 * time.Second)
+	poller := createPoller(t, ArgsHTTPPoller{Timeout: time.Second})
 	ctx := context.Background()
 
 	results := poller.PollAll(ctx, endpoints)
@@ -54,3 +115,343 @@ func TestHTTPPoller_PollAll(t *testing.T) {
 	require.Equal(t, "123456", res.Value)
 	require.Equal(t, "uint64", res.Config.Type)
 }
+
+func TestHTTPPoller_PollAllHTMLEndpoint(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><body><table class="stats"><tr><td class="balance"> 1,234.5 EGLD </td></tr></table></body></html>`))
+	}))
+	defer server.Close()
+
+	endpoints := []config.EndpointConfig{
+		{
+			Name:  "Explorer.balance",
+			URL:   server.URL,
+			Value: "table.stats td.balance",
+			Type:  "float64",
+			Kind:  config.EndpointKindHTML,
+			Transforms: []config.TransformConfig{
+				{Type: config.TransformTrimSuffix, Value: " EGLD"},
+			},
+		},
+		{Name: "Explorer.missing", URL: server.URL, Value: "td.nonce", Type: "uint64", Kind: config.EndpointKindHTML},
+	}
+
+	poller := createPoller(t, ArgsHTTPPoller{Timeout: time.Second})
+	results := poller.PollAll(context.Background(), endpoints)
+
+	require.Len(t, results, 1)
+	require.Equal(t, "1,234.5", results["Explorer.balance"].Value)
+}
+
+func TestHTTPPoller_PollAllTracedEndpoint(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		_, _ = w.Write([]byte(`{"data": {"nonce": 42}}`))
+	}))
+	defer server.Close()
+
+	endpoints := []config.EndpointConfig{
+		{
+			Name:           "VM1.nonce",
+			URL:            server.URL,
+			Value:          "data.nonce",
+			Type:           "uint64",
+			NumAggregation: 10,
+			Tags:           map[string]string{"shard": "0"},
+			TraceTimings:   true,
+		},
+		{Name: "VM2.nonce", URL: server.URL, Value: "data.nonce", Type: "uint64"},
+	}
+
+	poller := createPoller(t, ArgsHTTPPoller{Timeout: time.Second})
+	results := poller.PollAll(context.Background(), endpoints)
+
+	require.Len(t, results, 2+len(config.TimingSuffixes))
+	require.Equal(t, "42", results["VM1.nonce"].Value)
+	for _, suffix := range config.TimingSuffixes {
+		result, found := results["VM1.nonce"+suffix]
+		require.True(t, found, suffix)
+		require.Equal(t, "VM1.nonce"+suffix, result.Config.Name)
+		require.Equal(t, "float64", result.Config.Type)
+		require.Equal(t, 10, result.Config.NumAggregation)
+		require.Equal(t, map[string]string{"shard": "0"}, result.Config.Tags)
+		_, err := strconv.ParseFloat(result.Value, 64)
+		require.Nil(t, err)
+	}
+	// the IP address is not resolved and the plain HTTP server has no TLS handshake
+	require.Equal(t, "0.000", results["VM1.nonce"+config.TimingDNSSuffix].Value)
+	require.Equal(t, "0.000", results["VM1.nonce"+config.TimingTLSSuffix].Value)
+	ttfb, _ := strconv.ParseFloat(results["VM1.nonce"+config.TimingTTFBSuffix].Value, 64)
+	require.GreaterOrEqual(t, ttfb, 20.0)
+}
+
+func TestHTTPPoller_PollAllDependentEndpoint(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/network/status":
+			_, _ = w.Write([]byte(`{"data": {"status": {"erd_epoch_number": 1234}}}`))
+		case "/validator/statistics":
+			_, _ = w.Write([]byte(fmt.Sprintf(`{"data": {"epoch": %q, "rating": 99.5}}`, r.URL.Query().Get("epoch"))))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	endpoints := []config.EndpointConfig{
+		{
+			Name:  "Node1.epoch",
+			URL:   server.URL + "/validator/statistics?epoch={value}",
+			Value: "data.epoch",
+			Type:  "uint64",
+			DependsOn: config.DependencyConfig{
+				URL:   server.URL + "/network/status",
+				Value: "data.status.erd_epoch_number",
+			},
+		},
+		{
+			Name:  "Node1.rating",
+			URL:   server.URL + "/validator/statistics?epoch={value}",
+			Value: "data.rating",
+			Type:  "float64",
+			DependsOn: config.DependencyConfig{
+				URL:   server.URL + "/network/missing",
+				Value: "data.status.erd_epoch_number",
+			},
+		},
+	}
+
+	poller := createPoller(t, ArgsHTTPPoller{Timeout: time.Second})
+	results := poller.PollAll(context.Background(), endpoints)
+
+	// the failed dependency fails the endpoint poll
+	require.Len(t, results, 1)
+	require.Equal(t, "1234", results["Node1.epoch"].Value)
+}
+
+func TestHTTPPoller_PollAllDecoratedEndpoint(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session, err := r.Cookie("session")
+		if r.UserAgent() != "Mozilla/5.0 (monitoring)" || err != nil || session.Value != "abc" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Query().Get("shard") != "1" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(fmt.Sprintf(`{"data": {"nonce": %q}}`, r.URL.Query().Get("nonce"))))
+	}))
+	defer server.Close()
+
+	endpoint := config.EndpointConfig{
+		Name:        "VM1.nonce",
+		URL:         server.URL + "/status?shard=1&nonce=1",
+		Value:       "data.nonce",
+		Type:        "uint64",
+		UserAgent:   "Mozilla/5.0 (monitoring)",
+		QueryParams: map[string]string{"nonce": "42"},
+		Cookies:     map[string]string{"session": "abc", "lang": "en"},
+	}
+	blockedEndpoint := endpoint
+	blockedEndpoint.Name = "VM2.nonce"
+	blockedEndpoint.UserAgent = ""
+
+	poller := createPoller(t, ArgsHTTPPoller{Timeout: time.Second})
+	results := poller.PollAll(context.Background(), []config.EndpointConfig{endpoint, blockedEndpoint})
+
+	require.Len(t, results, 1)
+	require.Equal(t, "42", results["VM1.nonce"].Value)
+}
+
+func TestReplacePlaceholder(t *testing.T) {
+	require.Equal(t, "http://host/epoch/12%2F3/stats", replacePlaceholder("http://host/epoch/{value}/stats", "12/3"))
+	require.Equal(t, "http://host/stats?epoch=a%26b", replacePlaceholder("http://host/stats?epoch={value}", "a&b"))
+	require.Equal(t, "http://host/a%20b/stats?epoch=a+b",
+		replacePlaceholder("http://host/{value}/stats?epoch={value}", "a b"))
+}
+
+func TestHTTPPoller_PollAllSpread(t *testing.T) {
+	var mut sync.Mutex
+	requestTimes := make(map[string]time.Time)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mut.Lock()
+		requestTimes[r.URL.Path] = time.Now()
+		mut.Unlock()
+		_, _ = w.Write([]byte(`{"nonce": 1}`))
+	}))
+	defer server.Close()
+
+	endpoints := []config.EndpointConfig{
+		{Name: "Node1", URL: server.URL + "/node1", Value: "nonce", Type: "uint64"},
+		{Name: "Node2", URL: server.URL + "/node2", Value: "nonce", Type: "uint64"},
+		{Name: "Node3", URL: server.URL + "/node3", Value: "nonce", Type: "uint64"},
+	}
+	poller := createPoller(t, ArgsHTTPPoller{
+		Timeout: time.Second,
+		Spread:  300 * time.Millisecond,
+		Jitter:  10 * time.Millisecond,
+	})
+
+	start := time.Now()
+	results := poller.PollAll(context.Background(), endpoints)
+	require.Len(t, results, 3)
+
+	mut.Lock()
+	defer mut.Unlock()
+	require.Less(t, requestTimes["/node1"].Sub(start), 100*time.Millisecond)
+	require.GreaterOrEqual(t, requestTimes["/node2"].Sub(start), 100*time.Millisecond)
+	require.GreaterOrEqual(t, requestTimes["/node3"].Sub(start), 200*time.Millisecond)
+}
+
+func TestHTTPPoller_PollAllCancelledDuringTheSpread(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"nonce": 1}`))
+	}))
+	defer server.Close()
+
+	endpoints := []config.EndpointConfig{
+		{Name: "Node1", URL: server.URL, Value: "nonce", Type: "uint64"},
+		{Name: "Node2", URL: server.URL, Value: "nonce", Type: "uint64"},
+	}
+	poller := createPoller(t, ArgsHTTPPoller{
+		Timeout: time.Second,
+		Spread:  10 * time.Second,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	results := poller.PollAll(ctx, endpoints)
+	require.Len(t, results, 1)
+	require.Contains(t, results, "Node1")
+}
+
+func TestHTTPPoller_PollAllMaxConcurrency(t *testing.T) {
+	var mut sync.Mutex
+	numActive := 0
+	maxActive := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mut.Lock()
+		numActive++
+		if numActive > maxActive {
+			maxActive = numActive
+		}
+		mut.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mut.Lock()
+		numActive--
+		mut.Unlock()
+		_, _ = w.Write([]byte(`{"nonce": 1}`))
+	}))
+	defer server.Close()
+
+	endpoints := make([]config.EndpointConfig, 0, 20)
+	for i := 0; i < 20; i++ {
+		endpoints = append(endpoints, config.EndpointConfig{
+			Name:  fmt.Sprintf("Node%d", i),
+			URL:   server.URL,
+			Value: "nonce",
+			Type:  "uint64",
+		})
+	}
+	poller := createPoller(t, ArgsHTTPPoller{
+		Timeout:        time.Second,
+		MaxConcurrency: 3,
+	})
+
+	results := poller.PollAll(context.Background(), endpoints)
+	require.Len(t, results, 20)
+
+	mut.Lock()
+	defer mut.Unlock()
+	require.LessOrEqual(t, maxActive, 3)
+	require.Greater(t, maxActive, 0)
+}
+
+func TestHTTPPoller_Poll(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data": {"nonce": 42}}`))
+	}))
+	defer server.Close()
+
+	poller := createPoller(t, ArgsHTTPPoller{Timeout: time.Second})
+
+	value, err := poller.Poll(context.Background(), config.EndpointConfig{Name: "Node1", URL: server.URL, Value: "data.nonce", Type: "uint64"})
+	require.Nil(t, err)
+	require.Equal(t, "42", value)
+
+	_, err = poller.Poll(context.Background(), config.EndpointConfig{Name: "Node1", URL: server.URL, Value: "data.epoch", Type: "uint64"})
+	var pathErr errPathNotFound
+	require.True(t, errors.As(err, &pathErr))
+}
+
+func TestHTTPPoller_PollAllEndpointTimeout(t *testing.T) {
+	slowServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(600 * time.Millisecond)
+		_, _ = w.Write([]byte(`{"nonce": 1}`))
+	}))
+	defer slowServer.Close()
+
+	endpoints := []config.EndpointConfig{
+		{Name: "Node1", URL: slowServer.URL, Value: "nonce", Type: "uint64"},
+		{Name: "Node2", URL: slowServer.URL, Value: "nonce", Type: "uint64", TimeoutInSeconds: 2},
+	}
+	poller := createPoller(t, ArgsHTTPPoller{Timeout: 300 * time.Millisecond})
+
+	results := poller.PollAll(context.Background(), endpoints)
+	require.Len(t, results, 1)
+	require.Contains(t, results, "Node2")
+}
+
+func TestHTTPPoller_PollAllRetries(t *testing.T) {
+	var mut sync.Mutex
+	numRequests := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mut.Lock()
+		numRequests[r.URL.Path]++
+		numRequest := numRequests[r.URL.Path]
+		mut.Unlock()
+
+		switch r.URL.Path {
+		case "/blip":
+			if numRequest < 3 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+		case "/not-found":
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"nonce": 1}`))
+	}))
+	defer server.Close()
+
+	endpoints := []config.EndpointConfig{
+		{Name: "Blip", URL: server.URL + "/blip", Value: "nonce", Type: "uint64", Retries: 2, RetryBackoffInMilliseconds: 10},
+		{Name: "NotFound", URL: server.URL + "/not-found", Value: "nonce", Type: "uint64", Retries: 2},
+		{Name: "MissingPath", URL: server.URL + "/missing-path", Value: "missing", Type: "uint64", Retries: 2},
+	}
+	poller := createPoller(t, ArgsHTTPPoller{Timeout: time.Second})
+
+	results := poller.PollAll(context.Background(), endpoints)
+	require.Len(t, results, 1)
+	require.Contains(t, results, "Blip")
+
+	mut.Lock()
+	defer mut.Unlock()
+	require.Equal(t, 3, numRequests["/blip"])
+	// the errors a retry can not fix are not retried
+	require.Equal(t, 1, numRequests["/not-found"])
+	require.Equal(t, 1, numRequests["/missing-path"])
+}
+
+func TestIsTransient(t *testing.T) {
+	require.True(t, isTransient(errors.New("connection refused")))
+	require.True(t, isTransient(errStatusNotOK(http.StatusServiceUnavailable)))
+	require.False(t, isTransient(errStatusNotOK(http.StatusUnauthorized)))
+	require.False(t, isTransient(errPathNotFound("data.nonce")))
+	require.False(t, isTransient(errSelectorNotFound("td.nonce")))
+}
@@ -0,0 +1,9 @@
+package poller
+
+import "time"
+
+// Clock defines the time source of the polled values timestamps
+type Clock interface {
+	Now() time.Time
+	IsInterfaceNil() bool
+}
@@ -0,0 +1,121 @@
+package poller
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/agent/common"
+	"github.com/iulianpascalau/api-monitoring/services/agent/config"
+)
+
+// requestTimings records, with httptrace, the durations of the phases of an endpoint request
+type requestTimings struct {
+	mut          sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	dns          time.Duration
+	connect      time.Duration
+	tls          time.Duration
+	ttfb         time.Duration
+}
+
+// trace resets the timings and returns the context tracing the request, the nil timings do not trace
+func (timings *requestTimings) trace(ctx context.Context) context.Context {
+	if timings == nil {
+		return ctx
+	}
+
+	timings.record(timings.reset)
+
+	clientTrace := &httptrace.ClientTrace{
+		DNSStart: func(_ httptrace.DNSStartInfo) {
+			timings.record(func() { timings.dnsStart = time.Now() })
+		},
+		DNSDone: func(_ httptrace.DNSDoneInfo) {
+			timings.record(func() { timings.dns = time.Since(timings.dnsStart) })
+		},
+		ConnectStart: func(_, _ string) {
+			timings.record(func() {
+				// the dual stack dialers start several connections, the first one starts the phase
+				if timings.connectStart.IsZero() {
+					timings.connectStart = time.Now()
+				}
+			})
+		},
+		ConnectDone: func(_, _ string, err error) {
+			if err != nil {
+				return
+			}
+			timings.record(func() { timings.connect = time.Since(timings.connectStart) })
+		},
+		TLSHandshakeStart: func() {
+			timings.record(func() { timings.tlsStart = time.Now() })
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, _ error) {
+			timings.record(func() { timings.tls = time.Since(timings.tlsStart) })
+		},
+		GotFirstResponseByte: func() {
+			timings.record(func() { timings.ttfb = time.Since(timings.start) })
+		},
+	}
+
+	return httptrace.WithClientTrace(ctx, clientTrace)
+}
+
+// reset clears the timings of a previous attempt, the mutex must be held
+func (timings *requestTimings) reset() {
+	timings.start = time.Now()
+	timings.dnsStart = time.Time{}
+	timings.connectStart = time.Time{}
+	timings.tlsStart = time.Time{}
+	timings.dns = 0
+	timings.connect = 0
+	timings.tls = 0
+	timings.ttfb = 0
+}
+
+func (timings *requestTimings) record(handler func()) {
+	timings.mut.Lock()
+	handler()
+	timings.mut.Unlock()
+}
+
+// results returns the timing metrics of the endpoint, in milliseconds. The phases that did not happen (e.g. the DNS
+// lookup of an IP address) are reported as 0.
+func (timings *requestTimings) results(ep config.EndpointConfig, polledAt int64) map[string]common.MetricResult {
+	if timings == nil {
+		return nil
+	}
+
+	timings.mut.Lock()
+	defer timings.mut.Unlock()
+
+	durations := map[string]time.Duration{
+		config.TimingDNSSuffix:     timings.dns,
+		config.TimingConnectSuffix: timings.connect,
+		config.TimingTLSSuffix:     timings.tls,
+		config.TimingTTFBSuffix:    timings.ttfb,
+	}
+	results := make(map[string]common.MetricResult, len(durations))
+	for suffix, duration := range durations {
+		name := ep.Name + suffix
+		results[name] = common.MetricResult{
+			Config: config.EndpointConfig{
+				Name:           name,
+				Type:           "float64",
+				NumAggregation: ep.NumAggregation,
+				Tags:           ep.Tags,
+			},
+			Value:    strconv.FormatFloat(float64(duration.Microseconds())/1000, 'f', 3, 64),
+			PolledAt: polledAt,
+		}
+	}
+
+	return results
+}
@@ -0,0 +1,83 @@
+package poller
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/iulianpascalau/api-monitoring/services/agent/config"
+)
+
+// maxDecimals bounds the decimals of a non-integer multiplication or division result, e.g. a balance denominated with
+// 18 decimals converted to EGLD
+const maxDecimals = 18
+
+// applyTransforms runs the value through the transformation pipeline of the endpoint. The arithmetic is exact, so the
+// large denominated amounts do not lose precision.
+func applyTransforms(value string, transforms []config.TransformConfig) (string, error) {
+	for _, transform := range transforms {
+		var err error
+		value, err = applyTransform(value, transform)
+		if err != nil {
+			return "", fmt.Errorf("%w, %s: %v", errTransformFailed, transform.Type, err)
+		}
+	}
+
+	return value, nil
+}
+
+func applyTransform(value string, transform config.TransformConfig) (string, error) {
+	switch transform.Type {
+	case config.TransformTrimSuffix:
+		return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), transform.Value)), nil
+	case config.TransformMap:
+		mapped, found := transform.Mapping[strings.TrimSpace(value)]
+		if !found {
+			return "", errUnmappedValue(value)
+		}
+		return mapped, nil
+	}
+
+	number, err := parseNumber(value)
+	if err != nil {
+		return "", err
+	}
+
+	switch transform.Type {
+	case config.TransformMultiply, config.TransformDivide:
+		factor, errFactor := parseNumber(transform.Value)
+		if errFactor != nil {
+			return "", errFactor
+		}
+		if transform.Type == config.TransformMultiply {
+			return formatNumber(number.Mul(number, factor)), nil
+		}
+		if factor.Sign() == 0 {
+			return "", errDivisionByZero
+		}
+		return formatNumber(number.Quo(number, factor)), nil
+	case config.TransformRound:
+		return number.FloatString(int(transform.Decimals)), nil
+	default:
+		return "", fmt.Errorf("unsupported transform type %q", transform.Type)
+	}
+}
+
+func parseNumber(value string) (*big.Rat, error) {
+	number, ok := new(big.Rat).SetString(strings.TrimSpace(value))
+	if !ok {
+		return nil, errNotANumber(value)
+	}
+
+	return number, nil
+}
+
+func formatNumber(number *big.Rat) string {
+	if number.IsInt() {
+		return number.Num().String()
+	}
+
+	formatted := strings.TrimRight(number.FloatString(maxDecimals), "0")
+
+	return strings.TrimSuffix(formatted, ".")
+}
@@ -0,0 +1,93 @@
+package poller
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/services/agent/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyTransforms(t *testing.T) {
+	t.Parallel()
+
+	t.Run("no transforms should return the value", func(t *testing.T) {
+		t.Parallel()
+
+		value, err := applyTransforms("abc", nil)
+		require.Nil(t, err)
+		assert.Equal(t, "abc", value)
+	})
+	t.Run("denominated balance should be converted", func(t *testing.T) {
+		t.Parallel()
+
+		transforms := []config.TransformConfig{
+			{Type: config.TransformDivide, Value: "1000000000000000000"},
+		}
+		value, err := applyTransforms("123456789012345678901234", transforms)
+		require.Nil(t, err)
+		assert.Equal(t, "123456.789012345678901234", value)
+
+		transforms = append(transforms, config.TransformConfig{Type: config.TransformRound, Decimals: 2})
+		value, err = applyTransforms("123456789012345678901234", transforms)
+		require.Nil(t, err)
+		assert.Equal(t, "123456.79", value)
+	})
+	t.Run("multiply", func(t *testing.T) {
+		t.Parallel()
+
+		value, err := applyTransforms("0.25", []config.TransformConfig{{Type: config.TransformMultiply, Value: "100"}})
+		require.Nil(t, err)
+		assert.Equal(t, "25", value)
+
+		value, err = applyTransforms("1", []config.TransformConfig{{Type: config.TransformMultiply, Value: "1.5"}})
+		require.Nil(t, err)
+		assert.Equal(t, "1.5", value)
+	})
+	t.Run("round to an integer", func(t *testing.T) {
+		t.Parallel()
+
+		value, err := applyTransforms("2.5", []config.TransformConfig{{Type: config.TransformRound}})
+		require.Nil(t, err)
+		assert.Equal(t, "3", value)
+	})
+	t.Run("strip the suffix then scale", func(t *testing.T) {
+		t.Parallel()
+
+		transforms := []config.TransformConfig{
+			{Type: config.TransformTrimSuffix, Value: "%"},
+			{Type: config.TransformDivide, Value: "100"},
+		}
+		value, err := applyTransforms(" 42.5% ", transforms)
+		require.Nil(t, err)
+		assert.Equal(t, "0.425", value)
+	})
+	t.Run("map the strings to numbers", func(t *testing.T) {
+		t.Parallel()
+
+		transforms := []config.TransformConfig{
+			{Type: config.TransformMap, Mapping: map[string]string{"eligible": "1", "jailed": "0"}},
+		}
+		value, err := applyTransforms("jailed", transforms)
+		require.Nil(t, err)
+		assert.Equal(t, "0", value)
+
+		_, err = applyTransforms("waiting", transforms)
+		assert.True(t, errors.Is(err, errTransformFailed))
+		assert.Contains(t, err.Error(), "value is missing from the mapping: waiting")
+	})
+	t.Run("errors", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := applyTransforms("abc", []config.TransformConfig{{Type: config.TransformMultiply, Value: "2"}})
+		assert.True(t, errors.Is(err, errTransformFailed))
+		assert.False(t, isTransient(err))
+
+		_, err = applyTransforms("1", []config.TransformConfig{{Type: config.TransformDivide, Value: "0"}})
+		assert.True(t, errors.Is(err, errTransformFailed))
+
+		_, err = applyTransforms("1", []config.TransformConfig{{Type: "sqrt"}})
+		assert.True(t, errors.Is(err, errTransformFailed))
+	})
+}
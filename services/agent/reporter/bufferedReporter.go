@@ -0,0 +1,192 @@
+package reporter
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/iulianpascalau/api-monitoring/services/agent/common"
+	"github.com/iulianpascalau/api-monitoring/services/agent/config"
+	"github.com/multiversx/mx-chain-core-go/core/check"
+)
+
+const droppedSamplesName = "DroppedSamples"
+
+// sampleOverheadInBytes accounts, next to the strings, for the memory of a buffered sample: the map entry, the result
+// struct and the string headers
+const sampleOverheadInBytes = 256
+
+// ArgsBufferedReporter defines the DTO struct for the NewBufferedReporter constructor function
+type ArgsBufferedReporter struct {
+	Reporter Reporter
+	AgentID  string
+	// MaxMemoryInBytes caps the estimated memory of the buffered reports, the oldest ones are dropped first
+	MaxMemoryInBytes int
+}
+
+// bufferedReport is a report that could not be sent, kept with the cycle ID of its first attempt so the server drops
+// it if that attempt was accepted after all
+type bufferedReport struct {
+	cycleID string
+	results map[string]common.MetricResult
+	size    int
+}
+
+// bufferedReporter keeps the reports that could not be sent and replays them, oldest first, after the next accepted
+// report, so an outage of the aggregation service does not leave a gap in the history. The memory of the buffered
+// reports is capped: above the cap the oldest reports are dropped and counted in the <agent>.DroppedSamples metric.
+type bufferedReporter struct {
+	reporter           Reporter
+	droppedSamplesName string
+	maxMemory          int
+	mut                sync.Mutex
+	reports            []bufferedReport
+	memory             int
+	numDroppedSamples  uint64
+}
+
+// NewBufferedReporter creates a new reporter buffering the failed reports of the provided one
+func NewBufferedReporter(args ArgsBufferedReporter) (*bufferedReporter, error) {
+	if check.IfNil(args.Reporter) {
+		return nil, errNilReporter
+	}
+	if args.MaxMemoryInBytes < 1 {
+		return nil, fmt.Errorf("%w: %d", errInvalidMaxMemory, args.MaxMemoryInBytes)
+	}
+
+	return &bufferedReporter{
+		reporter:           args.Reporter,
+		droppedSamplesName: args.AgentID + separator + droppedSamplesName,
+		maxMemory:          args.MaxMemoryInBytes,
+	}, nil
+}
+
+// Report sends the results together with the dropped samples counter. If the report fails, the results are buffered
+// and the error is returned. If it succeeds, the buffered reports are replayed until one of them fails again.
+func (br *bufferedReporter) Report(ctx context.Context, results map[string]common.MetricResult) error {
+	br.mut.Lock()
+	defer br.mut.Unlock()
+
+	err := br.reporter.Report(ctx, br.withDroppedSamples(results))
+	if err != nil {
+		br.buffer(common.CycleIDFromContext(ctx), results)
+		return err
+	}
+
+	br.replay(ctx)
+
+	return nil
+}
+
+// withDroppedSamples returns a copy of the results carrying the dropped samples counter
+func (br *bufferedReporter) withDroppedSamples(results map[string]common.MetricResult) map[string]common.MetricResult {
+	allResults := make(map[string]common.MetricResult, len(results)+1)
+	for name, result := range results {
+		allResults[name] = result
+	}
+	allResults[br.droppedSamplesName] = common.MetricResult{
+		Config: config.EndpointConfig{
+			Name:           br.droppedSamplesName,
+			Type:           "uint64",
+			NumAggregation: 1,
+		},
+		Value: strconv.FormatUint(br.numDroppedSamples, 10),
+	}
+
+	return allResults
+}
+
+// buffer keeps the results for a later replay, dropping the oldest reports above the memory cap. The stale results and
+// the ones without a poll timestamp are not kept, as the server stamps them on reception, and only the fields sent in
+// the reports are copied. The mutex must be held.
+func (br *bufferedReporter) buffer(cycleID string, results map[string]common.MetricResult) {
+	report := bufferedReport{
+		cycleID: cycleID,
+		results: make(map[string]common.MetricResult, len(results)),
+	}
+	for name, result := range results {
+		if result.Stale || result.PolledAt == 0 {
+			continue
+		}
+
+		report.results[name] = common.MetricResult{
+			Config: config.EndpointConfig{
+				Type:           result.Config.Type,
+				NumAggregation: result.Config.NumAggregation,
+				Tags:           result.Config.Tags,
+			},
+			Value:    result.Value,
+			PolledAt: result.PolledAt,
+		}
+		report.size += estimateSampleSize(name, result)
+	}
+	if len(report.results) == 0 {
+		return
+	}
+
+	br.reports = append(br.reports, report)
+	br.memory += report.size
+	log.Debug("buffered the failed report", "num samples", len(report.results), "num reports", len(br.reports),
+		"memory in bytes", br.memory)
+	numDropped := 0
+	for br.memory > br.maxMemory {
+		br.dropOldest()
+		numDropped++
+	}
+	if numDropped > 0 {
+		log.Warn("the report buffer is full, the oldest reports were dropped", "num reports", numDropped,
+			"num dropped samples", br.numDroppedSamples)
+	}
+}
+
+// dropOldest removes the oldest buffered report, the mutex must be held
+func (br *bufferedReporter) dropOldest() {
+	oldest := br.reports[0]
+	br.reports[0] = bufferedReport{}
+	br.reports = br.reports[1:]
+	br.memory -= oldest.size
+	br.numDroppedSamples += uint64(len(oldest.results))
+}
+
+// replay sends the buffered reports, oldest first, until one fails or the context is done. The mutex must be held.
+func (br *bufferedReporter) replay(ctx context.Context) {
+	numReplayed := 0
+	for len(br.reports) > 0 && ctx.Err() == nil {
+		report := br.reports[0]
+		err := br.reporter.Report(common.WithCycleID(ctx, report.cycleID), report.results)
+		if err != nil {
+			log.Debug("failed to replay a buffered report", "error", err)
+			break
+		}
+
+		br.reports[0] = bufferedReport{}
+		br.reports = br.reports[1:]
+		br.memory -= report.size
+		numReplayed++
+	}
+
+	if numReplayed > 0 {
+		log.Info("replayed the buffered reports", "num reports", numReplayed, "num remaining", len(br.reports))
+	}
+}
+
+// estimateSampleSize returns the estimated memory, in bytes, of a buffered sample
+func estimateSampleSize(name string, result common.MetricResult) int {
+	size := sampleOverheadInBytes + len(name) + len(result.Value) + len(result.Config.Type)
+	for key, value := range result.Config.Tags {
+		size += len(key) + len(value)
+	}
+
+	return size
+}
+
+// Close closes the wrapped reporter, the buffered reports are lost
+func (br *bufferedReporter) Close() error {
+	return br.reporter.Close()
+}
+
+// IsInterfaceNil returns true if the value under the interface is nil
+func (br *bufferedReporter) IsInterfaceNil() bool {
+	return br == nil
+}
@@ -0,0 +1,187 @@
+package reporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/services/agent/common"
+	"github.com/iulianpascalau/api-monitoring/services/agent/config"
+	"github.com/iulianpascalau/api-monitoring/services/agent/testsCommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createBufferedResults(polledAt int64) map[string]common.MetricResult {
+	return map[string]common.MetricResult{
+		"VM1.Node1.nonce": {
+			Config: config.EndpointConfig{
+				Name:           "VM1.Node1.nonce",
+				URL:            "http://127.0.0.1:8080/node/status",
+				Type:           "uint64",
+				NumAggregation: 10,
+			},
+			Value:    fmt.Sprintf("%d", polledAt),
+			PolledAt: polledAt,
+		},
+		"VM1.Node1.epoch": {
+			Config: config.EndpointConfig{Name: "VM1.Node1.epoch", Type: "uint64", NumAggregation: 1},
+			Value:  "7",
+			Stale:  true,
+		},
+	}
+}
+
+func TestNewBufferedReporter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("nil reporter should error", func(t *testing.T) {
+		t.Parallel()
+
+		br, err := NewBufferedReporter(ArgsBufferedReporter{MaxMemoryInBytes: 1024})
+		assert.Nil(t, br)
+		assert.Equal(t, errNilReporter, err)
+	})
+	t.Run("invalid maximum memory should error", func(t *testing.T) {
+		t.Parallel()
+
+		br, err := NewBufferedReporter(ArgsBufferedReporter{Reporter: &testsCommon.ReporterStub{}})
+		assert.Nil(t, br)
+		assert.True(t, errors.Is(err, errInvalidMaxMemory))
+	})
+	t.Run("should work", func(t *testing.T) {
+		t.Parallel()
+
+		br, err := NewBufferedReporter(ArgsBufferedReporter{Reporter: &testsCommon.ReporterStub{}, MaxMemoryInBytes: 1024})
+		assert.Nil(t, err)
+		assert.False(t, br.IsInterfaceNil())
+		assert.Nil(t, br.Close())
+	})
+}
+
+func TestBufferedReporter_Report(t *testing.T) {
+	t.Parallel()
+
+	t.Run("the failed reports are replayed after an accepted report", func(t *testing.T) {
+		t.Parallel()
+
+		expectedErr := errors.New("expected error")
+		var reportErr error
+		var sent []map[string]common.MetricResult
+		var cycleIDs []string
+		stub := &testsCommon.ReporterStub{
+			ReportHandler: func(ctx context.Context, results map[string]common.MetricResult) error {
+				if reportErr != nil {
+					return reportErr
+				}
+				sent = append(sent, results)
+				cycleIDs = append(cycleIDs, common.CycleIDFromContext(ctx))
+				return nil
+			},
+		}
+		br, _ := NewBufferedReporter(ArgsBufferedReporter{Reporter: stub, AgentID: "VM1", MaxMemoryInBytes: 1024 * 1024})
+
+		reportErr = expectedErr
+		assert.Equal(t, expectedErr, br.Report(common.WithCycleID(context.Background(), "cycle1"), createBufferedResults(1000)))
+		assert.Equal(t, expectedErr, br.Report(common.WithCycleID(context.Background(), "cycle2"), createBufferedResults(1030)))
+		assert.Len(t, br.reports, 2)
+		assert.Positive(t, br.memory)
+
+		reportErr = nil
+		require.Nil(t, br.Report(common.WithCycleID(context.Background(), "cycle3"), createBufferedResults(1060)))
+		require.Len(t, sent, 3)
+		assert.Equal(t, []string{"cycle3", "cycle1", "cycle2"}, cycleIDs)
+		assert.Equal(t, "0", sent[0]["VM1.DroppedSamples"].Value)
+		assert.Len(t, sent[0], 3)
+
+		// the replayed reports only hold the timestamped values, with the fields sent in the reports
+		require.Len(t, sent[1], 1)
+		replayed := sent[1]["VM1.Node1.nonce"]
+		assert.Equal(t, "1000", replayed.Value)
+		assert.Equal(t, int64(1000), replayed.PolledAt)
+		assert.Equal(t, "uint64", replayed.Config.Type)
+		assert.Equal(t, 10, replayed.Config.NumAggregation)
+		assert.Empty(t, replayed.Config.URL)
+		assert.Equal(t, "1030", sent[2]["VM1.Node1.nonce"].Value)
+		assert.Empty(t, br.reports)
+		assert.Zero(t, br.memory)
+	})
+	t.Run("a failed replay keeps the remaining reports", func(t *testing.T) {
+		t.Parallel()
+
+		numReports := 0
+		failFrom := 0
+		stub := &testsCommon.ReporterStub{
+			ReportHandler: func(ctx context.Context, results map[string]common.MetricResult) error {
+				numReports++
+				if failFrom > 0 && numReports >= failFrom {
+					return errors.New("expected error")
+				}
+				return nil
+			},
+		}
+		br, _ := NewBufferedReporter(ArgsBufferedReporter{Reporter: stub, AgentID: "VM1", MaxMemoryInBytes: 1024 * 1024})
+
+		failFrom = 1
+		_ = br.Report(context.Background(), createBufferedResults(1000))
+		_ = br.Report(context.Background(), createBufferedResults(1030))
+		_ = br.Report(context.Background(), createBufferedResults(1060))
+		require.Len(t, br.reports, 3)
+
+		// the current report and the first replay are accepted
+		numReports = 0
+		failFrom = 3
+		require.Nil(t, br.Report(context.Background(), createBufferedResults(1090)))
+		require.Len(t, br.reports, 2)
+		assert.Equal(t, int64(1030), br.reports[0].results["VM1.Node1.nonce"].PolledAt)
+	})
+	t.Run("the oldest reports are dropped above the memory cap", func(t *testing.T) {
+		t.Parallel()
+
+		var reportErr error
+		var sent []map[string]common.MetricResult
+		stub := &testsCommon.ReporterStub{
+			ReportHandler: func(ctx context.Context, results map[string]common.MetricResult) error {
+				if reportErr != nil {
+					return reportErr
+				}
+				sent = append(sent, results)
+				return nil
+			},
+		}
+		reportSize := estimateSampleSize("VM1.Node1.nonce", createBufferedResults(1000)["VM1.Node1.nonce"])
+		br, _ := NewBufferedReporter(ArgsBufferedReporter{Reporter: stub, AgentID: "VM1", MaxMemoryInBytes: 2 * reportSize})
+
+		reportErr = errors.New("expected error")
+		for i := int64(0); i < 5; i++ {
+			_ = br.Report(context.Background(), createBufferedResults(1000+i*30))
+		}
+		require.Len(t, br.reports, 2)
+		assert.Equal(t, 2*reportSize, br.memory)
+		assert.Equal(t, uint64(3), br.numDroppedSamples)
+
+		reportErr = nil
+		require.Nil(t, br.Report(context.Background(), createBufferedResults(1150)))
+		require.Len(t, sent, 3)
+		assert.Equal(t, "3", sent[0]["VM1.DroppedSamples"].Value)
+		assert.Equal(t, "uint64", sent[0]["VM1.DroppedSamples"].Config.Type)
+		assert.Equal(t, "1090", sent[1]["VM1.Node1.nonce"].Value)
+		assert.Equal(t, "1120", sent[2]["VM1.Node1.nonce"].Value)
+	})
+	t.Run("a report larger than the memory cap is dropped", func(t *testing.T) {
+		t.Parallel()
+
+		stub := &testsCommon.ReporterStub{
+			ReportHandler: func(ctx context.Context, results map[string]common.MetricResult) error {
+				return errors.New("expected error")
+			},
+		}
+		br, _ := NewBufferedReporter(ArgsBufferedReporter{Reporter: stub, AgentID: "VM1", MaxMemoryInBytes: 10})
+
+		_ = br.Report(context.Background(), createBufferedResults(1000))
+		assert.Empty(t, br.reports)
+		assert.Zero(t, br.memory)
+		assert.Equal(t, uint64(1), br.numDroppedSamples)
+	})
+}
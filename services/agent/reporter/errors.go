@@ -0,0 +1,21 @@
+package reporter
+
+import "errors"
+
+var errNilPayloadTracer = errors.New("nil payload tracer")
+
+var errChecksumMismatch = errors.New("server reported a payload checksum mismatch")
+
+var errNilMQTTClient = errors.New("nil MQTT client")
+
+var errEmptyTopic = errors.New("empty MQTT topic")
+
+var errNoEndpoints = errors.New("no report endpoints")
+
+var errInvalidEndpoint = errors.New("invalid report endpoint")
+
+var errNilReporter = errors.New("nil reporter")
+
+var errInvalidMaxMemory = errors.New("invalid report buffer maximum memory")
+
+var errNilFaultInjector = errors.New("nil fault injector")
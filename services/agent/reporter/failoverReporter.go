@@ -0,0 +1,238 @@
+package reporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/agent/common"
+)
+
+const (
+	defaultHealthCheckInterval        = 30 * time.Second
+	defaultNumHealthyChecksToFailBack = 3
+	defaultHealthCheckPath            = "/healthz"
+)
+
+// ArgsFailoverReporter defines the DTO struct for the NewFailoverReporter constructor function
+type ArgsFailoverReporter struct {
+	// Endpoints holds the report endpoints in the order of preference, the first one is the primary
+	Endpoints     []string
+	ApiKey        string
+	AgentID       string
+	Timeout       time.Duration
+	Tracer        PayloadTracer
+	UseChecksum   bool
+	UseMsgPack    bool
+	QueryInterval time.Duration
+	AgentInfo     *common.AgentInfo
+	// PrimaryHealthCheckURL is polled while the reports go to a replica. Empty derives <scheme>://<host>/healthz from
+	// the primary endpoint.
+	PrimaryHealthCheckURL      string
+	HealthCheckInterval        time.Duration
+	NumHealthyChecksToFailBack int
+	// Transport sends the reports and the health checks, nil uses http.DefaultTransport
+	Transport http.RoundTripper
+}
+
+// failoverReporter sends the reports to the first endpoint accepting them, starting with the active one. The active
+// endpoint is sticky: after a failover the reports keep going to the replica until the primary passes
+// NumHealthyChecksToFailBack consecutive health checks, so a flapping primary does not spread the data between the
+// aggregation services.
+type failoverReporter struct {
+	reporters            []endpointReporter
+	healthCheckURL       string
+	healthCheckInterval  time.Duration
+	numHealthyToFailBack int
+	client               *http.Client
+	mutActive            sync.RWMutex
+	active               int
+	numHealthyChecks     int
+	cancel               context.CancelFunc
+	wg                   sync.WaitGroup
+}
+
+// NewFailoverReporter creates a new reporter failing over between the provided endpoints and starts the health
+// checks of the primary
+func NewFailoverReporter(args ArgsFailoverReporter) (*failoverReporter, error) {
+	if len(args.Endpoints) == 0 {
+		return nil, errNoEndpoints
+	}
+
+	healthCheckURL := args.PrimaryHealthCheckURL
+	if len(healthCheckURL) == 0 {
+		var err error
+		healthCheckURL, err = createHealthCheckURL(args.Endpoints[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	reporters, err := createEndpointReporters(args.Endpoints, ArgsHTTPReporter{
+		ApiKey:        args.ApiKey,
+		AgentID:       args.AgentID,
+		Timeout:       args.Timeout,
+		Tracer:        args.Tracer,
+		UseChecksum:   args.UseChecksum,
+		UseMsgPack:    args.UseMsgPack,
+		QueryInterval: args.QueryInterval,
+		AgentInfo:     args.AgentInfo,
+		Transport:     args.Transport,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	fr := &failoverReporter{
+		reporters:            reporters,
+		healthCheckURL:       healthCheckURL,
+		healthCheckInterval:  args.HealthCheckInterval,
+		numHealthyToFailBack: args.NumHealthyChecksToFailBack,
+		client:               &http.Client{Timeout: args.Timeout, Transport: args.Transport},
+	}
+	if fr.healthCheckInterval <= 0 {
+		fr.healthCheckInterval = defaultHealthCheckInterval
+	}
+	if fr.numHealthyToFailBack <= 0 {
+		fr.numHealthyToFailBack = defaultNumHealthyChecksToFailBack
+	}
+
+	var ctx context.Context
+	ctx, fr.cancel = context.WithCancel(context.Background())
+	fr.wg.Add(1)
+	go fr.healthCheckLoop(ctx)
+
+	return fr, nil
+}
+
+func createHealthCheckURL(endpoint string) (string, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil || len(parsed.Scheme) == 0 || len(parsed.Host) == 0 {
+		return "", fmt.Errorf("%w: %s", errInvalidEndpoint, endpoint)
+	}
+
+	return parsed.Scheme + "://" + parsed.Host + defaultHealthCheckPath, nil
+}
+
+// Report sends the report to the active endpoint, failing over to the next ones, in order, if it is rejected
+func (fr *failoverReporter) Report(ctx context.Context, results map[string]common.MetricResult) error {
+	active := fr.getActive()
+
+	var errs []error
+	for i := 0; i < len(fr.reporters); i++ {
+		index := (active + i) % len(fr.reporters)
+		current := fr.reporters[index]
+
+		err := current.reporter.Report(ctx, results)
+		if err == nil {
+			if index != active {
+				fr.setActive(index)
+			}
+			return nil
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %w", current.endpoint, err))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (fr *failoverReporter) getActive() int {
+	fr.mutActive.RLock()
+	defer fr.mutActive.RUnlock()
+
+	return fr.active
+}
+
+func (fr *failoverReporter) setActive(index int) {
+	fr.mutActive.Lock()
+	defer fr.mutActive.Unlock()
+
+	if index == 0 {
+		log.Info("reports are sent to the primary endpoint again", "endpoint", fr.reporters[index].endpoint)
+	} else {
+		log.Warn("failed over to a replica endpoint", "endpoint", fr.reporters[index].endpoint,
+			"previous endpoint", fr.reporters[fr.active].endpoint)
+	}
+	fr.active = index
+	fr.numHealthyChecks = 0
+}
+
+func (fr *failoverReporter) healthCheckLoop(ctx context.Context) {
+	defer fr.wg.Done()
+
+	ticker := time.NewTicker(fr.healthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fr.checkPrimary(ctx)
+		}
+	}
+}
+
+// checkPrimary moves the reports back to the primary after enough consecutive successful health checks
+func (fr *failoverReporter) checkPrimary(ctx context.Context) {
+	if fr.getActive() == 0 {
+		return
+	}
+
+	healthy := fr.isPrimaryHealthy(ctx)
+
+	fr.mutActive.Lock()
+	if fr.active == 0 {
+		fr.mutActive.Unlock()
+		return
+	}
+	if !healthy {
+		fr.numHealthyChecks = 0
+		fr.mutActive.Unlock()
+		return
+	}
+	fr.numHealthyChecks++
+	failBack := fr.numHealthyChecks >= fr.numHealthyToFailBack
+	fr.mutActive.Unlock()
+
+	if failBack {
+		fr.setActive(0)
+	}
+}
+
+func (fr *failoverReporter) isPrimaryHealthy(ctx context.Context) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fr.healthCheckURL, nil)
+	if err != nil {
+		return false
+	}
+
+	resp, err := fr.client.Do(req)
+	if err != nil {
+		log.Debug("primary endpoint health check failed", "URL", fr.healthCheckURL, "error", err)
+		return false
+	}
+	_ = resp.Body.Close()
+
+	return resp.StatusCode >= 200 && resp.StatusCode < 300
+}
+
+// Close stops the health checks
+func (fr *failoverReporter) Close() error {
+	fr.cancel()
+	fr.wg.Wait()
+
+	return nil
+}
+
+// IsInterfaceNil returns true if the value under the interface is nil
+func (fr *failoverReporter) IsInterfaceNil() bool {
+	return fr == nil
+}
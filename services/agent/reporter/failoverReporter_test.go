@@ -0,0 +1,134 @@
+package reporter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/agent/testsCommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type aggregationServerMock struct {
+	*httptest.Server
+	healthy    atomic.Bool
+	numReports atomic.Int32
+}
+
+func newAggregationServerMock(healthy bool) *aggregationServerMock {
+	mock := &aggregationServerMock{}
+	mock.healthy.Store(healthy)
+	mock.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !mock.healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.URL.Path == "/report" {
+			mock.numReports.Add(1)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	return mock
+}
+
+func createMockArgsFailoverReporter(endpoints ...string) ArgsFailoverReporter {
+	return ArgsFailoverReporter{
+		Endpoints:           endpoints,
+		ApiKey:              "secret123",
+		AgentID:             "AgentX",
+		Timeout:             2 * time.Second,
+		Tracer:              &testsCommon.PayloadTracerStub{},
+		HealthCheckInterval: time.Hour,
+	}
+}
+
+func TestNewFailoverReporter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("no endpoints should error", func(t *testing.T) {
+		t.Parallel()
+
+		fr, err := NewFailoverReporter(createMockArgsFailoverReporter())
+		assert.Nil(t, fr)
+		assert.Equal(t, errNoEndpoints, err)
+	})
+	t.Run("invalid primary endpoint should error", func(t *testing.T) {
+		t.Parallel()
+
+		fr, err := NewFailoverReporter(createMockArgsFailoverReporter("/report"))
+		assert.Nil(t, fr)
+		assert.True(t, errors.Is(err, errInvalidEndpoint))
+	})
+	t.Run("nil tracer should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsFailoverReporter("https://aaa.bbb.com/report")
+		args.Tracer = nil
+		fr, err := NewFailoverReporter(args)
+		assert.Nil(t, fr)
+		assert.Equal(t, errNilPayloadTracer, err)
+	})
+	t.Run("should work", func(t *testing.T) {
+		t.Parallel()
+
+		fr, err := NewFailoverReporter(createMockArgsFailoverReporter("https://aaa.bbb.com/api/report", "https://ccc.ddd.com/api/report"))
+		require.NoError(t, err)
+		assert.False(t, fr.IsInterfaceNil())
+		assert.Equal(t, "https://aaa.bbb.com/healthz", fr.healthCheckURL)
+		assert.Equal(t, defaultNumHealthyChecksToFailBack, fr.numHealthyToFailBack)
+		assert.Nil(t, fr.Close())
+	})
+}
+
+func TestFailoverReporter_Report(t *testing.T) {
+	t.Parallel()
+
+	primary := newAggregationServerMock(false)
+	defer primary.Close()
+	replica := newAggregationServerMock(true)
+	defer replica.Close()
+
+	args := createMockArgsFailoverReporter(primary.URL+"/report", replica.URL+"/report")
+	args.NumHealthyChecksToFailBack = 2
+	fr, err := NewFailoverReporter(args)
+	require.NoError(t, err)
+	defer func() {
+		_ = fr.Close()
+	}()
+	ctx := context.Background()
+
+	// the primary is down, the report goes to the replica
+	require.NoError(t, fr.Report(ctx, nil))
+	assert.Equal(t, 1, fr.getActive())
+	assert.Equal(t, int32(1), replica.numReports.Load())
+
+	// the replica is sticky while the primary is unhealthy
+	fr.checkPrimary(ctx)
+	require.NoError(t, fr.Report(ctx, nil))
+	assert.Equal(t, int32(2), replica.numReports.Load())
+
+	// the reports go back to the primary after enough successful health checks
+	primary.healthy.Store(true)
+	fr.checkPrimary(ctx)
+	assert.Equal(t, 1, fr.getActive())
+	fr.checkPrimary(ctx)
+	assert.Equal(t, 0, fr.getActive())
+	require.NoError(t, fr.Report(ctx, nil))
+	assert.Equal(t, int32(1), primary.numReports.Load())
+	assert.Equal(t, int32(2), replica.numReports.Load())
+
+	// all the endpoints are down
+	primary.healthy.Store(false)
+	replica.healthy.Store(false)
+	err = fr.Report(ctx, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), primary.URL)
+	assert.Contains(t, err.Error(), replica.URL)
+	assert.Equal(t, 0, fr.getActive())
+}
@@ -0,0 +1,103 @@
+package reporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/agent/common"
+)
+
+// ArgsFanOutReporter defines the DTO struct for the NewFanOutReporter constructor function
+type ArgsFanOutReporter struct {
+	Endpoints     []string
+	ApiKey        string
+	AgentID       string
+	Timeout       time.Duration
+	Tracer        PayloadTracer
+	UseChecksum   bool
+	UseMsgPack    bool
+	QueryInterval time.Duration
+	AgentInfo     *common.AgentInfo
+	// Transport sends the reports, nil uses http.DefaultTransport
+	Transport http.RoundTripper
+}
+
+// fanOutReporter sends each report to all the endpoints in parallel, providing redundancy between aggregation
+// services that do not share the storage. A report is successful if at least one endpoint accepted it.
+type fanOutReporter struct {
+	reporters []endpointReporter
+}
+
+// NewFanOutReporter creates a new reporter sending the reports to all the provided endpoints
+func NewFanOutReporter(args ArgsFanOutReporter) (*fanOutReporter, error) {
+	if len(args.Endpoints) == 0 {
+		return nil, errNoEndpoints
+	}
+
+	reporters, err := createEndpointReporters(args.Endpoints, ArgsHTTPReporter{
+		ApiKey:        args.ApiKey,
+		AgentID:       args.AgentID,
+		Timeout:       args.Timeout,
+		Tracer:        args.Tracer,
+		UseChecksum:   args.UseChecksum,
+		UseMsgPack:    args.UseMsgPack,
+		QueryInterval: args.QueryInterval,
+		AgentInfo:     args.AgentInfo,
+		Transport:     args.Transport,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &fanOutReporter{
+		reporters: reporters,
+	}, nil
+}
+
+// Report sends the report to all the endpoints in parallel, returning an error only if all of them rejected it
+func (fr *fanOutReporter) Report(ctx context.Context, results map[string]common.MetricResult) error {
+	errs := make([]error, len(fr.reporters))
+
+	wg := sync.WaitGroup{}
+	wg.Add(len(fr.reporters))
+	for i, current := range fr.reporters {
+		go func(index int, current endpointReporter) {
+			defer wg.Done()
+
+			err := current.reporter.Report(ctx, results)
+			if err != nil {
+				errs[index] = fmt.Errorf("%s: %w", current.endpoint, err)
+			}
+		}(i, current)
+	}
+	wg.Wait()
+
+	var failed []error
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	if len(failed) == len(fr.reporters) {
+		return errors.Join(failed...)
+	}
+	for _, err := range failed {
+		log.Warn("report was not accepted by one of the fan-out endpoints", "error", err)
+	}
+
+	return nil
+}
+
+// Close does nothing as the HTTP reporters do not hold connections
+func (fr *fanOutReporter) Close() error {
+	return nil
+}
+
+// IsInterfaceNil returns true if the value under the interface is nil
+func (fr *fanOutReporter) IsInterfaceNil() bool {
+	return fr == nil
+}
@@ -0,0 +1,79 @@
+package reporter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/agent/testsCommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createMockArgsFanOutReporter(endpoints ...string) ArgsFanOutReporter {
+	return ArgsFanOutReporter{
+		Endpoints: endpoints,
+		ApiKey:    "secret123",
+		AgentID:   "AgentX",
+		Timeout:   2 * time.Second,
+		Tracer:    &testsCommon.PayloadTracerStub{},
+	}
+}
+
+func TestNewFanOutReporter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("no endpoints should error", func(t *testing.T) {
+		t.Parallel()
+
+		fr, err := NewFanOutReporter(createMockArgsFanOutReporter())
+		assert.Nil(t, fr)
+		assert.Equal(t, errNoEndpoints, err)
+	})
+	t.Run("nil tracer should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsFanOutReporter("https://aaa.bbb.com/report")
+		args.Tracer = nil
+		fr, err := NewFanOutReporter(args)
+		assert.Nil(t, fr)
+		assert.Equal(t, errNilPayloadTracer, err)
+	})
+	t.Run("should work", func(t *testing.T) {
+		t.Parallel()
+
+		fr, err := NewFanOutReporter(createMockArgsFanOutReporter("https://aaa.bbb.com/report", "https://ccc.ddd.com/report"))
+		require.NoError(t, err)
+		assert.False(t, fr.IsInterfaceNil())
+		assert.Len(t, fr.reporters, 2)
+		assert.Nil(t, fr.Close())
+	})
+}
+
+func TestFanOutReporter_Report(t *testing.T) {
+	t.Parallel()
+
+	first := newAggregationServerMock(true)
+	defer first.Close()
+	second := newAggregationServerMock(true)
+	defer second.Close()
+
+	fr, err := NewFanOutReporter(createMockArgsFanOutReporter(first.URL+"/report", second.URL+"/report"))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	require.NoError(t, fr.Report(ctx, nil))
+	assert.Equal(t, int32(1), first.numReports.Load())
+	assert.Equal(t, int32(1), second.numReports.Load())
+
+	// one accepting endpoint is enough
+	first.healthy.Store(false)
+	require.NoError(t, fr.Report(ctx, nil))
+	assert.Equal(t, int32(2), second.numReports.Load())
+
+	second.healthy.Store(false)
+	err = fr.Report(ctx, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), first.URL)
+	assert.Contains(t, err.Error(), second.URL)
+}
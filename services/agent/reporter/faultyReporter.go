@@ -0,0 +1,51 @@
+package reporter
+
+import (
+	"context"
+
+	"github.com/iulianpascalau/api-monitoring/commonGo/faults"
+	"github.com/iulianpascalau/api-monitoring/services/agent/common"
+	"github.com/multiversx/mx-chain-core-go/core/check"
+)
+
+// faultyReporter applies the faults injected in the faults.PointAgentReport point before each report: the latency
+// delays the report, the error drops it as a failed send would
+type faultyReporter struct {
+	reporter Reporter
+	injector FaultInjector
+}
+
+// NewFaultyReporter creates a reporter injecting the failures of the provided injector in the provided reporter
+func NewFaultyReporter(rep Reporter, injector FaultInjector) (*faultyReporter, error) {
+	if check.IfNil(rep) {
+		return nil, errNilReporter
+	}
+	if check.IfNil(injector) {
+		return nil, errNilFaultInjector
+	}
+
+	return &faultyReporter{
+		reporter: rep,
+		injector: injector,
+	}, nil
+}
+
+// Report sends the results, unless an error is injected
+func (fr *faultyReporter) Report(ctx context.Context, results map[string]common.MetricResult) error {
+	err := fr.injector.Inject(ctx, faults.PointAgentReport)
+	if err != nil {
+		return err
+	}
+
+	return fr.reporter.Report(ctx, results)
+}
+
+// Close closes the wrapped reporter
+func (fr *faultyReporter) Close() error {
+	return fr.reporter.Close()
+}
+
+// IsInterfaceNil returns true if there is no value under the interface
+func (fr *faultyReporter) IsInterfaceNil() bool {
+	return fr == nil
+}
@@ -0,0 +1,59 @@
+package reporter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/commonGo/faults"
+	"github.com/iulianpascalau/api-monitoring/services/agent/common"
+	"github.com/iulianpascalau/api-monitoring/services/agent/testsCommon"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewFaultyReporter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("nil reporter should error", func(t *testing.T) {
+		t.Parallel()
+
+		fr, err := NewFaultyReporter(nil, faults.NewInjector())
+		assert.Nil(t, fr)
+		assert.Equal(t, errNilReporter, err)
+	})
+	t.Run("nil fault injector should error", func(t *testing.T) {
+		t.Parallel()
+
+		fr, err := NewFaultyReporter(&testsCommon.ReporterStub{}, nil)
+		assert.Nil(t, fr)
+		assert.Equal(t, errNilFaultInjector, err)
+	})
+	t.Run("should work", func(t *testing.T) {
+		t.Parallel()
+
+		fr, err := NewFaultyReporter(&testsCommon.ReporterStub{}, faults.NewInjector())
+		assert.Nil(t, err)
+		assert.False(t, fr.IsInterfaceNil())
+		assert.Nil(t, fr.Close())
+	})
+}
+
+func TestFaultyReporter_Report(t *testing.T) {
+	t.Parallel()
+
+	numSent := 0
+	stub := &testsCommon.ReporterStub{
+		ReportHandler: func(ctx context.Context, results map[string]common.MetricResult) error {
+			numSent++
+			return nil
+		},
+	}
+	injector := faults.NewInjector()
+	fr, _ := NewFaultyReporter(stub, injector)
+
+	assert.Nil(t, fr.Report(context.Background(), createBufferedResults(1000)))
+	injector.Set(faults.PointAgentReport, faults.Fault{Err: faults.ErrInjected, Times: 1})
+	assert.Equal(t, faults.ErrInjected, fr.Report(context.Background(), createBufferedResults(1030)))
+	assert.Nil(t, fr.Report(context.Background(), createBufferedResults(1060)))
+	assert.Equal(t, 2, numSent)
+	assert.Equal(t, 3, injector.Hits(faults.PointAgentReport))
+}
@@ -3,86 +3,238 @@ package reporter
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
+	"github.com/iulianpascalau/api-monitoring/commonGo"
 	"github.com/iulianpascalau/api-monitoring/services/agent/common"
+	"github.com/multiversx/mx-chain-core-go/core/check"
 	logger "github.com/multiversx/mx-chain-logger-go"
 )
 
 const activeHeartbeatName = "Active"
 const separator = "."
 
+// checksumHeader carries the hex encoded SHA-256 of the request body so the server can detect truncated/corrupted payloads
+const checksumHeader = "X-Content-Sha256"
+
 var log = logger.GetOrCreate("reporter")
 
+// ArgsHTTPReporter defines the DTO struct for the NewHTTPReporter constructor function
+type ArgsHTTPReporter struct {
+	Endpoint    string
+	ApiKey      string
+	AgentID     string
+	Timeout     time.Duration
+	Tracer      PayloadTracer
+	UseChecksum bool
+	// UseMsgPack encodes the reports with MessagePack instead of JSON
+	UseMsgPack bool
+	// QueryInterval is the agent polling interval, reported with each metric as its expected update interval
+	QueryInterval time.Duration
+	// AgentInfo is attached to each report, if set
+	AgentInfo *common.AgentInfo
+	// Transport sends the requests, nil uses http.DefaultTransport
+	Transport http.RoundTripper
+}
+
 type httpReporter struct {
-	endpoint string
-	apiKey   string
-	agentID  string
-	client   *http.Client
+	endpoint          string
+	apiKey            string
+	agentID           string
+	client            *http.Client
+	tracer            PayloadTracer
+	useChecksum       bool
+	useMsgPack        bool
+	intervalInSeconds int
+	agentInfo         *common.AgentInfo
 }
 
 // NewHTTPReporter creates a new reporter that pushes to the configured ReportEndpoint
-func NewHTTPReporter(endpoint, apiKey, agentID string, timeout time.Duration) *httpReporter {
+func NewHTTPReporter(args ArgsHTTPReporter) (*httpReporter, error) {
+	if check.IfNil(args.Tracer) {
+		return nil, errNilPayloadTracer
+	}
+
 	return &httpReporter{
-		endpoint: endpoint,
-		apiKey:   apiKey,
-		agentID:  agentID,
+		endpoint: args.Endpoint,
+		apiKey:   args.ApiKey,
+		agentID:  args.AgentID,
 		client: &http.Client{
-			Timeout: timeout,
+			Timeout:   args.Timeout,
+			Transport: args.Transport,
 		},
-	}
+		tracer:            args.Tracer,
+		useChecksum:       args.UseChecksum,
+		useMsgPack:        args.UseMsgPack,
+		intervalInSeconds: int(args.QueryInterval / time.Second),
+		agentInfo:         args.AgentInfo,
+	}, nil
 }
 
-func (r *httpReporter) Report(ctx context.Context, results map[string]common.MetricResult) error {
-	payload := common.ReportPayload{
-		Metrics: make(map[string]common.MetricPayload, len(results)+1), // +1 for heartbeat
-	}
+type endpointReporter struct {
+	endpoint string
+	reporter *httpReporter
+}
 
-	for name, res := range results {
-		payload.Metrics[name] = common.MetricPayload{
-			Value:          res.Value,
-			Type:           res.Config.Type,
-			NumAggregation: res.Config.NumAggregation,
+// createEndpointReporters creates an HTTP reporter for each endpoint, the other arguments are taken from the template
+func createEndpointReporters(endpoints []string, template ArgsHTTPReporter) ([]endpointReporter, error) {
+	reporters := make([]endpointReporter, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		args := template
+		args.Endpoint = endpoint
+		rep, err := NewHTTPReporter(args)
+		if err != nil {
+			return nil, err
 		}
-	}
 
-	// Always append heatbeat (agent metadata)
-	payload.Metrics[r.agentID+separator+activeHeartbeatName] = common.MetricPayload{
-		Value:          "true",
-		Type:           "bool",
-		NumAggregation: 1,
+		reporters = append(reporters, endpointReporter{
+			endpoint: endpoint,
+			reporter: rep,
+		})
 	}
 
+	return reporters, nil
+}
+
+// Report sends a payload containing the polled results and a heartbeat to the server
+func (r *httpReporter) Report(ctx context.Context, results map[string]common.MetricResult) error {
+	payload := createReportPayload(r.agentID, r.intervalInSeconds, results)
+	payload.Agent = r.agentInfo
+	payload.CycleID = common.CycleIDFromContext(ctx)
+
 	body, err := json.Marshal(payload)
 	if err != nil {
 		return fmt.Errorf("failed to marshal report payload: %w", err)
 	}
+	// the traces keep the JSON encoding, readable by the operators
+	tracedBody := body
+	contentType := commonGo.ContentTypeJSON
+	if r.useMsgPack {
+		body, err = commonGo.MarshalMsgPack(payload)
+		if err != nil {
+			return fmt.Errorf("failed to marshal report payload: %w", err)
+		}
+		contentType = commonGo.ContentTypeMsgPack
+	}
+
+	err = r.sendBody(ctx, body, tracedBody, contentType)
+	if errors.Is(err, errChecksumMismatch) {
+		// the payload was altered on the way, most probably by a flaky proxy, try once more right away
+		log.Warn("server reported a checksum mismatch, resending the report", "endpoint", r.endpoint)
+		err = r.sendBody(ctx, body, tracedBody, contentType)
+	}
+	if err != nil {
+		return err
+	}
 
+	log.Debug("successfully sent metrics report", "endpoint", r.endpoint, "metrics_count", len(payload.Metrics))
+
+	return nil
+}
+
+func (r *httpReporter) sendBody(ctx context.Context, body []byte, tracedBody []byte, contentType string) error {
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.endpoint, bytes.NewBuffer(body))
 	if err != nil {
 		return fmt.Errorf("failed to create report request: %w", err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Type", contentType)
 	req.Header.Set("X-Api-Key", r.apiKey)
+	req.Header.Set(commonGo.RequestIDHeader, commonGo.NewRequestID())
+	if r.useChecksum {
+		checksum := sha256.Sum256(body)
+		req.Header.Set(checksumHeader, hex.EncodeToString(checksum[:]))
+	}
+
+	start := time.Now()
+	statusCode, err := r.send(req)
+	r.trace(req, tracedBody, statusCode, err, time.Since(start))
+
+	return err
+}
 
+func (r *httpReporter) send(req *http.Request) (int, error) {
 	resp, err := r.client.Do(req)
 	if err != nil {
-		return fmt.Errorf("network error sending report: %w", err)
+		return 0, fmt.Errorf("network error sending report, request ID %s: %w", req.Header.Get(commonGo.RequestIDHeader), err)
 	}
 	defer func() {
 		_ = resp.Body.Close()
 	}()
 
+	if resp.StatusCode == http.StatusUnprocessableEntity && r.useChecksum {
+		return resp.StatusCode, fmt.Errorf("%w, status code: %d", errChecksumMismatch, resp.StatusCode)
+	}
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("server rejected report with status code: %d", resp.StatusCode)
+		return resp.StatusCode, fmt.Errorf("server rejected report with status code: %d, request ID %s",
+			resp.StatusCode, req.Header.Get(commonGo.RequestIDHeader))
 	}
 
-	log.Debug("successfully sent metrics report", "endpoint", r.endpoint, "metrics_count", len(payload.Metrics))
+	return resp.StatusCode, nil
+}
+
+func (r *httpReporter) trace(req *http.Request, body []byte, statusCode int, err error, duration time.Duration) {
+	entry := common.PayloadTraceEntry{
+		Timestamp:  time.Now().Unix(),
+		Endpoint:   req.URL.String(),
+		Headers:    make(map[string]string, len(req.Header)),
+		Payload:    body,
+		StatusCode: statusCode,
+		DurationMs: duration.Milliseconds(),
+	}
+	for key := range req.Header {
+		entry.Headers[key] = req.Header.Get(key)
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	r.tracer.Trace(entry)
+}
+
+// createReportPayload converts the polled results in the report payload, appending the agent heartbeat. All the metrics
+// carry the polling interval so the server knows how often to expect them, and the timestamp of their poll so the
+// delayed reports land at the right time in the history. The stale values are stamped by the server, as new samples.
+func createReportPayload(agentID string, intervalInSeconds int, results map[string]common.MetricResult) common.ReportPayload {
+	payload := common.ReportPayload{
+		Metrics: make(map[string]common.MetricPayload, len(results)+1), // +1 for heartbeat
+		SentAt:  time.Now().Unix(),
+	}
+
+	for name, res := range results {
+		metric := common.MetricPayload{
+			Value:          res.Value,
+			Type:           res.Config.Type,
+			NumAggregation: res.Config.NumAggregation,
+			Tags:           res.Config.Tags,
+			Interval:       intervalInSeconds,
+			Stale:          res.Stale,
+		}
+		if !res.Stale {
+			metric.RecordedAt = res.PolledAt
+		}
+		payload.Metrics[name] = metric
+	}
+
+	// Always append heatbeat (agent metadata)
+	payload.Metrics[agentID+separator+activeHeartbeatName] = common.MetricPayload{
+		Value:          "true",
+		Type:           "bool",
+		NumAggregation: 1,
+		Interval:       intervalInSeconds,
+	}
+
+	return payload
+}
 
+// Close does nothing as the HTTP reporter does not hold a connection
+func (r *httpReporter) Close() error {
 	return nil
 }
 
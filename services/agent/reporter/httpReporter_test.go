@@ -2,6 +2,10 @@ package reporter
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -9,11 +13,42 @@ import (
 	"testing"
 	"time"
 
+	"github.com/iulianpascalau/api-monitoring/commonGo"
 	"github.com/iulianpascalau/api-monitoring/services/agent/common"
 	"github.com/iulianpascalau/api-monitoring/services/agent/config"
+	"github.com/iulianpascalau/api-monitoring/services/agent/testsCommon"
 	"github.com/stretchr/testify/require"
 )
 
+func createMockArgsHTTPReporter(endpoint string) ArgsHTTPReporter {
+	return ArgsHTTPReporter{
+		Endpoint: endpoint,
+		ApiKey:   "secret123",
+		AgentID:  "AgentX",
+		Timeout:  2 * time.Second,
+		Tracer:   &testsCommon.PayloadTracerStub{},
+	}
+}
+
+func TestNewHTTPReporter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("nil tracer should error", func(t *testing.T) {
+		args := createMockArgsHTTPReporter("")
+		args.Tracer = nil
+
+		reporter, err := NewHTTPReporter(args)
+		require.Nil(t, reporter)
+		require.Equal(t, errNilPayloadTracer, err)
+	})
+	t.Run("should work", func(t *testing.T) {
+		reporter, err := NewHTTPReporter(createMockArgsHTTPReporter(""))
+		require.NotNil(t, reporter)
+		require.NoError(t, err)
+		require.False(t, reporter.IsInterfaceNil())
+	})
+}
+
 func TestHTTPReporter_Report(t *testing.T) {
 	var receivedBody string
 	var receivedAuth string
@@ -32,20 +67,290 @@ func TestHTTPReporter_Report(t *testing.T) {
 	}))
 	defer server.Close()
 
-	reporter := NewHTTPReporter(server.URL, "secret123", "AgentX", 2*time.Second)
+	reporter, err := NewHTTPReporter(createMockArgsHTTPReporter(server.URL))
+	require.NoError(t, err)
 
 	results := map[string]common.MetricResult{
 		"Node1": {
-			Config: config.EndpointConfig{Name: "Node1", Type: "uint64", NumAggregation: 10},
+			Config: config.EndpointConfig{Name: "Node1", Type: "uint64", NumAggregation: 10, Tags: map[string]string{"shard": "0"}},
 			Value:  "999",
 		},
 	}
 
-	err := reporter.Report(context.Background(), results)
+	err = reporter.Report(context.Background(), results)
 	require.NoError(t, err)
+	require.Contains(t, receivedBody, `"tags":{"shard":"0"}`)
 
 	require.Equal(t, "secret123", receivedAuth)
 	require.Contains(t, receivedBody, `"AgentX.Active"`)
 	require.Contains(t, receivedBody, `"Node1"`)
 	require.Contains(t, receivedBody, `"999"`)
+	require.NotContains(t, receivedBody, `"interval"`)
+}
+
+func TestHTTPReporter_ReportInterval(t *testing.T) {
+	receivedPayload := common.ReportPayload{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&receivedPayload)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	args := createMockArgsHTTPReporter(server.URL)
+	args.QueryInterval = 30 * time.Second
+	reporter, err := NewHTTPReporter(args)
+	require.NoError(t, err)
+
+	results := map[string]common.MetricResult{
+		"Node1": {
+			Config:   config.EndpointConfig{Name: "Node1", Type: "uint64", NumAggregation: 10},
+			Value:    "999",
+			PolledAt: 1000,
+		},
+		"Node2": {
+			Config:   config.EndpointConfig{Name: "Node2", Type: "uint64", NumAggregation: 10},
+			Value:    "998",
+			Stale:    true,
+			PolledAt: 900,
+		},
+	}
+	err = reporter.Report(context.Background(), results)
+	require.NoError(t, err)
+
+	require.Len(t, receivedPayload.Metrics, 3)
+	require.Equal(t, 30, receivedPayload.Metrics["Node1"].Interval)
+	require.Equal(t, 30, receivedPayload.Metrics["AgentX.Active"].Interval)
+	require.False(t, receivedPayload.Metrics["Node1"].Stale)
+	require.True(t, receivedPayload.Metrics["Node2"].Stale)
+
+	// the stale values and the heartbeat are stamped by the server
+	require.Equal(t, int64(1000), receivedPayload.Metrics["Node1"].RecordedAt)
+	require.Zero(t, receivedPayload.Metrics["Node2"].RecordedAt)
+	require.Zero(t, receivedPayload.Metrics["AgentX.Active"].RecordedAt)
+	require.InDelta(t, time.Now().Unix(), receivedPayload.SentAt, 5)
+}
+
+func TestHTTPReporter_ReportAgentInfo(t *testing.T) {
+	receivedPayload := common.ReportPayload{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&receivedPayload)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	agentInfo := &common.AgentInfo{
+		Name:       "AgentX",
+		Version:    "v1.2.3",
+		Commit:     "abcdef",
+		ConfigHash: "0123456789abcdef",
+	}
+	args := createMockArgsHTTPReporter(server.URL)
+	args.AgentInfo = agentInfo
+	reporter, err := NewHTTPReporter(args)
+	require.NoError(t, err)
+
+	err = reporter.Report(context.Background(), make(map[string]common.MetricResult))
+	require.NoError(t, err)
+	require.Equal(t, agentInfo, receivedPayload.Agent)
+}
+
+func TestHTTPReporter_ReportCycleID(t *testing.T) {
+	receivedPayloads := make([]common.ReportPayload, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload := common.ReportPayload{}
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		receivedPayloads = append(receivedPayloads, payload)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	reporter, err := NewHTTPReporter(createMockArgsHTTPReporter(server.URL))
+	require.NoError(t, err)
+
+	err = reporter.Report(common.WithCycleID(context.Background(), "0123456789abcdef"), make(map[string]common.MetricResult))
+	require.NoError(t, err)
+	err = reporter.Report(context.Background(), make(map[string]common.MetricResult))
+	require.NoError(t, err)
+
+	require.Len(t, receivedPayloads, 2)
+	require.Equal(t, "0123456789abcdef", receivedPayloads[0].CycleID)
+	require.Empty(t, receivedPayloads[1].CycleID)
+}
+
+func TestHTTPReporter_ReportMsgPack(t *testing.T) {
+	receivedPayload := common.ReportPayload{}
+	var contentType string
+	var checksum string
+	var decodeErr error
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		checksum = r.Header.Get(checksumHeader)
+		body, _ := io.ReadAll(r.Body)
+		expected := sha256.Sum256(body)
+		if checksum != hex.EncodeToString(expected[:]) {
+			checksum = "mismatch"
+		}
+		decodeErr = commonGo.UnmarshalMsgPack(body, &receivedPayload)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	var tracedEntries []common.PayloadTraceEntry
+	args := createMockArgsHTTPReporter(server.URL)
+	args.UseMsgPack = true
+	args.UseChecksum = true
+	args.QueryInterval = 30 * time.Second
+	args.Tracer = &testsCommon.PayloadTracerStub{
+		TraceHandler: func(entry common.PayloadTraceEntry) {
+			tracedEntries = append(tracedEntries, entry)
+		},
+	}
+	reporter, err := NewHTTPReporter(args)
+	require.NoError(t, err)
+
+	results := map[string]common.MetricResult{
+		"Node1": {
+			Config: config.EndpointConfig{Name: "Node1", Type: "uint64", NumAggregation: 10, Tags: map[string]string{"vm": "VM1"}},
+			Value:  "999",
+		},
+	}
+	err = reporter.Report(context.Background(), results)
+	require.NoError(t, err)
+
+	require.NoError(t, decodeErr)
+	require.Equal(t, commonGo.ContentTypeMsgPack, contentType)
+	require.NotEqual(t, "mismatch", checksum)
+	require.Len(t, receivedPayload.Metrics, 2)
+	require.Equal(t, "999", receivedPayload.Metrics["Node1"].Value)
+	require.Equal(t, map[string]string{"vm": "VM1"}, receivedPayload.Metrics["Node1"].Tags)
+	require.Equal(t, 30, receivedPayload.Metrics["AgentX.Active"].Interval)
+
+	// the traces keep the readable JSON encoding
+	require.Len(t, tracedEntries, 1)
+	require.True(t, json.Valid(tracedEntries[0].Payload))
+	require.Contains(t, string(tracedEntries[0].Payload), `"AgentX.Active"`)
+}
+
+func TestHTTPReporter_ReportChecksum(t *testing.T) {
+	t.Run("checksum disabled should not send the header", func(t *testing.T) {
+		receivedChecksum := "not called"
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			receivedChecksum = r.Header.Get(checksumHeader)
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		reporter, _ := NewHTTPReporter(createMockArgsHTTPReporter(server.URL))
+		err := reporter.Report(context.Background(), make(map[string]common.MetricResult))
+		require.NoError(t, err)
+		require.Empty(t, receivedChecksum)
+	})
+	t.Run("checksum enabled should send the body checksum", func(t *testing.T) {
+		receivedChecksum := ""
+		computedChecksum := ""
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			receivedChecksum = r.Header.Get(checksumHeader)
+			body, _ := io.ReadAll(r.Body)
+			checksum := sha256.Sum256(body)
+			computedChecksum = hex.EncodeToString(checksum[:])
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		args := createMockArgsHTTPReporter(server.URL)
+		args.UseChecksum = true
+		reporter, _ := NewHTTPReporter(args)
+		err := reporter.Report(context.Background(), make(map[string]common.MetricResult))
+		require.NoError(t, err)
+		require.NotEmpty(t, receivedChecksum)
+		require.Equal(t, computedChecksum, receivedChecksum)
+	})
+	t.Run("checksum mismatch should resend once", func(t *testing.T) {
+		numCalls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			numCalls++
+			if numCalls == 1 {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		args := createMockArgsHTTPReporter(server.URL)
+		args.UseChecksum = true
+		reporter, _ := NewHTTPReporter(args)
+		err := reporter.Report(context.Background(), make(map[string]common.MetricResult))
+		require.NoError(t, err)
+		require.Equal(t, 2, numCalls)
+	})
+	t.Run("persistent checksum mismatch should error", func(t *testing.T) {
+		numCalls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			numCalls++
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		}))
+		defer server.Close()
+
+		args := createMockArgsHTTPReporter(server.URL)
+		args.UseChecksum = true
+		reporter, _ := NewHTTPReporter(args)
+		err := reporter.Report(context.Background(), make(map[string]common.MetricResult))
+		require.True(t, errors.Is(err, errChecksumMismatch))
+		require.Equal(t, 2, numCalls)
+	})
+}
+
+func TestHTTPReporter_ReportShouldTrace(t *testing.T) {
+	t.Run("server accepts the report", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		var tracedEntries []common.PayloadTraceEntry
+		args := createMockArgsHTTPReporter(server.URL)
+		args.Tracer = &testsCommon.PayloadTracerStub{
+			TraceHandler: func(entry common.PayloadTraceEntry) {
+				tracedEntries = append(tracedEntries, entry)
+			},
+		}
+		reporter, _ := NewHTTPReporter(args)
+
+		err := reporter.Report(context.Background(), make(map[string]common.MetricResult))
+		require.NoError(t, err)
+
+		require.Len(t, tracedEntries, 1)
+		require.Equal(t, http.StatusOK, tracedEntries[0].StatusCode)
+		require.Equal(t, server.URL, tracedEntries[0].Endpoint)
+		require.Equal(t, "secret123", tracedEntries[0].Headers["X-Api-Key"]) // redacting is the tracer's job
+		require.Contains(t, string(tracedEntries[0].Payload), `"AgentX.Active"`)
+		require.Empty(t, tracedEntries[0].Error)
+	})
+	t.Run("server rejects the report", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer server.Close()
+
+		var tracedEntries []common.PayloadTraceEntry
+		args := createMockArgsHTTPReporter(server.URL)
+		args.Tracer = &testsCommon.PayloadTracerStub{
+			TraceHandler: func(entry common.PayloadTraceEntry) {
+				tracedEntries = append(tracedEntries, entry)
+			},
+		}
+		reporter, _ := NewHTTPReporter(args)
+
+		err := reporter.Report(context.Background(), make(map[string]common.MetricResult))
+		require.Error(t, err)
+
+		require.Len(t, tracedEntries, 1)
+		require.Equal(t, http.StatusBadRequest, tracedEntries[0].StatusCode)
+		require.Contains(t, tracedEntries[0].Error, "status code: 400")
+		// the request ID correlates the error with the aggregation service logs
+		requestID := tracedEntries[0].Headers[http.CanonicalHeaderKey(commonGo.RequestIDHeader)]
+		require.True(t, commonGo.IsValidRequestID(requestID))
+		require.Contains(t, err.Error(), "request ID "+requestID)
+	})
 }
@@ -0,0 +1,35 @@
+package reporter
+
+import (
+	"context"
+
+	"github.com/iulianpascalau/api-monitoring/services/agent/common"
+)
+
+// PayloadTracer defines the operations of a component able to record the exchanged report payloads
+type PayloadTracer interface {
+	Trace(entry common.PayloadTraceEntry)
+	IsInterfaceNil() bool
+}
+
+// MQTTClient defines the operations of an MQTT client able to publish messages
+type MQTTClient interface {
+	Connect(ctx context.Context) error
+	Publish(ctx context.Context, topic string, payload []byte, qos byte) error
+	IsConnected() bool
+	Close() error
+	IsInterfaceNil() bool
+}
+
+// Reporter defines the operations of a component able to push the polled metrics to the aggregation service
+type Reporter interface {
+	Report(ctx context.Context, results map[string]common.MetricResult) error
+	Close() error
+	IsInterfaceNil() bool
+}
+
+// FaultInjector defines the operations of a component injecting failures in the named points, used by the tests
+type FaultInjector interface {
+	Inject(ctx context.Context, point string) error
+	IsInterfaceNil() bool
+}
@@ -0,0 +1,136 @@
+package reporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/agent/common"
+	"github.com/multiversx/mx-chain-core-go/core/check"
+)
+
+const mqttEndpointPrefix = "mqtt://"
+
+// ArgsMQTTReporter defines the DTO struct for the NewMQTTReporter constructor function
+type ArgsMQTTReporter struct {
+	Client  MQTTClient
+	Topic   string
+	QoS     byte
+	ApiKey  string
+	AgentID string
+	Tracer  PayloadTracer
+	// QueryInterval is the agent polling interval, reported with each metric as its expected update interval
+	QueryInterval time.Duration
+	// AgentInfo is attached to each report, if set
+	AgentInfo *common.AgentInfo
+}
+
+type mqttReporter struct {
+	client            MQTTClient
+	topic             string
+	qos               byte
+	apiKey            string
+	agentID           string
+	tracer            PayloadTracer
+	intervalInSeconds int
+	agentInfo         *common.AgentInfo
+}
+
+// NewMQTTReporter creates a new reporter that publishes the reports on an MQTT topic, for the agents that can not
+// reach the aggregation service over HTTP. The broker connection is (re)established on demand.
+func NewMQTTReporter(args ArgsMQTTReporter) (*mqttReporter, error) {
+	if check.IfNil(args.Client) {
+		return nil, errNilMQTTClient
+	}
+	if len(args.Topic) == 0 {
+		return nil, errEmptyTopic
+	}
+	if check.IfNil(args.Tracer) {
+		return nil, errNilPayloadTracer
+	}
+
+	return &mqttReporter{
+		client:            args.Client,
+		topic:             args.Topic,
+		qos:               args.QoS,
+		apiKey:            args.ApiKey,
+		agentID:           args.AgentID,
+		tracer:            args.Tracer,
+		intervalInSeconds: int(args.QueryInterval / time.Second),
+		agentInfo:         args.AgentInfo,
+	}, nil
+}
+
+// Report publishes a payload containing the polled results and a heartbeat on the MQTT topic
+func (r *mqttReporter) Report(ctx context.Context, results map[string]common.MetricResult) error {
+	payload := common.MQTTReportPayload{
+		ApiKey:        r.apiKey,
+		ReportPayload: createReportPayload(r.agentID, r.intervalInSeconds, results),
+	}
+	payload.Agent = r.agentInfo
+	payload.CycleID = common.CycleIDFromContext(ctx)
+
+	message, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal report payload: %w", err)
+	}
+
+	start := time.Now()
+	err = r.publish(ctx, message)
+	r.trace(payload.ReportPayload, err, time.Since(start))
+	if err != nil {
+		return err
+	}
+
+	log.Debug("successfully published metrics report", "topic", r.topic, "metrics_count", len(payload.Metrics))
+
+	return nil
+}
+
+func (r *mqttReporter) publish(ctx context.Context, message []byte) error {
+	if !r.client.IsConnected() {
+		err := r.client.Connect(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to connect to the MQTT broker: %w", err)
+		}
+	}
+
+	err := r.client.Publish(ctx, r.topic, message, r.qos)
+	if err != nil {
+		return fmt.Errorf("failed to publish the report: %w", err)
+	}
+
+	return nil
+}
+
+// trace records the report without the API key, which is passed as a header so the tracer redacts it
+func (r *mqttReporter) trace(payload common.ReportPayload, err error, duration time.Duration) {
+	body, _ := json.Marshal(payload)
+	entry := common.PayloadTraceEntry{
+		Timestamp: time.Now().Unix(),
+		Endpoint:  mqttEndpointPrefix + r.topic,
+		Headers: map[string]string{
+			"X-Api-Key": r.apiKey,
+			"Qos":       strconv.Itoa(int(r.qos)),
+		},
+		Payload:    body,
+		DurationMs: duration.Milliseconds(),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	r.tracer.Trace(entry)
+}
+
+// Close disconnects from the broker
+func (r *mqttReporter) Close() error {
+	return r.client.Close()
+}
+
+// IsInterfaceNil returns true if the value under the interface is nil
+func (r *mqttReporter) IsInterfaceNil() bool {
+	return r == nil
+}
@@ -0,0 +1,157 @@
+package reporter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/services/agent/common"
+	"github.com/iulianpascalau/api-monitoring/services/agent/config"
+	"github.com/iulianpascalau/api-monitoring/services/agent/testsCommon"
+	"github.com/stretchr/testify/require"
+)
+
+func createMockArgsMQTTReporter() ArgsMQTTReporter {
+	return ArgsMQTTReporter{
+		Client:  &testsCommon.MQTTClientStub{},
+		Topic:   "monitoring/reports/AgentX",
+		QoS:     1,
+		ApiKey:  "secret123",
+		AgentID: "AgentX",
+		Tracer:  &testsCommon.PayloadTracerStub{},
+	}
+}
+
+func TestNewMQTTReporter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("nil client should error", func(t *testing.T) {
+		args := createMockArgsMQTTReporter()
+		args.Client = nil
+
+		reporter, err := NewMQTTReporter(args)
+		require.Nil(t, reporter)
+		require.Equal(t, errNilMQTTClient, err)
+	})
+	t.Run("empty topic should error", func(t *testing.T) {
+		args := createMockArgsMQTTReporter()
+		args.Topic = ""
+
+		reporter, err := NewMQTTReporter(args)
+		require.Nil(t, reporter)
+		require.Equal(t, errEmptyTopic, err)
+	})
+	t.Run("nil tracer should error", func(t *testing.T) {
+		args := createMockArgsMQTTReporter()
+		args.Tracer = nil
+
+		reporter, err := NewMQTTReporter(args)
+		require.Nil(t, reporter)
+		require.Equal(t, errNilPayloadTracer, err)
+	})
+	t.Run("should work", func(t *testing.T) {
+		reporter, err := NewMQTTReporter(createMockArgsMQTTReporter())
+		require.NotNil(t, reporter)
+		require.NoError(t, err)
+		require.False(t, reporter.IsInterfaceNil())
+	})
+}
+
+func TestMQTTReporter_Report(t *testing.T) {
+	t.Parallel()
+
+	results := map[string]common.MetricResult{
+		"AgentX.Node1.nonce": {
+			Config: config.EndpointConfig{Type: "uint64", NumAggregation: 10},
+			Value:  "100",
+		},
+	}
+
+	t.Run("should connect and publish", func(t *testing.T) {
+		numConnects := 0
+		var publishedTopic string
+		var publishedQoS byte
+		payload := common.MQTTReportPayload{}
+		var traced common.PayloadTraceEntry
+
+		args := createMockArgsMQTTReporter()
+		args.Client = &testsCommon.MQTTClientStub{
+			IsConnectedHandler: func() bool {
+				return numConnects > 0
+			},
+			ConnectHandler: func(ctx context.Context) error {
+				numConnects++
+				return nil
+			},
+			PublishHandler: func(ctx context.Context, topic string, message []byte, qos byte) error {
+				publishedTopic = topic
+				publishedQoS = qos
+				return json.Unmarshal(message, &payload)
+			},
+		}
+		args.Tracer = &testsCommon.PayloadTracerStub{
+			TraceHandler: func(entry common.PayloadTraceEntry) {
+				traced = entry
+			},
+		}
+		reporter, _ := NewMQTTReporter(args)
+
+		require.NoError(t, reporter.Report(context.Background(), results))
+		require.NoError(t, reporter.Report(context.Background(), results))
+
+		require.Equal(t, 1, numConnects)
+		require.Equal(t, "monitoring/reports/AgentX", publishedTopic)
+		require.Equal(t, byte(1), publishedQoS)
+		require.Equal(t, "secret123", payload.ApiKey)
+		require.Equal(t, "100", payload.Metrics["AgentX.Node1.nonce"].Value)
+		require.Equal(t, "true", payload.Metrics["AgentX.Active"].Value)
+
+		require.Equal(t, "mqtt://monitoring/reports/AgentX", traced.Endpoint)
+		require.Equal(t, "secret123", traced.Headers["X-Api-Key"])
+		require.NotContains(t, string(traced.Payload), "secret123")
+		require.Empty(t, traced.Error)
+	})
+	t.Run("connection error should be returned", func(t *testing.T) {
+		expectedErr := errors.New("connection refused")
+		var traced common.PayloadTraceEntry
+
+		args := createMockArgsMQTTReporter()
+		args.Client = &testsCommon.MQTTClientStub{
+			ConnectHandler: func(ctx context.Context) error {
+				return expectedErr
+			},
+			PublishHandler: func(ctx context.Context, topic string, message []byte, qos byte) error {
+				require.Fail(t, "should not publish")
+				return nil
+			},
+		}
+		args.Tracer = &testsCommon.PayloadTracerStub{
+			TraceHandler: func(entry common.PayloadTraceEntry) {
+				traced = entry
+			},
+		}
+		reporter, _ := NewMQTTReporter(args)
+
+		err := reporter.Report(context.Background(), results)
+		require.ErrorIs(t, err, expectedErr)
+		require.Contains(t, traced.Error, "connection refused")
+	})
+	t.Run("publish error should be returned", func(t *testing.T) {
+		expectedErr := errors.New("timeout")
+
+		args := createMockArgsMQTTReporter()
+		args.Client = &testsCommon.MQTTClientStub{
+			IsConnectedHandler: func() bool {
+				return true
+			},
+			PublishHandler: func(ctx context.Context, topic string, message []byte, qos byte) error {
+				return expectedErr
+			},
+		}
+		reporter, _ := NewMQTTReporter(args)
+
+		err := reporter.Report(context.Background(), results)
+		require.ErrorIs(t, err, expectedErr)
+	})
+}
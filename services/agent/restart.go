@@ -0,0 +1,14 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// restart replaces the agent process with the binary found at the executable path, keeping the PID, the arguments and
+// the environment, so the service manager does not notice the update
+func restart(executablePath string) error {
+	return syscall.Exec(executablePath, os.Args, os.Environ())
+}
@@ -0,0 +1,11 @@
+//go:build windows
+
+package main
+
+import "errors"
+
+// restart is not supported on Windows, which has no exec equivalent: the agent exits with an error so the service
+// manager starts the installed release
+func restart(_ string) error {
+	return errors.New("the agent release was installed, restart the agent to run it")
+}
@@ -0,0 +1,32 @@
+package testsCommon
+
+import (
+	"context"
+
+	"github.com/iulianpascalau/api-monitoring/services/agent/common"
+)
+
+// CollectorStub -
+type CollectorStub struct {
+	CollectHandler func(ctx context.Context) (map[string]common.MetricResult, error)
+	NameValue      string
+}
+
+// Collect -
+func (stub *CollectorStub) Collect(ctx context.Context) (map[string]common.MetricResult, error) {
+	if stub.CollectHandler != nil {
+		return stub.CollectHandler(ctx)
+	}
+
+	return make(map[string]common.MetricResult), nil
+}
+
+// Name -
+func (stub *CollectorStub) Name() string {
+	return stub.NameValue
+}
+
+// IsInterfaceNil -
+func (stub *CollectorStub) IsInterfaceNil() bool {
+	return stub == nil
+}
@@ -0,0 +1,52 @@
+package testsCommon
+
+import "context"
+
+// MQTTClientStub -
+type MQTTClientStub struct {
+	ConnectHandler     func(ctx context.Context) error
+	PublishHandler     func(ctx context.Context, topic string, payload []byte, qos byte) error
+	IsConnectedHandler func() bool
+	CloseHandler       func() error
+}
+
+// Connect -
+func (stub *MQTTClientStub) Connect(ctx context.Context) error {
+	if stub.ConnectHandler != nil {
+		return stub.ConnectHandler(ctx)
+	}
+
+	return nil
+}
+
+// Publish -
+func (stub *MQTTClientStub) Publish(ctx context.Context, topic string, payload []byte, qos byte) error {
+	if stub.PublishHandler != nil {
+		return stub.PublishHandler(ctx, topic, payload, qos)
+	}
+
+	return nil
+}
+
+// IsConnected -
+func (stub *MQTTClientStub) IsConnected() bool {
+	if stub.IsConnectedHandler != nil {
+		return stub.IsConnectedHandler()
+	}
+
+	return false
+}
+
+// Close -
+func (stub *MQTTClientStub) Close() error {
+	if stub.CloseHandler != nil {
+		return stub.CloseHandler()
+	}
+
+	return nil
+}
+
+// IsInterfaceNil -
+func (stub *MQTTClientStub) IsInterfaceNil() bool {
+	return stub == nil
+}
@@ -0,0 +1,30 @@
+package testsCommon
+
+import "github.com/iulianpascalau/api-monitoring/services/agent/common"
+
+// PayloadTracerStub -
+type PayloadTracerStub struct {
+	TraceHandler func(entry common.PayloadTraceEntry)
+	CloseHandler func() error
+}
+
+// Trace -
+func (stub *PayloadTracerStub) Trace(entry common.PayloadTraceEntry) {
+	if stub.TraceHandler != nil {
+		stub.TraceHandler(entry)
+	}
+}
+
+// Close -
+func (stub *PayloadTracerStub) Close() error {
+	if stub.CloseHandler != nil {
+		return stub.CloseHandler()
+	}
+
+	return nil
+}
+
+// IsInterfaceNil -
+func (stub *PayloadTracerStub) IsInterfaceNil() bool {
+	return stub == nil
+}
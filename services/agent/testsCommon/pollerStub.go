@@ -10,6 +10,7 @@ import (
 // PollerStub -
 type PollerStub struct {
 	PollAllHandler func(ctx context.Context, endpoints []config.EndpointConfig) map[string]common.MetricResult
+	PollHandler    func(ctx context.Context, endpoint config.EndpointConfig) (string, error)
 }
 
 // PollAll -
@@ -21,6 +22,15 @@ func (stub *PollerStub) PollAll(ctx context.Context, endpoints []config.Endpoint
 	return make(map[string]common.MetricResult)
 }
 
+// Poll -
+func (stub *PollerStub) Poll(ctx context.Context, endpoint config.EndpointConfig) (string, error) {
+	if stub.PollHandler != nil {
+		return stub.PollHandler(ctx, endpoint)
+	}
+
+	return "", nil
+}
+
 // IsInterfaceNil -
 func (stub *PollerStub) IsInterfaceNil() bool {
 	return stub == nil
@@ -20,6 +20,11 @@ func (stub *ReporterStub) Report(ctx context.Context, results map[string]common.
 	return nil
 }
 
+// Close -
+func (stub *ReporterStub) Close() error {
+	return nil
+}
+
 // IsInterfaceNil -
 func (stub *ReporterStub) IsInterfaceNil() bool {
 	return stub == nil
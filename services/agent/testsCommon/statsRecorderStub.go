@@ -0,0 +1,33 @@
+package testsCommon
+
+import (
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/agent/common"
+	"github.com/iulianpascalau/api-monitoring/services/agent/config"
+)
+
+// StatsRecorderStub -
+type StatsRecorderStub struct {
+	RecordPollHandler   func(endpoints []config.EndpointConfig, results map[string]common.MetricResult, duration time.Duration)
+	RecordReportHandler func(err error)
+}
+
+// RecordPoll -
+func (stub *StatsRecorderStub) RecordPoll(endpoints []config.EndpointConfig, results map[string]common.MetricResult, duration time.Duration) {
+	if stub.RecordPollHandler != nil {
+		stub.RecordPollHandler(endpoints, results, duration)
+	}
+}
+
+// RecordReport -
+func (stub *StatsRecorderStub) RecordReport(err error) {
+	if stub.RecordReportHandler != nil {
+		stub.RecordReportHandler(err)
+	}
+}
+
+// IsInterfaceNil -
+func (stub *StatsRecorderStub) IsInterfaceNil() bool {
+	return stub == nil
+}
@@ -0,0 +1,22 @@
+package testsCommon
+
+import "context"
+
+// UpdaterStub -
+type UpdaterStub struct {
+	CheckAndUpdateHandler func(ctx context.Context) (string, error)
+}
+
+// CheckAndUpdate -
+func (stub *UpdaterStub) CheckAndUpdate(ctx context.Context) (string, error) {
+	if stub.CheckAndUpdateHandler != nil {
+		return stub.CheckAndUpdateHandler(ctx)
+	}
+
+	return "", nil
+}
+
+// IsInterfaceNil -
+func (stub *UpdaterStub) IsInterfaceNil() bool {
+	return stub == nil
+}
@@ -0,0 +1,25 @@
+package tracer
+
+import "github.com/iulianpascalau/api-monitoring/services/agent/common"
+
+type disabledPayloadTracer struct {
+}
+
+// NewDisabledPayloadTracer creates a payload tracer that does nothing
+func NewDisabledPayloadTracer() *disabledPayloadTracer {
+	return &disabledPayloadTracer{}
+}
+
+// Trace does nothing
+func (tracer *disabledPayloadTracer) Trace(_ common.PayloadTraceEntry) {
+}
+
+// Close does nothing and returns nil
+func (tracer *disabledPayloadTracer) Close() error {
+	return nil
+}
+
+// IsInterfaceNil returns true if there is no value under the interface
+func (tracer *disabledPayloadTracer) IsInterfaceNil() bool {
+	return tracer == nil
+}
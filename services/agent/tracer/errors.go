@@ -0,0 +1,10 @@
+package tracer
+
+import "errors"
+
+var (
+	errEmptyDirectory      = errors.New("empty trace directory")
+	errInvalidMaxFileSize  = errors.New("invalid max file size")
+	errInvalidMaxNumFiles  = errors.New("invalid max number of files")
+	errTracerAlreadyClosed = errors.New("payload tracer already closed")
+)
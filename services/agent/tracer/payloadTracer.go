@@ -0,0 +1,213 @@
+package tracer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/iulianpascalau/api-monitoring/services/agent/common"
+	logger "github.com/multiversx/mx-chain-logger-go"
+)
+
+const (
+	traceFileName  = "payloads.trace"
+	redactedValue  = "[REDACTED]"
+	bytesInOneMB   = 1024 * 1024
+	filePermission = 0644
+)
+
+var log = logger.GetOrCreate("tracer")
+
+// sensitiveKeys contains the lowercase header names and query parameters that should never reach the trace file
+var sensitiveKeys = []string{"x-api-key", "authorization", "cookie", "token", "key", "secret", "password"}
+
+// ArgsPayloadTracer defines the DTO struct for the NewPayloadTracer constructor function
+type ArgsPayloadTracer struct {
+	Directory       string
+	MaxFileSizeInMB uint32
+	MaxNumFiles     uint32
+}
+
+type payloadTracer struct {
+	mut         sync.Mutex
+	directory   string
+	maxFileSize int64
+	maxNumFiles uint32
+	file        *os.File
+	currentSize int64
+}
+
+// NewPayloadTracer creates a new payload tracer that writes each traced entry as a JSON line in a rotating file
+func NewPayloadTracer(args ArgsPayloadTracer) (*payloadTracer, error) {
+	if len(args.Directory) == 0 {
+		return nil, errEmptyDirectory
+	}
+	if args.MaxFileSizeInMB == 0 {
+		return nil, errInvalidMaxFileSize
+	}
+	if args.MaxNumFiles == 0 {
+		return nil, errInvalidMaxNumFiles
+	}
+
+	err := os.MkdirAll(args.Directory, os.ModePerm)
+	if err != nil {
+		return nil, fmt.Errorf("%w while creating the trace directory", err)
+	}
+
+	tracer := &payloadTracer{
+		directory:   args.Directory,
+		maxFileSize: int64(args.MaxFileSizeInMB) * bytesInOneMB,
+		maxNumFiles: args.MaxNumFiles,
+	}
+
+	err = tracer.openFile()
+	if err != nil {
+		return nil, err
+	}
+
+	return tracer, nil
+}
+
+func (tracer *payloadTracer) openFile() error {
+	file, err := os.OpenFile(tracer.filePath(0), os.O_CREATE|os.O_WRONLY|os.O_APPEND, filePermission)
+	if err != nil {
+		return fmt.Errorf("%w while opening the trace file", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("%w while reading the trace file info", err)
+	}
+
+	tracer.file = file
+	tracer.currentSize = info.Size()
+
+	return nil
+}
+
+func (tracer *payloadTracer) filePath(index uint32) string {
+	if index == 0 {
+		return filepath.Join(tracer.directory, traceFileName)
+	}
+
+	return filepath.Join(tracer.directory, fmt.Sprintf("%s.%d", traceFileName, index))
+}
+
+// Trace redacts the secrets from the provided entry and appends it to the trace file
+func (tracer *payloadTracer) Trace(entry common.PayloadTraceEntry) {
+	entry.Endpoint = redactURL(entry.Endpoint)
+	entry.Headers = redactHeaders(entry.Headers)
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		log.Warn("failed to marshal the trace entry", "error", err)
+		return
+	}
+	line = append(line, '\n')
+
+	tracer.mut.Lock()
+	defer tracer.mut.Unlock()
+
+	if tracer.file == nil {
+		return
+	}
+
+	if tracer.currentSize+int64(len(line)) > tracer.maxFileSize && tracer.currentSize > 0 {
+		err = tracer.rotate()
+		if err != nil {
+			log.Warn("failed to rotate the trace file", "error", err)
+			return
+		}
+	}
+
+	n, err := tracer.file.Write(line)
+	tracer.currentSize += int64(n)
+	if err != nil {
+		log.Warn("failed to write the trace entry", "error", err)
+	}
+}
+
+// rotate shifts the existing trace files by one position, dropping the oldest one
+func (tracer *payloadTracer) rotate() error {
+	err := tracer.file.Close()
+	if err != nil {
+		return err
+	}
+	tracer.file = nil
+
+	_ = os.Remove(tracer.filePath(tracer.maxNumFiles - 1))
+	for i := tracer.maxNumFiles - 1; i > 0; i-- {
+		_ = os.Rename(tracer.filePath(i-1), tracer.filePath(i))
+	}
+
+	return tracer.openFile()
+}
+
+func redactHeaders(headers map[string]string) map[string]string {
+	result := make(map[string]string, len(headers))
+	for key, value := range headers {
+		if isSensitive(key) {
+			value = redactedValue
+		}
+		result[key] = value
+	}
+
+	return result
+}
+
+func redactURL(endpoint string) string {
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return endpoint
+	}
+
+	if parsed.User != nil {
+		parsed.User = url.User(redactedValue)
+	}
+
+	query := parsed.Query()
+	for key := range query {
+		if isSensitive(key) {
+			query.Set(key, redactedValue)
+		}
+	}
+	parsed.RawQuery = query.Encode()
+
+	return parsed.String()
+}
+
+func isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Close closes the underlying trace file
+func (tracer *payloadTracer) Close() error {
+	tracer.mut.Lock()
+	defer tracer.mut.Unlock()
+
+	if tracer.file == nil {
+		return errTracerAlreadyClosed
+	}
+
+	err := tracer.file.Close()
+	tracer.file = nil
+
+	return err
+}
+
+// IsInterfaceNil returns true if there is no value under the interface
+func (tracer *payloadTracer) IsInterfaceNil() bool {
+	return tracer == nil
+}
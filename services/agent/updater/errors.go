@@ -0,0 +1,17 @@
+package updater
+
+import "errors"
+
+var errEmptyManifestURL = errors.New("empty manifest URL")
+
+var errInvalidManifestURL = errors.New("invalid manifest URL")
+
+var errEmptyCurrentVersion = errors.New("empty current version")
+
+var errNoBinaryForPlatform = errors.New("the release manifest has no binary for this platform")
+
+var errBinaryTooLarge = errors.New("the release binary is too large")
+
+var errChecksumMismatch = errors.New("the release binary checksum does not match the manifest")
+
+var errUnexpectedStatus = errors.New("unexpected status code")
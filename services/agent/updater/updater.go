@@ -0,0 +1,282 @@
+package updater
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/commonGo/release"
+	logger "github.com/multiversx/mx-chain-logger-go"
+)
+
+var log = logger.GetOrCreate("updater")
+
+const (
+	defaultTimeout       = 5 * time.Minute
+	defaultMaxBinarySize = 256 * 1024 * 1024
+	maxManifestSize      = 1024 * 1024
+	// PreviousBinarySuffix is appended to the name of the replaced binary, kept for a manual rollback
+	PreviousBinarySuffix = ".previous"
+)
+
+// ArgsUpdater defines the DTO struct for the NewUpdater constructor function
+type ArgsUpdater struct {
+	// ManifestURL is the URL of the release manifest, the relative binary URLs are resolved against it
+	ManifestURL    string
+	PublicKey      ed25519.PublicKey
+	CurrentVersion string
+	// ExecutablePath is the binary replaced by the update, empty defaults to the running executable
+	ExecutablePath string
+	// Timeout bounds a whole check, including the binary download, defaults to 5 minutes
+	Timeout       time.Duration
+	MaxBinarySize int64
+}
+
+// updater installs the release published in the manifest when it is newer than the running version. The binary is
+// only installed after its signature and its checksum were verified; restarting the agent is left to the caller.
+type updater struct {
+	manifestURL    *url.URL
+	publicKey      ed25519.PublicKey
+	currentVersion string
+	executablePath string
+	maxBinarySize  int64
+	client         *http.Client
+}
+
+// NewUpdater creates a new updater
+func NewUpdater(args ArgsUpdater) (*updater, error) {
+	if len(args.ManifestURL) == 0 {
+		return nil, errEmptyManifestURL
+	}
+	manifestURL, err := url.Parse(args.ManifestURL)
+	if err != nil || (manifestURL.Scheme != "http" && manifestURL.Scheme != "https") || len(manifestURL.Host) == 0 {
+		return nil, fmt.Errorf("%w: %s", errInvalidManifestURL, args.ManifestURL)
+	}
+	if len(args.PublicKey) != ed25519.PublicKeySize {
+		return nil, release.ErrInvalidKey
+	}
+	if len(args.CurrentVersion) == 0 {
+		return nil, errEmptyCurrentVersion
+	}
+
+	executablePath := args.ExecutablePath
+	if len(executablePath) == 0 {
+		executablePath, err = os.Executable()
+		if err != nil {
+			return nil, err
+		}
+	}
+	executablePath, err = filepath.EvalSymlinks(executablePath)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &updater{
+		manifestURL:    manifestURL,
+		publicKey:      args.PublicKey,
+		currentVersion: args.CurrentVersion,
+		executablePath: executablePath,
+		maxBinarySize:  args.MaxBinarySize,
+		client:         &http.Client{Timeout: args.Timeout},
+	}
+	if u.client.Timeout <= 0 {
+		u.client.Timeout = defaultTimeout
+	}
+	if u.maxBinarySize <= 0 {
+		u.maxBinarySize = defaultMaxBinarySize
+	}
+
+	return u, nil
+}
+
+// ExecutablePath returns the path of the binary replaced by the updates
+func (u *updater) ExecutablePath() string {
+	return u.executablePath
+}
+
+// CheckAndUpdate fetches the release manifest and installs its binary if the version is newer than the running one.
+// It returns the installed version, or an empty string if the agent is up to date.
+func (u *updater) CheckAndUpdate(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, u.client.Timeout)
+	defer cancel()
+
+	manifest, err := u.fetchManifest(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(manifest.Version) == 0 {
+		return "", nil
+	}
+	// an older signed release replayed by whoever controls the manifest must not downgrade the agent
+	comparison, err := release.CompareVersions(manifest.Version, u.currentVersion)
+	if err != nil {
+		return "", err
+	}
+	if comparison <= 0 {
+		return "", nil
+	}
+
+	platform := release.Platform()
+	binary, found := manifest.Binaries[platform]
+	if !found {
+		return "", fmt.Errorf("%w: version %s, platform %s", errNoBinaryForPlatform, manifest.Version, platform)
+	}
+	// the binary is already installed, waiting for a restart, or its version is not the one it was built with:
+	// installing it again would restart the agent in a loop
+	if u.isInstalled(binary) {
+		return "", nil
+	}
+	err = release.Verify(u.publicKey, manifest.Version, platform, binary)
+	if err != nil {
+		return "", fmt.Errorf("%w: version %s, platform %s", err, manifest.Version, platform)
+	}
+
+	log.Info("downloading the agent release", "current version", u.currentVersion, "new version", manifest.Version)
+	err = u.install(ctx, binary)
+	if err != nil {
+		return "", fmt.Errorf("%w, version %s", err, manifest.Version)
+	}
+
+	log.Info("agent release installed", "version", manifest.Version, "path", u.executablePath)
+
+	return manifest.Version, nil
+}
+
+func (u *updater) isInstalled(binary release.Binary) bool {
+	file, err := os.Open(u.executablePath)
+	if err != nil {
+		return false
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	hasher := sha256.New()
+	_, err = io.Copy(hasher, file)
+	if err != nil {
+		return false
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)) == strings.ToLower(binary.SHA256)
+}
+
+func (u *updater) fetchManifest(ctx context.Context) (*release.Manifest, error) {
+	body, err := u.get(ctx, u.manifestURL.String())
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = body.Close()
+	}()
+
+	manifest := &release.Manifest{}
+	err = json.NewDecoder(io.LimitReader(body, maxManifestSize)).Decode(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("invalid release manifest: %w", err)
+	}
+
+	return manifest, nil
+}
+
+// install downloads the binary next to the executable, so the final rename stays on the same file system and is
+// atomic, then moves the running executable aside and the verified binary in its place
+func (u *updater) install(ctx context.Context, binary release.Binary) error {
+	binaryURL, err := u.manifestURL.Parse(binary.URL)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(u.executablePath)
+	tempFile, err := os.CreateTemp(dir, filepath.Base(u.executablePath)+".download-*")
+	if err != nil {
+		return err
+	}
+	tempPath := tempFile.Name()
+	defer func() {
+		_ = tempFile.Close()
+		_ = os.Remove(tempPath)
+	}()
+
+	err = u.download(ctx, binaryURL.String(), tempFile, binary.SHA256)
+	if err != nil {
+		return err
+	}
+	err = tempFile.Close()
+	if err != nil {
+		return err
+	}
+	err = os.Chmod(tempPath, 0755)
+	if err != nil {
+		return err
+	}
+
+	previousPath := u.executablePath + PreviousBinarySuffix
+	err = os.Rename(u.executablePath, previousPath)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tempPath, u.executablePath)
+	if err != nil {
+		_ = os.Rename(previousPath, u.executablePath)
+		return err
+	}
+
+	return nil
+}
+
+func (u *updater) download(ctx context.Context, binaryURL string, writer io.Writer, expectedSHA256 string) error {
+	body, err := u.get(ctx, binaryURL)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = body.Close()
+	}()
+
+	hasher := sha256.New()
+	numWritten, err := io.Copy(io.MultiWriter(writer, hasher), io.LimitReader(body, u.maxBinarySize+1))
+	if err != nil {
+		return err
+	}
+	if numWritten > u.maxBinarySize {
+		return errBinaryTooLarge
+	}
+	if hex.EncodeToString(hasher.Sum(nil)) != strings.ToLower(expectedSHA256) {
+		return errChecksumMismatch
+	}
+
+	return nil
+}
+
+func (u *updater) get(ctx context.Context, address string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, address, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("%w %d from %s", errUnexpectedStatus, resp.StatusCode, address)
+	}
+
+	return resp.Body, nil
+}
+
+// IsInterfaceNil returns true if there is no value under the interface
+func (u *updater) IsInterfaceNil() bool {
+	return u == nil
+}
@@ -0,0 +1,272 @@
+package updater
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/commonGo/release"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type releaseServerMock struct {
+	manifest release.Manifest
+	binary   []byte
+}
+
+func (mock *releaseServerMock) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/releases/manifest.json":
+		_ = json.NewEncoder(w).Encode(mock.manifest)
+	case "/releases/agent":
+		_, _ = w.Write(mock.binary)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func createReleaseServer(t *testing.T, privateKey ed25519.PrivateKey, version string, binary []byte) (*releaseServerMock, string) {
+	hash := sha256.Sum256(binary)
+	sha256Hex := hex.EncodeToString(hash[:])
+	mock := &releaseServerMock{
+		manifest: release.Manifest{
+			Version: version,
+			Binaries: map[string]release.Binary{
+				release.Platform(): {
+					URL:       "agent",
+					SHA256:    sha256Hex,
+					Signature: release.Sign(privateKey, version, release.Platform(), sha256Hex),
+				},
+			},
+		},
+		binary: binary,
+	}
+
+	server := httptest.NewServer(mock)
+	t.Cleanup(server.Close)
+
+	return mock, server.URL + "/releases/manifest.json"
+}
+
+func createMockArgs(t *testing.T, manifestURL string, publicKey ed25519.PublicKey) ArgsUpdater {
+	executablePath := filepath.Join(t.TempDir(), "agent")
+	require.NoError(t, os.WriteFile(executablePath, []byte("current binary"), 0755))
+
+	return ArgsUpdater{
+		ManifestURL:    manifestURL,
+		PublicKey:      publicKey,
+		CurrentVersion: "v1.0.0",
+		ExecutablePath: executablePath,
+	}
+}
+
+func generateKey(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	return publicKey, privateKey
+}
+
+func TestNewUpdater(t *testing.T) {
+	t.Parallel()
+
+	publicKey, _ := generateKey(t)
+
+	t.Run("empty manifest URL should error", func(t *testing.T) {
+		t.Parallel()
+
+		u, err := NewUpdater(createMockArgs(t, "", publicKey))
+		assert.Nil(t, u)
+		assert.Equal(t, errEmptyManifestURL, err)
+	})
+	t.Run("invalid manifest URL should error", func(t *testing.T) {
+		t.Parallel()
+
+		u, err := NewUpdater(createMockArgs(t, "ftp://releases.example.com/manifest.json", publicKey))
+		assert.Nil(t, u)
+		assert.True(t, errors.Is(err, errInvalidManifestURL))
+	})
+	t.Run("invalid public key should error", func(t *testing.T) {
+		t.Parallel()
+
+		u, err := NewUpdater(createMockArgs(t, "https://releases.example.com/manifest.json", publicKey[:10]))
+		assert.Nil(t, u)
+		assert.Equal(t, release.ErrInvalidKey, err)
+	})
+	t.Run("empty current version should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgs(t, "https://releases.example.com/manifest.json", publicKey)
+		args.CurrentVersion = ""
+		u, err := NewUpdater(args)
+		assert.Nil(t, u)
+		assert.Equal(t, errEmptyCurrentVersion, err)
+	})
+	t.Run("should work", func(t *testing.T) {
+		t.Parallel()
+
+		u, err := NewUpdater(createMockArgs(t, "https://releases.example.com/manifest.json", publicKey))
+		require.Nil(t, err)
+		assert.False(t, u.IsInterfaceNil())
+		assert.Equal(t, defaultTimeout, u.client.Timeout)
+		assert.Equal(t, int64(defaultMaxBinarySize), u.maxBinarySize)
+	})
+}
+
+func TestUpdater_CheckAndUpdate(t *testing.T) {
+	t.Parallel()
+
+	publicKey, privateKey := generateKey(t)
+
+	t.Run("same version should not update", func(t *testing.T) {
+		t.Parallel()
+
+		_, manifestURL := createReleaseServer(t, privateKey, "v1.0.0", []byte("new binary"))
+		u, err := NewUpdater(createMockArgs(t, manifestURL, publicKey))
+		require.Nil(t, err)
+
+		version, err := u.CheckAndUpdate(context.Background())
+		assert.Nil(t, err)
+		assert.Empty(t, version)
+		assertBinary(t, u.ExecutablePath(), "current binary")
+	})
+	t.Run("older signed version should not downgrade", func(t *testing.T) {
+		t.Parallel()
+
+		_, manifestURL := createReleaseServer(t, privateKey, "v0.9.0", []byte("old binary"))
+		u, err := NewUpdater(createMockArgs(t, manifestURL, publicKey))
+		require.Nil(t, err)
+
+		version, err := u.CheckAndUpdate(context.Background())
+		assert.Nil(t, err)
+		assert.Empty(t, version)
+		assertBinary(t, u.ExecutablePath(), "current binary")
+	})
+	t.Run("unordered version should error", func(t *testing.T) {
+		t.Parallel()
+
+		_, manifestURL := createReleaseServer(t, privateKey, "latest", []byte("new binary"))
+		u, err := NewUpdater(createMockArgs(t, manifestURL, publicKey))
+		require.Nil(t, err)
+
+		version, err := u.CheckAndUpdate(context.Background())
+		assert.True(t, errors.Is(err, release.ErrInvalidVersion))
+		assert.Empty(t, version)
+		assertBinary(t, u.ExecutablePath(), "current binary")
+	})
+	t.Run("new version should be installed", func(t *testing.T) {
+		t.Parallel()
+
+		_, manifestURL := createReleaseServer(t, privateKey, "v1.1.0", []byte("new binary"))
+		u, err := NewUpdater(createMockArgs(t, manifestURL, publicKey))
+		require.Nil(t, err)
+
+		version, err := u.CheckAndUpdate(context.Background())
+		require.Nil(t, err)
+		assert.Equal(t, "v1.1.0", version)
+		assertBinary(t, u.ExecutablePath(), "new binary")
+		assertBinary(t, u.ExecutablePath()+PreviousBinarySuffix, "current binary")
+
+		// the installed binary is not installed again while the old version is running
+		version, err = u.CheckAndUpdate(context.Background())
+		require.Nil(t, err)
+		assert.Empty(t, version)
+		assertBinary(t, u.ExecutablePath()+PreviousBinarySuffix, "current binary")
+
+		info, err := os.Stat(u.ExecutablePath())
+		require.Nil(t, err)
+		assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+		entries, err := os.ReadDir(filepath.Dir(u.ExecutablePath()))
+		require.Nil(t, err)
+		assert.Len(t, entries, 2)
+	})
+	t.Run("signature of another key should not update", func(t *testing.T) {
+		t.Parallel()
+
+		_, otherPrivateKey := generateKey(t)
+		_, manifestURL := createReleaseServer(t, otherPrivateKey, "v1.1.0", []byte("new binary"))
+		u, err := NewUpdater(createMockArgs(t, manifestURL, publicKey))
+		require.Nil(t, err)
+
+		version, err := u.CheckAndUpdate(context.Background())
+		assert.True(t, errors.Is(err, release.ErrInvalidSignature))
+		assert.Empty(t, version)
+		assertBinary(t, u.ExecutablePath(), "current binary")
+	})
+	t.Run("signature of another version should not update", func(t *testing.T) {
+		t.Parallel()
+
+		mock, manifestURL := createReleaseServer(t, privateKey, "v0.9.0", []byte("old binary"))
+		mock.manifest.Version = "v1.1.0"
+		u, err := NewUpdater(createMockArgs(t, manifestURL, publicKey))
+		require.Nil(t, err)
+
+		_, err = u.CheckAndUpdate(context.Background())
+		assert.True(t, errors.Is(err, release.ErrInvalidSignature))
+		assertBinary(t, u.ExecutablePath(), "current binary")
+	})
+	t.Run("tampered binary should not update", func(t *testing.T) {
+		t.Parallel()
+
+		mock, manifestURL := createReleaseServer(t, privateKey, "v1.1.0", []byte("new binary"))
+		mock.binary = []byte("tampered binary")
+		u, err := NewUpdater(createMockArgs(t, manifestURL, publicKey))
+		require.Nil(t, err)
+
+		_, err = u.CheckAndUpdate(context.Background())
+		assert.True(t, errors.Is(err, errChecksumMismatch))
+		assertBinary(t, u.ExecutablePath(), "current binary")
+		entries, err := os.ReadDir(filepath.Dir(u.ExecutablePath()))
+		require.Nil(t, err)
+		assert.Len(t, entries, 1)
+	})
+	t.Run("too large binary should not update", func(t *testing.T) {
+		t.Parallel()
+
+		_, manifestURL := createReleaseServer(t, privateKey, "v1.1.0", []byte("new binary"))
+		args := createMockArgs(t, manifestURL, publicKey)
+		args.MaxBinarySize = 5
+		u, err := NewUpdater(args)
+		require.Nil(t, err)
+
+		_, err = u.CheckAndUpdate(context.Background())
+		assert.True(t, errors.Is(err, errBinaryTooLarge))
+		assertBinary(t, u.ExecutablePath(), "current binary")
+	})
+	t.Run("missing platform should error", func(t *testing.T) {
+		t.Parallel()
+
+		mock, manifestURL := createReleaseServer(t, privateKey, "v1.1.0", []byte("new binary"))
+		mock.manifest.Binaries = map[string]release.Binary{"plan9-386": mock.manifest.Binaries[release.Platform()]}
+		u, err := NewUpdater(createMockArgs(t, manifestURL, publicKey))
+		require.Nil(t, err)
+
+		_, err = u.CheckAndUpdate(context.Background())
+		assert.True(t, errors.Is(err, errNoBinaryForPlatform))
+	})
+	t.Run("unreachable manifest should error", func(t *testing.T) {
+		t.Parallel()
+
+		_, manifestURL := createReleaseServer(t, privateKey, "v1.1.0", []byte("new binary"))
+		u, err := NewUpdater(createMockArgs(t, manifestURL+".missing", publicKey))
+		require.Nil(t, err)
+
+		_, err = u.CheckAndUpdate(context.Background())
+		assert.True(t, errors.Is(err, errUnexpectedStatus))
+	})
+}
+
+func assertBinary(t *testing.T, path string, expected string) {
+	contents, err := os.ReadFile(path)
+	require.Nil(t, err)
+	assert.Equal(t, expected, string(contents))
+}
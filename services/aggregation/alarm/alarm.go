@@ -3,10 +3,12 @@ package alarm
 import (
 	"context"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/config"
 	"github.com/multiversx/mx-chain-core-go/core/check"
 	logger "github.com/multiversx/mx-chain-logger-go"
 )
@@ -14,11 +16,19 @@ import (
 var log = logger.GetOrCreate("alarm")
 
 const (
-	alarmMessage = "Host appears offline"
+	alarmMessage         = "Host appears offline"
+	agentDownMessage     = "Agent appears offline, the metric is no longer reported"
+	recoveryMessage      = "Host is back online"
+	alarmDisabledMessage = "Alarm disabled"
+
+	// alertEventSuppressed is the history action recorded when a notification is muted by a silence
+	alertEventSuppressed = "suppressed"
 )
 
 type alarmService struct {
 	numSecondsToConsiderStale uint32
+	pendingDuration           time.Duration
+	rules                     []monotonicIncreaseRule
 	store                     Storage
 	outputNotifiersHandler    OutputNotifiersHandler
 	statusHandler             StatusHandler
@@ -28,19 +38,27 @@ type alarmService struct {
 	wg         sync.WaitGroup
 	loopTime   time.Duration
 
-	// Mutex and map to avoid spamming the same alarm
-	// Maps a metric name to the last time we triggered an alarm
-	mutTriggered     sync.Mutex
-	triggeredMetrics map[string]bool
+	// The active (not resolved) alerts, mapped by the metric name, so the same alarm is not sent again.
+	// They are persisted in the storage, so they survive the restarts.
+	mutAlerts    sync.Mutex
+	alertsLoaded bool
+	activeAlerts map[string]*common.Alert
+	// the silence that last suppressed the alert of each metric, so the suppression is recorded only once
+	suppressedAlerts map[string]int64
+	// the agent heartbeats of the current check, telling apart the metrics of a down agent
+	heartbeats common.AgentHeartbeats
 }
 
-// NewAlarmService creates a new alarm service
+// NewAlarmService creates a new alarm service. A stale metric, or one breaking a rule, raises a pending alert that
+// fires (and gets notified) once it was pending for at least the pending duration.
 func NewAlarmService(
 	store Storage,
 	outputNotifiersHandler OutputNotifiersHandler,
 	statusHandler StatusHandler,
 	numSecondsToConsiderStale uint32,
 	loopTime time.Duration,
+	pendingDuration time.Duration,
+	rulesConfig []config.AlarmRuleConfig,
 ) (*alarmService, error) {
 	if check.IfNil(store) {
 		return nil, fmt.Errorf("nil storage provided to alarm service")
@@ -57,14 +75,24 @@ func NewAlarmService(
 	if loopTime < time.Millisecond*10 {
 		return nil, fmt.Errorf("loop time must be greater than 10ms")
 	}
+	if pendingDuration < 0 {
+		return nil, fmt.Errorf("pending duration can not be negative")
+	}
+	rules, err := newRules(rulesConfig)
+	if err != nil {
+		return nil, err
+	}
 
 	return &alarmService{
 		numSecondsToConsiderStale: numSecondsToConsiderStale,
+		pendingDuration:           pendingDuration,
+		rules:                     rules,
 		store:                     store,
 		outputNotifiersHandler:    outputNotifiersHandler,
 		statusHandler:             statusHandler,
 		loopTime:                  loopTime,
-		triggeredMetrics:          make(map[string]bool),
+		activeAlerts:              make(map[string]*common.Alert),
+		suppressedAlerts:          make(map[string]int64),
 	}, nil
 }
 
@@ -127,37 +155,247 @@ func (as *alarmService) checkMetrics(ctx context.Context) {
 
 	log.Debug("alarm service fetched latest metrics to be checked", "num metrics", len(metrics))
 
-	metricsToNotify := make([]common.MetricHistory, 0)
+	as.mutAlerts.Lock()
+	defer as.mutAlerts.Unlock()
+
+	as.loadActiveAlerts(ctx)
+	as.heartbeats = common.CollectHeartbeats(metrics)
+
+	nowSec := time.Now().Unix()
+	silences := as.getActiveSilences(ctx, nowSec)
+	alertsToNotify := make([]*common.Alert, 0)
+	metricsRecovered := make([]common.MetricHistory, 0)
 	for _, m := range metrics {
-		if !as.shouldNotify(m) {
+		transition, alert := as.evaluateMetric(ctx, m, findSilence(silences, m.Name), nowSec)
+		if transition == common.AlertStateNone {
+			continue
+		}
+		if as.isSilenced(ctx, alert, nowSec) {
+			log.Debug("alarm service: silenced alert, notification skipped", "metric", m.Name, "transition", transition)
 			continue
 		}
 
-		metricsToNotify = append(metricsToNotify, m)
+		switch transition {
+		case common.AlertStateFiring:
+			alertsToNotify = append(alertsToNotify, alert)
+		case common.AlertStateResolved:
+			metricsRecovered = append(metricsRecovered, m)
+		}
 	}
 
-	if len(metricsToNotify) > 0 {
-		as.triggerAlarm(metricsToNotify, alarmMessage)
+	if len(alertsToNotify) > 0 {
+		as.triggerAlarm(alertsToNotify)
+	}
+	if len(metricsRecovered) > 0 {
+		as.resolveAlarm(metricsRecovered, recoveryMessage)
 	}
 }
 
-func (as *alarmService) shouldNotify(metric common.MetricHistory) bool {
-	if !metric.IsAlarmEnabled {
-		return false
+// loadActiveAlerts restores the alerts that were active before the restart, retrying on each check until it succeeds
+func (as *alarmService) loadActiveAlerts(ctx context.Context) {
+	if as.alertsLoaded {
+		return
+	}
+
+	alerts, err := as.store.GetActiveAlerts(ctx)
+	if err != nil {
+		log.Error("alarm service failed to load the active alerts", "error", err)
+		return
+	}
+
+	for i := range alerts {
+		_, found := as.activeAlerts[alerts[i].MetricName]
+		if !found {
+			as.activeAlerts[alerts[i].MetricName] = &alerts[i]
+		}
+	}
+	as.alertsLoaded = true
+}
+
+func (as *alarmService) getActiveSilences(ctx context.Context, nowSec int64) []common.Silence {
+	silences, err := as.store.GetActiveSilences(ctx, nowSec)
+	if err != nil {
+		// better to notify during a maintenance window than to miss a real problem
+		log.Error("alarm service failed to fetch the active silences", "error", err)
+		return nil
 	}
 
-	stale := as.isMetricStale(metric)
-	as.mutTriggered.Lock()
-	oldStale := as.triggeredMetrics[metric.Name]
-	as.triggeredMetrics[metric.Name] = stale
-	as.mutTriggered.Unlock()
+	return silences
+}
+
+func findSilence(silences []common.Silence, metricName string) *common.Silence {
+	for i := range silences {
+		if silences[i].Matches(metricName) {
+			return &silences[i]
+		}
+	}
+
+	return nil
+}
+
+// evaluateMetric advances the alert state machine of the metric, returning the transition that has to be
+// notified (firing or resolved) or AlertStateNone. A silenced metric keeps its alert pending, so it fires after
+// the silence ends if the metric is still stale, and its resolution is not notified.
+func (as *alarmService) evaluateMetric(
+	ctx context.Context,
+	metric common.MetricHistory,
+	silence *common.Silence,
+	nowSec int64,
+) (common.AlertState, *common.Alert) {
+	alert, found := as.activeAlerts[metric.Name]
+	problem, ok := as.findProblem(ctx, metric)
+	if !ok {
+		// the metric could not be checked, the alert state is kept
+		return common.AlertStateNone, alert
+	}
+	if len(problem) == 0 {
+		if !found {
+			return common.AlertStateNone, nil
+		}
+
+		delete(as.activeAlerts, metric.Name)
+		delete(as.suppressedAlerts, metric.Name)
+		if !metric.IsAlarmEnabled {
+			as.updateAlertState(ctx, alert, common.AlertStateResolved, alarmDisabledMessage, nowSec)
+			return common.AlertStateNone, alert
+		}
+
+		// a pending alert was never notified, so its resolution is not notified either
+		wasNotified := alert.State != common.AlertStatePending
+		as.updateAlertState(ctx, alert, common.AlertStateResolved, recoveryMessage, nowSec)
+		if !wasNotified {
+			return common.AlertStateNone, alert
+		}
+		if silence != nil {
+			as.recordSuppressed(ctx, alert, common.AlertStateResolved, silence, nowSec)
+			return common.AlertStateNone, alert
+		}
+
+		return common.AlertStateResolved, alert
+	}
 
-	if oldStale == stale {
+	if !found {
+		alert = as.createAlert(ctx, metric.Name, problem, nowSec)
+	}
+	if alert.State != common.AlertStatePending {
 		// nothing was changed, we should not notify
+		return common.AlertStateNone, alert
+	}
+	if nowSec-alert.StartedAt < int64(as.pendingDuration.Seconds()) {
+		return common.AlertStateNone, alert
+	}
+	if silence != nil {
+		if as.suppressedAlerts[metric.Name] != silence.ID {
+			as.suppressedAlerts[metric.Name] = silence.ID
+			as.recordSuppressed(ctx, alert, common.AlertStateFiring, silence, nowSec)
+		}
+		return common.AlertStateNone, alert
+	}
+
+	as.updateAlertState(ctx, alert, common.AlertStateFiring, "", nowSec)
+
+	return common.AlertStateFiring, alert
+}
+
+// findProblem returns the problem of an alarm enabled metric, stale or breaking a rule, or an empty string. It
+// returns false if the metric history needed by the rules could not be fetched.
+func (as *alarmService) findProblem(ctx context.Context, metric common.MetricHistory) (string, bool) {
+	if !metric.IsAlarmEnabled {
+		return "", true
+	}
+	if as.isMetricStale(metric) {
+		// the heartbeat itself keeps reporting the host as offline
+		isHeartbeat := strings.HasSuffix(metric.Name, "."+common.HeartbeatMetricSuffix)
+		if !isHeartbeat && as.heartbeats.IsAgentDown(metric.Name, time.Now().Unix(), int64(as.numSecondsToConsiderStale)) {
+			return agentDownMessage, true
+		}
+		return alarmMessage, true
+	}
+
+	var history *common.MetricHistory
+	for i := range as.rules {
+		if !as.rules[i].matches(metric.Name) {
+			continue
+		}
+		if history == nil {
+			var err error
+			history, err = as.store.GetMetricHistory(ctx, metric.Name)
+			if err != nil {
+				log.Error("alarm service failed to fetch the metric history", "metric", metric.Name, "error", err)
+				return "", false
+			}
+		}
+
+		problem := as.rules[i].check(history.History)
+		if len(problem) > 0 {
+			return problem, true
+		}
+	}
+
+	return "", true
+}
+
+func (as *alarmService) createAlert(ctx context.Context, metricName string, problem string, nowSec int64) *common.Alert {
+	alert := &common.Alert{
+		MetricName: metricName,
+		State:      common.AlertStatePending,
+		Problem:    problem,
+		StartedAt:  nowSec,
+	}
+	as.activeAlerts[metricName] = alert
+
+	var err error
+	alert.ID, err = as.store.CreateAlert(ctx, metricName, problem, nowSec)
+	if err != nil {
+		// the alert is still tracked in memory, the notifications do not depend on the storage
+		log.Error("alarm service failed to store the alert", "metric", metricName, "error", err)
+	}
+
+	return alert
+}
+
+func (as *alarmService) updateAlertState(ctx context.Context, alert *common.Alert, state common.AlertState, note string, nowSec int64) {
+	alert.State = state
+	if alert.ID == 0 {
+		// not persisted
+		return
+	}
+
+	err := as.store.UpdateAlertState(ctx, alert.ID, state, "", note, nowSec)
+	if err != nil {
+		log.Error("alarm service failed to update the alert state",
+			"metric", alert.MetricName, "alert", alert.ID, "state", state, "error", err)
+	}
+}
+
+func (as *alarmService) recordSuppressed(ctx context.Context, alert *common.Alert, transition common.AlertState, silence *common.Silence, nowSec int64) {
+	log.Debug("alarm service: notification suppressed by silence",
+		"metric", alert.MetricName, "transition", transition, "silence", silence.ID, "pattern", silence.Pattern)
+	if alert.ID == 0 {
+		// not persisted
+		return
+	}
+
+	note := fmt.Sprintf("%s notification suppressed by silence #%d (%s)", transition, silence.ID, silence.Pattern)
+	err := as.store.AddAlertEvent(ctx, alert.ID, alertEventSuppressed, "", note, nowSec)
+	if err != nil {
+		log.Error("alarm service failed to record the suppressed notification",
+			"metric", alert.MetricName, "alert", alert.ID, "error", err)
+	}
+}
+
+// isSilenced reads the stored alert, as the silences are set through the API
+func (as *alarmService) isSilenced(ctx context.Context, alert *common.Alert, nowSec int64) bool {
+	if alert == nil || alert.ID == 0 {
+		return false
+	}
+
+	storedAlert, err := as.store.GetAlert(ctx, alert.ID)
+	if err != nil {
 		return false
 	}
 
-	return stale
+	return storedAlert.IsSilenced(nowSec)
 }
 
 func (as *alarmService) isMetricStale(metric common.MetricHistory) bool {
@@ -169,18 +407,19 @@ func (as *alarmService) isMetricStale(metric common.MetricHistory) bool {
 	nowSec := time.Now().Unix()
 	diffSec := nowSec - lastVal.RecordedAt
 
-	return uint32(diffSec) >= as.numSecondsToConsiderStale
+	return diffSec >= common.StaleSeconds(metric.ExpectedInterval, int64(as.numSecondsToConsiderStale))
 }
 
-func (as *alarmService) triggerAlarm(metricsToNotify []common.MetricHistory, problem string) {
-	messages := make([]common.OutputMessage, 0, len(metricsToNotify))
+func (as *alarmService) triggerAlarm(alertsToNotify []*common.Alert) {
+	messages := make([]common.OutputMessage, 0, len(alertsToNotify))
 
-	for _, metric := range metricsToNotify {
+	for _, alert := range alertsToNotify {
 		msg := common.OutputMessage{
 			Type:               common.ErrorMessageOutputType, // mapping to defined constants in common package
-			Identifier:         metric.Name,
+			Identifier:         alert.MetricName,
 			ExecutorName:       common.ExecutorName,
-			ProblemEncountered: problem,
+			ProblemEncountered: alert.Problem,
+			State:              common.AlertStateFiring,
 		}
 
 		messages = append(messages, msg)
@@ -190,6 +429,25 @@ func (as *alarmService) triggerAlarm(metricsToNotify []common.MetricHistory, pro
 	as.statusHandler.CollectKeysProblems(messages)
 }
 
+// resolveAlarm notifies the recovery of the metrics, so the incidents opened for them can be closed
+func (as *alarmService) resolveAlarm(recoveredMetrics []common.MetricHistory, message string) {
+	messages := make([]common.OutputMessage, 0, len(recoveredMetrics))
+
+	for _, metric := range recoveredMetrics {
+		msg := common.OutputMessage{
+			Type:               common.InfoMessageOutputType,
+			Identifier:         metric.Name,
+			ExecutorName:       common.ExecutorName,
+			ProblemEncountered: message,
+			State:              common.AlertStateResolved,
+		}
+
+		messages = append(messages, msg)
+	}
+
+	_ = as.outputNotifiersHandler.NotifyWithRetry(fmt.Sprintf("%T", as), messages...)
+}
+
 // IsInterfaceNil returns true if the value under the interface is nil
 func (as *alarmService) IsInterfaceNil() bool {
 	return as == nil
@@ -2,13 +2,16 @@ package alarm
 
 import (
 	"context"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/config"
 	"github.com/iulianpascalau/api-monitoring/services/aggregation/testsCommon"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewAlarmService(t *testing.T) {
@@ -20,7 +23,9 @@ func TestNewAlarmService(t *testing.T) {
 			&testsCommon.OutputNotifiersHandlerStub{},
 			&testsCommon.StatusHandlerStub{},
 			1,
-			time.Second)
+			time.Second,
+			0,
+			nil)
 
 		assert.Nil(t, alarm)
 		assert.Error(t, err)
@@ -33,7 +38,9 @@ func TestNewAlarmService(t *testing.T) {
 			nil,
 			&testsCommon.StatusHandlerStub{},
 			1,
-			time.Second)
+			time.Second,
+			0,
+			nil)
 
 		assert.Nil(t, alarm)
 		assert.Error(t, err)
@@ -46,7 +53,9 @@ func TestNewAlarmService(t *testing.T) {
 			&testsCommon.OutputNotifiersHandlerStub{},
 			nil,
 			1,
-			time.Second)
+			time.Second,
+			0,
+			nil)
 
 		assert.Nil(t, alarm)
 		assert.Error(t, err)
@@ -59,7 +68,9 @@ func TestNewAlarmService(t *testing.T) {
 			&testsCommon.OutputNotifiersHandlerStub{},
 			&testsCommon.StatusHandlerStub{},
 			0,
-			time.Second)
+			time.Second,
+			0,
+			nil)
 
 		assert.Nil(t, alarm)
 		assert.Error(t, err)
@@ -72,20 +83,54 @@ func TestNewAlarmService(t *testing.T) {
 			&testsCommon.OutputNotifiersHandlerStub{},
 			&testsCommon.StatusHandlerStub{},
 			1,
-			time.Millisecond)
+			time.Millisecond,
+			0,
+			nil)
 
 		assert.Nil(t, alarm)
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "loop time must be greater than 10ms")
 		assert.True(t, alarm.IsInterfaceNil())
 	})
+	t.Run("negative pending duration should error", func(t *testing.T) {
+		alarm, err := NewAlarmService(
+			&testsCommon.StoreStub{},
+			&testsCommon.OutputNotifiersHandlerStub{},
+			&testsCommon.StatusHandlerStub{},
+			1,
+			time.Second,
+			-time.Second,
+			nil)
+
+		assert.Nil(t, alarm)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "pending duration can not be negative")
+		assert.True(t, alarm.IsInterfaceNil())
+	})
+	t.Run("invalid rule should error", func(t *testing.T) {
+		alarm, err := NewAlarmService(
+			&testsCommon.StoreStub{},
+			&testsCommon.OutputNotifiersHandlerStub{},
+			&testsCommon.StatusHandlerStub{},
+			1,
+			time.Second,
+			0,
+			[]config.AlarmRuleConfig{{Type: "increase", Pattern: "*.erd_nonce", NumSamples: 5}})
+
+		assert.Nil(t, alarm)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), `unsupported type "increase" for alarm rule 0`)
+		assert.True(t, alarm.IsInterfaceNil())
+	})
 	t.Run("should work", func(t *testing.T) {
 		alarm, err := NewAlarmService(
 			&testsCommon.StoreStub{},
 			&testsCommon.OutputNotifiersHandlerStub{},
 			&testsCommon.StatusHandlerStub{},
 			1,
-			time.Millisecond*10)
+			time.Millisecond*10,
+			0,
+			nil)
 
 		assert.NotNil(t, alarm)
 		assert.Nil(t, err)
@@ -107,7 +152,9 @@ func TestAlarmService_StartClose(t *testing.T) {
 		&testsCommon.OutputNotifiersHandlerStub{},
 		&testsCommon.StatusHandlerStub{},
 		1,
-		time.Millisecond*100)
+		time.Millisecond*100,
+		0,
+		nil)
 
 	time.Sleep(time.Second)
 	// numCalls should be 0 as we did not start the loop
@@ -187,7 +234,9 @@ func TestAlarmService_Notifications(t *testing.T) {
 				},
 			},
 			1,
-			time.Millisecond*100)
+			time.Millisecond*100,
+			0,
+			nil)
 
 		alarm.Start()
 		defer func() {
@@ -247,7 +296,9 @@ func TestAlarmService_Notifications(t *testing.T) {
 				},
 			},
 			1,
-			time.Millisecond*100)
+			time.Millisecond*100,
+			0,
+			nil)
 
 		alarm.Start()
 		defer func() {
@@ -303,7 +354,9 @@ func TestAlarmService_Notifications(t *testing.T) {
 				},
 			},
 			100,
-			time.Millisecond*100)
+			time.Millisecond*100,
+			0,
+			nil)
 
 		alarm.Start()
 		defer func() {
@@ -315,4 +368,403 @@ func TestAlarmService_Notifications(t *testing.T) {
 		assert.Equal(t, uint32(1), atomic.LoadUint32(&notifyWithRetryNumCalled))
 		assert.Equal(t, uint32(1), atomic.LoadUint32(&collectKeysProblemsNumCalled))
 	})
+	t.Run("recovered metric should notify the resolution", func(t *testing.T) {
+		t.Parallel()
+
+		recordedAt := int64(0)
+		mut := sync.Mutex{}
+		sentMessages := make([]common.OutputMessage, 0)
+		collectKeysProblemsNumCalled := uint32(0)
+
+		alarm, _ := NewAlarmService(
+			&testsCommon.StoreStub{
+				GetLatestMetricsHandler: func(ctx context.Context) ([]common.MetricHistory, error) {
+					metric1 := common.MetricHistory{
+						Name:           "metric1",
+						IsAlarmEnabled: true,
+						History: []common.MetricValue{
+							{
+								Value:      "1",
+								RecordedAt: atomic.LoadInt64(&recordedAt),
+							},
+						},
+					}
+
+					return []common.MetricHistory{metric1}, nil
+				},
+			},
+			&testsCommon.OutputNotifiersHandlerStub{
+				NotifyWithRetryHandler: func(caller string, messages ...common.OutputMessage) error {
+					mut.Lock()
+					sentMessages = append(sentMessages, messages...)
+					mut.Unlock()
+
+					return nil
+				},
+			},
+			&testsCommon.StatusHandlerStub{
+				CollectKeysProblemsHandler: func(messages []common.OutputMessage) {
+					atomic.AddUint32(&collectKeysProblemsNumCalled, 1)
+				},
+			},
+			100,
+			time.Millisecond*50,
+			0,
+			nil)
+
+		alarm.checkMetrics(context.Background())
+		atomic.StoreInt64(&recordedAt, time.Now().Unix())
+		alarm.checkMetrics(context.Background())
+		alarm.checkMetrics(context.Background())
+
+		mut.Lock()
+		defer mut.Unlock()
+		require.Len(t, sentMessages, 2)
+		assert.Equal(t, common.AlertStateFiring, sentMessages[0].State)
+		assert.Equal(t, common.ErrorMessageOutputType, sentMessages[0].Type)
+		assert.Equal(t, common.OutputMessage{
+			Type:               common.InfoMessageOutputType,
+			Identifier:         "metric1",
+			ExecutorName:       common.ExecutorName,
+			ProblemEncountered: recoveryMessage,
+			State:              common.AlertStateResolved,
+		}, sentMessages[1])
+		assert.Equal(t, uint32(1), atomic.LoadUint32(&collectKeysProblemsNumCalled))
+	})
+}
+
+func TestAlarmService_AlertStateMachine(t *testing.T) {
+	t.Parallel()
+
+	staleMetric := common.MetricHistory{
+		Name:           "metric1",
+		IsAlarmEnabled: true,
+	}
+	freshMetric := staleMetric
+	freshMetric.History = []common.MetricValue{{Value: "1", RecordedAt: time.Now().Unix()}}
+	staleMetric.History = []common.MetricValue{{Value: "1", RecordedAt: time.Now().Unix() - 1000}}
+
+	type stateUpdate struct {
+		id    int64
+		state common.AlertState
+		note  string
+	}
+
+	t.Run("pending alert should fire after the pending duration", func(t *testing.T) {
+		t.Parallel()
+
+		numNotifications := uint32(0)
+		updates := make([]stateUpdate, 0)
+		alarm, _ := NewAlarmService(
+			&testsCommon.StoreStub{
+				GetLatestMetricsHandler: func(ctx context.Context) ([]common.MetricHistory, error) {
+					return []common.MetricHistory{staleMetric}, nil
+				},
+				CreateAlertHandler: func(ctx context.Context, metricName string, problem string, startedAt int64) (int64, error) {
+					assert.Equal(t, "metric1", metricName)
+					assert.Equal(t, alarmMessage, problem)
+					return 7, nil
+				},
+				UpdateAlertStateHandler: func(ctx context.Context, id int64, state common.AlertState, actor string, note string, timestamp int64) error {
+					updates = append(updates, stateUpdate{id: id, state: state, note: note})
+					return nil
+				},
+			},
+			&testsCommon.OutputNotifiersHandlerStub{
+				NotifyWithRetryHandler: func(caller string, messages ...common.OutputMessage) error {
+					numNotifications++
+					return nil
+				},
+			},
+			&testsCommon.StatusHandlerStub{},
+			100,
+			time.Second,
+			time.Hour,
+			nil)
+
+		alarm.checkMetrics(context.Background())
+		assert.Equal(t, uint32(0), numNotifications)
+		assert.Equal(t, common.AlertStatePending, alarm.activeAlerts["metric1"].State)
+
+		// simulate the pending duration elapsed
+		alarm.activeAlerts["metric1"].StartedAt -= 3600
+		alarm.checkMetrics(context.Background())
+		assert.Equal(t, uint32(1), numNotifications)
+		assert.Equal(t, []stateUpdate{{id: 7, state: common.AlertStateFiring}}, updates)
+	})
+	t.Run("metrics of a down agent should report the agent problem", func(t *testing.T) {
+		t.Parallel()
+
+		staleNonce := staleMetric
+		staleNonce.Name = "VM1.Node1.nonce"
+		staleHeartbeat := staleMetric
+		staleHeartbeat.Name = "VM1.Active"
+		problems := make(map[string]string)
+		alarm, _ := NewAlarmService(
+			&testsCommon.StoreStub{
+				GetLatestMetricsHandler: func(ctx context.Context) ([]common.MetricHistory, error) {
+					return []common.MetricHistory{staleNonce, staleHeartbeat, staleMetric}, nil
+				},
+				CreateAlertHandler: func(ctx context.Context, metricName string, problem string, startedAt int64) (int64, error) {
+					problems[metricName] = problem
+					return int64(len(problems)), nil
+				},
+			},
+			&testsCommon.OutputNotifiersHandlerStub{},
+			&testsCommon.StatusHandlerStub{},
+			100,
+			time.Second,
+			time.Hour,
+			nil)
+
+		alarm.checkMetrics(context.Background())
+		assert.Equal(t, map[string]string{
+			"VM1.Node1.nonce": agentDownMessage,
+			"VM1.Active":      alarmMessage,
+			"metric1":         alarmMessage,
+		}, problems)
+	})
+	t.Run("pending alert resolved before firing should not notify", func(t *testing.T) {
+		t.Parallel()
+
+		metrics := []common.MetricHistory{staleMetric}
+		updates := make([]stateUpdate, 0)
+		alarm, _ := NewAlarmService(
+			&testsCommon.StoreStub{
+				GetLatestMetricsHandler: func(ctx context.Context) ([]common.MetricHistory, error) {
+					return metrics, nil
+				},
+				CreateAlertHandler: func(ctx context.Context, metricName string, problem string, startedAt int64) (int64, error) {
+					return 3, nil
+				},
+				UpdateAlertStateHandler: func(ctx context.Context, id int64, state common.AlertState, actor string, note string, timestamp int64) error {
+					updates = append(updates, stateUpdate{id: id, state: state, note: note})
+					return nil
+				},
+			},
+			&testsCommon.OutputNotifiersHandlerStub{
+				NotifyWithRetryHandler: func(caller string, messages ...common.OutputMessage) error {
+					assert.Fail(t, "should not notify")
+					return nil
+				},
+			},
+			&testsCommon.StatusHandlerStub{},
+			100,
+			time.Second,
+			time.Hour,
+			nil)
+
+		alarm.checkMetrics(context.Background())
+		metrics = []common.MetricHistory{freshMetric}
+		alarm.checkMetrics(context.Background())
+
+		assert.Empty(t, alarm.activeAlerts)
+		assert.Equal(t, []stateUpdate{{id: 3, state: common.AlertStateResolved, note: recoveryMessage}}, updates)
+	})
+	t.Run("restored active alert should not notify again", func(t *testing.T) {
+		t.Parallel()
+
+		numNotifications := uint32(0)
+		alarm, _ := NewAlarmService(
+			&testsCommon.StoreStub{
+				GetLatestMetricsHandler: func(ctx context.Context) ([]common.MetricHistory, error) {
+					return []common.MetricHistory{staleMetric}, nil
+				},
+				GetActiveAlertsHandler: func(ctx context.Context) ([]common.Alert, error) {
+					return []common.Alert{{ID: 1, MetricName: "metric1", State: common.AlertStateAcknowledged}}, nil
+				},
+				CreateAlertHandler: func(ctx context.Context, metricName string, problem string, startedAt int64) (int64, error) {
+					assert.Fail(t, "should not create a new alert")
+					return 0, nil
+				},
+			},
+			&testsCommon.OutputNotifiersHandlerStub{
+				NotifyWithRetryHandler: func(caller string, messages ...common.OutputMessage) error {
+					numNotifications++
+					return nil
+				},
+			},
+			&testsCommon.StatusHandlerStub{},
+			100,
+			time.Second,
+			0,
+			nil)
+
+		alarm.checkMetrics(context.Background())
+		alarm.checkMetrics(context.Background())
+		assert.Equal(t, uint32(0), numNotifications)
+	})
+	t.Run("silenced alert should not notify", func(t *testing.T) {
+		t.Parallel()
+
+		metrics := []common.MetricHistory{staleMetric}
+		alarm, _ := NewAlarmService(
+			&testsCommon.StoreStub{
+				GetLatestMetricsHandler: func(ctx context.Context) ([]common.MetricHistory, error) {
+					return metrics, nil
+				},
+				GetActiveAlertsHandler: func(ctx context.Context) ([]common.Alert, error) {
+					return []common.Alert{{ID: 1, MetricName: "metric1", State: common.AlertStateFiring}}, nil
+				},
+				GetAlertHandler: func(ctx context.Context, id int64) (*common.Alert, error) {
+					return &common.Alert{ID: id, SilencedUntil: time.Now().Unix() + 100}, nil
+				},
+			},
+			&testsCommon.OutputNotifiersHandlerStub{
+				NotifyWithRetryHandler: func(caller string, messages ...common.OutputMessage) error {
+					assert.Fail(t, "should not notify")
+					return nil
+				},
+			},
+			&testsCommon.StatusHandlerStub{},
+			100,
+			time.Second,
+			0,
+			nil)
+
+		alarm.checkMetrics(context.Background())
+		metrics = []common.MetricHistory{freshMetric}
+		alarm.checkMetrics(context.Background())
+		assert.Empty(t, alarm.activeAlerts)
+	})
+	t.Run("disabling the alarm should resolve the alert without notifying", func(t *testing.T) {
+		t.Parallel()
+
+		disabledMetric := staleMetric
+		disabledMetric.IsAlarmEnabled = false
+		updates := make([]stateUpdate, 0)
+		alarm, _ := NewAlarmService(
+			&testsCommon.StoreStub{
+				GetLatestMetricsHandler: func(ctx context.Context) ([]common.MetricHistory, error) {
+					return []common.MetricHistory{disabledMetric}, nil
+				},
+				GetActiveAlertsHandler: func(ctx context.Context) ([]common.Alert, error) {
+					return []common.Alert{{ID: 5, MetricName: "metric1", State: common.AlertStateFiring}}, nil
+				},
+				UpdateAlertStateHandler: func(ctx context.Context, id int64, state common.AlertState, actor string, note string, timestamp int64) error {
+					updates = append(updates, stateUpdate{id: id, state: state, note: note})
+					return nil
+				},
+			},
+			&testsCommon.OutputNotifiersHandlerStub{
+				NotifyWithRetryHandler: func(caller string, messages ...common.OutputMessage) error {
+					assert.Fail(t, "should not notify")
+					return nil
+				},
+			},
+			&testsCommon.StatusHandlerStub{},
+			100,
+			time.Second,
+			0,
+			nil)
+
+		alarm.checkMetrics(context.Background())
+		assert.Equal(t, []stateUpdate{{id: 5, state: common.AlertStateResolved, note: alarmDisabledMessage}}, updates)
+	})
+	t.Run("stalled counter should fire with the rule problem", func(t *testing.T) {
+		t.Parallel()
+
+		counter := freshMetric
+		counter.Name = "VM1.Node1.erd_nonce"
+		nonces := []string{"10", "11", "12"}
+		var problems []string
+		alarm, _ := NewAlarmService(
+			&testsCommon.StoreStub{
+				GetLatestMetricsHandler: func(ctx context.Context) ([]common.MetricHistory, error) {
+					return []common.MetricHistory{counter, freshMetric}, nil
+				},
+				GetMetricHistoryHandler: func(ctx context.Context, name string) (*common.MetricHistory, error) {
+					assert.Equal(t, "VM1.Node1.erd_nonce", name)
+					history := make([]common.MetricValue, 0, len(nonces))
+					for _, nonce := range nonces {
+						history = append(history, common.MetricValue{Value: nonce})
+					}
+					return &common.MetricHistory{Name: name, History: history}, nil
+				},
+			},
+			&testsCommon.OutputNotifiersHandlerStub{
+				NotifyWithRetryHandler: func(caller string, messages ...common.OutputMessage) error {
+					for _, msg := range messages {
+						problems = append(problems, msg.Identifier+": "+msg.ProblemEncountered)
+					}
+					return nil
+				},
+			},
+			&testsCommon.StatusHandlerStub{},
+			100,
+			time.Second,
+			0,
+			[]config.AlarmRuleConfig{{Type: config.AlarmRuleTypeMonotonicIncrease, Pattern: "*.erd_nonce", NumSamples: 3}})
+
+		alarm.checkMetrics(context.Background())
+		assert.Empty(t, problems)
+
+		nonces = []string{"10", "11", "12", "12", "12"}
+		alarm.checkMetrics(context.Background())
+		assert.Equal(t, []string{"VM1.Node1.erd_nonce: Counter did not increase over the last 3 samples"}, problems)
+
+		nonces = []string{"12", "12", "13"}
+		alarm.checkMetrics(context.Background())
+		assert.Equal(t, []string{
+			"VM1.Node1.erd_nonce: Counter did not increase over the last 3 samples",
+			"VM1.Node1.erd_nonce: " + recoveryMessage,
+		}, problems)
+	})
+	t.Run("metric matching an active silence should not fire until the silence ends", func(t *testing.T) {
+		t.Parallel()
+
+		type suppressedEvent struct {
+			id   int64
+			note string
+		}
+
+		silences := []common.Silence{{ID: 2, Pattern: "metric*", StartsAt: 0, EndsAt: time.Now().Unix() + 100}}
+		events := make([]suppressedEvent, 0)
+		updates := make([]stateUpdate, 0)
+		numNotifications := uint32(0)
+		alarm, _ := NewAlarmService(
+			&testsCommon.StoreStub{
+				GetLatestMetricsHandler: func(ctx context.Context) ([]common.MetricHistory, error) {
+					return []common.MetricHistory{staleMetric}, nil
+				},
+				GetActiveSilencesHandler: func(ctx context.Context, timestamp int64) ([]common.Silence, error) {
+					return silences, nil
+				},
+				CreateAlertHandler: func(ctx context.Context, metricName string, problem string, startedAt int64) (int64, error) {
+					return 4, nil
+				},
+				UpdateAlertStateHandler: func(ctx context.Context, id int64, state common.AlertState, actor string, note string, timestamp int64) error {
+					updates = append(updates, stateUpdate{id: id, state: state, note: note})
+					return nil
+				},
+				AddAlertEventHandler: func(ctx context.Context, id int64, action string, actor string, note string, timestamp int64) error {
+					assert.Equal(t, alertEventSuppressed, action)
+					events = append(events, suppressedEvent{id: id, note: note})
+					return nil
+				},
+			},
+			&testsCommon.OutputNotifiersHandlerStub{
+				NotifyWithRetryHandler: func(caller string, messages ...common.OutputMessage) error {
+					numNotifications++
+					return nil
+				},
+			},
+			&testsCommon.StatusHandlerStub{},
+			100,
+			time.Second,
+			0,
+			nil)
+
+		alarm.checkMetrics(context.Background())
+		alarm.checkMetrics(context.Background())
+		assert.Equal(t, uint32(0), numNotifications)
+		assert.Equal(t, common.AlertStatePending, alarm.activeAlerts["metric1"].State)
+		assert.Equal(t, []suppressedEvent{{id: 4, note: "firing notification suppressed by silence #2 (metric*)"}}, events)
+
+		// the silence ended
+		silences = nil
+		alarm.checkMetrics(context.Background())
+		assert.Equal(t, uint32(1), numNotifications)
+		assert.Equal(t, []stateUpdate{{id: 4, state: common.AlertStateFiring}}, updates)
+	})
 }
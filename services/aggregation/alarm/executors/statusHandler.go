@@ -3,6 +3,8 @@ package executors
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -13,6 +15,9 @@ import (
 
 const statusHandlerName = "statusHandler"
 
+// maxListedProblematicKeys is the maximum number of problematic keys named in the periodic digest
+const maxListedProblematicKeys = 10
+
 type statusHandler struct {
 	mut              sync.Mutex
 	numErrors        uint32
@@ -73,13 +78,17 @@ func (handler *statusHandler) Execute(_ context.Context) error {
 	handler.mut.Lock()
 	numErrors := atomic.SwapUint32(&handler.numErrors, 0)
 
-	numProblematicKeys := len(handler.problematicKeys)
+	problematicKeys := make([]string, 0, len(handler.problematicKeys))
+	for key := range handler.problematicKeys {
+		problematicKeys = append(problematicKeys, key)
+	}
 	handler.problematicKeys = make(map[string]struct{})
 	handler.mut.Unlock()
 
+	sort.Strings(problematicKeys)
 	messages := []common.OutputMessage{
 		handler.createErrorsMessage(numErrors),
-		handler.createProblematicKeysMessage(numProblematicKeys),
+		handler.createProblematicKeysMessage(problematicKeys),
 	}
 
 	return handler.notifiersHandler.NotifyWithRetry(statusHandlerName, messages...)
@@ -102,19 +111,20 @@ func (handler *statusHandler) createErrorsMessage(numErrors uint32) common.Outpu
 	return msg
 }
 
-func (handler *statusHandler) createProblematicKeysMessage(numProblematicKeys int) common.OutputMessage {
+func (handler *statusHandler) createProblematicKeysMessage(problematicKeys []string) common.OutputMessage {
 	msg := common.OutputMessage{
 		Type:         common.InfoMessageOutputType,
 		ExecutorName: common.ExecutorName,
 		Identifier:   "All monitored metrics are performing as expected",
 	}
 
-	if numProblematicKeys == 0 {
+	if len(problematicKeys) == 0 {
 		return msg
 	}
 
 	msg.Type = common.WarningMessageOutputType
-	msg.Identifier = fmt.Sprintf("%d monitored metrics encountered problems", numProblematicKeys)
+	msg.Identifier = fmt.Sprintf("%d monitored metrics encountered problems", len(problematicKeys))
+	msg.ProblemEncountered = createProblematicKeysList(problematicKeys)
 
 	return msg
 }
@@ -141,3 +151,13 @@ func (handler *statusHandler) SendCloseMessage() {
 func (handler *statusHandler) IsInterfaceNil() bool {
 	return handler == nil
 }
+
+// createProblematicKeysList lists the sorted problematic keys in the digest, truncated so the message stays readable
+func createProblematicKeysList(problematicKeys []string) string {
+	if len(problematicKeys) <= maxListedProblematicKeys {
+		return strings.Join(problematicKeys, ", ")
+	}
+
+	numRemaining := len(problematicKeys) - maxListedProblematicKeys
+	return fmt.Sprintf("%s and %d more", strings.Join(problematicKeys[:maxListedProblematicKeys], ", "), numRemaining)
+}
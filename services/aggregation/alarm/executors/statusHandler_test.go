@@ -3,6 +3,7 @@ package executors
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
@@ -157,9 +158,10 @@ func TestStatusHandler_Execute(t *testing.T) {
 			ExecutorName: common.ExecutorName,
 		}
 		expectedMessageKeys := common.OutputMessage{
-			Type:         common.WarningMessageOutputType,
-			Identifier:   "2 monitored metrics encountered problems",
-			ExecutorName: common.ExecutorName,
+			Type:               common.WarningMessageOutputType,
+			Identifier:         "2 monitored metrics encountered problems",
+			ProblemEncountered: "vm1, vm2",
+			ExecutorName:       common.ExecutorName,
 		}
 
 		assert.Equal(t, []common.OutputMessage{expectedMessageErr, expectedMessageKeys}, sentMessages)
@@ -212,3 +214,16 @@ func TestStatusHandler_SendCloseMessage(t *testing.T) {
 
 	assert.Equal(t, []common.OutputMessage{expectedMessage}, sentMessages)
 }
+
+func TestCreateProblematicKeysList(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "", createProblematicKeysList(nil))
+	assert.Equal(t, "a, b", createProblematicKeysList([]string{"a", "b"}))
+
+	keys := make([]string, 0, maxListedProblematicKeys+3)
+	for i := 0; i < maxListedProblematicKeys+3; i++ {
+		keys = append(keys, fmt.Sprintf("k%02d", i))
+	}
+	assert.Equal(t, "k00, k01, k02, k03, k04, k05, k06, k07, k08, k09 and 3 more", createProblematicKeysList(keys))
+}
@@ -17,7 +17,7 @@ type Storage interface {
 	// GetMetricHistory returns the definition and all retained values (up to NumAggregation) for a specific metric
 	GetMetricHistory(ctx context.Context, name string) (*common.MetricHistory, error)
 
-	// DeleteMetric removes a metric definition and all associated values
+	// DeleteMetric moves a metric to the trash, hiding it until restored or purged
 	DeleteMetric(ctx context.Context, name string) error
 
 	// UpdateMetricOrder updates the display order of a specific metric
@@ -32,6 +32,24 @@ type Storage interface {
 	// GetPanelsConfigs returns the display configurations for all panels
 	GetPanelsConfigs(ctx context.Context) (map[string]int, error)
 
+	// CreateAlert stores a new pending alert for a metric, returning its ID
+	CreateAlert(ctx context.Context, metricName string, problem string, startedAt int64) (int64, error)
+
+	// UpdateAlertState moves an alert to a new state, recording the transition in the alert history
+	UpdateAlertState(ctx context.Context, id int64, state common.AlertState, actor string, note string, timestamp int64) error
+
+	// GetActiveAlerts returns all the alerts that are not resolved yet
+	GetActiveAlerts(ctx context.Context) ([]common.Alert, error)
+
+	// GetAlert returns an alert together with its history
+	GetAlert(ctx context.Context, id int64) (*common.Alert, error)
+
+	// AddAlertEvent records an event in the history of an alert without changing its state
+	AddAlertEvent(ctx context.Context, id int64, action string, actor string, note string, timestamp int64) error
+
+	// GetActiveSilences returns the silences applying at the provided timestamp
+	GetActiveSilences(ctx context.Context, timestamp int64) ([]common.Silence, error)
+
 	// Close shuts down the database connection
 	Close() error
 
@@ -0,0 +1,44 @@
+package notifiers
+
+import (
+	"fmt"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+)
+
+// incidentDedupPrefix prefixes the incident keys, so the alerts of this service do not collide with other sources
+const incidentDedupPrefix = "api-monitoring/"
+
+// filterAlertMessages returns the messages reporting an alert transition. The informational ones (app start,
+// daily digest...) do not open incidents.
+func filterAlertMessages(messages []common.OutputMessage) []common.OutputMessage {
+	alerts := make([]common.OutputMessage, 0, len(messages))
+	for _, msg := range messages {
+		switch msg.State {
+		case common.AlertStateFiring:
+			// only the problems are worth paging someone
+			if msg.Type < common.WarningMessageOutputType {
+				continue
+			}
+		case common.AlertStateResolved:
+		default:
+			continue
+		}
+
+		alerts = append(alerts, msg)
+	}
+
+	return alerts
+}
+
+func createIncidentKey(msg common.OutputMessage) string {
+	return incidentDedupPrefix + msg.Identifier
+}
+
+func createIncidentSummary(msg common.OutputMessage) string {
+	if len(msg.ProblemEncountered) == 0 {
+		return msg.Identifier
+	}
+
+	return fmt.Sprintf("%s: %s", msg.Identifier, msg.ProblemEncountered)
+}
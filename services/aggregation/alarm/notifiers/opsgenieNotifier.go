@@ -0,0 +1,147 @@
+package notifiers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+)
+
+type opsgenieCreateRequest struct {
+	Message     string `json:"message"`
+	Alias       string `json:"alias"`
+	Description string `json:"description"`
+	Priority    string `json:"priority"`
+	Source      string `json:"source"`
+}
+
+type opsgenieCloseRequest struct {
+	Source string `json:"source"`
+	Note   string `json:"note"`
+}
+
+type opsgenieNotifier struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// NewOpsgenieNotifier will create a new Opsgenie notifier that creates an alert for each firing alert and closes
+// it when the alert recovers. The escalation is handled by the team's escalation policy.
+func NewOpsgenieNotifier(url string, apiKey string) *opsgenieNotifier {
+	return &opsgenieNotifier{
+		baseURL: strings.TrimSuffix(url, "/"),
+		apiKey:  apiKey,
+		httpClient: &http.Client{
+			Timeout: maxSendTimeout,
+		},
+	}
+}
+
+// OutputMessages will create or close the Opsgenie alerts of the alert messages, ignoring the other ones
+func (notifier *opsgenieNotifier) OutputMessages(messages ...common.OutputMessage) error {
+	alerts := filterAlertMessages(messages)
+	log.Debug("opsgenieNotifier.OutputMessages sending alerts", "num messages", len(messages), "num alerts", len(alerts))
+
+	var errs []error
+	for _, msg := range alerts {
+		err := notifier.sendAlert(msg)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%w for %s", err, msg.Identifier))
+		}
+	}
+
+	err := errors.Join(errs...)
+	if err != nil {
+		return fmt.Errorf("%w in opsgenieNotifier.OutputMessages", err)
+	}
+
+	return nil
+}
+
+func (notifier *opsgenieNotifier) sendAlert(msg common.OutputMessage) error {
+	alias := createIncidentKey(msg)
+	if msg.State == common.AlertStateResolved {
+		request := &opsgenieCloseRequest{
+			Source: msg.ExecutorName,
+			Note:   createIncidentSummary(msg),
+		}
+		endpoint := fmt.Sprintf("/v2/alerts/%s/close?identifierType=alias", url.PathEscape(alias))
+
+		return notifier.post(endpoint, request)
+	}
+
+	request := &opsgenieCreateRequest{
+		Message:     createIncidentSummary(msg),
+		Alias:       alias,
+		Description: msg.ProblemEncountered,
+		Priority:    opsgeniePriority(msg.Type),
+		Source:      msg.ExecutorName,
+	}
+
+	return notifier.post("/v2/alerts", request)
+}
+
+// opsgeniePriority maps the message severity to the Opsgenie alert priority
+func opsgeniePriority(messageType common.MessageOutputType) string {
+	switch messageType {
+	case common.ErrorMessageOutputType:
+		return "P1"
+	case common.WarningMessageOutputType:
+		return "P3"
+	default:
+		return "P5"
+	}
+}
+
+func (notifier *opsgenieNotifier) post(endpoint string, request interface{}) error {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), maxSendTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, notifier.baseURL+endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// the API key can only be provided as a header, the reason this notifier does not use the HTTP client wrapper
+	req.Header.Set("Authorization", "GenieKey "+notifier.apiKey)
+
+	resp, err := notifier.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if !common.IsHttpStatusCodeSuccess(resp.StatusCode) {
+		return fmt.Errorf("%w, but %d", errReturnCodeIsNotOk, resp.StatusCode)
+	}
+
+	log.Debug("opsgenieNotifier.post: sent request", "endpoint", endpoint, "status", resp.StatusCode)
+
+	return nil
+}
+
+// Name returns the name of the notifier
+func (notifier *opsgenieNotifier) Name() string {
+	return fmt.Sprintf("%T", notifier)
+}
+
+// IsInterfaceNil returns true if there is no value under the interface
+func (notifier *opsgenieNotifier) IsInterfaceNil() bool {
+	return notifier == nil
+}
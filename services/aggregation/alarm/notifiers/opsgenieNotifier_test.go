@@ -0,0 +1,115 @@
+package notifiers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type opsgenieRequest struct {
+	path          string
+	authorization string
+	body          map[string]string
+}
+
+func TestNewOpsgenieNotifier(t *testing.T) {
+	t.Parallel()
+
+	notifier := NewOpsgenieNotifier("url/", "")
+	assert.NotNil(t, notifier)
+	assert.Equal(t, "url", notifier.baseURL)
+}
+
+func TestOpsgenieNotifier_IsInterfaceNil(t *testing.T) {
+	t.Parallel()
+
+	var instance *opsgenieNotifier
+	assert.True(t, instance.IsInterfaceNil())
+
+	instance = &opsgenieNotifier{}
+	assert.False(t, instance.IsInterfaceNil())
+}
+
+func TestOpsgenieNotifier_Name(t *testing.T) {
+	t.Parallel()
+
+	notifier := NewOpsgenieNotifier("url", "")
+	assert.Equal(t, "*notifiers.opsgenieNotifier", notifier.Name())
+}
+
+func TestOpsgenieNotifier_OutputMessages(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should create and close the alerts", func(t *testing.T) {
+		t.Parallel()
+
+		mut := sync.Mutex{}
+		requests := make([]opsgenieRequest, 0)
+		testHttpServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			data, _ := io.ReadAll(req.Body)
+			request := opsgenieRequest{
+				path:          req.URL.RequestURI(),
+				authorization: req.Header.Get("Authorization"),
+			}
+			assert.Nil(t, json.Unmarshal(data, &request.body))
+
+			mut.Lock()
+			requests = append(requests, request)
+			mut.Unlock()
+
+			rw.WriteHeader(http.StatusAccepted)
+		}))
+		defer testHttpServer.Close()
+
+		warning := testFiringMessage
+		warning.Type = common.WarningMessageOutputType
+
+		notifier := NewOpsgenieNotifier(testHttpServer.URL, "api-key")
+		err := notifier.OutputMessages(testInfoMessage, testFiringMessage, warning, testResolvedMessage)
+		assert.Nil(t, err)
+
+		mut.Lock()
+		defer mut.Unlock()
+		require.Len(t, requests, 3)
+		assert.Equal(t, opsgenieRequest{
+			path:          "/v2/alerts",
+			authorization: "GenieKey api-key",
+			body: map[string]string{
+				"message":     "VM1.Node1.nonce: Host appears offline",
+				"alias":       "api-monitoring/VM1.Node1.nonce",
+				"description": "Host appears offline",
+				"priority":    "P1",
+				"source":      "executor",
+			},
+		}, requests[0])
+		assert.Equal(t, "P3", requests[1].body["priority"])
+		assert.Equal(t, opsgenieRequest{
+			path:          "/v2/alerts/api-monitoring%2FVM1.Node1.nonce/close?identifierType=alias",
+			authorization: "GenieKey api-key",
+			body: map[string]string{
+				"source": "executor",
+				"note":   "VM1.Node1.nonce: Host is back online",
+			},
+		}, requests[2])
+	})
+	t.Run("server errors should error", func(t *testing.T) {
+		t.Parallel()
+
+		testHttpServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.WriteHeader(http.StatusUnauthorized)
+		}))
+		defer testHttpServer.Close()
+
+		notifier := NewOpsgenieNotifier(testHttpServer.URL, "api-key")
+		err := notifier.OutputMessages(testFiringMessage, testResolvedMessage)
+		assert.ErrorIs(t, err, errReturnCodeIsNotOk)
+		assert.Contains(t, err.Error(), "401")
+	})
+}
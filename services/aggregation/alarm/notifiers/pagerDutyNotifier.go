@@ -0,0 +1,137 @@
+package notifiers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	httpSDK "github.com/multiversx/mx-sdk-go/core/http"
+)
+
+const (
+	pagerDutyActionTrigger = "trigger"
+	pagerDutyActionResolve = "resolve"
+)
+
+type pagerDutyPayload struct {
+	Summary  string `json:"summary"`
+	Source   string `json:"source"`
+	Severity string `json:"severity"`
+}
+
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+type pagerDutyResponse struct {
+	Status   string `json:"status"`
+	DedupKey string `json:"dedup_key"`
+}
+
+type pagerDutyNotifier struct {
+	routingKey        string
+	httpClientWrapper HTTPClientWrapper
+}
+
+// NewPagerDutyNotifier will create a new PagerDuty notifier that opens an incident (Events API v2) for each firing
+// alert and resolves it when the alert recovers. The escalation is handled by the service's escalation policy.
+func NewPagerDutyNotifier(url string, routingKey string) *pagerDutyNotifier {
+	return &pagerDutyNotifier{
+		httpClientWrapper: httpSDK.NewHttpClientWrapper(nil, url),
+		routingKey:        routingKey,
+	}
+}
+
+// OutputMessages will trigger or resolve the PagerDuty incidents of the alert messages, ignoring the other ones
+func (notifier *pagerDutyNotifier) OutputMessages(messages ...common.OutputMessage) error {
+	alerts := filterAlertMessages(messages)
+	log.Debug("pagerDutyNotifier.OutputMessages sending events", "num messages", len(messages), "num alerts", len(alerts))
+
+	var errs []error
+	for _, msg := range alerts {
+		err := notifier.sendEvent(createPagerDutyEvent(notifier.routingKey, msg))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%w for %s", err, msg.Identifier))
+		}
+	}
+
+	err := errors.Join(errs...)
+	if err != nil {
+		return fmt.Errorf("%w in pagerDutyNotifier.OutputMessages", err)
+	}
+
+	return nil
+}
+
+func createPagerDutyEvent(routingKey string, msg common.OutputMessage) *pagerDutyEvent {
+	event := &pagerDutyEvent{
+		RoutingKey:  routingKey,
+		EventAction: pagerDutyActionResolve,
+		DedupKey:    createIncidentKey(msg),
+	}
+	if msg.State == common.AlertStateResolved {
+		return event
+	}
+
+	event.EventAction = pagerDutyActionTrigger
+	event.Payload = &pagerDutyPayload{
+		Summary:  createIncidentSummary(msg),
+		Source:   msg.ExecutorName,
+		Severity: pagerDutySeverity(msg.Type),
+	}
+
+	return event
+}
+
+// pagerDutySeverity maps the message severity to the PagerDuty event severity, which drives the incident urgency
+func pagerDutySeverity(messageType common.MessageOutputType) string {
+	switch messageType {
+	case common.ErrorMessageOutputType:
+		return "critical"
+	case common.WarningMessageOutputType:
+		return "warning"
+	default:
+		return "info"
+	}
+}
+
+func (notifier *pagerDutyNotifier) sendEvent(event *pagerDutyEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), maxSendTimeout)
+	defer cancel()
+
+	responseBytes, statusCode, err := notifier.httpClientWrapper.PostHTTP(ctx, "", data)
+	if err != nil {
+		return err
+	}
+	if !common.IsHttpStatusCodeSuccess(statusCode) {
+		return fmt.Errorf("%w, but %d", errReturnCodeIsNotOk, statusCode)
+	}
+
+	resp := &pagerDutyResponse{}
+	_ = json.Unmarshal(responseBytes, resp)
+
+	log.Debug("pagerDutyNotifier.sendEvent: sent event",
+		"action", event.EventAction, "dedup key", event.DedupKey, "status", resp.Status)
+
+	return nil
+}
+
+// Name returns the name of the notifier
+func (notifier *pagerDutyNotifier) Name() string {
+	return fmt.Sprintf("%T", notifier)
+}
+
+// IsInterfaceNil returns true if there is no value under the interface
+func (notifier *pagerDutyNotifier) IsInterfaceNil() bool {
+	return notifier == nil
+}
@@ -0,0 +1,129 @@
+package notifiers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testFiringMessage = common.OutputMessage{
+	Type:               common.ErrorMessageOutputType,
+	ExecutorName:       "executor",
+	Identifier:         "VM1.Node1.nonce",
+	ProblemEncountered: "Host appears offline",
+	State:              common.AlertStateFiring,
+}
+
+var testResolvedMessage = common.OutputMessage{
+	Type:               common.InfoMessageOutputType,
+	ExecutorName:       "executor",
+	Identifier:         "VM1.Node1.nonce",
+	ProblemEncountered: "Host is back online",
+	State:              common.AlertStateResolved,
+}
+
+func TestNewPagerDutyNotifier(t *testing.T) {
+	t.Parallel()
+
+	notifier := NewPagerDutyNotifier("url", "")
+	assert.NotNil(t, notifier)
+}
+
+func TestPagerDutyNotifier_IsInterfaceNil(t *testing.T) {
+	t.Parallel()
+
+	var instance *pagerDutyNotifier
+	assert.True(t, instance.IsInterfaceNil())
+
+	instance = &pagerDutyNotifier{}
+	assert.False(t, instance.IsInterfaceNil())
+}
+
+func TestPagerDutyNotifier_Name(t *testing.T) {
+	t.Parallel()
+
+	notifier := NewPagerDutyNotifier("url", "")
+	assert.Equal(t, "*notifiers.pagerDutyNotifier", notifier.Name())
+}
+
+func TestPagerDutyNotifier_OutputMessages(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should trigger and resolve the incidents", func(t *testing.T) {
+		t.Parallel()
+
+		mut := sync.Mutex{}
+		events := make([]pagerDutyEvent, 0)
+		testHttpServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			data, _ := io.ReadAll(req.Body)
+			event := pagerDutyEvent{}
+			assert.Nil(t, json.Unmarshal(data, &event))
+
+			mut.Lock()
+			events = append(events, event)
+			mut.Unlock()
+
+			rw.WriteHeader(http.StatusAccepted)
+			_, _ = rw.Write([]byte(`{"status":"success","dedup_key":"` + event.DedupKey + `"}`))
+		}))
+		defer testHttpServer.Close()
+
+		warning := testFiringMessage
+		warning.Type = common.WarningMessageOutputType
+		warning.Identifier = "VM2.Node1.nonce"
+
+		notifier := NewPagerDutyNotifier(testHttpServer.URL, "routing-key")
+		err := notifier.OutputMessages(testInfoMessage, testFiringMessage, warning)
+		assert.Nil(t, err)
+		err = notifier.OutputMessages(testResolvedMessage)
+		assert.Nil(t, err)
+
+		mut.Lock()
+		defer mut.Unlock()
+		require.Len(t, events, 3)
+		assert.Equal(t, pagerDutyEvent{
+			RoutingKey:  "routing-key",
+			EventAction: pagerDutyActionTrigger,
+			DedupKey:    "api-monitoring/VM1.Node1.nonce",
+			Payload: &pagerDutyPayload{
+				Summary:  "VM1.Node1.nonce: Host appears offline",
+				Source:   "executor",
+				Severity: "critical",
+			},
+		}, events[0])
+		assert.Equal(t, "warning", events[1].Payload.Severity)
+		assert.Equal(t, "api-monitoring/VM2.Node1.nonce", events[1].DedupKey)
+		assert.Equal(t, pagerDutyEvent{
+			RoutingKey:  "routing-key",
+			EventAction: pagerDutyActionResolve,
+			DedupKey:    "api-monitoring/VM1.Node1.nonce",
+		}, events[2])
+	})
+	t.Run("messages without an alert state should not send events", func(t *testing.T) {
+		t.Parallel()
+
+		notifier := NewPagerDutyNotifier("not-a-server-URL", "routing-key")
+		err := notifier.OutputMessages(testInfoMessage)
+		assert.Nil(t, err)
+	})
+	t.Run("server errors should error", func(t *testing.T) {
+		t.Parallel()
+
+		testHttpServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.WriteHeader(http.StatusBadRequest)
+		}))
+		defer testHttpServer.Close()
+
+		notifier := NewPagerDutyNotifier(testHttpServer.URL, "routing-key")
+		err := notifier.OutputMessages(testFiringMessage)
+		assert.ErrorIs(t, err, errReturnCodeIsNotOk)
+		assert.Contains(t, err.Error(), "for VM1.Node1.nonce")
+	})
+}
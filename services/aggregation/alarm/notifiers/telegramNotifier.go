@@ -2,6 +2,7 @@ package notifiers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/url"
 
@@ -11,16 +12,16 @@ import (
 
 type telegramNotifier struct {
 	token             string
-	chatID            string
+	chatIDs           []string
 	httpClientWrapper HTTPClientWrapper
 }
 
-// NewTelegramNotifier will create a new Telegram notifier
-func NewTelegramNotifier(url string, token string, chatID string) *telegramNotifier {
+// NewTelegramNotifier will create a new Telegram notifier that sends the messages to all the provided chats
+func NewTelegramNotifier(url string, token string, chatIDs []string) *telegramNotifier {
 	return &telegramNotifier{
 		httpClientWrapper: httpSDK.NewHttpClientWrapper(nil, url),
 		token:             token,
-		chatID:            chatID,
+		chatIDs:           chatIDs,
 	}
 }
 
@@ -43,7 +44,16 @@ func (notifier *telegramNotifier) OutputMessages(messages ...common.OutputMessag
 
 	title := createTitle(maxMessageOutputType, messages[0].ExecutorName)
 
-	err := notifier.pushNotification(msgString, title)
+	// a failing chat does not prevent the delivery to the other ones
+	var errs []error
+	for _, chatID := range notifier.chatIDs {
+		err := notifier.pushNotification(chatID, msgString, title)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%w for chat %s", err, chatID))
+		}
+	}
+
+	err := errors.Join(errs...)
 	if err != nil {
 		return fmt.Errorf("%w in telegramNotifier.OutputMessages", err)
 	}
@@ -51,12 +61,12 @@ func (notifier *telegramNotifier) OutputMessages(messages ...common.OutputMessag
 	return nil
 }
 
-func (notifier *telegramNotifier) pushNotification(msgString string, title string) error {
+func (notifier *telegramNotifier) pushNotification(chatID string, msgString string, title string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), maxSendTimeout)
 	defer cancel()
 
 	urlVal := url.Values{
-		"chat_id":    {notifier.chatID},
+		"chat_id":    {chatID},
 		"parse_mode": {"html"},
 		"text":       {fmt.Sprintf("%s\n\n%s", title, msgString)},
 	}
@@ -71,7 +81,7 @@ func (notifier *telegramNotifier) pushNotification(msgString string, title strin
 	}
 
 	log.Debug("telegramNotifier.pushNotification: sent notification",
-		"status", statusCode)
+		"chat", chatID, "status", statusCode)
 
 	return nil
 }
@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -40,7 +42,7 @@ func createHttpTestServerThatRespondsOKForTelegram(
 func TestNewTelegramNotifier(t *testing.T) {
 	t.Parallel()
 
-	notifier := NewTelegramNotifier("url", "", "")
+	notifier := NewTelegramNotifier("url", "", nil)
 	assert.NotNil(t, notifier)
 }
 
@@ -57,7 +59,7 @@ func TestTelegramNotifier_IsInterfaceNil(t *testing.T) {
 func TestTelegramNotifier_Name(t *testing.T) {
 	t.Parallel()
 
-	notifier := NewTelegramNotifier("url", "", "")
+	notifier := NewTelegramNotifier("url", "", nil)
 	assert.Equal(t, "*notifiers.telegramNotifier", notifier.Name())
 }
 
@@ -73,7 +75,7 @@ func TestTelegramNotifier_OutputMessages(t *testing.T) {
 		testServer := createHttpTestServerThatRespondsOKForTelegram(t, expectedMessage, expectedTitle, &numCalls)
 		defer testServer.Close()
 
-		notifier := NewTelegramNotifier(testServer.URL, testTelegramToken, testTelegramChatID)
+		notifier := NewTelegramNotifier(testServer.URL, testTelegramToken, []string{testTelegramChatID})
 		err := notifier.OutputMessages()
 		assert.Nil(t, err)
 
@@ -83,7 +85,7 @@ func TestTelegramNotifier_OutputMessages(t *testing.T) {
 	t.Run("post method fails should error", func(t *testing.T) {
 		t.Parallel()
 
-		notifier := NewTelegramNotifier("not-a-server-URL", "", "")
+		notifier := NewTelegramNotifier("not-a-server-URL", "", []string{""})
 		err := notifier.OutputMessages(testInfoMessage)
 		assert.NotNil(t, err)
 		assert.Contains(t, err.Error(), "not-a-server-URL")
@@ -95,7 +97,7 @@ func TestTelegramNotifier_OutputMessages(t *testing.T) {
 			rw.WriteHeader(http.StatusInternalServerError)
 		}))
 
-		notifier := NewTelegramNotifier(testHttpServer.URL, "", "")
+		notifier := NewTelegramNotifier(testHttpServer.URL, "", []string{""})
 		err := notifier.OutputMessages(testInfoMessage)
 		assert.ErrorIs(t, err, errReturnCodeIsNotOk)
 	})
@@ -132,7 +134,7 @@ func TestTelegramNotifier_OutputMessages(t *testing.T) {
 		testServer := createHttpTestServerThatRespondsOKForTelegram(t, expectedMessage, expectedTitle, &numCalls)
 		defer testServer.Close()
 
-		notifier := NewTelegramNotifier(testServer.URL, testTelegramToken, testTelegramChatID)
+		notifier := NewTelegramNotifier(testServer.URL, testTelegramToken, []string{testTelegramChatID})
 		err := notifier.OutputMessages(msg1, msg2, msg3)
 		assert.Nil(t, err)
 
@@ -172,7 +174,7 @@ func TestTelegramNotifier_OutputMessages(t *testing.T) {
 		testServer := createHttpTestServerThatRespondsOKForTelegram(t, expectedMessage, expectedTitle, &numCalls)
 		defer testServer.Close()
 
-		notifier := NewTelegramNotifier(testServer.URL, testTelegramToken, testTelegramChatID)
+		notifier := NewTelegramNotifier(testServer.URL, testTelegramToken, []string{testTelegramChatID})
 		err := notifier.OutputMessages(msg1, msg2, msg3)
 		assert.Nil(t, err)
 
@@ -212,13 +214,42 @@ func TestTelegramNotifier_OutputMessages(t *testing.T) {
 		testServer := createHttpTestServerThatRespondsOKForTelegram(t, expectedMessage, expectedTitle, &numCalls)
 		defer testServer.Close()
 
-		notifier := NewTelegramNotifier(testServer.URL, testTelegramToken, testTelegramChatID)
+		notifier := NewTelegramNotifier(testServer.URL, testTelegramToken, []string{testTelegramChatID})
 		err := notifier.OutputMessages(msg1, msg2, msg3)
 		assert.Nil(t, err)
 
 		time.Sleep(time.Second)
 		assert.Equal(t, uint32(1), atomic.LoadUint32(&numCalls))
 	})
+	t.Run("should send to all the chats", func(t *testing.T) {
+		t.Parallel()
+
+		mut := sync.Mutex{}
+		chats := make([]string, 0)
+		testHttpServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			chatID := req.URL.Query().Get("chat_id")
+			mut.Lock()
+			chats = append(chats, chatID)
+			mut.Unlock()
+
+			if chatID == "chat2" {
+				rw.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			rw.WriteHeader(http.StatusOK)
+		}))
+		defer testHttpServer.Close()
+
+		notifier := NewTelegramNotifier(testHttpServer.URL, testTelegramToken, []string{"chat1", "chat2", "chat3"})
+		err := notifier.OutputMessages(testInfoMessage)
+		assert.ErrorIs(t, err, errReturnCodeIsNotOk)
+		assert.Contains(t, err.Error(), "for chat chat2")
+		assert.NotContains(t, err.Error(), "chat1")
+
+		mut.Lock()
+		assert.Equal(t, []string{"chat1", "chat2", "chat3"}, chats)
+		mut.Unlock()
+	})
 	t.Run("sending unknown type of messages should work", func(t *testing.T) {
 		t.Parallel()
 
@@ -252,7 +283,7 @@ func TestTelegramNotifier_OutputMessages(t *testing.T) {
 		testServer := createHttpTestServerThatRespondsOKForTelegram(t, expectedMessage, expectedTitle, &numCalls)
 		defer testServer.Close()
 
-		notifier := NewTelegramNotifier(testServer.URL, testTelegramToken, testTelegramChatID)
+		notifier := NewTelegramNotifier(testServer.URL, testTelegramToken, []string{testTelegramChatID})
 		err := notifier.OutputMessages(msg1, msg2, msg3)
 		assert.Nil(t, err)
 
@@ -273,7 +304,7 @@ func TestTelegramNotifier_FunctionalTest(t *testing.T) {
 	notifier := NewTelegramNotifier(
 		"https://api.telegram.org",
 		telegramBotToken,
-		telegramChatID,
+		strings.Split(telegramChatID, ","),
 	)
 
 	t.Run("info messages", func(t *testing.T) {
@@ -0,0 +1,73 @@
+package alarm
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/config"
+)
+
+// counterStalledMessage is the alert problem of the counters that did not increase
+const counterStalledMessage = "Counter did not increase over the last %d samples"
+
+const minRuleNumSamples = 2
+
+// monotonicIncreaseRule requires the counters matching the pattern to increase over the last numSamples values.
+// It is the main health signal of a MultiversX node: a nonce that does not increase means the node is stuck.
+type monotonicIncreaseRule struct {
+	pattern    string
+	numSamples int
+}
+
+func newRules(rulesConfig []config.AlarmRuleConfig) ([]monotonicIncreaseRule, error) {
+	rules := make([]monotonicIncreaseRule, 0, len(rulesConfig))
+	for i, ruleConfig := range rulesConfig {
+		if ruleConfig.Type != config.AlarmRuleTypeMonotonicIncrease {
+			return nil, fmt.Errorf("unsupported type %q for alarm rule %d", ruleConfig.Type, i)
+		}
+		_, err := path.Match(ruleConfig.Pattern, "")
+		if len(ruleConfig.Pattern) == 0 || err != nil {
+			return nil, fmt.Errorf("invalid pattern %q for alarm rule %d", ruleConfig.Pattern, i)
+		}
+		if ruleConfig.NumSamples < minRuleNumSamples {
+			return nil, fmt.Errorf("alarm rule %d needs at least %d samples, got %d", i, minRuleNumSamples, ruleConfig.NumSamples)
+		}
+
+		rules = append(rules, monotonicIncreaseRule{
+			pattern:    ruleConfig.Pattern,
+			numSamples: ruleConfig.NumSamples,
+		})
+	}
+
+	return rules, nil
+}
+
+func (rule *monotonicIncreaseRule) matches(metricName string) bool {
+	matched, err := path.Match(rule.pattern, metricName)
+
+	return err == nil && matched
+}
+
+// check returns the problem of the metric or an empty string. The history is sorted from the oldest value to the
+// newest one. The metrics without enough samples or with non-numeric values are not checked.
+func (rule *monotonicIncreaseRule) check(history []common.MetricValue) string {
+	if len(history) < rule.numSamples {
+		return ""
+	}
+
+	oldest, err := strconv.ParseFloat(history[len(history)-rule.numSamples].Value, 64)
+	if err != nil {
+		return ""
+	}
+	newest, err := strconv.ParseFloat(history[len(history)-1].Value, 64)
+	if err != nil {
+		return ""
+	}
+	if newest > oldest {
+		return ""
+	}
+
+	return fmt.Sprintf(counterStalledMessage, rule.numSamples)
+}
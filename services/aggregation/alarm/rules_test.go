@@ -0,0 +1,49 @@
+package alarm
+
+import (
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRules(t *testing.T) {
+	t.Parallel()
+
+	_, err := newRules([]config.AlarmRuleConfig{{Type: config.AlarmRuleTypeMonotonicIncrease, Pattern: "VM1.[", NumSamples: 3}})
+	assert.ErrorContains(t, err, `invalid pattern "VM1.[" for alarm rule 0`)
+
+	_, err = newRules([]config.AlarmRuleConfig{{Type: config.AlarmRuleTypeMonotonicIncrease, Pattern: "*.erd_nonce", NumSamples: 1}})
+	assert.ErrorContains(t, err, "alarm rule 0 needs at least 2 samples, got 1")
+
+	rules, err := newRules([]config.AlarmRuleConfig{{Type: config.AlarmRuleTypeMonotonicIncrease, Pattern: "*.erd_nonce", NumSamples: 3}})
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	assert.True(t, rules[0].matches("VM1.Node1.erd_nonce"))
+	assert.False(t, rules[0].matches("VM1.Node1.erd_epoch_number"))
+}
+
+func TestMonotonicIncreaseRule_Check(t *testing.T) {
+	t.Parallel()
+
+	rule := monotonicIncreaseRule{pattern: "*", numSamples: 3}
+	createHistory := func(values ...string) []common.MetricValue {
+		history := make([]common.MetricValue, 0, len(values))
+		for _, value := range values {
+			history = append(history, common.MetricValue{Value: value})
+		}
+		return history
+	}
+
+	// not enough samples
+	assert.Empty(t, rule.check(createHistory("5", "5")))
+	// not numeric
+	assert.Empty(t, rule.check(createHistory("a", "b", "c")))
+	// increasing over the last samples, even if not on each sample
+	assert.Empty(t, rule.check(createHistory("1", "1", "5", "5", "6")))
+	assert.Equal(t, "Counter did not increase over the last 3 samples", rule.check(createHistory("1", "5", "5", "5")))
+	// decreasing, e.g. a resynced node
+	assert.Equal(t, "Counter did not increase over the last 3 samples", rule.check(createHistory("9", "8", "7")))
+}
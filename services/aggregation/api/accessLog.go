@@ -0,0 +1,89 @@
+package api
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iulianpascalau/api-monitoring/commonGo"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	logger "github.com/multiversx/mx-chain-logger-go"
+)
+
+// accessLog is a distinct logger so the access lines can be filtered, e.g. -log-level *:INFO,api/access:DEBUG
+var accessLog logger.Logger = logger.GetOrCreate("api/access")
+
+const (
+	// requestIDKey holds, in the gin context, the ID of the request
+	requestIDKey = "requestID"
+	// maxLoggedErrorLength bounds the response body logged for the server errors
+	maxLoggedErrorLength = 512
+)
+
+// errorCapturingWriter keeps the beginning of the server error responses, so the error is logged with the request
+type errorCapturingWriter struct {
+	gin.ResponseWriter
+	body []byte
+}
+
+// Write captures the server error responses, then writes the data
+func (w *errorCapturingWriter) Write(data []byte) (int, error) {
+	w.capture(data)
+	return w.ResponseWriter.Write(data)
+}
+
+// WriteString captures the server error responses, then writes the string
+func (w *errorCapturingWriter) WriteString(data string) (int, error) {
+	w.capture([]byte(data))
+	return w.ResponseWriter.WriteString(data)
+}
+
+func (w *errorCapturingWriter) capture(data []byte) {
+	if w.Status() < 500 || len(w.body) >= maxLoggedErrorLength {
+		return
+	}
+
+	remaining := maxLoggedErrorLength - len(w.body)
+	if len(data) > remaining {
+		data = data[:remaining]
+	}
+	w.body = append(w.body, data...)
+}
+
+// requestLogger assigns the request ID (the one sent by the client, if valid) and writes the access log line once the
+// request is served. The request ID is returned in the response headers and carried in the request context, so the
+// storage logs can be matched with the request. The successful requests are logged at the debug level.
+func requestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(commonGo.RequestIDHeader)
+		if !commonGo.IsValidRequestID(requestID) {
+			requestID = commonGo.NewRequestID()
+		}
+		c.Set(requestIDKey, requestID)
+		c.Request = c.Request.WithContext(common.WithRequestID(c.Request.Context(), requestID))
+		c.Header(commonGo.RequestIDHeader, requestID)
+
+		writer := &errorCapturingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		args := []interface{}{
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"status", status,
+			"latency", time.Since(start),
+			"client", c.ClientIP(),
+			"request ID", requestID,
+		}
+		switch {
+		case status >= 500:
+			accessLog.Warn("request failed", append(args, "error", string(writer.body))...)
+		case status >= 400:
+			accessLog.Info("request rejected", args...)
+		default:
+			accessLog.Debug("request served", args...)
+		}
+	}
+}
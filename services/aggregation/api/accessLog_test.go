@@ -0,0 +1,93 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/commonGo"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/testsCommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_RequestLogger(t *testing.T) {
+	type logLine struct {
+		message string
+		args    map[interface{}]interface{}
+	}
+	var lines []logLine
+	record := func(message string, args ...interface{}) {
+		line := logLine{message: message, args: make(map[interface{}]interface{})}
+		for i := 0; i+1 < len(args); i += 2 {
+			line.args[args[i]] = args[i+1]
+		}
+		lines = append(lines, line)
+	}
+	originalAccessLog := accessLog
+	accessLog = &testsCommon.LoggerStub{DebugHandler: record, InfoHandler: record, WarnHandler: record}
+	defer func() {
+		accessLog = originalAccessLog
+	}()
+
+	var storageRequestID string
+	store := &testsCommon.StoreStub{
+		GetLatestMetricsFilteredHandler: func(ctx context.Context, filter common.MetricsFilter) ([]common.MetricHistory, error) {
+			storageRequestID = common.RequestIDFromContext(ctx)
+			return nil, errors.New("database is locked")
+		},
+	}
+	serv, err := NewServer(ArgsWebServer{
+		ServiceKeyApi:  "test-secret",
+		AuthUsername:   "admin",
+		AuthPassword:   "password",
+		ListenAddress:  ":0",
+		Storage:        store,
+		GeneralHandler: func(h http.Handler) http.Handler { return h },
+	})
+	require.NoError(t, err)
+	token := getValidToken(serv)
+
+	doRequest := func(url string, requestID string) *httptest.ResponseRecorder {
+		lines = nil
+		req, _ := http.NewRequest("GET", url, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set(commonGo.RequestIDHeader, requestID)
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+
+		return w
+	}
+
+	// the client request ID is kept and propagated to the storage
+	w := doRequest("/api/metrics", "agent-request-1")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "agent-request-1", w.Header().Get(commonGo.RequestIDHeader))
+	assert.Equal(t, "agent-request-1", storageRequestID)
+	require.Len(t, lines, 1)
+	assert.Equal(t, "request failed", lines[0].message)
+	assert.Equal(t, "GET", lines[0].args["method"])
+	assert.Equal(t, "/api/metrics", lines[0].args["path"])
+	assert.Equal(t, http.StatusInternalServerError, lines[0].args["status"])
+	assert.Equal(t, "agent-request-1", lines[0].args["request ID"])
+	assert.Contains(t, lines[0].args["error"], "database is locked")
+
+	// the invalid request IDs are replaced
+	w = doRequest("/api/app-info", "forged\nline")
+	assert.Equal(t, http.StatusOK, w.Code)
+	requestID := w.Header().Get(commonGo.RequestIDHeader)
+	assert.True(t, commonGo.IsValidRequestID(requestID))
+	require.Len(t, lines, 1)
+	assert.Equal(t, "request served", lines[0].message)
+	assert.Equal(t, requestID, lines[0].args["request ID"])
+	assert.NotContains(t, lines[0].args, "error")
+
+	w = doRequest("/api/missing", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NotEmpty(t, w.Header().Get(commonGo.RequestIDHeader))
+	require.Len(t, lines, 1)
+	assert.Equal(t, "request rejected", lines[0].message)
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+)
+
+const (
+	defaultMaxAgentLogEntries    = 100
+	maxAgentLogEntriesPerRequest = 100
+	maxAgentLogMessageLength     = 2048
+	maxAgentLogFieldLength       = 1024
+	maxAgentLogNumFields         = 32
+	maxAgentNameLength           = 256
+)
+
+var agentLogLevels = map[string]struct{}{
+	"warn":  {},
+	"error": {},
+}
+
+// AgentLogsPayload represents the incoming JSON body on /api/agents/:name/logs
+type AgentLogsPayload struct {
+	Entries []ReportedLogEntry `json:"entries"`
+}
+
+// ReportedLogEntry represents a warning or an error logged by an agent
+type ReportedLogEntry struct {
+	// Timestamp is the unix time the entry was logged at
+	Timestamp int64             `json:"timestamp"`
+	Level     string            `json:"level"`
+	Component string            `json:"component"`
+	Message   string            `json:"message"`
+	Fields    map[string]string `json:"fields,omitempty"`
+}
+
+// handleAgentLogs stores the warnings and the errors shipped by an agent, so the operators can see why its endpoints
+// fail without logging in on the agent host
+func (s *server) handleAgentLogs(c *gin.Context) {
+	agent := c.Param("name")
+	if len(agent) == 0 || len(agent) > maxAgentNameLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid agent name"})
+		return
+	}
+
+	var payload AgentLogsPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	if len(payload.Entries) > maxAgentLogEntriesPerRequest {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("too many entries, at most %d are accepted",
+			maxAgentLogEntriesPerRequest)})
+		return
+	}
+
+	tenant := c.GetString(sessionTenantKey)
+	now := time.Now().Unix()
+	entries := make([]common.AgentLogEntry, 0, len(payload.Entries))
+	for _, reported := range payload.Entries {
+		level := strings.ToLower(reported.Level)
+		_, supported := agentLogLevels[level]
+		if !supported {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unsupported log level %q", reported.Level)})
+			return
+		}
+
+		entries = append(entries, createAgentLogEntry(agent, tenant, level, reported, now))
+	}
+	if len(entries) == 0 {
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+		return
+	}
+
+	err := s.storage.SaveAgentLogs(c.Request.Context(), entries, s.maxAgentLogEntries)
+	if err != nil {
+		log.Warn("failed to save the agent logs", "agent", agent, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+func createAgentLogEntry(agent string, tenant string, level string, reported ReportedLogEntry, now int64) common.AgentLogEntry {
+	entry := common.AgentLogEntry{
+		Agent:      agent,
+		Tenant:     tenant,
+		Level:      level,
+		Component:  truncate(reported.Component, maxAgentLogFieldLength),
+		Message:    truncate(reported.Message, maxAgentLogMessageLength),
+		RecordedAt: reported.Timestamp,
+	}
+	if entry.RecordedAt <= 0 || entry.RecordedAt > now {
+		entry.RecordedAt = now
+	}
+
+	for key, value := range reported.Fields {
+		if len(entry.Fields) == maxAgentLogNumFields {
+			break
+		}
+		if entry.Fields == nil {
+			entry.Fields = make(map[string]string)
+		}
+		entry.Fields[truncate(key, maxAgentLogFieldLength)] = truncate(value, maxAgentLogFieldLength)
+	}
+
+	return entry
+}
+
+func truncate(value string, maxLength int) string {
+	if len(value) <= maxLength {
+		return value
+	}
+
+	return strings.ToValidUTF8(value[:maxLength], "")
+}
+
+// handleGetAgentLogs returns the warnings and the errors last shipped by an agent, newest first
+func (s *server) handleGetAgentLogs(c *gin.Context) {
+	entries, err := s.storage.GetAgentLogs(c.Request.Context(), c.GetString(sessionTenantKey), c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"logs": entries})
+}
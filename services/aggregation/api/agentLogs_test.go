@@ -0,0 +1,159 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func postAgentLogs(serv *server, agent string, payload AgentLogsPayload, apiKey string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(payload)
+	req, _ := http.NewRequest("POST", "/api/agents/"+agent+"/logs", bytes.NewBuffer(body))
+	req.Header.Set("X-Api-Key", apiKey)
+	w := httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+
+	return w
+}
+
+func getAgentLogs(t *testing.T, serv *server, agent string) []common.AgentLogEntry {
+	req, _ := http.NewRequest("GET", "/api/agents/"+agent+"/logs", nil)
+	req.Header.Set("Authorization", "Bearer "+getValidToken(serv))
+	w := httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Logs []common.AgentLogEntry `json:"logs"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+	return resp.Logs
+}
+
+func TestServer_AgentLogs(t *testing.T) {
+	serv, store := setupTestServer(t)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	payload := AgentLogsPayload{
+		Entries: []ReportedLogEntry{
+			{
+				Timestamp: 1000,
+				Level:     "WARN",
+				Component: "poller",
+				Message:   "endpoint failed",
+				Fields:    map[string]string{"metric": "VM1.Active", "error": "timeout"},
+			},
+			{
+				Timestamp: time.Now().Add(time.Hour).Unix(),
+				Level:     "error",
+				Component: "reporter",
+				Message:   strings.Repeat("a", maxAgentLogMessageLength+10),
+			},
+		},
+	}
+
+	w := postAgentLogs(serv, "VM1", payload, "wrong-secret")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = postAgentLogs(serv, "VM1", payload, "test-secret")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	req, _ := http.NewRequest("GET", "/api/agents/VM1/logs", nil)
+	w = httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	logs := getAgentLogs(t, serv, "VM1")
+	require.Len(t, logs, 2)
+	assert.Equal(t, "error", logs[0].Level)
+	assert.Equal(t, "reporter", logs[0].Component)
+	assert.Len(t, logs[0].Message, maxAgentLogMessageLength)
+	// a timestamp in the future is replaced with the receiving time
+	assert.LessOrEqual(t, logs[0].RecordedAt, time.Now().Unix())
+	assert.Equal(t, "warn", logs[1].Level)
+	assert.Equal(t, "endpoint failed", logs[1].Message)
+	assert.Equal(t, map[string]string{"metric": "VM1.Active", "error": "timeout"}, logs[1].Fields)
+	assert.Equal(t, int64(1000), logs[1].RecordedAt)
+
+	assert.Empty(t, getAgentLogs(t, serv, "VM2"))
+}
+
+func TestServer_AgentLogsInvalidPayload(t *testing.T) {
+	serv, store := setupTestServer(t)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	t.Run("unsupported level should error", func(t *testing.T) {
+		payload := AgentLogsPayload{
+			Entries: []ReportedLogEntry{{Level: "info", Message: "started"}},
+		}
+		w := postAgentLogs(serv, "VM1", payload, "test-secret")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+	t.Run("too many entries should error", func(t *testing.T) {
+		payload := AgentLogsPayload{}
+		for i := 0; i <= maxAgentLogEntriesPerRequest; i++ {
+			payload.Entries = append(payload.Entries, ReportedLogEntry{Level: "warn", Message: fmt.Sprint(i)})
+		}
+		w := postAgentLogs(serv, "VM1", payload, "test-secret")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+	t.Run("too many fields should be dropped", func(t *testing.T) {
+		entry := ReportedLogEntry{Level: "warn", Message: "fields", Fields: make(map[string]string)}
+		for i := 0; i < 2*maxAgentLogNumFields; i++ {
+			entry.Fields[fmt.Sprintf("field%d", i)] = "value"
+		}
+		w := postAgentLogs(serv, "VM1", AgentLogsPayload{Entries: []ReportedLogEntry{entry}}, "test-secret")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		logs := getAgentLogs(t, serv, "VM1")
+		require.Len(t, logs, 1)
+		assert.Len(t, logs[0].Fields, maxAgentLogNumFields)
+	})
+}
+
+func TestServer_AgentLogsRetention(t *testing.T) {
+	store, err := storage.NewSQLiteStorage(":memory:", 100, storage.SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	serv, err := NewServer(ArgsWebServer{
+		ServiceKeyApi:      "test-secret",
+		AuthUsername:       "admin",
+		AuthPassword:       "password",
+		ListenAddress:      ":0",
+		Storage:            store,
+		GeneralHandler:     func(h http.Handler) http.Handler { return h },
+		MaxAgentLogEntries: 3,
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		payload := AgentLogsPayload{
+			Entries: []ReportedLogEntry{{Level: "warn", Message: fmt.Sprintf("message %d", i)}},
+		}
+		w := postAgentLogs(serv, "VM1", payload, "test-secret")
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	logs := getAgentLogs(t, serv, "VM1")
+	require.Len(t, logs, 3)
+	assert.Equal(t, "message 4", logs[0].Message)
+	assert.Equal(t, "message 2", logs[2].Message)
+}
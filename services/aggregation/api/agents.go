@@ -0,0 +1,91 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+)
+
+// saveAgentInfo stores the details of the agent sending a report. As they rarely change, only the changes reach the
+// storage; a failed write is only logged and retried with the next report.
+func (s *server) saveAgentInfo(ctx context.Context, tenant string, reported ReportedAgent, timestamp int64) {
+	if len(reported.Name) == 0 {
+		return
+	}
+
+	info := common.AgentInfo{
+		Name:       reported.Name,
+		Tenant:     tenant,
+		Version:    reported.Version,
+		Commit:     reported.Commit,
+		ConfigHash: reported.ConfigHash,
+	}
+
+	s.mutAgents.Lock()
+	defer s.mutAgents.Unlock()
+
+	previous, found := s.knownAgents[info.Name]
+	if found && isSameAgentBuild(previous, info) {
+		return
+	}
+
+	info.UpdatedAt = timestamp
+	err := s.storage.SaveAgentInfo(ctx, info)
+	if err != nil {
+		log.Warn("failed to save the agent info", "agent", info.Name, "error", err)
+		return
+	}
+	if found {
+		log.Info("agent build or configuration changed", "agent", info.Name, "version", info.Version,
+			"commit", info.Commit, "config hash", info.ConfigHash)
+	}
+	s.knownAgents[info.Name] = info
+}
+
+func isSameAgentBuild(previous common.AgentInfo, current common.AgentInfo) bool {
+	return previous.Tenant == current.Tenant &&
+		previous.Version == current.Version &&
+		previous.Commit == current.Commit &&
+		previous.ConfigHash == current.ConfigHash
+}
+
+// handleGetAgents returns the build and configuration details of the agents, together with their last heartbeat, so
+// the agents running outdated builds can be spotted
+func (s *server) handleGetAgents(c *gin.Context) {
+	tenant := c.GetString(sessionTenantKey)
+	agents, err := s.storage.GetAgents(c.Request.Context(), tenant)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	latest, err := s.storage.GetLatestMetricsFiltered(c.Request.Context(), common.MetricsFilter{Tenant: tenant})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	heartbeats := common.CollectHeartbeats(latest)
+	now := time.Now().Unix()
+	staleSeconds := s.staleSeconds()
+
+	type responseAgent struct {
+		common.AgentInfo
+		LastReportAt int64 `json:"lastReportAt"`
+		Stale        bool  `json:"stale"`
+	}
+
+	out := make([]responseAgent, 0, len(agents))
+	for _, agent := range agents {
+		heartbeat := heartbeats[agent.Name]
+		out = append(out, responseAgent{
+			AgentInfo:    agent,
+			LastReportAt: heartbeat.RecordedAt,
+			Stale:        now-heartbeat.RecordedAt >= common.StaleSeconds(heartbeat.ExpectedInterval, staleSeconds),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"agents": out})
+}
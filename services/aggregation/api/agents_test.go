@@ -0,0 +1,98 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/testsCommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_Agents(t *testing.T) {
+	serv, store := setupTestServer(t)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	report := func(version string) {
+		payload := MetricReportPayload{
+			Metrics: map[string]ReportedMetric{
+				"VM1.Active": {Value: "true", Type: common.MetricTypeBool, NumAggregation: 1},
+			},
+			Agent: &ReportedAgent{Name: "VM1", Version: version, Commit: "abc", ConfigHash: "0123"},
+		}
+		body, _ := json.Marshal(payload)
+		req, _ := http.NewRequest("POST", "/api/report", bytes.NewBuffer(body))
+		req.Header.Set("X-Api-Key", "test-secret")
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+	report("v1.0.0")
+	report("v1.1.0")
+
+	req, _ := http.NewRequest("GET", "/api/agents", nil)
+	w := httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	req, _ = http.NewRequest("GET", "/api/agents", nil)
+	req.Header.Set("Authorization", "Bearer "+getValidToken(serv))
+	w = httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Agents []struct {
+			common.AgentInfo
+			LastReportAt int64 `json:"lastReportAt"`
+			Stale        bool  `json:"stale"`
+		} `json:"agents"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Agents, 1)
+	assert.Equal(t, "VM1", resp.Agents[0].Name)
+	assert.Equal(t, "v1.1.0", resp.Agents[0].Version)
+	assert.Equal(t, "abc", resp.Agents[0].Commit)
+	assert.Equal(t, "0123", resp.Agents[0].ConfigHash)
+	assert.Greater(t, resp.Agents[0].LastReportAt, int64(0))
+	assert.False(t, resp.Agents[0].Stale)
+}
+
+func TestServer_SaveAgentInfo(t *testing.T) {
+	t.Parallel()
+
+	numSaves := 0
+	store := &testsCommon.StoreStub{
+		SaveAgentInfoHandler: func(ctx context.Context, info common.AgentInfo) error {
+			numSaves++
+			return nil
+		},
+	}
+	serv, err := NewServer(ArgsWebServer{
+		ServiceKeyApi:  "test-secret",
+		AuthUsername:   "admin",
+		AuthPassword:   "password",
+		ListenAddress:  ":0",
+		Storage:        store,
+		GeneralHandler: func(h http.Handler) http.Handler { return h },
+	})
+	require.NoError(t, err)
+
+	agent := ReportedAgent{Name: "VM1", Version: "v1.0.0"}
+	serv.saveAgentInfo(context.Background(), "", agent, 100)
+	serv.saveAgentInfo(context.Background(), "", agent, 101)
+	// the reports without an agent name are ignored
+	serv.saveAgentInfo(context.Background(), "", ReportedAgent{Version: "v1.0.0"}, 101)
+	assert.Equal(t, 1, numSaves)
+
+	agent.ConfigHash = "0123"
+	serv.saveAgentInfo(context.Background(), "", agent, 102)
+	assert.Equal(t, 2, numSaves)
+}
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+)
+
+const (
+	defaultAlertsLimit = 100
+	maxAlertsLimit     = 1000
+	maxSilenceDuration = 30 * 24 * time.Hour
+)
+
+// handleGetAlerts lists the alerts, the most recent first, optionally filtered by state and metric
+func (s *server) handleGetAlerts(c *gin.Context) {
+	filter := common.AlertsFilter{
+		State:      common.AlertState(c.Query("state")),
+		MetricName: c.Query("metric"),
+		Tenant:     c.GetString(sessionTenantKey),
+		Limit:      defaultAlertsLimit,
+	}
+	if len(filter.State) > 0 && !filter.State.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid alert state"})
+		return
+	}
+	if len(c.Query("limit")) > 0 {
+		limit, err := strconv.Atoi(c.Query("limit"))
+		if err != nil || limit < 1 || limit > maxAlertsLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(maxAlertsLimit)})
+			return
+		}
+		filter.Limit = limit
+	}
+
+	alerts, err := s.storage.GetAlerts(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"alerts": alerts})
+}
+
+// handleGetAlert returns an alert together with its history
+func (s *server) handleGetAlert(c *gin.Context) {
+	id, ok := parseAlertID(c)
+	if !ok {
+		return
+	}
+
+	if !s.authorizeAlert(c, id) {
+		return
+	}
+
+	alert, err := s.storage.GetAlert(c.Request.Context(), id)
+	if errors.Is(err, common.ErrAlertNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, alert)
+}
+
+// handleAcknowledgeAlert marks a firing alert as being handled by the logged in user
+func (s *server) handleAcknowledgeAlert(c *gin.Context) {
+	id, ok := parseAlertID(c)
+	if !ok {
+		return
+	}
+
+	if !s.authorizeAlert(c, id) {
+		return
+	}
+
+	var req struct {
+		Note string `json:"note"`
+	}
+	// the body is optional
+	_ = c.ShouldBindJSON(&req)
+
+	err := s.storage.UpdateAlertState(c.Request.Context(), id, common.AlertStateAcknowledged, c.GetString(sessionUserKey), req.Note, time.Now().Unix())
+	if !writeAlertUpdateError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// handleSilenceAlert mutes the notifications of an active alert for the provided duration
+func (s *server) handleSilenceAlert(c *gin.Context) {
+	id, ok := parseAlertID(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		DurationInSec int64  `json:"durationInSec"`
+		Note          string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	duration := time.Duration(req.DurationInSec) * time.Second
+	if duration <= 0 || duration > maxSilenceDuration {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "durationInSec must be between 1 and " + strconv.Itoa(int(maxSilenceDuration.Seconds()))})
+		return
+	}
+
+	if !s.authorizeAlert(c, id) {
+		return
+	}
+
+	now := time.Now()
+	until := now.Add(duration).Unix()
+	err := s.storage.SilenceAlert(c.Request.Context(), id, until, c.GetString(sessionUserKey), req.Note, now.Unix())
+	if !writeAlertUpdateError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true, "silencedUntil": until})
+}
+
+func parseAlertID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid alert id"})
+		return 0, false
+	}
+
+	return id, true
+}
+
+// writeAlertUpdateError writes the error response, if any, returning true if the update succeeded
+func writeAlertUpdateError(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, common.ErrAlertNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, common.ErrInvalidAlertTransition):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+
+	return false
+}
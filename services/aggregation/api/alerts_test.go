@@ -0,0 +1,103 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type alertsCreator interface {
+	CreateAlert(ctx context.Context, metricName string, problem string, startedAt int64) (int64, error)
+}
+
+func TestServer_Alerts(t *testing.T) {
+	serv, store := setupTestServer(t)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	ctx := context.Background()
+	creator := store.(alertsCreator)
+	firingID, err := creator.CreateAlert(ctx, "VM1.Node1.nonce", "Host appears offline", 1000)
+	require.NoError(t, err)
+	require.NoError(t, store.UpdateAlertState(ctx, firingID, common.AlertStateFiring, "", "", 1001))
+	pendingID, err := creator.CreateAlert(ctx, "VM2.Node1.nonce", "Host appears offline", 1002)
+	require.NoError(t, err)
+
+	token := getValidToken(serv)
+	doRequest := func(method string, url string, body string) *httptest.ResponseRecorder {
+		var reader io.Reader
+		if len(body) > 0 {
+			reader = bytes.NewBufferString(body)
+		}
+		req, _ := http.NewRequest(method, url, reader)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+
+		return w
+	}
+
+	// unauthenticated
+	req, _ := http.NewRequest("GET", "/api/alerts", nil)
+	w := httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = doRequest("GET", "/api/alerts", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var listResp struct {
+		Alerts []common.Alert `json:"alerts"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &listResp))
+	require.Len(t, listResp.Alerts, 2)
+	assert.Equal(t, pendingID, listResp.Alerts[0].ID)
+
+	w = doRequest("GET", "/api/alerts?state=firing&limit=5", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &listResp))
+	require.Len(t, listResp.Alerts, 1)
+	assert.Equal(t, firingID, listResp.Alerts[0].ID)
+
+	assert.Equal(t, http.StatusBadRequest, doRequest("GET", "/api/alerts?state=closed", "").Code)
+	assert.Equal(t, http.StatusBadRequest, doRequest("GET", "/api/alerts?limit=0", "").Code)
+
+	// acknowledge
+	assert.Equal(t, http.StatusBadRequest, doRequest("POST", "/api/alerts/abc/acknowledge", "").Code)
+	assert.Equal(t, http.StatusNotFound, doRequest("POST", "/api/alerts/100/acknowledge", "").Code)
+	assert.Equal(t, http.StatusConflict, doRequest("POST", "/api/alerts/2/acknowledge", "").Code)
+	w = doRequest("POST", "/api/alerts/1/acknowledge", `{"note":"on it"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusConflict, doRequest("POST", "/api/alerts/1/acknowledge", "").Code)
+
+	// silence
+	assert.Equal(t, http.StatusBadRequest, doRequest("POST", "/api/alerts/1/silence", `{"durationInSec":0}`).Code)
+	assert.Equal(t, http.StatusBadRequest, doRequest("POST", "/api/alerts/1/silence", `{"durationInSec":99999999}`).Code)
+	assert.Equal(t, http.StatusNotFound, doRequest("POST", "/api/alerts/100/silence", `{"durationInSec":60}`).Code)
+	w = doRequest("POST", "/api/alerts/1/silence", `{"durationInSec":3600,"note":"node upgrade"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// details and history
+	assert.Equal(t, http.StatusNotFound, doRequest("GET", "/api/alerts/100", "").Code)
+	w = doRequest("GET", "/api/alerts/1", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	alert := common.Alert{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &alert))
+	assert.Equal(t, common.AlertStateAcknowledged, alert.State)
+	assert.Equal(t, "admin", alert.AcknowledgedBy)
+	assert.NotZero(t, alert.SilencedUntil)
+	require.Len(t, alert.Events, 4)
+	assert.Equal(t, "acknowledged", alert.Events[2].Action)
+	assert.Equal(t, "on it", alert.Events[2].Note)
+	assert.Equal(t, "admin", alert.Events[2].Actor)
+	assert.Equal(t, "silenced", alert.Events[3].Action)
+	assert.Equal(t, "node upgrade", alert.Events[3].Note)
+}
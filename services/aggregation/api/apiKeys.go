@@ -0,0 +1,123 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+)
+
+// sessionAPIKeyKey holds, in the gin context, whether the request was authenticated by a scoped API key instead of a
+// user session
+const sessionAPIKeyKey = "sessionAPIKey"
+
+// apiKeyUserPrefix prefixes the API key name in the user recorded for the changes made with the key (e.g. the
+// acknowledged alerts)
+const apiKeyUserPrefix = "api-key:"
+
+// APIKey defines a key, sent in the X-Api-Key header, for the external tools that should not use a human login or the
+// agents key. The key acts within its tenant, the empty one being the default tenant.
+type APIKey struct {
+	Name   string
+	Key    string
+	Scope  string
+	Tenant string
+}
+
+func (s *server) setAPIKeys(apiKeys []APIKey) error {
+	s.apiKeys = make(map[string]APIKey, len(apiKeys))
+	for _, apiKey := range apiKeys {
+		if len(apiKey.Name) == 0 {
+			return errors.New("empty API key name")
+		}
+		if len(apiKey.Key) == 0 {
+			return errors.New("API key " + apiKey.Name + " is empty")
+		}
+		_, isTenantKey := s.tenantOfAPIKey(apiKey.Key)
+		_, isDuplicate := s.apiKeys[apiKey.Key]
+		if isTenantKey || isDuplicate {
+			return errors.New("API key " + apiKey.Name + " is already used")
+		}
+		if apiKey.Scope != common.APIKeyScopeReport && apiKey.Scope != common.APIKeyScopeRead && apiKey.Scope != common.APIKeyScopeAdmin {
+			return errors.New("API key " + apiKey.Name + " has the invalid scope " + apiKey.Scope)
+		}
+		if len(apiKey.Tenant) > 0 && !s.isTenant(apiKey.Tenant) {
+			return errors.New("API key " + apiKey.Name + " uses the unknown tenant " + apiKey.Tenant)
+		}
+
+		s.apiKeys[apiKey.Key] = apiKey
+	}
+
+	return nil
+}
+
+// TenantOfReportKey returns the tenant of the keys allowed to report metrics: the service and tenant API keys and the
+// API keys with the report or admin scope
+func (s *server) TenantOfReportKey(key string) (string, bool) {
+	tenant, found := s.tenantOfAPIKey(key)
+	if found {
+		return tenant, true
+	}
+
+	apiKey, found := s.apiKeys[key]
+	if !found || apiKey.Scope == common.APIKeyScopeRead {
+		return "", false
+	}
+
+	return apiKey.Tenant, true
+}
+
+// authScopedAPIKey authenticates the frontend endpoints request with an API key having the read or admin scope. The
+// read keys act as the viewers, without even logging out, while the admin keys act as the admin users.
+func (s *server) authScopedAPIKey(c *gin.Context, key string) {
+	apiKey, found := s.apiKeys[key]
+	if !found || apiKey.Scope == common.APIKeyScopeReport {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		c.Abort()
+		return
+	}
+
+	role := common.RoleAdmin
+	if apiKey.Scope == common.APIKeyScopeRead {
+		if c.Request.Method != http.MethodGet {
+			c.JSON(http.StatusForbidden, gin.H{"error": "read-only API key"})
+			c.Abort()
+			return
+		}
+		role = common.RoleViewer
+	}
+
+	c.Set(sessionUserKey, apiKeyUserPrefix+apiKey.Name)
+	c.Set(sessionTenantKey, apiKey.Tenant)
+	c.Set(sessionRoleKey, role)
+	c.Set(sessionAPIKeyKey, true)
+	c.Next()
+}
+
+// requireSession restricts the endpoints managing the user sessions and credentials to the requests authenticated by
+// a session token
+func (s *server) requireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetBool(sessionAPIKeyKey) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "not available for the API keys"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// handleGetAPIKeys lists the configured API keys, without the keys themselves
+func (s *server) handleGetAPIKeys(c *gin.Context) {
+	apiKeys := make([]gin.H, 0, len(s.apiKeys))
+	for _, apiKey := range s.apiKeys {
+		apiKeys = append(apiKeys, gin.H{"name": apiKey.Name, "scope": apiKey.Scope, "tenant": apiKey.Tenant})
+	}
+	sort.Slice(apiKeys, func(i, j int) bool {
+		return apiKeys[i]["name"].(string) < apiKeys[j]["name"].(string)
+	})
+
+	c.JSON(http.StatusOK, gin.H{"apiKeys": apiKeys})
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createAPIKeysArgs(store Storage) ArgsWebServer {
+	args := createTenantsArgs(store)
+	args.ProfilingEnabled = true
+	args.APIKeys = []APIKey{
+		{Name: "pusher", Key: "report-key", Scope: common.APIKeyScopeReport},
+		{Name: "grafana", Key: "read-key", Scope: common.APIKeyScopeRead},
+		{Name: "automation", Key: "admin-key", Scope: common.APIKeyScopeAdmin},
+		{Name: "acme-grafana", Key: "acme-read-key", Scope: common.APIKeyScopeRead, Tenant: "acme"},
+	}
+
+	return args
+}
+
+func TestNewServer_APIKeys(t *testing.T) {
+	t.Parallel()
+
+	store, err := storage.NewSQLiteStorage(":memory:", 100, storage.SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	testCases := map[string]func(args *ArgsWebServer){
+		"API key grafana is already used":             func(args *ArgsWebServer) { args.APIKeys[1].Key = "test-secret" },
+		"API key automation is already used":          func(args *ArgsWebServer) { args.APIKeys[2].Key = "read-key" },
+		"API key grafana has the invalid scope write": func(args *ArgsWebServer) { args.APIKeys[1].Scope = "write" },
+		"API key grafana uses the unknown tenant xyz": func(args *ArgsWebServer) { args.APIKeys[1].Tenant = "xyz" },
+		"API key grafana is empty":                    func(args *ArgsWebServer) { args.APIKeys[1].Key = "" },
+		"empty API key name":                          func(args *ArgsWebServer) { args.APIKeys[1].Name = "" },
+	}
+	for expectedError, mutate := range testCases {
+		args := createAPIKeysArgs(store)
+		mutate(&args)
+		serv, errNew := NewServer(args)
+		assert.Nil(t, serv)
+		assert.EqualError(t, errNew, expectedError)
+	}
+}
+
+func TestServer_APIKeys(t *testing.T) {
+	store, err := storage.NewSQLiteStorage(":memory:", 100, storage.SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	serv, err := NewServer(createAPIKeysArgs(store))
+	require.NoError(t, err)
+
+	doRequest := func(apiKey string, method string, url string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+		req.Header.Set("X-Api-Key", apiKey)
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+
+		return w
+	}
+	getMetricNames := func(apiKey string) []string {
+		w := doRequest(apiKey, "GET", "/api/metrics", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		var metricsResp struct {
+			Metrics []struct {
+				Name string `json:"name"`
+			} `json:"metrics"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &metricsResp))
+
+		names := make([]string, 0, len(metricsResp.Metrics))
+		for _, metric := range metricsResp.Metrics {
+			names = append(names, metric.Name)
+		}
+		return names
+	}
+
+	// the report scope can only report
+	assert.Equal(t, http.StatusOK, doRequest("report-key", "POST", "/api/report", `{"metrics": {"VM2.Node1.nonce": {"value": "20", "type": "uint64", "numAggregation": 5}}}`).Code)
+	assert.Equal(t, http.StatusOK, doRequest("acme-secret", "POST", "/api/report", `{"metrics": {"VM1.Node1.nonce": {"value": "10", "type": "uint64", "numAggregation": 5}}}`).Code)
+	assert.Equal(t, http.StatusUnauthorized, doRequest("report-key", "GET", "/api/metrics", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, doRequest("unknown-key", "GET", "/api/metrics", "").Code)
+
+	// the read scope can only read, within its tenant
+	assert.Equal(t, http.StatusUnauthorized, doRequest("read-key", "POST", "/api/report", `{"metrics": {}}`).Code)
+	assert.ElementsMatch(t, []string{"acme:VM1.Node1.nonce", "VM2.Node1.nonce"}, getMetricNames("read-key"))
+	assert.Equal(t, []string{"acme:VM1.Node1.nonce"}, getMetricNames("acme-read-key"))
+	assert.Equal(t, http.StatusOK, doRequest("read-key", "GET", "/api/metrics/VM2.Node1.nonce/history", "").Code)
+	assert.Equal(t, http.StatusNotFound, doRequest("acme-read-key", "GET", "/api/metrics/VM2.Node1.nonce/history", "").Code)
+	assert.Equal(t, http.StatusOK, doRequest("read-key", "GET", "/api/internal/stats", "").Code)
+	assert.Equal(t, http.StatusForbidden, doRequest("read-key", "POST", "/api/config/metrics/alarm", `{"name":"VM2.Node1.nonce","enabled":true}`).Code)
+	assert.Equal(t, http.StatusForbidden, doRequest("read-key", "DELETE", "/api/metrics/VM2.Node1.nonce", "").Code)
+	assert.Equal(t, http.StatusForbidden, doRequest("read-key", "GET", "/api/sessions", "").Code)
+	assert.Equal(t, http.StatusForbidden, doRequest("read-key", "GET", "/api/admin/schema", "").Code)
+	assert.Equal(t, http.StatusForbidden, doRequest("read-key", "GET", "/api/admin/debug/pprof/goroutine", "").Code)
+	assert.Equal(t, http.StatusForbidden, doRequest("read-key", "GET", "/api/admin/debug/pprof/cmdline", "").Code)
+
+	// the admin scope can do everything the admin user can, except the endpoints bound to a user session
+	assert.Equal(t, http.StatusOK, doRequest("admin-key", "POST", "/api/report", `{"metrics": {"VM3.Node1.nonce": {"value": "30", "type": "uint64", "numAggregation": 5}}}`).Code)
+	assert.Equal(t, http.StatusOK, doRequest("admin-key", "POST", "/api/config/metrics/alarm", `{"name":"VM2.Node1.nonce","enabled":true}`).Code)
+	assert.Equal(t, http.StatusOK, doRequest("admin-key", "GET", "/api/sessions", "").Code)
+	assert.Equal(t, http.StatusOK, doRequest("admin-key", "GET", "/api/admin/schema", "").Code)
+	assert.Equal(t, http.StatusOK, doRequest("admin-key", "GET", "/api/admin/debug/pprof/cmdline", "").Code)
+
+	// the configured keys are listed without the keys themselves
+	assert.Equal(t, http.StatusForbidden, doRequest("read-key", "GET", "/api/admin/api-keys", "").Code)
+	w := doRequest("admin-key", "GET", "/api/admin/api-keys", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"apiKeys": [
+		{"name": "acme-grafana", "scope": "read", "tenant": "acme"},
+		{"name": "automation", "scope": "admin", "tenant": ""},
+		{"name": "grafana", "scope": "read", "tenant": ""},
+		{"name": "pusher", "scope": "report", "tenant": ""}
+	]}`, w.Body.String())
+	assert.Equal(t, http.StatusForbidden, doRequest("admin-key", "GET", "/api/auth/totp", "").Code)
+	assert.Equal(t, http.StatusForbidden, doRequest("admin-key", "POST", "/api/auth/logout", "").Code)
+
+	// the changes made with the key are recorded under its name
+	id, err := store.CreateAlert(context.Background(), "VM2.Node1.nonce", "Host appears offline", 1000)
+	require.NoError(t, err)
+	require.NoError(t, store.UpdateAlertState(context.Background(), id, common.AlertStateFiring, "", "", 1001))
+	assert.Equal(t, http.StatusOK, doRequest("admin-key", "POST", fmt.Sprintf("/api/alerts/%d/acknowledge", id), `{}`).Code)
+	alert, err := store.GetAlert(context.Background(), id)
+	require.NoError(t, err)
+	assert.Equal(t, "api-key:automation", alert.AcknowledgedBy)
+}
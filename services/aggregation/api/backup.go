@@ -0,0 +1,36 @@
+package api
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+)
+
+const backupFileTimeFormat = "20060102-150405"
+
+// handleBackup streams a consistent snapshot of the database, taken without blocking the ingestion. The snapshot is
+// written to a temporary file, removed once sent, so the service temporary directory needs room for a copy of the
+// database.
+func (s *server) handleBackup(c *gin.Context) {
+	dir, err := os.MkdirTemp("", "monitoring-backup-")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	backupPath := filepath.Join(dir, "backup.db")
+	err = s.storage.Backup(c.Request.Context(), backupPath)
+	if err != nil {
+		log.Warn("database backup failed", "by", c.GetString(sessionUserKey), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Info("database backup", "by", c.GetString(sessionUserKey))
+	c.FileAttachment(backupPath, "monitoring-"+s.clock.Now().UTC().Format(backupFileTimeFormat)+".db")
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_Backup(t *testing.T) {
+	t.Parallel()
+
+	store, err := storage.NewSQLiteStorage(":memory:", 100, storage.SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = store.Close()
+	}()
+	require.NoError(t, store.SaveMetric(context.Background(), "VM1.Node1.nonce", common.MetricTypeUint64, 10, "10", 1000))
+
+	serv, err := NewServer(createAPIKeysArgs(store))
+	require.NoError(t, err)
+
+	backup := func(apiKey string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/admin/backup", nil)
+		req.Header.Set("X-Api-Key", apiKey)
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+
+		return w
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, backup("report-key").Code)
+	assert.Equal(t, http.StatusForbidden, backup("read-key").Code)
+
+	w := backup("admin-key")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Disposition"), `attachment; filename="monitoring-`)
+
+	backupPath := filepath.Join(t.TempDir(), "backup.db")
+	require.NoError(t, os.WriteFile(backupPath, w.Body.Bytes(), 0600))
+	restored, err := storage.NewSQLiteStorage(backupPath, 100, storage.SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = restored.Close()
+	}()
+
+	hist, err := restored.GetMetricHistory(context.Background(), "VM1.Node1.nonce")
+	require.NoError(t, err)
+	assert.Equal(t, []common.MetricValue{{Value: "10", RecordedAt: 1000}}, hist.History)
+}
@@ -0,0 +1,31 @@
+package api
+
+// defaultMaxClockSkewInSeconds is the tolerated difference between the agent clocks and the server clock
+const defaultMaxClockSkewInSeconds = 30
+
+// clockSkew returns the number of seconds the agent clock is behind the server clock (negative if ahead), 0 if the
+// report does not carry its send time or the difference is within the tolerated skew
+func (s *server) clockSkew(payload MetricReportPayload, receivedAt int64) int64 {
+	if payload.SentAt <= 0 {
+		return 0
+	}
+
+	skew := receivedAt - payload.SentAt
+	if skew >= -s.maxClockSkew && skew <= s.maxClockSkew {
+		return 0
+	}
+
+	log.Debug("correcting the agent clock skew", "skew in seconds", skew, "cycle ID", payload.CycleID)
+
+	return skew
+}
+
+// correctedTimestamp returns the server time of a value polled by the agent at recordedAt, the values without a poll
+// timestamp are stamped on reception and no value is recorded in the future
+func correctedTimestamp(recordedAt int64, skew int64, receivedAt int64) int64 {
+	if recordedAt <= 0 {
+		return receivedAt
+	}
+
+	return min(recordedAt+skew, receivedAt)
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/testsCommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCorrectedTimestamp(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, int64(1000), correctedTimestamp(0, 0, 1000))
+	assert.Equal(t, int64(1000), correctedTimestamp(0, 50, 1000))
+	assert.Equal(t, int64(900), correctedTimestamp(900, 0, 1000))
+	assert.Equal(t, int64(950), correctedTimestamp(900, 50, 1000))
+	// no value is recorded in the future
+	assert.Equal(t, int64(1000), correctedTimestamp(1100, 0, 1000))
+	assert.Equal(t, int64(1000), correctedTimestamp(900, 200, 1000))
+}
+
+func TestReportEndpoint_ClientTimestamps(t *testing.T) {
+	serv, store := setupTestServer(t)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	report := func(metricName string, recordedAt int64, sentAt int64) {
+		payload := MetricReportPayload{
+			SentAt: sentAt,
+			Metrics: map[string]ReportedMetric{
+				metricName: {Value: "true", Type: "bool", NumAggregation: 10, RecordedAt: recordedAt},
+			},
+		}
+		body, _ := json.Marshal(payload)
+		req, _ := http.NewRequest("POST", "/api/report", bytes.NewBuffer(body))
+		req.Header.Set("X-Api-Key", "test-secret")
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+	recordedAt := func(metricName string) int64 {
+		hist, err := store.GetMetricHistory(context.Background(), metricName)
+		require.NoError(t, err)
+		require.Len(t, hist.History, 1)
+
+		return hist.History[0].RecordedAt
+	}
+
+	now := time.Now().Unix()
+	t.Run("buffered values land at the poll time", func(t *testing.T) {
+		report("VM1.Active", now-600, now)
+		assert.Equal(t, now-600, recordedAt("VM1.Active"))
+	})
+	t.Run("values without a poll time are stamped on reception", func(t *testing.T) {
+		report("VM2.Active", 0, 0)
+		assert.InDelta(t, now, recordedAt("VM2.Active"), 5)
+	})
+	t.Run("values in the future are clamped", func(t *testing.T) {
+		report("VM3.Active", now+3600, 0)
+		assert.InDelta(t, now, recordedAt("VM3.Active"), 5)
+	})
+	t.Run("the agent clock skew is corrected", func(t *testing.T) {
+		// the agent clock is one hour ahead, the value was polled one minute before sending
+		report("VM4.Active", now+3600-60, now+3600)
+		assert.InDelta(t, now-60, recordedAt("VM4.Active"), 5)
+	})
+	t.Run("a skew within the tolerance is kept", func(t *testing.T) {
+		report("VM5.Active", now-100, now-10)
+		assert.Equal(t, now-100, recordedAt("VM5.Active"))
+	})
+}
+
+func TestReportEndpoint_ReceptionTimestamp(t *testing.T) {
+	var saved []common.MetricRecord
+	store := &testsCommon.StoreStub{
+		SaveMetricsHandler: func(ctx context.Context, records []common.MetricRecord) error {
+			saved = append(saved, records...)
+			return nil
+		},
+	}
+	serv, err := NewServer(ArgsWebServer{
+		ServiceKeyApi:  "test-secret",
+		AuthUsername:   "admin",
+		AuthPassword:   "password",
+		ListenAddress:  ":0",
+		Storage:        store,
+		GeneralHandler: func(h http.Handler) http.Handler { return h },
+	})
+	require.NoError(t, err)
+
+	// without a write queue, the values of the default tenant are saved synchronously
+	now := time.Now().Unix()
+	body := fmt.Sprintf(`{"sentAt": %d, "metrics": {"VM1.Active": {"value": "true", "type": "bool", "numAggregation": 1, "recordedAt": %d}}}`,
+		now, now-600)
+	req, _ := http.NewRequest("POST", "/api/report", bytes.NewBufferString(body))
+	req.Header.Set("X-Api-Key", "test-secret")
+	w := httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	require.Len(t, saved, 1)
+	assert.Equal(t, now-600, saved[0].RecordedAt)
+	assert.InDelta(t, now, saved[0].ReceivedAt, 5)
+}
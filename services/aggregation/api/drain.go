@@ -0,0 +1,83 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// drainTimeout is the maximum time the drain request waits for the in-flight ingests to finish
+const drainTimeout = 30 * time.Second
+
+var errServerDraining = errors.New("server is draining")
+
+// trackIngest counts the in-flight ingest requests and rejects the new ones once the drain started.
+// The rejected agents will resend their reports on the next cycle, possibly to another instance.
+func (s *server) trackIngest() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		s.mutDrain.RLock()
+		if s.draining {
+			s.mutDrain.RUnlock()
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": errServerDraining.Error()})
+			c.Abort()
+			return
+		}
+		s.inFlightIngests.Add(1)
+		s.mutDrain.RUnlock()
+
+		defer s.inFlightIngests.Done()
+		c.Next()
+	}
+}
+
+func (s *server) isDraining() bool {
+	s.mutDrain.RLock()
+	defer s.mutDrain.RUnlock()
+
+	return s.draining
+}
+
+func (s *server) handleHealthz(c *gin.Context) {
+	if s.isDraining() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "draining"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// handleDrain marks the server as not ready and waits for the in-flight ingests to be persisted.
+// Calling it multiple times is safe.
+func (s *server) handleDrain(c *gin.Context) {
+	s.mutDrain.Lock()
+	if !s.draining {
+		log.Info("draining the server, new reports will be rejected")
+	}
+	s.draining = true
+	s.mutDrain.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.inFlightIngests.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(s.drainTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		// no new report is accepted, so once the in-flight ingests finished, the write queue can be emptied
+		if s.writeQueue != nil {
+			s.writeQueue.flush(c.Request.Context())
+		}
+		log.Info("server drained, it can be safely restarted")
+		c.JSON(http.StatusOK, gin.H{"drained": true})
+	case <-timer.C:
+		c.JSON(http.StatusServiceUnavailable, gin.H{"drained": false, "error": "timeout waiting for the in-flight reports"})
+	case <-c.Request.Context().Done():
+		c.JSON(http.StatusServiceUnavailable, gin.H{"drained": false, "error": "request canceled"})
+	}
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func sendReport(serv *server) *httptest.ResponseRecorder {
+	body := []byte(`{"metrics": {"VM1.Active": {"value": "true", "type": "bool", "numAggregation": 1}}}`)
+	req, _ := http.NewRequest("POST", "/api/report", bytes.NewBuffer(body))
+	req.Header.Set("X-Api-Key", "test-secret")
+	w := httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestServer_Drain(t *testing.T) {
+	serv, store := setupTestServer(t)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	token := getValidToken(serv)
+
+	req, _ := http.NewRequest("GET", "/healthz", nil)
+	w := httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, http.StatusOK, sendReport(serv).Code)
+
+	// drain requires authentication
+	req, _ = http.NewRequest("POST", "/api/admin/drain", nil)
+	w = httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+
+	req, _ = http.NewRequest("POST", "/api/admin/drain", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"drained":true`)
+
+	req, _ = http.NewRequest("GET", "/healthz", nil)
+	w = httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Contains(t, w.Body.String(), "draining")
+
+	require.Equal(t, http.StatusServiceUnavailable, sendReport(serv).Code)
+
+	// the read endpoints are still served
+	req, _ = http.NewRequest("GET", "/api/metrics", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestServer_DrainWaitsForInFlightIngests(t *testing.T) {
+	serv, store := setupTestServer(t)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	token := getValidToken(serv)
+
+	// simulate an in-flight ingest
+	serv.inFlightIngests.Add(1)
+
+	t.Run("timeout", func(t *testing.T) {
+		serv.drainTimeout = 10 * time.Millisecond
+
+		req, _ := http.NewRequest("POST", "/api/admin/drain", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusServiceUnavailable, w.Code)
+		require.Contains(t, w.Body.String(), `"drained":false`)
+	})
+	t.Run("in-flight ingest finishes", func(t *testing.T) {
+		serv.drainTimeout = time.Minute
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			serv.inFlightIngests.Done()
+		}()
+
+		req, _ := http.NewRequest("POST", "/api/admin/drain", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Contains(t, w.Body.String(), `"drained":true`)
+	})
+}
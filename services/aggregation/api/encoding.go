@@ -0,0 +1,46 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iulianpascalau/api-monitoring/commonGo"
+)
+
+// bindPayload decodes the request body in the payload, as MessagePack if the Content-Type says so and as JSON
+// otherwise
+func bindPayload(c *gin.Context, payload interface{}) error {
+	if commonGo.IsMsgPackContentType(c.ContentType()) {
+		return commonGo.DecodeMsgPack(c.Request.Body, payload)
+	}
+
+	return c.ShouldBindJSON(payload)
+}
+
+// acceptsMsgPack returns true if the client asked for a MessagePack response
+func acceptsMsgPack(c *gin.Context) bool {
+	for _, accepted := range strings.Split(c.GetHeader("Accept"), ",") {
+		if commonGo.IsMsgPackContentType(strings.TrimSpace(accepted)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// respondNegotiated writes the response as MessagePack if the client accepts it and as JSON otherwise
+func respondNegotiated(c *gin.Context, status int, response interface{}) {
+	if !acceptsMsgPack(c) {
+		c.JSON(status, response)
+		return
+	}
+
+	body, err := commonGo.MarshalMsgPack(response)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Data(status, commonGo.ContentTypeMsgPack, body)
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// writeJSONWithETag writes the payload with an ETag made of the version (e.g. the latest recorded timestamp) and of
+// the payload hash, answering 304 without the body if the client already has it (If-None-Match). The clients
+// polling every few seconds only get the payload when it changes.
+func writeJSONWithETag(c *gin.Context, version int64, payload interface{}) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	hash := sha256.Sum256(body)
+	etag := fmt.Sprintf(`W/"%d-%s"`, version, hex.EncodeToString(hash[:8]))
+
+	c.Header("ETag", etag)
+	// the cached copies must be revalidated on each use
+	c.Header("Cache-Control", "no-cache")
+	if matchesETag(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+// matchesETag implements the weak comparison of the If-None-Match header values
+func matchesETag(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchesETag(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, matchesETag(`W/"10-abcd"`, `W/"10-abcd"`))
+	assert.True(t, matchesETag(`"10-abcd"`, `W/"10-abcd"`))
+	assert.True(t, matchesETag(`W/"9-ef01", W/"10-abcd"`, `W/"10-abcd"`))
+	assert.True(t, matchesETag(`*`, `W/"10-abcd"`))
+	assert.False(t, matchesETag(``, `W/"10-abcd"`))
+	assert.False(t, matchesETag(`W/"9-abcd"`, `W/"10-abcd"`))
+}
+
+func TestServer_GetMetricsETag(t *testing.T) {
+	serv, store := setupTestServer(t)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	require.NoError(t, store.SaveMetric(context.Background(), "VM1.Node1.nonce", "uint64", 5, "10", 1000))
+	token := getValidToken(serv)
+
+	getMetrics := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/api/metrics", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("If-None-Match", ifNoneMatch)
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+
+		return w
+	}
+
+	w := getMetrics("")
+	require.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	assert.True(t, strings.HasPrefix(etag, `W/"1000-`))
+	assert.Equal(t, "no-cache", w.Header().Get("Cache-Control"))
+	assert.Contains(t, w.Body.String(), `"VM1.Node1.nonce"`)
+
+	w = getMetrics(etag)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+	assert.Equal(t, etag, w.Header().Get("ETag"))
+
+	// the configuration changes are caught even if no new value was recorded
+	require.NoError(t, store.UpdateMetricOrder(context.Background(), "VM1.Node1.nonce", 3))
+	w = getMetrics(etag)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+
+	require.NoError(t, store.SaveMetric(context.Background(), "VM1.Node1.nonce", "uint64", 5, "11", 1010))
+	w = getMetrics(w.Header().Get("ETag"))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, strings.HasPrefix(w.Header().Get("ETag"), `W/"1010-`))
+}
@@ -0,0 +1,186 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+)
+
+// the supported export formats
+const (
+	exportFormatCSV  = "csv"
+	exportFormatJSON = "json"
+)
+
+// exportFlushInterval is the number of values written between two flushes of the response, so the client receives the
+// export progressively
+const exportFlushInterval = 1000
+
+// handleExport streams the values of the metrics matching the filters of /metrics, recorded between from and to, as CSV
+// or JSON. The values are written to the response as they are read from the database, without a Content-Length, so
+// the size of the export is not bound by the memory of the service. An export failing after the first value is
+// truncated: the CSV misses its last rows and the JSON is not terminated.
+func (s *server) handleExport(c *gin.Context) {
+	format := c.DefaultQuery("format", exportFormatCSV)
+	if format != exportFormatCSV && format != exportFormatJSON {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or json"})
+		return
+	}
+	from, errFrom := parseTimestampQuery(c, "from", 0)
+	to, errTo := parseTimestampQuery(c, "to", time.Now().Unix())
+	if errFrom != nil || errTo != nil || from > to {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid range, from and to must be unix timestamps, from <= to"})
+		return
+	}
+
+	filters, err := parseTagFilters(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	metricsFilter, err := parseMetricsFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	metricsFilter.Tenant = c.GetString(sessionTenantKey)
+	dashboard, status, err := s.requestedDashboard(c)
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	results, err := s.storage.GetLatestMetricsFiltered(c.Request.Context(), metricsFilter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	names := make([]string, 0, len(results))
+	for _, r := range results {
+		tags := r.Tags
+		if tags == nil {
+			tags = common.ParseTagsFromName(r.Name)
+		}
+		if !common.MatchesTags(tags, filters) {
+			continue
+		}
+		if dashboard != nil && !dashboard.Contains(r.Name, tags[common.TagVM]) {
+			continue
+		}
+		names = append(names, r.Name)
+	}
+
+	writer := &exportWriter{c: c, format: format}
+	err = s.storage.StreamMetricValues(c.Request.Context(), names, from, to, writer.write)
+	if err == nil {
+		err = writer.finish()
+	}
+	if err == nil {
+		return
+	}
+	if !c.Writer.Written() {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	log.Warn("the export was interrupted", "error", err, "num values", writer.numValues,
+		"request ID", common.RequestIDFromContext(c.Request.Context()))
+}
+
+// exportWriter writes the exported values to the response, the response headers being sent with the first value
+type exportWriter struct {
+	c         *gin.Context
+	format    string
+	csv       *csv.Writer
+	numValues int
+}
+
+func (writer *exportWriter) start() error {
+	header := writer.c.Writer.Header()
+	header.Set("Content-Disposition", `attachment; filename="metrics-export.`+writer.format+`"`)
+	if writer.format == exportFormatJSON {
+		header.Set("Content-Type", "application/json; charset=utf-8")
+		writer.c.Status(http.StatusOK)
+		_, err := writer.c.Writer.WriteString(`{"values":[`)
+		return err
+	}
+
+	header.Set("Content-Type", "text/csv; charset=utf-8")
+	writer.c.Status(http.StatusOK)
+	writer.csv = csv.NewWriter(writer.c.Writer)
+	return writer.csv.Write([]string{"name", "type", "recorded_at", "value"})
+}
+
+func (writer *exportWriter) write(value common.ExportedValue) error {
+	if writer.numValues == 0 {
+		err := writer.start()
+		if err != nil {
+			return err
+		}
+	}
+
+	var err error
+	if writer.format == exportFormatJSON {
+		err = writer.writeJSON(value)
+	} else {
+		err = writer.csv.Write([]string{value.Name, value.Type, strconv.FormatInt(value.RecordedAt, 10), value.Value})
+	}
+	if err != nil {
+		return err
+	}
+
+	writer.numValues++
+	if writer.numValues%exportFlushInterval == 0 {
+		return writer.flush()
+	}
+
+	return nil
+}
+
+func (writer *exportWriter) writeJSON(value common.ExportedValue) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if writer.numValues > 0 {
+		data = append([]byte{','}, data...)
+	}
+
+	_, err = writer.c.Writer.Write(data)
+	return err
+}
+
+func (writer *exportWriter) flush() error {
+	if writer.csv != nil {
+		writer.csv.Flush()
+		err := writer.csv.Error()
+		if err != nil {
+			return err
+		}
+	}
+	writer.c.Writer.Flush()
+
+	return nil
+}
+
+// finish terminates the export, the headers being sent if no value was exported
+func (writer *exportWriter) finish() error {
+	if writer.numValues == 0 {
+		err := writer.start()
+		if err != nil {
+			return err
+		}
+	}
+	if writer.format == exportFormatJSON {
+		_, err := writer.c.Writer.WriteString("]}")
+		if err != nil {
+			return err
+		}
+	}
+
+	return writer.flush()
+}
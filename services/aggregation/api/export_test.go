@@ -0,0 +1,110 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/testsCommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_Export(t *testing.T) {
+	serv, store := setupTestServer(t)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	ctx := context.Background()
+	require.NoError(t, store.SaveMetric(ctx, "VM1.nonce", common.MetricTypeUint64, 10, "1", 1000))
+	require.NoError(t, store.SaveMetric(ctx, "VM1.nonce", common.MetricTypeUint64, 10, "2", 1006))
+	require.NoError(t, store.SaveMetric(ctx, "VM1.version", common.MetricTypeString, 10, "v1, rc", 1000))
+	require.NoError(t, store.SaveMetric(ctx, "VM2.nonce", common.MetricTypeUint64, 10, "7", 1000))
+
+	export := func(query string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/api/export"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+getValidToken(serv))
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusBadRequest, export("?format=xml").Code)
+	assert.Equal(t, http.StatusBadRequest, export("?from=10&to=5").Code)
+
+	w := export("?prefix=VM1.")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="metrics-export.csv"`, w.Header().Get("Content-Disposition"))
+	expected := "name,type,recorded_at,value\n" +
+		"VM1.nonce,uint64,1000,1\n" +
+		"VM1.nonce,uint64,1006,2\n" +
+		"VM1.version,string,1000,\"v1, rc\"\n"
+	assert.Equal(t, expected, w.Body.String())
+
+	w = export("?format=json&vm=VM1&from=1001&sort=name&order=desc")
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Values []common.ExportedValue `json:"values"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, []common.ExportedValue{{Name: "VM1.nonce", Type: common.MetricTypeUint64, Value: "2", RecordedAt: 1006}}, resp.Values)
+
+	w = export("?format=json&name=missing")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"values":[]}`, w.Body.String())
+}
+
+func TestServer_ExportFailure(t *testing.T) {
+	t.Parallel()
+
+	expectedErr := errors.New("expected error")
+	numValues := 0
+	store := &testsCommon.StoreStub{
+		GetLatestMetricsFilteredHandler: func(ctx context.Context, filter common.MetricsFilter) ([]common.MetricHistory, error) {
+			return []common.MetricHistory{{Name: "VM1.nonce"}}, nil
+		},
+		StreamMetricValuesHandler: func(ctx context.Context, names []string, from int64, to int64, handler func(value common.ExportedValue) error) error {
+			for i := 0; i < numValues; i++ {
+				err := handler(common.ExportedValue{Name: "VM1.nonce", Type: common.MetricTypeUint64, Value: "1", RecordedAt: int64(i)})
+				if err != nil {
+					return err
+				}
+			}
+			return expectedErr
+		},
+	}
+	serv, err := NewServer(ArgsWebServer{
+		ServiceKeyApi:  "test-secret",
+		AuthUsername:   "admin",
+		AuthPassword:   "password",
+		ListenAddress:  ":0",
+		Storage:        store,
+		GeneralHandler: func(h http.Handler) http.Handler { return h },
+	})
+	require.NoError(t, err)
+
+	export := func() *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/api/export?format=json", nil)
+		req.Header.Set("Authorization", "Bearer "+getValidToken(serv))
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+		return w
+	}
+
+	// the failure is reported while nothing was written
+	w := export()
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), expectedErr.Error())
+
+	// the export is truncated once started
+	numValues = 2
+	w = export()
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"values":[{"name":"VM1.nonce","type":"uint64","value":"1","recordedAt":0},{"name":"VM1.nonce","type":"uint64","value":"1","recordedAt":1}`, w.Body.String())
+}
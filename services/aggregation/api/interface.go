@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+	"time"
 
 	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
 )
@@ -11,15 +12,61 @@ type Storage interface {
 	// SaveMetric updates the metric definition and appends a new value, trimming history to NumAggregation
 	SaveMetric(ctx context.Context, name string, metricType string, numAggregation int, valString string, recordedAt int64) error
 
+	// SaveMetricTags upserts the tags of a metric
+	SaveMetricTags(ctx context.Context, name string, tags map[string]string) error
+
+	// SaveMetricInterval stores the number of seconds between two values of a metric, as reported by its agent
+	SaveMetricInterval(ctx context.Context, name string, intervalInSeconds int) error
+
+	// SaveMetrics persists a batch of metric values and their tags in a single transaction
+	SaveMetrics(ctx context.Context, records []common.MetricRecord) error
+
 	// GetLatestMetrics returns the single latest recorded value for every known metric
 	GetLatestMetrics(ctx context.Context) ([]common.MetricHistory, error)
 
+	// GetLatestMetricsFiltered returns the single latest recorded value for every metric matching the filter
+	GetLatestMetricsFiltered(ctx context.Context, filter common.MetricsFilter) ([]common.MetricHistory, error)
+
+	// GetMetricsHistoriesFiltered returns all the retained values of every metric matching the filter
+	GetMetricsHistoriesFiltered(ctx context.Context, filter common.MetricsFilter) ([]common.MetricHistory, error)
+
 	// GetMetricHistory returns the definition and all retained values (up to NumAggregation) for a specific metric
 	GetMetricHistory(ctx context.Context, name string) (*common.MetricHistory, error)
 
-	// DeleteMetric removes a metric definition and all associated values
+	// GetMetricHistorySince returns the definition and the values recorded after the provided timestamp for a specific metric
+	GetMetricHistorySince(ctx context.Context, name string, since int64) (*common.MetricHistory, error)
+	// GetMetricStats returns the statistics of the values of a numeric metric recorded between from and to, inclusive
+	GetMetricStats(ctx context.Context, name string, from int64, to int64) (*common.MetricStats, error)
+	// StreamMetricValues calls the handler for each value of the metrics recorded between from and to, inclusive
+	StreamMetricValues(ctx context.Context, names []string, from int64, to int64, handler func(value common.ExportedValue) error) error
+
+	// GetMetricTenant returns the tenant owning a metric
+	GetMetricTenant(ctx context.Context, name string) (string, error)
+
+	// DeleteMetric moves a metric to the trash, hiding it until restored or purged
 	DeleteMetric(ctx context.Context, name string) error
 
+	// RestoreMetric brings back a deleted metric not yet purged
+	RestoreMetric(ctx context.Context, name string) error
+
+	// GetTrashedMetrics returns the deleted metrics of the tenant not yet purged, all of them for the empty tenant
+	GetTrashedMetrics(ctx context.Context, tenant string) ([]common.TrashedMetric, error)
+
+	// DeleteMetricsByPrefix removes all the metrics whose names start with the prefix, returning their number
+	DeleteMetricsByPrefix(ctx context.Context, prefix string) (int64, error)
+
+	// RenameMetric renames a metric while preserving its history
+	RenameMetric(ctx context.Context, name string, newName string) error
+
+	// AddMetricAnnotation attaches a timestamped note to a metric, returning the annotation ID
+	AddMetricAnnotation(ctx context.Context, name string, text string, recordedAt int64) (int64, error)
+
+	// GetMetricAnnotations returns all the annotations of a metric
+	GetMetricAnnotations(ctx context.Context, name string) ([]common.MetricAnnotation, error)
+
+	// DeleteMetricAnnotation removes an annotation of a metric
+	DeleteMetricAnnotation(ctx context.Context, name string, id int64) error
+
 	// UpdateMetricOrder updates the display order of a specific metric
 	UpdateMetricOrder(ctx context.Context, name string, order int) error
 
@@ -29,11 +76,132 @@ type Storage interface {
 	// UpdateMetricAlarm updates the alarm status of a specific metric
 	UpdateMetricAlarm(ctx context.Context, name string, enabled bool) error
 
+	// UpdateMetricGapMode updates the way the missing intervals are represented in the history of a specific metric
+	UpdateMetricGapMode(ctx context.Context, name string, gapMode common.GapMode) error
+	// UpdateMetricAggregationMode updates the way the aggregated value of a specific metric is computed over its window
+	UpdateMetricAggregationMode(ctx context.Context, name string, aggregationMode common.AggregationMode) error
+	// UpdateMetricStoreOnChange enables or disables storing only the changes of the value of a specific metric
+	UpdateMetricStoreOnChange(ctx context.Context, name string, enabled bool) error
+	// UpdateMetricCompression enables or disables packing the older values of a specific metric in delta encoded chunks
+	UpdateMetricCompression(ctx context.Context, name string, enabled bool) error
+	// SetMetricThresholds stores the display thresholds of a metric, nil thresholds remove them
+	SetMetricThresholds(ctx context.Context, name string, thresholds *common.MetricThresholds) error
+
 	// GetPanelsConfigs returns the display configurations for all panels
 	GetPanelsConfigs(ctx context.Context) (map[string]int, error)
+	// SetPanelMetrics replaces the metrics assigned to a custom panel
+	SetPanelMetrics(ctx context.Context, panel string, metrics []string) error
+	// GetCustomPanels returns the metrics assigned to each custom panel
+	GetCustomPanels(ctx context.Context) (map[string][]string, error)
+
+	// CreateDashboard stores a new dashboard, returning its ID
+	CreateDashboard(ctx context.Context, dashboard common.Dashboard) (int64, error)
+
+	// GetDashboards returns all the dashboards
+	GetDashboards(ctx context.Context) ([]common.Dashboard, error)
+
+	// GetDashboard returns the dashboard with the provided ID
+	GetDashboard(ctx context.Context, id int64) (*common.Dashboard, error)
+
+	// UpdateDashboard replaces the name and the selections of an existing dashboard
+	UpdateDashboard(ctx context.Context, dashboard common.Dashboard) error
+
+	// DeleteDashboard removes a dashboard
+	DeleteDashboard(ctx context.Context, id int64) error
+
+	// GetAlerts returns the alerts matching the filter, the most recent first
+	GetAlerts(ctx context.Context, filter common.AlertsFilter) ([]common.Alert, error)
+
+	// GetAlert returns an alert together with its history
+	GetAlert(ctx context.Context, id int64) (*common.Alert, error)
+
+	// UpdateAlertState moves an alert to a new state, recording the transition in the alert history
+	UpdateAlertState(ctx context.Context, id int64, state common.AlertState, actor string, note string, timestamp int64) error
+
+	// SilenceAlert mutes the notifications of an active alert until the provided timestamp
+	SilenceAlert(ctx context.Context, id int64, until int64, actor string, note string, timestamp int64) error
+
+	// CreateSilence stores a new silence, returning its ID
+	CreateSilence(ctx context.Context, silence common.Silence) (int64, error)
+
+	// GetSilences returns the silences that did not end before the provided timestamp (all of them if 0)
+	GetSilences(ctx context.Context, endsAfter int64) ([]common.Silence, error)
+
+	// ExpireSilence ends a silence at the provided timestamp, keeping it in the history
+	ExpireSilence(ctx context.Context, id int64, timestamp int64) error
+
+	// CreateSession stores a new frontend session
+	CreateSession(ctx context.Context, session common.Session) error
+
+	// GetSession returns the session with the provided ID
+	GetSession(ctx context.Context, id string) (*common.Session, error)
+
+	// GetActiveSessions returns the sessions neither expired nor revoked at the provided timestamp
+	GetActiveSessions(ctx context.Context, timestamp int64) ([]common.Session, error)
+
+	// RevokeSession invalidates the tokens of the session at the provided timestamp
+	RevokeSession(ctx context.Context, id string, timestamp int64) error
+
+	// RevokeUserSessions invalidates all the sessions of the user at the provided timestamp, returning their number
+	RevokeUserSessions(ctx context.Context, username string, timestamp int64) (int64, error)
+
+	// GetTOTPEnrollment returns the two-factor authentication settings of the account
+	GetTOTPEnrollment(ctx context.Context, username string) (*common.TOTPEnrollment, error)
+
+	// SaveTOTPEnrollment stores the two-factor authentication settings of the account
+	SaveTOTPEnrollment(ctx context.Context, enrollment common.TOTPEnrollment) error
+
+	// DeleteTOTPEnrollment removes the two-factor authentication settings of the account
+	DeleteTOTPEnrollment(ctx context.Context, username string) error
+
+	// SaveAgentInfo stores the build and configuration details reported by an agent
+	SaveAgentInfo(ctx context.Context, info common.AgentInfo) error
+
+	// GetAgents returns the details of the agents of the tenant, all of them for the empty tenant
+	GetAgents(ctx context.Context, tenant string) ([]common.AgentInfo, error)
+
+	// SaveAgentLogs stores the log entries shipped by the agents, keeping the newest maxEntriesPerAgent of each agent
+	SaveAgentLogs(ctx context.Context, entries []common.AgentLogEntry, maxEntriesPerAgent int) error
+
+	// GetAgentLogs returns the log entries of an agent, newest first, of any tenant for the empty tenant
+	GetAgentLogs(ctx context.Context, tenant string, agent string) ([]common.AgentLogEntry, error)
+
+	// GetAvailabilityBuckets returns the availability buckets starting at or after the provided timestamp
+	GetAvailabilityBuckets(ctx context.Context, since int64) ([]common.AvailabilityBucket, error)
+
+	// GetSchemaInfo returns the schema version, the applied migrations and the row count of each table
+	GetSchemaInfo(ctx context.Context) (*common.SchemaInfo, error)
+
+	// Backup writes a consistent snapshot of the database to the provided path, which must not exist
+	Backup(ctx context.Context, path string) error
+
+	// GetStorageStats returns the counters of the storage operations (total, slow, timed out)
+	GetStorageStats() common.StorageStats
 
 	// Close shuts down the database connection
 	Close() error
 
 	IsInterfaceNil() bool
 }
+
+// MetricsSink defines the secondary destination the accepted metric values are forwarded to
+type MetricsSink interface {
+	// Forward hands over the records without blocking, the sink writes them asynchronously
+	Forward(records []common.MetricRecord)
+	IsInterfaceNil() bool
+}
+
+// OIDCProvider defines the OpenID Connect identity provider the dashboard users can log in with
+type OIDCProvider interface {
+	// AuthCodeURL returns the identity provider URL the user is redirected to for logging in
+	AuthCodeURL(ctx context.Context, state string, nonce string) (string, error)
+	// Exchange redeems the authorization code, returning the identity of the logged in user
+	Exchange(ctx context.Context, code string, nonce string) (*common.UserIdentity, error)
+	IsInterfaceNil() bool
+}
+
+// Clock defines the time source of the tokens and sessions expiry
+type Clock interface {
+	Now() time.Time
+	IsInterfaceNil() bool
+}
@@ -21,7 +21,8 @@ func CORSMiddleware(next http.Handler) http.Handler {
 		}
 
 		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-		w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, If-None-Match")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag, X-Request-ID")
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
 
 		// Handle preflight requests
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultOIDCPostLoginURL is the frontend page the users land on after the identity provider login. The session
+	// token (or the error) is passed in the URL fragment so it does not reach the server logs.
+	defaultOIDCPostLoginURL = "/login"
+	// oidcStateCookie binds the login flow to the browser that started it
+	oidcStateCookie = "oidc_state"
+	oidcStateScope  = "oidc-state"
+	oidcStateTTL    = 10 * time.Minute
+	// oidcCookiePath covers the callback of both the versioned API and its aliases, the login may go through either
+	oidcCookiePath = apiLegacyPath
+)
+
+type oidcState struct {
+	Scope string `json:"scope"`
+	Nonce string `json:"nonce"`
+	Exp   int64  `json:"exp"`
+}
+
+// handleOIDCLogin redirects the user to the identity provider login page
+func (s *server) handleOIDCLogin(c *gin.Context) {
+	nonceBytes := make([]byte, 16)
+	if _, err := rand.Read(nonceBytes); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	state := oidcState{
+		Scope: oidcStateScope,
+		Nonce: hex.EncodeToString(nonceBytes),
+		Exp:   s.clock.Now().Add(oidcStateTTL).Unix(),
+	}
+	signedState := s.signOIDCState(state)
+
+	authURL, err := s.oidcProvider.AuthCodeURL(c.Request.Context(), signedState, state.Nonce)
+	if err != nil {
+		log.Warn("OIDC login failed", "error", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "identity provider unavailable"})
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, signedState, int(oidcStateTTL.Seconds()), s.basePath+oidcCookiePath, "", s.isSecureRequest(c), true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// handleOIDCCallback completes the login started by handleOIDCLogin, issuing a session token for the user
+// authenticated by the identity provider
+func (s *server) handleOIDCCallback(c *gin.Context) {
+	if len(c.Query("error")) > 0 {
+		s.redirectAfterOIDCLogin(c, "error", c.Query("error"))
+		return
+	}
+
+	signedState := c.Query("state")
+	cookieState, err := c.Cookie(oidcStateCookie)
+	if err != nil || len(signedState) == 0 || cookieState != signedState {
+		s.redirectAfterOIDCLogin(c, "error", "invalid login state")
+		return
+	}
+	c.SetCookie(oidcStateCookie, "", -1, s.basePath+oidcCookiePath, "", s.isSecureRequest(c), true)
+
+	state, err := s.verifyOIDCState(signedState)
+	if err != nil {
+		s.redirectAfterOIDCLogin(c, "error", err.Error())
+		return
+	}
+
+	identity, err := s.oidcProvider.Exchange(c.Request.Context(), c.Query("code"), state.Nonce)
+	if err != nil {
+		log.Warn("OIDC login failed", "error", err)
+		s.redirectAfterOIDCLogin(c, "error", "login failed")
+		return
+	}
+	if len(identity.Tenant) > 0 && !s.isTenant(identity.Tenant) {
+		log.Warn("OIDC login failed", "username", identity.Username, "error", "unknown tenant "+identity.Tenant)
+		s.redirectAfterOIDCLogin(c, "error", "login failed")
+		return
+	}
+
+	log.Info("OIDC login", "username", identity.Username, "role", identity.Role, "tenant", identity.Tenant)
+	token, err := s.startSession(c, sessionClaims{
+		Sub:    identity.Username,
+		Tenant: identity.Tenant,
+		Role:   identity.Role,
+		OIDC:   true,
+	})
+	if err != nil {
+		log.Warn("OIDC login failed", "username", identity.Username, "error", err)
+		s.redirectAfterOIDCLogin(c, "error", "login failed")
+		return
+	}
+	s.redirectAfterOIDCLogin(c, "token", token)
+}
+
+func (s *server) redirectAfterOIDCLogin(c *gin.Context, key string, value string) {
+	fragment := url.Values{}
+	fragment.Set(key, value)
+
+	c.Redirect(http.StatusFound, s.oidcPostLoginURL+"#"+fragment.Encode())
+}
+
+func (s *server) signOIDCState(state oidcState) string {
+	stateBytes, _ := json.Marshal(state)
+	payload := base64.RawURLEncoding.EncodeToString(stateBytes)
+
+	macd := hmac.New(sha256.New, s.jwtSecret)
+	macd.Write([]byte(payload))
+
+	return payload + "." + base64.RawURLEncoding.EncodeToString(macd.Sum(nil))
+}
+
+func (s *server) verifyOIDCState(signedState string) (*oidcState, error) {
+	parts := strings.Split(signedState, ".")
+	if len(parts) != 2 {
+		return nil, errors.New("invalid login state")
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errors.New("invalid login state")
+	}
+	macd := hmac.New(sha256.New, s.jwtSecret)
+	macd.Write([]byte(parts[0]))
+	if !hmac.Equal(sig, macd.Sum(nil)) {
+		return nil, errors.New("invalid login state")
+	}
+
+	stateBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, errors.New("invalid login state")
+	}
+	state := &oidcState{}
+	err = json.Unmarshal(stateBytes, state)
+	if err != nil || state.Scope != oidcStateScope {
+		return nil, errors.New("invalid login state")
+	}
+	if s.clock.Now().Unix() > state.Exp {
+		return nil, errors.New("login expired, please retry")
+	}
+
+	return state, nil
+}
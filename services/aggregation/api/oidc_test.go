@@ -0,0 +1,140 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/storage"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/testsCommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_OIDCLogin(t *testing.T) {
+	store, err := storage.NewSQLiteStorage(":memory:", 100, storage.SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	var providedNonce string
+	identity := &common.UserIdentity{Username: "jane@example.com", Role: common.RoleViewer}
+	provider := &testsCommon.OIDCProviderStub{
+		AuthCodeURLHandler: func(ctx context.Context, state string, nonce string) (string, error) {
+			providedNonce = nonce
+			return "https://idp.example.com/authorize?state=" + url.QueryEscape(state), nil
+		},
+		ExchangeHandler: func(ctx context.Context, code string, nonce string) (*common.UserIdentity, error) {
+			if code != "valid-code" || nonce != providedNonce {
+				return nil, errors.New("invalid code")
+			}
+			return identity, nil
+		},
+	}
+
+	args := createTenantsArgs(store)
+	args.OIDCProvider = provider
+	serv, err := NewServer(args)
+	require.NoError(t, err)
+
+	login := func() (string, *http.Cookie) {
+		req, _ := http.NewRequest("GET", "/api/auth/oidc/login", nil)
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusFound, w.Code)
+
+		location, errParse := url.Parse(w.Header().Get("Location"))
+		require.NoError(t, errParse)
+		cookies := w.Result().Cookies()
+		require.Len(t, cookies, 1)
+		assert.True(t, cookies[0].HttpOnly)
+
+		return location.Query().Get("state"), cookies[0]
+	}
+	callback := func(query string, cookie *http.Cookie) url.Values {
+		req, _ := http.NewRequest("GET", "/api/auth/oidc/callback?"+query, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusFound, w.Code)
+
+		location := w.Header().Get("Location")
+		require.True(t, strings.HasPrefix(location, defaultOIDCPostLoginURL+"#"))
+		fragment, errParse := url.ParseQuery(strings.TrimPrefix(location, defaultOIDCPostLoginURL+"#"))
+		require.NoError(t, errParse)
+
+		return fragment
+	}
+
+	// the identity provider reported an error
+	fragment := callback("error=access_denied", nil)
+	assert.Equal(t, "access_denied", fragment.Get("error"))
+
+	// the state is bound to the browser
+	state, cookie := login()
+	fragment = callback("code=valid-code&state="+url.QueryEscape(state), nil)
+	assert.Equal(t, "invalid login state", fragment.Get("error"))
+
+	// forged state
+	forged := &http.Cookie{Name: oidcStateCookie, Value: "e30.c2ln"}
+	fragment = callback("code=valid-code&state=e30.c2ln", forged)
+	assert.Equal(t, "invalid login state", fragment.Get("error"))
+
+	fragment = callback("code=invalid-code&state="+url.QueryEscape(state), cookie)
+	assert.Equal(t, "login failed", fragment.Get("error"))
+
+	state, cookie = login()
+	fragment = callback("code=valid-code&state="+url.QueryEscape(state), cookie)
+	token := fragment.Get("token")
+	require.NotEmpty(t, token)
+
+	doRequest := func(method string, url string, body string) int {
+		req, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+
+		return w.Code
+	}
+
+	// viewers can only read
+	assert.Equal(t, http.StatusOK, doRequest("GET", "/api/metrics", ""))
+	assert.Equal(t, http.StatusForbidden, doRequest("POST", "/api/config/metrics/alarm", `{"name":"VM1.Node1.nonce","enabled":true}`))
+
+	// unknown tenants are rejected
+	identity = &common.UserIdentity{Username: "john@example.com", Role: common.RoleAdmin, Tenant: "globex"}
+	state, cookie = login()
+	fragment = callback("code=valid-code&state="+url.QueryEscape(state), cookie)
+	assert.Equal(t, "login failed", fragment.Get("error"))
+
+	identity = &common.UserIdentity{Username: "john@example.com", Role: common.RoleAdmin, Tenant: "acme"}
+	state, cookie = login()
+	fragment = callback("code=valid-code&state="+url.QueryEscape(state), cookie)
+	require.NotEmpty(t, fragment.Get("token"))
+}
+
+func TestServer_OIDCDisabled(t *testing.T) {
+	serv, store := setupTestServer(t)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	req, _ := http.NewRequest("GET", "/api/auth/oidc/login", nil)
+	w := httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	req, _ = http.NewRequest("GET", "/api/app-info", nil)
+	w = httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	assert.JSONEq(t, `{"version":"","oidcEnabled":false}`, w.Body.String())
+}
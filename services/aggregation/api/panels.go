@@ -0,0 +1,231 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+)
+
+// panelMetric is a metric rendered in a panel, labeled with its name relative to the panel and the node
+type panelMetric struct {
+	latestMetric
+	Label string `json:"label"`
+}
+
+// panelNode groups the metrics of a node (e.g. VM1.Node1.nonce and VM1.Node1.epoch) in a panel
+type panelNode struct {
+	Name    string        `json:"name"`
+	Metrics []panelMetric `json:"metrics"`
+}
+
+// panel groups the metrics of an agent (VM), or the metrics assigned to a custom panel, as rendered by the dashboard
+type panel struct {
+	Name         string `json:"name"`
+	DisplayOrder int    `json:"displayOrder"`
+	// Custom is set for the panels grouping only assigned metrics, listed in their assignment order
+	Custom bool `json:"custom"`
+	// Heartbeat is the <panel>.Active metric of the agent, nil if not reported
+	Heartbeat *latestMetric `json:"heartbeat"`
+	// Metrics are the metrics of the agent not bound to a node, e.g. VM1.CPU
+	Metrics []panelMetric `json:"metrics"`
+	Nodes   []panelNode   `json:"nodes"`
+}
+
+// handleGetPanels returns the metrics of /metrics grouped in the hierarchy rendered by the dashboard: the panels of
+// the agents, ordered by the requested dashboard and the panel configs, their nodes and the metrics ordered by
+// display order
+func (s *server) handleGetPanels(c *gin.Context) {
+	metrics, latestRecordedAt, dashboard, ok := s.latestMetrics(c)
+	if !ok {
+		return
+	}
+
+	configs, err := s.storage.GetPanelsConfigs(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	customPanels, err := s.storage.GetCustomPanels(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	writeJSONWithETag(c, latestRecordedAt, gin.H{"panels": buildPanels(metrics, configs, customPanels, dashboard)})
+}
+
+// handleSetPanelMetrics assigns metrics to a custom panel, regardless of their names, so a panel can mix the metrics of
+// several agents. The assignment replaces the previous one, an empty list removes the panel.
+func (s *server) handleSetPanelMetrics(c *gin.Context) {
+	var req struct {
+		Metrics []string `json:"metrics"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+
+	name := strings.TrimSpace(c.Param("panel"))
+	if len(name) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "empty panel name"})
+		return
+	}
+	for _, metric := range req.Metrics {
+		if len(strings.TrimSpace(metric)) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "empty metric name"})
+			return
+		}
+	}
+
+	err := s.storage.SetPanelMetrics(c.Request.Context(), name, req.Metrics)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// buildPanels groups the metrics in the panels discovered from their names, then adds the metrics assigned to the
+// custom panels. The assigned metrics not visible in the request (other tenants, other dashboards) are skipped.
+func buildPanels(metrics []latestMetric, configs map[string]int, customPanels map[string][]string, dashboard *common.Dashboard) []*panel {
+	panels := make([]*panel, 0)
+	panelsByName := make(map[string]*panel)
+	getPanel := func(panelName string) *panel {
+		p, found := panelsByName[panelName]
+		if !found {
+			p = &panel{
+				Name:         panelName,
+				DisplayOrder: configs[panelName],
+				Metrics:      make([]panelMetric, 0),
+				Nodes:        make([]panelNode, 0),
+			}
+			panelsByName[panelName] = p
+			panels = append(panels, p)
+		}
+
+		return p
+	}
+
+	metricsByName := make(map[string]latestMetric, len(metrics))
+	for _, metric := range metrics {
+		metricsByName[metric.Name] = metric
+		panelName := metric.Tags[common.TagVM]
+		if len(panelName) == 0 {
+			panelName = strings.Split(metric.Name, ".")[0]
+		}
+
+		p := getPanel(panelName)
+		if metric.Name == panelName+"."+common.HeartbeatMetricSuffix {
+			heartbeat := metric
+			p.Heartbeat = &heartbeat
+			continue
+		}
+
+		p.addMetric(metric)
+	}
+
+	for panelName, names := range customPanels {
+		_, discovered := panelsByName[panelName]
+		var p *panel
+		for _, name := range names {
+			metric, found := metricsByName[name]
+			if !found {
+				continue
+			}
+			if p == nil {
+				p = getPanel(panelName)
+				p.Custom = !discovered
+			}
+			// the metrics assigned to a discovered panel of the same name are merged in it
+			if !p.containsMetric(name) {
+				p.Metrics = append(p.Metrics, panelMetric{latestMetric: metric, Label: strings.TrimPrefix(name, panelName+".")})
+			}
+		}
+	}
+
+	sort.SliceStable(panels, func(i, j int) bool {
+		// the panels selected by a dashboard keep the dashboard order
+		if dashboard != nil {
+			indexI := slices.Index(dashboard.Panels, panels[i].Name)
+			indexJ := slices.Index(dashboard.Panels, panels[j].Name)
+			if indexI != indexJ {
+				return indexJ == -1 || (indexI != -1 && indexI < indexJ)
+			}
+		}
+		if panels[i].DisplayOrder != panels[j].DisplayOrder {
+			return panels[i].DisplayOrder < panels[j].DisplayOrder
+		}
+
+		return panels[i].Name < panels[j].Name
+	})
+	for _, p := range panels {
+		if p.Custom {
+			continue
+		}
+		sortPanelMetrics(p.Metrics)
+		sort.Slice(p.Nodes, func(i, j int) bool {
+			return p.Nodes[i].Name < p.Nodes[j].Name
+		})
+		for _, node := range p.Nodes {
+			sortPanelMetrics(node.Metrics)
+		}
+	}
+
+	return panels
+}
+
+func (p *panel) addMetric(metric latestMetric) {
+	label := strings.TrimPrefix(metric.Name, p.Name+".")
+	nodeName := metric.Tags[common.TagNode]
+	if len(nodeName) == 0 {
+		p.Metrics = append(p.Metrics, panelMetric{latestMetric: metric, Label: label})
+		return
+	}
+
+	label = strings.TrimPrefix(label, nodeName+".")
+	for i := range p.Nodes {
+		if p.Nodes[i].Name == nodeName {
+			p.Nodes[i].Metrics = append(p.Nodes[i].Metrics, panelMetric{latestMetric: metric, Label: label})
+			return
+		}
+	}
+
+	p.Nodes = append(p.Nodes, panelNode{
+		Name:    nodeName,
+		Metrics: []panelMetric{{latestMetric: metric, Label: label}},
+	})
+}
+
+func (p *panel) containsMetric(name string) bool {
+	if p.Heartbeat != nil && p.Heartbeat.Name == name {
+		return true
+	}
+	for _, metric := range p.Metrics {
+		if metric.Name == name {
+			return true
+		}
+	}
+	for _, node := range p.Nodes {
+		for _, metric := range node.Metrics {
+			if metric.Name == name {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func sortPanelMetrics(metrics []panelMetric) {
+	sort.Slice(metrics, func(i, j int) bool {
+		if metrics[i].DisplayOrder != metrics[j].DisplayOrder {
+			return metrics[i].DisplayOrder < metrics[j].DisplayOrder
+		}
+
+		return metrics[i].Name < metrics[j].Name
+	})
+}
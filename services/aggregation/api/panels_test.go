@@ -0,0 +1,167 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildPanels(t *testing.T) {
+	t.Parallel()
+
+	metrics := []latestMetric{
+		{Name: "VM1.Active", Tags: map[string]string{"vm": "VM1", "kind": "Active"}},
+		{Name: "VM1.Node2.nonce", Tags: map[string]string{"vm": "VM1", "node": "Node2", "kind": "nonce"}},
+		{Name: "VM1.Node1.nonce", Tags: map[string]string{"vm": "VM1", "node": "Node1", "kind": "nonce"}, DisplayOrder: 2},
+		{Name: "VM1.Node1.epoch", Tags: map[string]string{"vm": "VM1", "node": "Node1", "kind": "epoch"}, DisplayOrder: 1},
+		{Name: "VM1.CPU", Tags: map[string]string{"vm": "VM1", "kind": "CPU"}},
+		{Name: "VM2.CPU", Tags: map[string]string{"vm": "VM2", "kind": "CPU"}},
+		{Name: "DC1.VM3.CPU", Tags: map[string]string{"vm": "DC1.VM3", "kind": "CPU"}},
+		{Name: "Lag"},
+	}
+
+	t.Run("should group the metrics and order the panels by config", func(t *testing.T) {
+		t.Parallel()
+
+		panels := buildPanels(metrics, map[string]int{"VM2": -1}, nil, nil)
+		names := make([]string, 0, len(panels))
+		for _, p := range panels {
+			names = append(names, p.Name)
+		}
+		assert.Equal(t, []string{"VM2", "DC1.VM3", "Lag", "VM1"}, names)
+
+		vm1 := panels[3]
+		require.NotNil(t, vm1.Heartbeat)
+		assert.Equal(t, "VM1.Active", vm1.Heartbeat.Name)
+		require.Len(t, vm1.Metrics, 1)
+		assert.Equal(t, "CPU", vm1.Metrics[0].Label)
+		require.Len(t, vm1.Nodes, 2)
+		assert.Equal(t, "Node1", vm1.Nodes[0].Name)
+		require.Len(t, vm1.Nodes[0].Metrics, 2)
+		assert.Equal(t, "epoch", vm1.Nodes[0].Metrics[0].Label)
+		assert.Equal(t, "nonce", vm1.Nodes[0].Metrics[1].Label)
+		assert.Equal(t, "Node2", vm1.Nodes[1].Name)
+
+		assert.Nil(t, panels[0].Heartbeat)
+		assert.Equal(t, -1, panels[0].DisplayOrder)
+		assert.Equal(t, "CPU", panels[1].Metrics[0].Label)
+		assert.Equal(t, "Lag", panels[2].Metrics[0].Label)
+	})
+	t.Run("the dashboard panels keep the dashboard order", func(t *testing.T) {
+		t.Parallel()
+
+		dashboard := &common.Dashboard{Panels: []string{"VM1", "DC1.VM3"}}
+		panels := buildPanels(metrics, map[string]int{"VM2": -1}, nil, dashboard)
+		names := make([]string, 0, len(panels))
+		for _, p := range panels {
+			names = append(names, p.Name)
+		}
+		assert.Equal(t, []string{"VM1", "DC1.VM3", "VM2", "Lag"}, names)
+	})
+	t.Run("the assigned metrics are added to the custom panels", func(t *testing.T) {
+		t.Parallel()
+
+		customPanels := map[string][]string{
+			"Critical": {"VM2.CPU", "VM1.Node1.nonce", "VM9.missing"},
+			"VM2":      {"VM1.CPU", "VM2.CPU"},
+			"Empty":    {"VM9.missing"},
+		}
+		panels := buildPanels(metrics, map[string]int{"Critical": -2, "VM2": -1}, customPanels, nil)
+		require.Len(t, panels, 5)
+
+		critical := panels[0]
+		assert.Equal(t, "Critical", critical.Name)
+		assert.True(t, critical.Custom)
+		assert.Nil(t, critical.Heartbeat)
+		require.Len(t, critical.Metrics, 2)
+		assert.Equal(t, "VM2.CPU", critical.Metrics[0].Label)
+		assert.Equal(t, "VM1.Node1.nonce", critical.Metrics[1].Label)
+		assert.Empty(t, critical.Nodes)
+
+		vm2 := panels[1]
+		assert.False(t, vm2.Custom)
+		require.Len(t, vm2.Metrics, 2)
+		assert.Equal(t, "VM1.CPU", vm2.Metrics[0].Name)
+		assert.Equal(t, "VM1.CPU", vm2.Metrics[0].Label)
+		assert.Equal(t, "CPU", vm2.Metrics[1].Label)
+
+		vm1 := panels[4]
+		assert.Equal(t, "VM1", vm1.Name)
+		require.Len(t, vm1.Metrics, 1)
+		assert.Equal(t, "VM1.CPU", vm1.Metrics[0].Name)
+	})
+}
+
+func TestServer_GetPanels(t *testing.T) {
+	serv, store := setupTestServer(t)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	ctx := context.Background()
+	now := time.Now().Unix()
+	require.NoError(t, store.SaveMetric(ctx, "VM1.Active", common.MetricTypeBool, 1, "true", now))
+	require.NoError(t, store.SaveMetric(ctx, "VM1.Node1.nonce", common.MetricTypeUint64, 1, "10", now))
+	require.NoError(t, store.SaveMetric(ctx, "VM2.CPU", common.MetricTypeUint64, 1, "50", now))
+	require.NoError(t, store.UpdatePanelOrder(ctx, "VM2", -1))
+
+	req, _ := http.NewRequest("GET", "/api/panels", nil)
+	w := httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	req, _ = http.NewRequest("GET", "/api/panels", nil)
+	req.Header.Set("Authorization", "Bearer "+getValidToken(serv))
+	w = httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Panels []panel `json:"panels"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Panels, 2)
+	assert.Equal(t, "VM2", resp.Panels[0].Name)
+	assert.Equal(t, "50", resp.Panels[0].Metrics[0].Value)
+	assert.Equal(t, "VM1", resp.Panels[1].Name)
+	require.NotNil(t, resp.Panels[1].Heartbeat)
+	require.Len(t, resp.Panels[1].Nodes, 1)
+	assert.Equal(t, "Node1", resp.Panels[1].Nodes[0].Name)
+	assert.Equal(t, "nonce", resp.Panels[1].Nodes[0].Metrics[0].Label)
+	assert.Empty(t, resp.Panels[1].Metrics)
+
+	setPanelMetrics := func(panel string, body string) int {
+		req, _ = http.NewRequest("PUT", "/api/config/panels/"+panel+"/metrics", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+getValidToken(serv))
+		req.Header.Set("Content-Type", "application/json")
+		w = httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusBadRequest, setPanelMetrics("Critical", "{"))
+	assert.Equal(t, http.StatusBadRequest, setPanelMetrics("%20", `{"metrics":["VM2.CPU"]}`))
+	assert.Equal(t, http.StatusBadRequest, setPanelMetrics("Critical", `{"metrics":[""]}`))
+	require.Equal(t, http.StatusOK, setPanelMetrics("Critical", `{"metrics":["VM2.CPU","VM1.Node1.nonce"]}`))
+
+	req, _ = http.NewRequest("GET", "/api/panels", nil)
+	req.Header.Set("Authorization", "Bearer "+getValidToken(serv))
+	w = httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	resp.Panels = nil
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Panels, 3)
+	assert.Equal(t, "Critical", resp.Panels[1].Name)
+	assert.True(t, resp.Panels[1].Custom)
+	require.Len(t, resp.Panels[1].Metrics, 2)
+	assert.Equal(t, "VM2.CPU", resp.Panels[1].Metrics[0].Name)
+	assert.Equal(t, "VM1.Node1.nonce", resp.Panels[1].Metrics[1].Name)
+}
@@ -0,0 +1,27 @@
+package api
+
+import (
+	"net/http/pprof"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleProfiling serves the Go runtime profiles (heap, goroutine, CPU profile, trace...) under
+// /api/admin/debug/pprof/. The profile name is taken from the route, as the standard pprof.Index handler expects
+// the /debug/pprof/ path prefix.
+func (s *server) handleProfiling(c *gin.Context) {
+	switch name := c.Param("profile")[1:]; name {
+	case "":
+		pprof.Index(c.Writer, c.Request)
+	case "cmdline":
+		pprof.Cmdline(c.Writer, c.Request)
+	case "profile":
+		pprof.Profile(c.Writer, c.Request)
+	case "symbol":
+		pprof.Symbol(c.Writer, c.Request)
+	case "trace":
+		pprof.Trace(c.Writer, c.Request)
+	default:
+		pprof.Handler(name).ServeHTTP(c.Writer, c.Request)
+	}
+}
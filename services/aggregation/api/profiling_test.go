@@ -0,0 +1,75 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/testsCommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createProfilingServer(t *testing.T, enabled bool) *server {
+	serv, err := NewServer(ArgsWebServer{
+		ServiceKeyApi:    "test-secret",
+		AuthUsername:     "admin",
+		AuthPassword:     "password",
+		Storage:          &testsCommon.StoreStub{},
+		GeneralHandler:   func(h http.Handler) http.Handler { return h },
+		ProfilingEnabled: enabled,
+	})
+	require.NoError(t, err)
+
+	return serv
+}
+
+func TestServer_Profiling(t *testing.T) {
+	t.Parallel()
+
+	getProfile := func(serv *server, path string, token string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/api/admin/debug/pprof/"+path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+
+		return w
+	}
+
+	t.Run("disabled should not expose the endpoints", func(t *testing.T) {
+		t.Parallel()
+
+		serv := createProfilingServer(t, false)
+		w := getProfile(serv, "heap", getValidToken(serv))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+	t.Run("unauthenticated should error", func(t *testing.T) {
+		t.Parallel()
+
+		serv := createProfilingServer(t, true)
+		w := getProfile(serv, "heap", "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+	t.Run("should serve the profiles", func(t *testing.T) {
+		t.Parallel()
+
+		serv := createProfilingServer(t, true)
+		token := getValidToken(serv)
+
+		w := getProfile(serv, "", token)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "goroutine")
+
+		w = getProfile(serv, "goroutine?debug=1", token)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "goroutine profile:")
+
+		w = getProfile(serv, "cmdline", token)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		w = getProfile(serv, "unknown", token)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/storage"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/testsCommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createProxyArgs(t *testing.T) ArgsWebServer {
+	store, err := storage.NewSQLiteStorage(":memory:", 100, storage.SQLiteTuning{})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = store.Close()
+	})
+
+	return ArgsWebServer{
+		ServiceKeyApi:  "test-secret",
+		AuthUsername:   "admin",
+		AuthPassword:   "password",
+		ListenAddress:  ":0",
+		Storage:        store,
+		GeneralHandler: func(h http.Handler) http.Handler { return h },
+		BasePath:       "/monitoring/",
+		TrustedProxies: []string{"10.0.0.1", "192.168.0.0/16"},
+	}
+}
+
+func TestNewServer_TrustedProxies(t *testing.T) {
+	t.Parallel()
+
+	args := createProxyArgs(t)
+	args.TrustedProxies = []string{"nginx"}
+	serv, err := NewServer(args)
+	assert.Nil(t, serv)
+	assert.ErrorContains(t, err, "invalid trusted proxies")
+}
+
+func TestServer_BasePath(t *testing.T) {
+	t.Parallel()
+
+	serv, err := NewServer(createProxyArgs(t))
+	require.NoError(t, err)
+	handler := serv.httpHandler()
+
+	doRequest := func(url string) int {
+		req, _ := http.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		return w.Code
+	}
+
+	// the proxy can forward the prefix or strip it
+	assert.Equal(t, http.StatusOK, doRequest("/monitoring/api/app-info"))
+	assert.Equal(t, http.StatusOK, doRequest("/api/app-info"))
+	assert.Equal(t, http.StatusOK, doRequest("/monitoring/healthz"))
+	assert.Equal(t, http.StatusNotFound, doRequest("/monitoringx/api/app-info"))
+}
+
+func TestServer_ClientIPBehindProxy(t *testing.T) {
+	t.Parallel()
+
+	serv, err := NewServer(createProxyArgs(t))
+	require.NoError(t, err)
+	handler := serv.httpHandler()
+
+	login := func(remoteAddr string, forwardedFor string) string {
+		req, _ := http.NewRequest("POST", "/monitoring/api/auth/login", bytes.NewBufferString(`{"username":"admin", "password":"password"}`))
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp := make(map[string]string)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp["token"]
+	}
+	sessionAddress := func(token string) string {
+		req, _ := http.NewRequest("GET", "/monitoring/api/sessions", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var resp struct {
+			Sessions []common.Session `json:"sessions"`
+			Current  string           `json:"current"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		for _, session := range resp.Sessions {
+			if session.ID == resp.Current {
+				return session.RemoteAddr
+			}
+		}
+		return ""
+	}
+
+	assert.Equal(t, "203.0.113.7", sessionAddress(login("10.0.0.1:4000", "203.0.113.7")))
+	assert.Equal(t, "203.0.113.8", sessionAddress(login("192.168.1.20:4000", "203.0.113.8, 192.168.1.1")))
+	// the forwarding headers of the untrusted peers are ignored
+	assert.Equal(t, "10.0.0.2", sessionAddress(login("10.0.0.2:4000", "203.0.113.9")))
+}
+
+func TestServer_OIDCCookieBehindProxy(t *testing.T) {
+	t.Parallel()
+
+	args := createProxyArgs(t)
+	args.OIDCProvider = &testsCommon.OIDCProviderStub{}
+	serv, err := NewServer(args)
+	require.NoError(t, err)
+	assert.Equal(t, "/monitoring/login", serv.oidcPostLoginURL)
+
+	login := func(remoteAddr string) *http.Cookie {
+		req, _ := http.NewRequest("GET", "/monitoring/api/auth/oidc/login", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-Proto", "https")
+		w := httptest.NewRecorder()
+		serv.httpHandler().ServeHTTP(w, req)
+		require.Equal(t, http.StatusFound, w.Code)
+
+		cookies := w.Result().Cookies()
+		require.Len(t, cookies, 1)
+		return cookies[0]
+	}
+
+	cookie := login("10.0.0.1:4000")
+	assert.Equal(t, "/monitoring/api", cookie.Path)
+	assert.True(t, cookie.Secure)
+	assert.False(t, login("10.0.0.2:4000").Secure)
+}
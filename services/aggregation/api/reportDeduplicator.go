@@ -0,0 +1,92 @@
+package api
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// duplicateReportsWindow is how long the cycle ID of an accepted report is remembered, it covers the agent retries
+	// and the MQTT redeliveries
+	duplicateReportsWindow = 10 * time.Minute
+	// maxTrackedReports bounds the memory of the deduplicator, the oldest cycle IDs are forgotten first
+	maxTrackedReports = 100000
+)
+
+var errDuplicateReport = errors.New("duplicate report")
+
+var errInvalidCycleID = errors.New("invalid cycle ID")
+
+type trackedReport struct {
+	key        string
+	acceptedAt time.Time
+}
+
+// reportDeduplicator remembers the cycle IDs of the recently accepted reports, so a report sent again (e.g. retried
+// by the agent after a timeout, or redelivered by the MQTT broker) does not create a second sample of each metric
+type reportDeduplicator struct {
+	mut      sync.Mutex
+	window   time.Duration
+	maxSize  int
+	accepted map[string]time.Time
+	// queue holds the cycle IDs in the order they were accepted, the forgotten ones are skipped when popped
+	queue []trackedReport
+}
+
+func newReportDeduplicator(window time.Duration, maxSize int) *reportDeduplicator {
+	return &reportDeduplicator{
+		window:   window,
+		maxSize:  maxSize,
+		accepted: make(map[string]time.Time),
+	}
+}
+
+// accept records the cycle ID of the tenant, returning false if it was already accepted within the window
+func (dedup *reportDeduplicator) accept(tenant string, cycleID string, now time.Time) bool {
+	dedup.mut.Lock()
+	defer dedup.mut.Unlock()
+
+	dedup.evict(now)
+
+	key := tenant + "/" + cycleID
+	_, found := dedup.accepted[key]
+	if found {
+		return false
+	}
+
+	dedup.accepted[key] = now
+	dedup.queue = append(dedup.queue, trackedReport{key: key, acceptedAt: now})
+
+	return true
+}
+
+// forget removes the cycle ID of a report that could not be saved, so its retry is accepted
+func (dedup *reportDeduplicator) forget(tenant string, cycleID string) {
+	dedup.mut.Lock()
+	defer dedup.mut.Unlock()
+
+	delete(dedup.accepted, tenant+"/"+cycleID)
+}
+
+// evict drops the cycle IDs older than the window and the oldest ones above the maximum size, the mutex must be held
+func (dedup *reportDeduplicator) evict(now time.Time) {
+	numEvicted := 0
+	for _, report := range dedup.queue {
+		isExpired := now.Sub(report.acceptedAt) >= dedup.window
+		isAboveSize := len(dedup.queue)-numEvicted >= dedup.maxSize
+		if !isExpired && !isAboveSize {
+			break
+		}
+
+		acceptedAt, found := dedup.accepted[report.key]
+		if found && acceptedAt.Equal(report.acceptedAt) {
+			delete(dedup.accepted, report.key)
+		}
+		numEvicted++
+	}
+
+	if numEvicted > 0 {
+		dedup.queue = dedup.queue[numEvicted:]
+	}
+}
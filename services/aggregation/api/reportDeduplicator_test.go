@@ -0,0 +1,98 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportDeduplicator(t *testing.T) {
+	t.Parallel()
+
+	start := time.Unix(1000, 0)
+	t.Run("drops the cycle IDs accepted within the window", func(t *testing.T) {
+		t.Parallel()
+
+		dedup := newReportDeduplicator(time.Minute, 10)
+		assert.True(t, dedup.accept("", "cycle1", start))
+		assert.False(t, dedup.accept("", "cycle1", start.Add(time.Second)))
+		// the cycle IDs are scoped by tenant
+		assert.True(t, dedup.accept("tenant1", "cycle1", start.Add(time.Second)))
+		assert.True(t, dedup.accept("", "cycle1", start.Add(time.Minute)))
+	})
+	t.Run("forgotten cycle IDs are accepted again", func(t *testing.T) {
+		t.Parallel()
+
+		dedup := newReportDeduplicator(time.Minute, 10)
+		assert.True(t, dedup.accept("", "cycle1", start))
+		dedup.forget("", "cycle1")
+		assert.True(t, dedup.accept("", "cycle1", start.Add(time.Second)))
+		assert.False(t, dedup.accept("", "cycle1", start.Add(2*time.Second)))
+
+		// the stale queue entry of the forgotten acceptance does not evict the new one
+		assert.True(t, dedup.accept("", "cycle2", start.Add(time.Minute)))
+		assert.False(t, dedup.accept("", "cycle1", start.Add(time.Minute)))
+	})
+	t.Run("the oldest cycle IDs are evicted above the maximum size", func(t *testing.T) {
+		t.Parallel()
+
+		dedup := newReportDeduplicator(time.Hour, 3)
+		for i := 0; i < 5; i++ {
+			assert.True(t, dedup.accept("", fmt.Sprintf("cycle%d", i), start))
+		}
+		assert.Len(t, dedup.accepted, 3)
+		assert.True(t, dedup.accept("", "cycle0", start))
+		assert.False(t, dedup.accept("", "cycle4", start))
+	})
+}
+
+func TestReportEndpoint_DuplicateReports(t *testing.T) {
+	serv, store := setupTestServer(t)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	report := func(body string) (int, map[string]interface{}) {
+		req, _ := http.NewRequest("POST", "/api/report", bytes.NewBufferString(body))
+		req.Header.Set("X-Api-Key", "test-secret")
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+
+		response := make(map[string]interface{})
+		_ = json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response
+	}
+
+	body := `{"cycleId": "0123456789abcdef", "metrics": {"VM1.Active": {"value": "true", "type": "bool", "numAggregation": 10}}}`
+	code, response := report(body)
+	require.Equal(t, http.StatusOK, code)
+	assert.Nil(t, response["duplicate"])
+
+	code, response = report(body)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, true, response["duplicate"])
+
+	// the reports without a cycle ID are never dropped
+	body = `{"metrics": {"VM1.Active": {"value": "true", "type": "bool", "numAggregation": 10}}}`
+	code, _ = report(body)
+	require.Equal(t, http.StatusOK, code)
+	code, response = report(body)
+	require.Equal(t, http.StatusOK, code)
+	assert.Nil(t, response["duplicate"])
+
+	hist, err := store.GetMetricHistory(context.Background(), "VM1.Active")
+	require.NoError(t, err)
+	assert.Len(t, hist.History, 3)
+	assert.Equal(t, uint64(1), serv.stats.snapshot(time.Now()).NumDuplicates)
+
+	code, _ = report(`{"cycleId": "bad id", "metrics": {}}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
@@ -0,0 +1,236 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+)
+
+// reportsRateWindow is the number of seconds the reports rate is averaged over
+const reportsRateWindow = 60
+
+// prometheusContentType is the content type of the Prometheus text exposition format
+const prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// selfStats holds the operational counters of the aggregation service itself
+type selfStats struct {
+	mut              sync.Mutex
+	startTime        time.Time
+	numReports       uint64
+	numMetricsSaved  uint64
+	numSaveErrors    uint64
+	numWrites        uint64
+	totalWriteTime   time.Duration
+	maxWriteTime     time.Duration
+	numBatches       uint64
+	numQueueRejected uint64
+	numDuplicates    uint64
+	reportsPerSecond [reportsRateWindow]uint64
+	bucketTimestamps [reportsRateWindow]int64
+}
+
+func newSelfStats() *selfStats {
+	return &selfStats{
+		startTime: time.Now(),
+	}
+}
+
+func (ss *selfStats) recordReport(now time.Time) {
+	ss.mut.Lock()
+	defer ss.mut.Unlock()
+
+	ss.numReports++
+
+	second := now.Unix()
+	index := second % reportsRateWindow
+	if ss.bucketTimestamps[index] != second {
+		ss.bucketTimestamps[index] = second
+		ss.reportsPerSecond[index] = 0
+	}
+	ss.reportsPerSecond[index]++
+}
+
+func (ss *selfStats) recordWrite(duration time.Duration, err error) {
+	ss.mut.Lock()
+	defer ss.mut.Unlock()
+
+	ss.numWrites++
+	ss.totalWriteTime += duration
+	if duration > ss.maxWriteTime {
+		ss.maxWriteTime = duration
+	}
+	if err != nil {
+		ss.numSaveErrors++
+		return
+	}
+	ss.numMetricsSaved++
+}
+
+func (ss *selfStats) recordBatch(numRecords int, duration time.Duration) {
+	ss.mut.Lock()
+	defer ss.mut.Unlock()
+
+	ss.numBatches++
+	ss.numWrites++
+	ss.numMetricsSaved += uint64(numRecords)
+	ss.totalWriteTime += duration
+	if duration > ss.maxWriteTime {
+		ss.maxWriteTime = duration
+	}
+}
+
+func (ss *selfStats) recordQueueRejected() {
+	ss.mut.Lock()
+	defer ss.mut.Unlock()
+
+	ss.numQueueRejected++
+}
+
+func (ss *selfStats) recordDuplicateReport() {
+	ss.mut.Lock()
+	defer ss.mut.Unlock()
+
+	ss.numDuplicates++
+}
+
+// selfStatsSnapshot is the JSON representation of the operational metrics
+type selfStatsSnapshot struct {
+	UptimeInSec      int64               `json:"uptimeInSec"`
+	NumReports       uint64              `json:"numReports"`
+	ReportsPerSecond float64             `json:"reportsPerSecond"`
+	NumMetricsSaved  uint64              `json:"numMetricsSaved"`
+	NumSaveErrors    uint64              `json:"numSaveErrors"`
+	NumDBWrites      uint64              `json:"numDBWrites"`
+	AvgDBWriteMs     float64             `json:"avgDBWriteMs"`
+	MaxDBWriteMs     float64             `json:"maxDBWriteMs"`
+	NumBatches       uint64              `json:"numBatches"`
+	NumQueueRejected uint64              `json:"numQueueRejected"`
+	NumDuplicates    uint64              `json:"numDuplicateReports"`
+	QueueLength      int                 `json:"queueLength"`
+	QueueCapacity    int                 `json:"queueCapacity"`
+	Storage          common.StorageStats `json:"storage"`
+	TableRows        map[string]int64    `json:"tableRows"`
+	NumGoroutines    int                 `json:"numGoroutines"`
+	HeapAllocBytes   uint64              `json:"heapAllocBytes"`
+	HeapInUseBytes   uint64              `json:"heapInUseBytes"`
+	SysBytes         uint64              `json:"sysBytes"`
+	NumGC            uint32              `json:"numGC"`
+}
+
+func (ss *selfStats) snapshot(now time.Time) selfStatsSnapshot {
+	ss.mut.Lock()
+	defer ss.mut.Unlock()
+
+	// the current second is not complete, so the rate is computed over the previous seconds
+	numRecentReports := uint64(0)
+	for i := range ss.bucketTimestamps {
+		age := now.Unix() - ss.bucketTimestamps[i]
+		if age > 0 && age <= reportsRateWindow {
+			numRecentReports += ss.reportsPerSecond[i]
+		}
+	}
+
+	snapshot := selfStatsSnapshot{
+		UptimeInSec:      int64(now.Sub(ss.startTime).Seconds()),
+		NumReports:       ss.numReports,
+		ReportsPerSecond: float64(numRecentReports) / reportsRateWindow,
+		NumMetricsSaved:  ss.numMetricsSaved,
+		NumSaveErrors:    ss.numSaveErrors,
+		NumDBWrites:      ss.numWrites,
+		MaxDBWriteMs:     float64(ss.maxWriteTime) / float64(time.Millisecond),
+		NumBatches:       ss.numBatches,
+		NumQueueRejected: ss.numQueueRejected,
+		NumDuplicates:    ss.numDuplicates,
+		NumGoroutines:    runtime.NumGoroutine(),
+	}
+	if ss.numWrites > 0 {
+		snapshot.AvgDBWriteMs = float64(ss.totalWriteTime) / float64(ss.numWrites) / float64(time.Millisecond)
+	}
+
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	snapshot.HeapAllocBytes = memStats.HeapAlloc
+	snapshot.HeapInUseBytes = memStats.HeapInuse
+	snapshot.SysBytes = memStats.Sys
+	snapshot.NumGC = memStats.NumGC
+
+	return snapshot
+}
+
+// writePrometheus writes the snapshot in the Prometheus text exposition format
+func (snapshot *selfStatsSnapshot) writePrometheus(w io.Writer) {
+	writeMetric := func(name string, metricType string, help string, value interface{}) {
+		_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, help, name, metricType, name, value)
+	}
+
+	writeMetric("aggregation_uptime_seconds", "gauge", "Time since the service started.", snapshot.UptimeInSec)
+	writeMetric("aggregation_reports_total", "counter", "Number of agent reports received.", snapshot.NumReports)
+	writeMetric("aggregation_duplicate_reports_total", "counter", "Number of agent reports dropped as duplicates of an accepted one.", snapshot.NumDuplicates)
+	writeMetric("aggregation_reports_per_second", "gauge", "Agent reports rate over the last minute.", snapshot.ReportsPerSecond)
+	writeMetric("aggregation_metrics_saved_total", "counter", "Number of metric values saved.", snapshot.NumMetricsSaved)
+	writeMetric("aggregation_metric_save_errors_total", "counter", "Number of metric values that failed to be saved.", snapshot.NumSaveErrors)
+	writeMetric("aggregation_db_writes_total", "counter", "Number of database writes.", snapshot.NumDBWrites)
+	writeMetric("aggregation_db_write_avg_seconds", "gauge", "Average database write latency.", snapshot.AvgDBWriteMs/1000)
+	writeMetric("aggregation_db_write_max_seconds", "gauge", "Maximum database write latency.", snapshot.MaxDBWriteMs/1000)
+	writeMetric("aggregation_storage_operations_total", "counter", "Number of storage operations.", snapshot.Storage.NumOperations)
+	writeMetric("aggregation_storage_slow_operations_total", "counter", "Number of storage operations slower than the threshold.", snapshot.Storage.NumSlowOperations)
+	writeMetric("aggregation_storage_timed_out_operations_total", "counter", "Number of storage operations that exceeded their deadline.", snapshot.Storage.NumTimedOutOperations)
+	writeMetric("aggregation_storage_operation_max_seconds", "gauge", "Maximum storage operation duration.", snapshot.Storage.MaxOperationMs/1000)
+	writeMetric("aggregation_write_batches_total", "counter", "Number of batches written by the write queue.", snapshot.NumBatches)
+	writeMetric("aggregation_write_queue_rejected_total", "counter", "Number of reports rejected because the write queue was full.", snapshot.NumQueueRejected)
+	writeMetric("aggregation_write_queue_length", "gauge", "Number of metric values waiting in the write queue.", snapshot.QueueLength)
+	writeMetric("aggregation_write_queue_capacity", "gauge", "Maximum number of metric values in the write queue.", snapshot.QueueCapacity)
+
+	_, _ = fmt.Fprint(w, "# HELP aggregation_table_rows Number of rows of each database table.\n# TYPE aggregation_table_rows gauge\n")
+	tables := make([]string, 0, len(snapshot.TableRows))
+	for table := range snapshot.TableRows {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	for _, table := range tables {
+		_, _ = fmt.Fprintf(w, "aggregation_table_rows{table=%q} %d\n", table, snapshot.TableRows[table])
+	}
+
+	writeMetric("go_goroutines", "gauge", "Number of goroutines that currently exist.", snapshot.NumGoroutines)
+	writeMetric("go_memstats_heap_alloc_bytes", "gauge", "Number of heap bytes allocated and still in use.", snapshot.HeapAllocBytes)
+	writeMetric("go_memstats_heap_inuse_bytes", "gauge", "Number of heap bytes that are in use.", snapshot.HeapInUseBytes)
+	writeMetric("go_memstats_sys_bytes", "gauge", "Number of bytes obtained from system.", snapshot.SysBytes)
+	writeMetric("go_gc_cycles_total", "counter", "Number of completed GC cycles.", snapshot.NumGC)
+}
+
+// handleInternalStats serves the operational metrics of the service as JSON or, with format=prometheus, in the
+// Prometheus text exposition format
+func (s *server) handleInternalStats(c *gin.Context) {
+	snapshot := s.stats.snapshot(time.Now())
+	snapshot.Storage = s.storage.GetStorageStats()
+	if s.writeQueue != nil {
+		snapshot.QueueLength = s.writeQueue.length()
+		snapshot.QueueCapacity = s.writeQueue.capacity
+	}
+
+	info, err := s.storage.GetSchemaInfo(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	snapshot.TableRows = make(map[string]int64, len(info.Tables))
+	for _, table := range info.Tables {
+		snapshot.TableRows[table.Name] = table.NumRows
+	}
+
+	if c.Query("format") == "prometheus" {
+		c.Header("Content-Type", prometheusContentType)
+		c.Status(http.StatusOK)
+		snapshot.writePrometheus(c.Writer)
+		return
+	}
+
+	c.JSON(http.StatusOK, snapshot)
+}
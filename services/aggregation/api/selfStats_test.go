@@ -0,0 +1,97 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelfStats(t *testing.T) {
+	t.Parallel()
+
+	stats := newSelfStats()
+	now := time.Unix(10000, 0)
+
+	for i := 0; i < 30; i++ {
+		stats.recordReport(now.Add(-time.Duration(i) * time.Second))
+	}
+	// outside the rate window
+	stats.recordReport(now.Add(-2 * reportsRateWindow * time.Second))
+
+	stats.recordWrite(2*time.Millisecond, nil)
+	stats.recordWrite(4*time.Millisecond, nil)
+	stats.recordWrite(6*time.Millisecond, errors.New("expected error"))
+
+	snapshot := stats.snapshot(now)
+	assert.Equal(t, uint64(31), snapshot.NumReports)
+	// the report of the current second is not counted
+	assert.Equal(t, float64(29)/reportsRateWindow, snapshot.ReportsPerSecond)
+	assert.Equal(t, uint64(2), snapshot.NumMetricsSaved)
+	assert.Equal(t, uint64(1), snapshot.NumSaveErrors)
+	assert.Equal(t, uint64(3), snapshot.NumDBWrites)
+	assert.Equal(t, float64(4), snapshot.AvgDBWriteMs)
+	assert.Equal(t, float64(6), snapshot.MaxDBWriteMs)
+	assert.Greater(t, snapshot.NumGoroutines, 0)
+	assert.Greater(t, snapshot.SysBytes, uint64(0))
+}
+
+func TestServer_InternalStats(t *testing.T) {
+	serv, store := setupTestServer(t)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	body := []byte(`{"metrics": {"VM1.Node1.nonce": {"value": "10", "type": "uint64", "numAggregation": 5}}}`)
+	req, _ := http.NewRequest("POST", "/api/report", bytes.NewBuffer(body))
+	req.Header.Set("X-Api-Key", "test-secret")
+	w := httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// unauthenticated
+	req, _ = http.NewRequest("GET", "/api/internal/stats", nil)
+	w = httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+
+	req, _ = http.NewRequest("GET", "/api/internal/stats", nil)
+	req.Header.Set("X-Api-Key", "wrong")
+	w = httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// session token
+	req, _ = http.NewRequest("GET", "/api/internal/stats", nil)
+	req.Header.Set("Authorization", "Bearer "+getValidToken(serv))
+	w = httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	snapshot := selfStatsSnapshot{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &snapshot))
+	assert.Equal(t, uint64(1), snapshot.NumReports)
+	assert.Equal(t, uint64(1), snapshot.NumMetricsSaved)
+	assert.Equal(t, int64(1), snapshot.TableRows["metrics"])
+	assert.Equal(t, int64(1), snapshot.TableRows["metrics_values"])
+	assert.Greater(t, snapshot.Storage.NumOperations, uint64(0))
+	assert.Equal(t, int64(500), snapshot.Storage.SlowQueryThresholdInMs)
+
+	// service key, prometheus format
+	req, _ = http.NewRequest("GET", "/api/internal/stats?format=prometheus", nil)
+	req.Header.Set("X-Api-Key", "test-secret")
+	w = httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, prometheusContentType, w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "# TYPE aggregation_reports_total counter\naggregation_reports_total 1\n")
+	assert.Contains(t, w.Body.String(), `aggregation_table_rows{table="metrics_values"} 1`)
+	assert.Contains(t, w.Body.String(), "# TYPE aggregation_storage_slow_operations_total counter\naggregation_storage_slow_operations_total ")
+	assert.Contains(t, w.Body.String(), "go_goroutines ")
+}
@@ -1,28 +1,50 @@
 package api
 
 import (
+	"bytes"
 	"context"
 	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"net"
 	"net/http"
-	"path"
+	"net/url"
+	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/iulianpascalau/api-monitoring/commonGo"
+	"github.com/iulianpascalau/api-monitoring/commonGo/clock"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/history"
 	"github.com/multiversx/mx-chain-core-go/core/check"
 	logger "github.com/multiversx/mx-chain-logger-go"
 )
 
 var log = logger.GetOrCreate("api")
 
+// checksumHeader optionally carries the hex encoded SHA-256 of the report body, computed by the agent
+const checksumHeader = "X-Content-Sha256"
+
+// checksumMismatchCode is the error code returned when the report body does not match the provided checksum
+const checksumMismatchCode = "checksum_mismatch"
+
+// defaultRateWindow is the window of the rate endpoint when the request does not provide one
+const defaultRateWindow = 5 * time.Minute
+
+// sessionUserKey holds, in the gin context, the user the session token was issued to
+const sessionUserKey = "sessionUser"
+
 type server struct {
 	router                    *gin.Engine
 	httpServer                *http.Server
@@ -31,34 +53,109 @@ type server struct {
 	username                  string
 	password                  string
 	listenAddr                string
-	staticDir                 string
+	staticFS                  fs.FS
 	jwtSecret                 []byte
+	shareSecret               []byte
 	generalHandler            func(http.Handler) http.Handler
 	wg                        sync.WaitGroup
 	numSecondsToConsiderStale int
 	appVersion                string
+	mutDrain                  sync.RWMutex
+	draining                  bool
+	inFlightIngests           sync.WaitGroup
+	drainTimeout              time.Duration
+	statusPageEnabled         bool
+	statusPageTitle           string
+	statusPageCacheMaxAge     time.Duration
+	mutStatus                 sync.Mutex
+	status                    *cachedStatus
+	stats                     *selfStats
+	profilingEnabled          bool
+	writeQueue                *writeQueue
+	sink                      MetricsSink
+	tenantsByKey              map[string]string
+	tenantsByUser             map[string]Tenant
+	apiKeys                   map[string]APIKey
+	webhookSources            map[string]WebhookSource
+	oidcProvider              OIDCProvider
+	oidcPostLoginURL          string
+	mutTOTP                   sync.Mutex
+	basePath                  string
+	trustedProxies            []*net.IPNet
+	mutAgents                 sync.Mutex
+	knownAgents               map[string]common.AgentInfo
+	maxAgentLogEntries        int
+	reportDeduplicator        *reportDeduplicator
+	maxClockSkew              int64
+	clock                     Clock
 }
 
-// MetricReportPayload represents the incoming JSON body on /api/report
+// MetricReportPayload represents the incoming JSON (or MessagePack) body on /api/report
 type MetricReportPayload struct {
-	Metrics map[string]struct {
-		Value          string `json:"value"`
-		Type           string `json:"type"`
-		NumAggregation int    `json:"numAggregation"`
-	} `json:"metrics"`
+	Metrics map[string]ReportedMetric `json:"metrics"`
+	Agent   *ReportedAgent            `json:"agent,omitempty"`
+	// CycleID identifies the polling cycle of the agent, the reports carrying an already accepted one are dropped
+	CycleID string `json:"cycleId,omitempty"`
+	// SentAt is the agent unix timestamp of the report, used to measure the agent clock skew
+	SentAt int64 `json:"sentAt,omitempty"`
+}
+
+// ReportedAgent represents the build and configuration details of the agent sending the report
+type ReportedAgent struct {
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	Commit     string `json:"commit"`
+	ConfigHash string `json:"configHash"`
+}
+
+// ReportedMetric represents a single metric value contained in the report payload
+type ReportedMetric struct {
+	Value          string            `json:"value"`
+	Type           string            `json:"type"`
+	NumAggregation int               `json:"numAggregation"`
+	Tags           map[string]string `json:"tags,omitempty"`
+	// Interval is the number of seconds between two values of the metric, 0 if unknown
+	Interval int `json:"interval,omitempty"`
+	// RecordedAt is the agent unix timestamp of the poll, 0 stamps the value on reception
+	RecordedAt int64 `json:"recordedAt,omitempty"`
+	// Stale is set when the value is the last good one, re-sent by the agent after a failed poll
+	Stale bool `json:"stale,omitempty"`
 }
 
 // ArgsWebServer defines the web server arguments
 type ArgsWebServer struct {
-	ServiceKeyApi             string
-	AuthUsername              string
-	AuthPassword              string
-	ListenAddress             string
-	StaticDir                 string
-	Storage                   Storage
-	GeneralHandler            func(http.Handler) http.Handler
-	NumSecondsToConsiderStale int
-	AppVersion                string
+	ServiceKeyApi              string
+	AuthUsername               string
+	AuthPassword               string
+	ListenAddress              string
+	StaticDir                  string
+	StaticFS                   fs.FS
+	Storage                    Storage
+	GeneralHandler             func(http.Handler) http.Handler
+	NumSecondsToConsiderStale  int
+	AppVersion                 string
+	StatusPageEnabled          bool
+	StatusPageTitle            string
+	StatusPageCacheMaxAgeInSec int
+	ProfilingEnabled           bool
+	WriteQueueEnabled          bool
+	WriteQueueCapacity         int
+	WriteQueueBatchSize        int
+	WriteQueueFlushIntervalMs  int
+	Sink                       MetricsSink
+	Tenants                    []Tenant
+	APIKeys                    []APIKey
+	WebhookSources             []WebhookSource
+	OIDCProvider               OIDCProvider
+	OIDCPostLoginURL           string
+	BasePath                   string
+	TrustedProxies             []string
+	// MaxAgentLogEntries is the number of log entries kept per agent, defaults to 100
+	MaxAgentLogEntries int
+	// MaxClockSkewInSeconds is the tolerated difference between the agent clocks and the server clock, defaults to 30
+	MaxClockSkewInSeconds int
+	// Clock checks the expiry of the sessions, OIDC states and share tokens, nil uses the system clock
+	Clock Clock
 }
 
 // NewServer initializes the Gin engine and mounts all routes
@@ -83,7 +180,13 @@ func NewServer(args ArgsWebServer) (*server, error) {
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.New()
 
-	router.Use(gin.Recovery())
+	// the request logger wraps the recovery middleware, so the panics are logged as server errors
+	router.Use(requestLogger(), gin.Recovery())
+	// the X-Forwarded-For header is only honored for the requests coming through the trusted reverse proxies
+	err := router.SetTrustedProxies(args.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
 
 	s := &server{
 		router:                    router,
@@ -92,11 +195,78 @@ func NewServer(args ArgsWebServer) (*server, error) {
 		username:                  args.AuthUsername,
 		password:                  args.AuthPassword,
 		listenAddr:                args.ListenAddress,
-		staticDir:                 args.StaticDir,
+		staticFS:                  args.StaticFS,
 		generalHandler:            args.GeneralHandler,
 		jwtSecret:                 jwtSecret,
+		shareSecret:               deriveShareSecret(args.ServiceKeyApi),
 		numSecondsToConsiderStale: args.NumSecondsToConsiderStale,
 		appVersion:                args.AppVersion,
+		drainTimeout:              drainTimeout,
+		stats:                     newSelfStats(),
+		statusPageEnabled:         args.StatusPageEnabled,
+		statusPageTitle:           args.StatusPageTitle,
+		statusPageCacheMaxAge:     time.Duration(args.StatusPageCacheMaxAgeInSec) * time.Second,
+		profilingEnabled:          args.ProfilingEnabled,
+		basePath:                  strings.TrimSuffix(args.BasePath, "/"),
+		knownAgents:               make(map[string]common.AgentInfo),
+		maxAgentLogEntries:        args.MaxAgentLogEntries,
+		reportDeduplicator:        newReportDeduplicator(duplicateReportsWindow, maxTrackedReports),
+		maxClockSkew:              int64(args.MaxClockSkewInSeconds),
+		clock:                     args.Clock,
+	}
+	if check.IfNil(s.clock) {
+		s.clock = clock.NewSystemClock()
+	}
+	if s.maxClockSkew <= 0 {
+		s.maxClockSkew = defaultMaxClockSkewInSeconds
+	}
+	if s.maxAgentLogEntries <= 0 {
+		s.maxAgentLogEntries = defaultMaxAgentLogEntries
+	}
+	if len(args.StaticDir) > 0 {
+		s.staticFS = os.DirFS(args.StaticDir)
+	}
+	s.trustedProxies, err = parseTrustedProxies(args.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+	err = s.setTenants(args.Tenants)
+	if err != nil {
+		return nil, err
+	}
+	err = s.setAPIKeys(args.APIKeys)
+	if err != nil {
+		return nil, err
+	}
+	err = s.setWebhookSources(args.WebhookSources)
+	if err != nil {
+		return nil, err
+	}
+	if !check.IfNil(args.Sink) {
+		s.sink = args.Sink
+	}
+	if !check.IfNil(args.OIDCProvider) {
+		s.oidcProvider = args.OIDCProvider
+		s.oidcPostLoginURL = args.OIDCPostLoginURL
+	}
+	if len(s.oidcPostLoginURL) == 0 {
+		s.oidcPostLoginURL = s.basePath + defaultOIDCPostLoginURL
+	}
+	if len(s.statusPageTitle) == 0 {
+		s.statusPageTitle = defaultStatusPageTitle
+	}
+	if s.statusPageCacheMaxAge <= 0 {
+		s.statusPageCacheMaxAge = defaultStatusPageCacheMaxAge
+	}
+	if args.WriteQueueEnabled {
+		s.writeQueue = newWriteQueue(
+			args.Storage,
+			s.stats,
+			args.WriteQueueCapacity,
+			args.WriteQueueBatchSize,
+			time.Duration(args.WriteQueueFlushIntervalMs)*time.Millisecond,
+		)
+		s.writeQueue.start()
 	}
 
 	s.setupRoutes()
@@ -104,56 +274,163 @@ func NewServer(args ArgsWebServer) (*server, error) {
 }
 
 func (s *server) setupRoutes() {
-	api := s.router.Group("/api")
+	// Readiness probe for the load balancers
+	s.router.GET("/healthz", s.handleHealthz)
+
+	// Public status page, for embedding in the external uptime pages
+	if s.statusPageEnabled {
+		s.router.GET("/status", s.handleStatus)
+	}
+
+	// The versioned API and its unversioned aliases, kept for the deployed agents and frontends
+	s.setupAPIRoutes(s.router.Group(apiV1Path))
+	legacy := s.router.Group(apiLegacyPath)
+	legacy.Use(s.deprecatedAPI())
+	s.setupAPIRoutes(legacy)
+
+	// Serve the frontend build, if available
+	s.setupStaticRoutes()
+}
 
+// setupAPIRoutes registers the API endpoints under the provided group
+func (s *server) setupAPIRoutes(api *gin.RouterGroup) {
 	// Agent reporting endpoint
-	api.POST("/report", s.authAPIKey(), s.handleReport)
+	api.POST("/report", s.authAPIKey(), s.trackIngest(), s.verifyChecksum(), s.handleReport)
+	api.POST("/agents/:name/logs", s.authAPIKey(), s.handleAgentLogs)
+	// Push-style sources (third-party webhooks), authenticated by the source token
+	api.POST("/ingest/:source", s.trackIngest(), s.handleIngestWebhook)
 
 	// Public app info
 	api.GET("/app-info", s.handleAppInfo)
 
+	// Operational metrics of the service itself, for the scrapers (X-Api-Key) or the frontend users
+	api.GET("/internal/stats", s.authAPIKeyOrJWT(), s.requireDefaultTenant(), s.handleInternalStats)
+
 	// Frontend authentication
 	api.POST("/auth/login", s.handleLogin)
+	if s.oidcProvider != nil {
+		api.GET("/auth/oidc/login", s.handleOIDCLogin)
+		api.GET("/auth/oidc/callback", s.handleOIDCCallback)
+	}
+
+	// Read-only endpoints for the shared links
+	public := api.Group("/public")
+	public.Use(s.authShareToken())
+	{
+		public.GET("/metrics", s.handleGetMetrics)
+		public.GET("/metrics/full", s.handleGetMetricsFull)
+		public.GET("/panels", s.handleGetPanels)
+		public.GET("/metrics/:name/history", s.handleGetMetricHistory)
+		public.GET("/config/general", s.handleGetGeneralConfig)
+	}
 
-	// Protected frontend endpoints
+	// Protected frontend endpoints. The instance-wide ones are reserved to the users of the default tenant.
 	protected := api.Group("/")
 	protected.Use(s.authJWT())
+	defaultTenant := s.requireDefaultTenant()
+	session := s.requireSession()
 	{
+		protected.POST(logoutPath, session, s.handleLogout)
+		protected.POST(logoutAllPath, session, s.handleLogoutAll)
+		protected.GET("/sessions", defaultTenant, s.requireAdmin(), s.handleGetSessions)
+		protected.DELETE("/sessions/:id", defaultTenant, s.requireAdmin(), s.handleRevokeSession)
+
+		protected.GET("/auth/totp", session, s.requireLocalAccount(), s.handleGetTOTP)
+		protected.POST("/auth/totp/enroll", session, s.requireLocalAccount(), s.handleEnrollTOTP)
+		protected.POST("/auth/totp/activate", session, s.requireLocalAccount(), s.handleActivateTOTP)
+		protected.POST("/auth/totp/disable", session, s.requireLocalAccount(), s.handleDisableTOTP)
+
 		protected.GET("/metrics", s.handleGetMetrics)
+		protected.GET("/metrics/full", s.handleGetMetricsFull)
 		protected.GET("/metrics/:name/history", s.handleGetMetricHistory)
+		protected.GET("/metrics/:name/rate", s.handleGetMetricRate)
+		protected.GET("/metrics/:name/stats", s.handleGetMetricStats)
+		protected.GET("/export", s.handleExport)
 		protected.DELETE("/metrics/:name", s.handleDeleteMetric)
+		protected.POST("/metrics/:name/restore", s.handleRestoreMetric)
+		protected.GET("/metrics/trash", s.handleGetTrashedMetrics)
+		protected.DELETE("/metrics", defaultTenant, s.handleDeleteMetricsByPrefix)
+		protected.POST("/metrics/:name/rename", s.handleRenameMetric)
+		protected.GET("/metrics/:name/annotations", s.handleGetMetricAnnotations)
+		protected.POST("/metrics/:name/annotations", s.handleAddMetricAnnotation)
+		protected.DELETE("/metrics/:name/annotations/:id", s.handleDeleteMetricAnnotation)
 
 		protected.GET("/config/general", s.handleGetGeneralConfig)
 		protected.GET("/config/panels", s.handleGetPanelsConfigs)
+		protected.GET("/panels", s.handleGetPanels)
 		protected.POST("/config/panels", s.handleUpdatePanelOrder)
+		protected.PUT("/config/panels/:panel/metrics", defaultTenant, s.handleSetPanelMetrics)
 		protected.POST("/config/metrics/order", s.handleUpdateMetricOrder)
 		protected.POST("/config/metrics/alarm", s.handleUpdateMetricAlarm)
+		protected.POST("/config/metrics/gaps", s.handleUpdateMetricGapMode)
+		protected.POST("/config/metrics/aggregation", s.handleUpdateMetricAggregationMode)
+		protected.POST("/config/metrics/store-on-change", s.handleUpdateMetricStoreOnChange)
+		protected.POST("/config/metrics/compression", s.handleUpdateMetricCompression)
+		protected.PUT("/config/metrics/:name/thresholds", s.handleSetMetricThresholds)
+		protected.DELETE("/config/metrics/:name/thresholds", s.handleDeleteMetricThresholds)
+
+		protected.GET("/dashboards", defaultTenant, s.handleGetDashboards)
+		protected.POST("/dashboards", defaultTenant, s.handleCreateDashboard)
+		protected.GET("/dashboards/:id", defaultTenant, s.handleGetDashboard)
+		protected.PUT("/dashboards/:id", defaultTenant, s.handleUpdateDashboard)
+		protected.DELETE("/dashboards/:id", defaultTenant, s.handleDeleteDashboard)
+
+		protected.GET("/alerts", s.handleGetAlerts)
+		protected.GET("/alerts/:id", s.handleGetAlert)
+		protected.POST("/alerts/:id/acknowledge", s.handleAcknowledgeAlert)
+		protected.POST("/alerts/:id/silence", s.handleSilenceAlert)
+		protected.GET("/silences", defaultTenant, s.handleGetSilences)
+		protected.POST("/silences", defaultTenant, s.handleCreateSilence)
+		protected.DELETE("/silences/:id", defaultTenant, s.handleExpireSilence)
+		protected.GET("/sla", s.handleGetSLA)
+		protected.GET("/agents", s.handleGetAgents)
+		protected.GET("/agents/:name/logs", s.handleGetAgentLogs)
+
+		protected.POST("/share", defaultTenant, s.handleCreateShareToken)
+
+		// the administration endpoints are reserved to the admins, the viewers and the read keys can not reach them
+		protected.POST("/admin/drain", defaultTenant, s.requireAdmin(), s.handleDrain)
+		protected.GET("/admin/schema", defaultTenant, s.requireAdmin(), s.handleGetSchema)
+		protected.POST("/admin/backup", defaultTenant, s.requireAdmin(), s.handleBackup)
+		protected.GET("/admin/api-keys", defaultTenant, s.requireAdmin(), s.handleGetAPIKeys)
+
+		if s.profilingEnabled {
+			protected.GET("/admin/debug/pprof/*profile", defaultTenant, s.requireAdmin(), s.handleProfiling)
+			protected.POST("/admin/debug/pprof/*profile", defaultTenant, s.requireAdmin(), s.handleProfiling)
+		}
 	}
+}
 
-	// Serve static files from the frontend build if configured
-	if s.staticDir != "" {
-		log.Info("serving static files", "dir", s.staticDir)
-		s.router.Static("/_expo", path.Join(s.staticDir, "_expo"))
-		s.router.Static("/assets", path.Join(s.staticDir, "assets"))
-		s.router.StaticFile("/favicon.ico", path.Join(s.staticDir, "favicon.ico"))
+// httpHandler returns the router, accepting the requests with or without the base path prefix so that the reverse
+// proxies can forward the path as is or strip the prefix
+func (s *server) httpHandler() http.Handler {
+	if len(s.basePath) == 0 {
+		return s.router
+	}
 
-		// NoRoute for SPA fallback
-		s.router.NoRoute(func(c *gin.Context) {
-			// If request is for an /api route that doesn't exist, return 404
-			if strings.HasPrefix(c.Request.URL.Path, "/api") {
-				c.JSON(http.StatusNotFound, gin.H{"error": "api route not found"})
-				return
-			}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != s.basePath && !strings.HasPrefix(r.URL.Path, s.basePath+"/") {
+			s.router.ServeHTTP(w, r)
+			return
+		}
 
-			// Fallback to index.html for all client-side routes
-			c.File(path.Join(s.staticDir, "index.html"))
-		})
-	}
+		stripped := new(url.URL)
+		*stripped = *r.URL
+		stripped.Path = strings.TrimPrefix(r.URL.Path, s.basePath)
+		stripped.RawPath = strings.TrimPrefix(r.URL.RawPath, s.basePath)
+		if len(stripped.Path) == 0 {
+			stripped.Path = "/"
+		}
+
+		request := r.Clone(r.Context())
+		request.URL = stripped
+		s.router.ServeHTTP(w, request)
+	})
 }
 
 // Start listens and serves connections
 func (s *server) Start() {
-	handler := s.generalHandler(s.router)
+	handler := s.generalHandler(s.httpHandler())
 
 	s.httpServer = &http.Server{
 		Addr:    s.listenAddr,
@@ -184,38 +461,106 @@ func (s *server) Address() string {
 	return s.listenAddr
 }
 
-// Close gracefully stops the server
+// Close gracefully stops the server. The buffered values are persisted and the storage is closed even if the HTTP
+// server did not stop in time, the shutdown error being returned afterwards.
 func (s *server) Close() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	var errShutdown error
 	if s.httpServer != nil {
-		if err := s.httpServer.Shutdown(ctx); err != nil {
-			return err
-		}
+		errShutdown = s.httpServer.Shutdown(ctx)
 	}
 	s.wg.Wait()
-	return s.storage.Close()
+	if s.writeQueue != nil {
+		s.writeQueue.close()
+	}
+	errClose := s.storage.Close()
+
+	return errors.Join(errShutdown, errClose)
 }
 
 // --- Middlewares ---
 
+// authAPIKey accepts the service API key, the tenant API keys and the API keys with the report or admin scope
 func (s *server) authAPIKey() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		key := c.GetHeader("X-Api-Key")
-		if key != s.serviceKey {
+		tenant, found := s.TenantOfReportKey(c.GetHeader("X-Api-Key"))
+		if !found {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 			c.Abort()
 			return
 		}
+		c.Set(sessionTenantKey, tenant)
+		c.Next()
+	}
+}
+
+// verifyChecksum rejects the report bodies that do not match the checksum provided by the agent (if any).
+// The mismatch is signaled with 422 so the agents can tell it apart from the malformed payloads.
+func (s *server) verifyChecksum() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		expectedChecksum := c.GetHeader(checksumHeader)
+		if expectedChecksum == "" {
+			c.Next()
+			return
+		}
+
+		expected, err := hex.DecodeString(expectedChecksum)
+		if err != nil || len(expected) != sha256.Size {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid checksum header"})
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read payload"})
+			c.Abort()
+			return
+		}
+
+		actual := sha256.Sum256(body)
+		if !hmac.Equal(actual[:], expected) {
+			log.Warn("report checksum mismatch", "sender", c.ClientIP(), "body length", len(body))
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "checksum mismatch", "code": checksumMismatchCode})
+			c.Abort()
+			return
+		}
+
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
 		c.Next()
 	}
 }
 
-// VERY basic JWT implementation for frontend session based on HS256
+// authAPIKeyOrJWT accepts either the service API key, if the X-Api-Key header is present, or a frontend session token.
+// The read and admin API keys are accepted as session tokens.
+func (s *server) authAPIKeyOrJWT() gin.HandlerFunc {
+	apiKeyHandler := s.authAPIKey()
+	jwtHandler := s.authJWT()
+
+	return func(c *gin.Context) {
+		key := c.GetHeader("X-Api-Key")
+		_, isScopedKey := s.apiKeys[key]
+		if len(key) > 0 && !isScopedKey {
+			apiKeyHandler(c)
+			return
+		}
+
+		jwtHandler(c)
+	}
+}
+
+// VERY basic JWT implementation for frontend session based on HS256. The API keys with the read or admin scope, sent
+// in the X-Api-Key header, are accepted instead of the session token.
 func (s *server) authJWT() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
+		apiKey := c.GetHeader("X-Api-Key")
+		if !strings.HasPrefix(authHeader, "Bearer ") && len(apiKey) > 0 {
+			s.authScopedAPIKey(c, apiKey)
+			return
+		}
 		if !strings.HasPrefix(authHeader, "Bearer ") {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "missing token"})
 			c.Abort()
@@ -250,20 +595,34 @@ func (s *server) authJWT() gin.HandlerFunc {
 		}
 
 		// Verify expiration
-		var claims struct {
-			Exp int64 `json:"exp"`
-		}
+		var claims sessionClaims
 		payloadBytes, err := base64.RawURLEncoding.DecodeString(parts[1])
 		if err == nil {
 			_ = json.Unmarshal(payloadBytes, &claims)
 		}
 
-		if time.Now().Unix() > claims.Exp {
+		if s.clock.Now().Unix() > claims.Exp {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "token expired"})
 			c.Abort()
 			return
 		}
 
+		if !s.isSessionActive(c, claims.Sid) {
+			return
+		}
+
+		// the viewers can only read, and log out
+		if claims.Role == common.RoleViewer && c.Request.Method != http.MethodGet && !isLogoutPath(c.FullPath()) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "read-only user"})
+			c.Abort()
+			return
+		}
+
+		c.Set(sessionUserKey, claims.Sub)
+		c.Set(sessionTenantKey, claims.Tenant)
+		c.Set(sessionOIDCKey, claims.OIDC)
+		c.Set(sessionIDKey, claims.Sid)
+		c.Set(sessionRoleKey, claims.Role)
 		c.Next()
 	}
 }
@@ -272,180 +631,1135 @@ func (s *server) authJWT() gin.HandlerFunc {
 
 func (s *server) handleReport(c *gin.Context) {
 	var payload MetricReportPayload
-	if err := c.ShouldBindJSON(&payload); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+	if err := bindPayload(c, &payload); err != nil {
+		respondNegotiated(c, http.StatusBadRequest, gin.H{"error": "invalid payload"})
 		return
 	}
 
-	recordedAt := time.Now().Unix()
-	ctx := c.Request.Context()
-
 	log.Debug("received report", "sender", c.ClientIP(), "num metrics", len(payload.Metrics))
 
-	// In real-world, we could parallelize or bulk this, but for SQLite WAL, serial Tx is fine.
-	for name, m := range payload.Metrics {
-		err := s.storage.SaveMetric(ctx, name, m.Type, m.NumAggregation, m.Value, recordedAt)
-		if err != nil {
-			log.Warn("failed to save metric", "name", name, "error", err)
-			// Continue with others
+	err := s.ingestReport(c.Request.Context(), c.GetString(sessionTenantKey), payload)
+	if errors.Is(err, errInvalidCycleID) {
+		respondNegotiated(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, errDuplicateReport) {
+		respondNegotiated(c, http.StatusOK, gin.H{"ok": true, "duplicate": true})
+		return
+	}
+	if err != nil {
+		log.Warn("report rejected", "sender", c.ClientIP(), "num metrics", len(payload.Metrics), "error", err)
+		c.Header("Retry-After", "1")
+		respondNegotiated(c, http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+
+	respondNegotiated(c, http.StatusOK, gin.H{"ok": true})
+}
+
+// IngestReport persists a report received on another transport than HTTP (e.g. MQTT) for a tenant, resolved from the
+// report key with TenantOfReportKey. The reports are rejected while the server is draining.
+func (s *server) IngestReport(ctx context.Context, tenant string, payload MetricReportPayload) error {
+	s.mutDrain.RLock()
+	if s.draining {
+		s.mutDrain.RUnlock()
+		return errServerDraining
+	}
+	s.inFlightIngests.Add(1)
+	s.mutDrain.RUnlock()
+	defer s.inFlightIngests.Done()
+
+	err := s.ingestReport(ctx, tenant, payload)
+	if errors.Is(err, errDuplicateReport) {
+		return nil
+	}
+
+	return err
+}
+
+// ingestReport saves the reported values of a tenant, synchronously or through the write queue, and forwards them
+// to the sink. The metrics of a tenant are stored prefixed by the tenant name, the default tenant metrics in the
+// namespace of a tenant being dropped. The stale values are not saved, so the history keeps the last good value at
+// the time of its poll instead of repeating it during the outage. A report carrying the cycle ID of an already
+// accepted one is dropped with errDuplicateReport.
+func (s *server) ingestReport(ctx context.Context, tenant string, payload MetricReportPayload) error {
+	now := time.Now()
+	if len(payload.CycleID) > 0 {
+		if !commonGo.IsValidRequestID(payload.CycleID) {
+			return fmt.Errorf("%w %q", errInvalidCycleID, payload.CycleID)
+		}
+		if !s.reportDeduplicator.accept(tenant, payload.CycleID, now) {
+			log.Debug("dropped a duplicate report", "cycle ID", payload.CycleID, "num metrics", len(payload.Metrics))
+			s.stats.recordDuplicateReport()
+			return errDuplicateReport
 		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{"ok": true})
+	receivedAt := now.Unix()
+	s.stats.recordReport(now)
+	if payload.Agent != nil {
+		s.saveAgentInfo(ctx, tenant, *payload.Agent, receivedAt)
+	}
+	skew := s.clockSkew(payload, receivedAt)
+
+	records := make([]common.MetricRecord, 0, len(payload.Metrics))
+	numStale := 0
+	for reportedName, m := range payload.Metrics {
+		if m.Stale {
+			numStale++
+			continue
+		}
+
+		name := common.TenantMetricName(tenant, reportedName)
+		if s.metricNamespace(name) != tenant {
+			log.Warn("dropped a metric from the namespace of a tenant", "metric", name)
+			continue
+		}
+
+		records = append(records, common.MetricRecord{
+			Name:             name,
+			Type:             m.Type,
+			NumAggregation:   m.NumAggregation,
+			Value:            m.Value,
+			Tags:             common.MergeTags(name, m.Tags),
+			RecordedAt:       correctedTimestamp(m.RecordedAt, skew, receivedAt),
+			ReceivedAt:       receivedAt,
+			Tenant:           tenant,
+			ExpectedInterval: max(m.Interval, 0),
+		})
+	}
+	if numStale > 0 {
+		log.Debug("skipped the stale values of a report", "num stale", numStale)
+	}
+
+	if s.writeQueue == nil {
+		saveRecords(ctx, s.storage, s.stats, records)
+		s.forwardToSink(records)
+		return nil
+	}
+
+	err := s.writeQueue.enqueue(records)
+	if err != nil {
+		s.stats.recordQueueRejected()
+		// the rejected report is not saved, its retry must be accepted
+		if len(payload.CycleID) > 0 {
+			s.reportDeduplicator.forget(tenant, payload.CycleID)
+		}
+		return err
+	}
+
+	s.forwardToSink(records)
+	return nil
+}
+
+// forwardToSink hands over the accepted records to the secondary sink, if one is configured
+func (s *server) forwardToSink(records []common.MetricRecord) {
+	if s.sink == nil {
+		return
+	}
+
+	s.sink.Forward(records)
 }
 
 func (s *server) handleLogin(c *gin.Context) {
 	var req struct {
 		Username string `json:"username"`
 		Password string `json:"password"`
+		// OTP is the authenticator app code, or a recovery code, of the accounts with two-factor authentication
+		OTP string `json:"otp"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
 		return
 	}
 
-	if req.Username != s.username || req.Password != s.password {
+	tenant, ok := s.authenticate(req.Username, req.Password)
+	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
 		return
 	}
+	if !s.checkSecondFactor(c, req.Username, req.OTP) {
+		return
+	}
+
+	token, err := s.startSession(c, sessionClaims{Sub: req.Username, Tenant: tenant, Role: common.RoleAdmin})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"token": token, "tenant": tenant})
+}
+
+// sessionClaims are the claims of the frontend session tokens
+type sessionClaims struct {
+	// Sid is the ID of the session the token belongs to
+	Sid    string `json:"sid"`
+	Sub    string `json:"sub"`
+	Tenant string `json:"tenant"`
+	Role   string `json:"role"`
+	// OIDC marks the users authenticated by the identity provider, as opposed to the local accounts
+	OIDC bool  `json:"oidc,omitempty"`
+	Exp  int64 `json:"exp"`
+}
+
+// issueSessionToken generates a basic JWT (Header.Payload.Signature) carrying the session claims
+func (s *server) issueSessionToken(claims sessionClaims) string {
+	claimsBytes, _ := json.Marshal(claims)
 
-	// Generate basic JWT (Header.Payload.Signature)
 	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
-	claims := fmt.Sprintf(`{"sub":"%s","exp":%d}`, req.Username, time.Now().Add(24*time.Hour).Unix())
-	payload := base64.RawURLEncoding.EncodeToString([]byte(claims))
+	payload := base64.RawURLEncoding.EncodeToString(claimsBytes)
 
 	msg := header + "." + payload
 	macd := hmac.New(sha256.New, s.jwtSecret)
 	macd.Write([]byte(msg))
 	sig := base64.RawURLEncoding.EncodeToString(macd.Sum(nil))
 
-	token := msg + "." + sig
-	c.JSON(http.StatusOK, gin.H{"token": token})
+	return msg + "." + sig
+}
+
+// latestMetric is the latest value of a metric, formatted to match specs.md
+type latestMetric struct {
+	Name           string            `json:"name"`
+	Value          string            `json:"value"`
+	Type           string            `json:"type"`
+	NumAggregation int               `json:"numAggregation"`
+	DisplayOrder   int               `json:"displayOrder"`
+	IsAlarmEnabled bool              `json:"isAlarmEnabled"`
+	GapMode        common.GapMode    `json:"gapMode"`
+	StoreOnChange  bool              `json:"storeOnChange"` // the identical consecutive values are not stored again
+	Compressed     bool              `json:"compressed"`    // the older values are stored in delta encoded chunks
+	Tags           map[string]string `json:"tags,omitempty"`
+	RecordedAt     int64             `json:"recordedAt"`
+	// AggregatedValue is computed over the stored values according to the AggregationMode, empty for last
+	AggregationMode common.AggregationMode `json:"aggregationMode"`
+	AggregatedValue string                 `json:"aggregatedValue,omitempty"`
+	// ExpectedInterval is the number of seconds between two values, as reported by the agent, 0 if unknown
+	ExpectedInterval int `json:"expectedInterval"`
+	// Status is the displayed value (the aggregated value, if any) evaluated against the Thresholds, empty without them
+	Thresholds *common.MetricThresholds `json:"thresholds,omitempty"`
+	Status     common.MetricStatus      `json:"status,omitempty"`
+	// Stale is set when the heartbeat of the agent reporting the metric is stale (agent down), as opposed to
+	// an old value reported by a live agent (e.g. node down)
+	Stale bool `json:"stale"`
 }
 
 func (s *server) handleGetMetrics(c *gin.Context) {
-	results, err := s.storage.GetLatestMetrics(c.Request.Context())
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	metrics, latestRecordedAt, _, ok := s.latestMetrics(c)
+	if !ok {
 		return
 	}
 
-	// Format to match specs.md exactly
-	type responseMetric struct {
-		Name           string `json:"name"`
-		Value          string `json:"value"`
-		Type           string `json:"type"`
-		NumAggregation int    `json:"numAggregation"`
-		DisplayOrder   int    `json:"displayOrder"`
-		IsAlarmEnabled bool   `json:"isAlarmEnabled"`
-		RecordedAt     int64  `json:"recordedAt"`
+	writeJSONWithETag(c, latestRecordedAt, gin.H{"metrics": metrics})
+}
+
+// latestMetrics returns the latest values of the metrics matching the request filters, their most recent timestamp
+// and the requested dashboard, if any. The error response is written when returning false.
+func (s *server) latestMetrics(c *gin.Context) ([]latestMetric, int64, *common.Dashboard, bool) {
+	filters, err := parseTagFilters(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, 0, nil, false
 	}
 
-	out := make([]responseMetric, 0, len(results))
+	metricsFilter, err := parseMetricsFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, 0, nil, false
+	}
+	metricsFilter.Tenant = c.GetString(sessionTenantKey)
+
+	dashboard, status, err := s.requestedDashboard(c)
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return nil, 0, nil, false
+	}
+
+	results, err := s.storage.GetLatestMetricsFiltered(c.Request.Context(), metricsFilter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, 0, nil, false
+	}
+	heartbeats, err := s.collectHeartbeats(c.Request.Context(), metricsFilter, results)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, 0, nil, false
+	}
+	now := time.Now().Unix()
+	staleSeconds := s.staleSeconds()
+
+	out := make([]latestMetric, 0, len(results))
+	latestRecordedAt := int64(0)
 	for _, r := range results {
+		tags := r.Tags
+		if tags == nil {
+			// metrics not reported by agents (e.g. computed metrics) fall back on the dotted name convention
+			tags = common.ParseTagsFromName(r.Name)
+		}
+		if !common.MatchesTags(tags, filters) {
+			continue
+		}
+		if dashboard != nil && !dashboard.Contains(r.Name, tags[common.TagVM]) {
+			continue
+		}
+
 		if len(r.History) > 0 {
-			out = append(out, responseMetric{
-				Name:           r.Name,
-				Value:          r.History[0].Value,
-				Type:           r.Type,
-				NumAggregation: r.NumAggregation,
-				DisplayOrder:   r.DisplayOrder,
-				IsAlarmEnabled: r.IsAlarmEnabled,
-				RecordedAt:     r.History[0].RecordedAt,
+			status := common.MetricStatus("")
+			if r.Thresholds != nil {
+				status = r.Thresholds.Evaluate(displayedValue(r))
+			}
+			out = append(out, latestMetric{
+				Name:             r.Name,
+				Value:            r.History[0].Value,
+				Type:             r.Type,
+				NumAggregation:   r.NumAggregation,
+				DisplayOrder:     r.DisplayOrder,
+				IsAlarmEnabled:   r.IsAlarmEnabled,
+				GapMode:          r.GapMode,
+				Tags:             tags,
+				RecordedAt:       r.History[0].RecordedAt,
+				AggregationMode:  r.AggregationMode,
+				AggregatedValue:  r.AggregatedValue,
+				StoreOnChange:    r.StoreOnChange,
+				Compressed:       r.Compressed,
+				ExpectedInterval: r.ExpectedInterval,
+				Stale:            heartbeats.IsAgentDown(r.Name, now, staleSeconds),
+				Thresholds:       r.Thresholds,
+				Status:           status,
 			})
+			latestRecordedAt = max(latestRecordedAt, r.History[0].RecordedAt)
 		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{"metrics": out})
+	return out, latestRecordedAt, dashboard, true
 }
 
-func (s *server) handleGetMetricHistory(c *gin.Context) {
-	name := c.Param("name")
-	hist, err := s.storage.GetMetricHistory(c.Request.Context(), name)
+// handleGetMetricsFull returns every metric matching the filters of /metrics together with all its retained values,
+// so the dashboards rendering many charts do not request the history of each metric
+func (s *server) handleGetMetricsFull(c *gin.Context) {
+	filters, err := parseTagFilters(c)
 	if err != nil {
-		if err.Error() == "metric not found" {
-			c.JSON(http.StatusNotFound, gin.H{"error": "metric not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, hist)
-}
-
-func (s *server) handleDeleteMetric(c *gin.Context) {
-	name := c.Param("name")
-	err := s.storage.DeleteMetric(c.Request.Context(), name)
+	metricsFilter, err := parseMetricsFilter(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	metricsFilter.Tenant = c.GetString(sessionTenantKey)
 
-	c.JSON(http.StatusOK, gin.H{"ok": true})
-}
-
-func (s *server) handleGetPanelsConfigs(c *gin.Context) {
-	configs, err := s.storage.GetPanelsConfigs(c.Request.Context())
+	dashboard, status, err := s.requestedDashboard(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, configs)
-}
 
-func (s *server) handleUpdatePanelOrder(c *gin.Context) {
-	var req struct {
-		Name  string `json:"name"`
-		Order int    `json:"order"`
-	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+	results, err := s.storage.GetMetricsHistoriesFiltered(c.Request.Context(), metricsFilter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-
-	err := s.storage.UpdatePanelOrder(c.Request.Context(), req.Name, req.Order)
+	// the histories are in chronological order, the heartbeats are computed on the latest values
+	latest, err := s.storage.GetLatestMetricsFiltered(c.Request.Context(), common.MetricsFilter{Tenant: metricsFilter.Tenant})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"ok": true})
-}
+	heartbeats := common.CollectHeartbeats(latest)
+	now := time.Now().Unix()
+	staleSeconds := s.staleSeconds()
 
-func (s *server) handleUpdateMetricOrder(c *gin.Context) {
-	var req struct {
-		Name  string `json:"name"`
-		Order int    `json:"order"`
+	type responseMetric struct {
+		common.MetricHistory
+		// Stale is set when the heartbeat of the agent reporting the metric is stale, as for /metrics
+		Stale bool `json:"stale"`
 	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
-		return
+
+	out := make([]responseMetric, 0, len(results))
+	latestRecordedAt := int64(0)
+	for _, r := range results {
+		if r.Tags == nil {
+			r.Tags = common.ParseTagsFromName(r.Name)
+		}
+		if !common.MatchesTags(r.Tags, filters) {
+			continue
+		}
+		if dashboard != nil && !dashboard.Contains(r.Name, r.Tags[common.TagVM]) {
+			continue
+		}
+
+		if len(r.History) > 0 {
+			latestRecordedAt = max(latestRecordedAt, r.History[len(r.History)-1].RecordedAt)
+		}
+		r.History = history.FillGaps(r.History, r.GapMode, 0)
+		if r.History == nil {
+			r.History = make([]common.MetricValue, 0)
+		}
+		r.Tenant = ""
+		out = append(out, responseMetric{
+			MetricHistory: r,
+			Stale:         heartbeats.IsAgentDown(r.Name, now, staleSeconds),
+		})
 	}
 
-	err := s.storage.UpdateMetricOrder(c.Request.Context(), req.Name, req.Order)
+	writeJSONWithETag(c, latestRecordedAt, gin.H{"metrics": out})
+}
+
+// collectHeartbeats returns the agent heartbeats, fetching them separately if the filter might have excluded them
+func (s *server) collectHeartbeats(
+	ctx context.Context,
+	filter common.MetricsFilter,
+	results []common.MetricHistory,
+) (common.AgentHeartbeats, error) {
+	if len(filter.Name) == 0 && len(filter.Prefix) == 0 && len(filter.Type) == 0 {
+		return common.CollectHeartbeats(results), nil
+	}
+
+	all, err := s.storage.GetLatestMetricsFiltered(ctx, common.MetricsFilter{Tenant: filter.Tenant})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return nil, err
 	}
-	c.JSON(http.StatusOK, gin.H{"ok": true})
+
+	return common.CollectHeartbeats(all), nil
 }
 
-func (s *server) handleUpdateMetricAlarm(c *gin.Context) {
-	var req struct {
-		Name    string `json:"name"`
-		Enabled bool   `json:"enabled"`
+func parseTagFilters(c *gin.Context) ([]common.TagFilter, error) {
+	filters := make([]common.TagFilter, 0)
+	for _, tag := range c.QueryArray("tag") {
+		filter, err := common.ParseTagFilter(tag)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
 	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
-		return
+
+	return filters, nil
+}
+
+func parseMetricsFilter(c *gin.Context) (common.MetricsFilter, error) {
+	filter := common.MetricsFilter{
+		Name:   c.Query("name"),
+		Prefix: c.Query("prefix"),
+		Type:   c.Query("type"),
+		SortBy: common.MetricsSortField(c.Query("sort")),
+	}
+	if filter.SortBy != "" && !filter.SortBy.IsValid() {
+		return common.MetricsFilter{}, fmt.Errorf("invalid sort field %s", filter.SortBy)
 	}
 
-	err := s.storage.UpdateMetricAlarm(c.Request.Context(), req.Name, req.Enabled)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	switch strings.ToLower(c.Query("order")) {
+	case "", "asc":
+	case "desc":
+		filter.Descending = true
+	default:
+		return common.MetricsFilter{}, errors.New("invalid sort order, expected asc or desc")
 	}
-	c.JSON(http.StatusOK, gin.H{"ok": true})
+
+	return filter, nil
+}
+
+// handleGetMetricHistory returns the retained values of the metric or, with ?since=<recordedAt>, only the values
+// recorded after the provided timestamp so the charts can be updated incrementally
+func (s *server) handleGetMetricHistory(c *gin.Context) {
+	name := c.Param("name")
+	var hist *common.MetricHistory
+	var err error
+	sinceString := c.Query("since")
+	if sinceString != "" {
+		since, errParse := strconv.ParseInt(sinceString, 10, 64)
+		if errParse != nil || since < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid since"})
+			return
+		}
+		hist, err = s.storage.GetMetricHistorySince(c.Request.Context(), name, since)
+	} else {
+		hist, err = s.storage.GetMetricHistory(c.Request.Context(), name)
+	}
+	if err != nil {
+		if errors.Is(err, common.ErrMetricNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "metric not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !s.authorizeMetricHistory(c, hist) {
+		return
+	}
+
+	step := int64(0)
+	stepString := c.Query("step")
+	if stepString != "" {
+		step, err = strconv.ParseInt(stepString, 10, 64)
+		if err != nil || step <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid step"})
+			return
+		}
+	}
+
+	hist.History = history.FillGaps(hist.History, hist.GapMode, step)
+
+	c.JSON(http.StatusOK, hist)
+}
+
+// authorizeMetricHistory checks that the fetched metric belongs to the tenant of the session and to the requested
+// dashboard, writing the error response if not
+func (s *server) authorizeMetricHistory(c *gin.Context, hist *common.MetricHistory) bool {
+	tenant := c.GetString(sessionTenantKey)
+	if len(tenant) > 0 && hist.Tenant != tenant {
+		c.JSON(http.StatusNotFound, gin.H{"error": "metric not found"})
+		return false
+	}
+
+	dashboard, status, err := s.requestedDashboard(c)
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return false
+	}
+	if dashboard != nil {
+		tags := hist.Tags
+		if tags == nil {
+			tags = common.ParseTagsFromName(hist.Name)
+		}
+		if !dashboard.Contains(hist.Name, tags[common.TagVM]) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "metric not found"})
+			return false
+		}
+	}
+
+	return true
+}
+
+// handleGetMetricRate returns the increase rate of a monotonically increasing metric (e.g. a nonce) over the window
+// query parameter, a Go duration defaulting to 5 minutes
+func (s *server) handleGetMetricRate(c *gin.Context) {
+	window := defaultRateWindow
+	windowString := c.Query("window")
+	if windowString != "" {
+		var err error
+		window, err = time.ParseDuration(windowString)
+		if err != nil || window < time.Second {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid window, use a duration of at least 1s, e.g. 5m"})
+			return
+		}
+	}
+
+	name := c.Param("name")
+	since := time.Now().Add(-window).Unix()
+	hist, err := s.storage.GetMetricHistorySince(c.Request.Context(), name, since)
+	if err != nil {
+		if errors.Is(err, common.ErrMetricNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "metric not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !s.authorizeMetricHistory(c, hist) {
+		return
+	}
+	if !common.IsNumericMetricType(hist.Type) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the rate is only computed for the numeric metrics"})
+		return
+	}
+
+	c.JSON(http.StatusOK, struct {
+		Name            string `json:"name"`
+		WindowInSeconds int64  `json:"windowInSeconds"`
+		history.Rate
+	}{
+		Name:            hist.Name,
+		WindowInSeconds: int64(window / time.Second),
+		Rate:            history.ComputeRate(hist.History),
+	})
+}
+
+// handleGetMetricStats returns the min, max, mean and percentiles of the values recorded between the from and to
+// query parameters (unix timestamps, defaulting to all the stored values)
+func (s *server) handleGetMetricStats(c *gin.Context) {
+	from, errFrom := parseTimestampQuery(c, "from", 0)
+	to, errTo := parseTimestampQuery(c, "to", time.Now().Unix())
+	if errFrom != nil || errTo != nil || from > to {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid range, from and to must be unix timestamps, from <= to"})
+		return
+	}
+
+	name := c.Param("name")
+	if !s.authorizeMetric(c, name) {
+		return
+	}
+
+	stats, err := s.storage.GetMetricStats(c.Request.Context(), name, from, to)
+	switch {
+	case errors.Is(err, common.ErrMetricNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "metric not found"})
+	case errors.Is(err, common.ErrMetricNotNumeric):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the stats are only computed for the numeric metrics"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, stats)
+	}
+}
+
+// parseTimestampQuery returns the non-negative unix timestamp of the query parameter, or the default value if missing
+func parseTimestampQuery(c *gin.Context, key string, defaultValue int64) (int64, error) {
+	value := c.Query(key)
+	if len(value) == 0 {
+		return defaultValue, nil
+	}
+
+	timestamp, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if timestamp < 0 {
+		return 0, fmt.Errorf("negative timestamp %d", timestamp)
+	}
+
+	return timestamp, nil
+}
+
+func (s *server) handleDeleteMetric(c *gin.Context) {
+	name := c.Param("name")
+	if !s.authorizeMetric(c, name) {
+		return
+	}
+
+	err := s.storage.DeleteMetric(c.Request.Context(), name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+func (s *server) handleRestoreMetric(c *gin.Context) {
+	name := c.Param("name")
+	if !s.authorizeMetric(c, name) {
+		return
+	}
+
+	err := s.storage.RestoreMetric(c.Request.Context(), name)
+	if errors.Is(err, common.ErrMetricNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "metric is not in the trash"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// handleGetTrashedMetrics returns the deleted metrics of the session tenant that can still be restored
+func (s *server) handleGetTrashedMetrics(c *gin.Context) {
+	metrics, err := s.storage.GetTrashedMetrics(c.Request.Context(), c.GetString(sessionTenantKey))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"metrics": metrics})
+}
+
+func (s *server) handleDeleteMetricsByPrefix(c *gin.Context) {
+	prefix := c.Query("prefix")
+	if len(prefix) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the prefix query parameter is required"})
+		return
+	}
+
+	numDeleted, err := s.storage.DeleteMetricsByPrefix(c.Request.Context(), prefix)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Info("deleted metrics", "prefix", prefix, "num metrics", numDeleted)
+
+	c.JSON(http.StatusOK, gin.H{"ok": true, "deleted": numDeleted})
+}
+
+func (s *server) handleRenameMetric(c *gin.Context) {
+	var req struct {
+		NewName string `json:"newName"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	if len(strings.TrimSpace(req.NewName)) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "empty new name"})
+		return
+	}
+
+	name := c.Param("name")
+	if !s.authorizeMetric(c, name) {
+		return
+	}
+	if name == req.NewName {
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+		return
+	}
+	if s.metricNamespace(name) != s.metricNamespace(req.NewName) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the new name must keep the tenant prefix of the metric"})
+		return
+	}
+
+	err := s.storage.RenameMetric(c.Request.Context(), name, req.NewName)
+	switch {
+	case errors.Is(err, common.ErrMetricNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, common.ErrMetricAlreadyExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	}
+}
+
+func (s *server) handleGetMetricAnnotations(c *gin.Context) {
+	if !s.authorizeMetric(c, c.Param("name")) {
+		return
+	}
+
+	annotations, err := s.storage.GetMetricAnnotations(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"annotations": annotations})
+}
+
+func (s *server) handleAddMetricAnnotation(c *gin.Context) {
+	var req struct {
+		Text       string `json:"text"`
+		RecordedAt int64  `json:"recordedAt"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	if len(strings.TrimSpace(req.Text)) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "empty annotation text"})
+		return
+	}
+	if req.RecordedAt == 0 {
+		req.RecordedAt = time.Now().Unix()
+	}
+	if !s.authorizeMetric(c, c.Param("name")) {
+		return
+	}
+
+	id, err := s.storage.AddMetricAnnotation(c.Request.Context(), c.Param("name"), req.Text, req.RecordedAt)
+	if errors.Is(err, common.ErrMetricNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true, "id": id})
+}
+
+func (s *server) handleDeleteMetricAnnotation(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid annotation id"})
+		return
+	}
+	if !s.authorizeMetric(c, c.Param("name")) {
+		return
+	}
+
+	err = s.storage.DeleteMetricAnnotation(c.Request.Context(), c.Param("name"), id)
+	if errors.Is(err, common.ErrAnnotationNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+func (s *server) handleGetPanelsConfigs(c *gin.Context) {
+	configs, err := s.storage.GetPanelsConfigs(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	tenant := c.GetString(sessionTenantKey)
+	if len(tenant) > 0 {
+		panels, errPanels := s.tenantPanels(c, tenant)
+		if errPanels != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": errPanels.Error()})
+			return
+		}
+		for name := range configs {
+			if _, found := panels[name]; !found {
+				delete(configs, name)
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, configs)
+}
+
+func (s *server) handleUpdatePanelOrder(c *gin.Context) {
+	var req struct {
+		Name  string `json:"name"`
+		Order int    `json:"order"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+
+	tenant := c.GetString(sessionTenantKey)
+	if len(tenant) > 0 {
+		panels, err := s.tenantPanels(c, tenant)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if _, found := panels[req.Name]; !found {
+			c.JSON(http.StatusNotFound, gin.H{"error": "panel not found"})
+			return
+		}
+	}
+
+	err := s.storage.UpdatePanelOrder(c.Request.Context(), req.Name, req.Order)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+func (s *server) handleUpdateMetricOrder(c *gin.Context) {
+	var req struct {
+		Name  string `json:"name"`
+		Order int    `json:"order"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+
+	if !s.authorizeMetric(c, req.Name) {
+		return
+	}
+
+	err := s.storage.UpdateMetricOrder(c.Request.Context(), req.Name, req.Order)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+func (s *server) handleUpdateMetricAlarm(c *gin.Context) {
+	var req struct {
+		Name    string `json:"name"`
+		Enabled bool   `json:"enabled"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+
+	if !s.authorizeMetric(c, req.Name) {
+		return
+	}
+
+	err := s.storage.UpdateMetricAlarm(c.Request.Context(), req.Name, req.Enabled)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+func (s *server) handleUpdateMetricGapMode(c *gin.Context) {
+	var req struct {
+		Name string         `json:"name"`
+		Mode common.GapMode `json:"mode"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	if !req.Mode.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid gap mode"})
+		return
+	}
+
+	if !s.authorizeMetric(c, req.Name) {
+		return
+	}
+
+	err := s.storage.UpdateMetricGapMode(c.Request.Context(), req.Name, req.Mode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+func (s *server) handleUpdateMetricAggregationMode(c *gin.Context) {
+	var req struct {
+		Name string                 `json:"name"`
+		Mode common.AggregationMode `json:"mode"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	if !req.Mode.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid aggregation mode"})
+		return
+	}
+
+	if !s.authorizeMetric(c, req.Name) {
+		return
+	}
+
+	err := s.storage.UpdateMetricAggregationMode(c.Request.Context(), req.Name, req.Mode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// handleUpdateMetricStoreOnChange toggles storing only the changes of the value of a metric, the identical
+// consecutive values only extending the last seen timestamp of the stored one
+func (s *server) handleUpdateMetricStoreOnChange(c *gin.Context) {
+	var req struct {
+		Name    string `json:"name"`
+		Enabled bool   `json:"enabled"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+
+	if !s.authorizeMetric(c, req.Name) {
+		return
+	}
+
+	err := s.storage.UpdateMetricStoreOnChange(c.Request.Context(), req.Name, req.Enabled)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// handleUpdateMetricCompression toggles packing the older values of a metric in delta encoded chunks, for the high
+// frequency metrics with large aggregation windows
+func (s *server) handleUpdateMetricCompression(c *gin.Context) {
+	var req struct {
+		Name    string `json:"name"`
+		Enabled bool   `json:"enabled"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+
+	if !s.authorizeMetric(c, req.Name) {
+		return
+	}
+
+	err := s.storage.UpdateMetricCompression(c.Request.Context(), req.Name, req.Enabled)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// handleSetMetricThresholds stores the display thresholds of a metric, evaluated by the server so all the clients
+// color the metric alike
+func (s *server) handleSetMetricThresholds(c *gin.Context) {
+	var thresholds common.MetricThresholds
+	if err := c.ShouldBindJSON(&thresholds); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	if err := thresholds.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.storeMetricThresholds(c, &thresholds)
+}
+
+func (s *server) handleDeleteMetricThresholds(c *gin.Context) {
+	s.storeMetricThresholds(c, nil)
+}
+
+func (s *server) storeMetricThresholds(c *gin.Context, thresholds *common.MetricThresholds) {
+	name := c.Param("name")
+	if !s.authorizeMetric(c, name) {
+		return
+	}
+
+	err := s.storage.SetMetricThresholds(c.Request.Context(), name, thresholds)
+	if errors.Is(err, common.ErrMetricNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// displayedValue returns the value shown by the dashboard for the metric: the aggregated value, if any, or the latest
+// value
+func displayedValue(metric common.MetricHistory) string {
+	if len(metric.AggregatedValue) > 0 {
+		return metric.AggregatedValue
+	}
+
+	return metric.History[0].Value
+}
+
+func (s *server) handleGetDashboards(c *gin.Context) {
+	dashboards, err := s.storage.GetDashboards(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"dashboards": dashboards})
+}
+
+func (s *server) handleGetDashboard(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid dashboard id"})
+		return
+	}
+
+	dashboard, err := s.storage.GetDashboard(c.Request.Context(), id)
+	if errors.Is(err, common.ErrDashboardNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, dashboard)
+}
+
+func (s *server) handleCreateDashboard(c *gin.Context) {
+	dashboard, ok := bindDashboard(c)
+	if !ok {
+		return
+	}
+
+	id, err := s.storage.CreateDashboard(c.Request.Context(), dashboard)
+	if errors.Is(err, common.ErrDashboardAlreadyExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true, "id": id})
+}
+
+func (s *server) handleUpdateDashboard(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid dashboard id"})
+		return
+	}
+
+	dashboard, ok := bindDashboard(c)
+	if !ok {
+		return
+	}
+	dashboard.ID = id
+
+	err = s.storage.UpdateDashboard(c.Request.Context(), dashboard)
+	switch {
+	case errors.Is(err, common.ErrDashboardNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, common.ErrDashboardAlreadyExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	}
+}
+
+func bindDashboard(c *gin.Context) (common.Dashboard, bool) {
+	var dashboard common.Dashboard
+	if err := c.ShouldBindJSON(&dashboard); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return dashboard, false
+	}
+
+	dashboard.Name = strings.TrimSpace(dashboard.Name)
+	if len(dashboard.Name) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "empty dashboard name"})
+		return dashboard, false
+	}
+
+	return dashboard, true
+}
+
+func (s *server) handleDeleteDashboard(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid dashboard id"})
+		return
+	}
+
+	err = s.storage.DeleteDashboard(c.Request.Context(), id)
+	if errors.Is(err, common.ErrDashboardNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+func (s *server) handleGetSchema(c *gin.Context) {
+	info, err := s.storage.GetSchemaInfo(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
 }
 
 func (s *server) handleGetGeneralConfig(c *gin.Context) {
@@ -456,6 +1770,7 @@ func (s *server) handleGetGeneralConfig(c *gin.Context) {
 
 func (s *server) handleAppInfo(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
-		"version": s.appVersion,
+		"version":     s.appVersion,
+		"oidcEnabled": s.oidcProvider != nil,
 	})
 }
@@ -44,7 +44,7 @@ func TestServer_StartAndClose(t *testing.T) {
 
 func TestHandlers_StorageErrors(t *testing.T) {
 	store := &testsCommon.StoreStub{
-		SaveMetricHandler: func(ctx context.Context, name string, metricType string, numAggregation int, valString string, recordedAt int64) error {
+		SaveMetricsHandler: func(ctx context.Context, records []common.MetricRecord) error {
 			return errors.New("db save error")
 		},
 		GetLatestMetricsHandler: func(ctx context.Context) ([]common.MetricHistory, error) {
@@ -3,18 +3,26 @@ package api
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
 	"time"
 
+	"github.com/iulianpascalau/api-monitoring/commonGo"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
 	"github.com/iulianpascalau/api-monitoring/services/aggregation/storage"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/testsCommon"
 	"github.com/stretchr/testify/require"
 )
 
 func setupTestServer(t *testing.T) (*server, Storage) {
-	store, err := storage.NewSQLiteStorage(":memory:", 100)
+	store, err := storage.NewSQLiteStorage(":memory:", 100, storage.SQLiteTuning{})
 	require.NoError(t, err)
 
 	args := ArgsWebServer{
@@ -39,11 +47,7 @@ func TestReportEndpoint(t *testing.T) {
 	}()
 
 	payload := MetricReportPayload{
-		Metrics: map[string]struct {
-			Value          string `json:"value"`
-			Type           string `json:"type"`
-			NumAggregation int    `json:"numAggregation"`
-		}{
+		Metrics: map[string]ReportedMetric{
 			"VM1.Active": {
 				Value:          "true",
 				Type:           "bool",
@@ -74,6 +78,181 @@ func TestReportEndpoint(t *testing.T) {
 	require.Equal(t, "true", metrics[0].History[0].Value)
 }
 
+func TestReportEndpoint_Tags(t *testing.T) {
+	serv, store := setupTestServer(t)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	payload := MetricReportPayload{
+		Metrics: map[string]ReportedMetric{
+			"VM1.Active": {
+				Value:          "true",
+				Type:           "bool",
+				NumAggregation: 1,
+			},
+			"VM1.Node1.nonce": {
+				Value:          "100",
+				Type:           "uint64",
+				NumAggregation: 1,
+				Tags:           map[string]string{"shard": "0"},
+			},
+			"VM2.Node1.nonce": {
+				Value:          "101",
+				Type:           "uint64",
+				NumAggregation: 1,
+			},
+		},
+	}
+	body, _ := json.Marshal(payload)
+
+	req, _ := http.NewRequest("POST", "/api/report", bytes.NewBuffer(body))
+	req.Header.Set("X-Api-Key", "test-secret")
+	w := httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	token := getValidToken(serv)
+	getMetricNames := func(query string) []string {
+		req, _ = http.NewRequest("GET", "/api/metrics"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w = httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var resp struct {
+			Metrics []struct {
+				Name string            `json:"name"`
+				Tags map[string]string `json:"tags"`
+			} `json:"metrics"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+
+		names := make([]string, 0, len(resp.Metrics))
+		for _, m := range resp.Metrics {
+			names = append(names, m.Name)
+		}
+		return names
+	}
+
+	require.ElementsMatch(t, []string{"VM1.Active", "VM1.Node1.nonce", "VM2.Node1.nonce"}, getMetricNames(""))
+	require.ElementsMatch(t, []string{"VM1.Active", "VM1.Node1.nonce"}, getMetricNames("?tag=vm:VM1"))
+	require.ElementsMatch(t, []string{"VM1.Node1.nonce", "VM2.Node1.nonce"}, getMetricNames("?tag=kind:nonce"))
+	require.ElementsMatch(t, []string{"VM1.Node1.nonce"}, getMetricNames("?tag=kind:nonce&tag=shard:0"))
+	require.Empty(t, getMetricNames("?tag=vm:VM3"))
+
+	req, _ = http.NewRequest("GET", "/api/metrics?tag=invalid", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	// the history also contains the tags
+	req, _ = http.NewRequest("GET", "/api/metrics/VM1.Node1.nonce/history", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"tags":{"kind":"nonce","node":"Node1","shard":"0","vm":"VM1"}`)
+}
+
+func TestReportEndpoint_ExpectedInterval(t *testing.T) {
+	serv, store := setupTestServer(t)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	body := `{"metrics":{"VM1.Active":{"value":"true","type":"bool","numAggregation":1,"interval":30},` +
+		`"VM1.Node1.nonce":{"value":"100","type":"uint64","numAggregation":1}}}`
+	req, _ := http.NewRequest("POST", "/api/report", bytes.NewBufferString(body))
+	req.Header.Set("X-Api-Key", "test-secret")
+	w := httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	req, _ = http.NewRequest("GET", "/api/metrics", nil)
+	req.Header.Set("Authorization", "Bearer "+getValidToken(serv))
+	w = httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Metrics []struct {
+			Name             string `json:"name"`
+			ExpectedInterval int    `json:"expectedInterval"`
+		} `json:"metrics"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+	intervals := make(map[string]int)
+	for _, m := range resp.Metrics {
+		intervals[m.Name] = m.ExpectedInterval
+	}
+	require.Equal(t, map[string]int{"VM1.Active": 30, "VM1.Node1.nonce": 0}, intervals)
+}
+
+func TestReportEndpoint_StaleValues(t *testing.T) {
+	serv, store := setupTestServer(t)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	report := func(body string) {
+		req, _ := http.NewRequest("POST", "/api/report", bytes.NewBufferString(body))
+		req.Header.Set("X-Api-Key", "test-secret")
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	report(`{"metrics":{"VM1.Active":{"value":"true","type":"bool","numAggregation":1},` +
+		`"VM1.Node1.nonce":{"value":"100","type":"uint64","numAggregation":1,"recordedAt":1000}}}`)
+	// the last good value re-sent after a failed poll is not stored as a new sample
+	report(`{"metrics":{"VM1.Active":{"value":"true","type":"bool","numAggregation":1},` +
+		`"VM1.Node1.nonce":{"value":"100","type":"uint64","numAggregation":1,"stale":true},` +
+		`"VM1.Node2.nonce":{"value":"5","type":"uint64","numAggregation":1,"stale":true}}}`)
+
+	hist, err := store.GetMetricHistory(context.Background(), "VM1.Node1.nonce")
+	require.NoError(t, err)
+	require.Equal(t, []common.MetricValue{{Value: "100", RecordedAt: 1000}}, hist.History)
+	_, err = store.GetMetricHistory(context.Background(), "VM1.Node2.nonce")
+	require.Equal(t, common.ErrMetricNotFound, err)
+}
+
+func TestReportEndpoint_Sink(t *testing.T) {
+	serv, store := setupTestServer(t)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	var forwarded []common.MetricRecord
+	serv.sink = &testsCommon.MetricsSinkStub{
+		ForwardHandler: func(records []common.MetricRecord) {
+			forwarded = append(forwarded, records...)
+		},
+	}
+
+	body := []byte(`{"metrics": {"VM1.Node1.nonce": {"value": "10", "type": "uint64", "numAggregation": 5}}}`)
+	req, _ := http.NewRequest("POST", "/api/report", bytes.NewBuffer(body))
+	req.Header.Set("X-Api-Key", "test-secret")
+	w := httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	require.Len(t, forwarded, 1)
+	require.Equal(t, "VM1.Node1.nonce", forwarded[0].Name)
+	require.Equal(t, "10", forwarded[0].Value)
+	require.Equal(t, "VM1", forwarded[0].Tags["vm"])
+
+	// the rejected reports are not forwarded
+	req, _ = http.NewRequest("POST", "/api/report", bytes.NewBufferString("{"))
+	req.Header.Set("X-Api-Key", "test-secret")
+	w = httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Len(t, forwarded, 1)
+}
+
 func TestLoginAndGetMetrics(t *testing.T) {
 	serv, store := setupTestServer(t)
 	defer func() {
@@ -152,6 +331,43 @@ func TestGetMetricHistory(t *testing.T) {
 	require.Equal(t, http.StatusNotFound, w.Code)
 }
 
+func TestGetMetricHistory_Since(t *testing.T) {
+	serv, store := setupTestServer(t)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	now := time.Now().Unix()
+	require.NoError(t, store.SaveMetric(context.Background(), "VM1.CPU", "uint64", 3, "50", now-20))
+	require.NoError(t, store.SaveMetric(context.Background(), "VM1.CPU", "uint64", 3, "60", now-10))
+
+	token := getValidToken(serv)
+	get := func(url string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", url, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+
+		return w
+	}
+
+	w := get(fmt.Sprintf("/api/metrics/VM1.CPU/history?since=%d", now-20))
+	require.Equal(t, http.StatusOK, w.Code)
+	hist := &common.MetricHistory{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), hist))
+	require.Equal(t, []common.MetricValue{{Value: "60", RecordedAt: now - 10}}, hist.History)
+
+	w = get(fmt.Sprintf("/api/metrics/VM1.CPU/history?since=%d", now-10))
+	require.Equal(t, http.StatusOK, w.Code)
+	hist = &common.MetricHistory{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), hist))
+	require.Empty(t, hist.History)
+
+	require.Equal(t, http.StatusBadRequest, get("/api/metrics/VM1.CPU/history?since=abc").Code)
+	require.Equal(t, http.StatusBadRequest, get("/api/metrics/VM1.CPU/history?since=-1").Code)
+	require.Equal(t, http.StatusNotFound, get("/api/metrics/UnknownMetric/history?since=0").Code)
+}
+
 func TestDeleteMetric(t *testing.T) {
 	serv, store := setupTestServer(t)
 	defer func() {
@@ -178,6 +394,86 @@ func TestDeleteMetric(t *testing.T) {
 	require.Equal(t, http.StatusNotFound, w.Code)
 }
 
+func TestRestoreMetric(t *testing.T) {
+	serv, store := setupTestServer(t)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	err := store.SaveMetric(context.Background(), "VM1.RAM", "uint64", 3, "2048", time.Now().Unix())
+	require.NoError(t, err)
+
+	token := getValidToken(serv)
+	do := func(method string, path string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+		return w
+	}
+
+	require.Equal(t, http.StatusOK, do("DELETE", "/api/metrics/VM1.RAM").Code)
+	w := do("GET", "/api/metrics/trash")
+	require.Equal(t, http.StatusOK, w.Code)
+	response := struct {
+		Metrics []common.TrashedMetric `json:"metrics"`
+	}{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Metrics, 1)
+	require.Equal(t, "VM1.RAM", response.Metrics[0].Name)
+	require.Equal(t, "uint64", response.Metrics[0].Type)
+
+	require.Equal(t, http.StatusOK, do("POST", "/api/metrics/VM1.RAM/restore").Code)
+	require.Equal(t, http.StatusOK, do("GET", "/api/metrics/VM1.RAM/history").Code)
+
+	// restoring a metric not in the trash
+	require.Equal(t, http.StatusNotFound, do("POST", "/api/metrics/VM1.RAM/restore").Code)
+	require.Equal(t, http.StatusNotFound, do("POST", "/api/metrics/Unknown/restore").Code)
+}
+
+func TestReportEndpoint_Checksum(t *testing.T) {
+	serv, store := setupTestServer(t)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	body := []byte(`{"metrics": {"VM1.Active": {"value": "true", "type": "bool", "numAggregation": 1}}}`)
+	checksum := sha256.Sum256(body)
+
+	// Invalid checksum header
+	req, _ := http.NewRequest("POST", "/api/report", bytes.NewBuffer(body))
+	req.Header.Set("X-Api-Key", "test-secret")
+	req.Header.Set(checksumHeader, "not-hex")
+	w := httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Truncated body
+	req, _ = http.NewRequest("POST", "/api/report", bytes.NewBuffer(body[:len(body)-5]))
+	req.Header.Set("X-Api-Key", "test-secret")
+	req.Header.Set(checksumHeader, hex.EncodeToString(checksum[:]))
+	w = httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	require.Contains(t, w.Body.String(), `"code":"checksum_mismatch"`)
+
+	metrics, err := store.GetLatestMetrics(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, metrics)
+
+	// Matching checksum
+	req, _ = http.NewRequest("POST", "/api/report", bytes.NewBuffer(body))
+	req.Header.Set("X-Api-Key", "test-secret")
+	req.Header.Set(checksumHeader, hex.EncodeToString(checksum[:]))
+	w = httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	metrics, err = store.GetLatestMetrics(context.Background())
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+}
+
 func TestReportEndpoint_BadPayload(t *testing.T) {
 	serv, store := setupTestServer(t)
 	defer func() {
@@ -192,6 +488,61 @@ func TestReportEndpoint_BadPayload(t *testing.T) {
 	require.Equal(t, http.StatusBadRequest, w.Code)
 }
 
+func TestReportEndpoint_MsgPack(t *testing.T) {
+	serv, store := setupTestServer(t)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	payload := MetricReportPayload{
+		Metrics: map[string]ReportedMetric{
+			"VM1.Node1.nonce": {
+				Value:          "12345",
+				Type:           "uint64",
+				NumAggregation: 10,
+				Tags:           map[string]string{"network": "mainnet"},
+				Interval:       30,
+			},
+		},
+	}
+	body, err := commonGo.MarshalMsgPack(payload)
+	require.NoError(t, err)
+
+	report := func(body []byte, accept string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/report", bytes.NewBuffer(body))
+		req.Header.Set("X-Api-Key", "test-secret")
+		req.Header.Set("Content-Type", commonGo.ContentTypeMsgPack)
+		req.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+
+		return w
+	}
+
+	w := report(body, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"ok": true}`, w.Body.String())
+
+	hist, err := store.GetMetricHistory(context.Background(), "VM1.Node1.nonce")
+	require.NoError(t, err)
+	require.Equal(t, "12345", hist.History[0].Value)
+	require.Equal(t, 30, hist.ExpectedInterval)
+	require.Equal(t, "mainnet", hist.Tags["network"])
+
+	w = report(body, "application/json, application/x-msgpack")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, commonGo.ContentTypeMsgPack, w.Header().Get("Content-Type"))
+	response := make(map[string]interface{})
+	require.NoError(t, commonGo.UnmarshalMsgPack(w.Body.Bytes(), &response))
+	require.Equal(t, true, response["ok"])
+
+	w = report([]byte{0xc1}, commonGo.ContentTypeMsgPack)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	response = make(map[string]interface{})
+	require.NoError(t, commonGo.UnmarshalMsgPack(w.Body.Bytes(), &response))
+	require.Equal(t, "invalid payload", response["error"])
+}
+
 func TestAuth_InvalidToken(t *testing.T) {
 	serv, store := setupTestServer(t)
 	defer func() {
@@ -262,3 +613,650 @@ func TestConfigEndpoints(t *testing.T) {
 	require.Equal(t, http.StatusOK, w.Code)
 	require.Contains(t, w.Body.String(), `"isAlarmEnabled":true`)
 }
+
+func TestMetricGapMode(t *testing.T) {
+	serv, store := setupTestServer(t)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	ctx := context.Background()
+	_ = store.SaveMetric(ctx, "VM1.CPU", "uint64", 10, "50", 1000)
+	_ = store.SaveMetric(ctx, "VM1.CPU", "uint64", 10, "51", 1010)
+	_ = store.SaveMetric(ctx, "VM1.CPU", "uint64", 10, "52", 1040)
+
+	token := getValidToken(serv)
+
+	// 1. Invalid gap mode
+	req, _ := http.NewRequest("POST", "/api/config/metrics/gaps", bytes.NewBuffer([]byte(`{"name":"VM1.CPU", "mode":"interpolate"}`)))
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	// 2. Set the null gap mode
+	req, _ = http.NewRequest("POST", "/api/config/metrics/gaps", bytes.NewBuffer([]byte(`{"name":"VM1.CPU", "mode":"null"}`)))
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// 3. The history contains the filled points
+	req, _ = http.NewRequest("GET", "/api/metrics/VM1.CPU/history", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"gapMode":"null"`)
+	require.Contains(t, w.Body.String(), `{"value":null,"recordedAt":1020,"filled":true},{"value":null,"recordedAt":1030,"filled":true}`)
+
+	// 4. Explicit step
+	req, _ = http.NewRequest("GET", "/api/metrics/VM1.CPU/history?step=5", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `{"value":null,"recordedAt":1005,"filled":true}`)
+
+	// 5. Invalid step
+	req, _ = http.NewRequest("GET", "/api/metrics/VM1.CPU/history?step=abc", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	// 6. The latest metrics contain the gap mode
+	req, _ = http.NewRequest("GET", "/api/metrics", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"gapMode":"null"`)
+}
+
+func TestGetMetricRate(t *testing.T) {
+	serv, store := setupTestServer(t)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	ctx := context.Background()
+	now := time.Now().Unix()
+	_ = store.SaveMetric(ctx, "VM1.nonce", "uint64", 100, "1000", now-600)
+	_ = store.SaveMetric(ctx, "VM1.nonce", "uint64", 100, "1010", now-120)
+	_ = store.SaveMetric(ctx, "VM1.nonce", "uint64", 100, "1030", now-60)
+	_ = store.SaveMetric(ctx, "VM1.version", "string", 1, "v1.0.0", now)
+
+	token := getValidToken(serv)
+	get := func(path string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+
+		return w
+	}
+
+	// 1. Invalid window
+	require.Equal(t, http.StatusBadRequest, get("/api/metrics/VM1.nonce/rate?window=abc").Code)
+	require.Equal(t, http.StatusBadRequest, get("/api/metrics/VM1.nonce/rate?window=10ms").Code)
+
+	// 2. Unknown and non numeric metrics
+	require.Equal(t, http.StatusNotFound, get("/api/metrics/VM1.missing/rate").Code)
+	require.Equal(t, http.StatusBadRequest, get("/api/metrics/VM1.version/rate").Code)
+
+	// 3. The default window of 5 minutes holds the last 2 samples
+	w := get("/api/metrics/VM1.nonce/rate")
+	require.Equal(t, http.StatusOK, w.Code)
+	response := struct {
+		Name            string   `json:"name"`
+		WindowInSeconds int64    `json:"windowInSeconds"`
+		NumSamples      int      `json:"numSamples"`
+		Increase        *float64 `json:"increase"`
+		PerMinute       *float64 `json:"perMinute"`
+	}{}
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Equal(t, "VM1.nonce", response.Name)
+	require.Equal(t, int64(300), response.WindowInSeconds)
+	require.Equal(t, 2, response.NumSamples)
+	require.Equal(t, 20.0, *response.Increase)
+	require.Equal(t, 20.0, *response.PerMinute)
+
+	// 4. A wider window
+	w = get("/api/metrics/VM1.nonce/rate?window=1h")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Equal(t, 3, response.NumSamples)
+	require.Equal(t, 30.0, *response.Increase)
+	require.Equal(t, 3.3, math.Round(*response.PerMinute*10)/10)
+
+	// 5. A window without enough samples
+	w = get("/api/metrics/VM1.nonce/rate?window=30s")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"numSamples":0`)
+	require.Contains(t, w.Body.String(), `"perSecond":null`)
+}
+
+func TestGetMetricStats(t *testing.T) {
+	serv, store := setupTestServer(t)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	ctx := context.Background()
+	for i := 1; i <= 20; i++ {
+		_ = store.SaveMetric(ctx, "VM1.latency", "uint64", 100, strconv.Itoa(i*10), int64(1000+i))
+	}
+	_ = store.SaveMetric(ctx, "VM1.version", "string", 1, "v1.0.0", 1000)
+
+	token := getValidToken(serv)
+	get := func(path string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+
+		return w
+	}
+
+	// 1. Invalid ranges
+	require.Equal(t, http.StatusBadRequest, get("/api/metrics/VM1.latency/stats?from=abc").Code)
+	require.Equal(t, http.StatusBadRequest, get("/api/metrics/VM1.latency/stats?to=-1").Code)
+	require.Equal(t, http.StatusBadRequest, get("/api/metrics/VM1.latency/stats?from=2000&to=1000").Code)
+
+	// 2. Unknown and non numeric metrics
+	require.Equal(t, http.StatusNotFound, get("/api/metrics/VM1.missing/stats").Code)
+	require.Equal(t, http.StatusBadRequest, get("/api/metrics/VM1.version/stats").Code)
+
+	// 3. All the stored values
+	w := get("/api/metrics/VM1.latency/stats")
+	require.Equal(t, http.StatusOK, w.Code)
+	stats := common.MetricStats{}
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	require.Equal(t, 20, stats.NumValues)
+	require.Equal(t, 10.0, *stats.Min)
+	require.Equal(t, 200.0, *stats.Max)
+	require.Equal(t, 105.0, *stats.Mean)
+	require.Equal(t, 100.0, *stats.P50)
+	require.Equal(t, 190.0, *stats.P95)
+	require.Equal(t, 200.0, *stats.P99)
+
+	// 4. A range
+	w = get("/api/metrics/VM1.latency/stats?from=1001&to=1004")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"from":1001,"to":1004,"numValues":4,"min":10,"max":40,"mean":25,"p50":20,"p95":40,"p99":40`)
+}
+
+func TestMetricAggregationMode(t *testing.T) {
+	serv, store := setupTestServer(t)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	ctx := context.Background()
+	_ = store.SaveMetric(ctx, "VM1.latency", "uint64", 10, "50", 1000)
+	_ = store.SaveMetric(ctx, "VM1.latency", "uint64", 10, "70", 1010)
+
+	token := getValidToken(serv)
+
+	// 1. Invalid aggregation mode
+	req, _ := http.NewRequest("POST", "/api/config/metrics/aggregation", bytes.NewBuffer([]byte(`{"name":"VM1.latency", "mode":"median"}`)))
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	// 2. The latest value is exposed by default
+	req, _ = http.NewRequest("GET", "/api/metrics", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"aggregationMode":"last"`)
+	require.NotContains(t, w.Body.String(), `"aggregatedValue"`)
+
+	// 3. Set the avg aggregation mode
+	req, _ = http.NewRequest("POST", "/api/config/metrics/aggregation", bytes.NewBuffer([]byte(`{"name":"VM1.latency", "mode":"avg"}`)))
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// 4. The latest metrics contain the aggregated value next to the latest one
+	req, _ = http.NewRequest("GET", "/api/metrics", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"value":"70"`)
+	require.Contains(t, w.Body.String(), `"aggregationMode":"avg","aggregatedValue":"60"`)
+}
+
+func TestMetricStoreOnChange(t *testing.T) {
+	serv, store := setupTestServer(t)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	ctx := context.Background()
+	_ = store.SaveMetric(ctx, "VM1.Active", "bool", 10, "true", 1000)
+
+	token := getValidToken(serv)
+	do := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer([]byte(body)))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+		return w
+	}
+
+	require.Equal(t, http.StatusBadRequest, do("POST", "/api/config/metrics/store-on-change", `{"name":"VM1.Active", "enabled":"yes"}`).Code)
+	require.Contains(t, do("GET", "/api/metrics", "").Body.String(), `"storeOnChange":false`)
+
+	require.Equal(t, http.StatusOK, do("POST", "/api/config/metrics/store-on-change", `{"name":"VM1.Active", "enabled":true}`).Code)
+	_ = store.SaveMetric(ctx, "VM1.Active", "bool", 10, "true", 1010)
+
+	w := do("GET", "/api/metrics", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"storeOnChange":true`)
+	require.Contains(t, w.Body.String(), `"recordedAt":1010`)
+
+	w = do("GET", "/api/metrics/VM1.Active/history", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"history":[{"value":"true","recordedAt":1000,"lastSeenAt":1010}]`)
+}
+
+func TestMetricCompression(t *testing.T) {
+	serv, store := setupTestServer(t)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	ctx := context.Background()
+	_ = store.SaveMetric(ctx, "VM1.nonce", "uint64", 1000, "10", 1000)
+
+	token := getValidToken(serv)
+	do := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer([]byte(body)))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+		return w
+	}
+
+	require.Equal(t, http.StatusBadRequest, do("POST", "/api/config/metrics/compression", `{"name":"VM1.nonce", "enabled":1}`).Code)
+	require.Contains(t, do("GET", "/api/metrics", "").Body.String(), `"compressed":false`)
+
+	require.Equal(t, http.StatusOK, do("POST", "/api/config/metrics/compression", `{"name":"VM1.nonce", "enabled":true}`).Code)
+	w := do("GET", "/api/metrics", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"compressed":true`)
+}
+
+func TestMetricThresholds(t *testing.T) {
+	serv, store := setupTestServer(t)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	ctx := context.Background()
+	now := time.Now().Unix()
+	require.NoError(t, store.SaveMetric(ctx, "VM1.cpu", "uint64", 10, "50", now))
+	require.NoError(t, store.SaveMetric(ctx, "VM1.cpu", "uint64", 10, "95", now+1))
+	require.NoError(t, store.SaveMetric(ctx, "VM1.status", "string", 1, "synced", now))
+
+	token := getValidToken(serv)
+	do := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer([]byte(body)))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+		return w
+	}
+
+	// 1. Invalid thresholds
+	require.Equal(t, http.StatusBadRequest, do("PUT", "/api/config/metrics/VM1.cpu/thresholds", `{"warn":`).Code)
+	require.Equal(t, http.StatusBadRequest, do("PUT", "/api/config/metrics/VM1.cpu/thresholds", `{"warn":90,"crit":80}`).Code)
+	require.Equal(t, http.StatusNotFound, do("PUT", "/api/config/metrics/VM1.missing/thresholds", `{"warn":80}`).Code)
+
+	// 2. No status without thresholds
+	w := do("GET", "/api/metrics", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NotContains(t, w.Body.String(), `"status":`)
+
+	// 3. The server evaluates the thresholds
+	require.Equal(t, http.StatusOK, do("PUT", "/api/config/metrics/VM1.cpu/thresholds", `{"warn":80,"crit":90}`).Code)
+	require.Equal(t, http.StatusOK, do("PUT", "/api/config/metrics/VM1.status/thresholds", `{"expected":["synced"]}`).Code)
+	w = do("GET", "/api/metrics", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"thresholds":{"warn":80,"crit":90},"status":"crit"`)
+	require.Contains(t, w.Body.String(), `"thresholds":{"expected":["synced"]},"status":"ok"`)
+
+	// 4. The aggregated value is evaluated instead of the latest one
+	require.Equal(t, http.StatusOK, do("POST", "/api/config/metrics/aggregation", `{"name":"VM1.cpu", "mode":"min"}`).Code)
+	w = do("GET", "/api/metrics", "")
+	require.Contains(t, w.Body.String(), `"aggregatedValue":"50","expectedInterval":0,"thresholds":{"warn":80,"crit":90},"status":"ok"`)
+
+	// 5. Remove the thresholds
+	require.Equal(t, http.StatusOK, do("DELETE", "/api/config/metrics/VM1.cpu/thresholds", "").Code)
+	w = do("GET", "/api/metrics", "")
+	require.NotContains(t, w.Body.String(), `"warn":80`)
+}
+
+func TestGetSchema(t *testing.T) {
+	serv, store := setupTestServer(t)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	req, _ := http.NewRequest("GET", "/api/admin/schema", nil)
+	w := httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+
+	req, _ = http.NewRequest("GET", "/api/admin/schema", nil)
+	req.Header.Set("Authorization", "Bearer "+getValidToken(serv))
+	w = httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"version":`)
+	require.Contains(t, w.Body.String(), `"name":"metrics_values"`)
+	require.Contains(t, w.Body.String(), `"numRows":0`)
+}
+
+func TestGetMetrics_Filtering(t *testing.T) {
+	serv, store := setupTestServer(t)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	ctx := context.Background()
+	_ = store.SaveMetric(ctx, "VM1.Node1.nonce", "uint64", 10, "1", 1001)
+	_ = store.SaveMetric(ctx, "VM1.Active", "bool", 1, "true", 1002)
+	_ = store.SaveMetric(ctx, "VM2.Node1.nonce", "uint64", 10, "2", 1000)
+
+	token := getValidToken(serv)
+	getMetrics := func(query string) (int, []string) {
+		req, _ := http.NewRequest("GET", "/api/metrics"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+
+		var resp struct {
+			Metrics []struct {
+				Name string `json:"name"`
+			} `json:"metrics"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+
+		names := make([]string, 0, len(resp.Metrics))
+		for _, m := range resp.Metrics {
+			names = append(names, m.Name)
+		}
+		return w.Code, names
+	}
+
+	code, names := getMetrics("?name=VM1.Active")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, []string{"VM1.Active"}, names)
+
+	code, names = getMetrics("?prefix=VM1.&sort=name")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, []string{"VM1.Active", "VM1.Node1.nonce"}, names)
+
+	code, names = getMetrics("?type=uint64&sort=recordedAt&order=desc")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, []string{"VM1.Node1.nonce", "VM2.Node1.nonce"}, names)
+
+	code, _ = getMetrics("?sort=value")
+	require.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = getMetrics("?sort=name&order=random")
+	require.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestGetMetricsFull(t *testing.T) {
+	serv, store := setupTestServer(t)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	ctx := context.Background()
+	now := time.Now().Unix()
+	_ = store.SaveMetric(ctx, "VM1.Node1.nonce", "uint64", 10, "1", now-20)
+	_ = store.SaveMetric(ctx, "VM1.Node1.nonce", "uint64", 10, "2", now-10)
+	_ = store.SaveMetric(ctx, "VM1.Active", "bool", 1, "true", now-5)
+	_ = store.SaveMetric(ctx, "VM2.Node1.nonce", "uint64", 10, "7", now-15)
+
+	token := getValidToken(serv)
+	getFull := func(query string) (int, []common.MetricHistory) {
+		req, _ := http.NewRequest("GET", "/api/metrics/full"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+
+		var resp struct {
+			Metrics []common.MetricHistory `json:"metrics"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+
+		return w.Code, resp.Metrics
+	}
+
+	code, metrics := getFull("?prefix=VM1.&sort=name")
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, metrics, 2)
+	require.Equal(t, "VM1.Active", metrics[0].Name)
+	require.Equal(t, []common.MetricValue{{Value: "true", RecordedAt: now - 5}}, metrics[0].History)
+	require.Equal(t, "VM1.Node1.nonce", metrics[1].Name)
+	require.Equal(t, []common.MetricValue{
+		{Value: "1", RecordedAt: now - 20},
+		{Value: "2", RecordedAt: now - 10},
+	}, metrics[1].History)
+
+	code, metrics = getFull("")
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, metrics, 3)
+
+	code, metrics = getFull("?tag=vm:VM2")
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, metrics, 1)
+	require.Equal(t, "VM2.Node1.nonce", metrics[0].Name)
+
+	code, _ = getFull("?sort=value")
+	require.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestBulkDeleteAndRename(t *testing.T) {
+	serv, store := setupTestServer(t)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	ctx := context.Background()
+	_ = store.SaveMetric(ctx, "VM1.Active", "bool", 1, "true", 1000)
+	_ = store.SaveMetric(ctx, "VM1.Node1.nonce", "uint64", 10, "1", 1000)
+	_ = store.SaveMetric(ctx, "VM2.Active", "bool", 1, "true", 1000)
+	_ = store.SaveMetric(ctx, "VM2.Node1.nonce", "uint64", 10, "1", 1000)
+
+	token := getValidToken(serv)
+	send := func(method string, url string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, bytes.NewBuffer([]byte(body)))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+		return w
+	}
+
+	// bulk delete
+	require.Equal(t, http.StatusBadRequest, send("DELETE", "/api/metrics", "").Code)
+
+	w := send("DELETE", "/api/metrics?prefix=VM1.", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"deleted":2`)
+	require.Equal(t, http.StatusNotFound, send("GET", "/api/metrics/VM1.Node1.nonce/history", "").Code)
+
+	// rename
+	require.Equal(t, http.StatusBadRequest, send("POST", "/api/metrics/VM2.Node1.nonce/rename", `{bad}`).Code)
+	require.Equal(t, http.StatusBadRequest, send("POST", "/api/metrics/VM2.Node1.nonce/rename", `{"newName":" "}`).Code)
+	require.Equal(t, http.StatusNotFound, send("POST", "/api/metrics/VM1.Node1.nonce/rename", `{"newName":"VM3.Node1.nonce"}`).Code)
+	require.Equal(t, http.StatusConflict, send("POST", "/api/metrics/VM2.Node1.nonce/rename", `{"newName":"VM2.Active"}`).Code)
+	require.Equal(t, http.StatusOK, send("POST", "/api/metrics/VM2.Node1.nonce/rename", `{"newName":"VM2.Node1.nonce"}`).Code)
+	require.Equal(t, http.StatusOK, send("POST", "/api/metrics/VM2.Node1.nonce/rename", `{"newName":"VM3.Node1.nonce"}`).Code)
+
+	w = send("GET", "/api/metrics/VM3.Node1.nonce/history", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"value":"1"`)
+}
+
+func TestMetricAnnotations(t *testing.T) {
+	serv, store := setupTestServer(t)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	_ = store.SaveMetric(context.Background(), "VM1.Node1.nonce", "uint64", 10, "1", time.Now().Unix()-10)
+
+	token := getValidToken(serv)
+	send := func(method string, url string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, bytes.NewBuffer([]byte(body)))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+		return w
+	}
+
+	require.Equal(t, http.StatusBadRequest, send("POST", "/api/metrics/VM1.Node1.nonce/annotations", `{bad}`).Code)
+	require.Equal(t, http.StatusBadRequest, send("POST", "/api/metrics/VM1.Node1.nonce/annotations", `{"text":""}`).Code)
+	require.Equal(t, http.StatusNotFound, send("POST", "/api/metrics/VM2.Node1.nonce/annotations", `{"text":"note"}`).Code)
+
+	w := send("POST", "/api/metrics/VM1.Node1.nonce/annotations", `{"text":"upgraded node to v1.7.0"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		ID int64 `json:"id"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	require.NotZero(t, resp.ID)
+
+	w = send("GET", "/api/metrics/VM1.Node1.nonce/annotations", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"text":"upgraded node to v1.7.0"`)
+
+	w = send("GET", "/api/metrics/VM1.Node1.nonce/history", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"annotations":[{"id":`)
+
+	require.Equal(t, http.StatusBadRequest, send("DELETE", "/api/metrics/VM1.Node1.nonce/annotations/abc", "").Code)
+	require.Equal(t, http.StatusOK, send("DELETE", fmt.Sprintf("/api/metrics/VM1.Node1.nonce/annotations/%d", resp.ID), "").Code)
+	require.Equal(t, http.StatusNotFound, send("DELETE", fmt.Sprintf("/api/metrics/VM1.Node1.nonce/annotations/%d", resp.ID), "").Code)
+}
+
+func TestDashboards(t *testing.T) {
+	serv, store := setupTestServer(t)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	now := time.Now().Unix()
+	_ = store.SaveMetric(context.Background(), "VM1.Node1.nonce", "uint64", 10, "1", now)
+	_ = store.SaveMetric(context.Background(), "VM2.Node1.nonce", "uint64", 10, "1", now)
+	_ = store.SaveMetric(context.Background(), "VM3.Node1.nonce", "uint64", 10, "1", now)
+
+	token := getValidToken(serv)
+	send := func(method string, url string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, bytes.NewBuffer([]byte(body)))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+		return w
+	}
+
+	require.Equal(t, http.StatusBadRequest, send("POST", "/api/dashboards", `{bad}`).Code)
+	require.Equal(t, http.StatusBadRequest, send("POST", "/api/dashboards", `{"name":" "}`).Code)
+
+	w := send("POST", "/api/dashboards", `{"name":"mainnet","panels":["VM1"],"metrics":["VM3.Node1.nonce"]}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		ID int64 `json:"id"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	require.NotZero(t, resp.ID)
+	require.Equal(t, http.StatusConflict, send("POST", "/api/dashboards", `{"name":"mainnet"}`).Code)
+
+	w = send("GET", "/api/dashboards", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"name":"mainnet"`)
+
+	dashboardURL := fmt.Sprintf("/api/dashboards/%d", resp.ID)
+	w = send("GET", dashboardURL, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"panels":["VM1"]`)
+	require.Equal(t, http.StatusBadRequest, send("GET", "/api/dashboards/abc", "").Code)
+	require.Equal(t, http.StatusNotFound, send("GET", "/api/dashboards/1000", "").Code)
+
+	// the metrics listing can be restricted to a dashboard
+	w = send("GET", fmt.Sprintf("/api/metrics?dashboard=%d", resp.ID), "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), "VM1.Node1.nonce")
+	require.NotContains(t, w.Body.String(), "VM2.Node1.nonce")
+	require.Contains(t, w.Body.String(), "VM3.Node1.nonce")
+	require.Equal(t, http.StatusBadRequest, send("GET", "/api/metrics?dashboard=abc", "").Code)
+	require.Equal(t, http.StatusNotFound, send("GET", "/api/metrics?dashboard=1000", "").Code)
+
+	require.Equal(t, http.StatusOK, send("PUT", dashboardURL, `{"name":"testnet","panels":["VM2"]}`).Code)
+	require.Equal(t, http.StatusNotFound, send("PUT", "/api/dashboards/1000", `{"name":"devnet"}`).Code)
+	w = send("GET", fmt.Sprintf("/api/metrics?dashboard=%d", resp.ID), "")
+	require.NotContains(t, w.Body.String(), "VM1.Node1.nonce")
+	require.Contains(t, w.Body.String(), "VM2.Node1.nonce")
+
+	require.Equal(t, http.StatusOK, send("DELETE", dashboardURL, "").Code)
+	require.Equal(t, http.StatusNotFound, send("DELETE", dashboardURL, "").Code)
+}
+
+func TestGetMetrics_StaleAgents(t *testing.T) {
+	serv, store := setupTestServer(t)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	ctx := context.Background()
+	now := time.Now().Unix()
+	require.NoError(t, store.SaveMetric(ctx, "VM1.Active", "bool", 1, "true", now-1000))
+	require.NoError(t, store.SaveMetric(ctx, "VM1.Node1.nonce", "uint64", 1, "10", now-1000))
+	require.NoError(t, store.SaveMetric(ctx, "VM2.Active", "bool", 1, "true", now))
+	require.NoError(t, store.SaveMetric(ctx, "VM2.Node1.nonce", "uint64", 1, "10", now-1000))
+
+	token := getValidToken(serv)
+	getStaleFlags := func(url string) map[string]bool {
+		req, _ := http.NewRequest("GET", url, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var response struct {
+			Metrics []struct {
+				Name  string `json:"name"`
+				Stale bool   `json:"stale"`
+			} `json:"metrics"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		flags := make(map[string]bool)
+		for _, metric := range response.Metrics {
+			flags[metric.Name] = metric.Stale
+		}
+
+		return flags
+	}
+
+	// the old value of VM2.Node1.nonce is reported by a live agent
+	require.Equal(t, map[string]bool{
+		"VM1.Active":      true,
+		"VM1.Node1.nonce": true,
+		"VM2.Active":      false,
+		"VM2.Node1.nonce": false,
+	}, getStaleFlags("/api/metrics"))
+
+	// the heartbeats excluded by the filters are still taken into account
+	require.Equal(t, map[string]bool{"VM1.Node1.nonce": true}, getStaleFlags("/api/metrics?prefix=VM1.Node1"))
+}
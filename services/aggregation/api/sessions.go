@@ -0,0 +1,142 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+)
+
+const (
+	// sessionDuration is the validity of the frontend sessions
+	sessionDuration = 24 * time.Hour
+	sessionIDSize   = 16
+	// sessionIDKey and sessionRoleKey hold, in the gin context, the session of the request and the user role
+	sessionIDKey   = "sessionID"
+	sessionRoleKey = "sessionRole"
+
+	// the full paths of the logout endpoints, allowed for the read-only users
+	logoutPath    = "/auth/logout"
+	logoutAllPath = "/auth/logout-all"
+)
+
+func isLogoutPath(fullPath string) bool {
+	return isAPIPath(fullPath, logoutPath) || isAPIPath(fullPath, logoutAllPath)
+}
+
+// startSession records a new session for the authenticated user, returning its token
+func (s *server) startSession(c *gin.Context, claims sessionClaims) (string, error) {
+	idBytes := make([]byte, sessionIDSize)
+	_, err := rand.Read(idBytes)
+	if err != nil {
+		return "", err
+	}
+
+	now := s.clock.Now()
+	claims.Sid = hex.EncodeToString(idBytes)
+	claims.Exp = now.Add(sessionDuration).Unix()
+
+	err = s.storage.CreateSession(c.Request.Context(), common.Session{
+		ID:         claims.Sid,
+		Username:   claims.Sub,
+		Tenant:     claims.Tenant,
+		Role:       claims.Role,
+		OIDC:       claims.OIDC,
+		UserAgent:  c.Request.UserAgent(),
+		RemoteAddr: c.ClientIP(),
+		CreatedAt:  now.Unix(),
+		ExpiresAt:  claims.Exp,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return s.issueSessionToken(claims), nil
+}
+
+// isSessionActive checks that the session of the token was not revoked, writing the error response if it was
+func (s *server) isSessionActive(c *gin.Context, id string) bool {
+	session, err := s.storage.GetSession(c.Request.Context(), id)
+	if errors.Is(err, common.ErrSessionNotFound) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session expired"})
+		c.Abort()
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Abort()
+		return false
+	}
+	if !session.IsActive(s.clock.Now().Unix()) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session revoked"})
+		c.Abort()
+		return false
+	}
+
+	return true
+}
+
+// requireAdmin restricts the endpoints to the users with the admin role
+func (s *server) requireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString(sessionRoleKey) != common.RoleAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "forbidden for the read-only users"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// handleLogout revokes the session of the request
+func (s *server) handleLogout(c *gin.Context) {
+	err := s.storage.RevokeSession(c.Request.Context(), c.GetString(sessionIDKey), s.clock.Now().Unix())
+	if err != nil && !errors.Is(err, common.ErrSessionNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// handleLogoutAll revokes all the sessions of the user, e.g. after losing a device
+func (s *server) handleLogoutAll(c *gin.Context) {
+	username := c.GetString(sessionUserKey)
+	numRevoked, err := s.storage.RevokeUserSessions(c.Request.Context(), username, s.clock.Now().Unix())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Info("sessions revoked", "username", username, "num sessions", numRevoked)
+	c.JSON(http.StatusOK, gin.H{"revoked": numRevoked})
+}
+
+func (s *server) handleGetSessions(c *gin.Context) {
+	sessions, err := s.storage.GetActiveSessions(c.Request.Context(), s.clock.Now().Unix())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions, "current": c.GetString(sessionIDKey)})
+}
+
+func (s *server) handleRevokeSession(c *gin.Context) {
+	err := s.storage.RevokeSession(c.Request.Context(), c.Param("id"), s.clock.Now().Unix())
+	if errors.Is(err, common.ErrSessionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Info("session revoked", "id", c.Param("id"), "by", c.GetString(sessionUserKey))
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/commonGo/clock"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_Sessions(t *testing.T) {
+	serv, store := setupTestServer(t)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	doRequest := func(token string, method string, url string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, bytes.NewBuffer(nil))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+
+		return w
+	}
+	getSessions := func(token string) (string, []common.Session) {
+		w := doRequest(token, "GET", "/api/sessions")
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp struct {
+			Sessions []common.Session `json:"sessions"`
+			Current  string           `json:"current"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+		return resp.Current, resp.Sessions
+	}
+
+	token1 := getValidToken(serv)
+	token2 := getValidToken(serv)
+	token3 := getValidToken(serv)
+
+	current, sessions := getSessions(token1)
+	require.Len(t, sessions, 3)
+	assert.Equal(t, "admin", sessions[0].Username)
+	assert.Equal(t, common.RoleAdmin, sessions[0].Role)
+
+	// logout only revokes the current session
+	assert.Equal(t, http.StatusOK, doRequest(token1, "POST", "/api/auth/logout").Code)
+	w := doRequest(token1, "GET", "/api/metrics")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.JSONEq(t, `{"error":"session revoked"}`, w.Body.String())
+	assert.Equal(t, http.StatusOK, doRequest(token2, "GET", "/api/metrics").Code)
+
+	// the admins can revoke the other sessions
+	current, sessions = getSessions(token2)
+	require.Len(t, sessions, 2)
+	var otherID string
+	for _, session := range sessions {
+		if session.ID != current {
+			otherID = session.ID
+		}
+	}
+	require.NotEmpty(t, otherID)
+	assert.Equal(t, http.StatusOK, doRequest(token2, "DELETE", "/api/sessions/"+otherID).Code)
+	assert.Equal(t, http.StatusNotFound, doRequest(token2, "DELETE", "/api/sessions/"+otherID).Code)
+	assert.Equal(t, http.StatusUnauthorized, doRequest(token3, "GET", "/api/metrics").Code)
+
+	// the tokens without a known session are rejected
+	claims := sessionClaims{Sid: "unknown", Sub: "admin", Role: common.RoleAdmin, Exp: time.Now().Add(time.Hour).Unix()}
+	w = doRequest(serv.issueSessionToken(claims), "GET", "/api/metrics")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.JSONEq(t, `{"error":"session expired"}`, w.Body.String())
+
+	// the viewers can log out but not list the sessions
+	viewerClaims := sessionClaims{Sid: "viewer-session", Sub: "jane@example.com", Role: common.RoleViewer, OIDC: true, Exp: claims.Exp}
+	require.NoError(t, store.CreateSession(context.Background(), common.Session{ID: viewerClaims.Sid, Username: viewerClaims.Sub, Role: viewerClaims.Role, ExpiresAt: viewerClaims.Exp}))
+	viewerToken := serv.issueSessionToken(viewerClaims)
+	assert.Equal(t, http.StatusForbidden, doRequest(viewerToken, "GET", "/api/sessions").Code)
+	assert.Equal(t, http.StatusOK, doRequest(viewerToken, "POST", "/api/auth/logout").Code)
+	assert.Equal(t, http.StatusUnauthorized, doRequest(viewerToken, "GET", "/api/metrics").Code)
+
+	// log out everywhere
+	token4 := getValidToken(serv)
+	w = doRequest(token4, "POST", "/api/auth/logout-all")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"revoked":2}`, w.Body.String())
+	assert.Equal(t, http.StatusUnauthorized, doRequest(token2, "GET", "/api/metrics").Code)
+	assert.Equal(t, http.StatusUnauthorized, doRequest(token4, "GET", "/api/metrics").Code)
+}
+
+func TestServer_SessionExpiry(t *testing.T) {
+	serv, store := setupTestServer(t)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	clk := clock.NewManualClock(time.Now())
+	serv.clock = clk
+	token := getValidToken(serv)
+
+	getMetrics := func() *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/api/metrics", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+
+		return w
+	}
+
+	clk.Advance(sessionDuration)
+	assert.Equal(t, http.StatusOK, getMetrics().Code)
+
+	clk.Advance(time.Second)
+	w := getMetrics()
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.JSONEq(t, `{"error":"token expired"}`, w.Body.String())
+}
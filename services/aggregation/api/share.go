@@ -0,0 +1,188 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+)
+
+const (
+	// shareTokenScope is the scope claim of the share tokens so they can not be confused with the session tokens
+	shareTokenScope = "share"
+	// shareTokenHeader can carry the share token as an alternative to the token query parameter
+	shareTokenHeader = "X-Share-Token"
+	// sharedDashboardKey holds, in the gin context, the dashboard ID the share token is restricted to (0 means all)
+	sharedDashboardKey = "sharedDashboard"
+
+	defaultShareTokenTTL = 7 * 24 * time.Hour
+	maxShareTokenTTL     = 365 * 24 * time.Hour
+)
+
+type shareClaims struct {
+	Scope     string `json:"scope"`
+	Dashboard int64  `json:"dashboard,omitempty"`
+	Exp       int64  `json:"exp"`
+}
+
+// deriveShareSecret derives the share tokens signing key from the service key, so the shared links
+// survive the restarts. Rotating the service key revokes all the issued links.
+func deriveShareSecret(serviceKey string) []byte {
+	h := hmac.New(sha256.New, []byte(serviceKey))
+	h.Write([]byte("share-tokens"))
+
+	return h.Sum(nil)
+}
+
+func (s *server) signShareToken(claims shareClaims) (string, error) {
+	claimsBytes, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(claimsBytes)
+	macd := hmac.New(sha256.New, s.shareSecret)
+	macd.Write([]byte(payload))
+
+	return payload + "." + base64.RawURLEncoding.EncodeToString(macd.Sum(nil)), nil
+}
+
+func (s *server) verifyShareToken(token string) (*shareClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return nil, errors.New("invalid share token")
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errors.New("invalid share token sign")
+	}
+
+	macd := hmac.New(sha256.New, s.shareSecret)
+	macd.Write([]byte(parts[0]))
+	if !hmac.Equal(sig, macd.Sum(nil)) {
+		return nil, errors.New("unauthorized")
+	}
+
+	claimsBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, errors.New("invalid share token")
+	}
+	claims := &shareClaims{}
+	err = json.Unmarshal(claimsBytes, claims)
+	if err != nil || claims.Scope != shareTokenScope {
+		return nil, errors.New("invalid share token")
+	}
+	if s.clock.Now().Unix() > claims.Exp {
+		return nil, errors.New("share token expired")
+	}
+
+	return claims, nil
+}
+
+// authShareToken grants read-only access to the requests carrying a valid share token, either in the
+// token query parameter or in the X-Share-Token header
+func (s *server) authShareToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Query("token")
+		if token == "" {
+			token = c.GetHeader(shareTokenHeader)
+		}
+		if token == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "missing share token"})
+			c.Abort()
+			return
+		}
+
+		claims, err := s.verifyShareToken(token)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+
+		c.Set(sharedDashboardKey, claims.Dashboard)
+		c.Next()
+	}
+}
+
+func (s *server) handleCreateShareToken(c *gin.Context) {
+	var req struct {
+		DashboardID int64 `json:"dashboardId"`
+		TTLInSec    int64 `json:"ttlInSec"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+
+	ttl := time.Duration(req.TTLInSec) * time.Second
+	if req.TTLInSec == 0 {
+		ttl = defaultShareTokenTTL
+	}
+	if ttl <= 0 || ttl > maxShareTokenTTL {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid share token ttl"})
+		return
+	}
+
+	if req.DashboardID != 0 {
+		_, err := s.storage.GetDashboard(c.Request.Context(), req.DashboardID)
+		if errors.Is(err, common.ErrDashboardNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	expiresAt := s.clock.Now().Add(ttl).Unix()
+	token, err := s.signShareToken(shareClaims{
+		Scope:     shareTokenScope,
+		Dashboard: req.DashboardID,
+		Exp:       expiresAt,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Info("issued share token", "dashboard", req.DashboardID, "expires at", expiresAt)
+
+	c.JSON(http.StatusOK, gin.H{"token": token, "expiresAt": expiresAt})
+}
+
+// requestedDashboard returns the dashboard the metrics are restricted to. The share tokens bound to a dashboard
+// take precedence over the dashboard query parameter. A nil dashboard (with no error) means no restriction.
+func (s *server) requestedDashboard(c *gin.Context) (*common.Dashboard, int, error) {
+	id := c.GetInt64(sharedDashboardKey)
+	if id == 0 && len(c.Query("dashboard")) > 0 {
+		var err error
+		id, err = strconv.ParseInt(c.Query("dashboard"), 10, 64)
+		if err != nil {
+			return nil, http.StatusBadRequest, errors.New("invalid dashboard id")
+		}
+	}
+	if id == 0 {
+		return nil, http.StatusOK, nil
+	}
+
+	dashboard, err := s.storage.GetDashboard(c.Request.Context(), id)
+	if errors.Is(err, common.ErrDashboardNotFound) {
+		return nil, http.StatusNotFound, err
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return dashboard, http.StatusOK, nil
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/stretchr/testify/require"
+)
+
+func createShareToken(t *testing.T, serv *server, body string) string {
+	req, _ := http.NewRequest("POST", "/api/share", bytes.NewBuffer([]byte(body)))
+	req.Header.Set("Authorization", "Bearer "+getValidToken(serv))
+	w := httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Token     string `json:"token"`
+		ExpiresAt int64  `json:"expiresAt"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.NotEmpty(t, resp.Token)
+	require.Greater(t, resp.ExpiresAt, time.Now().Unix())
+
+	return resp.Token
+}
+
+func sendPublicRequest(serv *server, method string, url string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, url, nil)
+	w := httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestServer_ShareTokens(t *testing.T) {
+	serv, store := setupTestServer(t)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	ctx := context.Background()
+	now := time.Now().Unix()
+	_ = store.SaveMetric(ctx, "VM1.Node1.nonce", "uint64", 10, "1", now)
+	_ = store.SaveMetric(ctx, "VM2.Node1.nonce", "uint64", 10, "1", now)
+	dashboardID, _ := store.CreateDashboard(ctx, common.Dashboard{Name: "mainnet", Panels: []string{"VM1"}})
+
+	t.Run("creating a share token requires authentication", func(t *testing.T) {
+		w := sendPublicRequest(serv, "POST", "/api/share")
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+	t.Run("invalid share token requests should error", func(t *testing.T) {
+		for _, body := range []string{`{bad}`, `{"ttlInSec":-1}`, `{"ttlInSec":100000000}`} {
+			req, _ := http.NewRequest("POST", "/api/share", bytes.NewBuffer([]byte(body)))
+			req.Header.Set("Authorization", "Bearer "+getValidToken(serv))
+			w := httptest.NewRecorder()
+			serv.router.ServeHTTP(w, req)
+			require.Equal(t, http.StatusBadRequest, w.Code, body)
+		}
+
+		req, _ := http.NewRequest("POST", "/api/share", bytes.NewBuffer([]byte(`{"dashboardId":1000}`)))
+		req.Header.Set("Authorization", "Bearer "+getValidToken(serv))
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusNotFound, w.Code)
+	})
+	t.Run("public endpoints require a valid share token", func(t *testing.T) {
+		require.Equal(t, http.StatusUnauthorized, sendPublicRequest(serv, "GET", "/api/public/metrics").Code)
+		require.Equal(t, http.StatusUnauthorized, sendPublicRequest(serv, "GET", "/api/public/metrics?token=abc.def").Code)
+
+		// session tokens are not share tokens
+		url := "/api/public/metrics?token=" + getValidToken(serv)
+		require.Equal(t, http.StatusUnauthorized, sendPublicRequest(serv, "GET", url).Code)
+
+		expired, _ := serv.signShareToken(shareClaims{Scope: shareTokenScope, Exp: now - 1})
+		w := sendPublicRequest(serv, "GET", "/api/public/metrics?token="+expired)
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+		require.Contains(t, w.Body.String(), "expired")
+
+		// share tokens are not session tokens
+		req, _ := http.NewRequest("GET", "/api/metrics", nil)
+		req.Header.Set("Authorization", "Bearer "+createShareToken(t, serv, `{}`))
+		w = httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+	t.Run("share token grants read-only access", func(t *testing.T) {
+		token := createShareToken(t, serv, `{"ttlInSec":60}`)
+
+		w := sendPublicRequest(serv, "GET", "/api/public/metrics?token="+token)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Contains(t, w.Body.String(), "VM1.Node1.nonce")
+		require.Contains(t, w.Body.String(), "VM2.Node1.nonce")
+
+		req, _ := http.NewRequest("GET", "/api/public/metrics/VM2.Node1.nonce/history", nil)
+		req.Header.Set(shareTokenHeader, token)
+		w = httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		w = sendPublicRequest(serv, "DELETE", "/api/public/metrics/VM2.Node1.nonce?token="+token)
+		require.Equal(t, http.StatusNotFound, w.Code)
+		w = sendPublicRequest(serv, "DELETE", "/api/metrics/VM2.Node1.nonce?token="+token)
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+	t.Run("dashboard share token is restricted to the dashboard", func(t *testing.T) {
+		token := createShareToken(t, serv, fmt.Sprintf(`{"dashboardId":%d}`, dashboardID))
+
+		w := sendPublicRequest(serv, "GET", "/api/public/metrics?dashboard=1000&token="+token)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Contains(t, w.Body.String(), "VM1.Node1.nonce")
+		require.NotContains(t, w.Body.String(), "VM2.Node1.nonce")
+
+		w = sendPublicRequest(serv, "GET", "/api/public/metrics/VM1.Node1.nonce/history?token="+token)
+		require.Equal(t, http.StatusOK, w.Code)
+		w = sendPublicRequest(serv, "GET", "/api/public/metrics/VM2.Node1.nonce/history?token="+token)
+		require.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
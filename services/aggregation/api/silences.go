@@ -0,0 +1,106 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+)
+
+// handleGetSilences lists the active and the upcoming silences or, with all=true, the expired ones as well
+func (s *server) handleGetSilences(c *gin.Context) {
+	endsAfter := time.Now().Unix()
+	if c.Query("all") == "true" {
+		endsAfter = 0
+	}
+
+	silences, err := s.storage.GetSilences(c.Request.Context(), endsAfter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"silences": silences})
+}
+
+// handleCreateSilence defines a silence for the metrics matching a pattern. The silence starts now if startsAt is
+// not provided and ends at endsAt or after durationInSec.
+func (s *server) handleCreateSilence(c *gin.Context) {
+	var req struct {
+		Pattern       string `json:"pattern"`
+		StartsAt      int64  `json:"startsAt"`
+		EndsAt        int64  `json:"endsAt"`
+		DurationInSec int64  `json:"durationInSec"`
+		Comment       string `json:"comment"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	if len(req.Pattern) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "pattern is required"})
+		return
+	}
+	if _, err := path.Match(req.Pattern, ""); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid pattern: " + err.Error()})
+		return
+	}
+
+	now := time.Now().Unix()
+	silence := common.Silence{
+		Pattern:   req.Pattern,
+		StartsAt:  req.StartsAt,
+		EndsAt:    req.EndsAt,
+		Comment:   req.Comment,
+		CreatedBy: c.GetString(sessionUserKey),
+		CreatedAt: now,
+	}
+	if silence.StartsAt == 0 {
+		silence.StartsAt = now
+	}
+	if silence.EndsAt == 0 {
+		silence.EndsAt = silence.StartsAt + req.DurationInSec
+	}
+	duration := time.Duration(silence.EndsAt-silence.StartsAt) * time.Second
+	if duration <= 0 || duration > maxSilenceDuration {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the silence must last between 1 and " + strconv.Itoa(int(maxSilenceDuration.Seconds())) + " seconds"})
+		return
+	}
+	if silence.EndsAt <= now {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the silence must end in the future"})
+		return
+	}
+
+	id, err := s.storage.CreateSilence(c.Request.Context(), silence)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true, "id": id})
+}
+
+// handleExpireSilence ends a silence now, keeping it in the history
+func (s *server) handleExpireSilence(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid silence id"})
+		return
+	}
+
+	err = s.storage.ExpireSilence(c.Request.Context(), id, time.Now().Unix())
+	if errors.Is(err, common.ErrSilenceNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
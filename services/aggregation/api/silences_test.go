@@ -0,0 +1,87 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_Silences(t *testing.T) {
+	serv, store := setupTestServer(t)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	token := getValidToken(serv)
+	doRequest := func(method string, url string, body string) *httptest.ResponseRecorder {
+		var reader io.Reader
+		if len(body) > 0 {
+			reader = bytes.NewBufferString(body)
+		}
+		req, _ := http.NewRequest(method, url, reader)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+
+		return w
+	}
+	getSilences := func(url string) []common.Silence {
+		w := doRequest("GET", url, "")
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp struct {
+			Silences []common.Silence `json:"silences"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+		return resp.Silences
+	}
+
+	// unauthenticated
+	req, _ := http.NewRequest("GET", "/api/silences", nil)
+	w := httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	assert.Equal(t, http.StatusBadRequest, doRequest("POST", "/api/silences", `{"durationInSec":60}`).Code)
+	assert.Equal(t, http.StatusBadRequest, doRequest("POST", "/api/silences", `{"pattern":"VM1.[","durationInSec":60}`).Code)
+	assert.Equal(t, http.StatusBadRequest, doRequest("POST", "/api/silences", `{"pattern":"VM1.*"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, doRequest("POST", "/api/silences", `{"pattern":"VM1.*","durationInSec":99999999}`).Code)
+	assert.Equal(t, http.StatusBadRequest, doRequest("POST", "/api/silences", `{"pattern":"VM1.*","startsAt":1000,"endsAt":2000}`).Code)
+
+	w = doRequest("POST", "/api/silences", `{"pattern":"VM1.*","durationInSec":3600,"comment":"node upgrade"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	var createResp struct {
+		ID int64 `json:"id"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &createResp))
+
+	startsAt := time.Now().Unix() + 7200
+	body := fmt.Sprintf(`{"pattern":"VM2.*","startsAt":%d,"endsAt":%d}`, startsAt, startsAt+600)
+	require.Equal(t, http.StatusOK, doRequest("POST", "/api/silences", body).Code)
+
+	silences := getSilences("/api/silences")
+	require.Len(t, silences, 2)
+	assert.Equal(t, "VM2.*", silences[0].Pattern)
+	assert.Equal(t, createResp.ID, silences[1].ID)
+	assert.Equal(t, "node upgrade", silences[1].Comment)
+	assert.Equal(t, "admin", silences[1].CreatedBy)
+
+	// expire
+	assert.Equal(t, http.StatusBadRequest, doRequest("DELETE", "/api/silences/abc", "").Code)
+	assert.Equal(t, http.StatusNotFound, doRequest("DELETE", "/api/silences/100", "").Code)
+	require.Equal(t, http.StatusOK, doRequest("DELETE", fmt.Sprintf("/api/silences/%d", createResp.ID), "").Code)
+
+	silences = getSilences("/api/silences")
+	require.Len(t, silences, 1)
+	assert.Equal(t, "VM2.*", silences[0].Pattern)
+	assert.Len(t, getSilences("/api/silences?all=true"), 2)
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/sla"
+)
+
+// handleGetSLA serves the availability of the agents and of their metrics over the last 24h, 7d and 30d. The
+// window parameter restricts the report to one window and the agent parameter to one agent.
+func (s *server) handleGetSLA(c *gin.Context) {
+	windows := sla.Windows
+	if len(c.Query("window")) > 0 {
+		windows = nil
+		for _, window := range sla.Windows {
+			if window.Name == c.Query("window") {
+				windows = []sla.Window{window}
+			}
+		}
+		if len(windows) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "window must be one of 24h, 7d or 30d"})
+			return
+		}
+	}
+
+	now := time.Now().Unix()
+	buckets, err := s.storage.GetAvailabilityBuckets(c.Request.Context(), now-common.MaxAvailabilityWindowSeconds-common.AvailabilityBucketSeconds)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	tenant := c.GetString(sessionTenantKey)
+	if len(tenant) > 0 {
+		metrics, errMetrics := s.tenantMetrics(c, tenant)
+		if errMetrics != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": errMetrics.Error()})
+			return
+		}
+
+		tenantBuckets := make([]common.AvailabilityBucket, 0, len(buckets))
+		for _, bucket := range buckets {
+			if _, found := metrics[bucket.MetricName]; found {
+				tenantBuckets = append(tenantBuckets, bucket)
+			}
+		}
+		buckets = tenantBuckets
+	}
+
+	c.JSON(http.StatusOK, sla.ComputeReport(buckets, windows, c.Query("agent"), now))
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_SLA(t *testing.T) {
+	serv, store := setupTestServer(t)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	recordedAt := time.Now().Unix() - 2*common.AvailabilityBucketSeconds
+	require.NoError(t, store.SaveMetric(context.Background(), "VM1.Active", common.MetricTypeBool, 10, "true", recordedAt))
+
+	req, _ := http.NewRequest("GET", "/api/sla", nil)
+	w := httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	token := getValidToken(serv)
+	req, _ = http.NewRequest("GET", "/api/sla?window=1y", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	req, _ = http.NewRequest("GET", "/api/sla?window=7d", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	report := common.SLAReport{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	require.Len(t, report.Agents, 1)
+	assert.Equal(t, "VM1", report.Agents[0].Name)
+	week := report.Agents[0].Availability["7d"]
+	assert.Equal(t, int64(7*24*3600), week.WindowInSec)
+	assert.Greater(t, week.UpSeconds, int64(0))
+	require.Len(t, report.Metrics, 1)
+}
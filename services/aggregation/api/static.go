@@ -0,0 +1,82 @@
+package api
+
+import (
+	"io/fs"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const indexFile = "index.html"
+
+// staticPrefixes are the paths of the build files, never served with the client-side routes fallback
+var staticPrefixes = []string{"/_expo/", "/assets/"}
+
+// setupStaticRoutes serves the frontend build: the one embedded in the binary or, if StaticDir is configured, the one
+// found in the directory (the development builds)
+func (s *server) setupStaticRoutes() {
+	if s.staticFS == nil {
+		return
+	}
+
+	log.Info("serving static files")
+	s.router.StaticFS("/_expo", s.staticSubFS("_expo"))
+	s.router.StaticFS("/assets", s.staticSubFS("assets"))
+	s.router.GET("/favicon.ico", func(c *gin.Context) {
+		c.FileFromFS("favicon.ico", http.FS(s.staticFS))
+	})
+
+	// NoRoute for SPA fallback
+	s.router.NoRoute(func(c *gin.Context) {
+		// If request is for an /api route that doesn't exist, return 404
+		if strings.HasPrefix(c.Request.URL.Path, "/api") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "api route not found"})
+			return
+		}
+
+		for _, prefix := range staticPrefixes {
+			if strings.HasPrefix(c.Request.URL.Path, prefix) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+				return
+			}
+		}
+
+		// Fallback to index.html for all client-side routes. The file is written directly as the file server
+		// redirects the requests for index.html to the directory.
+		index, err := fs.ReadFile(s.staticFS, indexFile)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "frontend not found"})
+			return
+		}
+		c.Data(http.StatusOK, "text/html; charset=utf-8", index)
+	})
+}
+
+func (s *server) staticSubFS(dir string) http.FileSystem {
+	// fs.Sub only fails for the invalid directory names
+	sub, _ := fs.Sub(s.staticFS, dir)
+
+	return noDirListingFS{http.FS(sub)}
+}
+
+// noDirListingFS hides the directories so that their content is not listed
+type noDirListingFS struct {
+	http.FileSystem
+}
+
+// Open returns the file, the directories are reported as not found
+func (noListing noDirListingFS) Open(name string) (http.File, error) {
+	file, err := noListing.FileSystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		_ = file.Close()
+		return nil, fs.ErrNotExist
+	}
+
+	return file, nil
+}
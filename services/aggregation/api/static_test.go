@@ -0,0 +1,71 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_StaticFiles(t *testing.T) {
+	t.Parallel()
+
+	store, err := storage.NewSQLiteStorage(":memory:", 100, storage.SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	args := ArgsWebServer{
+		ServiceKeyApi:  "test-secret",
+		AuthUsername:   "admin",
+		AuthPassword:   "password",
+		ListenAddress:  ":0",
+		Storage:        store,
+		GeneralHandler: func(h http.Handler) http.Handler { return h },
+		StaticFS: fstest.MapFS{
+			"index.html":              {Data: []byte("<html>dashboard</html>")},
+			"favicon.ico":             {Data: []byte("icon")},
+			"_expo/static/js/app.js":  {Data: []byte("app")},
+			"assets/images/logo.png":  {Data: []byte("logo")},
+			"assets/images/other.png": {Data: []byte("other")},
+		},
+	}
+	serv, err := NewServer(args)
+	require.NoError(t, err)
+
+	doRequest := func(url string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+
+		return w
+	}
+
+	w := doRequest("/_expo/static/js/app.js")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "app", w.Body.String())
+	assert.Equal(t, "logo", doRequest("/assets/images/logo.png").Body.String())
+	assert.Equal(t, "icon", doRequest("/favicon.ico").Body.String())
+	assert.Equal(t, http.StatusNotFound, doRequest("/assets/images/").Code)
+	assert.Equal(t, http.StatusNotFound, doRequest("/_expo/missing.js").Code)
+
+	// the client-side routes get the index
+	for _, url := range []string{"/", "/login", "/management"} {
+		w = doRequest(url)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "<html>dashboard</html>", w.Body.String())
+	}
+	assert.Equal(t, http.StatusNotFound, doRequest("/api/missing").Code)
+
+	// StaticDir overrides the embedded build
+	args.StaticDir = t.TempDir()
+	serv, err = NewServer(args)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, doRequest("/favicon.ico").Code)
+	assert.Equal(t, http.StatusNotFound, doRequest("/login").Code)
+}
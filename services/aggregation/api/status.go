@@ -0,0 +1,215 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+)
+
+const (
+	defaultStatusPageTitle       = "Status"
+	defaultStatusPageCacheMaxAge = 30 * time.Second
+	defaultStaleSeconds          = 300
+)
+
+var statusPageTemplate = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif; margin: 2em auto; max-width: 640px; color: #1f2937; }
+table { width: 100%; border-collapse: collapse; }
+td, th { padding: 8px; border-bottom: 1px solid #e5e7eb; text-align: left; }
+.up { color: #10b981; } .down { color: #ef4444; } .degraded { color: #f59e0b; } .unknown { color: #6b7280; }
+</style>
+</head>
+<body>
+<h1>{{.Title}} <span class="{{.Status}}">{{.Status}}</span></h1>
+<table>
+<tr><th>Agent</th><th>Status</th><th>Last report</th></tr>
+{{range .Agents}}<tr><td>{{.Name}}</td><td class="{{.Status}}">{{.Status}}</td><td>{{.LastReportAgeInSec}}s ago</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// cachedStatus holds the last computed status page together with its entity tag
+type cachedStatus struct {
+	summary *common.StatusSummary
+	json    []byte
+	etag    string
+	expires time.Time
+}
+
+// handleStatus serves the per-agent health summary as JSON or, for the browsers, as a minimal HTML page.
+// The summary is computed at most once per cache interval and can be revalidated with If-None-Match.
+func (s *server) handleStatus(c *gin.Context) {
+	status, err := s.getStatus(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	format := c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML)
+	if c.Query("format") == "html" {
+		format = gin.MIMEHTML
+	}
+	etag := status.etag
+	if format == gin.MIMEHTML {
+		etag = `"html-` + etag[1:]
+	}
+
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(s.statusPageCacheMaxAge.Seconds())))
+	c.Header("ETag", etag)
+	c.Header("Vary", "Accept")
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	if format == gin.MIMEHTML {
+		buff := &bytes.Buffer{}
+		err = statusPageTemplate.Execute(buff, status.summary)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Data(http.StatusOK, "text/html; charset=utf-8", buff.Bytes())
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json; charset=utf-8", status.json)
+}
+
+func (s *server) getStatus(c *gin.Context) (*cachedStatus, error) {
+	s.mutStatus.Lock()
+	defer s.mutStatus.Unlock()
+
+	now := time.Now()
+	if s.status != nil && now.Before(s.status.expires) {
+		return s.status, nil
+	}
+
+	latest, err := s.storage.GetLatestMetrics(c.Request.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	summary := s.computeStatusSummary(latest, now.Unix())
+	jsonBytes, err := json.Marshal(summary)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.Sum256(jsonBytes)
+	s.status = &cachedStatus{
+		summary: summary,
+		json:    jsonBytes,
+		etag:    `"` + hex.EncodeToString(hash[:16]) + `"`,
+		expires: now.Add(s.statusPageCacheMaxAge),
+	}
+
+	return s.status, nil
+}
+
+// staleSeconds returns the age after which a metric, or an agent heartbeat, is considered stale
+func (s *server) staleSeconds() int64 {
+	if s.numSecondsToConsiderStale <= 0 {
+		return defaultStaleSeconds
+	}
+
+	return int64(s.numSecondsToConsiderStale)
+}
+
+// computeStatusSummary groups the metrics by agent (VM). An agent is up if its heartbeat metric is true and fresh or,
+// for the agents without a heartbeat metric, if any of its metrics is fresh.
+func (s *server) computeStatusSummary(latest []common.MetricHistory, now int64) *common.StatusSummary {
+	staleSeconds := s.staleSeconds()
+
+	agents := make(map[string]*common.AgentStatus)
+	heartbeats := make(map[string]common.MetricValue)
+	for _, metric := range latest {
+		if len(metric.History) == 0 || metric.History[0].RecordedAt == 0 {
+			continue
+		}
+
+		tags := metric.Tags
+		if tags == nil {
+			tags = common.ParseTagsFromName(metric.Name)
+		}
+		vm := tags[common.TagVM]
+		if vm == "" {
+			continue
+		}
+
+		agent, found := agents[vm]
+		if !found {
+			agent = &common.AgentStatus{Name: vm}
+			agents[vm] = agent
+		}
+
+		lastValue := metric.History[0]
+		if lastValue.RecordedAt > agent.LastReportAt {
+			agent.LastReportAt = lastValue.RecordedAt
+		}
+		if metric.Name == vm+"."+common.HeartbeatMetricSuffix {
+			heartbeats[vm] = lastValue
+		}
+	}
+
+	summary := &common.StatusSummary{
+		Title:       s.statusPageTitle,
+		GeneratedAt: now,
+		Agents:      make([]common.AgentStatus, 0, len(agents)),
+	}
+
+	numUp := 0
+	for vm, agent := range agents {
+		agent.LastReportAgeInSec = now - agent.LastReportAt
+		agent.Status = common.StatusDown
+
+		heartbeat, hasHeartbeat := heartbeats[vm]
+		switch {
+		case hasHeartbeat:
+			if heartbeat.Value == "true" && now-heartbeat.RecordedAt < staleSeconds {
+				agent.Status = common.StatusUp
+			}
+		case agent.LastReportAgeInSec < staleSeconds:
+			agent.Status = common.StatusUp
+		}
+		if agent.Status == common.StatusUp {
+			numUp++
+		}
+
+		summary.Agents = append(summary.Agents, *agent)
+	}
+
+	sort.Slice(summary.Agents, func(i, j int) bool {
+		return summary.Agents[i].Name < summary.Agents[j].Name
+	})
+
+	switch {
+	case len(agents) == 0:
+		summary.Status = common.StatusUnknown
+	case numUp == len(agents):
+		summary.Status = common.StatusUp
+	case numUp == 0:
+		summary.Status = common.StatusDown
+	default:
+		summary.Status = common.StatusDegraded
+	}
+
+	return summary
+}
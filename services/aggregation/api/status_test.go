@@ -0,0 +1,134 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/testsCommon"
+	"github.com/stretchr/testify/require"
+)
+
+func createStatusServer(t *testing.T, store Storage, enabled bool) *server {
+	serv, err := NewServer(ArgsWebServer{
+		ServiceKeyApi:              "test-secret",
+		Storage:                    store,
+		GeneralHandler:             func(h http.Handler) http.Handler { return h },
+		NumSecondsToConsiderStale:  60,
+		StatusPageEnabled:          enabled,
+		StatusPageTitle:            "Nodes",
+		StatusPageCacheMaxAgeInSec: 10,
+	})
+	require.NoError(t, err)
+
+	return serv
+}
+
+func getStatus(serv *server, accept string, etag string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", "/status", nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	w := httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestServer_Status(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now().Unix()
+	numCalls := 0
+	store := &testsCommon.StoreStub{
+		GetLatestMetricsHandler: func(ctx context.Context) ([]common.MetricHistory, error) {
+			numCalls++
+			return []common.MetricHistory{
+				{Name: "VM1.Active", History: []common.MetricValue{{Value: "true", RecordedAt: now - 5}}},
+				{Name: "VM1.Node1.nonce", History: []common.MetricValue{{Value: "10", RecordedAt: now - 2}}},
+				{Name: "VM2.Active", History: []common.MetricValue{{Value: "true", RecordedAt: now - 600}}},
+				{Name: "VM3.Node1.nonce", History: []common.MetricValue{{Value: "10", RecordedAt: now - 1}}},
+				{Name: "VM4.Active", History: []common.MetricValue{{Value: "false", RecordedAt: now - 1}}},
+				{Name: "never-reported", History: []common.MetricValue{{Value: "", RecordedAt: 0}}},
+			}, nil
+		},
+	}
+
+	t.Run("disabled status page should not be served", func(t *testing.T) {
+		t.Parallel()
+
+		serv := createStatusServer(t, &testsCommon.StoreStub{}, false)
+		require.Equal(t, http.StatusNotFound, getStatus(serv, "", "").Code)
+	})
+	t.Run("storage error should error", func(t *testing.T) {
+		t.Parallel()
+
+		serv := createStatusServer(t, &testsCommon.StoreStub{
+			GetLatestMetricsHandler: func(ctx context.Context) ([]common.MetricHistory, error) {
+				return nil, errors.New("expected error")
+			},
+		}, true)
+		require.Equal(t, http.StatusInternalServerError, getStatus(serv, "", "").Code)
+	})
+	t.Run("should summarize the agents health", func(t *testing.T) {
+		t.Parallel()
+
+		serv := createStatusServer(t, store, true)
+		w := getStatus(serv, "application/json", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "public, max-age=10", w.Header().Get("Cache-Control"))
+
+		summary := common.StatusSummary{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &summary))
+		require.Equal(t, "Nodes", summary.Title)
+		require.Equal(t, common.StatusDegraded, summary.Status)
+		require.Equal(t, []common.AgentStatus{
+			{Name: "VM1", Status: common.StatusUp, LastReportAt: now - 2, LastReportAgeInSec: summary.GeneratedAt - now + 2},
+			{Name: "VM2", Status: common.StatusDown, LastReportAt: now - 600, LastReportAgeInSec: summary.GeneratedAt - now + 600},
+			{Name: "VM3", Status: common.StatusUp, LastReportAt: now - 1, LastReportAgeInSec: summary.GeneratedAt - now + 1},
+			{Name: "VM4", Status: common.StatusDown, LastReportAt: now - 1, LastReportAgeInSec: summary.GeneratedAt - now + 1},
+		}, summary.Agents)
+
+		// cached and revalidated
+		etag := w.Header().Get("ETag")
+		require.NotEmpty(t, etag)
+		w = getStatus(serv, "application/json", etag)
+		require.Equal(t, http.StatusNotModified, w.Code)
+		require.Equal(t, 1, numCalls)
+
+		w = getStatus(serv, "text/html,application/xhtml+xml", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Contains(t, w.Header().Get("Content-Type"), "text/html")
+		require.NotEqual(t, etag, w.Header().Get("ETag"))
+		require.Contains(t, w.Body.String(), "<td>VM2</td>")
+		require.Equal(t, 1, numCalls)
+	})
+}
+
+func TestServer_ComputeStatusSummary(t *testing.T) {
+	t.Parallel()
+
+	serv := createStatusServer(t, &testsCommon.StoreStub{}, true)
+
+	summary := serv.computeStatusSummary(nil, 1000)
+	require.Equal(t, common.StatusUnknown, summary.Status)
+	require.Empty(t, summary.Agents)
+
+	summary = serv.computeStatusSummary([]common.MetricHistory{
+		{Name: "VM1.Active", History: []common.MetricValue{{Value: "true", RecordedAt: 999}}},
+	}, 1000)
+	require.Equal(t, common.StatusUp, summary.Status)
+
+	summary = serv.computeStatusSummary([]common.MetricHistory{
+		{Name: "VM1.Active", History: []common.MetricValue{{Value: "true", RecordedAt: 900}}},
+	}, 1000)
+	require.Equal(t, common.StatusDown, summary.Status)
+}
@@ -0,0 +1,193 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+)
+
+// sessionTenantKey holds, in the gin context, the tenant of the reporting agent or of the logged in user. The default
+// tenant is empty.
+const sessionTenantKey = "sessionTenant"
+
+// Tenant defines an organization sharing the aggregation instance. Its agents report with the tenant API key and
+// its user only sees the tenant metrics.
+type Tenant struct {
+	Name          string
+	ServiceKeyApi string
+	Username      string
+	Password      string
+}
+
+func (s *server) setTenants(tenants []Tenant) error {
+	s.tenantsByKey = make(map[string]string, len(tenants))
+	s.tenantsByUser = make(map[string]Tenant, len(tenants))
+	for _, tenant := range tenants {
+		if len(tenant.Name) == 0 {
+			return errors.New("empty tenant name")
+		}
+		if tenant.ServiceKeyApi == s.serviceKey {
+			return errors.New("tenant " + tenant.Name + " can not use the service API key")
+		}
+		if tenant.Username == s.username {
+			return errors.New("tenant " + tenant.Name + " can not use the admin username")
+		}
+
+		s.tenantsByKey[tenant.ServiceKeyApi] = tenant.Name
+		s.tenantsByUser[tenant.Username] = tenant
+	}
+
+	return nil
+}
+
+// tenantOfAPIKey returns the tenant the API key was issued for, the service API key belonging to the default tenant
+func (s *server) tenantOfAPIKey(key string) (string, bool) {
+	if key == s.serviceKey {
+		return "", true
+	}
+
+	tenant, found := s.tenantsByKey[key]
+	return tenant, found
+}
+
+// metricNamespace returns the tenant owning the namespace of a stored metric name, empty for the default tenant
+func (s *server) metricNamespace(name string) string {
+	prefix, _, found := strings.Cut(name, common.TenantNameSeparator)
+	if found && s.isTenant(prefix) {
+		return prefix
+	}
+
+	return ""
+}
+
+// isTenant returns true if the tenant is configured
+func (s *server) isTenant(name string) bool {
+	for _, tenant := range s.tenantsByUser {
+		if tenant.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// authenticate checks the login credentials, returning the tenant of the user
+func (s *server) authenticate(username string, password string) (string, bool) {
+	if username == s.username && password == s.password {
+		return "", true
+	}
+
+	tenant, found := s.tenantsByUser[username]
+	if !found || tenant.Password != password {
+		return "", false
+	}
+
+	return tenant.Name, true
+}
+
+// requireDefaultTenant restricts the instance-wide endpoints (silences, dashboards, administration) to the users of
+// the default tenant
+func (s *server) requireDefaultTenant() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(c.GetString(sessionTenantKey)) > 0 {
+			c.JSON(http.StatusForbidden, gin.H{"error": "forbidden for the tenant users"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// authorizeMetric checks that the metric is visible to the tenant of the session, writing the error response if not.
+// The metrics of the other tenants are reported as not found.
+func (s *server) authorizeMetric(c *gin.Context, name string) bool {
+	visible, err := s.isMetricVisible(c, name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	if !visible {
+		c.JSON(http.StatusNotFound, gin.H{"error": common.ErrMetricNotFound.Error()})
+		return false
+	}
+
+	return true
+}
+
+// authorizeAlert checks that the alert was raised for a metric visible to the tenant of the session, writing the error
+// response if not
+func (s *server) authorizeAlert(c *gin.Context, id int64) bool {
+	if len(c.GetString(sessionTenantKey)) == 0 {
+		return true
+	}
+
+	alert, err := s.storage.GetAlert(c.Request.Context(), id)
+	if err != nil {
+		return writeAlertUpdateError(c, err)
+	}
+
+	visible, err := s.isMetricVisible(c, alert.MetricName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	if !visible {
+		c.JSON(http.StatusNotFound, gin.H{"error": common.ErrAlertNotFound.Error()})
+		return false
+	}
+
+	return true
+}
+
+func (s *server) isMetricVisible(c *gin.Context, name string) (bool, error) {
+	tenant := c.GetString(sessionTenantKey)
+	if len(tenant) == 0 {
+		return true, nil
+	}
+
+	owner, err := s.storage.GetMetricTenant(c.Request.Context(), name)
+	if errors.Is(err, common.ErrMetricNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return owner == tenant, nil
+}
+
+// tenantMetrics returns the tags of the metrics of the tenant, by metric name
+func (s *server) tenantMetrics(c *gin.Context, tenant string) (map[string]map[string]string, error) {
+	metrics, err := s.storage.GetLatestMetricsFiltered(c.Request.Context(), common.MetricsFilter{Tenant: tenant})
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make(map[string]map[string]string, len(metrics))
+	for _, metric := range metrics {
+		tags[metric.Name] = metric.Tags
+		if metric.Tags == nil {
+			tags[metric.Name] = common.ParseTagsFromName(metric.Name)
+		}
+	}
+
+	return tags, nil
+}
+
+// tenantPanels returns the panels (VMs) the metrics of the tenant are displayed on
+func (s *server) tenantPanels(c *gin.Context, tenant string) (map[string]struct{}, error) {
+	metrics, err := s.tenantMetrics(c, tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	panels := make(map[string]struct{})
+	for _, tags := range metrics {
+		panels[tags[common.TagVM]] = struct{}{}
+	}
+
+	return panels, nil
+}
@@ -0,0 +1,198 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createTenantsArgs(store Storage) ArgsWebServer {
+	return ArgsWebServer{
+		ServiceKeyApi:  "test-secret",
+		AuthUsername:   "admin",
+		AuthPassword:   "password",
+		ListenAddress:  ":0",
+		Storage:        store,
+		GeneralHandler: func(h http.Handler) http.Handler { return h },
+		Tenants: []Tenant{
+			{Name: "acme", ServiceKeyApi: "acme-secret", Username: "acme-ops", Password: "acme-password"},
+		},
+	}
+}
+
+func TestNewServer_Tenants(t *testing.T) {
+	t.Parallel()
+
+	store, err := storage.NewSQLiteStorage(":memory:", 100, storage.SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	args := createTenantsArgs(store)
+	args.Tenants[0].ServiceKeyApi = "test-secret"
+	serv, err := NewServer(args)
+	assert.Nil(t, serv)
+	assert.ErrorContains(t, err, "tenant acme can not use the service API key")
+
+	args = createTenantsArgs(store)
+	args.Tenants[0].Username = "admin"
+	serv, err = NewServer(args)
+	assert.Nil(t, serv)
+	assert.ErrorContains(t, err, "tenant acme can not use the admin username")
+}
+
+func TestServer_Tenants(t *testing.T) {
+	store, err := storage.NewSQLiteStorage(":memory:", 100, storage.SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	args := createTenantsArgs(store)
+	args.Tenants = append(args.Tenants, Tenant{Name: "globex", ServiceKeyApi: "globex-secret", Username: "globex-ops", Password: "globex-password"})
+	serv, err := NewServer(args)
+	require.NoError(t, err)
+
+	report := func(apiKey string, body string) int {
+		req, _ := http.NewRequest("POST", "/api/report", bytes.NewBufferString(body))
+		req.Header.Set("X-Api-Key", apiKey)
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+
+		return w.Code
+	}
+	login := func(username string, password string) (int, map[string]string) {
+		body := `{"username":"` + username + `", "password":"` + password + `"}`
+		req, _ := http.NewRequest("POST", "/api/auth/login", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+
+		resp := make(map[string]string)
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp
+	}
+
+	assert.Equal(t, http.StatusOK, report("acme-secret", `{"metrics": {"VM1.Node1.nonce": {"value": "10", "type": "uint64", "numAggregation": 5}}}`))
+	assert.Equal(t, http.StatusUnauthorized, report("other-secret", `{"metrics": {}}`))
+	// each tenant reports in its own namespace, the names reported first by a tenant stay available to the others
+	assert.Equal(t, http.StatusOK, report("acme-secret", `{"metrics": {"VM2.Node1.nonce": {"value": "21", "type": "uint64", "numAggregation": 5}}}`))
+	assert.Equal(t, http.StatusOK, report("test-secret", `{"metrics": {"VM2.Node1.nonce": {"value": "20", "type": "uint64", "numAggregation": 5}}}`))
+	assert.Equal(t, http.StatusOK, report("globex-secret", `{"metrics": {"VM2.Node1.nonce": {"value": "22", "type": "uint64", "numAggregation": 5}}}`))
+	for name, tenant := range map[string]string{"VM2.Node1.nonce": "", "acme:VM2.Node1.nonce": "acme", "globex:VM2.Node1.nonce": "globex"} {
+		history, errHistory := store.GetMetricHistory(context.Background(), name)
+		require.NoError(t, errHistory)
+		require.Len(t, history.History, 1)
+		assert.Equal(t, tenant, history.Tenant)
+	}
+	history, err := store.GetMetricHistory(context.Background(), "acme:VM1.Node1.nonce")
+	require.NoError(t, err)
+	assert.Equal(t, "acme:VM1", history.Tags[common.TagVM])
+	// the default tenant agents can not report in the namespace of a tenant
+	assert.Equal(t, http.StatusOK, report("test-secret", `{"metrics": {"acme:VM3.Node1.nonce": {"value": "30", "type": "uint64", "numAggregation": 5}}}`))
+	_, err = store.GetMetricHistory(context.Background(), "acme:VM3.Node1.nonce")
+	assert.Equal(t, common.ErrMetricNotFound, err)
+
+	code, _ := login("acme-ops", "wrong")
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, resp := login("acme-ops", "acme-password")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "acme", resp["tenant"])
+	tenantToken := resp["token"]
+	code, resp = login("admin", "password")
+	require.Equal(t, http.StatusOK, code)
+	assert.Empty(t, resp["tenant"])
+	adminToken := resp["token"]
+
+	doRequest := func(token string, method string, url string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+
+		return w
+	}
+	getMetricNames := func(token string) []string {
+		w := doRequest(token, "GET", "/api/metrics", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		var metricsResp struct {
+			Metrics []struct {
+				Name string `json:"name"`
+			} `json:"metrics"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &metricsResp))
+
+		names := make([]string, 0, len(metricsResp.Metrics))
+		for _, metric := range metricsResp.Metrics {
+			names = append(names, metric.Name)
+		}
+		return names
+	}
+
+	assert.ElementsMatch(t, []string{"acme:VM1.Node1.nonce", "acme:VM2.Node1.nonce"}, getMetricNames(tenantToken))
+	assert.ElementsMatch(t, []string{"acme:VM1.Node1.nonce", "acme:VM2.Node1.nonce", "VM2.Node1.nonce", "globex:VM2.Node1.nonce"},
+		getMetricNames(adminToken))
+
+	// the metrics of the other tenants are not found
+	assert.Equal(t, http.StatusOK, doRequest(tenantToken, "GET", "/api/metrics/acme:VM1.Node1.nonce/history", "").Code)
+	assert.Equal(t, http.StatusNotFound, doRequest(tenantToken, "GET", "/api/metrics/VM2.Node1.nonce/history", "").Code)
+	assert.Equal(t, http.StatusNotFound, doRequest(tenantToken, "GET", "/api/metrics/globex:VM2.Node1.nonce/history", "").Code)
+	assert.Equal(t, http.StatusNotFound, doRequest(tenantToken, "DELETE", "/api/metrics/VM2.Node1.nonce", "").Code)
+	assert.Equal(t, http.StatusNotFound, doRequest(tenantToken, "POST", "/api/metrics/VM2.Node1.nonce/rename", `{"newName":"VM3.Node1.nonce"}`).Code)
+	assert.Equal(t, http.StatusNotFound, doRequest(tenantToken, "POST", "/api/config/metrics/alarm", `{"name":"VM2.Node1.nonce","enabled":true}`).Code)
+	assert.Equal(t, http.StatusOK, doRequest(tenantToken, "POST", "/api/config/metrics/alarm", `{"name":"acme:VM1.Node1.nonce","enabled":true}`).Code)
+	assert.Equal(t, http.StatusNotFound, doRequest(tenantToken, "POST", "/api/config/panels", `{"name":"VM2","order":1}`).Code)
+	assert.Equal(t, http.StatusOK, doRequest(tenantToken, "POST", "/api/config/panels", `{"name":"acme:VM1","order":1}`).Code)
+	assert.Equal(t, http.StatusOK, doRequest(adminToken, "POST", "/api/config/panels", `{"name":"VM2","order":2}`).Code)
+	w := doRequest(tenantToken, "GET", "/api/config/panels", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"acme:VM1":1}`, w.Body.String())
+
+	// the renamed metrics stay in the namespace of their tenant
+	w = doRequest(tenantToken, "POST", "/api/metrics/acme:VM2.Node1.nonce/rename", `{"newName":"VM3.Node1.nonce"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = doRequest(adminToken, "POST", "/api/metrics/VM2.Node1.nonce/rename", `{"newName":"acme:VM3.Node1.nonce"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = doRequest(tenantToken, "POST", "/api/metrics/acme:VM2.Node1.nonce/rename", `{"newName":"acme:VM3.Node1.nonce"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// alerts
+	_, err = store.CreateAlert(context.Background(), "acme:VM1.Node1.nonce", "Host appears offline", 1000)
+	require.NoError(t, err)
+	otherID, err := store.CreateAlert(context.Background(), "VM2.Node1.nonce", "Host appears offline", 1000)
+	require.NoError(t, err)
+	w = doRequest(tenantToken, "GET", "/api/alerts", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var alertsResp struct {
+		Alerts []common.Alert `json:"alerts"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &alertsResp))
+	require.Len(t, alertsResp.Alerts, 1)
+	assert.Equal(t, "acme:VM1.Node1.nonce", alertsResp.Alerts[0].MetricName)
+	otherURL := fmt.Sprintf("/api/alerts/%d", otherID)
+	assert.Equal(t, http.StatusNotFound, doRequest(tenantToken, "GET", otherURL, "").Code)
+	assert.Equal(t, http.StatusNotFound, doRequest(tenantToken, "POST", otherURL+"/silence", `{"durationInSec":60}`).Code)
+	assert.Equal(t, http.StatusOK, doRequest(adminToken, "GET", otherURL, "").Code)
+
+	// the instance-wide endpoints are reserved to the default tenant
+	assert.Equal(t, http.StatusForbidden, doRequest(tenantToken, "GET", "/api/dashboards", "").Code)
+	assert.Equal(t, http.StatusForbidden, doRequest(tenantToken, "GET", "/api/silences", "").Code)
+	assert.Equal(t, http.StatusForbidden, doRequest(tenantToken, "DELETE", "/api/metrics?prefix=VM", "").Code)
+	assert.Equal(t, http.StatusForbidden, doRequest(tenantToken, "GET", "/api/admin/schema", "").Code)
+	assert.Equal(t, http.StatusOK, doRequest(adminToken, "GET", "/api/dashboards", "").Code)
+
+	req, _ := http.NewRequest("GET", "/api/internal/stats", nil)
+	req.Header.Set("X-Api-Key", "acme-secret")
+	w = httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
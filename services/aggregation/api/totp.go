@@ -0,0 +1,243 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/totp"
+)
+
+const (
+	// totpIssuer names the service in the authenticator apps
+	totpIssuer       = "API Monitoring"
+	numRecoveryCodes = 10
+)
+
+// sessionOIDCKey holds, in the gin context, whether the user was authenticated by the OIDC identity provider
+const sessionOIDCKey = "sessionOIDC"
+
+var errInvalidSecondFactor = errors.New("invalid two-factor code")
+
+// requireLocalAccount restricts the endpoints to the accounts logging in with a password. The two-factor
+// authentication of the OIDC users is the identity provider business.
+func (s *server) requireLocalAccount() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetBool(sessionOIDCKey) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "not available for the OIDC users"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// checkSecondFactor verifies the code of the accounts with two-factor authentication, writing the error response if
+// the code is missing or invalid
+func (s *server) checkSecondFactor(c *gin.Context, username string, code string) bool {
+	enrollment, err := s.storage.GetTOTPEnrollment(c.Request.Context(), username)
+	if errors.Is(err, common.ErrTOTPNotEnrolled) {
+		return true
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	if !enrollment.Enabled {
+		return true
+	}
+	if len(code) == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "two-factor code required", "totpRequired": true})
+		return false
+	}
+
+	err = s.verifySecondFactor(c.Request.Context(), username, code)
+	if errors.Is(err, errInvalidSecondFactor) {
+		log.Warn("invalid two-factor code", "username", username)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error(), "totpRequired": true})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+
+	return true
+}
+
+// verifySecondFactor accepts an authenticator app code or an unused recovery code, consuming it
+func (s *server) verifySecondFactor(ctx context.Context, username string, code string) error {
+	s.mutTOTP.Lock()
+	defer s.mutTOTP.Unlock()
+
+	enrollment, err := s.storage.GetTOTPEnrollment(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	step, ok := totp.Validate(enrollment.Secret, code, time.Now(), enrollment.LastStep)
+	if ok {
+		enrollment.LastStep = step
+		return s.storage.SaveTOTPEnrollment(ctx, *enrollment)
+	}
+
+	hash := totp.HashRecoveryCode(code)
+	for i, recoveryCode := range enrollment.RecoveryCodes {
+		if recoveryCode != hash {
+			continue
+		}
+
+		log.Info("recovery code used", "username", username, "remaining", len(enrollment.RecoveryCodes)-1)
+		enrollment.RecoveryCodes = append(enrollment.RecoveryCodes[:i], enrollment.RecoveryCodes[i+1:]...)
+		return s.storage.SaveTOTPEnrollment(ctx, *enrollment)
+	}
+
+	return errInvalidSecondFactor
+}
+
+func (s *server) handleGetTOTP(c *gin.Context) {
+	enrollment, err := s.storage.GetTOTPEnrollment(c.Request.Context(), c.GetString(sessionUserKey))
+	if errors.Is(err, common.ErrTOTPNotEnrolled) {
+		c.JSON(http.StatusOK, gin.H{"enabled": false, "recoveryCodesLeft": 0})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"enabled": enrollment.Enabled, "recoveryCodesLeft": len(enrollment.RecoveryCodes)})
+}
+
+// handleEnrollTOTP generates a new secret for the user. The two-factor authentication is enabled once the user
+// confirms a code generated by the authenticator app.
+func (s *server) handleEnrollTOTP(c *gin.Context) {
+	username := c.GetString(sessionUserKey)
+	enrollment, err := s.storage.GetTOTPEnrollment(c.Request.Context(), username)
+	if err != nil && !errors.Is(err, common.ErrTOTPNotEnrolled) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err == nil && enrollment.Enabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "two-factor authentication is already enabled"})
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = s.storage.SaveTOTPEnrollment(c.Request.Context(), common.TOTPEnrollment{
+		Username:  username,
+		Secret:    secret,
+		CreatedAt: time.Now().Unix(),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":     secret,
+		"otpauthURL": totp.KeyURI(totpIssuer, username, secret),
+	})
+}
+
+// handleActivateTOTP enables the two-factor authentication after checking a code of the enrolled secret, returning
+// the recovery codes. The recovery codes are only shown once.
+func (s *server) handleActivateTOTP(c *gin.Context) {
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+
+	s.mutTOTP.Lock()
+	defer s.mutTOTP.Unlock()
+
+	username := c.GetString(sessionUserKey)
+	enrollment, err := s.storage.GetTOTPEnrollment(c.Request.Context(), username)
+	if errors.Is(err, common.ErrTOTPNotEnrolled) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "enroll first"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if enrollment.Enabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "two-factor authentication is already enabled"})
+		return
+	}
+
+	step, ok := totp.Validate(enrollment.Secret, req.Code, time.Now(), enrollment.LastStep)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errInvalidSecondFactor.Error()})
+		return
+	}
+
+	recoveryCodes, err := totp.GenerateRecoveryCodes(numRecoveryCodes)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	hashes := make([]string, 0, len(recoveryCodes))
+	for _, code := range recoveryCodes {
+		hashes = append(hashes, totp.HashRecoveryCode(code))
+	}
+
+	enrollment.Enabled = true
+	enrollment.LastStep = step
+	enrollment.RecoveryCodes = hashes
+	err = s.storage.SaveTOTPEnrollment(c.Request.Context(), *enrollment)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Info("two-factor authentication enabled", "username", username)
+	c.JSON(http.StatusOK, gin.H{"recoveryCodes": recoveryCodes})
+}
+
+// handleDisableTOTP turns off the two-factor authentication, requiring a valid code (or recovery code) so that a
+// stolen session token is not enough
+func (s *server) handleDisableTOTP(c *gin.Context) {
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+
+	username := c.GetString(sessionUserKey)
+	err := s.verifySecondFactor(c.Request.Context(), username, req.Code)
+	if errors.Is(err, common.ErrTOTPNotEnrolled) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, errInvalidSecondFactor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = s.storage.DeleteTOTPEnrollment(c.Request.Context(), username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Info("two-factor authentication disabled", "username", username)
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/totp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_TOTP(t *testing.T) {
+	serv, store := setupTestServer(t)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	token := getValidToken(serv)
+	doRequest := func(token string, method string, url string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+
+		return w
+	}
+	login := func(otp string) *httptest.ResponseRecorder {
+		body := `{"username":"admin", "password":"password", "otp":"` + otp + `"}`
+		req, _ := http.NewRequest("POST", "/api/auth/login", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+
+		return w
+	}
+
+	w := doRequest(token, "GET", "/api/auth/totp", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"enabled":false,"recoveryCodesLeft":0}`, w.Body.String())
+	assert.Equal(t, http.StatusBadRequest, doRequest(token, "POST", "/api/auth/totp/activate", `{"code":"123456"}`).Code)
+
+	w = doRequest(token, "POST", "/api/auth/totp/enroll", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var enrollResp struct {
+		Secret     string `json:"secret"`
+		OTPAuthURL string `json:"otpauthURL"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &enrollResp))
+	assert.Equal(t, totp.KeyURI(totpIssuer, "admin", enrollResp.Secret), enrollResp.OTPAuthURL)
+
+	// the pending enrollment does not change the login
+	assert.Equal(t, http.StatusOK, login("").Code)
+
+	assert.Equal(t, http.StatusBadRequest, doRequest(token, "POST", "/api/auth/totp/activate", `{"code":"000000"}`).Code)
+	code, err := totp.GenerateCode(enrollResp.Secret, time.Now())
+	require.NoError(t, err)
+	w = doRequest(token, "POST", "/api/auth/totp/activate", `{"code":"`+code+`"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	var activateResp struct {
+		RecoveryCodes []string `json:"recoveryCodes"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &activateResp))
+	require.Len(t, activateResp.RecoveryCodes, numRecoveryCodes)
+	assert.Equal(t, http.StatusConflict, doRequest(token, "POST", "/api/auth/totp/enroll", "").Code)
+
+	w = login("")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.JSONEq(t, `{"error":"two-factor code required","totpRequired":true}`, w.Body.String())
+	// the activation code can not be used again
+	assert.Equal(t, http.StatusUnauthorized, login(code).Code)
+
+	nextCode, err := totp.GenerateCode(enrollResp.Secret, time.Now().Add(totp.Period))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, login(nextCode).Code)
+
+	// the recovery codes can be used once
+	assert.Equal(t, http.StatusOK, login(activateResp.RecoveryCodes[0]).Code)
+	assert.Equal(t, http.StatusUnauthorized, login(activateResp.RecoveryCodes[0]).Code)
+	w = doRequest(token, "GET", "/api/auth/totp", "")
+	assert.JSONEq(t, `{"enabled":true,"recoveryCodesLeft":9}`, w.Body.String())
+
+	// the OIDC users have no local account
+	oidcClaims := sessionClaims{Sid: "oidc-session", Sub: "admin", Role: common.RoleAdmin, OIDC: true, Exp: time.Now().Add(time.Hour).Unix()}
+	require.NoError(t, store.CreateSession(context.Background(), common.Session{ID: oidcClaims.Sid, ExpiresAt: oidcClaims.Exp}))
+	oidcToken := serv.issueSessionToken(oidcClaims)
+	assert.Equal(t, http.StatusForbidden, doRequest(oidcToken, "POST", "/api/auth/totp/disable", `{"code":"`+activateResp.RecoveryCodes[1]+`"}`).Code)
+
+	assert.Equal(t, http.StatusBadRequest, doRequest(token, "POST", "/api/auth/totp/disable", `{"code":"000000"}`).Code)
+	assert.Equal(t, http.StatusOK, doRequest(token, "POST", "/api/auth/totp/disable", `{"code":"`+activateResp.RecoveryCodes[1]+`"}`).Code)
+	assert.Equal(t, http.StatusOK, login("").Code)
+	assert.Equal(t, http.StatusNotFound, doRequest(token, "POST", "/api/auth/totp/disable", `{"code":"000000"}`).Code)
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// apiV1Path is the prefix of the current API version. A breaking change (new auth, new payloads) is released
+	// under a new version, the previous one being kept for the deployed agents and frontends.
+	apiV1Path = "/api/v1"
+	// apiLegacyPath serves the endpoints of the current version under their unversioned paths, as deprecated aliases
+	apiLegacyPath = "/api"
+
+	deprecationHeader = "Deprecation"
+	linkHeader        = "Link"
+)
+
+// deprecatedAPI marks the responses of the unversioned aliases as deprecated (RFC 9745) and links the same endpoint
+// of the current version as their successor
+func (s *server) deprecatedAPI() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		successor := s.basePath + apiV1Path + strings.TrimPrefix(c.Request.URL.Path, apiLegacyPath)
+		c.Header(deprecationHeader, "true")
+		c.Header(linkHeader, fmt.Sprintf("<%s>; rel=\"successor-version\"", successor))
+
+		c.Next()
+	}
+}
+
+// isAPIPath returns true if the full route path is the provided API path under any of the served prefixes
+func isAPIPath(fullPath string, path string) bool {
+	return fullPath == apiV1Path+path || fullPath == apiLegacyPath+path
+}
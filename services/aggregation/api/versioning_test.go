@@ -0,0 +1,66 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIVersioning(t *testing.T) {
+	serv, store := setupTestServer(t)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	require.NoError(t, store.SaveMetric(context.Background(), "VM1.Active", "bool", 1, "true", time.Now().Unix()))
+	token := getValidToken(serv)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+
+		return w
+	}
+
+	t.Run("versioned endpoints are not deprecated", func(t *testing.T) {
+		w := get("/api/v1/metrics/VM1.Active/history")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "VM1.Active")
+		assert.Empty(t, w.Header().Get(deprecationHeader))
+		assert.Empty(t, w.Header().Get(linkHeader))
+	})
+	t.Run("unversioned aliases are deprecated", func(t *testing.T) {
+		w := get("/api/metrics/VM1.Active/history")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "VM1.Active")
+		assert.Equal(t, "true", w.Header().Get(deprecationHeader))
+		assert.Equal(t, `</api/v1/metrics/VM1.Active/history>; rel="successor-version"`, w.Header().Get(linkHeader))
+	})
+	t.Run("versioned login should work", func(t *testing.T) {
+		body := `{"username":"admin", "password":"password"}`
+		req, _ := http.NewRequest("POST", "/api/v1/auth/login", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+	t.Run("unknown versioned route", func(t *testing.T) {
+		w := get("/api/v1/unknown")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestIsLogoutPath(t *testing.T) {
+	assert.True(t, isLogoutPath("/api/auth/logout"))
+	assert.True(t, isLogoutPath("/api/v1/auth/logout"))
+	assert.True(t, isLogoutPath("/api/v1/auth/logout-all"))
+	assert.False(t, isLogoutPath("/api/v2/auth/logout"))
+	assert.False(t, isLogoutPath("/api/metrics"))
+}
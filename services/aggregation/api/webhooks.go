@@ -0,0 +1,111 @@
+package api
+
+import (
+	"crypto/subtle"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+// WebhookSource defines a third-party service (e.g. the GitHub status or a cloud provider health feed) pushing JSON
+// to /api/ingest/:source, its configured JSON paths being ingested as metrics. The sender authenticates with the
+// source token, in the X-Api-Key header or in the token query parameter for the senders that can only be given a URL.
+type WebhookSource struct {
+	Name    string
+	Token   string
+	Tenant  string
+	Metrics []WebhookMetric
+}
+
+// WebhookMetric maps the value found at the gjson Path of the pushed document (e.g. "status.indicator") to a metric
+type WebhookMetric struct {
+	Name           string
+	Path           string
+	Type           string
+	NumAggregation int
+}
+
+func (s *server) setWebhookSources(sources []WebhookSource) error {
+	s.webhookSources = make(map[string]WebhookSource, len(sources))
+	for _, source := range sources {
+		if len(source.Name) == 0 {
+			return errors.New("empty webhook source name")
+		}
+		if len(source.Token) == 0 {
+			return errors.New("webhook source " + source.Name + " has an empty token")
+		}
+		if len(source.Tenant) > 0 && !s.isTenant(source.Tenant) {
+			return errors.New("webhook source " + source.Name + " uses the unknown tenant " + source.Tenant)
+		}
+		_, isDuplicate := s.webhookSources[source.Name]
+		if isDuplicate {
+			return errors.New("duplicate webhook source " + source.Name)
+		}
+
+		s.webhookSources[source.Name] = source
+	}
+
+	return nil
+}
+
+// handleIngestWebhook ingests the values found at the configured paths of the pushed JSON document, as a report of
+// the source tenant. The paths missing from the document are skipped and returned, so the sender can spot a changed
+// document schema. A document without any of the paths is rejected with 422.
+func (s *server) handleIngestWebhook(c *gin.Context) {
+	source, found := s.webhookSources[c.Param("source")]
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown webhook source"})
+		return
+	}
+	token := c.GetHeader("X-Api-Key")
+	if len(token) == 0 {
+		token = c.Query("token")
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(source.Token)) != 1 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil || !gjson.ValidBytes(body) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON payload"})
+		return
+	}
+
+	payload := MetricReportPayload{
+		Metrics: make(map[string]ReportedMetric, len(source.Metrics)),
+	}
+	missing := make([]string, 0)
+	for _, metric := range source.Metrics {
+		result := gjson.GetBytes(body, metric.Path)
+		if !result.Exists() {
+			missing = append(missing, metric.Path)
+			continue
+		}
+
+		payload.Metrics[metric.Name] = ReportedMetric{
+			Value:          result.String(),
+			Type:           metric.Type,
+			NumAggregation: metric.NumAggregation,
+		}
+	}
+	if len(payload.Metrics) == 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "none of the configured paths was found", "missing": missing})
+		return
+	}
+
+	log.Debug("received webhook", "source", source.Name, "sender", c.ClientIP(), "num metrics", len(payload.Metrics))
+
+	err = s.ingestReport(c.Request.Context(), source.Tenant, payload)
+	if err != nil {
+		log.Warn("webhook rejected", "source", source.Name, "sender", c.ClientIP(), "error", err)
+		c.Header("Retry-After", "1")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true, "numMetrics": len(payload.Metrics), "missing": missing})
+}
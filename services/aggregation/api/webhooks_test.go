@@ -0,0 +1,128 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createWebhooksArgs(store Storage) ArgsWebServer {
+	args := createTenantsArgs(store)
+	args.WebhookSources = []WebhookSource{
+		{
+			Name:  "github-status",
+			Token: "github-token",
+			Metrics: []WebhookMetric{
+				{Name: "GitHub.status.indicator", Path: "status.indicator", Type: common.MetricTypeString, NumAggregation: 10},
+				{Name: "GitHub.components.api", Path: `components.#(name=="API").status`, Type: common.MetricTypeString, NumAggregation: 10},
+				{Name: "GitHub.incidents", Path: "incidents.#", Type: common.MetricTypeUint64, NumAggregation: 10},
+			},
+		},
+		{
+			Name:    "acme-health",
+			Token:   "acme-token",
+			Tenant:  "acme",
+			Metrics: []WebhookMetric{{Name: "Acme.healthy", Path: "healthy", Type: common.MetricTypeBool, NumAggregation: 10}},
+		},
+	}
+
+	return args
+}
+
+func TestNewServer_WebhookSources(t *testing.T) {
+	t.Parallel()
+
+	store, err := storage.NewSQLiteStorage(":memory:", 100, storage.SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	testCases := map[string]func(args *ArgsWebServer){
+		"empty webhook source name":                              func(args *ArgsWebServer) { args.WebhookSources[1].Name = "" },
+		"webhook source acme-health has an empty token":          func(args *ArgsWebServer) { args.WebhookSources[1].Token = "" },
+		"webhook source acme-health uses the unknown tenant xyz": func(args *ArgsWebServer) { args.WebhookSources[1].Tenant = "xyz" },
+		"duplicate webhook source github-status":                 func(args *ArgsWebServer) { args.WebhookSources[1].Name = "github-status" },
+	}
+	for expectedError, mutate := range testCases {
+		args := createWebhooksArgs(store)
+		mutate(&args)
+		serv, errNew := NewServer(args)
+		assert.Nil(t, serv)
+		assert.EqualError(t, errNew, expectedError)
+	}
+}
+
+func TestServer_IngestWebhook(t *testing.T) {
+	t.Parallel()
+
+	store, err := storage.NewSQLiteStorage(":memory:", 100, storage.SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	serv, err := NewServer(createWebhooksArgs(store))
+	require.NoError(t, err)
+
+	push := func(url string, apiKey string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", url, bytes.NewBufferString(body))
+		if len(apiKey) > 0 {
+			req.Header.Set("X-Api-Key", apiKey)
+		}
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+
+		return w
+	}
+	document := `{"status": {"indicator": "minor"}, "components": [{"name": "Git", "status": "operational"}, {"name": "API", "status": "degraded"}], "incidents": [{}, {}]}`
+
+	assert.Equal(t, http.StatusNotFound, push("/api/ingest/unknown", "github-token", document).Code)
+	assert.Equal(t, http.StatusUnauthorized, push("/api/ingest/github-status", "", document).Code)
+	assert.Equal(t, http.StatusUnauthorized, push("/api/ingest/github-status", "acme-token", document).Code)
+	assert.Equal(t, http.StatusUnauthorized, push("/api/ingest/github-status", "test-secret", document).Code)
+	assert.Equal(t, http.StatusBadRequest, push("/api/ingest/github-status", "github-token", `{"status":`).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, push("/api/ingest/github-status", "github-token", `{"other": 1}`).Code)
+
+	w := push("/api/ingest/github-status", "github-token", document)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"ok": true, "numMetrics": 3, "missing": []}`, w.Body.String())
+	expectedValues := map[string]string{
+		"GitHub.status.indicator": "minor",
+		"GitHub.components.api":   "degraded",
+		"GitHub.incidents":        "2",
+	}
+	for name, expectedValue := range expectedValues {
+		hist, errHistory := store.GetMetricHistory(context.Background(), name)
+		require.NoError(t, errHistory)
+		require.Len(t, hist.History, 1)
+		assert.Equal(t, expectedValue, hist.History[0].Value)
+		assert.Empty(t, hist.Tenant)
+	}
+
+	// the token can be provided in the URL and the missing paths are skipped
+	w = push("/api/ingest/github-status?token=github-token", "", `{"status": {"indicator": "none"}}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		NumMetrics int      `json:"numMetrics"`
+		Missing    []string `json:"missing"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 1, resp.NumMetrics)
+	assert.Equal(t, []string{`components.#(name=="API").status`, "incidents.#"}, resp.Missing)
+
+	// the values are ingested within the source tenant
+	require.Equal(t, http.StatusOK, push("/api/ingest/acme-health", "acme-token", `{"healthy": true}`).Code)
+	hist, err := store.GetMetricHistory(context.Background(), "acme:Acme.healthy")
+	require.NoError(t, err)
+	assert.Equal(t, "acme", hist.Tenant)
+	assert.Equal(t, "true", hist.History[0].Value)
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+)
+
+const (
+	defaultWriteQueueCapacity      = 10000
+	defaultWriteQueueBatchSize     = 500
+	defaultWriteQueueFlushInterval = 200 * time.Millisecond
+)
+
+var errWriteQueueFull = errors.New("write queue is full")
+
+// writeQueue decouples the report requests from the disk writes: the reported values are buffered in memory and
+// persisted in batches by a background worker. The queue is bounded, the reports that do not fit are rejected.
+type writeQueue struct {
+	storage       Storage
+	stats         *selfStats
+	capacity      int
+	batchSize     int
+	flushInterval time.Duration
+	mutPending    sync.Mutex
+	pending       []common.MetricRecord
+	mutFlush      sync.Mutex
+	notify        chan struct{}
+	done          chan struct{}
+	closeOnce     sync.Once
+	wg            sync.WaitGroup
+}
+
+func newWriteQueue(storage Storage, stats *selfStats, capacity int, batchSize int, flushInterval time.Duration) *writeQueue {
+	if capacity <= 0 {
+		capacity = defaultWriteQueueCapacity
+	}
+	if batchSize <= 0 {
+		batchSize = defaultWriteQueueBatchSize
+	}
+	if flushInterval <= 0 {
+		flushInterval = defaultWriteQueueFlushInterval
+	}
+
+	return &writeQueue{
+		storage:       storage,
+		stats:         stats,
+		capacity:      capacity,
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		pending:       make([]common.MetricRecord, 0, batchSize),
+		notify:        make(chan struct{}, 1),
+		done:          make(chan struct{}),
+	}
+}
+
+// start launches the worker. Its writes are not bound to the worker lifetime: a batch already taken out of the pending
+// records is always written, even if the queue is closed meanwhile.
+func (wq *writeQueue) start() {
+	ctx := context.Background()
+
+	wq.wg.Add(1)
+	go func() {
+		defer wq.wg.Done()
+
+		ticker := time.NewTicker(wq.flushInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-wq.done:
+				return
+			case <-wq.notify:
+				wq.flush(ctx)
+			case <-ticker.C:
+				wq.flush(ctx)
+			}
+		}
+	}()
+}
+
+// enqueue adds all the records of a report or none of them, if the queue does not have enough free space
+func (wq *writeQueue) enqueue(records []common.MetricRecord) error {
+	wq.mutPending.Lock()
+	if len(wq.pending)+len(records) > wq.capacity {
+		wq.mutPending.Unlock()
+		return errWriteQueueFull
+	}
+	wq.pending = append(wq.pending, records...)
+	isBatchFull := len(wq.pending) >= wq.batchSize
+	wq.mutPending.Unlock()
+
+	if isBatchFull {
+		select {
+		case wq.notify <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+func (wq *writeQueue) length() int {
+	wq.mutPending.Lock()
+	defer wq.mutPending.Unlock()
+
+	return len(wq.pending)
+}
+
+// flush writes all the pending records. When it returns, all the records enqueued before the call are persisted.
+func (wq *writeQueue) flush(ctx context.Context) {
+	wq.mutFlush.Lock()
+	defer wq.mutFlush.Unlock()
+
+	for {
+		wq.mutPending.Lock()
+		numRecords := len(wq.pending)
+		if numRecords > wq.batchSize {
+			numRecords = wq.batchSize
+		}
+		batch := wq.pending[:numRecords:numRecords]
+		wq.pending = wq.pending[numRecords:]
+		wq.mutPending.Unlock()
+
+		if len(batch) == 0 {
+			return
+		}
+
+		wq.writeBatch(ctx, batch)
+	}
+}
+
+func (wq *writeQueue) writeBatch(ctx context.Context, batch []common.MetricRecord) {
+	writeStart := time.Now()
+	err := wq.storage.SaveMetrics(ctx, batch)
+	if err == nil {
+		wq.stats.recordBatch(len(batch), time.Since(writeStart))
+		return
+	}
+
+	// one bad record fails the whole transaction, the batch is retried record by record so only that one is lost
+	log.Debug("failed to save the metrics batch, saving them one by one", "num records", len(batch), "error", err)
+	saveRecords(ctx, wq.storage, wq.stats, batch)
+}
+
+// close stops the worker, waiting for its ongoing flush, and persists the remaining records
+func (wq *writeQueue) close() {
+	wq.closeOnce.Do(func() {
+		close(wq.done)
+	})
+	wq.wg.Wait()
+
+	wq.flush(context.Background())
+}
+
+// saveRecords persists the records one by one, logging the failed ones. Each record is saved in its own batch write,
+// with its tags, interval and reception timestamp, which also checks the tenant owning the metric.
+func saveRecords(ctx context.Context, storage Storage, stats *selfStats, records []common.MetricRecord) {
+	for _, record := range records {
+		writeStart := time.Now()
+		err := storage.SaveMetrics(ctx, []common.MetricRecord{record})
+		stats.recordWrite(time.Since(writeStart), err)
+		if err != nil {
+			log.Warn("failed to save metric", "metric", record.Name, "tenant", record.Tenant, "error", err)
+		}
+	}
+}
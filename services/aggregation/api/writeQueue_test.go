@@ -0,0 +1,229 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/storage"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/testsCommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createRecords(prefix string, numRecords int) []common.MetricRecord {
+	records := make([]common.MetricRecord, 0, numRecords)
+	for i := 0; i < numRecords; i++ {
+		records = append(records, common.MetricRecord{
+			Name:           fmt.Sprintf("%s.m%d", prefix, i),
+			Type:           common.MetricTypeUint64,
+			NumAggregation: 10,
+			Value:          "1",
+			RecordedAt:     1000,
+		})
+	}
+
+	return records
+}
+
+func TestWriteQueue(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should reject the reports that do not fit", func(t *testing.T) {
+		t.Parallel()
+
+		wq := newWriteQueue(&testsCommon.StoreStub{}, newSelfStats(), 5, 2, time.Hour)
+		assert.Nil(t, wq.enqueue(createRecords("VM1", 3)))
+		assert.Equal(t, errWriteQueueFull, wq.enqueue(createRecords("VM2", 3)))
+		assert.Nil(t, wq.enqueue(createRecords("VM3", 2)))
+		assert.Equal(t, 5, wq.length())
+	})
+	t.Run("flush should write in batches", func(t *testing.T) {
+		t.Parallel()
+
+		batchSizes := make([]int, 0)
+		store := &testsCommon.StoreStub{
+			SaveMetricsHandler: func(ctx context.Context, records []common.MetricRecord) error {
+				batchSizes = append(batchSizes, len(records))
+				return nil
+			},
+		}
+		stats := newSelfStats()
+		wq := newWriteQueue(store, stats, 100, 4, time.Hour)
+		require.Nil(t, wq.enqueue(createRecords("VM1", 10)))
+
+		wq.flush(context.Background())
+		assert.Equal(t, []int{4, 4, 2}, batchSizes)
+		assert.Equal(t, 0, wq.length())
+
+		snapshot := stats.snapshot(time.Now())
+		assert.Equal(t, uint64(3), snapshot.NumBatches)
+		assert.Equal(t, uint64(10), snapshot.NumMetricsSaved)
+	})
+	t.Run("failed batch should be written record by record", func(t *testing.T) {
+		t.Parallel()
+
+		savedMetrics := make([]string, 0)
+		store := &testsCommon.StoreStub{
+			SaveMetricsHandler: func(ctx context.Context, records []common.MetricRecord) error {
+				if len(records) > 1 || records[0].Name == "VM1.m1" {
+					return errors.New("expected error")
+				}
+				savedMetrics = append(savedMetrics, records[0].Name)
+				return nil
+			},
+		}
+		stats := newSelfStats()
+		wq := newWriteQueue(store, stats, 100, 10, time.Hour)
+		require.Nil(t, wq.enqueue(createRecords("VM1", 3)))
+
+		wq.flush(context.Background())
+		assert.Equal(t, []string{"VM1.m0", "VM1.m2"}, savedMetrics)
+
+		snapshot := stats.snapshot(time.Now())
+		assert.Equal(t, uint64(0), snapshot.NumBatches)
+		assert.Equal(t, uint64(2), snapshot.NumMetricsSaved)
+		assert.Equal(t, uint64(1), snapshot.NumSaveErrors)
+	})
+	t.Run("worker should write the full batches and close should write the rest", func(t *testing.T) {
+		t.Parallel()
+
+		mut := sync.Mutex{}
+		numSaved := 0
+		store := &testsCommon.StoreStub{
+			SaveMetricsHandler: func(ctx context.Context, records []common.MetricRecord) error {
+				mut.Lock()
+				numSaved += len(records)
+				mut.Unlock()
+				return nil
+			},
+		}
+		getNumSaved := func() int {
+			mut.Lock()
+			defer mut.Unlock()
+			return numSaved
+		}
+
+		wq := newWriteQueue(store, newSelfStats(), 100, 5, time.Hour)
+		wq.start()
+
+		require.Nil(t, wq.enqueue(createRecords("VM1", 6)))
+		assert.Eventually(t, func() bool {
+			return getNumSaved() == 6
+		}, time.Second, time.Millisecond*10)
+
+		require.Nil(t, wq.enqueue(createRecords("VM2", 2)))
+		time.Sleep(time.Millisecond * 50)
+		assert.Equal(t, 6, getNumSaved())
+
+		wq.close()
+		assert.Equal(t, 8, getNumSaved())
+	})
+	t.Run("close during a flush should not lose the batch being written", func(t *testing.T) {
+		t.Parallel()
+
+		mut := sync.Mutex{}
+		numSaved := 0
+		flushStarted := make(chan struct{})
+		releaseFlush := make(chan struct{})
+		store := &testsCommon.StoreStub{
+			SaveMetricsHandler: func(ctx context.Context, records []common.MetricRecord) error {
+				select {
+				case flushStarted <- struct{}{}:
+					<-releaseFlush
+				default:
+				}
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+
+				mut.Lock()
+				numSaved += len(records)
+				mut.Unlock()
+				return nil
+			},
+		}
+
+		wq := newWriteQueue(store, newSelfStats(), 100, 5, time.Hour)
+		wq.start()
+		require.Nil(t, wq.enqueue(createRecords("VM1", 5)))
+		<-flushStarted
+
+		closed := make(chan struct{})
+		go func() {
+			wq.close()
+			close(closed)
+		}()
+		time.Sleep(time.Millisecond * 50)
+		close(releaseFlush)
+		<-closed
+
+		mut.Lock()
+		defer mut.Unlock()
+		assert.Equal(t, 5, numSaved)
+	})
+}
+
+func TestServer_ReportWithWriteQueue(t *testing.T) {
+	t.Parallel()
+
+	store, err := storage.NewSQLiteStorage(":memory:", 100, storage.SQLiteTuning{})
+	require.NoError(t, err)
+
+	serv, err := NewServer(ArgsWebServer{
+		ServiceKeyApi:             "test-secret",
+		AuthUsername:              "admin",
+		AuthPassword:              "password",
+		Storage:                   store,
+		GeneralHandler:            func(h http.Handler) http.Handler { return h },
+		WriteQueueEnabled:         true,
+		WriteQueueCapacity:        2,
+		WriteQueueFlushIntervalMs: 3600 * 1000,
+	})
+	require.NoError(t, err)
+
+	report := func(body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/report", bytes.NewBufferString(body))
+		req.Header.Set("X-Api-Key", "test-secret")
+		w := httptest.NewRecorder()
+		serv.router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := report(`{"metrics": {"VM1.Node1.nonce": {"value": "10", "type": "uint64", "numAggregation": 5}, "VM1.Active": {"value": "true", "type": "bool", "numAggregation": 1}}}`)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// queued, not yet written
+	_, err = store.GetMetricHistory(context.Background(), "VM1.Node1.nonce")
+	require.Equal(t, common.ErrMetricNotFound, err)
+
+	w = report(`{"metrics": {"VM2.Active": {"value": "true", "type": "bool", "numAggregation": 1}}}`)
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	snapshot := serv.stats.snapshot(time.Now())
+	assert.Equal(t, uint64(1), snapshot.NumQueueRejected)
+
+	// drain writes the queued values
+	req, _ := http.NewRequest("POST", "/api/admin/drain", nil)
+	req.Header.Set("Authorization", "Bearer "+getValidToken(serv))
+	w = httptest.NewRecorder()
+	serv.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	history, err := store.GetMetricHistory(context.Background(), "VM1.Node1.nonce")
+	require.NoError(t, err)
+	require.Len(t, history.History, 1)
+	assert.Equal(t, "10", history.History[0].Value)
+
+	assert.Equal(t, "VM1", history.Tags[common.TagVM])
+
+	require.NoError(t, serv.Close())
+}
@@ -0,0 +1,9 @@
+package archive
+
+import "errors"
+
+var (
+	errEmptyDirectory     = errors.New("empty archive directory")
+	errUnsupportedFormat  = errors.New("unsupported archive format")
+	errEmptyArchiveReason = errors.New("empty archive reason")
+)
@@ -0,0 +1,167 @@
+package archive
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	logger "github.com/multiversx/mx-chain-logger-go"
+)
+
+var log = logger.GetOrCreate("archive")
+
+// Supported archive formats
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+)
+
+const archiveFileTimeLayout = "20060102T150405.000000000Z"
+
+// ArgsFileArchiver defines the DTO struct for the NewFileArchiver constructor function
+type ArgsFileArchiver struct {
+	// Directory receives the archive files, it can be a mounted object store bucket
+	Directory string
+	// Format is FormatJSON (the default) or FormatCSV
+	Format string
+}
+
+// archiveFile is the content of a JSON archive
+type archiveFile struct {
+	Reason     string                  `json:"reason"`
+	ArchivedAt int64                   `json:"archivedAt"`
+	Metrics    []common.ArchivedMetric `json:"metrics"`
+}
+
+// fileArchiver writes the metrics history to gzip compressed JSON or CSV files, one file per archival. The files are
+// written under a temporary name and renamed once complete, so the tools syncing the directory never pick partial
+// archives.
+type fileArchiver struct {
+	directory string
+	format    string
+}
+
+// NewFileArchiver creates a new file archiver, creating the archive directory if missing
+func NewFileArchiver(args ArgsFileArchiver) (*fileArchiver, error) {
+	if len(args.Directory) == 0 {
+		return nil, errEmptyDirectory
+	}
+
+	format := args.Format
+	if len(format) == 0 {
+		format = FormatJSON
+	}
+	if format != FormatJSON && format != FormatCSV {
+		return nil, fmt.Errorf("%w: %q", errUnsupportedFormat, args.Format)
+	}
+
+	err := os.MkdirAll(args.Directory, os.ModePerm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the archive directory: %w", err)
+	}
+
+	return &fileArchiver{
+		directory: args.Directory,
+		format:    format,
+	}, nil
+}
+
+// Archive writes the metrics in a new archive file named after the reason and the current time
+func (archiver *fileArchiver) Archive(reason string, metrics []common.ArchivedMetric) error {
+	if len(reason) == 0 {
+		return errEmptyArchiveReason
+	}
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	fileName := fmt.Sprintf("%s-%s.%s.gz", reason, now.Format(archiveFileTimeLayout), archiver.format)
+	path := filepath.Join(archiver.directory, fileName)
+	tempPath := path + ".tmp"
+
+	err := archiver.writeFile(tempPath, reason, now.Unix(), metrics)
+	if err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to write the archive %s: %w", fileName, err)
+	}
+
+	err = os.Rename(tempPath, path)
+	if err != nil {
+		_ = os.Remove(tempPath)
+		return err
+	}
+
+	log.Debug("archived metrics", "reason", reason, "num metrics", len(metrics), "file", fileName)
+
+	return nil
+}
+
+func (archiver *fileArchiver) writeFile(path string, reason string, archivedAt int64, metrics []common.ArchivedMetric) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	writer := gzip.NewWriter(file)
+	if archiver.format == FormatCSV {
+		err = writeCSV(writer, metrics)
+	} else {
+		err = json.NewEncoder(writer).Encode(archiveFile{
+			Reason:     reason,
+			ArchivedAt: archivedAt,
+			Metrics:    metrics,
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+
+	err = file.Sync()
+	if err != nil {
+		return err
+	}
+
+	return file.Close()
+}
+
+// writeCSV writes one row per value, the tags and the annotations are only kept in the JSON archives
+func writeCSV(w io.Writer, metrics []common.ArchivedMetric) error {
+	writer := csv.NewWriter(w)
+	err := writer.Write([]string{"name", "type", "tenant", "recorded_at", "value"})
+	if err != nil {
+		return err
+	}
+
+	for _, metric := range metrics {
+		for _, value := range metric.History {
+			err = writer.Write([]string{metric.Name, metric.Type, metric.Tenant, strconv.FormatInt(value.RecordedAt, 10), value.Value})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	writer.Flush()
+
+	return writer.Error()
+}
+
+// IsInterfaceNil returns true if there is no value under the interface
+func (archiver *fileArchiver) IsInterfaceNil() bool {
+	return archiver == nil
+}
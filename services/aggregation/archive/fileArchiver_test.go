@@ -0,0 +1,137 @@
+package archive
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createTestMetrics() []common.ArchivedMetric {
+	return []common.ArchivedMetric{
+		{
+			Name:        "VM1.Node1.nonce",
+			Type:        common.MetricTypeUint64,
+			Tags:        map[string]string{"vm": "VM1"},
+			Annotations: []common.MetricAnnotation{{ID: 1, Text: "upgraded", RecordedAt: 1000}},
+			History:     []common.MetricValue{{Value: "10", RecordedAt: 1000}, {Value: "11", RecordedAt: 1030}},
+		},
+		{
+			Name:    "VM2.Version",
+			Type:    common.MetricTypeString,
+			Tenant:  "acme",
+			History: []common.MetricValue{{Value: "v1.7.0, rc", RecordedAt: 1000}},
+		},
+	}
+}
+
+func readArchive(t *testing.T, directory string) (string, string) {
+	entries, err := os.ReadDir(directory)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	file, err := os.Open(filepath.Join(directory, entries[0].Name()))
+	require.NoError(t, err)
+	defer func() {
+		_ = file.Close()
+	}()
+
+	reader, err := gzip.NewReader(file)
+	require.NoError(t, err)
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+
+	return entries[0].Name(), string(content)
+}
+
+func TestNewFileArchiver(t *testing.T) {
+	t.Parallel()
+
+	t.Run("empty directory should error", func(t *testing.T) {
+		t.Parallel()
+
+		archiver, err := NewFileArchiver(ArgsFileArchiver{})
+		assert.Nil(t, archiver)
+		assert.Equal(t, errEmptyDirectory, err)
+	})
+	t.Run("unsupported format should error", func(t *testing.T) {
+		t.Parallel()
+
+		archiver, err := NewFileArchiver(ArgsFileArchiver{Directory: t.TempDir(), Format: "parquet"})
+		assert.Nil(t, archiver)
+		assert.True(t, errors.Is(err, errUnsupportedFormat))
+	})
+	t.Run("should create the directory", func(t *testing.T) {
+		t.Parallel()
+
+		directory := filepath.Join(t.TempDir(), "archive")
+		archiver, err := NewFileArchiver(ArgsFileArchiver{Directory: directory})
+		require.NoError(t, err)
+		assert.False(t, archiver.IsInterfaceNil())
+		assert.Equal(t, FormatJSON, archiver.format)
+		assert.DirExists(t, directory)
+	})
+}
+
+func TestFileArchiver_Archive(t *testing.T) {
+	t.Parallel()
+
+	t.Run("empty reason should error", func(t *testing.T) {
+		t.Parallel()
+
+		archiver, _ := NewFileArchiver(ArgsFileArchiver{Directory: t.TempDir()})
+		assert.Equal(t, errEmptyArchiveReason, archiver.Archive("", createTestMetrics()))
+	})
+	t.Run("no metrics should not write a file", func(t *testing.T) {
+		t.Parallel()
+
+		directory := t.TempDir()
+		archiver, _ := NewFileArchiver(ArgsFileArchiver{Directory: directory})
+		require.NoError(t, archiver.Archive(common.ArchiveReasonDeleted, nil))
+
+		entries, err := os.ReadDir(directory)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+	t.Run("should write a JSON archive", func(t *testing.T) {
+		t.Parallel()
+
+		directory := t.TempDir()
+		archiver, _ := NewFileArchiver(ArgsFileArchiver{Directory: directory, Format: FormatJSON})
+		require.NoError(t, archiver.Archive(common.ArchiveReasonDeleted, createTestMetrics()))
+
+		name, content := readArchive(t, directory)
+		assert.True(t, strings.HasPrefix(name, "deleted-"))
+		assert.True(t, strings.HasSuffix(name, ".json.gz"))
+
+		archived := archiveFile{}
+		require.NoError(t, json.Unmarshal([]byte(content), &archived))
+		assert.Equal(t, common.ArchiveReasonDeleted, archived.Reason)
+		assert.NotZero(t, archived.ArchivedAt)
+		assert.Equal(t, createTestMetrics(), archived.Metrics)
+	})
+	t.Run("should write a CSV archive", func(t *testing.T) {
+		t.Parallel()
+
+		directory := t.TempDir()
+		archiver, _ := NewFileArchiver(ArgsFileArchiver{Directory: directory, Format: FormatCSV})
+		require.NoError(t, archiver.Archive(common.ArchiveReasonExpired, createTestMetrics()))
+
+		name, content := readArchive(t, directory)
+		assert.True(t, strings.HasPrefix(name, "expired-"))
+		assert.True(t, strings.HasSuffix(name, ".csv.gz"))
+		expected := "name,type,tenant,recorded_at,value\n" +
+			"VM1.Node1.nonce,uint64,,1000,10\n" +
+			"VM1.Node1.nonce,uint64,,1030,11\n" +
+			"VM2.Version,string,acme,1000,\"v1.7.0, rc\"\n"
+		assert.Equal(t, expected, content)
+	})
+}
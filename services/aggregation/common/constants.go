@@ -21,4 +21,71 @@ const (
 	EnvSMTPHost         = "SMTP_HOST"
 	EnvTelegramBotToken = "TELEGRAM_BOT_TOKEN"
 	EnvTelegramChatId   = "TELEGRAM_CHAT_ID"
+	EnvInfluxToken      = "INFLUX_TOKEN"
+	EnvNatsToken        = "NATS_TOKEN"
+	EnvPagerDutyKey     = "PAGERDUTY_ROUTING_KEY"
+	EnvOpsgenieKey      = "OPSGENIE_API_KEY"
+	EnvOIDCClientSecret = "OIDC_CLIENT_SECRET"
+	EnvFederationAPIKey = "FEDERATION_API_KEY"
 )
+
+// Roles of the dashboard users
+const (
+	// RoleAdmin can read and change everything visible to the user tenant
+	RoleAdmin = "admin"
+	// RoleViewer can only read
+	RoleViewer = "viewer"
+)
+
+// Scopes of the API keys issued to the external tools
+const (
+	// APIKeyScopeReport can only report metrics, as the agents do
+	APIKeyScopeReport = "report"
+	// APIKeyScopeRead can only call the read endpoints of the dashboard
+	APIKeyScopeRead = "read"
+	// APIKeyScopeAdmin can do everything the admin user can
+	APIKeyScopeAdmin = "admin"
+)
+
+// Supported metric types
+const (
+	MetricTypeUint64  = "uint64"
+	MetricTypeFloat64 = "float64"
+	MetricTypeString  = "string"
+	MetricTypeBool    = "bool"
+)
+
+// Reasons of the metrics history archival
+const (
+	// ArchiveReasonDeleted is used for the metrics removed by a delete or purged from the trash
+	ArchiveReasonDeleted = "deleted"
+	// ArchiveReasonExpired is used for the values older than the retention period
+	ArchiveReasonExpired = "expired"
+)
+
+// Tag keys derived from the dotted metric names
+const (
+	TagVM   = "vm"
+	TagNode = "node"
+	TagKind = "kind"
+	// TagSource is set on the metrics rolled up from a child aggregation service, to the child prefix
+	TagSource = "source"
+)
+
+// Statuses displayed on the public status page
+const (
+	StatusUp       = "up"
+	StatusDown     = "down"
+	StatusDegraded = "degraded"
+	StatusUnknown  = "unknown"
+)
+
+// HeartbeatMetricSuffix is the suffix of the bool metric each agent reports to signal it is alive (e.g. VM1.Active)
+const HeartbeatMetricSuffix = "Active"
+
+// AvailabilityBucketSeconds is the resolution of the availability (SLA) data: a metric is considered up during a
+// bucket if it reported at least one successful value in it
+const AvailabilityBucketSeconds = 300
+
+// MaxAvailabilityWindowSeconds is the longest window the availability is computed for and kept
+const MaxAvailabilityWindowSeconds = 30 * 24 * 3600
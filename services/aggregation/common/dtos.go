@@ -1,5 +1,10 @@
 package common
 
+import (
+	"encoding/json"
+	"path"
+)
+
 // MetricDefinition defines the structure of a metric in the metrics table
 type MetricDefinition struct {
 	Name           string `json:"name"`
@@ -13,16 +18,103 @@ type MetricDefinition struct {
 type MetricValue struct {
 	Value      string `json:"value"` // Stored natively in DB but returned as string to API
 	RecordedAt int64  `json:"recordedAt"`
+	LastSeenAt int64  `json:"lastSeenAt,omitempty"` // the latest report of the same value, for the metrics stored on change
+	Filled     bool   `json:"filled,omitempty"`     // true for the points generated server-side to represent missing intervals
+	IsNull     bool   `json:"-"`                    // when true, the value is encoded as JSON null
+}
+
+// MarshalJSON encodes the value as JSON null for the null filler points
+func (mv MetricValue) MarshalJSON() ([]byte, error) {
+	type plainMetricValue MetricValue
+	if !mv.IsNull {
+		return json.Marshal(plainMetricValue(mv))
+	}
+
+	return json.Marshal(struct {
+		Value      *string `json:"value"`
+		RecordedAt int64   `json:"recordedAt"`
+		Filled     bool    `json:"filled,omitempty"`
+	}{
+		RecordedAt: mv.RecordedAt,
+		Filled:     mv.Filled,
+	})
+}
+
+// MetricRecord is a reported metric value queued to be persisted
+type MetricRecord struct {
+	Name           string            `json:"name"`
+	Type           string            `json:"type"`
+	NumAggregation int               `json:"numAggregation"`
+	Value          string            `json:"value"`
+	Tags           map[string]string `json:"tags,omitempty"`
+	RecordedAt     int64             `json:"recordedAt"`
+	// ExpectedInterval is the number of seconds between two values of the metric, as reported by the agent, 0 if
+	// unknown
+	ExpectedInterval int `json:"expectedInterval,omitempty"`
+	// Tenant is the organization owning the metric, resolved from the API key of the reporting agent. The
+	// default tenant is empty.
+	Tenant string `json:"tenant,omitempty"`
+	// ReceivedAt is the server unix timestamp of the report, RecordedAt being the (corrected) agent poll timestamp.
+	// 0 means the value was recorded on reception.
+	ReceivedAt int64 `json:"receivedAt,omitempty"`
+}
+
+// StorageStats holds the counters of the storage operations, used to diagnose the lock contention
+type StorageStats struct {
+	NumOperations          uint64  `json:"numOperations"`
+	NumSlowOperations      uint64  `json:"numSlowOperations"`
+	NumTimedOutOperations  uint64  `json:"numTimedOutOperations"`
+	MaxOperationMs         float64 `json:"maxOperationMs"`
+	SlowQueryThresholdInMs int64   `json:"slowQueryThresholdInMs"`
 }
 
 // MetricHistory encapsulates a metric's definition and its recent time-series values
 type MetricHistory struct {
-	Name           string        `json:"name"`
-	Type           string        `json:"type"`
-	NumAggregation int           `json:"numAggregation"`
-	DisplayOrder   int           `json:"displayOrder"`
-	IsAlarmEnabled bool          `json:"isAlarmEnabled"`
-	History        []MetricValue `json:"history"`
+	Name           string            `json:"name"`
+	Type           string            `json:"type"`
+	NumAggregation int               `json:"numAggregation"`
+	DisplayOrder   int               `json:"displayOrder"`
+	IsAlarmEnabled bool              `json:"isAlarmEnabled"`
+	GapMode        GapMode           `json:"gapMode"`
+	Tags           map[string]string `json:"tags,omitempty"`
+	Tenant         string            `json:"tenant,omitempty"`
+	// AggregationMode defines the AggregatedValue, computed over the stored values of the numeric metrics
+	AggregationMode AggregationMode `json:"aggregationMode"`
+	AggregatedValue string          `json:"aggregatedValue,omitempty"`
+	// StoreOnChange is set when only the changes of the value are stored, the History holding the distinct values
+	StoreOnChange bool `json:"storeOnChange"`
+	// Compressed is set when the older values are packed in delta encoded chunks, decoded on read
+	Compressed bool `json:"compressed"`
+	// ExpectedInterval is the number of seconds between two values of the metric, 0 if unknown
+	ExpectedInterval int `json:"expectedInterval"`
+	// Thresholds are the display boundaries of the metric, nil if not defined
+	Thresholds  *MetricThresholds  `json:"thresholds,omitempty"`
+	History     []MetricValue      `json:"history"`
+	Annotations []MetricAnnotation `json:"annotations,omitempty"`
+}
+
+// ExportedValue is a value streamed by the exports, together with the definition of its metric
+type ExportedValue struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	Value      string `json:"value"`
+	RecordedAt int64  `json:"recordedAt"`
+	LastSeenAt int64  `json:"lastSeenAt,omitempty"`
+}
+
+// MetricStats holds the statistics of the values of a numeric metric recorded in a time range. The percentiles use
+// the nearest-rank method. The statistics are nil if the range holds no values.
+type MetricStats struct {
+	Name      string   `json:"name"`
+	From      int64    `json:"from"`
+	To        int64    `json:"to"`
+	NumValues int      `json:"numValues"`
+	Min       *float64 `json:"min"`
+	Max       *float64 `json:"max"`
+	Mean      *float64 `json:"mean"`
+	P50       *float64 `json:"p50"`
+	P95       *float64 `json:"p95"`
+	P99       *float64 `json:"p99"`
 }
 
 // OutputMessage defines the message to be sent to an output notifier
@@ -31,4 +123,263 @@ type OutputMessage struct {
 	Identifier         string
 	ExecutorName       string
 	ProblemEncountered string
+	State              AlertState
+}
+
+// SchemaInfo describes the database schema, used for support and debugging
+type SchemaInfo struct {
+	Version    int             `json:"version"`
+	Migrations []MigrationInfo `json:"migrations"`
+	Tables     []TableInfo     `json:"tables"`
+}
+
+// MigrationInfo defines an applied schema migration
+type MigrationInfo struct {
+	Name      string `json:"name"`
+	AppliedAt int64  `json:"appliedAt"`
+}
+
+// TableInfo defines a database table with its definition and row count
+type TableInfo struct {
+	Name       string `json:"name"`
+	Definition string `json:"definition"`
+	NumRows    int64  `json:"numRows"`
+}
+
+// MetricsFilter defines the conditions and ordering applied when listing the latest metrics
+type MetricsFilter struct {
+	Name       string
+	Prefix     string
+	Type       string
+	SortBy     MetricsSortField
+	Descending bool
+	// Tenant restricts the listing to the metrics of a tenant, all the metrics are listed if empty
+	Tenant string
+}
+
+// MetricAnnotation is a timestamped note attached to a metric (e.g. "upgraded node to v1.7.0")
+type MetricAnnotation struct {
+	ID         int64  `json:"id"`
+	Text       string `json:"text"`
+	RecordedAt int64  `json:"recordedAt"`
+}
+
+// Dashboard is a named selection of panels and metrics (e.g. "mainnet" or "testnet").
+// An empty selection means that all the metrics are displayed.
+type Dashboard struct {
+	ID      int64    `json:"id"`
+	Name    string   `json:"name"`
+	Panels  []string `json:"panels"`
+	Metrics []string `json:"metrics"`
+}
+
+// Contains returns true if the metric is selected by the dashboard, either directly or through its panel
+func (d *Dashboard) Contains(metricName string, panel string) bool {
+	if len(d.Panels) == 0 && len(d.Metrics) == 0 {
+		return true
+	}
+
+	for _, name := range d.Metrics {
+		if name == metricName {
+			return true
+		}
+	}
+	for _, name := range d.Panels {
+		if name == panel {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AgentStatus is the health summary of an agent (VM) as displayed on the public status page
+type AgentStatus struct {
+	Name               string `json:"name"`
+	Status             string `json:"status"`
+	LastReportAt       int64  `json:"lastReportAt"`
+	LastReportAgeInSec int64  `json:"lastReportAgeInSec"`
+}
+
+// StatusSummary is the public status page content
+type StatusSummary struct {
+	Title       string        `json:"title"`
+	Status      string        `json:"status"`
+	GeneratedAt int64         `json:"generatedAt"`
+	Agents      []AgentStatus `json:"agents"`
+}
+
+// Alert is a stateful alert raised for a metric, retained after its resolution for the post-mortems
+type Alert struct {
+	ID             int64        `json:"id"`
+	MetricName     string       `json:"metricName"`
+	State          AlertState   `json:"state"`
+	Problem        string       `json:"problem"`
+	StartedAt      int64        `json:"startedAt"`
+	FiredAt        int64        `json:"firedAt,omitempty"`
+	AcknowledgedAt int64        `json:"acknowledgedAt,omitempty"`
+	AcknowledgedBy string       `json:"acknowledgedBy,omitempty"`
+	ResolvedAt     int64        `json:"resolvedAt,omitempty"`
+	SilencedUntil  int64        `json:"silencedUntil,omitempty"`
+	Events         []AlertEvent `json:"events,omitempty"`
+}
+
+// IsSilenced returns true if the notifications of the alert are muted at the provided timestamp
+func (alert *Alert) IsSilenced(nowSec int64) bool {
+	return alert.SilencedUntil > nowSec
+}
+
+// AlertEvent is an entry of the alert history: a state transition or a silence
+type AlertEvent struct {
+	Action     string `json:"action"`
+	Note       string `json:"note,omitempty"`
+	Actor      string `json:"actor,omitempty"`
+	RecordedAt int64  `json:"recordedAt"`
+}
+
+// AlertsFilter holds the criteria for the alerts listing. The zero values match everything.
+type AlertsFilter struct {
+	State      AlertState
+	MetricName string
+	// ActiveSince matches the alerts that were not resolved before the timestamp
+	ActiveSince int64
+	// Tenant restricts the listing to the alerts of the metrics of a tenant, all the alerts are listed if empty
+	Tenant string
+	Limit  int
+}
+
+// Silence mutes the alerts of the metrics matching the pattern during a time range, e.g. a maintenance window.
+// The pattern uses the shell glob syntax (VM1.*, *.nonce).
+type Silence struct {
+	ID        int64  `json:"id"`
+	Pattern   string `json:"pattern"`
+	StartsAt  int64  `json:"startsAt"`
+	EndsAt    int64  `json:"endsAt"`
+	Comment   string `json:"comment,omitempty"`
+	CreatedBy string `json:"createdBy,omitempty"`
+	CreatedAt int64  `json:"createdAt"`
+}
+
+// IsActive returns true if the silence applies at the provided timestamp
+func (silence *Silence) IsActive(nowSec int64) bool {
+	return silence.StartsAt <= nowSec && nowSec < silence.EndsAt
+}
+
+// Matches returns true if the metric name matches the silence pattern
+func (silence *Silence) Matches(metricName string) bool {
+	matched, err := path.Match(silence.Pattern, metricName)
+
+	return err == nil && matched
+}
+
+// AvailabilityBucket holds the number of values, and of successful ones, reported by a metric during a bucket.
+// The values of the bool metrics are successful if true, the values of the other metrics always are.
+type AvailabilityBucket struct {
+	MetricName  string
+	BucketStart int64
+	NumSamples  int
+	NumSuccess  int
+}
+
+// Availability is the share of a time window in which a metric, or an agent, was up. Only the part of the window
+// after the first report is observed and the percentage is 0 if nothing was observed.
+type Availability struct {
+	WindowInSec     int64   `json:"windowInSec"`
+	ObservedSeconds int64   `json:"observedSeconds"`
+	UpSeconds       int64   `json:"upSeconds"`
+	Percentage      float64 `json:"percentage"`
+}
+
+// SLAEntry holds the availability of a metric or of an agent, mapped by the window name (24h, 7d, 30d)
+type SLAEntry struct {
+	Name         string                  `json:"name"`
+	Availability map[string]Availability `json:"availability"`
+}
+
+// SLAReport holds the availability of the agents and of their metrics
+type SLAReport struct {
+	GeneratedAt   int64      `json:"generatedAt"`
+	BucketSeconds int64      `json:"bucketSeconds"`
+	Agents        []SLAEntry `json:"agents"`
+	Metrics       []SLAEntry `json:"metrics"`
+}
+
+// Session is a frontend login. The session tokens are accepted until the session expires or is revoked.
+type Session struct {
+	ID         string `json:"id"`
+	Username   string `json:"username"`
+	Tenant     string `json:"tenant,omitempty"`
+	Role       string `json:"role"`
+	OIDC       bool   `json:"oidc"`
+	UserAgent  string `json:"userAgent,omitempty"`
+	RemoteAddr string `json:"remoteAddr,omitempty"`
+	CreatedAt  int64  `json:"createdAt"`
+	ExpiresAt  int64  `json:"expiresAt"`
+	RevokedAt  int64  `json:"revokedAt,omitempty"`
+}
+
+// IsActive returns true if the session tokens are accepted at the provided timestamp
+func (session *Session) IsActive(nowSec int64) bool {
+	return session.RevokedAt == 0 && nowSec <= session.ExpiresAt
+}
+
+// AgentInfo holds the build and configuration details last reported by an agent. UpdatedAt is the time they were
+// first reported.
+type AgentInfo struct {
+	Name       string `json:"name"`
+	Tenant     string `json:"tenant,omitempty"`
+	Version    string `json:"version"`
+	Commit     string `json:"commit"`
+	ConfigHash string `json:"configHash"`
+	UpdatedAt  int64  `json:"updatedAt"`
+}
+
+// TrashedMetric is a deleted metric kept, with its history, until purged
+type TrashedMetric struct {
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	Tenant    string `json:"tenant,omitempty"`
+	DeletedAt int64  `json:"deletedAt"`
+	// PurgeAt is the unix timestamp after which the metric and its history are permanently deleted
+	PurgeAt int64 `json:"purgeAt"`
+}
+
+// ArchivedMetric is the history of a metric written to the archive before being removed from the database
+type ArchivedMetric struct {
+	Name        string             `json:"name"`
+	Type        string             `json:"type"`
+	Tenant      string             `json:"tenant,omitempty"`
+	Tags        map[string]string  `json:"tags,omitempty"`
+	Annotations []MetricAnnotation `json:"annotations,omitempty"`
+	History     []MetricValue      `json:"history"`
+}
+
+// AgentLogEntry is a warning or an error logged by an agent and shipped to the aggregation service
+type AgentLogEntry struct {
+	ID         int64             `json:"id"`
+	Agent      string            `json:"agent"`
+	Tenant     string            `json:"tenant,omitempty"`
+	Level      string            `json:"level"`
+	Component  string            `json:"component"`
+	Message    string            `json:"message"`
+	Fields     map[string]string `json:"fields,omitempty"`
+	RecordedAt int64             `json:"recordedAt"`
+}
+
+// TOTPEnrollment holds the two-factor authentication settings of a dashboard account. The enrollment becomes enabled
+// once the user proves the authenticator app generates valid codes. The recovery codes are stored hashed.
+type TOTPEnrollment struct {
+	Username      string
+	Secret        string
+	Enabled       bool
+	LastStep      int64
+	RecoveryCodes []string
+	CreatedAt     int64
+}
+
+// UserIdentity is the dashboard user authenticated by an external identity provider
+type UserIdentity struct {
+	Username string
+	Role     string
+	Tenant   string
 }
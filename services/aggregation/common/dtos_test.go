@@ -0,0 +1,49 @@
+package common
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricValue_MarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	t.Run("regular value", func(t *testing.T) {
+		t.Parallel()
+
+		buff, err := json.Marshal(MetricValue{Value: "37", RecordedAt: 100})
+		assert.Nil(t, err)
+		assert.Equal(t, `{"value":"37","recordedAt":100}`, string(buff))
+	})
+	t.Run("filled value", func(t *testing.T) {
+		t.Parallel()
+
+		buff, err := json.Marshal(MetricValue{Value: "0", RecordedAt: 100, Filled: true})
+		assert.Nil(t, err)
+		assert.Equal(t, `{"value":"0","recordedAt":100,"filled":true}`, string(buff))
+	})
+	t.Run("null value", func(t *testing.T) {
+		t.Parallel()
+
+		buff, err := json.Marshal(MetricValue{Value: "ignored", RecordedAt: 100, Filled: true, IsNull: true})
+		assert.Nil(t, err)
+		assert.Equal(t, `{"value":null,"recordedAt":100,"filled":true}`, string(buff))
+	})
+}
+
+func TestDashboard_Contains(t *testing.T) {
+	t.Parallel()
+
+	empty := &Dashboard{}
+	assert.True(t, empty.Contains("VM1.Node1.nonce", "VM1"))
+
+	dashboard := &Dashboard{
+		Panels:  []string{"VM1"},
+		Metrics: []string{"VM2.Node1.nonce"},
+	}
+	assert.True(t, dashboard.Contains("VM1.Node1.nonce", "VM1"))
+	assert.True(t, dashboard.Contains("VM2.Node1.nonce", "VM2"))
+	assert.False(t, dashboard.Contains("VM2.Node2.nonce", "VM2"))
+}
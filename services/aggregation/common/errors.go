@@ -0,0 +1,39 @@
+package common
+
+import "errors"
+
+// ErrMetricNotFound signals that the requested metric does not exist
+var ErrMetricNotFound = errors.New("metric not found")
+
+// ErrMetricAlreadyExists signals that a metric with the same name already exists
+var ErrMetricAlreadyExists = errors.New("metric already exists")
+
+// ErrMetricTenantMismatch signals that the metric is owned by another tenant
+var ErrMetricTenantMismatch = errors.New("metric belongs to another tenant")
+
+// ErrAnnotationNotFound signals that the requested metric annotation does not exist
+var ErrAnnotationNotFound = errors.New("annotation not found")
+
+// ErrDashboardNotFound signals that the requested dashboard does not exist
+var ErrDashboardNotFound = errors.New("dashboard not found")
+
+// ErrAlertNotFound signals that the requested alert does not exist
+var ErrAlertNotFound = errors.New("alert not found")
+
+// ErrInvalidAlertTransition signals that the alert can not move to the requested state from the current one
+var ErrInvalidAlertTransition = errors.New("invalid alert state transition")
+
+// ErrSilenceNotFound signals that the requested silence does not exist
+var ErrSilenceNotFound = errors.New("silence not found")
+
+// ErrSessionNotFound signals that the requested session does not exist
+var ErrSessionNotFound = errors.New("session not found")
+
+// ErrTOTPNotEnrolled signals that the account did not enroll for the two-factor authentication
+var ErrTOTPNotEnrolled = errors.New("two-factor authentication not enrolled")
+
+// ErrDashboardAlreadyExists signals that a dashboard with the same name already exists
+var ErrDashboardAlreadyExists = errors.New("dashboard already exists")
+
+// ErrMetricNotNumeric signals that the requested operation only applies to the numeric metrics
+var ErrMetricNotNumeric = errors.New("metric is not numeric")
@@ -0,0 +1,55 @@
+package common
+
+import "strings"
+
+// NumIntervalsToConsiderStale is the number of missed reports after which a metric with a known expected interval is
+// considered stale
+const NumIntervalsToConsiderStale = 3
+
+// AgentHeartbeat holds the last heartbeat of an agent
+type AgentHeartbeat struct {
+	RecordedAt       int64
+	ExpectedInterval int
+}
+
+// AgentHeartbeats holds the last heartbeat of each agent, keyed by the agent name (the part of the heartbeat metric
+// name before the .Active suffix)
+type AgentHeartbeats map[string]AgentHeartbeat
+
+// CollectHeartbeats extracts the last heartbeat of each agent from the latest metric values
+func CollectHeartbeats(latest []MetricHistory) AgentHeartbeats {
+	heartbeats := make(AgentHeartbeats)
+	for _, metric := range latest {
+		agent, isHeartbeat := strings.CutSuffix(metric.Name, nameSeparator+HeartbeatMetricSuffix)
+		if !isHeartbeat || len(agent) == 0 || len(metric.History) == 0 {
+			continue
+		}
+
+		heartbeats[agent] = AgentHeartbeat{
+			RecordedAt:       metric.History[0].RecordedAt,
+			ExpectedInterval: metric.ExpectedInterval,
+		}
+	}
+
+	return heartbeats
+}
+
+// IsAgentDown returns true if the metric belongs to an agent whose heartbeat is stale, as computed by StaleSeconds. A
+// metric belongs to the agent whose name, followed by a dot, prefixes the metric name (the longest one if several
+// match). The metrics without a known agent are never reported as belonging to a down agent.
+func (heartbeats AgentHeartbeats) IsAgentDown(metricName string, now int64, staleSeconds int64) bool {
+	for end := strings.LastIndex(metricName, nameSeparator); end > 0; end = strings.LastIndex(metricName[:end], nameSeparator) {
+		lastHeartbeat, found := heartbeats[metricName[:end]]
+		if found {
+			return now-lastHeartbeat.RecordedAt >= StaleSeconds(lastHeartbeat.ExpectedInterval, staleSeconds)
+		}
+	}
+
+	return false
+}
+
+// StaleSeconds returns the age after which a value is stale: the configured one, extended to
+// NumIntervalsToConsiderStale expected intervals for the metrics reported less often
+func StaleSeconds(expectedInterval int, staleSeconds int64) int64 {
+	return max(staleSeconds, int64(NumIntervalsToConsiderStale)*int64(expectedInterval))
+}
@@ -0,0 +1,53 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAgentHeartbeats(t *testing.T) {
+	t.Parallel()
+
+	heartbeats := CollectHeartbeats([]MetricHistory{
+		{Name: "VM1.Active", History: []MetricValue{{Value: "true", RecordedAt: 1000}}},
+		{Name: "VM1.Node1.nonce", History: []MetricValue{{Value: "10", RecordedAt: 1000}}},
+		{Name: "VM2.Active", History: []MetricValue{{Value: "true", RecordedAt: 1500}}},
+		{Name: "DC1.VM1.Active", History: []MetricValue{{Value: "true", RecordedAt: 1500}}},
+		{Name: "Active", History: []MetricValue{{Value: "true", RecordedAt: 1500}}},
+		{Name: "VM3.Active"},
+	})
+	assert.Equal(t, AgentHeartbeats{
+		"VM1":     {RecordedAt: 1000},
+		"VM2":     {RecordedAt: 1500},
+		"DC1.VM1": {RecordedAt: 1500},
+	}, heartbeats)
+
+	assert.True(t, heartbeats.IsAgentDown("VM1.Node1.nonce", 1300, 300))
+	assert.True(t, heartbeats.IsAgentDown("VM1.Active", 1300, 300))
+	assert.False(t, heartbeats.IsAgentDown("VM1.Node1.nonce", 1299, 300))
+	assert.False(t, heartbeats.IsAgentDown("VM2.Node1.nonce", 1300, 300))
+	// the longest agent name wins
+	assert.False(t, heartbeats.IsAgentDown("DC1.VM1.Node1.nonce", 1300, 300))
+	// unknown agents
+	assert.False(t, heartbeats.IsAgentDown("VM3.Node1.nonce", 1300, 300))
+	assert.False(t, heartbeats.IsAgentDown("VM1", 1300, 300))
+	assert.False(t, heartbeats.IsAgentDown("mock-api", 1300, 300))
+
+	// the agents reporting less often are down after missing a few reports
+	heartbeats = CollectHeartbeats([]MetricHistory{
+		{Name: "VM1.Active", ExpectedInterval: 600, History: []MetricValue{{Value: "true", RecordedAt: 1000}}},
+		{Name: "VM2.Active", ExpectedInterval: 10, History: []MetricValue{{Value: "true", RecordedAt: 1000}}},
+	})
+	assert.False(t, heartbeats.IsAgentDown("VM1.Node1.nonce", 2799, 300))
+	assert.True(t, heartbeats.IsAgentDown("VM1.Node1.nonce", 2800, 300))
+	assert.True(t, heartbeats.IsAgentDown("VM2.Node1.nonce", 1300, 300))
+}
+
+func TestStaleSeconds(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, int64(300), StaleSeconds(0, 300))
+	assert.Equal(t, int64(300), StaleSeconds(60, 300))
+	assert.Equal(t, int64(1800), StaleSeconds(600, 300))
+}
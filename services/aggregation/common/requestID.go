@@ -0,0 +1,17 @@
+package common
+
+import "context"
+
+type requestIDContextKey struct{}
+
+// WithRequestID returns a copy of the context carrying the ID of the HTTP request being served
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, requestID)
+}
+
+// RequestIDFromContext returns the ID of the HTTP request the context belongs to, empty for the background work
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDContextKey{}).(string)
+
+	return requestID
+}
@@ -0,0 +1,17 @@
+package common
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestIDFromContext(t *testing.T) {
+	t.Parallel()
+
+	assert.Empty(t, RequestIDFromContext(context.Background()))
+
+	ctx := WithRequestID(context.Background(), "request-1")
+	assert.Equal(t, "request-1", RequestIDFromContext(ctx))
+}
@@ -0,0 +1,95 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+)
+
+const nameSeparator = "."
+const tagSeparator = ":"
+
+// TenantNameSeparator separates the tenant from the reported name in the stored names of the tenant metrics. The
+// tenant names can not contain it, so each tenant owns its own metric namespace.
+const TenantNameSeparator = ":"
+
+// TagFilter defines a single key:value tag condition
+type TagFilter struct {
+	Key   string
+	Value string
+}
+
+// ParseTagsFromName derives the tags from a dotted metric name, following the <vm>.<node...>.<kind> convention.
+// Examples: VM1.Node1.nonce => vm=VM1, node=Node1, kind=nonce; VM1.Active => vm=VM1, kind=Active
+func ParseTagsFromName(name string) map[string]string {
+	parts := strings.Split(name, nameSeparator)
+	if len(parts) < 2 {
+		return nil
+	}
+
+	tags := map[string]string{
+		TagVM:   parts[0],
+		TagKind: parts[len(parts)-1],
+	}
+	if len(parts) > 2 {
+		tags[TagNode] = strings.Join(parts[1:len(parts)-1], nameSeparator)
+	}
+
+	return tags
+}
+
+// TenantMetricName returns the stored name of a metric reported by the agents of a tenant, the names of the default
+// tenant being stored as reported. Example: acme, VM1.Node1.nonce => acme:VM1.Node1.nonce
+func TenantMetricName(tenant string, name string) string {
+	if len(tenant) == 0 {
+		return name
+	}
+
+	return tenant + TenantNameSeparator + name
+}
+
+// MergeTags returns the tags derived from the metric name overwritten by the explicitly provided tags
+func MergeTags(name string, provided map[string]string) map[string]string {
+	tags := ParseTagsFromName(name)
+	if len(provided) == 0 {
+		return tags
+	}
+	if tags == nil {
+		tags = make(map[string]string, len(provided))
+	}
+
+	for key, value := range provided {
+		key = strings.TrimSpace(key)
+		if len(key) == 0 {
+			continue
+		}
+		tags[key] = value
+	}
+
+	return tags
+}
+
+// ParseTagFilter parses a filter expressed as key:value
+func ParseTagFilter(text string) (TagFilter, error) {
+	key, value, found := strings.Cut(text, tagSeparator)
+	key = strings.TrimSpace(key)
+	if !found || len(key) == 0 {
+		return TagFilter{}, fmt.Errorf("invalid tag filter %q, expected key:value", text)
+	}
+
+	return TagFilter{
+		Key:   key,
+		Value: value,
+	}, nil
+}
+
+// MatchesTags returns true if all the filters are satisfied by the provided tags
+func MatchesTags(tags map[string]string, filters []TagFilter) bool {
+	for _, filter := range filters {
+		value, found := tags[filter.Key]
+		if !found || value != filter.Value {
+			return false
+		}
+	}
+
+	return true
+}
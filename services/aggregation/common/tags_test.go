@@ -0,0 +1,66 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTagsFromName(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, ParseTagsFromName("mock-api"))
+	assert.Equal(t, map[string]string{"vm": "VM1", "kind": "Active"}, ParseTagsFromName("VM1.Active"))
+	assert.Equal(t, map[string]string{"vm": "VM1", "node": "Node1", "kind": "nonce"}, ParseTagsFromName("VM1.Node1.nonce"))
+	assert.Equal(t, map[string]string{"vm": "VM1", "node": "Node1.shard", "kind": "nonce"}, ParseTagsFromName("VM1.Node1.shard.nonce"))
+}
+
+func TestTenantMetricName(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "VM1.Node1.nonce", TenantMetricName("", "VM1.Node1.nonce"))
+	assert.Equal(t, "acme:VM1.Node1.nonce", TenantMetricName("acme", "VM1.Node1.nonce"))
+	assert.Equal(t, map[string]string{"vm": "acme:VM1", "node": "Node1", "kind": "nonce"},
+		ParseTagsFromName(TenantMetricName("acme", "VM1.Node1.nonce")))
+}
+
+func TestMergeTags(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, MergeTags("mock-api", nil))
+	assert.Equal(t, map[string]string{"env": "prod"}, MergeTags("mock-api", map[string]string{"env": "prod", " ": "ignored"}))
+	assert.Equal(t,
+		map[string]string{"vm": "VM1", "node": "Validator", "kind": "nonce", "shard": "0"},
+		MergeTags("VM1.Node1.nonce", map[string]string{"node": "Validator", "shard": "0"}),
+	)
+}
+
+func TestParseTagFilter(t *testing.T) {
+	t.Parallel()
+
+	filter, err := ParseTagFilter("vm:VM1")
+	assert.Nil(t, err)
+	assert.Equal(t, TagFilter{Key: "vm", Value: "VM1"}, filter)
+
+	filter, err = ParseTagFilter("url:http://host")
+	assert.Nil(t, err)
+	assert.Equal(t, TagFilter{Key: "url", Value: "http://host"}, filter)
+
+	_, err = ParseTagFilter("vm")
+	assert.NotNil(t, err)
+
+	_, err = ParseTagFilter(":VM1")
+	assert.NotNil(t, err)
+}
+
+func TestMatchesTags(t *testing.T) {
+	t.Parallel()
+
+	tags := map[string]string{"vm": "VM1", "kind": "nonce"}
+	assert.True(t, MatchesTags(tags, nil))
+	assert.True(t, MatchesTags(tags, []TagFilter{{Key: "vm", Value: "VM1"}}))
+	assert.True(t, MatchesTags(tags, []TagFilter{{Key: "vm", Value: "VM1"}, {Key: "kind", Value: "nonce"}}))
+	assert.False(t, MatchesTags(tags, []TagFilter{{Key: "vm", Value: "VM2"}}))
+	assert.False(t, MatchesTags(tags, []TagFilter{{Key: "node", Value: "Node1"}}))
+	assert.False(t, MatchesTags(nil, []TagFilter{{Key: "vm", Value: "VM1"}}))
+}
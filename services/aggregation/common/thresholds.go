@@ -0,0 +1,85 @@
+package common
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+)
+
+// MetricStatus is the visual status of a metric value, evaluated against the thresholds of the metric
+type MetricStatus string
+
+// defined constants for the MetricStatus
+const (
+	MetricStatusOK       MetricStatus = "ok"
+	MetricStatusWarning  MetricStatus = "warn"
+	MetricStatusCritical MetricStatus = "crit"
+)
+
+// MetricThresholds holds the display boundaries of a metric. The numeric metrics use the Warn and Crit boundaries,
+// the other metrics the Expected values.
+type MetricThresholds struct {
+	Warn *float64 `json:"warn,omitempty"`
+	Crit *float64 `json:"crit,omitempty"`
+	// Below makes the values under the boundaries the problems (e.g. a free disk space), instead of the ones over them
+	Below bool `json:"below,omitempty"`
+	// Expected lists the values considered ok, any other value being critical
+	Expected []string `json:"expected,omitempty"`
+}
+
+// IsEmpty returns true if no boundary and no expected value is defined
+func (thresholds *MetricThresholds) IsEmpty() bool {
+	return thresholds.Warn == nil && thresholds.Crit == nil && len(thresholds.Expected) == 0
+}
+
+// Validate checks that the boundaries are ordered in the direction of the thresholds
+func (thresholds *MetricThresholds) Validate() error {
+	if len(thresholds.Expected) > 0 && (thresholds.Warn != nil || thresholds.Crit != nil) {
+		return errors.New("the expected values can not be combined with the warn and crit boundaries")
+	}
+	if thresholds.Warn == nil || thresholds.Crit == nil {
+		return nil
+	}
+	if thresholds.Below && *thresholds.Crit > *thresholds.Warn {
+		return errors.New("the crit boundary must not be over the warn boundary")
+	}
+	if !thresholds.Below && *thresholds.Crit < *thresholds.Warn {
+		return errors.New("the crit boundary must not be under the warn boundary")
+	}
+
+	return nil
+}
+
+// Evaluate returns the status of the value, empty if the value can not be evaluated (e.g. a non-numeric value against
+// the numeric boundaries)
+func (thresholds *MetricThresholds) Evaluate(value string) MetricStatus {
+	if len(thresholds.Expected) > 0 {
+		if slices.Contains(thresholds.Expected, value) {
+			return MetricStatusOK
+		}
+		return MetricStatusCritical
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return ""
+	}
+
+	crosses := func(boundary *float64) bool {
+		if boundary == nil {
+			return false
+		}
+		if thresholds.Below {
+			return number <= *boundary
+		}
+		return number >= *boundary
+	}
+	switch {
+	case crosses(thresholds.Crit):
+		return MetricStatusCritical
+	case crosses(thresholds.Warn):
+		return MetricStatusWarning
+	default:
+		return MetricStatusOK
+	}
+}
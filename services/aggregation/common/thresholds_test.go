@@ -0,0 +1,61 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricThresholds_Validate(t *testing.T) {
+	t.Parallel()
+
+	warn, crit := 80.0, 90.0
+	assert.Nil(t, (&MetricThresholds{}).Validate())
+	assert.Nil(t, (&MetricThresholds{Warn: &warn}).Validate())
+	assert.Nil(t, (&MetricThresholds{Warn: &warn, Crit: &crit}).Validate())
+	assert.NotNil(t, (&MetricThresholds{Warn: &crit, Crit: &warn}).Validate())
+	assert.Nil(t, (&MetricThresholds{Warn: &crit, Crit: &warn, Below: true}).Validate())
+	assert.NotNil(t, (&MetricThresholds{Warn: &warn, Crit: &crit, Below: true}).Validate())
+	assert.NotNil(t, (&MetricThresholds{Warn: &warn, Expected: []string{"true"}}).Validate())
+}
+
+func TestMetricThresholds_Evaluate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("numeric boundaries", func(t *testing.T) {
+		t.Parallel()
+
+		warn, crit := 80.0, 90.0
+		thresholds := &MetricThresholds{Warn: &warn, Crit: &crit}
+		assert.Equal(t, MetricStatusOK, thresholds.Evaluate("79.9"))
+		assert.Equal(t, MetricStatusWarning, thresholds.Evaluate("80"))
+		assert.Equal(t, MetricStatusCritical, thresholds.Evaluate("95"))
+		assert.Equal(t, MetricStatus(""), thresholds.Evaluate("n/a"))
+		assert.False(t, thresholds.IsEmpty())
+	})
+	t.Run("numeric boundaries below", func(t *testing.T) {
+		t.Parallel()
+
+		warn, crit := 20.0, 10.0
+		thresholds := &MetricThresholds{Warn: &warn, Crit: &crit, Below: true}
+		assert.Equal(t, MetricStatusOK, thresholds.Evaluate("50"))
+		assert.Equal(t, MetricStatusWarning, thresholds.Evaluate("15"))
+		assert.Equal(t, MetricStatusCritical, thresholds.Evaluate("10"))
+	})
+	t.Run("only the crit boundary", func(t *testing.T) {
+		t.Parallel()
+
+		crit := 1.0
+		thresholds := &MetricThresholds{Crit: &crit}
+		assert.Equal(t, MetricStatusOK, thresholds.Evaluate("0"))
+		assert.Equal(t, MetricStatusCritical, thresholds.Evaluate("1"))
+	})
+	t.Run("expected values", func(t *testing.T) {
+		t.Parallel()
+
+		thresholds := &MetricThresholds{Expected: []string{"synced", "syncing"}}
+		assert.Equal(t, MetricStatusOK, thresholds.Evaluate("syncing"))
+		assert.Equal(t, MetricStatusCritical, thresholds.Evaluate("stuck"))
+		assert.True(t, (&MetricThresholds{}).IsEmpty())
+	})
+}
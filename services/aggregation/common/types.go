@@ -25,3 +25,115 @@ func (messageOutputType MessageOutputType) String() string {
 		return fmt.Sprintf("unknown MessageOutputType: %d", messageOutputType)
 	}
 }
+
+// AlertState defines the state of an alert, also used for the alert transition a message reports. The
+// informational messages (e.g. the daily digest) do not carry a state.
+type AlertState string
+
+// defined constants for the AlertState
+const (
+	AlertStateNone         AlertState = ""
+	AlertStatePending      AlertState = "pending"
+	AlertStateFiring       AlertState = "firing"
+	AlertStateAcknowledged AlertState = "acknowledged"
+	AlertStateResolved     AlertState = "resolved"
+)
+
+// IsValid returns true if the alert state is a known one
+func (state AlertState) IsValid() bool {
+	switch state {
+	case AlertStatePending, AlertStateFiring, AlertStateAcknowledged, AlertStateResolved:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsActive returns true if the alert is not resolved yet
+func (state AlertState) IsActive() bool {
+	return state.IsValid() && state != AlertStateResolved
+}
+
+// CanTransitionTo returns true if the alert can move from the current state to the provided one:
+// pending -> firing -> acknowledged -> resolved, an active alert being resolvable from any state
+func (state AlertState) CanTransitionTo(newState AlertState) bool {
+	switch newState {
+	case AlertStateFiring:
+		return state == AlertStatePending
+	case AlertStateAcknowledged:
+		return state == AlertStateFiring
+	case AlertStateResolved:
+		return state.IsActive()
+	default:
+		return false
+	}
+}
+
+// IsNumericMetricType returns true if the provided metric type holds numeric values
+func IsNumericMetricType(metricType string) bool {
+	return metricType == MetricTypeUint64 || metricType == MetricTypeFloat64
+}
+
+// GapMode defines how the missing intervals are represented in the history responses
+type GapMode string
+
+// defined constants for the GapMode
+const (
+	GapModeNone GapMode = ""     // raw samples, missing intervals are not represented
+	GapModeHold GapMode = "hold" // the last known value is carried over the missing intervals
+	GapModeNull GapMode = "null" // the missing intervals contain explicit null values
+	GapModeZero GapMode = "zero" // the missing intervals contain zero values
+)
+
+// IsValid returns true if the gap mode is a known one
+func (gapMode GapMode) IsValid() bool {
+	switch gapMode {
+	case GapModeNone, GapModeHold, GapModeNull, GapModeZero:
+		return true
+	default:
+		return false
+	}
+}
+
+// AggregationMode defines the single value of a metric exposed over its NumAggregation window
+type AggregationMode string
+
+// defined constants for the AggregationMode
+const (
+	AggregationModeLast AggregationMode = "last" // the latest value, no aggregated value is exposed
+	AggregationModeAvg  AggregationMode = "avg"  // the average of the values in the window
+	AggregationModeMin  AggregationMode = "min"  // the minimum of the values in the window
+	AggregationModeMax  AggregationMode = "max"  // the maximum of the values in the window
+	AggregationModeSum  AggregationMode = "sum"  // the sum of the values in the window
+)
+
+// IsValid returns true if the aggregation mode is a known one
+func (aggregationMode AggregationMode) IsValid() bool {
+	switch aggregationMode {
+	case AggregationModeLast, AggregationModeAvg, AggregationModeMin, AggregationModeMax, AggregationModeSum:
+		return true
+	default:
+		return false
+	}
+}
+
+// MetricsSortField defines the field used to sort the metrics listing
+type MetricsSortField string
+
+// defined constants for the MetricsSortField
+const (
+	SortByName         MetricsSortField = "name"
+	SortByType         MetricsSortField = "type"
+	SortByDisplayOrder MetricsSortField = "displayOrder"
+	SortByRecordedAt   MetricsSortField = "recordedAt"
+)
+
+// IsValid returns true if the sort field is a known one
+func (field MetricsSortField) IsValid() bool {
+	switch field {
+	case SortByName, SortByType, SortByDisplayOrder, SortByRecordedAt:
+		return true
+	default:
+		return false
+	}
+}
@@ -15,3 +15,69 @@ func TestMessageOutputType_String(t *testing.T) {
 	assert.Equal(t, "error", ErrorMessageOutputType.String())
 	assert.Equal(t, "unknown MessageOutputType: 100", MessageOutputType(100).String())
 }
+
+func TestIsNumericMetricType(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, IsNumericMetricType(MetricTypeUint64))
+	assert.True(t, IsNumericMetricType(MetricTypeFloat64))
+	assert.False(t, IsNumericMetricType(MetricTypeString))
+	assert.False(t, IsNumericMetricType(MetricTypeBool))
+	assert.False(t, IsNumericMetricType(""))
+}
+
+func TestGapMode_IsValid(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, GapModeNone.IsValid())
+	assert.True(t, GapModeHold.IsValid())
+	assert.True(t, GapModeNull.IsValid())
+	assert.True(t, GapModeZero.IsValid())
+	assert.False(t, GapMode("interpolate").IsValid())
+}
+
+func TestAggregationMode_IsValid(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, AggregationModeLast.IsValid())
+	assert.True(t, AggregationModeAvg.IsValid())
+	assert.True(t, AggregationModeMin.IsValid())
+	assert.True(t, AggregationModeMax.IsValid())
+	assert.True(t, AggregationModeSum.IsValid())
+	assert.False(t, AggregationMode("").IsValid())
+	assert.False(t, AggregationMode("median").IsValid())
+}
+
+func TestMetricsSortField_IsValid(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, SortByName.IsValid())
+	assert.True(t, SortByType.IsValid())
+	assert.True(t, SortByDisplayOrder.IsValid())
+	assert.True(t, SortByRecordedAt.IsValid())
+	assert.False(t, MetricsSortField("").IsValid())
+	assert.False(t, MetricsSortField("value").IsValid())
+}
+
+func TestAlertState(t *testing.T) {
+	t.Parallel()
+
+	assert.False(t, AlertStateNone.IsValid())
+	assert.False(t, AlertState("closed").IsValid())
+	assert.True(t, AlertStatePending.IsActive())
+	assert.True(t, AlertStateFiring.IsActive())
+	assert.True(t, AlertStateAcknowledged.IsActive())
+	assert.False(t, AlertStateResolved.IsActive())
+	assert.False(t, AlertStateNone.IsActive())
+
+	assert.True(t, AlertStatePending.CanTransitionTo(AlertStateFiring))
+	assert.True(t, AlertStatePending.CanTransitionTo(AlertStateResolved))
+	assert.False(t, AlertStatePending.CanTransitionTo(AlertStateAcknowledged))
+	assert.True(t, AlertStateFiring.CanTransitionTo(AlertStateAcknowledged))
+	assert.True(t, AlertStateFiring.CanTransitionTo(AlertStateResolved))
+	assert.False(t, AlertStateFiring.CanTransitionTo(AlertStatePending))
+	assert.True(t, AlertStateAcknowledged.CanTransitionTo(AlertStateResolved))
+	assert.False(t, AlertStateAcknowledged.CanTransitionTo(AlertStateFiring))
+	assert.False(t, AlertStateResolved.CanTransitionTo(AlertStateFiring))
+	assert.False(t, AlertStateResolved.CanTransitionTo(AlertStateResolved))
+}
@@ -0,0 +1,185 @@
+package computed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/commonGo"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/multiversx/mx-chain-core-go/core/check"
+)
+
+const (
+	nonceSuffix        = ".nonce"
+	shardSuffix        = ".shard"
+	blocksBehindSuffix = ".blocksBehind"
+	shardTag           = "shard"
+	maxReferenceSize   = 1024 * 1024
+)
+
+// ArgsBlocksBehindHandler defines the DTO struct for the NewBlocksBehindHandler constructor function
+type ArgsBlocksBehindHandler struct {
+	Store Storage
+	// ReferenceURL is the base URL of the MultiversX proxy providing the highest nonce of each shard
+	ReferenceURL              string
+	Timeout                   time.Duration
+	NumAggregation            int
+	NumSecondsToConsiderStale int
+}
+
+type blocksBehindHandler struct {
+	store                     Storage
+	referenceURL              string
+	client                    *http.Client
+	numAggregation            int
+	numSecondsToConsiderStale int64
+	timeFunc                  func() time.Time
+}
+
+// networkStatusResponse is the part of the proxy /network/status/<shard> response holding the shard nonce
+type networkStatusResponse struct {
+	Data struct {
+		Status struct {
+			Nonce *uint64 `json:"erd_nonce"`
+		} `json:"status"`
+	} `json:"data"`
+}
+
+// NewBlocksBehindHandler creates a new handler computing the <node>.blocksBehind metric of every <node>.nonce metric
+func NewBlocksBehindHandler(args ArgsBlocksBehindHandler) (*blocksBehindHandler, error) {
+	if check.IfNil(args.Store) {
+		return nil, errNilStorage
+	}
+	if !commonGo.IsHTTPURL(args.ReferenceURL) {
+		return nil, fmt.Errorf("%w: %q", errInvalidReferenceURL, args.ReferenceURL)
+	}
+	if args.NumAggregation < 1 {
+		return nil, fmt.Errorf("%w for the blocks behind metrics: %d", errInvalidNumAggregation, args.NumAggregation)
+	}
+
+	return &blocksBehindHandler{
+		store:                     args.Store,
+		referenceURL:              strings.TrimRight(args.ReferenceURL, "/"),
+		client:                    &http.Client{Timeout: args.Timeout},
+		numAggregation:            args.NumAggregation,
+		numSecondsToConsiderStale: int64(args.NumSecondsToConsiderStale),
+		timeFunc:                  time.Now,
+	}, nil
+}
+
+// Execute saves, for each node reporting a fresh <node>.nonce metric, the number of blocks it is behind the highest
+// nonce of its shard. The shard is read from the shard tag of the nonce metric or from the <node>.shard metric, the
+// nodes with an unknown shard are skipped. The highest nonces are fetched once per execution.
+func (handler *blocksBehindHandler) Execute(ctx context.Context) error {
+	latest, err := handler.store.GetLatestMetrics(ctx)
+	if err != nil {
+		return fmt.Errorf("%w while fetching the latest metrics", err)
+	}
+
+	now := handler.timeFunc().Unix()
+	values := make(map[string]string, len(latest))
+	for _, metric := range latest {
+		if len(metric.History) == 0 || metric.History[0].RecordedAt == 0 {
+			continue
+		}
+		if handler.numSecondsToConsiderStale > 0 && now-metric.History[0].RecordedAt >= handler.numSecondsToConsiderStale {
+			continue
+		}
+		values[metric.Name] = metric.History[0].Value
+	}
+
+	referenceNonces := make(map[uint32]uint64)
+	for _, metric := range latest {
+		node, isNonce := strings.CutSuffix(metric.Name, nonceSuffix)
+		value, isFresh := values[metric.Name]
+		if !isNonce || !isFresh {
+			continue
+		}
+		nonce, errParse := strconv.ParseUint(value, 10, 64)
+		if errParse != nil {
+			continue
+		}
+		shard, found := handler.shardOf(metric, values[node+shardSuffix])
+		if !found {
+			log.Debug("skipping the blocks behind metric, unknown shard", "metric", metric.Name)
+			continue
+		}
+
+		referenceNonce, found := referenceNonces[shard]
+		if !found {
+			referenceNonce, err = handler.fetchReferenceNonce(ctx, shard)
+			if err != nil {
+				return fmt.Errorf("%w while fetching the reference nonce of shard %d", err, shard)
+			}
+			referenceNonces[shard] = referenceNonce
+		}
+
+		blocksBehind := uint64(0)
+		if referenceNonce > nonce {
+			blocksBehind = referenceNonce - nonce
+		}
+		err = handler.store.SaveMetric(ctx, node+blocksBehindSuffix, common.MetricTypeUint64, handler.numAggregation,
+			strconv.FormatUint(blocksBehind, 10), now)
+		if err != nil {
+			log.Warn("failed to save the blocks behind metric", "metric", node+blocksBehindSuffix, "error", err)
+		}
+	}
+
+	return nil
+}
+
+func (handler *blocksBehindHandler) shardOf(metric common.MetricHistory, shardValue string) (uint32, bool) {
+	tagValue, hasTag := metric.Tags[shardTag]
+	if hasTag {
+		shardValue = tagValue
+	}
+
+	shard, err := strconv.ParseUint(shardValue, 10, 32)
+	if err != nil {
+		return 0, false
+	}
+
+	return uint32(shard), true
+}
+
+func (handler *blocksBehindHandler) fetchReferenceNonce(ctx context.Context, shard uint32) (uint64, error) {
+	address := fmt.Sprintf("%s/network/status/%d", handler.referenceURL, shard)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, address, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := handler.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("%w %d from %s", errUnexpectedStatus, resp.StatusCode, address)
+	}
+
+	response := networkStatusResponse{}
+	err = json.NewDecoder(io.LimitReader(resp.Body, maxReferenceSize)).Decode(&response)
+	if err != nil {
+		return 0, err
+	}
+	if response.Data.Status.Nonce == nil {
+		return 0, fmt.Errorf("%w from %s", errMissingReferenceNonce, address)
+	}
+
+	return *response.Data.Status.Nonce, nil
+}
+
+// IsInterfaceNil returns true if there is no value under the interface
+func (handler *blocksBehindHandler) IsInterfaceNil() bool {
+	return handler == nil
+}
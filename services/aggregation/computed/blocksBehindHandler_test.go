@@ -0,0 +1,180 @@
+package computed
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/testsCommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createReferenceServer(t *testing.T) (string, *sync.Map) {
+	numRequests := &sync.Map{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		counter, _ := numRequests.LoadOrStore(r.URL.Path, new(int))
+		*counter.(*int)++
+
+		switch r.URL.Path {
+		case "/network/status/0":
+			_, _ = w.Write([]byte(`{"data": {"status": {"erd_nonce": 1000}}, "code": "successful"}`))
+		case "/network/status/4294967295":
+			_, _ = w.Write([]byte(`{"data": {"status": {"erd_nonce": 500}}, "code": "successful"}`))
+		case "/network/status/1":
+			_, _ = w.Write([]byte(`{"data": {}, "code": "successful"}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server.URL + "/", numRequests
+}
+
+func createMockArgsBlocksBehindHandler(store Storage, referenceURL string) ArgsBlocksBehindHandler {
+	return ArgsBlocksBehindHandler{
+		Store:                     store,
+		ReferenceURL:              referenceURL,
+		Timeout:                   time.Second,
+		NumAggregation:            100,
+		NumSecondsToConsiderStale: 60,
+	}
+}
+
+func TestNewBlocksBehindHandler(t *testing.T) {
+	t.Parallel()
+
+	t.Run("nil storage should error", func(t *testing.T) {
+		t.Parallel()
+
+		handler, err := NewBlocksBehindHandler(createMockArgsBlocksBehindHandler(nil, "https://gateway.multiversx.com"))
+		assert.Nil(t, handler)
+		assert.Equal(t, errNilStorage, err)
+	})
+	t.Run("invalid reference URL should error", func(t *testing.T) {
+		t.Parallel()
+
+		handler, err := NewBlocksBehindHandler(createMockArgsBlocksBehindHandler(&testsCommon.StoreStub{}, "gateway"))
+		assert.Nil(t, handler)
+		assert.True(t, errors.Is(err, errInvalidReferenceURL))
+	})
+	t.Run("invalid num aggregation should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsBlocksBehindHandler(&testsCommon.StoreStub{}, "https://gateway.multiversx.com")
+		args.NumAggregation = 0
+		handler, err := NewBlocksBehindHandler(args)
+		assert.Nil(t, handler)
+		assert.True(t, errors.Is(err, errInvalidNumAggregation))
+	})
+	t.Run("should work", func(t *testing.T) {
+		t.Parallel()
+
+		handler, err := NewBlocksBehindHandler(
+			createMockArgsBlocksBehindHandler(&testsCommon.StoreStub{}, "https://gateway.multiversx.com/"))
+		require.Nil(t, err)
+		assert.False(t, handler.IsInterfaceNil())
+		assert.Equal(t, "https://gateway.multiversx.com", handler.referenceURL)
+	})
+}
+
+func TestBlocksBehindHandler_Execute(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(10000, 0)
+	value := func(val string, age int64) []common.MetricValue {
+		return []common.MetricValue{{Value: val, RecordedAt: now.Unix() - age}}
+	}
+
+	t.Run("storage errors should error", func(t *testing.T) {
+		t.Parallel()
+
+		expectedErr := errors.New("expected error")
+		store := &testsCommon.StoreStub{
+			GetLatestMetricsHandler: func(ctx context.Context) ([]common.MetricHistory, error) {
+				return nil, expectedErr
+			},
+		}
+
+		handler, _ := NewBlocksBehindHandler(createMockArgsBlocksBehindHandler(store, "https://gateway.multiversx.com"))
+		err := handler.Execute(context.Background())
+		assert.True(t, errors.Is(err, expectedErr))
+	})
+	t.Run("should compute and save the metrics", func(t *testing.T) {
+		t.Parallel()
+
+		referenceURL, numRequests := createReferenceServer(t)
+		saved := make([]savedMetric, 0)
+		store := &testsCommon.StoreStub{
+			GetLatestMetricsHandler: func(ctx context.Context) ([]common.MetricHistory, error) {
+				return []common.MetricHistory{
+					{Name: "VM1.Node1.nonce", History: value("990", 1), Tags: map[string]string{"shard": "0"}},
+					{Name: "VM1.Node2.nonce", History: value("1002", 1), Tags: map[string]string{"shard": "0"}},
+					{Name: "VM1.Node3.nonce", History: value("480", 1)},
+					{Name: "VM1.Node3.shard", History: value("4294967295", 1)},
+					// unknown shard
+					{Name: "VM1.Node4.nonce", History: value("10", 1)},
+					// stale nonce
+					{Name: "VM1.Node5.nonce", History: value("10", 60), Tags: map[string]string{"shard": "0"}},
+					// not a nonce metric
+					{Name: "VM1.Node1.epoch", History: value("10", 1), Tags: map[string]string{"shard": "0"}},
+				}, nil
+			},
+			SaveMetricHandler: func(ctx context.Context, name string, metricType string, numAggregation int, valString string, recordedAt int64) error {
+				saved = append(saved, savedMetric{
+					name:           name,
+					metricType:     metricType,
+					numAggregation: numAggregation,
+					value:          valString,
+					recordedAt:     recordedAt,
+				})
+				return nil
+			},
+		}
+
+		handler, _ := NewBlocksBehindHandler(createMockArgsBlocksBehindHandler(store, referenceURL))
+		handler.timeFunc = func() time.Time {
+			return now
+		}
+
+		err := handler.Execute(context.Background())
+		require.Nil(t, err)
+
+		expected := []savedMetric{
+			{name: "VM1.Node1.blocksBehind", metricType: common.MetricTypeUint64, numAggregation: 100, value: "10", recordedAt: now.Unix()},
+			{name: "VM1.Node2.blocksBehind", metricType: common.MetricTypeUint64, numAggregation: 100, value: "0", recordedAt: now.Unix()},
+			{name: "VM1.Node3.blocksBehind", metricType: common.MetricTypeUint64, numAggregation: 100, value: "20", recordedAt: now.Unix()},
+		}
+		assert.Equal(t, expected, saved)
+
+		// the reference nonce of a shard is fetched once per execution
+		counter, _ := numRequests.Load("/network/status/0")
+		assert.Equal(t, 1, *counter.(*int))
+	})
+	t.Run("missing reference nonce should error", func(t *testing.T) {
+		t.Parallel()
+
+		referenceURL, _ := createReferenceServer(t)
+		store := &testsCommon.StoreStub{
+			GetLatestMetricsHandler: func(ctx context.Context) ([]common.MetricHistory, error) {
+				return []common.MetricHistory{
+					{Name: "VM1.Node1.nonce", History: value("990", 1), Tags: map[string]string{"shard": "1"}},
+				}, nil
+			},
+		}
+
+		handler, _ := NewBlocksBehindHandler(createMockArgsBlocksBehindHandler(store, referenceURL))
+		handler.timeFunc = func() time.Time {
+			return now
+		}
+
+		err := handler.Execute(context.Background())
+		assert.True(t, errors.Is(err, errMissingReferenceNonce))
+	})
+}
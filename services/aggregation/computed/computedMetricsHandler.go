@@ -0,0 +1,150 @@
+package computed
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/config"
+	"github.com/multiversx/mx-chain-core-go/core/check"
+	logger "github.com/multiversx/mx-chain-logger-go"
+)
+
+var log = logger.GetOrCreate("computed")
+
+type computedMetric struct {
+	name           string
+	numAggregation int
+	expression     *expression
+}
+
+type computedMetricsHandler struct {
+	store                     Storage
+	metrics                   []*computedMetric
+	numSecondsToConsiderStale int64
+	timeFunc                  func() time.Time
+}
+
+// NewComputedMetricsHandler creates a new handler able to recalculate the configured computed metrics
+func NewComputedMetricsHandler(
+	store Storage,
+	metricsConfig []config.ComputedMetricConfig,
+	numSecondsToConsiderStale int,
+) (*computedMetricsHandler, error) {
+	if check.IfNil(store) {
+		return nil, errNilStorage
+	}
+
+	metrics := make([]*computedMetric, 0, len(metricsConfig))
+	names := make(map[string]struct{})
+	for _, cfg := range metricsConfig {
+		if len(strings.TrimSpace(cfg.Name)) == 0 {
+			return nil, errEmptyName
+		}
+		_, exists := names[cfg.Name]
+		if exists {
+			return nil, fmt.Errorf("%w: %s", errDuplicatedName, cfg.Name)
+		}
+		names[cfg.Name] = struct{}{}
+		if cfg.NumAggregation < 1 {
+			return nil, fmt.Errorf("%w for computed metric %s: %d", errInvalidNumAggregation, cfg.Name, cfg.NumAggregation)
+		}
+
+		expr, err := parseExpression(cfg.Expression)
+		if err != nil {
+			return nil, fmt.Errorf("%w for computed metric %s", err, cfg.Name)
+		}
+
+		metrics = append(metrics, &computedMetric{
+			name:           cfg.Name,
+			numAggregation: cfg.NumAggregation,
+			expression:     expr,
+		})
+	}
+
+	return &computedMetricsHandler{
+		store:                     store,
+		metrics:                   metrics,
+		numSecondsToConsiderStale: int64(numSecondsToConsiderStale),
+		timeFunc:                  time.Now,
+	}, nil
+}
+
+// Execute recalculates all computed metrics from the latest stored values and saves the results.
+// A computed metric is skipped if any of its input metrics is missing, is not numeric or is stale.
+func (handler *computedMetricsHandler) Execute(ctx context.Context) error {
+	if len(handler.metrics) == 0 {
+		return nil
+	}
+
+	latest, err := handler.store.GetLatestMetrics(ctx)
+	if err != nil {
+		return fmt.Errorf("%w while fetching the latest metrics", err)
+	}
+
+	now := handler.timeFunc().Unix()
+	values := handler.extractValues(latest, now)
+
+	for _, metric := range handler.metrics {
+		result, errEvaluate := metric.expression.evaluate(values)
+		if errEvaluate != nil {
+			log.Debug("skipping computed metric", "metric", metric.name, "reason", errEvaluate)
+			continue
+		}
+
+		valString := strconv.FormatFloat(result, 'f', -1, 64)
+		err = handler.store.SaveMetric(ctx, metric.name, common.MetricTypeFloat64, metric.numAggregation, valString, now)
+		if err != nil {
+			log.Warn("failed to save computed metric", "metric", metric.name, "error", err)
+		}
+	}
+
+	return nil
+}
+
+func (handler *computedMetricsHandler) extractValues(latest []common.MetricHistory, now int64) map[string]float64 {
+	values := make(map[string]float64, len(latest))
+	for _, metric := range latest {
+		if len(metric.History) == 0 || metric.History[0].RecordedAt == 0 {
+			continue
+		}
+
+		lastValue := metric.History[0]
+		if handler.numSecondsToConsiderStale > 0 && now-lastValue.RecordedAt >= handler.numSecondsToConsiderStale {
+			continue
+		}
+
+		value, ok := parseNumericValue(lastValue.Value)
+		if !ok {
+			continue
+		}
+
+		values[metric.Name] = value
+	}
+
+	return values
+}
+
+func parseNumericValue(value string) (float64, bool) {
+	switch value {
+	case "true":
+		return 1, true
+	case "false":
+		return 0, true
+	}
+
+	result, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return result, true
+}
+
+// IsInterfaceNil returns true if there is no value under the interface
+func (handler *computedMetricsHandler) IsInterfaceNil() bool {
+	return handler == nil
+}
@@ -0,0 +1,198 @@
+package computed
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/config"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/testsCommon"
+	"github.com/stretchr/testify/assert"
+)
+
+type savedMetric struct {
+	name           string
+	metricType     string
+	numAggregation int
+	value          string
+	recordedAt     int64
+}
+
+func createMockComputedMetricsConfig() []config.ComputedMetricConfig {
+	return []config.ComputedMetricConfig{
+		{
+			Name:           "Lag.VM3.Node1.nonce",
+			Expression:     "max(VM1.Node1.nonce, VM2.Node1.nonce) - VM3.Node1.nonce",
+			NumAggregation: 100,
+		},
+		{
+			Name:           "Avg.nonce",
+			Expression:     "avg(VM1.Node1.nonce, VM2.Node1.nonce)",
+			NumAggregation: 1,
+		},
+	}
+}
+
+func TestNewComputedMetricsHandler(t *testing.T) {
+	t.Parallel()
+
+	t.Run("nil storage should error", func(t *testing.T) {
+		t.Parallel()
+
+		handler, err := NewComputedMetricsHandler(nil, createMockComputedMetricsConfig(), 60)
+		assert.Nil(t, handler)
+		assert.Equal(t, errNilStorage, err)
+	})
+	t.Run("empty name should error", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := createMockComputedMetricsConfig()
+		cfg[1].Name = " "
+		handler, err := NewComputedMetricsHandler(&testsCommon.StoreStub{}, cfg, 60)
+		assert.Nil(t, handler)
+		assert.Equal(t, errEmptyName, err)
+	})
+	t.Run("duplicated name should error", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := createMockComputedMetricsConfig()
+		cfg[1].Name = cfg[0].Name
+		handler, err := NewComputedMetricsHandler(&testsCommon.StoreStub{}, cfg, 60)
+		assert.Nil(t, handler)
+		assert.True(t, errors.Is(err, errDuplicatedName))
+	})
+	t.Run("invalid num aggregation should error", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := createMockComputedMetricsConfig()
+		cfg[1].NumAggregation = 0
+		handler, err := NewComputedMetricsHandler(&testsCommon.StoreStub{}, cfg, 60)
+		assert.Nil(t, handler)
+		assert.True(t, errors.Is(err, errInvalidNumAggregation))
+	})
+	t.Run("invalid expression should error", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := createMockComputedMetricsConfig()
+		cfg[1].Expression = "max(VM1.Node1.nonce"
+		handler, err := NewComputedMetricsHandler(&testsCommon.StoreStub{}, cfg, 60)
+		assert.Nil(t, handler)
+		assert.True(t, errors.Is(err, errInvalidExpression))
+		assert.Contains(t, err.Error(), "Avg.nonce")
+	})
+	t.Run("should work", func(t *testing.T) {
+		t.Parallel()
+
+		handler, err := NewComputedMetricsHandler(&testsCommon.StoreStub{}, createMockComputedMetricsConfig(), 60)
+		assert.NotNil(t, handler)
+		assert.Nil(t, err)
+		assert.False(t, handler.IsInterfaceNil())
+	})
+}
+
+func TestComputedMetricsHandler_Execute(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(10000, 0)
+
+	t.Run("storage errors should error", func(t *testing.T) {
+		t.Parallel()
+
+		expectedErr := errors.New("expected error")
+		store := &testsCommon.StoreStub{
+			GetLatestMetricsHandler: func(ctx context.Context) ([]common.MetricHistory, error) {
+				return nil, expectedErr
+			},
+		}
+
+		handler, _ := NewComputedMetricsHandler(store, createMockComputedMetricsConfig(), 60)
+		err := handler.Execute(context.Background())
+		assert.True(t, errors.Is(err, expectedErr))
+	})
+	t.Run("should compute and save the metrics", func(t *testing.T) {
+		t.Parallel()
+
+		saved := make([]savedMetric, 0)
+		store := &testsCommon.StoreStub{
+			GetLatestMetricsHandler: func(ctx context.Context) ([]common.MetricHistory, error) {
+				return []common.MetricHistory{
+					{Name: "VM1.Node1.nonce", History: []common.MetricValue{{Value: "100", RecordedAt: now.Unix() - 1}}},
+					{Name: "VM2.Node1.nonce", History: []common.MetricValue{{Value: "105", RecordedAt: now.Unix() - 2}}},
+					{Name: "VM3.Node1.nonce", History: []common.MetricValue{{Value: "98", RecordedAt: now.Unix() - 3}}},
+				}, nil
+			},
+			SaveMetricHandler: func(ctx context.Context, name string, metricType string, numAggregation int, valString string, recordedAt int64) error {
+				saved = append(saved, savedMetric{
+					name:           name,
+					metricType:     metricType,
+					numAggregation: numAggregation,
+					value:          valString,
+					recordedAt:     recordedAt,
+				})
+				return nil
+			},
+		}
+
+		handler, _ := NewComputedMetricsHandler(store, createMockComputedMetricsConfig(), 60)
+		handler.timeFunc = func() time.Time {
+			return now
+		}
+
+		err := handler.Execute(context.Background())
+		assert.Nil(t, err)
+
+		expected := []savedMetric{
+			{name: "Lag.VM3.Node1.nonce", metricType: common.MetricTypeFloat64, numAggregation: 100, value: "7", recordedAt: now.Unix()},
+			{name: "Avg.nonce", metricType: common.MetricTypeFloat64, numAggregation: 1, value: "102.5", recordedAt: now.Unix()},
+		}
+		assert.Equal(t, expected, saved)
+	})
+	t.Run("stale, missing or non-numeric inputs should skip the metric", func(t *testing.T) {
+		t.Parallel()
+
+		saved := make([]savedMetric, 0)
+		store := &testsCommon.StoreStub{
+			GetLatestMetricsHandler: func(ctx context.Context) ([]common.MetricHistory, error) {
+				return []common.MetricHistory{
+					{Name: "VM1.Node1.nonce", History: []common.MetricValue{{Value: "100", RecordedAt: now.Unix() - 1}}},
+					{Name: "VM2.Node1.nonce", History: []common.MetricValue{{Value: "105", RecordedAt: now.Unix() - 60}}},
+					{Name: "VM3.Node1.nonce", History: []common.MetricValue{{Value: "", RecordedAt: 0}}},
+				}, nil
+			},
+			SaveMetricHandler: func(ctx context.Context, name string, metricType string, numAggregation int, valString string, recordedAt int64) error {
+				saved = append(saved, savedMetric{name: name, value: valString})
+				return nil
+			},
+		}
+
+		handler, _ := NewComputedMetricsHandler(store, createMockComputedMetricsConfig(), 60)
+		handler.timeFunc = func() time.Time {
+			return now
+		}
+
+		err := handler.Execute(context.Background())
+		assert.Nil(t, err)
+		assert.Empty(t, saved)
+	})
+}
+
+func TestParseNumericValue(t *testing.T) {
+	t.Parallel()
+
+	value, ok := parseNumericValue("true")
+	assert.True(t, ok)
+	assert.Equal(t, float64(1), value)
+
+	value, ok = parseNumericValue("false")
+	assert.True(t, ok)
+	assert.Equal(t, float64(0), value)
+
+	value, ok = parseNumericValue("-12.5")
+	assert.True(t, ok)
+	assert.Equal(t, -12.5, value)
+
+	_, ok = parseNumericValue("ok")
+	assert.False(t, ok)
+}
@@ -0,0 +1,18 @@
+package computed
+
+import "errors"
+
+var (
+	errInvalidExpression     = errors.New("invalid expression")
+	errMissingMetricValue    = errors.New("missing metric value")
+	errDivisionByZero        = errors.New("division by zero")
+	errUnknownOperator       = errors.New("unknown operator")
+	errUnknownFunction       = errors.New("unknown function")
+	errNilStorage            = errors.New("nil storage")
+	errEmptyName             = errors.New("empty computed metric name")
+	errDuplicatedName        = errors.New("duplicated computed metric name")
+	errInvalidNumAggregation = errors.New("invalid num aggregation")
+	errInvalidReferenceURL   = errors.New("invalid reference URL")
+	errUnexpectedStatus      = errors.New("unexpected HTTP status")
+	errMissingReferenceNonce = errors.New("missing reference nonce")
+)
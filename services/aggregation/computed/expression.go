@@ -0,0 +1,420 @@
+package computed
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// node is a parsed expression element that can be evaluated against a set of metric values
+type node interface {
+	evaluate(values map[string]float64) (float64, error)
+	collectMetrics(names map[string]struct{})
+}
+
+type numberNode struct {
+	value float64
+}
+
+func (n *numberNode) evaluate(_ map[string]float64) (float64, error) {
+	return n.value, nil
+}
+
+func (n *numberNode) collectMetrics(_ map[string]struct{}) {
+}
+
+type metricNode struct {
+	name string
+}
+
+func (n *metricNode) evaluate(values map[string]float64) (float64, error) {
+	value, found := values[n.name]
+	if !found {
+		return 0, fmt.Errorf("%w: %s", errMissingMetricValue, n.name)
+	}
+
+	return value, nil
+}
+
+func (n *metricNode) collectMetrics(names map[string]struct{}) {
+	names[n.name] = struct{}{}
+}
+
+type negateNode struct {
+	operand node
+}
+
+func (n *negateNode) evaluate(values map[string]float64) (float64, error) {
+	value, err := n.operand.evaluate(values)
+	if err != nil {
+		return 0, err
+	}
+
+	return -value, nil
+}
+
+func (n *negateNode) collectMetrics(names map[string]struct{}) {
+	n.operand.collectMetrics(names)
+}
+
+type binaryNode struct {
+	operator rune
+	left     node
+	right    node
+}
+
+func (n *binaryNode) evaluate(values map[string]float64) (float64, error) {
+	left, err := n.left.evaluate(values)
+	if err != nil {
+		return 0, err
+	}
+	right, err := n.right.evaluate(values)
+	if err != nil {
+		return 0, err
+	}
+
+	switch n.operator {
+	case '+':
+		return left + right, nil
+	case '-':
+		return left - right, nil
+	case '*':
+		return left * right, nil
+	case '/':
+		if right == 0 {
+			return 0, errDivisionByZero
+		}
+		return left / right, nil
+	}
+
+	return 0, fmt.Errorf("%w: %c", errUnknownOperator, n.operator)
+}
+
+func (n *binaryNode) collectMetrics(names map[string]struct{}) {
+	n.left.collectMetrics(names)
+	n.right.collectMetrics(names)
+}
+
+type functionNode struct {
+	name      string
+	arguments []node
+}
+
+func (n *functionNode) evaluate(values map[string]float64) (float64, error) {
+	args := make([]float64, 0, len(n.arguments))
+	for _, argument := range n.arguments {
+		value, err := argument.evaluate(values)
+		if err != nil {
+			return 0, err
+		}
+		args = append(args, value)
+	}
+
+	switch n.name {
+	case "max":
+		result := args[0]
+		for _, arg := range args[1:] {
+			result = math.Max(result, arg)
+		}
+		return result, nil
+	case "min":
+		result := args[0]
+		for _, arg := range args[1:] {
+			result = math.Min(result, arg)
+		}
+		return result, nil
+	case "sum":
+		return sum(args), nil
+	case "avg":
+		return sum(args) / float64(len(args)), nil
+	case "abs":
+		return math.Abs(args[0]), nil
+	}
+
+	return 0, fmt.Errorf("%w: %s", errUnknownFunction, n.name)
+}
+
+func (n *functionNode) collectMetrics(names map[string]struct{}) {
+	for _, argument := range n.arguments {
+		argument.collectMetrics(names)
+	}
+}
+
+func sum(values []float64) float64 {
+	result := float64(0)
+	for _, value := range values {
+		result += value
+	}
+
+	return result
+}
+
+// supportedFunctions holds the functions that can be used in expressions. All of them accept one or more arguments,
+// excepting abs that takes exactly one argument
+var supportedFunctions = map[string]struct{}{
+	"max": {},
+	"min": {},
+	"sum": {},
+	"avg": {},
+	"abs": {},
+}
+
+// expression is a parsed computed metric expression
+type expression struct {
+	root    node
+	metrics []string
+}
+
+// parseExpression parses expressions like `max(VM1.Node1.nonce, VM2.Node1.nonce) - VM3.Node1.nonce`.
+// Supported: decimal numbers, metric names (letters, digits, '.', '_' or any text enclosed in double quotes),
+// the + - * / operators, parentheses and the max, min, sum, avg and abs functions.
+func parseExpression(text string) (*expression, error) {
+	p := &parser{
+		input: []rune(text),
+	}
+
+	root, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpaces()
+	if !p.isAtEnd() {
+		return nil, p.errorf("unexpected character '%c'", p.current())
+	}
+
+	names := make(map[string]struct{})
+	root.collectMetrics(names)
+	metrics := make([]string, 0, len(names))
+	for name := range names {
+		metrics = append(metrics, name)
+	}
+	sort.Strings(metrics)
+
+	return &expression{
+		root:    root,
+		metrics: metrics,
+	}, nil
+}
+
+func (e *expression) evaluate(values map[string]float64) (float64, error) {
+	return e.root.evaluate(values)
+}
+
+type parser struct {
+	input    []rune
+	position int
+}
+
+func (p *parser) isAtEnd() bool {
+	return p.position >= len(p.input)
+}
+
+func (p *parser) current() rune {
+	return p.input[p.position]
+}
+
+func (p *parser) skipSpaces() {
+	for !p.isAtEnd() && unicode.IsSpace(p.current()) {
+		p.position++
+	}
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w at position %d: %s", errInvalidExpression, p.position, fmt.Sprintf(format, args...))
+}
+
+// parseSum handles the lowest precedence operators: + and -
+func (p *parser) parseSum() (node, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		p.skipSpaces()
+		if p.isAtEnd() || (p.current() != '+' && p.current() != '-') {
+			return left, nil
+		}
+
+		operator := p.current()
+		p.position++
+		right, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &binaryNode{operator: operator, left: left, right: right}
+	}
+}
+
+// parseProduct handles the * and / operators
+func (p *parser) parseProduct() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		p.skipSpaces()
+		if p.isAtEnd() || (p.current() != '*' && p.current() != '/') {
+			return left, nil
+		}
+
+		operator := p.current()
+		p.position++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &binaryNode{operator: operator, left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	p.skipSpaces()
+	if !p.isAtEnd() && p.current() == '-' {
+		p.position++
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		return &negateNode{operand: operand}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	p.skipSpaces()
+	if p.isAtEnd() {
+		return nil, p.errorf("unexpected end of expression")
+	}
+
+	c := p.current()
+	switch {
+	case c == '(':
+		p.position++
+		inner, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		err = p.expect(')')
+		if err != nil {
+			return nil, err
+		}
+		return inner, nil
+	case c == '"':
+		name, err := p.parseQuotedName()
+		if err != nil {
+			return nil, err
+		}
+		return &metricNode{name: name}, nil
+	case unicode.IsDigit(c):
+		return p.parseNumber()
+	case isNameCharacter(c):
+		return p.parseNameOrFunction()
+	}
+
+	return nil, p.errorf("unexpected character '%c'", c)
+}
+
+func (p *parser) expect(c rune) error {
+	p.skipSpaces()
+	if p.isAtEnd() || p.current() != c {
+		return p.errorf("expected '%c'", c)
+	}
+	p.position++
+
+	return nil
+}
+
+func (p *parser) parseNumber() (node, error) {
+	start := p.position
+	for !p.isAtEnd() && (unicode.IsDigit(p.current()) || p.current() == '.') {
+		p.position++
+	}
+
+	text := string(p.input[start:p.position])
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return nil, p.errorf("invalid number %s", text)
+	}
+
+	return &numberNode{value: value}, nil
+}
+
+func (p *parser) parseQuotedName() (string, error) {
+	p.position++ // opening quote
+	start := p.position
+	for !p.isAtEnd() && p.current() != '"' {
+		p.position++
+	}
+	if p.isAtEnd() {
+		return "", p.errorf("unterminated quoted metric name")
+	}
+
+	name := string(p.input[start:p.position])
+	p.position++ // closing quote
+	if len(strings.TrimSpace(name)) == 0 {
+		return "", p.errorf("empty metric name")
+	}
+
+	return name, nil
+}
+
+func (p *parser) parseNameOrFunction() (node, error) {
+	start := p.position
+	for !p.isAtEnd() && isNameCharacter(p.current()) {
+		p.position++
+	}
+	name := string(p.input[start:p.position])
+
+	p.skipSpaces()
+	if p.isAtEnd() || p.current() != '(' {
+		return &metricNode{name: name}, nil
+	}
+
+	functionName := strings.ToLower(name)
+	_, found := supportedFunctions[functionName]
+	if !found {
+		return nil, p.errorf("unknown function %s", name)
+	}
+
+	p.position++ // opening parenthesis
+	arguments := make([]node, 0)
+	for {
+		argument, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		arguments = append(arguments, argument)
+
+		p.skipSpaces()
+		if !p.isAtEnd() && p.current() == ',' {
+			p.position++
+			continue
+		}
+
+		err = p.expect(')')
+		if err != nil {
+			return nil, err
+		}
+		break
+	}
+
+	if functionName == "abs" && len(arguments) != 1 {
+		return nil, p.errorf("function abs requires exactly one argument")
+	}
+
+	return &functionNode{name: functionName, arguments: arguments}, nil
+}
+
+func isNameCharacter(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c) || c == '.' || c == '_'
+}
@@ -0,0 +1,101 @@
+package computed
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExpression(t *testing.T) {
+	t.Parallel()
+
+	t.Run("invalid expressions should error", func(t *testing.T) {
+		t.Parallel()
+
+		invalidExpressions := []string{
+			"",
+			"   ",
+			"VM1.nonce +",
+			"(VM1.nonce",
+			"VM1.nonce)",
+			"max(VM1.nonce,",
+			"max()",
+			"unknown(VM1.nonce)",
+			"abs(VM1.nonce, VM2.nonce)",
+			`"VM1.nonce`,
+			`""`,
+			"VM1.nonce # 2",
+			"1.2.3",
+		}
+
+		for _, text := range invalidExpressions {
+			expr, err := parseExpression(text)
+			assert.Nil(t, expr, text)
+			assert.True(t, errors.Is(err, errInvalidExpression), text)
+		}
+	})
+	t.Run("should collect the referenced metrics", func(t *testing.T) {
+		t.Parallel()
+
+		expr, err := parseExpression(`max(VM1.Node1.nonce, VM2.Node1.nonce) - VM3.Node1.nonce + "mock-api" - VM1.Node1.nonce`)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"VM1.Node1.nonce", "VM2.Node1.nonce", "VM3.Node1.nonce", "mock-api"}, expr.metrics)
+	})
+}
+
+func TestExpression_Evaluate(t *testing.T) {
+	t.Parallel()
+
+	values := map[string]float64{
+		"VM1.Node1.nonce": 100,
+		"VM2.Node1.nonce": 105,
+		"VM3.Node1.nonce": 98,
+		"mock-api":        2,
+	}
+
+	testCases := []struct {
+		text     string
+		expected float64
+	}{
+		{text: "max(VM1.Node1.nonce, VM2.Node1.nonce) - VM3.Node1.nonce", expected: 7},
+		{text: "min(VM1.Node1.nonce, VM2.Node1.nonce, VM3.Node1.nonce)", expected: 98},
+		{text: "sum(VM1.Node1.nonce, VM2.Node1.nonce)", expected: 205},
+		{text: "avg(VM1.Node1.nonce, VM3.Node1.nonce)", expected: 99},
+		{text: "abs(VM3.Node1.nonce - VM2.Node1.nonce)", expected: 7},
+		{text: "MAX(VM1.Node1.nonce, 200)", expected: 200},
+		{text: "1 + 2 * 3", expected: 7},
+		{text: "(1 + 2) * 3", expected: 9},
+		{text: "10 - 4 - 3", expected: 3},
+		{text: "12 / 4 / 3", expected: 1},
+		{text: "-VM1.Node1.nonce + 1", expected: -99},
+		{text: "--2", expected: 2},
+		{text: `"mock-api" * 1.5`, expected: 3},
+	}
+
+	for _, tc := range testCases {
+		expr, err := parseExpression(tc.text)
+		require.NoError(t, err, tc.text)
+
+		result, err := expr.evaluate(values)
+		require.NoError(t, err, tc.text)
+		assert.Equal(t, tc.expected, result, tc.text)
+	}
+
+	t.Run("missing metric should error", func(t *testing.T) {
+		t.Parallel()
+
+		expr, _ := parseExpression("VM1.Node1.nonce - VM4.Node1.nonce")
+		_, err := expr.evaluate(values)
+		assert.True(t, errors.Is(err, errMissingMetricValue))
+		assert.Contains(t, err.Error(), "VM4.Node1.nonce")
+	})
+	t.Run("division by zero should error", func(t *testing.T) {
+		t.Parallel()
+
+		expr, _ := parseExpression("VM1.Node1.nonce / (VM2.Node1.nonce - 105)")
+		_, err := expr.evaluate(values)
+		assert.Equal(t, errDivisionByZero, err)
+	})
+}
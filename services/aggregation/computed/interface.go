@@ -0,0 +1,18 @@
+package computed
+
+import (
+	"context"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+)
+
+// Storage defines the storage operations required by the computed metrics handler
+type Storage interface {
+	// SaveMetric updates the metric definition and appends a new value, trimming history to NumAggregation
+	SaveMetric(ctx context.Context, name string, metricType string, numAggregation int, valString string, recordedAt int64) error
+
+	// GetLatestMetrics returns the single latest recorded value for every known metric
+	GetLatestMetrics(ctx context.Context) ([]common.MetricHistory, error)
+
+	IsInterfaceNil() bool
+}
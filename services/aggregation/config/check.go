@@ -0,0 +1,151 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/iulianpascalau/api-monitoring/commonGo"
+)
+
+// Diagnostic statuses
+const (
+	DiagnosticOK   = "ok"
+	DiagnosticFail = "fail"
+	DiagnosticSkip = "skip"
+)
+
+// Diagnostic is the outcome of a check-config check
+type Diagnostic struct {
+	Check   string `json:"check"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// ArgsCheck is the DTO used to check the deployment of the aggregation service
+type ArgsCheck struct {
+	ConfigFile         string
+	EnvFile            string
+	EnvValues          map[string]*commonGo.EnvValue
+	EnvOverridesPrefix string
+	DBPath             string
+	LookupEnv          func(key string) (string, bool)
+}
+
+// Check runs the checks of the service deployment without starting it: the config file decoding, the .env values, the
+// environment overrides, the config values and the paths the service writes to. The checks depending on a config that
+// could not be loaded are skipped. Nothing is modified, except for the missing data directories, created as on startup.
+func Check(args ArgsCheck) []Diagnostic {
+	diagnostics := make([]Diagnostic, 0)
+	add := func(check string, err error, message string) {
+		if err != nil {
+			diagnostics = append(diagnostics, Diagnostic{Check: check, Status: DiagnosticFail, Message: err.Error()})
+			return
+		}
+		diagnostics = append(diagnostics, Diagnostic{Check: check, Status: DiagnosticOK, Message: message})
+	}
+
+	add("env file", checkEnvFile(args.EnvFile, args.EnvValues), args.EnvFile)
+
+	cfg, err := LoadConfig(args.ConfigFile)
+	add("config file", err, args.ConfigFile)
+	if err != nil {
+		for _, check := range []string{"env overrides", "config values", "database path", "static dir", "archive directory"} {
+			diagnostics = append(diagnostics, Diagnostic{Check: check, Status: DiagnosticSkip, Message: "the config file could not be loaded"})
+		}
+		return diagnostics
+	}
+
+	overrides, err := commonGo.ApplyEnvOverrides(cfg, args.EnvOverridesPrefix, args.LookupEnv)
+	add("env overrides", err, fmt.Sprintf("%d variable(s) applied %s", len(overrides), strings.Join(overrides, ", ")))
+	add("config values", cfg.Validate(), "valid")
+	add("database path", checkWritableFile(args.DBPath), args.DBPath)
+
+	if len(cfg.StaticDir) == 0 {
+		diagnostics = append(diagnostics, Diagnostic{Check: "static dir", Status: DiagnosticSkip, Message: "the embedded frontend is served"})
+	} else {
+		add("static dir", checkDirectory(cfg.StaticDir), cfg.StaticDir)
+	}
+
+	if !cfg.Archive.Enabled {
+		diagnostics = append(diagnostics, Diagnostic{Check: "archive directory", Status: DiagnosticSkip, Message: "the archival is disabled"})
+	} else {
+		add("archive directory", checkWritableDirectory(cfg.Archive.Directory), cfg.Archive.Directory)
+	}
+
+	return diagnostics
+}
+
+// checkEnvFile reads the .env file, listing all the missing required values
+func checkEnvFile(envFile string, envValues map[string]*commonGo.EnvValue) error {
+	// the values are read in a copy, not to alter the definitions and to collect all the missing ones
+	optional := make(map[string]*commonGo.EnvValue, len(envValues))
+	for key := range envValues {
+		optional[key] = &commonGo.EnvValue{}
+	}
+	err := commonGo.ReadEnvFile(envFile, optional)
+	if err != nil {
+		return err
+	}
+
+	missing := make([]string, 0)
+	for key, envValue := range envValues {
+		if envValue.Required && len(optional[key].Value) == 0 {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("%w: %s", errMissingEnvValues, strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// checkWritableFile checks the file can be opened for writing, or created if missing
+func checkWritableFile(path string) error {
+	err := checkWritableDirectory(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return file.Close()
+}
+
+// checkWritableDirectory creates the directory if missing and checks a file can be created in it
+func checkWritableDirectory(directory string) error {
+	err := os.MkdirAll(directory, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(directory, ".check-config-*")
+	if err != nil {
+		return err
+	}
+	_ = file.Close()
+
+	return os.Remove(file.Name())
+}
+
+func checkDirectory(directory string) error {
+	info, err := os.Stat(directory)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%w: %s", errNotADirectory, directory)
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/commonGo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createCheckArgs(t *testing.T, overrides map[string]string) ArgsCheck {
+	directory := t.TempDir()
+	envFile := filepath.Join(directory, ".env")
+	require.NoError(t, os.WriteFile(envFile, []byte("CHECK_TEST_KEY=key\n"), 0600))
+
+	return ArgsCheck{
+		ConfigFile: "../config.toml.example",
+		EnvFile:    envFile,
+		EnvValues: map[string]*commonGo.EnvValue{
+			"CHECK_TEST_KEY":      {Required: true},
+			"CHECK_TEST_OPTIONAL": {Required: false},
+		},
+		EnvOverridesPrefix: "AGG",
+		DBPath:             filepath.Join(directory, "data", "sqlite.db"),
+		LookupEnv: func(key string) (string, bool) {
+			value, found := overrides[key]
+			return value, found
+		},
+	}
+}
+
+func diagnosticsByCheck(diagnostics []Diagnostic) map[string]Diagnostic {
+	byCheck := make(map[string]Diagnostic)
+	for _, diagnostic := range diagnostics {
+		byCheck[diagnostic.Check] = diagnostic
+	}
+
+	return byCheck
+}
+
+func TestCheck(t *testing.T) {
+	t.Run("valid deployment should pass all checks", func(t *testing.T) {
+		archiveDir := filepath.Join(t.TempDir(), "archive")
+		args := createCheckArgs(t, map[string]string{
+			"AGG_STATIC_DIR":        t.TempDir(),
+			"AGG_ARCHIVE_ENABLED":   "true",
+			"AGG_ARCHIVE_DIRECTORY": archiveDir,
+		})
+
+		diagnostics := diagnosticsByCheck(Check(args))
+		require.Len(t, diagnostics, 7)
+		for _, diagnostic := range diagnostics {
+			assert.Equal(t, DiagnosticOK, diagnostic.Status, diagnostic.Check)
+		}
+		assert.Contains(t, diagnostics["env overrides"].Message, "3 variable(s) applied")
+		assert.DirExists(t, filepath.Dir(args.DBPath))
+		assert.NoFileExists(t, args.DBPath)
+
+		entries, err := os.ReadDir(archiveDir)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+	t.Run("problems should be reported", func(t *testing.T) {
+		regularFile := filepath.Join(t.TempDir(), "file")
+		require.NoError(t, os.WriteFile(regularFile, nil, 0600))
+		args := createCheckArgs(t, map[string]string{
+			"AGG_STATIC_DIR":   regularFile,
+			"AGG_MQTT_ENABLED": "not a bool",
+		})
+		args.EnvValues["CHECK_TEST_MISSING"] = &commonGo.EnvValue{Required: true}
+		args.DBPath = filepath.Join(regularFile, "sqlite.db")
+
+		diagnostics := diagnosticsByCheck(Check(args))
+		assert.Equal(t, DiagnosticFail, diagnostics["env file"].Status)
+		assert.Contains(t, diagnostics["env file"].Message, "CHECK_TEST_MISSING")
+		assert.Equal(t, DiagnosticOK, diagnostics["config file"].Status)
+		assert.Equal(t, DiagnosticFail, diagnostics["env overrides"].Status)
+		assert.Equal(t, DiagnosticFail, diagnostics["database path"].Status)
+		assert.Equal(t, DiagnosticFail, diagnostics["static dir"].Status)
+		assert.Contains(t, diagnostics["static dir"].Message, errNotADirectory.Error())
+		assert.Equal(t, DiagnosticSkip, diagnostics["archive directory"].Status)
+	})
+	t.Run("missing config file should skip the dependent checks", func(t *testing.T) {
+		args := createCheckArgs(t, nil)
+		args.ConfigFile = "missing.toml"
+
+		diagnostics := diagnosticsByCheck(Check(args))
+		assert.Equal(t, DiagnosticOK, diagnostics["env file"].Status)
+		assert.Equal(t, DiagnosticFail, diagnostics["config file"].Status)
+		assert.Equal(t, DiagnosticSkip, diagnostics["config values"].Status)
+		assert.Equal(t, DiagnosticSkip, diagnostics["database path"].Status)
+	})
+}
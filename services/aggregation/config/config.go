@@ -4,27 +4,342 @@ import (
 	"fmt"
 	"os"
 
+	agentConfig "github.com/iulianpascalau/api-monitoring/services/agent/config"
 	"github.com/pelletier/go-toml/v2"
 )
 
 // Config maps to the config.toml file for the aggregation service
 type Config struct {
-	ListenAddress             string       `toml:"ListenAddress"`
-	StaticDir                 string       `toml:"StaticDir"`
-	RetentionSeconds          int          `toml:"RetentionSeconds"`
-	NumSecondsToConsiderStale int          `toml:"NumSecondsToConsiderStale"`
-	Alarms                    AlarmsConfig `toml:"Alarms"`
+	ListenAddress             string                `toml:"ListenAddress"`
+	StaticDir                 string                `toml:"StaticDir"`
+	BasePath                  string                `toml:"BasePath"`
+	TrustedProxies            []string              `toml:"TrustedProxies"`
+	RetentionSeconds          int                   `toml:"RetentionSeconds"`
+	NumSecondsToConsiderStale int                   `toml:"NumSecondsToConsiderStale"`
+	MaxAgentLogEntries        int                   `toml:"MaxAgentLogEntries"`
+	MaxClockSkewInSeconds     int                   `toml:"MaxClockSkewInSeconds"`
+	TrashRetentionInDays      int                   `toml:"TrashRetentionInDays"`
+	Alarms                    AlarmsConfig          `toml:"Alarms"`
+	ComputedMetrics           ComputedMetricsConfig `toml:"ComputedMetrics"`
+	BlocksBehind              BlocksBehindConfig    `toml:"BlocksBehind"`
+	DirectProbes              DirectProbesConfig    `toml:"DirectProbes"`
+	Demo                      DemoConfig            `toml:"Demo"`
+	StatusPage                StatusPageConfig      `toml:"StatusPage"`
+	Profiling                 ProfilingConfig       `toml:"Profiling"`
+	Database                  DatabaseConfig        `toml:"Database"`
+	Archive                   ArchiveConfig         `toml:"Archive"`
+	WriteQueue                WriteQueueConfig      `toml:"WriteQueue"`
+	Sink                      SinkConfig            `toml:"Sink"`
+	EventBus                  EventBusConfig        `toml:"EventBus"`
+	Federation                FederationConfig      `toml:"Federation"`
+	MQTT                      MQTTConfig            `toml:"MQTT"`
+	Tenants                   []TenantConfig        `toml:"Tenants"`
+	APIKeys                   []APIKeyConfig        `toml:"APIKeys"`
+	Webhooks                  []WebhookConfig       `toml:"Webhooks"`
+	OIDC                      OIDCConfig            `toml:"OIDC"`
 }
 
+// Roles granted to the users logging in through the OIDC provider
+const (
+	OIDCRoleAdmin  = "admin"
+	OIDCRoleViewer = "viewer"
+)
+
+// OIDCConfig defines the OpenID Connect identity provider (e.g. Google, Keycloak) the dashboard users can log in with,
+// as an alternative to the .env credentials. The client secret is read from the OIDC_CLIENT_SECRET .env definition.
+// The zero values keep the defaults.
+type OIDCConfig struct {
+	Enabled   bool   `toml:"Enabled"`
+	IssuerURL string `toml:"IssuerURL"`
+	ClientID  string `toml:"ClientID"`
+	// RedirectURL is the public URL of the /api/auth/oidc/callback endpoint, as registered with the provider
+	RedirectURL   string   `toml:"RedirectURL"`
+	Scopes        []string `toml:"Scopes"`
+	UsernameClaim string   `toml:"UsernameClaim"`
+	GroupsClaim   string   `toml:"GroupsClaim"`
+	// PostLoginURL is the frontend page receiving the session token after the login
+	PostLoginURL  string                   `toml:"PostLoginURL"`
+	TimeoutInSec  int                      `toml:"TimeoutInSec"`
+	GroupMappings []OIDCGroupMappingConfig `toml:"GroupMappings"`
+}
+
+// OIDCGroupMappingConfig grants a role, within a tenant, to the members of an identity provider group. The first
+// mapping matching the user groups applies, the "*" group matches all the users.
+type OIDCGroupMappingConfig struct {
+	Group  string `toml:"Group"`
+	Role   string `toml:"Role"`
+	Tenant string `toml:"Tenant"`
+}
+
+// TenantConfig defines an organization sharing the aggregation instance. Its agents report with the tenant API key
+// and its dashboard user only sees the tenant metrics. The users logging in with the .env credentials and the agents
+// reporting with the service API key belong to the default tenant, which sees all the metrics.
+type TenantConfig struct {
+	Name          string `toml:"Name"`
+	ServiceKeyApi string `toml:"ServiceKeyApi"`
+	Username      string `toml:"Username"`
+	Password      string `toml:"Password"`
+}
+
+// Scopes of the API keys issued to the external tools
+const (
+	APIKeyScopeReport = "report"
+	APIKeyScopeRead   = "read"
+	APIKeyScopeAdmin  = "admin"
+)
+
+// APIKeyConfig defines an API key, sent in the X-Api-Key header, for the external tools (e.g. a Grafana JSON
+// datasource) that should not use a human login or the agents key. The report scope only reports metrics, as the
+// agents do, the read scope only reads the dashboard endpoints and the admin scope is granted everything the admin
+// user can do. The key acts within the tenant, the empty one being the default tenant.
+type APIKeyConfig struct {
+	Name   string `toml:"Name"`
+	Key    string `toml:"Key"`
+	Scope  string `toml:"Scope"`
+	Tenant string `toml:"Tenant"`
+}
+
+// WebhookConfig defines a third-party service (e.g. the GitHub status or a cloud provider health feed) pushing JSON
+// to /api/ingest/<Name>, so the sources that can push do not need an agent. The sender authenticates with the Token,
+// in the X-Api-Key header or in the token query parameter. The values are ingested within the Tenant, the empty one
+// being the default tenant.
+type WebhookConfig struct {
+	Name    string                `toml:"Name"`
+	Token   string                `toml:"Token"`
+	Tenant  string                `toml:"Tenant"`
+	Metrics []WebhookMetricConfig `toml:"Metrics"`
+}
+
+// WebhookMetricConfig maps the value found at the gjson Path of the pushed document (e.g. "status.indicator") to a
+// metric
+type WebhookMetricConfig struct {
+	Name           string `toml:"Name"`
+	Path           string `toml:"Path"`
+	Type           string `toml:"Type"`
+	NumAggregation int    `toml:"NumAggregation"`
+}
+
+// MQTTConfig defines the MQTT broker subscription through which the agents that can not reach the service over HTTP
+// deliver their reports. The reports carry the service API key, as the HTTP ones. The zero values keep the defaults.
+type MQTTConfig struct {
+	Enabled bool `toml:"Enabled"`
+	// BrokerURL uses the tcp:// (or mqtt://) scheme for plain connections and ssl:// (or mqtts://) for TLS
+	BrokerURL string `toml:"BrokerURL"`
+	// Topic is the subscribed topic filter, e.g. monitoring/reports/+
+	Topic                  string `toml:"Topic"`
+	QoS                    uint8  `toml:"QoS"`
+	ClientID               string `toml:"ClientID"`
+	Username               string `toml:"Username"`
+	Password               string `toml:"Password"`
+	KeepAliveInSeconds     int    `toml:"KeepAliveInSeconds"`
+	TimeoutInSec           int    `toml:"TimeoutInSec"`
+	ReconnectIntervalInSec int    `toml:"ReconnectIntervalInSec"`
+}
+
+// EventBusTypeNATS is the only supported event bus type
+const EventBusTypeNATS = "nats"
+
+// EventBusConfig defines the pub/sub publisher that emits every accepted report, as a JSON message, for the
+// downstream consumers. The messages are dropped if the bus can not be reached. The optional NATS authentication
+// token is read from the NATS_TOKEN .env definition. The zero values keep the defaults.
+type EventBusConfig struct {
+	Enabled       bool   `toml:"Enabled"`
+	Type          string `toml:"Type"`
+	URL           string `toml:"URL"`
+	Subject       string `toml:"Subject"`
+	QueueCapacity int    `toml:"QueueCapacity"`
+	TimeoutInSec  int    `toml:"TimeoutInSec"`
+}
+
+// FederationConfig defines the parent aggregation service the metrics of this instance are rolled up into, so the
+// per-datacenter instances can feed one global dashboard. The latest values are reported periodically, as an agent
+// would, with the names prefixed by Prefix. The API key of the parent is read from the FEDERATION_API_KEY .env
+// definition. The zero values keep the defaults.
+type FederationConfig struct {
+	Enabled   bool   `toml:"Enabled"`
+	ParentURL string `toml:"ParentURL"`
+	Prefix    string `toml:"Prefix"`
+	// QueueCapacity is the maximum number of distinct metrics waiting to be forwarded
+	QueueCapacity      int `toml:"QueueCapacity"`
+	FlushIntervalInSec int `toml:"FlushIntervalInSec"`
+	TimeoutInSec       int `toml:"TimeoutInSec"`
+}
+
+// SinkTypeInfluxDB is the only supported sink type
+const SinkTypeInfluxDB = "influxdb"
+
+// SinkConfig defines the secondary time series database every accepted metric value is forwarded to. The values are
+// written asynchronously, in batches, and are dropped if the sink can not keep up. The InfluxDB token is read from the
+// INFLUX_TOKEN .env definition. The zero values keep the defaults.
+type SinkConfig struct {
+	Enabled         bool   `toml:"Enabled"`
+	Type            string `toml:"Type"`
+	URL             string `toml:"URL"`
+	Org             string `toml:"Org"`
+	Bucket          string `toml:"Bucket"`
+	Database        string `toml:"Database"`
+	Measurement     string `toml:"Measurement"`
+	QueueCapacity   int    `toml:"QueueCapacity"`
+	BatchSize       int    `toml:"BatchSize"`
+	FlushIntervalMs int    `toml:"FlushIntervalMs"`
+	TimeoutInSec    int    `toml:"TimeoutInSec"`
+}
+
+// WriteQueueConfig defines the in-memory queue that buffers the reported values so they are written in batches
+// by a background worker. The zero values keep the defaults.
+type WriteQueueConfig struct {
+	Enabled         bool `toml:"Enabled"`
+	Capacity        int  `toml:"Capacity"`
+	BatchSize       int  `toml:"BatchSize"`
+	FlushIntervalMs int  `toml:"FlushIntervalMs"`
+}
+
+// DatabaseConfig defines the SQLite tuning options. The zero values keep the defaults.
+type DatabaseConfig struct {
+	BusyTimeoutInMs         int    `toml:"BusyTimeoutInMs"`
+	SynchronousMode         string `toml:"SynchronousMode"`
+	CacheSizeInKB           int    `toml:"CacheSizeInKB"`
+	WALAutoCheckpointPages  int    `toml:"WALAutoCheckpointPages"`
+	CheckpointIntervalInSec int    `toml:"CheckpointIntervalInSec"`
+	OperationTimeoutInMs    int    `toml:"OperationTimeoutInMs"`
+	SlowQueryThresholdInMs  int    `toml:"SlowQueryThresholdInMs"`
+	MaxReadConnections      int    `toml:"MaxReadConnections"`
+}
+
+// Supported archive formats
+const (
+	ArchiveFormatJSON = "json"
+	ArchiveFormatCSV  = "csv"
+)
+
+// ArchiveConfig defines the archival of the metrics history before its removal from the database: the metrics
+// deleted by prefix or purged from the trash and the values older than the retention period are written to gzip
+// compressed files in the directory, which can be a mounted object store bucket
+type ArchiveConfig struct {
+	Enabled   bool   `toml:"Enabled"`
+	Directory string `toml:"Directory"`
+	Format    string `toml:"Format"`
+}
+
+// ProfilingConfig defines the pprof runtime profiling endpoints, served for the authenticated users only
+type ProfilingConfig struct {
+	Enabled bool `toml:"Enabled"`
+}
+
+// StatusPageConfig defines the public (unauthenticated) status page that summarizes the health of each agent
+type StatusPageConfig struct {
+	Enabled          bool   `toml:"Enabled"`
+	Title            string `toml:"Title"`
+	CacheMaxAgeInSec int    `toml:"CacheMaxAgeInSec"`
+}
+
+// DemoConfig defines the sandbox mode in which synthetic agents data is generated by the service itself
+type DemoConfig struct {
+	Enabled              bool `toml:"Enabled"`
+	PollingIntervalInSec int  `toml:"PollingIntervalInSec"`
+}
+
+// ComputedMetricsConfig defines the configuration for the server-side computed metrics
+type ComputedMetricsConfig struct {
+	Enabled              bool                   `toml:"Enabled"`
+	PollingIntervalInSec int                    `toml:"PollingIntervalInSec"`
+	Metrics              []ComputedMetricConfig `toml:"Metrics"`
+}
+
+// ComputedMetricConfig defines a metric calculated from an expression over other metrics
+// (e.g. max(VM1.Node1.nonce, VM2.Node1.nonce) - VM3.Node1.nonce)
+type ComputedMetricConfig struct {
+	Name           string `toml:"Name"`
+	Expression     string `toml:"Expression"`
+	NumAggregation int    `toml:"NumAggregation"`
+}
+
+// BlocksBehindConfig defines the <node>.blocksBehind metrics, comparing the nonce reported by each node with the
+// highest nonce of its shard, fetched by the service from a reference MultiversX proxy. The zero values keep the
+// defaults.
+type BlocksBehindConfig struct {
+	Enabled              bool   `toml:"Enabled"`
+	ReferenceURL         string `toml:"ReferenceURL"`
+	PollingIntervalInSec int    `toml:"PollingIntervalInSec"`
+	TimeoutInSec         int    `toml:"TimeoutInSec"`
+	NumAggregation       int    `toml:"NumAggregation"`
+}
+
+// DirectProbesConfig defines the endpoints polled by the service itself, for the targets reachable from the server
+// where running an agent is overkill. The endpoints use the agent schema and are polled as an agent would, every
+// QueryIntervalInSeconds; their values are ingested as the agent reports are.
+type DirectProbesConfig struct {
+	Enabled                bool                         `toml:"Enabled"`
+	QueryIntervalInSeconds uint32                       `toml:"QueryIntervalInSeconds"`
+	MaxConcurrentPolls     uint32                       `toml:"MaxConcurrentPolls"`
+	Endpoints              []agentConfig.EndpointConfig `toml:"Endpoints"`
+	Network                agentConfig.NetworkConfig    `toml:"Network"`
+}
+
+// AgentConfig returns the agent config polling the direct probes
+func (cfg DirectProbesConfig) AgentConfig() agentConfig.Config {
+	return agentConfig.Config{
+		Name:                   DirectProbesAgentName,
+		QueryIntervalInSeconds: cfg.QueryIntervalInSeconds,
+		MaxConcurrentPolls:     cfg.MaxConcurrentPolls,
+		Endpoints:              cfg.Endpoints,
+		Network:                cfg.Network,
+	}
+}
+
+// DirectProbesAgentName is the agent name of the direct probes, used for their heartbeat metric
+const DirectProbesAgentName = "DirectProbes"
+
 // AlarmsConfig defines the configuration for alarms
 type AlarmsConfig struct {
 	Enabled                 bool                  `toml:"Enabled"`
 	NumSecondsLoopTimeAlarm int                   `toml:"NumSecondsLoopTimeAlarm"`
+	PendingDurationInSec    int                   `toml:"PendingDurationInSec"`
 	PushoverURL             string                `toml:"PushoverURL"`
 	TelegramURL             string                `toml:"TelegramURL"`
+	TelegramChatIDs         []string              `toml:"TelegramChatIDs"`
+	PagerDutyURL            string                `toml:"PagerDutyURL"`
+	OpsgenieURL             string                `toml:"OpsgenieURL"`
 	NumRetries              uint32                `toml:"NumRetries"`
 	SecondsBetweenRetries   int                   `toml:"SecondsBetweenRetries"`
 	SystemSelfCheck         SystemSelfCheckConfig `toml:"SystemSelfCheck"`
+	Summaries               SummariesConfig       `toml:"Summaries"`
+	Rules                   []AlarmRuleConfig     `toml:"Rules"`
+}
+
+// Supported summary periods, each summary covering the period that ends when it is sent
+const (
+	SummaryPeriodDaily  = "daily"
+	SummaryPeriodWeekly = "weekly"
+)
+
+// SummariesConfig defines the periodic summaries (top incidents, agents uptime, min/max of the key metrics) sent
+// through the configured notifiers. The key metrics are defined by patterns (shell glob syntax).
+type SummariesConfig struct {
+	Enabled              bool                    `toml:"Enabled"`
+	PollingIntervalInSec int                     `toml:"PollingIntervalInSec"`
+	NumTopIncidents      int                     `toml:"NumTopIncidents"`
+	KeyMetrics           []string                `toml:"KeyMetrics"`
+	Schedules            []SummaryScheduleConfig `toml:"Schedules"`
+}
+
+// SummaryScheduleConfig defines when a summary is sent and the period it covers
+type SummaryScheduleConfig struct {
+	Period    string `toml:"Period"`
+	DayOfWeek string `toml:"DayOfWeek"`
+	Hour      int    `toml:"Hour"`
+	Minute    int    `toml:"Minute"`
+}
+
+// AlarmRuleTypeMonotonicIncrease requires a counter (e.g. the node nonce) to increase over the last samples
+const AlarmRuleTypeMonotonicIncrease = "monotonic-increase"
+
+// AlarmRuleConfig defines a health check applied, besides the staleness check, to the alarm enabled metrics matching
+// the pattern (shell glob syntax, e.g. *.erd_nonce)
+type AlarmRuleConfig struct {
+	Type       string `toml:"Type"`
+	Pattern    string `toml:"Pattern"`
+	NumSamples int    `toml:"NumSamples"`
 }
 
 // SystemSelfCheckConfig defines the configuration for the self check system
@@ -48,6 +363,7 @@ func LoadConfig(filepath string) (*Config, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode config file: %w", err)
 	}
+	cfg.DirectProbes.Endpoints = agentConfig.ExpandPresetEndpoints(cfg.DirectProbes.Endpoints)
 
 	return &cfg, nil
 }
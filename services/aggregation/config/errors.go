@@ -0,0 +1,7 @@
+package config
+
+import "errors"
+
+var errMissingEnvValues = errors.New("required values not set in the .env file")
+
+var errNotADirectory = errors.New("not a directory")
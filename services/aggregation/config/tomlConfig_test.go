@@ -29,6 +29,22 @@ NumSecondsToConsiderStale = 300
         Hour = 12 # valid interval 0-23
         Minute = 0 # valid interval 0-59
         PollingIntervalInSec = 30
+	[[Alarms.Rules]]
+		Type = "monotonic-increase"
+		Pattern = "*.erd_nonce"
+		NumSamples = 5
+
+[ComputedMetrics]
+    Enabled = true
+    PollingIntervalInSec = 10
+    [[ComputedMetrics.Metrics]]
+        Name = "Lag.VM3.Node1.nonce"
+        Expression = "max(VM1.Node1.nonce, VM2.Node1.nonce) - VM3.Node1.nonce"
+        NumAggregation = 100
+
+[Demo]
+    Enabled = true
+    PollingIntervalInSec = 6
 `
 
 	expectedCfg := Config{
@@ -50,6 +66,28 @@ NumSecondsToConsiderStale = 300
 				Minute:               0,
 				PollingIntervalInSec: 30,
 			},
+			Rules: []AlarmRuleConfig{
+				{
+					Type:       AlarmRuleTypeMonotonicIncrease,
+					Pattern:    "*.erd_nonce",
+					NumSamples: 5,
+				},
+			},
+		},
+		ComputedMetrics: ComputedMetricsConfig{
+			Enabled:              true,
+			PollingIntervalInSec: 10,
+			Metrics: []ComputedMetricConfig{
+				{
+					Name:           "Lag.VM3.Node1.nonce",
+					Expression:     "max(VM1.Node1.nonce, VM2.Node1.nonce) - VM3.Node1.nonce",
+					NumAggregation: 100,
+				},
+			},
+		},
+		Demo: DemoConfig{
+			Enabled:              true,
+			PollingIntervalInSec: 6,
 		},
 	}
 
@@ -0,0 +1,642 @@
+package config
+
+import (
+	"net"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/iulianpascalau/api-monitoring/commonGo"
+	"github.com/iulianpascalau/api-monitoring/commonGo/mqtt"
+)
+
+const (
+	minIntervalInSec  = 1
+	minNumAggregation = 1
+	maxMQTTQoS        = 1
+	minRuleNumSamples = 2
+)
+
+var validDaysOfWeek = map[string]struct{}{
+	"every day": {},
+	"monday":    {},
+	"tuesday":   {},
+	"wednesday": {},
+	"thursday":  {},
+	"friday":    {},
+	"saturday":  {},
+	"sunday":    {},
+}
+
+var supportedMetricTypes = map[string]struct{}{
+	"uint64":  {},
+	"float64": {},
+	"string":  {},
+	"bool":    {},
+}
+
+// tenantNameRegex restricts the tenant names to identifiers, as they are carried in the session tokens
+var tenantNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// basePathRegex accepts the URL path prefixes like /monitoring or /tools/monitoring
+// federationPrefixRegex restricts the federation prefix to a single segment of the dotted metric names
+var federationPrefixRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+var basePathRegex = regexp.MustCompile(`^(/[A-Za-z0-9._~-]+)+/?$`)
+
+var validSynchronousModes = map[string]struct{}{
+	"":       {},
+	"OFF":    {},
+	"NORMAL": {},
+	"FULL":   {},
+	"EXTRA":  {},
+}
+
+// Validate checks the config values, returning all the problems found as a single human-readable error
+func (cfg Config) Validate() error {
+	errs := &commonGo.ConfigErrors{}
+
+	if len(strings.TrimSpace(cfg.ListenAddress)) == 0 {
+		errs.Add("ListenAddress is empty")
+	}
+	if len(cfg.BasePath) > 0 && !basePathRegex.MatchString(cfg.BasePath) {
+		errs.Add("BasePath %q must be an URL path like /monitoring", cfg.BasePath)
+	}
+	for i, proxy := range cfg.TrustedProxies {
+		if !isIPOrCIDR(proxy) {
+			errs.Add("TrustedProxies[%d]: %q is not an IP address or a CIDR range", i, proxy)
+		}
+	}
+	if cfg.RetentionSeconds < minIntervalInSec {
+		errs.Add("RetentionSeconds must be at least %d, got %d", minIntervalInSec, cfg.RetentionSeconds)
+	}
+	if cfg.NumSecondsToConsiderStale < 0 {
+		errs.Add("NumSecondsToConsiderStale can not be negative, got %d", cfg.NumSecondsToConsiderStale)
+	}
+	if cfg.MaxAgentLogEntries < 0 {
+		errs.Add("MaxAgentLogEntries can not be negative, got %d", cfg.MaxAgentLogEntries)
+	}
+	if cfg.MaxClockSkewInSeconds < 0 {
+		errs.Add("MaxClockSkewInSeconds can not be negative, got %d", cfg.MaxClockSkewInSeconds)
+	}
+	if cfg.TrashRetentionInDays < 0 {
+		errs.Add("TrashRetentionInDays can not be negative, got %d", cfg.TrashRetentionInDays)
+	}
+	if cfg.StatusPage.CacheMaxAgeInSec < 0 {
+		errs.Add("StatusPage.CacheMaxAgeInSec can not be negative, got %d", cfg.StatusPage.CacheMaxAgeInSec)
+	}
+	if cfg.Demo.Enabled && cfg.Demo.PollingIntervalInSec < minIntervalInSec {
+		errs.Add("Demo.PollingIntervalInSec must be at least %d, got %d", minIntervalInSec, cfg.Demo.PollingIntervalInSec)
+	}
+
+	cfg.validateDatabase(errs)
+	cfg.validateWriteQueue(errs)
+	cfg.validateArchive(errs)
+	cfg.validateSink(errs)
+	cfg.validateEventBus(errs)
+	cfg.validateFederation(errs)
+	cfg.validateMQTT(errs)
+	cfg.validateAlarms(errs)
+	cfg.validateComputedMetrics(errs)
+	cfg.validateBlocksBehind(errs)
+	cfg.validateDirectProbes(errs)
+	cfg.validateTenants(errs)
+	cfg.validateAPIKeys(errs)
+	cfg.validateWebhooks(errs)
+	cfg.validateOIDC(errs)
+
+	return errs.Err()
+}
+
+func (cfg Config) validateDatabase(errs *commonGo.ConfigErrors) {
+	db := cfg.Database
+	if db.BusyTimeoutInMs < 0 {
+		errs.Add("Database.BusyTimeoutInMs can not be negative, got %d", db.BusyTimeoutInMs)
+	}
+	_, isValidMode := validSynchronousModes[strings.ToUpper(db.SynchronousMode)]
+	if !isValidMode {
+		errs.Add("Database.SynchronousMode %q is not valid, use OFF, NORMAL, FULL or EXTRA", db.SynchronousMode)
+	}
+	if db.CacheSizeInKB < 0 {
+		errs.Add("Database.CacheSizeInKB can not be negative, got %d", db.CacheSizeInKB)
+	}
+	if db.WALAutoCheckpointPages < 0 {
+		errs.Add("Database.WALAutoCheckpointPages can not be negative, got %d", db.WALAutoCheckpointPages)
+	}
+	if db.CheckpointIntervalInSec < 0 {
+		errs.Add("Database.CheckpointIntervalInSec can not be negative, got %d", db.CheckpointIntervalInSec)
+	}
+	if db.OperationTimeoutInMs < 0 {
+		errs.Add("Database.OperationTimeoutInMs can not be negative, got %d", db.OperationTimeoutInMs)
+	}
+	if db.SlowQueryThresholdInMs < 0 {
+		errs.Add("Database.SlowQueryThresholdInMs can not be negative, got %d", db.SlowQueryThresholdInMs)
+	}
+	if db.MaxReadConnections < 0 {
+		errs.Add("Database.MaxReadConnections can not be negative, got %d", db.MaxReadConnections)
+	}
+}
+
+func (cfg Config) validateWriteQueue(errs *commonGo.ConfigErrors) {
+	queue := cfg.WriteQueue
+	if !queue.Enabled {
+		return
+	}
+
+	if queue.Capacity < 0 {
+		errs.Add("WriteQueue.Capacity can not be negative, got %d", queue.Capacity)
+	}
+	if queue.BatchSize < 0 {
+		errs.Add("WriteQueue.BatchSize can not be negative, got %d", queue.BatchSize)
+	}
+	if queue.Capacity > 0 && queue.BatchSize > queue.Capacity {
+		errs.Add("WriteQueue.BatchSize (%d) can not be greater than WriteQueue.Capacity (%d)", queue.BatchSize, queue.Capacity)
+	}
+	if queue.FlushIntervalMs < 0 {
+		errs.Add("WriteQueue.FlushIntervalMs can not be negative, got %d", queue.FlushIntervalMs)
+	}
+}
+
+func (cfg Config) validateArchive(errs *commonGo.ConfigErrors) {
+	archive := cfg.Archive
+	if !archive.Enabled {
+		return
+	}
+
+	if len(strings.TrimSpace(archive.Directory)) == 0 {
+		errs.Add("Archive.Directory is empty")
+	}
+	if archive.Format != "" && archive.Format != ArchiveFormatJSON && archive.Format != ArchiveFormatCSV {
+		errs.Add("Archive.Format %q is not supported, use %q or %q", archive.Format, ArchiveFormatJSON, ArchiveFormatCSV)
+	}
+}
+
+func (cfg Config) validateSink(errs *commonGo.ConfigErrors) {
+	sink := cfg.Sink
+	if !sink.Enabled {
+		return
+	}
+
+	if sink.Type != SinkTypeInfluxDB {
+		errs.Add("Sink.Type %q is not supported, use %q", sink.Type, SinkTypeInfluxDB)
+	}
+	if !commonGo.IsHTTPURL(sink.URL) {
+		errs.Add("Sink.URL %q is not a valid http(s) URL", sink.URL)
+	}
+	if len(sink.Bucket) == 0 && len(sink.Database) == 0 {
+		errs.Add("Sink.Bucket (InfluxDB 2.x) or Sink.Database (InfluxDB 1.x) is required")
+	}
+	if len(sink.Bucket) > 0 && len(sink.Org) == 0 {
+		errs.Add("Sink.Org is required when Sink.Bucket is set")
+	}
+	if sink.QueueCapacity < 0 {
+		errs.Add("Sink.QueueCapacity can not be negative, got %d", sink.QueueCapacity)
+	}
+	if sink.BatchSize < 0 {
+		errs.Add("Sink.BatchSize can not be negative, got %d", sink.BatchSize)
+	}
+	if sink.FlushIntervalMs < 0 {
+		errs.Add("Sink.FlushIntervalMs can not be negative, got %d", sink.FlushIntervalMs)
+	}
+	if sink.TimeoutInSec < 0 {
+		errs.Add("Sink.TimeoutInSec can not be negative, got %d", sink.TimeoutInSec)
+	}
+}
+
+func (cfg Config) validateFederation(errs *commonGo.ConfigErrors) {
+	federation := cfg.Federation
+	if !federation.Enabled {
+		return
+	}
+
+	if !commonGo.IsHTTPURL(federation.ParentURL) {
+		errs.Add("Federation.ParentURL %q is not a valid http(s) URL", federation.ParentURL)
+	}
+	if !federationPrefixRegex.MatchString(federation.Prefix) {
+		errs.Add("Federation.Prefix %q must contain only letters, digits, '-' and '_'", federation.Prefix)
+	}
+	if federation.QueueCapacity < 0 {
+		errs.Add("Federation.QueueCapacity can not be negative, got %d", federation.QueueCapacity)
+	}
+	if federation.FlushIntervalInSec < 0 {
+		errs.Add("Federation.FlushIntervalInSec can not be negative, got %d", federation.FlushIntervalInSec)
+	}
+	if federation.TimeoutInSec < 0 {
+		errs.Add("Federation.TimeoutInSec can not be negative, got %d", federation.TimeoutInSec)
+	}
+}
+
+func (cfg Config) validateEventBus(errs *commonGo.ConfigErrors) {
+	bus := cfg.EventBus
+	if !bus.Enabled {
+		return
+	}
+
+	if bus.Type != EventBusTypeNATS {
+		errs.Add("EventBus.Type %q is not supported, use %q", bus.Type, EventBusTypeNATS)
+	}
+	if !strings.HasPrefix(bus.URL, "nats://") || len(bus.URL) == len("nats://") {
+		errs.Add("EventBus.URL %q is not a valid nats:// URL", bus.URL)
+	}
+	if len(bus.Subject) == 0 {
+		errs.Add("EventBus.Subject is empty")
+	}
+	if strings.ContainsAny(bus.Subject, " \t*>") {
+		errs.Add("EventBus.Subject %q can not contain whitespaces or wildcards", bus.Subject)
+	}
+	if bus.QueueCapacity < 0 {
+		errs.Add("EventBus.QueueCapacity can not be negative, got %d", bus.QueueCapacity)
+	}
+	if bus.TimeoutInSec < 0 {
+		errs.Add("EventBus.TimeoutInSec can not be negative, got %d", bus.TimeoutInSec)
+	}
+}
+
+func (cfg Config) validateMQTT(errs *commonGo.ConfigErrors) {
+	mqttCfg := cfg.MQTT
+	if !mqttCfg.Enabled {
+		return
+	}
+
+	if !mqtt.IsValidBrokerURL(mqttCfg.BrokerURL) {
+		errs.Add("MQTT.BrokerURL %q is not a valid tcp://, mqtt://, ssl:// or mqtts:// URL", mqttCfg.BrokerURL)
+	}
+	if len(mqttCfg.Topic) == 0 {
+		errs.Add("MQTT.Topic is empty")
+	}
+	if mqttCfg.QoS > maxMQTTQoS {
+		errs.Add("MQTT.QoS must be 0 or 1, got %d", mqttCfg.QoS)
+	}
+	if mqttCfg.KeepAliveInSeconds < 0 {
+		errs.Add("MQTT.KeepAliveInSeconds can not be negative, got %d", mqttCfg.KeepAliveInSeconds)
+	}
+	if mqttCfg.TimeoutInSec < 0 {
+		errs.Add("MQTT.TimeoutInSec can not be negative, got %d", mqttCfg.TimeoutInSec)
+	}
+	if mqttCfg.ReconnectIntervalInSec < 0 {
+		errs.Add("MQTT.ReconnectIntervalInSec can not be negative, got %d", mqttCfg.ReconnectIntervalInSec)
+	}
+}
+
+func (cfg Config) validateAlarms(errs *commonGo.ConfigErrors) {
+	if !cfg.Alarms.Enabled {
+		return
+	}
+
+	if cfg.Alarms.NumSecondsLoopTimeAlarm < minIntervalInSec {
+		errs.Add("Alarms.NumSecondsLoopTimeAlarm must be at least %d, got %d", minIntervalInSec, cfg.Alarms.NumSecondsLoopTimeAlarm)
+	}
+	if cfg.Alarms.PendingDurationInSec < 0 {
+		errs.Add("Alarms.PendingDurationInSec can not be negative, got %d", cfg.Alarms.PendingDurationInSec)
+	}
+	if len(cfg.Alarms.PushoverURL) > 0 && !commonGo.IsHTTPURL(cfg.Alarms.PushoverURL) {
+		errs.Add("Alarms.PushoverURL %q is not a valid http(s) URL", cfg.Alarms.PushoverURL)
+	}
+	if len(cfg.Alarms.TelegramURL) > 0 && !commonGo.IsHTTPURL(cfg.Alarms.TelegramURL) {
+		errs.Add("Alarms.TelegramURL %q is not a valid http(s) URL", cfg.Alarms.TelegramURL)
+	}
+	if len(cfg.Alarms.PagerDutyURL) > 0 && !commonGo.IsHTTPURL(cfg.Alarms.PagerDutyURL) {
+		errs.Add("Alarms.PagerDutyURL %q is not a valid http(s) URL", cfg.Alarms.PagerDutyURL)
+	}
+	if len(cfg.Alarms.OpsgenieURL) > 0 && !commonGo.IsHTTPURL(cfg.Alarms.OpsgenieURL) {
+		errs.Add("Alarms.OpsgenieURL %q is not a valid http(s) URL", cfg.Alarms.OpsgenieURL)
+	}
+	for i, chatID := range cfg.Alarms.TelegramChatIDs {
+		if len(strings.TrimSpace(chatID)) == 0 {
+			errs.Add("Alarms.TelegramChatIDs[%d] is empty", i)
+		}
+	}
+	if cfg.Alarms.SecondsBetweenRetries < 0 {
+		errs.Add("Alarms.SecondsBetweenRetries can not be negative, got %d", cfg.Alarms.SecondsBetweenRetries)
+	}
+	for i, rule := range cfg.Alarms.Rules {
+		if rule.Type != AlarmRuleTypeMonotonicIncrease {
+			errs.Add("Alarms.Rules[%d]: Type %q is not supported, use %q", i, rule.Type, AlarmRuleTypeMonotonicIncrease)
+		}
+		if _, err := path.Match(rule.Pattern, ""); len(rule.Pattern) == 0 || err != nil {
+			errs.Add("Alarms.Rules[%d]: Pattern %q is not a valid glob pattern", i, rule.Pattern)
+		}
+		if rule.NumSamples < minRuleNumSamples {
+			errs.Add("Alarms.Rules[%d]: NumSamples must be at least %d, got %d", i, minRuleNumSamples, rule.NumSamples)
+		}
+	}
+
+	cfg.validateSummaries(errs)
+
+	selfCheck := cfg.Alarms.SystemSelfCheck
+	if !selfCheck.Enabled {
+		return
+	}
+
+	_, isValidDay := validDaysOfWeek[strings.ToLower(selfCheck.DayOfWeek)]
+	if !isValidDay {
+		errs.Add("Alarms.SystemSelfCheck.DayOfWeek %q is not valid, use \"every day\" or a week day name", selfCheck.DayOfWeek)
+	}
+	if selfCheck.Hour < 0 || selfCheck.Hour > 23 {
+		errs.Add("Alarms.SystemSelfCheck.Hour must be between 0 and 23, got %d", selfCheck.Hour)
+	}
+	if selfCheck.Minute < 0 || selfCheck.Minute > 59 {
+		errs.Add("Alarms.SystemSelfCheck.Minute must be between 0 and 59, got %d", selfCheck.Minute)
+	}
+	if selfCheck.PollingIntervalInSec < minIntervalInSec {
+		errs.Add("Alarms.SystemSelfCheck.PollingIntervalInSec must be at least %d, got %d", minIntervalInSec, selfCheck.PollingIntervalInSec)
+	}
+}
+
+func (cfg Config) validateSummaries(errs *commonGo.ConfigErrors) {
+	summaries := cfg.Alarms.Summaries
+	if !summaries.Enabled {
+		return
+	}
+
+	if summaries.PollingIntervalInSec < minIntervalInSec {
+		errs.Add("Alarms.Summaries.PollingIntervalInSec must be at least %d, got %d", minIntervalInSec, summaries.PollingIntervalInSec)
+	}
+	if summaries.NumTopIncidents < 0 {
+		errs.Add("Alarms.Summaries.NumTopIncidents can not be negative, got %d", summaries.NumTopIncidents)
+	}
+	for i, pattern := range summaries.KeyMetrics {
+		if _, err := path.Match(pattern, ""); len(pattern) == 0 || err != nil {
+			errs.Add("Alarms.Summaries.KeyMetrics[%d] %q is not a valid glob pattern", i, pattern)
+		}
+	}
+	if len(summaries.Schedules) == 0 {
+		errs.Add("Alarms.Summaries.Schedules is empty")
+	}
+	for i, schedule := range summaries.Schedules {
+		if schedule.Period != SummaryPeriodDaily && schedule.Period != SummaryPeriodWeekly {
+			errs.Add("Alarms.Summaries.Schedules[%d]: Period %q is not valid, use %q or %q", i, schedule.Period, SummaryPeriodDaily, SummaryPeriodWeekly)
+		}
+		_, isValidDay := validDaysOfWeek[strings.ToLower(schedule.DayOfWeek)]
+		if !isValidDay {
+			errs.Add("Alarms.Summaries.Schedules[%d]: DayOfWeek %q is not valid, use \"every day\" or a week day name", i, schedule.DayOfWeek)
+		}
+		if schedule.Hour < 0 || schedule.Hour > 23 {
+			errs.Add("Alarms.Summaries.Schedules[%d]: Hour must be between 0 and 23, got %d", i, schedule.Hour)
+		}
+		if schedule.Minute < 0 || schedule.Minute > 59 {
+			errs.Add("Alarms.Summaries.Schedules[%d]: Minute must be between 0 and 59, got %d", i, schedule.Minute)
+		}
+	}
+}
+
+func (cfg Config) validateBlocksBehind(errs *commonGo.ConfigErrors) {
+	blocksBehind := cfg.BlocksBehind
+	if !blocksBehind.Enabled {
+		return
+	}
+
+	if !commonGo.IsHTTPURL(blocksBehind.ReferenceURL) {
+		errs.Add("BlocksBehind.ReferenceURL %q is not a valid http(s) URL", blocksBehind.ReferenceURL)
+	}
+	if blocksBehind.PollingIntervalInSec < 0 {
+		errs.Add("BlocksBehind.PollingIntervalInSec can not be negative, got %d", blocksBehind.PollingIntervalInSec)
+	}
+	if blocksBehind.TimeoutInSec < 0 {
+		errs.Add("BlocksBehind.TimeoutInSec can not be negative, got %d", blocksBehind.TimeoutInSec)
+	}
+	if blocksBehind.NumAggregation < 0 {
+		errs.Add("BlocksBehind.NumAggregation can not be negative, got %d", blocksBehind.NumAggregation)
+	}
+}
+
+func (cfg Config) validateDirectProbes(errs *commonGo.ConfigErrors) {
+	probes := cfg.DirectProbes
+	if !probes.Enabled {
+		return
+	}
+
+	if probes.QueryIntervalInSeconds < minIntervalInSec {
+		errs.Add("DirectProbes.QueryIntervalInSeconds must be at least %d, got %d", minIntervalInSec, probes.QueryIntervalInSeconds)
+	}
+	if len(probes.Endpoints) == 0 {
+		errs.Add("DirectProbes.Endpoints is empty")
+	}
+
+	endpointErrs := &commonGo.ConfigErrors{}
+	probes.AgentConfig().ValidateEndpoints(endpointErrs)
+	probes.AgentConfig().ValidateNetwork(endpointErrs)
+	errs.Merge("DirectProbes.", endpointErrs)
+}
+
+func (cfg Config) validateComputedMetrics(errs *commonGo.ConfigErrors) {
+	if !cfg.ComputedMetrics.Enabled {
+		return
+	}
+
+	if cfg.ComputedMetrics.PollingIntervalInSec < minIntervalInSec {
+		errs.Add("ComputedMetrics.PollingIntervalInSec must be at least %d, got %d", minIntervalInSec, cfg.ComputedMetrics.PollingIntervalInSec)
+	}
+
+	names := make(map[string]int, len(cfg.ComputedMetrics.Metrics))
+	for i, metric := range cfg.ComputedMetrics.Metrics {
+		if len(strings.TrimSpace(metric.Name)) == 0 {
+			errs.Add("ComputedMetrics.Metrics[%d]: Name is empty", i)
+		} else {
+			firstIndex, found := names[metric.Name]
+			if found {
+				errs.Add("ComputedMetrics.Metrics[%d]: Name %q is already used by ComputedMetrics.Metrics[%d]", i, metric.Name, firstIndex)
+			} else {
+				names[metric.Name] = i
+			}
+		}
+
+		if len(strings.TrimSpace(metric.Expression)) == 0 {
+			errs.Add("ComputedMetrics.Metrics[%d] (%s): Expression is empty", i, metric.Name)
+		}
+		if metric.NumAggregation < minNumAggregation {
+			errs.Add("ComputedMetrics.Metrics[%d] (%s): NumAggregation must be at least %d, got %d",
+				i, metric.Name, minNumAggregation, metric.NumAggregation)
+		}
+	}
+}
+
+func (cfg Config) validateTenants(errs *commonGo.ConfigErrors) {
+	names := make(map[string]int, len(cfg.Tenants))
+	keys := make(map[string]int, len(cfg.Tenants))
+	usernames := make(map[string]int, len(cfg.Tenants))
+	for i, tenant := range cfg.Tenants {
+		if !tenantNameRegex.MatchString(tenant.Name) {
+			errs.Add("Tenants[%d]: Name %q must contain only letters, digits, '-' and '_'", i, tenant.Name)
+		} else {
+			firstIndex, found := names[tenant.Name]
+			if found {
+				errs.Add("Tenants[%d]: Name %q is already used by Tenants[%d]", i, tenant.Name, firstIndex)
+			} else {
+				names[tenant.Name] = i
+			}
+		}
+
+		if len(tenant.ServiceKeyApi) == 0 {
+			errs.Add("Tenants[%d] (%s): ServiceKeyApi is empty", i, tenant.Name)
+		} else {
+			firstIndex, found := keys[tenant.ServiceKeyApi]
+			if found {
+				errs.Add("Tenants[%d] (%s): ServiceKeyApi is already used by Tenants[%d]", i, tenant.Name, firstIndex)
+			} else {
+				keys[tenant.ServiceKeyApi] = i
+			}
+		}
+
+		if len(strings.TrimSpace(tenant.Username)) == 0 {
+			errs.Add("Tenants[%d] (%s): Username is empty", i, tenant.Name)
+		} else {
+			firstIndex, found := usernames[tenant.Username]
+			if found {
+				errs.Add("Tenants[%d] (%s): Username %q is already used by Tenants[%d]", i, tenant.Name, tenant.Username, firstIndex)
+			} else {
+				usernames[tenant.Username] = i
+			}
+		}
+
+		if len(tenant.Password) == 0 {
+			errs.Add("Tenants[%d] (%s): Password is empty", i, tenant.Name)
+		}
+	}
+}
+
+func (cfg Config) validateAPIKeys(errs *commonGo.ConfigErrors) {
+	tenants := make(map[string]struct{}, len(cfg.Tenants))
+	tenantKeys := make(map[string]struct{}, len(cfg.Tenants))
+	for _, tenant := range cfg.Tenants {
+		tenants[tenant.Name] = struct{}{}
+		tenantKeys[tenant.ServiceKeyApi] = struct{}{}
+	}
+
+	names := make(map[string]int, len(cfg.APIKeys))
+	keys := make(map[string]int, len(cfg.APIKeys))
+	for i, apiKey := range cfg.APIKeys {
+		if len(strings.TrimSpace(apiKey.Name)) == 0 {
+			errs.Add("APIKeys[%d]: Name is empty", i)
+		} else {
+			firstIndex, found := names[apiKey.Name]
+			if found {
+				errs.Add("APIKeys[%d]: Name %q is already used by APIKeys[%d]", i, apiKey.Name, firstIndex)
+			} else {
+				names[apiKey.Name] = i
+			}
+		}
+
+		_, isTenantKey := tenantKeys[apiKey.Key]
+		firstIndex, found := keys[apiKey.Key]
+		switch {
+		case len(apiKey.Key) == 0:
+			errs.Add("APIKeys[%d] (%s): Key is empty", i, apiKey.Name)
+		case isTenantKey:
+			errs.Add("APIKeys[%d] (%s): Key is already used as a tenant ServiceKeyApi", i, apiKey.Name)
+		case found:
+			errs.Add("APIKeys[%d] (%s): Key is already used by APIKeys[%d]", i, apiKey.Name, firstIndex)
+		default:
+			keys[apiKey.Key] = i
+		}
+
+		if apiKey.Scope != APIKeyScopeReport && apiKey.Scope != APIKeyScopeRead && apiKey.Scope != APIKeyScopeAdmin {
+			errs.Add("APIKeys[%d] (%s): Scope %q is not valid, use %q, %q or %q", i, apiKey.Name, apiKey.Scope,
+				APIKeyScopeReport, APIKeyScopeRead, APIKeyScopeAdmin)
+		}
+		_, isTenant := tenants[apiKey.Tenant]
+		if len(apiKey.Tenant) > 0 && !isTenant {
+			errs.Add("APIKeys[%d] (%s): Tenant %q is not defined in Tenants", i, apiKey.Name, apiKey.Tenant)
+		}
+	}
+}
+
+func (cfg Config) validateWebhooks(errs *commonGo.ConfigErrors) {
+	tenants := make(map[string]struct{}, len(cfg.Tenants))
+	for _, tenant := range cfg.Tenants {
+		tenants[tenant.Name] = struct{}{}
+	}
+
+	names := make(map[string]int, len(cfg.Webhooks))
+	for i, webhook := range cfg.Webhooks {
+		// the name is a segment of the ingestion URL
+		if !tenantNameRegex.MatchString(webhook.Name) {
+			errs.Add("Webhooks[%d]: Name %q must contain only letters, digits, '-' and '_'", i, webhook.Name)
+		} else {
+			firstIndex, found := names[webhook.Name]
+			if found {
+				errs.Add("Webhooks[%d]: Name %q is already used by Webhooks[%d]", i, webhook.Name, firstIndex)
+			} else {
+				names[webhook.Name] = i
+			}
+		}
+
+		if len(webhook.Token) == 0 {
+			errs.Add("Webhooks[%d] (%s): Token is empty", i, webhook.Name)
+		}
+		_, isTenant := tenants[webhook.Tenant]
+		if len(webhook.Tenant) > 0 && !isTenant {
+			errs.Add("Webhooks[%d] (%s): Tenant %q is not defined in Tenants", i, webhook.Name, webhook.Tenant)
+		}
+		if len(webhook.Metrics) == 0 {
+			errs.Add("Webhooks[%d] (%s): Metrics is empty", i, webhook.Name)
+		}
+
+		for j, metric := range webhook.Metrics {
+			if len(strings.TrimSpace(metric.Name)) == 0 {
+				errs.Add("Webhooks[%d] (%s): Metrics[%d]: Name is empty", i, webhook.Name, j)
+			}
+			if len(strings.TrimSpace(metric.Path)) == 0 {
+				errs.Add("Webhooks[%d] (%s): Metrics[%d]: Path is empty", i, webhook.Name, j)
+			}
+			_, supported := supportedMetricTypes[metric.Type]
+			if !supported {
+				errs.Add("Webhooks[%d] (%s): Metrics[%d]: Type %q is not supported, use one of uint64, float64, string or bool",
+					i, webhook.Name, j, metric.Type)
+			}
+			if metric.NumAggregation < minNumAggregation {
+				errs.Add("Webhooks[%d] (%s): Metrics[%d]: NumAggregation must be at least %d, got %d",
+					i, webhook.Name, j, minNumAggregation, metric.NumAggregation)
+			}
+		}
+	}
+}
+
+func (cfg Config) validateOIDC(errs *commonGo.ConfigErrors) {
+	oidcCfg := cfg.OIDC
+	if !oidcCfg.Enabled {
+		return
+	}
+
+	if !commonGo.IsHTTPURL(oidcCfg.IssuerURL) {
+		errs.Add("OIDC.IssuerURL %q is not a valid http(s) URL", oidcCfg.IssuerURL)
+	}
+	if len(oidcCfg.ClientID) == 0 {
+		errs.Add("OIDC.ClientID is empty")
+	}
+	if !commonGo.IsHTTPURL(oidcCfg.RedirectURL) {
+		errs.Add("OIDC.RedirectURL %q is not a valid http(s) URL", oidcCfg.RedirectURL)
+	}
+	if oidcCfg.TimeoutInSec < 0 {
+		errs.Add("OIDC.TimeoutInSec can not be negative, got %d", oidcCfg.TimeoutInSec)
+	}
+	if len(oidcCfg.GroupMappings) == 0 {
+		errs.Add("OIDC.GroupMappings is empty, no user could log in")
+	}
+
+	tenants := make(map[string]struct{}, len(cfg.Tenants))
+	for _, tenant := range cfg.Tenants {
+		tenants[tenant.Name] = struct{}{}
+	}
+	for i, mapping := range oidcCfg.GroupMappings {
+		if len(strings.TrimSpace(mapping.Group)) == 0 {
+			errs.Add("OIDC.GroupMappings[%d]: Group is empty", i)
+		}
+		if mapping.Role != OIDCRoleAdmin && mapping.Role != OIDCRoleViewer {
+			errs.Add("OIDC.GroupMappings[%d]: Role %q is not valid, use %q or %q", i, mapping.Role, OIDCRoleAdmin, OIDCRoleViewer)
+		}
+		_, isTenant := tenants[mapping.Tenant]
+		if len(mapping.Tenant) > 0 && !isTenant {
+			errs.Add("OIDC.GroupMappings[%d]: Tenant %q is not defined in Tenants", i, mapping.Tenant)
+		}
+	}
+}
+
+func isIPOrCIDR(value string) bool {
+	if net.ParseIP(value) != nil {
+		return true
+	}
+	_, _, err := net.ParseCIDR(value)
+
+	return err == nil
+}
@@ -0,0 +1,271 @@
+package config
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/commonGo"
+	agentConfig "github.com/iulianpascalau/api-monitoring/services/agent/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("example config should be valid", func(t *testing.T) {
+		t.Parallel()
+
+		cfg, err := LoadConfig("../config.toml.example")
+		require.Nil(t, err)
+		assert.Nil(t, cfg.Validate())
+	})
+	t.Run("disabled sections should not be validated", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := Config{
+			ListenAddress:             ":8080",
+			RetentionSeconds:          3600,
+			NumSecondsToConsiderStale: 300,
+			Alarms: AlarmsConfig{
+				PushoverURL: "invalid",
+			},
+			ComputedMetrics: ComputedMetricsConfig{
+				Metrics: []ComputedMetricConfig{{}},
+			},
+		}
+		assert.Nil(t, cfg.Validate())
+	})
+	t.Run("should report all the problems", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := Config{
+			BasePath:                  "monitoring",
+			TrustedProxies:            []string{"10.0.0.0/8", "nginx"},
+			RetentionSeconds:          0,
+			NumSecondsToConsiderStale: -1,
+			TrashRetentionInDays:      -1,
+			Alarms: AlarmsConfig{
+				Enabled:               true,
+				PushoverURL:           "pushover",
+				TelegramURL:           "https://api.telegram.org",
+				TelegramChatIDs:       []string{"-100123", " "},
+				PendingDurationInSec:  -1,
+				OpsgenieURL:           "api.opsgenie.com",
+				SecondsBetweenRetries: -1,
+				Summaries: SummariesConfig{
+					Enabled:         true,
+					NumTopIncidents: -1,
+					KeyMetrics:      []string{"*.erd_nonce", "["},
+					Schedules: []SummaryScheduleConfig{
+						{Period: SummaryPeriodDaily, DayOfWeek: "every day", Hour: 8},
+						{Period: "monthly", DayOfWeek: "Monday", Hour: 24, Minute: -1},
+					},
+				},
+				Rules: []AlarmRuleConfig{
+					{Type: AlarmRuleTypeMonotonicIncrease, Pattern: "*.erd_nonce", NumSamples: 5},
+					{Type: "increase", Pattern: "VM1.[", NumSamples: 1},
+				},
+				SystemSelfCheck: SystemSelfCheckConfig{
+					Enabled:   true,
+					DayOfWeek: "someday",
+					Hour:      24,
+					Minute:    -1,
+				},
+			},
+			ComputedMetrics: ComputedMetricsConfig{
+				Enabled: true,
+				Metrics: []ComputedMetricConfig{
+					{Name: "Lag", Expression: "VM1.nonce - VM2.nonce", NumAggregation: 1},
+					{Name: "Lag", NumAggregation: 0},
+					{Expression: "VM1.nonce", NumAggregation: 1},
+				},
+			},
+			Demo: DemoConfig{
+				Enabled: true,
+			},
+			StatusPage: StatusPageConfig{
+				CacheMaxAgeInSec: -1,
+			},
+			Database: DatabaseConfig{
+				BusyTimeoutInMs:         -1,
+				SynchronousMode:         "fast",
+				CacheSizeInKB:           -1,
+				WALAutoCheckpointPages:  -1,
+				CheckpointIntervalInSec: -1,
+				OperationTimeoutInMs:    -1,
+				SlowQueryThresholdInMs:  -1,
+			},
+			WriteQueue: WriteQueueConfig{
+				Enabled:         true,
+				Capacity:        10,
+				BatchSize:       20,
+				FlushIntervalMs: -1,
+			},
+			Archive: ArchiveConfig{
+				Enabled:   true,
+				Directory: " ",
+				Format:    "parquet",
+			},
+			Sink: SinkConfig{
+				Enabled:       true,
+				Type:          "timescaledb",
+				URL:           "influx",
+				Bucket:        "metrics",
+				QueueCapacity: -1,
+			},
+			EventBus: EventBusConfig{
+				Enabled: true,
+				Type:    "kafka",
+				URL:     "127.0.0.1:4222",
+				Subject: "metrics.*",
+			},
+			Federation: FederationConfig{
+				Enabled:            true,
+				ParentURL:          "https://global.example.com",
+				Prefix:             "DC1.",
+				FlushIntervalInSec: -1,
+			},
+			BlocksBehind: BlocksBehindConfig{
+				Enabled:        true,
+				ReferenceURL:   "gateway.multiversx.com",
+				NumAggregation: -1,
+			},
+			DirectProbes: DirectProbesConfig{
+				Enabled: true,
+				Endpoints: []agentConfig.EndpointConfig{
+					{Name: "Probe.api.nonce", URL: "api.multiversx.com", Value: "nonce", Type: "uint64", NumAggregation: 1},
+				},
+			},
+			MQTT: MQTTConfig{
+				Enabled:   true,
+				BrokerURL: "127.0.0.1:1883",
+				QoS:       2,
+			},
+			Tenants: []TenantConfig{
+				{Name: "acme", ServiceKeyApi: "key1", Username: "ops", Password: "pass"},
+				{Name: "acme", ServiceKeyApi: "key1", Username: "ops"},
+				{Name: "globex corp"},
+			},
+			APIKeys: []APIKeyConfig{
+				{Name: "grafana", Key: "grafana-key", Scope: APIKeyScopeRead},
+				{Name: "grafana", Key: "grafana-key", Scope: "write", Tenant: "initech"},
+				{Key: "key1", Scope: APIKeyScopeReport},
+			},
+			Webhooks: []WebhookConfig{
+				{Name: "github", Token: "token", Metrics: []WebhookMetricConfig{{Name: "GitHub.status", Path: "status.indicator", Type: "string", NumAggregation: 1}}},
+				{Name: "github", Tenant: "initech", Metrics: []WebhookMetricConfig{{Name: " ", Type: "int"}}},
+				{Name: "aws/health", Token: "token"},
+			},
+			OIDC: OIDCConfig{
+				Enabled:      true,
+				IssuerURL:    "accounts.google.com",
+				RedirectURL:  "https://monitoring.example.com/api/auth/oidc/callback",
+				TimeoutInSec: -1,
+				GroupMappings: []OIDCGroupMappingConfig{
+					{Group: "sre", Role: OIDCRoleAdmin, Tenant: "acme"},
+					{Role: "owner", Tenant: "initech"},
+				},
+			},
+		}
+
+		err := cfg.Validate()
+		require.NotNil(t, err)
+		assert.True(t, errors.Is(err, commonGo.ErrInvalidConfig))
+
+		expectedProblems := []string{
+			`BasePath "monitoring" must be an URL path like /monitoring`,
+			`TrustedProxies[1]: "nginx" is not an IP address or a CIDR range`,
+			"ListenAddress is empty",
+			"RetentionSeconds must be at least 1, got 0",
+			"NumSecondsToConsiderStale can not be negative, got -1",
+			"TrashRetentionInDays can not be negative, got -1",
+			"StatusPage.CacheMaxAgeInSec can not be negative, got -1",
+			"Demo.PollingIntervalInSec must be at least 1, got 0",
+			"Database.BusyTimeoutInMs can not be negative, got -1",
+			`Database.SynchronousMode "fast" is not valid`,
+			"Database.CacheSizeInKB can not be negative, got -1",
+			"Database.WALAutoCheckpointPages can not be negative, got -1",
+			"Database.CheckpointIntervalInSec can not be negative, got -1",
+			"Database.OperationTimeoutInMs can not be negative, got -1",
+			"Database.SlowQueryThresholdInMs can not be negative, got -1",
+			"WriteQueue.BatchSize (20) can not be greater than WriteQueue.Capacity (10)",
+			"WriteQueue.FlushIntervalMs can not be negative, got -1",
+			"Archive.Directory is empty",
+			`Archive.Format "parquet" is not supported, use "json" or "csv"`,
+			`Sink.Type "timescaledb" is not supported, use "influxdb"`,
+			`Sink.URL "influx" is not a valid http(s) URL`,
+			"Sink.Org is required when Sink.Bucket is set",
+			"Sink.QueueCapacity can not be negative, got -1",
+			`EventBus.Type "kafka" is not supported, use "nats"`,
+			`EventBus.URL "127.0.0.1:4222" is not a valid nats:// URL`,
+			`EventBus.Subject "metrics.*" can not contain whitespaces or wildcards`,
+			`MQTT.BrokerURL "127.0.0.1:1883" is not a valid tcp://, mqtt://, ssl:// or mqtts:// URL`,
+			"MQTT.Topic is empty",
+			"MQTT.QoS must be 0 or 1, got 2",
+			"Alarms.NumSecondsLoopTimeAlarm must be at least 1, got 0",
+			"Alarms.PendingDurationInSec can not be negative, got -1",
+			`Alarms.PushoverURL "pushover" is not a valid http(s) URL`,
+			`Alarms.OpsgenieURL "api.opsgenie.com" is not a valid http(s) URL`,
+			"Alarms.TelegramChatIDs[1] is empty",
+			"Alarms.SecondsBetweenRetries can not be negative, got -1",
+			`Alarms.Rules[1]: Type "increase" is not supported, use "monotonic-increase"`,
+			"Alarms.Summaries.PollingIntervalInSec must be at least 1, got 0",
+			"Alarms.Summaries.NumTopIncidents can not be negative, got -1",
+			`Alarms.Summaries.KeyMetrics[1] "[" is not a valid glob pattern`,
+			`Alarms.Summaries.Schedules[1]: Period "monthly" is not valid, use "daily" or "weekly"`,
+			"Alarms.Summaries.Schedules[1]: Hour must be between 0 and 23, got 24",
+			"Alarms.Summaries.Schedules[1]: Minute must be between 0 and 59, got -1",
+			`Alarms.Rules[1]: Pattern "VM1.[" is not a valid glob pattern`,
+			"Alarms.Rules[1]: NumSamples must be at least 2, got 1",
+			`Alarms.SystemSelfCheck.DayOfWeek "someday" is not valid`,
+			"Alarms.SystemSelfCheck.Hour must be between 0 and 23, got 24",
+			"Alarms.SystemSelfCheck.Minute must be between 0 and 59, got -1",
+			"Alarms.SystemSelfCheck.PollingIntervalInSec must be at least 1, got 0",
+			"ComputedMetrics.PollingIntervalInSec must be at least 1, got 0",
+			`ComputedMetrics.Metrics[1]: Name "Lag" is already used by ComputedMetrics.Metrics[0]`,
+			"ComputedMetrics.Metrics[1] (Lag): Expression is empty",
+			"ComputedMetrics.Metrics[1] (Lag): NumAggregation must be at least 1, got 0",
+			"ComputedMetrics.Metrics[2]: Name is empty",
+			`BlocksBehind.ReferenceURL "gateway.multiversx.com" is not a valid http(s) URL`,
+			"BlocksBehind.NumAggregation can not be negative, got -1",
+			"DirectProbes.QueryIntervalInSeconds must be at least 1, got 0",
+			`DirectProbes.Endpoints[0] (Probe.api.nonce): URL "api.multiversx.com" is not a valid http(s) URL`,
+			`Tenants[1]: Name "acme" is already used by Tenants[0]`,
+			"Tenants[1] (acme): ServiceKeyApi is already used by Tenants[0]",
+			`Tenants[1] (acme): Username "ops" is already used by Tenants[0]`,
+			"Tenants[1] (acme): Password is empty",
+			`Tenants[2]: Name "globex corp" must contain only letters, digits, '-' and '_'`,
+			"Tenants[2] (globex corp): ServiceKeyApi is empty",
+			"Tenants[2] (globex corp): Username is empty",
+			"Tenants[2] (globex corp): Password is empty",
+			`APIKeys[1]: Name "grafana" is already used by APIKeys[0]`,
+			"APIKeys[1] (grafana): Key is already used by APIKeys[0]",
+			`APIKeys[1] (grafana): Scope "write" is not valid, use "report", "read" or "admin"`,
+			`APIKeys[1] (grafana): Tenant "initech" is not defined in Tenants`,
+			"APIKeys[2]: Name is empty",
+			"APIKeys[2] (): Key is already used as a tenant ServiceKeyApi",
+			`Webhooks[1]: Name "github" is already used by Webhooks[0]`,
+			"Webhooks[1] (github): Token is empty",
+			`Webhooks[1] (github): Tenant "initech" is not defined in Tenants`,
+			"Webhooks[1] (github): Metrics[0]: Name is empty",
+			"Webhooks[1] (github): Metrics[0]: Path is empty",
+			`Webhooks[1] (github): Metrics[0]: Type "int" is not supported, use one of uint64, float64, string or bool`,
+			"Webhooks[1] (github): Metrics[0]: NumAggregation must be at least 1, got 0",
+			`Webhooks[2]: Name "aws/health" must contain only letters, digits, '-' and '_'`,
+			"Webhooks[2] (aws/health): Metrics is empty",
+			`Federation.Prefix "DC1." must contain only letters, digits, '-' and '_'`,
+			"Federation.FlushIntervalInSec can not be negative, got -1",
+			`OIDC.IssuerURL "accounts.google.com" is not a valid http(s) URL`,
+			"OIDC.ClientID is empty",
+			"OIDC.TimeoutInSec can not be negative, got -1",
+			"OIDC.GroupMappings[1]: Group is empty",
+			`OIDC.GroupMappings[1]: Role "owner" is not valid, use "admin" or "viewer"`,
+			`OIDC.GroupMappings[1]: Tenant "initech" is not defined in Tenants`,
+		}
+		for _, problem := range expectedProblems {
+			assert.Contains(t, err.Error(), problem)
+		}
+		assert.Contains(t, err.Error(), "88 problem(s) found")
+	})
+}
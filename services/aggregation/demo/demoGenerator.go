@@ -0,0 +1,209 @@
+package demo
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/multiversx/mx-chain-core-go/core/check"
+	logger "github.com/multiversx/mx-chain-logger-go"
+)
+
+var log = logger.GetOrCreate("demo")
+
+const (
+	defaultNumAgents        = 3
+	defaultNumHistoryPoints = 100
+	maxNumAgents            = 1000
+	activeMetricName        = "Active"
+	versionValue            = "v1.7.13"
+	startNonce              = 24000000
+	// offlineDuration defines how long ago the offline synthetic agent stopped reporting
+	offlineDuration = 10 * time.Minute
+)
+
+// agent is a synthetic agent with its own generated metrics
+type agent struct {
+	name    string
+	offline bool
+	nonce   uint64
+	lag     uint64
+	cpu     float64
+}
+
+// ArgsDemoGenerator is the DTO used to create a new demo data generator. The zero counts keep the defaults.
+type ArgsDemoGenerator struct {
+	Store    Storage
+	Interval time.Duration
+	// NumAgents is the number of synthetic agents, VM1 to VM<NumAgents>, the last one being offline
+	NumAgents int
+	// NumHistoryPoints is the number of values backfilled by Seed for each metric
+	NumHistoryPoints int
+}
+
+type demoGenerator struct {
+	store            Storage
+	interval         time.Duration
+	numHistoryPoints int
+	timeFunc         func() time.Time
+	mut              sync.Mutex
+	random           *rand.Rand
+	agents           []*agent
+}
+
+// NewDemoGenerator creates a generator of synthetic agents data. The values are generated as if the agents report
+// every interval. One of the agents is offline so the stale indicators and the alarms can be exercised as well.
+func NewDemoGenerator(args ArgsDemoGenerator) (*demoGenerator, error) {
+	if check.IfNil(args.Store) {
+		return nil, errNilStorage
+	}
+	if args.Interval < time.Second {
+		return nil, fmt.Errorf("%w: %v", errInvalidInterval, args.Interval)
+	}
+	if args.NumAgents < 0 || args.NumAgents > maxNumAgents {
+		return nil, fmt.Errorf("%w: %d, maximum %d", errInvalidNumAgents, args.NumAgents, maxNumAgents)
+	}
+	if args.NumHistoryPoints < 0 {
+		return nil, fmt.Errorf("%w: %d", errInvalidNumHistoryPoints, args.NumHistoryPoints)
+	}
+
+	numAgents := args.NumAgents
+	if numAgents == 0 {
+		numAgents = defaultNumAgents
+	}
+	numHistoryPoints := args.NumHistoryPoints
+	if numHistoryPoints == 0 {
+		numHistoryPoints = defaultNumHistoryPoints
+	}
+
+	// the agents cycle through the healthy, the slightly lagging and the loaded profiles
+	lags := []uint64{0, 1, 3}
+	agents := make([]*agent, 0, numAgents)
+	for i := 0; i < numAgents; i++ {
+		agents = append(agents, &agent{
+			name:    fmt.Sprintf("VM%d", i+1),
+			nonce:   startNonce,
+			lag:     lags[i%len(lags)],
+			cpu:     float64(20 + 25*(i%len(lags))),
+			offline: numAgents > 1 && i == numAgents-1,
+		})
+	}
+
+	return &demoGenerator{
+		store:            args.Store,
+		interval:         args.Interval,
+		numHistoryPoints: numHistoryPoints,
+		timeFunc:         time.Now,
+		random:           rand.New(rand.NewSource(time.Now().UnixNano())),
+		agents:           agents,
+	}, nil
+}
+
+// Seed backfills the history of all the synthetic agents and enables the alarms on their heartbeats
+func (generator *demoGenerator) Seed(ctx context.Context) error {
+	generator.mut.Lock()
+	defer generator.mut.Unlock()
+
+	now := generator.timeFunc()
+	for _, a := range generator.agents {
+		end := now
+		if a.offline {
+			end = now.Add(-offlineDuration)
+		}
+
+		start := end.Add(-generator.interval * time.Duration(generator.numHistoryPoints))
+		for timestamp := start; !timestamp.After(end); timestamp = timestamp.Add(generator.interval) {
+			err := generator.saveAgentMetrics(ctx, a, timestamp.Unix())
+			if err != nil {
+				return err
+			}
+		}
+
+		err := generator.store.UpdateMetricAlarm(ctx, a.name+"."+activeMetricName, true)
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Info("seeded the demo data", "num agents", len(generator.agents), "num points", generator.numHistoryPoints)
+
+	return nil
+}
+
+// Execute generates a new report for each online synthetic agent
+func (generator *demoGenerator) Execute(ctx context.Context) error {
+	generator.mut.Lock()
+	defer generator.mut.Unlock()
+
+	now := generator.timeFunc().Unix()
+	for _, a := range generator.agents {
+		if a.offline {
+			continue
+		}
+
+		err := generator.saveAgentMetrics(ctx, a, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (generator *demoGenerator) saveAgentMetrics(ctx context.Context, a *agent, recordedAt int64) error {
+	generator.advance(a)
+
+	metrics := []struct {
+		name           string
+		metricType     string
+		numAggregation int
+		value          string
+	}{
+		{name: activeMetricName, metricType: common.MetricTypeBool, numAggregation: 1, value: "true"},
+		{name: "Node1.nonce", metricType: common.MetricTypeUint64, numAggregation: generator.numHistoryPoints, value: strconv.FormatUint(a.nonce, 10)},
+		{name: "Node2.nonce", metricType: common.MetricTypeUint64, numAggregation: generator.numHistoryPoints, value: strconv.FormatUint(a.nonce-a.lag, 10)},
+		{name: "Node1.epoch", metricType: common.MetricTypeUint64, numAggregation: 1, value: strconv.FormatUint(a.nonce/14400, 10)},
+		{name: "Node1.version", metricType: common.MetricTypeString, numAggregation: 1, value: versionValue},
+		{name: "cpu", metricType: common.MetricTypeFloat64, numAggregation: generator.numHistoryPoints, value: strconv.FormatFloat(a.cpu, 'f', 2, 64)},
+	}
+
+	for _, metric := range metrics {
+		err := generator.store.SaveMetric(ctx, a.name+"."+metric.name, metric.metricType, metric.numAggregation, metric.value, recordedAt)
+		if err != nil {
+			return fmt.Errorf("%w while saving the demo metric %s.%s", err, a.name, metric.name)
+		}
+	}
+
+	return nil
+}
+
+// advance moves the synthetic agent state: one block per 6 seconds, a random lag on the second node and a
+// bounded random walk for the CPU load
+func (generator *demoGenerator) advance(a *agent) {
+	numBlocks := uint64(generator.interval / (6 * time.Second))
+	if numBlocks == 0 {
+		numBlocks = 1
+	}
+	a.nonce += numBlocks
+
+	if generator.random.Intn(10) == 0 {
+		a.lag = uint64(generator.random.Intn(5))
+	}
+
+	a.cpu += generator.random.Float64()*10 - 5
+	if a.cpu < 1 {
+		a.cpu = 1
+	}
+	if a.cpu > 99 {
+		a.cpu = 99
+	}
+}
+
+// IsInterfaceNil returns true if there is no value under the interface
+func (generator *demoGenerator) IsInterfaceNil() bool {
+	return generator == nil
+}
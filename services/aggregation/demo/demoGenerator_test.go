@@ -0,0 +1,142 @@
+package demo
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/testsCommon"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewDemoGenerator(t *testing.T) {
+	t.Parallel()
+
+	t.Run("nil storage should error", func(t *testing.T) {
+		t.Parallel()
+
+		generator, err := NewDemoGenerator(ArgsDemoGenerator{Interval: time.Second * 6})
+		assert.Nil(t, generator)
+		assert.Equal(t, errNilStorage, err)
+	})
+	t.Run("invalid interval should error", func(t *testing.T) {
+		t.Parallel()
+
+		generator, err := NewDemoGenerator(ArgsDemoGenerator{Store: &testsCommon.StoreStub{}, Interval: time.Millisecond})
+		assert.Nil(t, generator)
+		assert.True(t, errors.Is(err, errInvalidInterval))
+	})
+	t.Run("invalid number of agents should error", func(t *testing.T) {
+		t.Parallel()
+
+		generator, err := NewDemoGenerator(ArgsDemoGenerator{Store: &testsCommon.StoreStub{}, Interval: time.Second * 6, NumAgents: maxNumAgents + 1})
+		assert.Nil(t, generator)
+		assert.True(t, errors.Is(err, errInvalidNumAgents))
+	})
+	t.Run("invalid number of history points should error", func(t *testing.T) {
+		t.Parallel()
+
+		generator, err := NewDemoGenerator(ArgsDemoGenerator{Store: &testsCommon.StoreStub{}, Interval: time.Second * 6, NumHistoryPoints: -1})
+		assert.Nil(t, generator)
+		assert.True(t, errors.Is(err, errInvalidNumHistoryPoints))
+	})
+	t.Run("should work", func(t *testing.T) {
+		t.Parallel()
+
+		generator, err := NewDemoGenerator(ArgsDemoGenerator{Store: &testsCommon.StoreStub{}, Interval: time.Second * 6})
+		assert.NotNil(t, generator)
+		assert.Nil(t, err)
+		assert.False(t, generator.IsInterfaceNil())
+		assert.Equal(t, defaultNumHistoryPoints, generator.numHistoryPoints)
+		assert.Len(t, generator.agents, defaultNumAgents)
+	})
+	t.Run("custom counts should work", func(t *testing.T) {
+		t.Parallel()
+
+		generator, err := NewDemoGenerator(ArgsDemoGenerator{Store: &testsCommon.StoreStub{}, Interval: time.Second * 6, NumAgents: 5, NumHistoryPoints: 10})
+		assert.Nil(t, err)
+		assert.Equal(t, 10, generator.numHistoryPoints)
+		assert.Len(t, generator.agents, 5)
+		assert.Equal(t, "VM5", generator.agents[4].name)
+		assert.True(t, generator.agents[4].offline)
+		assert.False(t, generator.agents[3].offline)
+	})
+}
+
+func TestDemoGenerator_Seed(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(100000, 0)
+	latestTimestamps := make(map[string]int64)
+	numSaves := 0
+	alarms := make([]string, 0)
+	store := &testsCommon.StoreStub{
+		SaveMetricHandler: func(ctx context.Context, name string, metricType string, numAggregation int, valString string, recordedAt int64) error {
+			numSaves++
+			latestTimestamps[name] = recordedAt
+			return nil
+		},
+		UpdateMetricAlarmHandler: func(ctx context.Context, name string, enabled bool) error {
+			alarms = append(alarms, name)
+			return nil
+		},
+	}
+
+	generator, _ := NewDemoGenerator(ArgsDemoGenerator{Store: store, Interval: time.Second * 6})
+	generator.timeFunc = func() time.Time {
+		return now
+	}
+
+	err := generator.Seed(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"VM1.Active", "VM2.Active", "VM3.Active"}, alarms)
+	assert.Equal(t, len(generator.agents)*6*(generator.numHistoryPoints+1), numSaves)
+	assert.Equal(t, now.Unix(), latestTimestamps["VM1.Node1.nonce"])
+	assert.Equal(t, now.Add(-offlineDuration).Unix(), latestTimestamps["VM3.Node1.nonce"])
+}
+
+func TestDemoGenerator_Execute(t *testing.T) {
+	t.Parallel()
+
+	t.Run("storage error should error", func(t *testing.T) {
+		t.Parallel()
+
+		expectedErr := errors.New("expected error")
+		store := &testsCommon.StoreStub{
+			SaveMetricHandler: func(ctx context.Context, name string, metricType string, numAggregation int, valString string, recordedAt int64) error {
+				return expectedErr
+			},
+		}
+
+		generator, _ := NewDemoGenerator(ArgsDemoGenerator{Store: store, Interval: time.Second * 6})
+		err := generator.Execute(context.Background())
+		assert.True(t, errors.Is(err, expectedErr))
+	})
+	t.Run("should generate values only for the online agents", func(t *testing.T) {
+		t.Parallel()
+
+		values := make(map[string]string)
+		store := &testsCommon.StoreStub{
+			SaveMetricHandler: func(ctx context.Context, name string, metricType string, numAggregation int, valString string, recordedAt int64) error {
+				values[name] = valString
+				return nil
+			},
+		}
+
+		generator, _ := NewDemoGenerator(ArgsDemoGenerator{Store: store, Interval: time.Second * 6})
+		err := generator.Execute(context.Background())
+		assert.Nil(t, err)
+
+		for name := range values {
+			assert.False(t, strings.HasPrefix(name, "VM3."), name)
+		}
+		assert.Equal(t, "true", values["VM1.Active"])
+		assert.Equal(t, "24000001", values["VM1.Node1.nonce"])
+
+		err = generator.Execute(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, "24000002", values["VM1.Node1.nonce"])
+	})
+}
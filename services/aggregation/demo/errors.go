@@ -0,0 +1,10 @@
+package demo
+
+import "errors"
+
+var (
+	errNilStorage              = errors.New("nil storage")
+	errInvalidInterval         = errors.New("invalid interval")
+	errInvalidNumAgents        = errors.New("invalid number of agents")
+	errInvalidNumHistoryPoints = errors.New("invalid number of history points")
+)
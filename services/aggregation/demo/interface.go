@@ -0,0 +1,14 @@
+package demo
+
+import "context"
+
+// Storage defines the storage operations required by the demo data generator
+type Storage interface {
+	// SaveMetric updates the metric definition and appends a new value, trimming history to NumAggregation
+	SaveMetric(ctx context.Context, name string, metricType string, numAggregation int, valString string, recordedAt int64) error
+
+	// UpdateMetricAlarm updates the alarm status of a specific metric
+	UpdateMetricAlarm(ctx context.Context, name string, enabled bool) error
+
+	IsInterfaceNil() bool
+}
@@ -1,35 +1,61 @@
 package factory
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/iulianpascalau/api-monitoring/commonGo"
+	"github.com/iulianpascalau/api-monitoring/commonGo/mqtt"
 	"github.com/iulianpascalau/api-monitoring/services/aggregation/alarm"
 	"github.com/iulianpascalau/api-monitoring/services/aggregation/alarm/executors"
 	"github.com/iulianpascalau/api-monitoring/services/aggregation/alarm/notifiers"
 	"github.com/iulianpascalau/api-monitoring/services/aggregation/api"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/archive"
 	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/computed"
 	"github.com/iulianpascalau/api-monitoring/services/aggregation/config"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/demo"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/ingest"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/oidc"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/sink"
 	"github.com/iulianpascalau/api-monitoring/services/aggregation/storage"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/summaries"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/webui"
 	"github.com/multiversx/mx-chain-core-go/core/check"
 	logger "github.com/multiversx/mx-chain-logger-go"
 	"github.com/multiversx/mx-sdk-go/core/polling"
 )
 
-const unknownWeekDay = -2
+const (
+	unknownWeekDay               = -2
+	defaultMQTTClientID          = "aggregation"
+	defaultMQTTReconnectInterval = 10 * time.Second
+	secondsInDay                 = 24 * 60 * 60
+
+	defaultBlocksBehindPollingInterval = 10 * time.Second
+	defaultBlocksBehindTimeout         = 10 * time.Second
+	defaultBlocksBehindNumAggregation  = 100
+)
 
 var log = logger.GetOrCreate("factory")
 
 type componentsHandler struct {
 	store                 api.Storage
 	server                Server
+	sink                  MetricsSink
+	mqttSubscriber        Subscriber
 	notifiers             []executors.Notifier
 	pollingHandlerTrigger PollingHandler
+	summaryTriggers       []PollingHandler
 	statusHandler         alarm.StatusHandler
 	alarmService          AlarmEngine
+	computedMetrics       PollingHandler
+	blocksBehind          PollingHandler
+	directProbes          PollingHandler
+	demoData              PollingHandler
 }
 
 // NewComponentsHandler creates a new components handler
@@ -40,31 +66,112 @@ func NewComponentsHandler(
 	notifyLogger logger.Logger,
 	appVersion string,
 ) (*componentsHandler, error) {
-	store, err := storage.NewSQLiteStorage(sqlitePath, cfg.RetentionSeconds)
+	return NewComponentsHandlerWithTestHooks(sqlitePath, envFileContents, cfg, notifyLogger, appVersion, TestHooks{})
+}
+
+// NewComponentsHandlerWithTestHooks creates a new components handler wired to the test hooks, so the tests can exercise
+// the database errors and the timed behaviors deterministically
+func NewComponentsHandlerWithTestHooks(
+	sqlitePath string,
+	envFileContents map[string]*commonGo.EnvValue,
+	cfg config.Config,
+	notifyLogger logger.Logger,
+	appVersion string,
+	hooks TestHooks,
+) (*componentsHandler, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	tuning := storage.SQLiteTuning{
+		BusyTimeoutInMs:         cfg.Database.BusyTimeoutInMs,
+		SynchronousMode:         cfg.Database.SynchronousMode,
+		CacheSizeInKB:           cfg.Database.CacheSizeInKB,
+		WALAutoCheckpointPages:  cfg.Database.WALAutoCheckpointPages,
+		CheckpointIntervalInSec: cfg.Database.CheckpointIntervalInSec,
+		OperationTimeoutInMs:    cfg.Database.OperationTimeoutInMs,
+		SlowQueryThresholdInMs:  cfg.Database.SlowQueryThresholdInMs,
+		MaxReadConnections:      cfg.Database.MaxReadConnections,
+		TrashRetentionSeconds:   cfg.TrashRetentionInDays * secondsInDay,
+		Clock:                   hooks.Clock,
+	}
+	if cfg.Archive.Enabled {
+		tuning.Archiver, err = archive.NewFileArchiver(archive.ArgsFileArchiver{
+			Directory: cfg.Archive.Directory,
+			Format:    cfg.Archive.Format,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	store, err := storage.NewSQLiteStorage(sqlitePath, cfg.RetentionSeconds, tuning)
+	if err != nil {
+		return nil, err
+	}
+
+	metricsSink, err := createSinks(cfg, envFileContents)
 	if err != nil {
+		_ = store.Close()
+		return nil, err
+	}
+
+	oidcProvider, err := createOIDCProvider(cfg.OIDC, envFileContents[common.EnvOIDCClientSecret])
+	if err != nil {
+		_ = metricsSink.Close()
+		_ = store.Close()
 		return nil, err
 	}
 
 	serverArgs := api.ArgsWebServer{
-		ServiceKeyApi:             envFileContents[common.EnvServiceKey].Value,
-		AuthUsername:              envFileContents[common.EnvAuthUser].Value,
-		AuthPassword:              envFileContents[common.EnvAuthPassword].Value,
-		ListenAddress:             cfg.ListenAddress,
-		StaticDir:                 cfg.StaticDir,
-		Storage:                   store,
-		GeneralHandler:            api.CORSMiddleware,
-		NumSecondsToConsiderStale: cfg.NumSecondsToConsiderStale,
-		AppVersion:                appVersion,
+		ServiceKeyApi:              envFileContents[common.EnvServiceKey].Value,
+		AuthUsername:               envFileContents[common.EnvAuthUser].Value,
+		AuthPassword:               envFileContents[common.EnvAuthPassword].Value,
+		ListenAddress:              cfg.ListenAddress,
+		StaticDir:                  cfg.StaticDir,
+		StaticFS:                   webui.FS(),
+		Storage:                    createFaultyStorage(store, hooks.FaultInjector),
+		GeneralHandler:             api.CORSMiddleware,
+		NumSecondsToConsiderStale:  cfg.NumSecondsToConsiderStale,
+		AppVersion:                 appVersion,
+		StatusPageEnabled:          cfg.StatusPage.Enabled,
+		StatusPageTitle:            cfg.StatusPage.Title,
+		StatusPageCacheMaxAgeInSec: cfg.StatusPage.CacheMaxAgeInSec,
+		ProfilingEnabled:           cfg.Profiling.Enabled,
+		WriteQueueEnabled:          cfg.WriteQueue.Enabled,
+		WriteQueueCapacity:         cfg.WriteQueue.Capacity,
+		WriteQueueBatchSize:        cfg.WriteQueue.BatchSize,
+		WriteQueueFlushIntervalMs:  cfg.WriteQueue.FlushIntervalMs,
+		Sink:                       metricsSink,
+		Tenants:                    createTenants(cfg.Tenants),
+		APIKeys:                    createAPIKeys(cfg.APIKeys),
+		WebhookSources:             createWebhookSources(cfg.Webhooks),
+		OIDCProvider:               oidcProvider,
+		OIDCPostLoginURL:           cfg.OIDC.PostLoginURL,
+		BasePath:                   cfg.BasePath,
+		TrustedProxies:             cfg.TrustedProxies,
+		MaxAgentLogEntries:         cfg.MaxAgentLogEntries,
+		MaxClockSkewInSeconds:      cfg.MaxClockSkewInSeconds,
+		Clock:                      hooks.Clock,
 	}
 
 	server, err := api.NewServer(serverArgs)
 	if err != nil {
+		_ = metricsSink.Close()
 		return nil, err
 	}
 
 	components := &componentsHandler{
 		store:  store,
 		server: server,
+		sink:   metricsSink,
+	}
+
+	components.mqttSubscriber, err = createMQTTSubscriber(cfg.MQTT, server)
+	if err != nil {
+		_ = server.Close()
+		_ = metricsSink.Close()
+		return nil, err
 	}
 
 	err = components.addAlarmComponents(envFileContents, cfg, notifyLogger, store)
@@ -72,9 +179,242 @@ func NewComponentsHandler(
 		return nil, err
 	}
 
+	err = components.addComputedMetricsComponents(cfg, store)
+	if err != nil {
+		return nil, err
+	}
+
+	err = components.addBlocksBehindComponents(cfg, store)
+	if err != nil {
+		return nil, err
+	}
+
+	err = components.addDirectProbesComponents(cfg, server)
+	if err != nil {
+		return nil, err
+	}
+
+	err = components.addDemoComponents(cfg, store)
+	if err != nil {
+		return nil, err
+	}
+
 	return components, nil
 }
 
+func createSinks(cfg config.Config, envFileContents map[string]*commonGo.EnvValue) (MetricsSink, error) {
+	influxSink, err := createInfluxSink(cfg.Sink, envFileContents[common.EnvInfluxToken])
+	if err != nil {
+		return nil, err
+	}
+
+	natsPublisher, err := createNatsPublisher(cfg.EventBus, envFileContents[common.EnvNatsToken])
+	if err != nil {
+		_ = influxSink.Close()
+		return nil, err
+	}
+
+	federationForwarder, err := createFederationForwarder(cfg.Federation, envFileContents[common.EnvFederationAPIKey])
+	if err != nil {
+		_ = influxSink.Close()
+		_ = natsPublisher.Close()
+		return nil, err
+	}
+
+	return sink.NewSinksCollection(influxSink, natsPublisher, federationForwarder), nil
+}
+
+func createInfluxSink(cfg config.SinkConfig, influxToken *commonGo.EnvValue) (sink.MetricsSink, error) {
+	if !cfg.Enabled {
+		return sink.NewDisabledSink(), nil
+	}
+
+	args := sink.ArgsInfluxSink{
+		URL:           cfg.URL,
+		Org:           cfg.Org,
+		Bucket:        cfg.Bucket,
+		Database:      cfg.Database,
+		Measurement:   cfg.Measurement,
+		QueueCapacity: cfg.QueueCapacity,
+		BatchSize:     cfg.BatchSize,
+		FlushInterval: time.Duration(cfg.FlushIntervalMs) * time.Millisecond,
+		Timeout:       time.Duration(cfg.TimeoutInSec) * time.Second,
+	}
+	if influxToken != nil {
+		args.Token = influxToken.Value
+	}
+
+	log.Debug("enabled InfluxDB sink", "URL", cfg.URL)
+
+	return sink.NewInfluxSink(args)
+}
+
+func createNatsPublisher(cfg config.EventBusConfig, natsToken *commonGo.EnvValue) (sink.MetricsSink, error) {
+	if !cfg.Enabled {
+		return sink.NewDisabledSink(), nil
+	}
+
+	args := sink.ArgsNatsPublisher{
+		URL:           cfg.URL,
+		Subject:       cfg.Subject,
+		QueueCapacity: cfg.QueueCapacity,
+		Timeout:       time.Duration(cfg.TimeoutInSec) * time.Second,
+	}
+	if natsToken != nil {
+		args.Token = natsToken.Value
+	}
+
+	log.Debug("enabled NATS event bus publisher", "URL", cfg.URL, "subject", cfg.Subject)
+
+	return sink.NewNatsPublisher(args)
+}
+
+func createFederationForwarder(cfg config.FederationConfig, apiKey *commonGo.EnvValue) (sink.MetricsSink, error) {
+	if !cfg.Enabled {
+		return sink.NewDisabledSink(), nil
+	}
+
+	args := sink.ArgsFederationForwarder{
+		ParentURL:     cfg.ParentURL,
+		Prefix:        cfg.Prefix,
+		QueueCapacity: cfg.QueueCapacity,
+		FlushInterval: time.Duration(cfg.FlushIntervalInSec) * time.Second,
+		Timeout:       time.Duration(cfg.TimeoutInSec) * time.Second,
+	}
+	if apiKey != nil {
+		args.APIKey = apiKey.Value
+	}
+
+	log.Debug("enabled federation forwarder", "parent URL", cfg.ParentURL, "prefix", cfg.Prefix)
+
+	return sink.NewFederationForwarder(args)
+}
+
+func createTenants(cfg []config.TenantConfig) []api.Tenant {
+	tenants := make([]api.Tenant, 0, len(cfg))
+	for _, tenant := range cfg {
+		tenants = append(tenants, api.Tenant{
+			Name:          tenant.Name,
+			ServiceKeyApi: tenant.ServiceKeyApi,
+			Username:      tenant.Username,
+			Password:      tenant.Password,
+		})
+	}
+
+	return tenants
+}
+
+func createAPIKeys(cfg []config.APIKeyConfig) []api.APIKey {
+	apiKeys := make([]api.APIKey, 0, len(cfg))
+	for _, apiKey := range cfg {
+		apiKeys = append(apiKeys, api.APIKey{
+			Name:   apiKey.Name,
+			Key:    apiKey.Key,
+			Scope:  apiKey.Scope,
+			Tenant: apiKey.Tenant,
+		})
+	}
+
+	return apiKeys
+}
+
+func createWebhookSources(cfg []config.WebhookConfig) []api.WebhookSource {
+	sources := make([]api.WebhookSource, 0, len(cfg))
+	for _, webhook := range cfg {
+		metrics := make([]api.WebhookMetric, 0, len(webhook.Metrics))
+		for _, metric := range webhook.Metrics {
+			metrics = append(metrics, api.WebhookMetric{
+				Name:           metric.Name,
+				Path:           metric.Path,
+				Type:           metric.Type,
+				NumAggregation: metric.NumAggregation,
+			})
+		}
+		sources = append(sources, api.WebhookSource{
+			Name:    webhook.Name,
+			Token:   webhook.Token,
+			Tenant:  webhook.Tenant,
+			Metrics: metrics,
+		})
+	}
+
+	return sources
+}
+
+func createOIDCProvider(cfg config.OIDCConfig, clientSecret *commonGo.EnvValue) (api.OIDCProvider, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	mappings := make([]oidc.GroupMapping, 0, len(cfg.GroupMappings))
+	for _, mapping := range cfg.GroupMappings {
+		mappings = append(mappings, oidc.GroupMapping{
+			Group:  mapping.Group,
+			Role:   mapping.Role,
+			Tenant: mapping.Tenant,
+		})
+	}
+
+	args := oidc.ArgsProvider{
+		IssuerURL:     cfg.IssuerURL,
+		ClientID:      cfg.ClientID,
+		ClientSecret:  clientSecret.Value,
+		RedirectURL:   cfg.RedirectURL,
+		Scopes:        cfg.Scopes,
+		UsernameClaim: cfg.UsernameClaim,
+		GroupsClaim:   cfg.GroupsClaim,
+		GroupMappings: mappings,
+		Timeout:       time.Duration(cfg.TimeoutInSec) * time.Second,
+		TimeFunc:      time.Now,
+	}
+
+	return oidc.NewProvider(args)
+}
+
+func createMQTTSubscriber(cfg config.MQTTConfig, ingester ingest.ReportIngester) (Subscriber, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	handler, err := ingest.NewMQTTReportHandler(ingester)
+	if err != nil {
+		return nil, err
+	}
+
+	clientID := cfg.ClientID
+	if len(clientID) == 0 {
+		clientID = defaultMQTTClientID
+	}
+	argsClient := mqtt.ArgsClient{
+		BrokerURL: cfg.BrokerURL,
+		ClientID:  clientID,
+		Username:  cfg.Username,
+		Password:  cfg.Password,
+		KeepAlive: time.Duration(cfg.KeepAliveInSeconds) * time.Second,
+		Timeout:   time.Duration(cfg.TimeoutInSec) * time.Second,
+		OnMessage: handler.HandleMessage,
+	}
+	client, err := mqtt.NewClient(argsClient)
+	if err != nil {
+		return nil, err
+	}
+
+	reconnectInterval := time.Duration(cfg.ReconnectIntervalInSec) * time.Second
+	if reconnectInterval == 0 {
+		reconnectInterval = defaultMQTTReconnectInterval
+	}
+	argsSubscriber := ingest.ArgsMQTTSubscriber{
+		Client:            client,
+		Topic:             cfg.Topic,
+		QoS:               cfg.QoS,
+		ReconnectInterval: reconnectInterval,
+	}
+
+	log.Debug("enabled MQTT reports ingestion", "broker", cfg.BrokerURL, "topic", cfg.Topic)
+
+	return ingest.NewMQTTSubscriber(argsSubscriber)
+}
+
 func (ch *componentsHandler) addAlarmComponents(
 	envFileContents map[string]*commonGo.EnvValue,
 	cfg config.Config,
@@ -118,14 +458,90 @@ func (ch *componentsHandler) addAlarmComponents(
 		ch.statusHandler,
 		uint32(cfg.NumSecondsToConsiderStale),
 		loopTimeAlarmService,
+		time.Duration(cfg.Alarms.PendingDurationInSec)*time.Second,
+		cfg.Alarms.Rules,
 	)
 	if err != nil {
 		return err
 	}
 
+	err = ch.addSummaryComponents(cfg, notifiersHandler)
+	if err != nil {
+		return err
+	}
+
 	return ch.addSelfCheckAlarmComponents(cfg)
 }
 
+// summaryPeriods maps the configured summary periods to their durations and names
+var summaryPeriods = map[string]struct {
+	duration time.Duration
+	name     string
+}{
+	config.SummaryPeriodDaily:  {duration: 24 * time.Hour, name: "Daily summary"},
+	config.SummaryPeriodWeekly: {duration: 7 * 24 * time.Hour, name: "Weekly summary"},
+}
+
+func (ch *componentsHandler) addSummaryComponents(cfg config.Config, notifiersHandler summaries.OutputNotifiersHandler) error {
+	summariesConfig := cfg.Alarms.Summaries
+	if !summariesConfig.Enabled {
+		return nil
+	}
+
+	pollingInterval := time.Second * time.Duration(summariesConfig.PollingIntervalInSec)
+	for _, schedule := range summariesConfig.Schedules {
+		period, found := summaryPeriods[schedule.Period]
+		if !found {
+			return fmt.Errorf("unknown summary period %s", schedule.Period)
+		}
+		dayOfWeek, err := parseWeekday(schedule.DayOfWeek)
+		if err != nil {
+			return err
+		}
+
+		argsSummaryReporter := summaries.ArgsSummaryReporter{
+			Name:             period.name,
+			Period:           period.duration,
+			Storage:          ch.store,
+			NotifiersHandler: notifiersHandler,
+			NumTopIncidents:  summariesConfig.NumTopIncidents,
+			KeyMetrics:       summariesConfig.KeyMetrics,
+			TimeFunc:         time.Now,
+		}
+		summaryReporter, err := summaries.NewSummaryReporter(argsSummaryReporter)
+		if err != nil {
+			return err
+		}
+
+		argsTrigger := executors.ArgsStatusHandlerTrigger{
+			TimeFunc:      time.Now,
+			Executor:      summaryReporter,
+			TriggerDay:    dayOfWeek,
+			TriggerHour:   schedule.Hour,
+			TriggerMinute: schedule.Minute,
+		}
+		trigger, err := executors.NewStatusHandlerTrigger(argsTrigger)
+		if err != nil {
+			return err
+		}
+
+		argsPollingHandler := polling.ArgsPollingHandler{
+			Log:              log,
+			Name:             period.name,
+			PollingInterval:  pollingInterval,
+			PollingWhenError: pollingInterval,
+			Executor:         trigger,
+		}
+		pollingHandler, err := polling.NewPollingHandler(argsPollingHandler)
+		if err != nil {
+			return err
+		}
+		ch.summaryTriggers = append(ch.summaryTriggers, pollingHandler)
+	}
+
+	return nil
+}
+
 func (ch *componentsHandler) addSelfCheckAlarmComponents(cfg config.Config) error {
 	if !cfg.Alarms.SystemSelfCheck.Enabled {
 		return nil
@@ -160,6 +576,131 @@ func (ch *componentsHandler) addSelfCheckAlarmComponents(cfg config.Config) erro
 	return err
 }
 
+func (ch *componentsHandler) addComputedMetricsComponents(cfg config.Config, store computed.Storage) error {
+	if !cfg.ComputedMetrics.Enabled {
+		return nil
+	}
+
+	computedMetricsHandler, err := computed.NewComputedMetricsHandler(
+		store,
+		cfg.ComputedMetrics.Metrics,
+		cfg.NumSecondsToConsiderStale,
+	)
+	if err != nil {
+		return err
+	}
+
+	argsPollingHandler := polling.ArgsPollingHandler{
+		Log:              log,
+		Name:             "computed metrics",
+		PollingInterval:  time.Second * time.Duration(cfg.ComputedMetrics.PollingIntervalInSec),
+		PollingWhenError: time.Second * time.Duration(cfg.ComputedMetrics.PollingIntervalInSec),
+		Executor:         computedMetricsHandler,
+	}
+	ch.computedMetrics, err = polling.NewPollingHandler(argsPollingHandler)
+
+	return err
+}
+
+func (ch *componentsHandler) addBlocksBehindComponents(cfg config.Config, store computed.Storage) error {
+	if !cfg.BlocksBehind.Enabled {
+		return nil
+	}
+
+	pollingInterval := time.Second * time.Duration(cfg.BlocksBehind.PollingIntervalInSec)
+	if pollingInterval == 0 {
+		pollingInterval = defaultBlocksBehindPollingInterval
+	}
+	timeout := time.Second * time.Duration(cfg.BlocksBehind.TimeoutInSec)
+	if timeout == 0 {
+		timeout = defaultBlocksBehindTimeout
+	}
+	numAggregation := cfg.BlocksBehind.NumAggregation
+	if numAggregation == 0 {
+		numAggregation = defaultBlocksBehindNumAggregation
+	}
+
+	blocksBehindHandler, err := computed.NewBlocksBehindHandler(computed.ArgsBlocksBehindHandler{
+		Store:                     store,
+		ReferenceURL:              cfg.BlocksBehind.ReferenceURL,
+		Timeout:                   timeout,
+		NumAggregation:            numAggregation,
+		NumSecondsToConsiderStale: cfg.NumSecondsToConsiderStale,
+	})
+	if err != nil {
+		return err
+	}
+
+	argsPollingHandler := polling.ArgsPollingHandler{
+		Log:              log,
+		Name:             "blocks behind",
+		PollingInterval:  pollingInterval,
+		PollingWhenError: pollingInterval,
+		Executor:         blocksBehindHandler,
+	}
+	ch.blocksBehind, err = polling.NewPollingHandler(argsPollingHandler)
+
+	return err
+}
+
+func (ch *componentsHandler) addDirectProbesComponents(cfg config.Config, ingester ingest.ReportIngester) error {
+	if !cfg.DirectProbes.Enabled {
+		return nil
+	}
+
+	prober, err := ingest.NewDirectProber(cfg.DirectProbes.AgentConfig(), ingester)
+	if err != nil {
+		return err
+	}
+
+	log.Debug("enabled direct probes", "num endpoints", len(cfg.DirectProbes.Endpoints))
+
+	pollingInterval := time.Second * time.Duration(cfg.DirectProbes.QueryIntervalInSeconds)
+	argsPollingHandler := polling.ArgsPollingHandler{
+		Log:              log,
+		Name:             "direct probes",
+		PollingInterval:  pollingInterval,
+		PollingWhenError: pollingInterval,
+		Executor:         prober,
+	}
+	ch.directProbes, err = polling.NewPollingHandler(argsPollingHandler)
+
+	return err
+}
+
+func (ch *componentsHandler) addDemoComponents(cfg config.Config, store demo.Storage) error {
+	if !cfg.Demo.Enabled {
+		return nil
+	}
+
+	log.Warn("demo mode is enabled, synthetic data will be generated")
+
+	pollingInterval := time.Second * time.Duration(cfg.Demo.PollingIntervalInSec)
+	demoGenerator, err := demo.NewDemoGenerator(demo.ArgsDemoGenerator{
+		Store:    store,
+		Interval: pollingInterval,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = demoGenerator.Seed(context.Background())
+	if err != nil {
+		return err
+	}
+
+	argsPollingHandler := polling.ArgsPollingHandler{
+		Log:              log,
+		Name:             "demo data",
+		PollingInterval:  pollingInterval,
+		PollingWhenError: pollingInterval,
+		Executor:         demoGenerator,
+	}
+	ch.demoData, err = polling.NewPollingHandler(argsPollingHandler)
+
+	return err
+}
+
 func buildNotifiers(notifyLogger logger.Logger, envFileContents map[string]*commonGo.EnvValue, cfg config.Config) ([]executors.Notifier, error) {
 	notifiersCollection := make([]executors.Notifier, 0, 10)
 
@@ -201,16 +742,60 @@ func buildNotifiers(notifyLogger logger.Logger, envFileContents map[string]*comm
 	}
 
 	telegramBotToken := envFileContents[common.EnvTelegramBotToken]
-	telegramChatId := envFileContents[common.EnvTelegramChatId]
-	if len(telegramBotToken.Value) > 0 && len(telegramChatId.Value) > 0 {
-		notifier := notifiers.NewTelegramNotifier(cfg.Alarms.TelegramURL, telegramBotToken.Value, telegramChatId.Value)
+	telegramChatIDs := createTelegramChatIDs(envFileContents[common.EnvTelegramChatId].Value, cfg.Alarms.TelegramChatIDs)
+	if len(telegramBotToken.Value) > 0 && len(telegramChatIDs) > 0 {
+		notifier := notifiers.NewTelegramNotifier(cfg.Alarms.TelegramURL, telegramBotToken.Value, telegramChatIDs)
+		notifiersCollection = append(notifiersCollection, notifier)
+		log.Debug("enabled telegram notifier", "num chats", len(telegramChatIDs))
+	}
+
+	pagerDutyKey := optionalEnvValue(envFileContents, common.EnvPagerDutyKey)
+	if len(pagerDutyKey) > 0 {
+		notifier := notifiers.NewPagerDutyNotifier(cfg.Alarms.PagerDutyURL, pagerDutyKey)
+		notifiersCollection = append(notifiersCollection, notifier)
+		log.Debug("enabled PagerDuty notifier")
+	}
+
+	opsgenieKey := optionalEnvValue(envFileContents, common.EnvOpsgenieKey)
+	if len(opsgenieKey) > 0 {
+		notifier := notifiers.NewOpsgenieNotifier(cfg.Alarms.OpsgenieURL, opsgenieKey)
 		notifiersCollection = append(notifiersCollection, notifier)
-		log.Debug("enabled telegram notifier")
+		log.Debug("enabled Opsgenie notifier")
 	}
 
 	return notifiersCollection, nil
 }
 
+// optionalEnvValue returns the value of an optional .env definition that might be missing from the provided map
+func optionalEnvValue(envFileContents map[string]*commonGo.EnvValue, key string) string {
+	envValue := envFileContents[key]
+	if envValue == nil {
+		return ""
+	}
+
+	return envValue.Value
+}
+
+// createTelegramChatIDs merges the comma separated .env chat IDs with the configured ones, dropping the duplicates
+func createTelegramChatIDs(envChatIDs string, configChatIDs []string) []string {
+	chatIDs := make([]string, 0, len(configChatIDs)+1)
+	seen := make(map[string]struct{})
+	for _, chatID := range append(strings.Split(envChatIDs, ","), configChatIDs...) {
+		chatID = strings.TrimSpace(chatID)
+		if len(chatID) == 0 {
+			continue
+		}
+		if _, found := seen[chatID]; found {
+			continue
+		}
+
+		seen[chatID] = struct{}{}
+		chatIDs = append(chatIDs, chatID)
+	}
+
+	return chatIDs
+}
+
 // GetStore returns the storage component
 func (ch *componentsHandler) GetStore() api.Storage {
 	return ch.store
@@ -225,6 +810,10 @@ func (ch *componentsHandler) GetServer() Server {
 func (ch *componentsHandler) Start() {
 	ch.server.Start()
 
+	if !check.IfNil(ch.mqttSubscriber) {
+		_ = ch.mqttSubscriber.Start()
+	}
+
 	if !check.IfNil(ch.alarmService) {
 		ch.alarmService.Start()
 	}
@@ -233,6 +822,26 @@ func (ch *componentsHandler) Start() {
 		_ = ch.pollingHandlerTrigger.StartProcessingLoop()
 	}
 
+	for _, summaryTrigger := range ch.summaryTriggers {
+		_ = summaryTrigger.StartProcessingLoop()
+	}
+
+	if !check.IfNil(ch.computedMetrics) {
+		_ = ch.computedMetrics.StartProcessingLoop()
+	}
+
+	if !check.IfNil(ch.blocksBehind) {
+		_ = ch.blocksBehind.StartProcessingLoop()
+	}
+
+	if !check.IfNil(ch.directProbes) {
+		_ = ch.directProbes.StartProcessingLoop()
+	}
+
+	if !check.IfNil(ch.demoData) {
+		_ = ch.demoData.StartProcessingLoop()
+	}
+
 	if !check.IfNil(ch.statusHandler) {
 		ch.statusHandler.NotifyAppStart()
 	}
@@ -240,7 +849,24 @@ func (ch *componentsHandler) Start() {
 
 // Close closes the inner components
 func (ch *componentsHandler) Close() {
+	if !check.IfNil(ch.demoData) {
+		_ = ch.demoData.Close()
+	}
+	if !check.IfNil(ch.computedMetrics) {
+		_ = ch.computedMetrics.Close()
+	}
+	if !check.IfNil(ch.blocksBehind) {
+		_ = ch.blocksBehind.Close()
+	}
+	if !check.IfNil(ch.directProbes) {
+		_ = ch.directProbes.Close()
+	}
+
+	if !check.IfNil(ch.mqttSubscriber) {
+		_ = ch.mqttSubscriber.Close()
+	}
 	_ = ch.server.Close()
+	_ = ch.sink.Close()
 	_ = ch.store.Close()
 
 	if !check.IfNil(ch.alarmService) {
@@ -249,6 +875,9 @@ func (ch *componentsHandler) Close() {
 	if !check.IfNil(ch.pollingHandlerTrigger) {
 		_ = ch.pollingHandlerTrigger.Close()
 	}
+	for _, summaryTrigger := range ch.summaryTriggers {
+		_ = summaryTrigger.Close()
+	}
 
 	if !check.IfNil(ch.statusHandler) {
 		ch.statusHandler.SendCloseMessage()
@@ -1,14 +1,17 @@
 package factory
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
 	"github.com/iulianpascalau/api-monitoring/commonGo"
+	agentConfig "github.com/iulianpascalau/api-monitoring/services/agent/config"
 	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
 	"github.com/iulianpascalau/api-monitoring/services/aggregation/config"
 	"github.com/multiversx/mx-chain-core-go/core/check"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func createMockEnvFileContents() map[string]*commonGo.EnvValue {
@@ -25,6 +28,11 @@ func createMockEnvFileContents() map[string]*commonGo.EnvValue {
 		common.EnvSMTPHost:         {Value: "smtp-host"},
 		common.EnvTelegramBotToken: {Value: "telegram-bot"},
 		common.EnvTelegramChatId:   {Value: "telegram-chatid"},
+		common.EnvInfluxToken:      {Value: "influx-token"},
+		common.EnvNatsToken:        {Value: "nats-token"},
+		common.EnvPagerDutyKey:     {Value: "pagerduty-key"},
+		common.EnvOpsgenieKey:      {Value: "opsgenie-key"},
+		common.EnvFederationAPIKey: {Value: "federation-key"},
 	}
 }
 
@@ -47,6 +55,44 @@ func getMockConfig() config.Config {
 				Minute:               0,
 				PollingIntervalInSec: 30,
 			},
+			Summaries: config.SummariesConfig{
+				Enabled:              true,
+				PollingIntervalInSec: 30,
+				NumTopIncidents:      5,
+				KeyMetrics:           []string{"*.nonce"},
+				Schedules: []config.SummaryScheduleConfig{
+					{Period: config.SummaryPeriodDaily, DayOfWeek: "every day", Hour: 8},
+					{Period: config.SummaryPeriodWeekly, DayOfWeek: "Monday", Hour: 8},
+				},
+			},
+		},
+		ComputedMetrics: config.ComputedMetricsConfig{
+			Enabled:              true,
+			PollingIntervalInSec: 10,
+			Metrics: []config.ComputedMetricConfig{
+				{
+					Name:           "Lag.VM2.nonce",
+					Expression:     "VM1.nonce - VM2.nonce",
+					NumAggregation: 10,
+				},
+			},
+		},
+		BlocksBehind: config.BlocksBehindConfig{
+			Enabled:      true,
+			ReferenceURL: "https://gateway.multiversx.com",
+		},
+		DirectProbes: config.DirectProbesConfig{
+			Enabled:                true,
+			QueryIntervalInSeconds: 60,
+			Endpoints: []agentConfig.EndpointConfig{
+				{
+					Name:           "Probe.gateway.epoch",
+					URL:            "https://127.0.0.1:1/network/status/4294967295",
+					Value:          "data.status.erd_epoch_number",
+					Type:           "uint64",
+					NumAggregation: 1,
+				},
+			},
 		},
 	}
 }
@@ -88,13 +134,139 @@ func TestComponentsHandlerMethods(t *testing.T) {
 		serv := handler.GetServer()
 		assert.Equal(t, "*api.server", fmt.Sprintf("%T", serv))
 
-		assert.Equal(t, 4, len(handler.notifiers))
+		assert.Equal(t, 6, len(handler.notifiers))
 		assert.Equal(t, "*notifiers.logNotifier", fmt.Sprintf("%T", handler.notifiers[0]))
 		assert.Equal(t, "*notifiers.pushoverNotifier", fmt.Sprintf("%T", handler.notifiers[1]))
 		assert.Equal(t, "*notifiers.smtpNotifier", fmt.Sprintf("%T", handler.notifiers[2]))
 		assert.Equal(t, "*notifiers.telegramNotifier", fmt.Sprintf("%T", handler.notifiers[3]))
+		assert.Equal(t, "*notifiers.pagerDutyNotifier", fmt.Sprintf("%T", handler.notifiers[4]))
+		assert.Equal(t, "*notifiers.opsgenieNotifier", fmt.Sprintf("%T", handler.notifiers[5]))
 
 		assert.False(t, check.IfNil(handler.pollingHandlerTrigger))
+		assert.Len(t, handler.summaryTriggers, 2)
+		assert.False(t, check.IfNil(handler.computedMetrics))
+		assert.False(t, check.IfNil(handler.blocksBehind))
+		assert.False(t, check.IfNil(handler.directProbes))
+
+		handler.Close()
+	})
+	t.Run("invalid computed metrics should error", func(t *testing.T) {
+		cfg := getMockConfig()
+		cfg.ComputedMetrics.Metrics[0].Expression = "VM1.nonce -"
+
+		handler, err := NewComponentsHandler(
+			":memory:",
+			createMockEnvFileContents(),
+			cfg,
+			log,
+			"test-version",
+		)
+
+		assert.Nil(t, handler)
+		assert.NotNil(t, err)
+	})
+	t.Run("sink components", func(t *testing.T) {
+		cfg := getMockConfig()
+		cfg.Sink = config.SinkConfig{
+			Enabled:  true,
+			Type:     config.SinkTypeInfluxDB,
+			URL:      "http://127.0.0.1:8086",
+			Database: "metrics",
+		}
+		cfg.EventBus = config.EventBusConfig{
+			Enabled: true,
+			Type:    config.EventBusTypeNATS,
+			URL:     "nats://127.0.0.1:4222",
+			Subject: "monitoring.reports",
+		}
+		cfg.Federation = config.FederationConfig{
+			Enabled:   true,
+			ParentURL: "https://global.example.com",
+			Prefix:    "DC1",
+		}
+
+		handler, err := NewComponentsHandler(
+			":memory:",
+			createMockEnvFileContents(),
+			cfg,
+			log,
+			"test-version",
+		)
+		require.Nil(t, err)
+		assert.Equal(t, "*sink.sinksCollection", fmt.Sprintf("%T", handler.sink))
+
+		handler.Close()
+	})
+	t.Run("MQTT components", func(t *testing.T) {
+		cfg := getMockConfig()
+		cfg.MQTT = config.MQTTConfig{
+			Enabled:   true,
+			BrokerURL: "tcp://127.0.0.1:1",
+			Topic:     "monitoring/reports/+",
+		}
+
+		handler, err := NewComponentsHandler(
+			":memory:",
+			createMockEnvFileContents(),
+			cfg,
+			log,
+			"test-version",
+		)
+		require.Nil(t, err)
+		assert.Equal(t, "*ingest.mqttSubscriber", fmt.Sprintf("%T", handler.mqttSubscriber))
+
+		handler.Start()
+		handler.Close()
+	})
+	t.Run("demo components", func(t *testing.T) {
+		cfg := getMockConfig()
+		cfg.Demo.Enabled = true
+		cfg.Demo.PollingIntervalInSec = 6
+
+		handler, err := NewComponentsHandler(
+			":memory:",
+			createMockEnvFileContents(),
+			cfg,
+			log,
+			"test-version",
+		)
+		assert.Nil(t, err)
+		assert.False(t, check.IfNil(handler.demoData))
+
+		metrics, err := handler.GetStore().GetLatestMetrics(context.Background())
+		assert.Nil(t, err)
+		assert.NotEmpty(t, metrics)
+
+		handler.Close()
+	})
+	t.Run("invalid demo config should error", func(t *testing.T) {
+		cfg := getMockConfig()
+		cfg.Demo.Enabled = true
+		cfg.Demo.PollingIntervalInSec = 0
+
+		handler, err := NewComponentsHandler(
+			":memory:",
+			createMockEnvFileContents(),
+			cfg,
+			log,
+			"test-version",
+		)
+		assert.Nil(t, handler)
+		assert.NotNil(t, err)
+	})
+	t.Run("no computed metrics components", func(t *testing.T) {
+		cfg := getMockConfig()
+		cfg.ComputedMetrics.Enabled = false
+
+		handler, _ := NewComponentsHandler(
+			":memory:",
+			createMockEnvFileContents(),
+			cfg,
+			log,
+			"test-version",
+		)
+
+		assert.True(t, check.IfNil(handler.computedMetrics))
 
 		handler.Close()
 	})
@@ -144,14 +316,24 @@ func TestComponentsHandlerMethods(t *testing.T) {
 		serv := handler.GetServer()
 		assert.Equal(t, "*api.server", fmt.Sprintf("%T", serv))
 
-		assert.Equal(t, 4, len(handler.notifiers))
+		assert.Equal(t, 6, len(handler.notifiers))
 		assert.Equal(t, "*notifiers.logNotifier", fmt.Sprintf("%T", handler.notifiers[0]))
 		assert.Equal(t, "*notifiers.pushoverNotifier", fmt.Sprintf("%T", handler.notifiers[1]))
 		assert.Equal(t, "*notifiers.smtpNotifier", fmt.Sprintf("%T", handler.notifiers[2]))
 		assert.Equal(t, "*notifiers.telegramNotifier", fmt.Sprintf("%T", handler.notifiers[3]))
+		assert.Equal(t, "*notifiers.pagerDutyNotifier", fmt.Sprintf("%T", handler.notifiers[4]))
+		assert.Equal(t, "*notifiers.opsgenieNotifier", fmt.Sprintf("%T", handler.notifiers[5]))
 
 		assert.True(t, check.IfNil(handler.pollingHandlerTrigger))
 
 		handler.Close()
 	})
 }
+
+func TestCreateTelegramChatIDs(t *testing.T) {
+	t.Parallel()
+
+	assert.Empty(t, createTelegramChatIDs("", nil))
+	assert.Equal(t, []string{"1", "2"}, createTelegramChatIDs(" 1, 2 ,", nil))
+	assert.Equal(t, []string{"1", "-100", "3"}, createTelegramChatIDs("1", []string{"-100", "1", "3"}))
+}
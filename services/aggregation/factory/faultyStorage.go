@@ -0,0 +1,56 @@
+package factory
+
+import (
+	"context"
+
+	"github.com/iulianpascalau/api-monitoring/commonGo/faults"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/api"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/multiversx/mx-chain-core-go/core/check"
+)
+
+// faultyStorage applies the faults injected in the faults.PointStorageWrite point before each metric write of the web
+// server (the reports of the agents, over HTTP and MQTT), the other operations are not altered
+type faultyStorage struct {
+	api.Storage
+	injector FaultInjector
+}
+
+// createFaultyStorage wraps the storage in one injecting the failures, if an injector is provided
+func createFaultyStorage(store api.Storage, injector FaultInjector) api.Storage {
+	if check.IfNil(injector) {
+		return store
+	}
+
+	log.Warn("the fault injection is enabled, the metric writes can fail")
+
+	return &faultyStorage{
+		Storage:  store,
+		injector: injector,
+	}
+}
+
+// SaveMetric saves the metric value, unless an error is injected
+func (fs *faultyStorage) SaveMetric(ctx context.Context, name string, metricType string, numAggregation int, valString string, recordedAt int64) error {
+	err := fs.injector.Inject(ctx, faults.PointStorageWrite)
+	if err != nil {
+		return err
+	}
+
+	return fs.Storage.SaveMetric(ctx, name, metricType, numAggregation, valString, recordedAt)
+}
+
+// SaveMetrics saves the batch of metric values, unless an error is injected
+func (fs *faultyStorage) SaveMetrics(ctx context.Context, records []common.MetricRecord) error {
+	err := fs.injector.Inject(ctx, faults.PointStorageWrite)
+	if err != nil {
+		return err
+	}
+
+	return fs.Storage.SaveMetrics(ctx, records)
+}
+
+// IsInterfaceNil returns true if there is no value under the interface
+func (fs *faultyStorage) IsInterfaceNil() bool {
+	return fs == nil
+}
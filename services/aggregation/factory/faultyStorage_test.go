@@ -0,0 +1,47 @@
+package factory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/commonGo/faults"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateFaultyStorage(t *testing.T) {
+	t.Parallel()
+
+	store, err := storage.NewSQLiteStorage(":memory:", 3600, storage.SQLiteTuning{})
+	require.Nil(t, err)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	t.Run("nil injector should return the storage", func(t *testing.T) {
+		assert.Equal(t, store, createFaultyStorage(store, nil))
+	})
+	t.Run("the injected errors should fail the metric writes", func(t *testing.T) {
+		injector := faults.NewInjector()
+		faulty := createFaultyStorage(store, injector)
+		assert.False(t, faulty.IsInterfaceNil())
+
+		ctx := context.Background()
+		now := time.Now().Unix()
+		injector.Set(faults.PointStorageWrite, faults.Fault{Err: faults.ErrInjected, Times: 2})
+		assert.Equal(t, faults.ErrInjected, faulty.SaveMetric(ctx, "VM1.CPU", common.MetricTypeUint64, 1, "10", now))
+		records := []common.MetricRecord{{Name: "VM1.CPU", Type: common.MetricTypeUint64, NumAggregation: 1, Value: "20", RecordedAt: now}}
+		assert.Equal(t, faults.ErrInjected, faulty.SaveMetrics(ctx, records))
+		_, err = faulty.GetMetricHistory(ctx, "VM1.CPU")
+		assert.Equal(t, common.ErrMetricNotFound, err)
+
+		require.Nil(t, faulty.SaveMetric(ctx, "VM1.CPU", common.MetricTypeUint64, 1, "30", now))
+		history, err := faulty.GetMetricHistory(ctx, "VM1.CPU")
+		require.Nil(t, err)
+		assert.Equal(t, "30", history.History[0].Value)
+		assert.Equal(t, 3, injector.Hits(faults.PointStorageWrite))
+	})
+}
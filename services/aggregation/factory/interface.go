@@ -1,5 +1,13 @@
 package factory
 
+import (
+	"context"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/commonGo/clock"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/api"
+)
+
 // Server defines the operation of an entity able to serve requests
 type Server interface {
 	Start()
@@ -21,3 +29,37 @@ type PollingHandler interface {
 	Close() error
 	IsInterfaceNil() bool
 }
+
+// MetricsSink defines the operations of a secondary destination of the accepted metric values
+type MetricsSink interface {
+	api.MetricsSink
+	Close() error
+}
+
+// Subscriber defines the operations of a component receiving the agent reports on a non-HTTP transport
+type Subscriber interface {
+	Start() error
+	Close() error
+	IsInterfaceNil() bool
+}
+
+// FaultInjector defines the operations of a component injecting failures in the named points, used by the tests
+type FaultInjector interface {
+	Inject(ctx context.Context, point string) error
+	IsInterfaceNil() bool
+}
+
+// Clock defines the time source of the retention cleaner and of the sessions expiry
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) clock.Timer
+	IsInterfaceNil() bool
+}
+
+// TestHooks are the components replaced by the tests, the nil ones use the production behavior
+type TestHooks struct {
+	// FaultInjector injects the failures in the metric writes of the reports, nil injects nothing
+	FaultInjector FaultInjector
+	// Clock drives the retention cleaner and the sessions expiry, nil uses the system clock
+	Clock Clock
+}
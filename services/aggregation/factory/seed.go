@@ -0,0 +1,43 @@
+package factory
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/demo"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/storage"
+)
+
+// ArgsSeedDatabase is the DTO used to seed a database with synthetic data. The zero counts keep the demo defaults.
+type ArgsSeedDatabase struct {
+	SQLitePath       string
+	Interval         time.Duration
+	NumAgents        int
+	NumHistoryPoints int
+}
+
+// SeedDatabase populates the database with the synthetic agents and metric histories of the demo mode, so the
+// frontend can be developed and demoed without running real agents
+func SeedDatabase(args ArgsSeedDatabase) error {
+	// the retention cleanup must not remove the backfilled history while seeding
+	store, err := storage.NewSQLiteStorage(args.SQLitePath, math.MaxInt32, storage.SQLiteTuning{})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = store.Close()
+	}()
+
+	generator, err := demo.NewDemoGenerator(demo.ArgsDemoGenerator{
+		Store:            store,
+		Interval:         args.Interval,
+		NumAgents:        args.NumAgents,
+		NumHistoryPoints: args.NumHistoryPoints,
+	})
+	if err != nil {
+		return err
+	}
+
+	return generator.Seed(context.Background())
+}
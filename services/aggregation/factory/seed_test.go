@@ -0,0 +1,38 @@
+package factory
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeedDatabase(t *testing.T) {
+	t.Parallel()
+
+	sqlitePath := filepath.Join(t.TempDir(), "data", "seed.db")
+	err := SeedDatabase(ArgsSeedDatabase{SQLitePath: sqlitePath, Interval: time.Millisecond})
+	assert.Error(t, err)
+
+	err = SeedDatabase(ArgsSeedDatabase{SQLitePath: sqlitePath, Interval: time.Minute, NumAgents: 4, NumHistoryPoints: 10})
+	require.NoError(t, err)
+
+	store, err := storage.NewSQLiteStorage(sqlitePath, math.MaxInt32, storage.SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	metrics, err := store.GetLatestMetrics(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, metrics, 4*6)
+
+	history, err := store.GetMetricHistory(context.Background(), "VM4.cpu")
+	require.NoError(t, err)
+	assert.Len(t, history.History, 10)
+}
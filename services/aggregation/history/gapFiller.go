@@ -0,0 +1,88 @@
+package history
+
+import (
+	"sort"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+)
+
+// gapThresholdFactor defines how many steps need to pass between 2 consecutive samples in order to consider
+// that the interval between them is missing
+const gapThresholdFactor = 1.5
+
+// maxFilledPoints caps the number of generated points so a huge gap does not blow up the response
+const maxFilledPoints = 10000
+
+// FillGaps returns a new slice of values in which the missing intervals are represented as defined by the gap mode.
+// The values must be sorted ascending by their recorded timestamp. If the step is not positive, it is inferred
+// as the median interval between the consecutive samples.
+func FillGaps(values []common.MetricValue, gapMode common.GapMode, step int64) []common.MetricValue {
+	if gapMode == common.GapModeNone || len(values) < 2 {
+		return values
+	}
+	if step <= 0 {
+		step = InferStep(values)
+	}
+	if step <= 0 {
+		return values
+	}
+
+	threshold := int64(float64(step) * gapThresholdFactor)
+	result := make([]common.MetricValue, 0, len(values))
+	numFilled := 0
+	for i, value := range values {
+		if i > 0 {
+			previous := values[i-1]
+			// a value stored on change covers the interval until it was last seen
+			previousSeenAt := max(previous.RecordedAt, previous.LastSeenAt)
+			if value.RecordedAt-previousSeenAt > threshold {
+				for timestamp := previousSeenAt + step; timestamp < value.RecordedAt && numFilled < maxFilledPoints; timestamp += step {
+					result = append(result, createFiller(previous, gapMode, timestamp))
+					numFilled++
+				}
+			}
+		}
+
+		result = append(result, value)
+	}
+
+	return result
+}
+
+func createFiller(previous common.MetricValue, gapMode common.GapMode, timestamp int64) common.MetricValue {
+	filler := common.MetricValue{
+		RecordedAt: timestamp,
+		Filled:     true,
+	}
+
+	switch gapMode {
+	case common.GapModeHold:
+		filler.Value = previous.Value
+	case common.GapModeZero:
+		filler.Value = "0"
+	default:
+		filler.IsNull = true
+	}
+
+	return filler
+}
+
+// InferStep returns the (lower) median interval between the consecutive samples or 0 if it can not be determined
+func InferStep(values []common.MetricValue) int64 {
+	deltas := make([]int64, 0, len(values))
+	for i := 1; i < len(values); i++ {
+		delta := values[i].RecordedAt - values[i-1].RecordedAt
+		if delta > 0 {
+			deltas = append(deltas, delta)
+		}
+	}
+	if len(deltas) == 0 {
+		return 0
+	}
+
+	sort.Slice(deltas, func(i, j int) bool {
+		return deltas[i] < deltas[j]
+	})
+
+	return deltas[(len(deltas)-1)/2]
+}
@@ -0,0 +1,106 @@
+package history
+
+import (
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func createValues(timestamps ...int64) []common.MetricValue {
+	values := make([]common.MetricValue, 0, len(timestamps))
+	for _, timestamp := range timestamps {
+		values = append(values, common.MetricValue{Value: "5", RecordedAt: timestamp})
+	}
+
+	return values
+}
+
+func TestInferStep(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, int64(0), InferStep(nil))
+	assert.Equal(t, int64(0), InferStep(createValues(10)))
+	assert.Equal(t, int64(0), InferStep(createValues(10, 10)))
+	assert.Equal(t, int64(10), InferStep(createValues(10, 20, 30, 70, 80)))
+}
+
+func TestFillGaps(t *testing.T) {
+	t.Parallel()
+
+	t.Run("no gap mode should return the values unchanged", func(t *testing.T) {
+		t.Parallel()
+
+		values := createValues(10, 20, 60)
+		assert.Equal(t, values, FillGaps(values, common.GapModeNone, 0))
+	})
+	t.Run("no gaps should return the values unchanged", func(t *testing.T) {
+		t.Parallel()
+
+		values := createValues(10, 20, 30, 44)
+		assert.Equal(t, values, FillGaps(values, common.GapModeZero, 0))
+	})
+	t.Run("hold mode should carry the last value", func(t *testing.T) {
+		t.Parallel()
+
+		values := createValues(10, 20, 50)
+		values[1].Value = "7"
+		result := FillGaps(values, common.GapModeHold, 0)
+
+		expected := []common.MetricValue{
+			{Value: "5", RecordedAt: 10},
+			{Value: "7", RecordedAt: 20},
+			{Value: "7", RecordedAt: 30, Filled: true},
+			{Value: "7", RecordedAt: 40, Filled: true},
+			{Value: "5", RecordedAt: 50},
+		}
+		assert.Equal(t, expected, result)
+	})
+	t.Run("the values stored on change should cover the interval until last seen", func(t *testing.T) {
+		t.Parallel()
+
+		values := createValues(10, 20, 70)
+		values[1].LastSeenAt = 40
+		result := FillGaps(values, common.GapModeNull, 10)
+
+		expected := []common.MetricValue{
+			{Value: "5", RecordedAt: 10},
+			{Value: "5", RecordedAt: 20, LastSeenAt: 40},
+			{RecordedAt: 50, Filled: true, IsNull: true},
+			{RecordedAt: 60, Filled: true, IsNull: true},
+			{Value: "5", RecordedAt: 70},
+		}
+		assert.Equal(t, expected, result)
+	})
+	t.Run("zero mode should insert zeros", func(t *testing.T) {
+		t.Parallel()
+
+		result := FillGaps(createValues(10, 40), common.GapModeZero, 10)
+
+		expected := []common.MetricValue{
+			{Value: "5", RecordedAt: 10},
+			{Value: "0", RecordedAt: 20, Filled: true},
+			{Value: "0", RecordedAt: 30, Filled: true},
+			{Value: "5", RecordedAt: 40},
+		}
+		assert.Equal(t, expected, result)
+	})
+	t.Run("null mode should insert null values", func(t *testing.T) {
+		t.Parallel()
+
+		result := FillGaps(createValues(10, 30), common.GapModeNull, 10)
+
+		expected := []common.MetricValue{
+			{Value: "5", RecordedAt: 10},
+			{RecordedAt: 20, Filled: true, IsNull: true},
+			{Value: "5", RecordedAt: 30},
+		}
+		assert.Equal(t, expected, result)
+	})
+	t.Run("huge gaps should be capped", func(t *testing.T) {
+		t.Parallel()
+
+		result := FillGaps(createValues(0, 1, 2, 1000000), common.GapModeZero, 0)
+		assert.Len(t, result, 4+maxFilledPoints)
+	})
+}
@@ -0,0 +1,75 @@
+package history
+
+import (
+	"strconv"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+)
+
+// RatePoint is the increase rate of a counter between a sample and the previous one
+type RatePoint struct {
+	RecordedAt int64   `json:"recordedAt"`
+	PerSecond  float64 `json:"perSecond"`
+}
+
+// Rate is the increase rate of a monotonically increasing metric over a window. The increase and the rates are nil
+// if the window holds less than 2 samples.
+type Rate struct {
+	NumSamples int         `json:"numSamples"`
+	From       int64       `json:"from,omitempty"`
+	To         int64       `json:"to,omitempty"`
+	Increase   *float64    `json:"increase"`
+	PerSecond  *float64    `json:"perSecond"`
+	PerMinute  *float64    `json:"perMinute"`
+	Points     []RatePoint `json:"points"`
+}
+
+// ComputeRate returns the increase rate of the counter values, which must be sorted ascending by their recorded
+// timestamp. A decrease is a counter reset (e.g. a resynced node): its pair of samples adds no increase. The filled
+// and the non-numeric values are ignored.
+func ComputeRate(values []common.MetricValue) Rate {
+	rate := Rate{
+		Points: make([]RatePoint, 0),
+	}
+
+	increase := 0.0
+	var previous common.MetricValue
+	var previousValue float64
+	for _, value := range values {
+		if value.Filled || value.IsNull {
+			continue
+		}
+		parsed, err := strconv.ParseFloat(value.Value, 64)
+		if err != nil {
+			continue
+		}
+
+		rate.NumSamples++
+		if rate.NumSamples == 1 {
+			rate.From = value.RecordedAt
+		} else if value.RecordedAt > previous.RecordedAt && parsed >= previousValue {
+			delta := parsed - previousValue
+			increase += delta
+			rate.Points = append(rate.Points, RatePoint{
+				RecordedAt: value.RecordedAt,
+				PerSecond:  delta / float64(value.RecordedAt-previous.RecordedAt),
+			})
+		}
+		rate.To = value.RecordedAt
+		previous = value
+		previousValue = parsed
+	}
+
+	duration := float64(rate.To - rate.From)
+	if rate.NumSamples < 2 || duration <= 0 {
+		return rate
+	}
+
+	perSecond := increase / duration
+	perMinute := perSecond * 60
+	rate.Increase = &increase
+	rate.PerSecond = &perSecond
+	rate.PerMinute = &perMinute
+
+	return rate
+}
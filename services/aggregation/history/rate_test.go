@@ -0,0 +1,61 @@
+package history
+
+import (
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputeRate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("less than 2 samples should not compute the rate", func(t *testing.T) {
+		t.Parallel()
+
+		rate := ComputeRate(nil)
+		assert.Equal(t, 0, rate.NumSamples)
+		assert.Nil(t, rate.PerSecond)
+		assert.Empty(t, rate.Points)
+
+		rate = ComputeRate([]common.MetricValue{{Value: "100", RecordedAt: 10}, {Value: "abc", RecordedAt: 20}})
+		assert.Equal(t, 1, rate.NumSamples)
+		assert.Nil(t, rate.Increase)
+		assert.Nil(t, rate.PerMinute)
+	})
+	t.Run("should compute the rate", func(t *testing.T) {
+		t.Parallel()
+
+		values := []common.MetricValue{
+			{Value: "100", RecordedAt: 0},
+			{Value: "110", RecordedAt: 60},
+			{RecordedAt: 90, Filled: true, IsNull: true},
+			{Value: "130", RecordedAt: 120},
+		}
+		rate := ComputeRate(values)
+		assert.Equal(t, 3, rate.NumSamples)
+		assert.Equal(t, int64(0), rate.From)
+		assert.Equal(t, int64(120), rate.To)
+		require.NotNil(t, rate.Increase)
+		assert.Equal(t, 30.0, *rate.Increase)
+		assert.Equal(t, 0.25, *rate.PerSecond)
+		assert.Equal(t, 15.0, *rate.PerMinute)
+		assert.Equal(t, []RatePoint{{RecordedAt: 60, PerSecond: 10.0 / 60}, {RecordedAt: 120, PerSecond: 20.0 / 60}}, rate.Points)
+	})
+	t.Run("a counter reset should add no increase", func(t *testing.T) {
+		t.Parallel()
+
+		values := []common.MetricValue{
+			{Value: "100", RecordedAt: 0},
+			{Value: "160", RecordedAt: 60},
+			{Value: "10", RecordedAt: 120},
+			{Value: "70", RecordedAt: 180},
+		}
+		rate := ComputeRate(values)
+		assert.Equal(t, 4, rate.NumSamples)
+		assert.Equal(t, 120.0, *rate.Increase)
+		assert.Equal(t, 40.0, *rate.PerMinute)
+		assert.Len(t, rate.Points, 2)
+	})
+}
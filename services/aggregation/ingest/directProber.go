@@ -0,0 +1,100 @@
+package ingest
+
+import (
+	"context"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/commonGo/clock"
+	agentCommon "github.com/iulianpascalau/api-monitoring/services/agent/common"
+	agentConfig "github.com/iulianpascalau/api-monitoring/services/agent/config"
+	"github.com/iulianpascalau/api-monitoring/services/agent/engine"
+	"github.com/iulianpascalau/api-monitoring/services/agent/health"
+	"github.com/iulianpascalau/api-monitoring/services/agent/poller"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/api"
+)
+
+const activeHeartbeatSuffix = ".Active"
+
+type directProber struct {
+	engine Engine
+}
+
+// NewDirectProber creates the component polling the direct probes as an agent would, with the agent engine, and
+// ingesting their values as the agent reports are. The config must be valid.
+func NewDirectProber(cfg agentConfig.Config, ingester ReportIngester) (*directProber, error) {
+	if ingester == nil {
+		return nil, errNilIngester
+	}
+
+	argsPoller := poller.ArgsHTTPPoller{
+		Timeout:        time.Duration(cfg.QueryIntervalInSeconds) * time.Second,
+		MaxConcurrency: int(cfg.MaxConcurrentPolls),
+		ProxyURL:       cfg.Network.ProxyURL,
+		IPFamily:       cfg.Network.IPFamily,
+	}
+	poll, err := poller.NewHTTPPoller(argsPoller)
+	if err != nil {
+		return nil, err
+	}
+	reporter := &ingestReporter{
+		ingester:          ingester,
+		name:              cfg.Name,
+		intervalInSeconds: int(cfg.QueryIntervalInSeconds),
+	}
+	eng, err := engine.NewAgentEngine(cfg, poll, reporter, health.NewStatsTracker(), clock.NewSystemClock())
+	if err != nil {
+		return nil, err
+	}
+
+	return &directProber{
+		engine: eng,
+	}, nil
+}
+
+// Execute polls the direct probes once and ingests the results, the failures are logged by the engine
+func (prober *directProber) Execute(ctx context.Context) error {
+	prober.engine.Process(ctx)
+
+	return nil
+}
+
+// IsInterfaceNil returns true if there is no value under the interface
+func (prober *directProber) IsInterfaceNil() bool {
+	return prober == nil
+}
+
+// ingestReporter is the agent reporter ingesting the polled values in this service
+type ingestReporter struct {
+	ingester          ReportIngester
+	name              string
+	intervalInSeconds int
+}
+
+// Report ingests the polled results and the heartbeat of the direct probes
+func (reporter *ingestReporter) Report(ctx context.Context, results map[string]agentCommon.MetricResult) error {
+	payload := api.MetricReportPayload{
+		Metrics: make(map[string]api.ReportedMetric, len(results)+1),
+	}
+	for name, result := range results {
+		payload.Metrics[name] = api.ReportedMetric{
+			Value:          result.Value,
+			Type:           result.Config.Type,
+			NumAggregation: result.Config.NumAggregation,
+			Tags:           result.Config.Tags,
+			Interval:       reporter.intervalInSeconds,
+		}
+	}
+	payload.Metrics[reporter.name+activeHeartbeatSuffix] = api.ReportedMetric{
+		Value:          "true",
+		Type:           "bool",
+		NumAggregation: 1,
+		Interval:       reporter.intervalInSeconds,
+	}
+
+	return reporter.ingester.IngestReport(ctx, "", payload)
+}
+
+// IsInterfaceNil returns true if there is no value under the interface
+func (reporter *ingestReporter) IsInterfaceNil() bool {
+	return reporter == nil
+}
@@ -0,0 +1,108 @@
+package ingest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/services/agent/config"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createDirectProbesConfig(url string) config.Config {
+	return config.Config{
+		Name:                   "DirectProbes",
+		QueryIntervalInSeconds: 60,
+		Endpoints: []config.EndpointConfig{
+			{
+				Name:           "Probe.gateway.epoch",
+				URL:            url + "/network/status/4294967295",
+				Value:          "data.status.erd_epoch_number",
+				Type:           "uint64",
+				NumAggregation: 10,
+				Tags:           map[string]string{"network": "mainnet"},
+			},
+			{
+				Name:           "Probe.gateway.missing",
+				URL:            url + "/missing",
+				Value:          "data.status.erd_epoch_number",
+				Type:           "uint64",
+				NumAggregation: 10,
+				Validation:     config.ValidationConfig{MustEqual: "1"},
+			},
+		},
+	}
+}
+
+func TestNewDirectProber(t *testing.T) {
+	t.Parallel()
+
+	t.Run("nil ingester should error", func(t *testing.T) {
+		t.Parallel()
+
+		prober, err := NewDirectProber(createDirectProbesConfig("https://gateway.multiversx.com"), nil)
+		assert.Nil(t, prober)
+		assert.Equal(t, errNilIngester, err)
+	})
+	t.Run("invalid validation should error", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := createDirectProbesConfig("https://gateway.multiversx.com")
+		cfg.Endpoints[0].Validation.Regex = "("
+		prober, err := NewDirectProber(cfg, &reportIngesterStub{})
+		assert.Nil(t, prober)
+		assert.NotNil(t, err)
+	})
+	t.Run("should work", func(t *testing.T) {
+		t.Parallel()
+
+		prober, err := NewDirectProber(createDirectProbesConfig("https://gateway.multiversx.com"), &reportIngesterStub{})
+		require.Nil(t, err)
+		assert.False(t, prober.IsInterfaceNil())
+	})
+}
+
+func TestDirectProber_Execute(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/network/status/4294967295" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"data": {"status": {"erd_epoch_number": 1500}}}`))
+	}))
+	defer server.Close()
+
+	var ingested []api.MetricReportPayload
+	ingester := &reportIngesterStub{
+		ingestReportHandler: func(ctx context.Context, tenant string, payload api.MetricReportPayload) error {
+			assert.Empty(t, tenant)
+			ingested = append(ingested, payload)
+			return nil
+		},
+	}
+
+	prober, err := NewDirectProber(createDirectProbesConfig(server.URL), ingester)
+	require.Nil(t, err)
+	require.Nil(t, prober.Execute(context.Background()))
+
+	require.Len(t, ingested, 1)
+	expectedMetrics := map[string]api.ReportedMetric{
+		"Probe.gateway.epoch": {
+			Value:          "1500",
+			Type:           "uint64",
+			NumAggregation: 10,
+			Tags:           map[string]string{"network": "mainnet"},
+			Interval:       60,
+		},
+		// the failed poll fails the validation
+		"Probe.gateway.missing.ok": {Value: "false", Type: "bool", NumAggregation: 10, Interval: 60},
+		"DirectProbes.Active":      {Value: "true", Type: "bool", NumAggregation: 1, Interval: 60},
+	}
+	assert.Equal(t, expectedMetrics, ingested[0].Metrics)
+}
@@ -0,0 +1,13 @@
+package ingest
+
+import "errors"
+
+var (
+	errNilIngester        = errors.New("nil report ingester")
+	errNilMQTTClient      = errors.New("nil MQTT client")
+	errEmptyTopic         = errors.New("empty topic")
+	errInvalidPayload     = errors.New("invalid payload")
+	errUnauthorized       = errors.New("invalid API key")
+	errAlreadyStarted     = errors.New("subscriber already started")
+	errInvalidReconnectIn = errors.New("invalid reconnect interval")
+)
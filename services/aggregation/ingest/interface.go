@@ -0,0 +1,27 @@
+package ingest
+
+import (
+	"context"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/api"
+)
+
+// ReportIngester defines the component able to persist the reports received on the non-HTTP transports
+type ReportIngester interface {
+	TenantOfReportKey(key string) (string, bool)
+	IngestReport(ctx context.Context, tenant string, payload api.MetricReportPayload) error
+}
+
+// Engine defines the agent engine polling the direct probes
+type Engine interface {
+	Process(ctx context.Context)
+}
+
+// MQTTClient defines the operations of an MQTT client able to subscribe to topics
+type MQTTClient interface {
+	Connect(ctx context.Context) error
+	Subscribe(ctx context.Context, topicFilter string, qos byte) error
+	Done() <-chan struct{}
+	Close() error
+	IsInterfaceNil() bool
+}
@@ -0,0 +1,71 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/api"
+	logger "github.com/multiversx/mx-chain-logger-go"
+)
+
+var log = logger.GetOrCreate("ingest")
+
+const ingestTimeout = 30 * time.Second
+
+// mqttReportPayload is the message published by the agents: the report and, as there are no message headers in
+// MQTT 3.1.1, the API key
+type mqttReportPayload struct {
+	ApiKey string `json:"apiKey"`
+	api.MetricReportPayload
+}
+
+type mqttReportHandler struct {
+	ingester ReportIngester
+}
+
+// NewMQTTReportHandler creates the handler of the reports received over MQTT. The reports are ingested for the tenant
+// of their API key, accepting the same keys as the HTTP report endpoint, and the messages carrying a wrong API key
+// are discarded.
+func NewMQTTReportHandler(ingester ReportIngester) (*mqttReportHandler, error) {
+	if ingester == nil {
+		return nil, errNilIngester
+	}
+
+	return &mqttReportHandler{
+		ingester: ingester,
+	}, nil
+}
+
+// HandleMessage ingests the report contained in an MQTT message. The errors are only logged, as there is no way
+// to answer the publisher.
+func (handler *mqttReportHandler) HandleMessage(topic string, message []byte) {
+	err := handler.handle(message)
+	if err != nil {
+		log.Warn("MQTT report rejected", "topic", topic, "error", err)
+		return
+	}
+
+	log.Debug("received MQTT report", "topic", topic)
+}
+
+func (handler *mqttReportHandler) handle(message []byte) error {
+	payload := mqttReportPayload{}
+	err := json.Unmarshal(message, &payload)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errInvalidPayload, err.Error())
+	}
+	tenant, found := handler.ingester.TenantOfReportKey(payload.ApiKey)
+	if !found {
+		return errUnauthorized
+	}
+	if len(payload.Metrics) == 0 {
+		return fmt.Errorf("%w: no metrics", errInvalidPayload)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ingestTimeout)
+	defer cancel()
+
+	return handler.ingester.IngestReport(ctx, tenant, payload.MetricReportPayload)
+}
@@ -0,0 +1,102 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type reportIngesterStub struct {
+	tenantOfReportKeyHandler func(key string) (string, bool)
+	ingestReportHandler      func(ctx context.Context, tenant string, payload api.MetricReportPayload) error
+}
+
+func (stub *reportIngesterStub) TenantOfReportKey(key string) (string, bool) {
+	if stub.tenantOfReportKeyHandler != nil {
+		return stub.tenantOfReportKeyHandler(key)
+	}
+
+	return "", false
+}
+
+func (stub *reportIngesterStub) IngestReport(ctx context.Context, tenant string, payload api.MetricReportPayload) error {
+	if stub.ingestReportHandler != nil {
+		return stub.ingestReportHandler(ctx, tenant, payload)
+	}
+
+	return nil
+}
+
+func TestNewMQTTReportHandler(t *testing.T) {
+	t.Parallel()
+
+	t.Run("nil ingester should error", func(t *testing.T) {
+		t.Parallel()
+
+		handler, err := NewMQTTReportHandler(nil)
+		assert.Nil(t, handler)
+		assert.Equal(t, errNilIngester, err)
+	})
+	t.Run("should work", func(t *testing.T) {
+		t.Parallel()
+
+		handler, err := NewMQTTReportHandler(&reportIngesterStub{})
+		assert.NotNil(t, handler)
+		assert.Nil(t, err)
+	})
+}
+
+func TestMqttReportHandler_HandleMessage(t *testing.T) {
+	t.Parallel()
+
+	var ingested []api.MetricReportPayload
+	var tenants []string
+	ingestErr := error(nil)
+	ingester := &reportIngesterStub{
+		tenantOfReportKeyHandler: func(key string) (string, bool) {
+			tenantsByKey := map[string]string{"secret": "", "acme-secret": "acme"}
+			tenant, found := tenantsByKey[key]
+			return tenant, found
+		},
+		ingestReportHandler: func(ctx context.Context, tenant string, payload api.MetricReportPayload) error {
+			ingested = append(ingested, payload)
+			tenants = append(tenants, tenant)
+			return ingestErr
+		},
+	}
+	handler, _ := NewMQTTReportHandler(ingester)
+
+	err := handler.handle([]byte("{"))
+	assert.ErrorIs(t, err, errInvalidPayload)
+
+	err = handler.handle([]byte(`{"apiKey": "wrong", "metrics": {"VM1.Active": {"value": "true", "type": "bool", "numAggregation": 1}}}`))
+	assert.Equal(t, errUnauthorized, err)
+
+	err = handler.handle([]byte(`{"metrics": {"VM1.Active": {"value": "true", "type": "bool", "numAggregation": 1}}}`))
+	assert.Equal(t, errUnauthorized, err)
+
+	err = handler.handle([]byte(`{"apiKey": "secret", "metrics": {}}`))
+	assert.ErrorIs(t, err, errInvalidPayload)
+	assert.Empty(t, ingested)
+
+	handler.HandleMessage("monitoring/reports/VM1",
+		[]byte(`{"apiKey": "secret", "metrics": {"VM1.Active": {"value": "true", "type": "bool", "numAggregation": 1}}}`))
+	require.Len(t, ingested, 1)
+	assert.Equal(t, "true", ingested[0].Metrics["VM1.Active"].Value)
+	assert.Equal(t, "", tenants[0])
+
+	// the tenant keys report for their tenant
+	err = handler.handle([]byte(`{"apiKey": "acme-secret", "metrics": {"VM1.Active": {"value": "false", "type": "bool", "numAggregation": 1}}}`))
+	assert.Nil(t, err)
+	require.Len(t, ingested, 2)
+	assert.Equal(t, "false", ingested[1].Metrics["VM1.Active"].Value)
+	assert.Equal(t, "acme", tenants[1])
+
+	ingestErr = errors.New("write queue is full")
+	err = handler.handle([]byte(`{"apiKey": "secret", "metrics": {"VM1.Active": {"value": "true", "type": "bool", "numAggregation": 1}}}`))
+	assert.Equal(t, ingestErr, err)
+}
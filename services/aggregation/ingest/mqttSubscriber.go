@@ -0,0 +1,120 @@
+package ingest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/multiversx/mx-chain-core-go/core/check"
+)
+
+// ArgsMQTTSubscriber defines the DTO struct for the NewMQTTSubscriber constructor function
+type ArgsMQTTSubscriber struct {
+	Client            MQTTClient
+	Topic             string
+	QoS               byte
+	ReconnectInterval time.Duration
+}
+
+// mqttSubscriber keeps the subscription to the agents reports topic alive, reconnecting to the broker when the
+// connection is lost. The received messages are delivered by the client to its message handler.
+type mqttSubscriber struct {
+	client            MQTTClient
+	topic             string
+	qos               byte
+	reconnectInterval time.Duration
+	mut               sync.Mutex
+	cancel            context.CancelFunc
+	wg                sync.WaitGroup
+}
+
+// NewMQTTSubscriber creates a new MQTT subscriber
+func NewMQTTSubscriber(args ArgsMQTTSubscriber) (*mqttSubscriber, error) {
+	if check.IfNil(args.Client) {
+		return nil, errNilMQTTClient
+	}
+	if len(args.Topic) == 0 {
+		return nil, errEmptyTopic
+	}
+	if args.ReconnectInterval <= 0 {
+		return nil, errInvalidReconnectIn
+	}
+
+	return &mqttSubscriber{
+		client:            args.Client,
+		topic:             args.Topic,
+		qos:               args.QoS,
+		reconnectInterval: args.ReconnectInterval,
+	}, nil
+}
+
+// Start connects and subscribes in the background
+func (subscriber *mqttSubscriber) Start() error {
+	subscriber.mut.Lock()
+	defer subscriber.mut.Unlock()
+
+	if subscriber.cancel != nil {
+		return errAlreadyStarted
+	}
+
+	var ctx context.Context
+	ctx, subscriber.cancel = context.WithCancel(context.Background())
+	subscriber.wg.Add(1)
+	go subscriber.processLoop(ctx)
+
+	return nil
+}
+
+func (subscriber *mqttSubscriber) processLoop(ctx context.Context) {
+	defer subscriber.wg.Done()
+
+	for {
+		err := subscriber.subscribe(ctx)
+		if err != nil {
+			log.Warn("failed to subscribe to the MQTT reports topic, retrying",
+				"topic", subscriber.topic, "retry in", subscriber.reconnectInterval, "error", err)
+		} else {
+			log.Info("subscribed to the MQTT reports topic", "topic", subscriber.topic)
+
+			select {
+			case <-subscriber.client.Done():
+				log.Warn("lost the MQTT broker connection, reconnecting", "retry in", subscriber.reconnectInterval)
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case <-time.After(subscriber.reconnectInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (subscriber *mqttSubscriber) subscribe(ctx context.Context) error {
+	err := subscriber.client.Connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	return subscriber.client.Subscribe(ctx, subscriber.topic, subscriber.qos)
+}
+
+// Close stops the reconnect loop and disconnects from the broker
+func (subscriber *mqttSubscriber) Close() error {
+	subscriber.mut.Lock()
+	if subscriber.cancel != nil {
+		subscriber.cancel()
+	}
+	subscriber.mut.Unlock()
+
+	subscriber.wg.Wait()
+
+	return subscriber.client.Close()
+}
+
+// IsInterfaceNil returns true if there is no value under the interface
+func (subscriber *mqttSubscriber) IsInterfaceNil() bool {
+	return subscriber == nil
+}
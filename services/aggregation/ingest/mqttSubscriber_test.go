@@ -0,0 +1,172 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mqttClientStub struct {
+	mut           sync.Mutex
+	numConnects   int
+	connectErr    error
+	subscriptions []string
+	done          chan struct{}
+	closed        bool
+}
+
+func (stub *mqttClientStub) Connect(_ context.Context) error {
+	stub.mut.Lock()
+	defer stub.mut.Unlock()
+
+	stub.numConnects++
+	if stub.connectErr != nil {
+		return stub.connectErr
+	}
+	stub.done = make(chan struct{})
+
+	return nil
+}
+
+func (stub *mqttClientStub) Subscribe(_ context.Context, topicFilter string, _ byte) error {
+	stub.mut.Lock()
+	defer stub.mut.Unlock()
+
+	stub.subscriptions = append(stub.subscriptions, topicFilter)
+
+	return nil
+}
+
+func (stub *mqttClientStub) Done() <-chan struct{} {
+	stub.mut.Lock()
+	defer stub.mut.Unlock()
+
+	return stub.done
+}
+
+func (stub *mqttClientStub) dropConnection() {
+	stub.mut.Lock()
+	defer stub.mut.Unlock()
+
+	close(stub.done)
+}
+
+func (stub *mqttClientStub) getNumConnects() int {
+	stub.mut.Lock()
+	defer stub.mut.Unlock()
+
+	return stub.numConnects
+}
+
+func (stub *mqttClientStub) Close() error {
+	stub.mut.Lock()
+	defer stub.mut.Unlock()
+
+	stub.closed = true
+
+	return nil
+}
+
+func (stub *mqttClientStub) IsInterfaceNil() bool {
+	return stub == nil
+}
+
+func createMockArgsMQTTSubscriber() ArgsMQTTSubscriber {
+	return ArgsMQTTSubscriber{
+		Client:            &mqttClientStub{},
+		Topic:             "monitoring/reports/+",
+		QoS:               1,
+		ReconnectInterval: time.Millisecond * 10,
+	}
+}
+
+func TestNewMQTTSubscriber(t *testing.T) {
+	t.Parallel()
+
+	t.Run("nil client should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsMQTTSubscriber()
+		args.Client = nil
+		subscriber, err := NewMQTTSubscriber(args)
+		assert.Nil(t, subscriber)
+		assert.Equal(t, errNilMQTTClient, err)
+	})
+	t.Run("empty topic should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsMQTTSubscriber()
+		args.Topic = ""
+		subscriber, err := NewMQTTSubscriber(args)
+		assert.Nil(t, subscriber)
+		assert.Equal(t, errEmptyTopic, err)
+	})
+	t.Run("invalid reconnect interval should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsMQTTSubscriber()
+		args.ReconnectInterval = 0
+		subscriber, err := NewMQTTSubscriber(args)
+		assert.Nil(t, subscriber)
+		assert.Equal(t, errInvalidReconnectIn, err)
+	})
+	t.Run("should work", func(t *testing.T) {
+		t.Parallel()
+
+		subscriber, err := NewMQTTSubscriber(createMockArgsMQTTSubscriber())
+		assert.Nil(t, err)
+		assert.False(t, subscriber.IsInterfaceNil())
+	})
+}
+
+func TestMqttSubscriber_Start(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should resubscribe after the connection is lost", func(t *testing.T) {
+		t.Parallel()
+
+		client := &mqttClientStub{}
+		args := createMockArgsMQTTSubscriber()
+		args.Client = client
+		subscriber, _ := NewMQTTSubscriber(args)
+
+		require.Nil(t, subscriber.Start())
+		assert.Equal(t, errAlreadyStarted, subscriber.Start())
+
+		require.Eventually(t, func() bool {
+			return client.getNumConnects() == 1
+		}, time.Second, time.Millisecond*5)
+
+		client.dropConnection()
+		require.Eventually(t, func() bool {
+			return client.getNumConnects() == 2
+		}, time.Second, time.Millisecond*5)
+
+		require.Nil(t, subscriber.Close())
+
+		client.mut.Lock()
+		defer client.mut.Unlock()
+		assert.True(t, client.closed)
+		assert.Equal(t, []string{"monitoring/reports/+", "monitoring/reports/+"}, client.subscriptions)
+	})
+	t.Run("should retry the failed connections", func(t *testing.T) {
+		t.Parallel()
+
+		client := &mqttClientStub{connectErr: errors.New("connection refused")}
+		args := createMockArgsMQTTSubscriber()
+		args.Client = client
+		subscriber, _ := NewMQTTSubscriber(args)
+
+		require.Nil(t, subscriber.Start())
+		require.Eventually(t, func() bool {
+			return client.getNumConnects() >= 3
+		}, time.Second, time.Millisecond*5)
+
+		require.Nil(t, subscriber.Close())
+	})
+}
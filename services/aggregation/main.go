@@ -1,11 +1,13 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/signal"
 	"path"
 	"runtime"
+	"strings"
 	"syscall"
 	"time"
 
@@ -22,11 +24,19 @@ const (
 	defaultLogsPath      = "logs"
 	defaultDataPath      = "data"
 	dbFile               = "sqlite.db"
+	demoDBFile           = "demo.db"
+	demoServiceKey       = "demo-service-key"
+	demoUser             = "demo"
+	demoPassword         = "demo"
 	logFilePrefix        = "agent"
+	logServiceName       = "aggregation"
 	logFileLifeSpanInSec = 86400 // 24h
 	logFileLifeSpanInMB  = 1024  // 1GB
 	configFile           = "./config.toml"
 	envFile              = "./.env"
+	envOverridesPrefix   = "AGG" // e.g. AGG_LISTEN_ADDRESS overrides ListenAddress
+
+	defaultDemoPollingIntervalInSec = 6
 )
 
 // appVersion should be populated at build time using ldflags
@@ -41,11 +51,14 @@ var (
 	proxyHelpTemplate = `NAME:
    {{.Name}} - {{.Usage}}
 USAGE:
-   {{.HelpName}} {{if .VisibleFlags}}[global options]{{end}}
+   {{.HelpName}} {{if .VisibleFlags}}[global options]{{end}} [command [command options]]
    {{if len .Authors}}
 AUTHOR:
    {{range .Authors}}{{ . }}{{end}}
    {{end}}{{if .Commands}}
+COMMANDS:
+   {{range .VisibleCommands}}{{join .Names ", "}}{{"\t"}}{{.Usage}}
+   {{end}}
 GLOBAL OPTIONS:
    {{range .VisibleFlags}}{{.}}
    {{end}}
@@ -54,7 +67,7 @@ VERSION:
    {{end}}
 `
 
-	log = logger.GetOrCreate("proxy")
+	log = logger.GetOrCreate("main")
 
 	// logLevel defines the logger level
 	logLevel = cli.StringFlag{
@@ -65,6 +78,14 @@ VERSION:
 			" log level.",
 		Value: "*:" + logger.LogInfo.String(),
 	}
+	// logFormat defines the format of the logs written to the standard output
+	logFormat = cli.StringFlag{
+		Name: "log-format",
+		Usage: "This flag specifies the `format` of the logs written to the standard output: console or json. The json " +
+			"format writes a JSON object per line, for the log aggregators (Loki, Elasticsearch). The log file keeps " +
+			"the plain format.",
+		Value: commonGo.LogFormatConsole,
+	}
 	// logFile is used when the log output needs to be logged in a file
 	logSaveFile = cli.BoolFlag{
 		Name:  "log-save",
@@ -76,6 +97,56 @@ VERSION:
 		Usage: "This flag specifies the `directory` where the node will store databases and logs.",
 		Value: "",
 	}
+	// demo defines a flag that starts the service in the sandbox mode
+	demo = cli.BoolFlag{
+		Name: "demo",
+		Usage: "Boolean option for starting the service in the demo (sandbox) mode. Synthetic agents, metrics, " +
+			"history and alarms are generated in a separate database. If the .env file is missing, the " +
+			demoUser + "/" + demoPassword + " credentials are used.",
+	}
+
+	// healthCheckURL overrides the health endpoint queried by the healthcheck subcommand
+	healthCheckURL = cli.StringFlag{
+		Name:  "url",
+		Usage: "The health endpoint `URL`. Defaults to the /healthz endpoint of the configured ListenAddress and BasePath.",
+	}
+	// healthCheckTimeout bounds the health endpoint query of the healthcheck subcommand
+	healthCheckTimeout = cli.DurationFlag{
+		Name:  "timeout",
+		Usage: "The `duration` after which the health check fails.",
+		Value: 5 * time.Second,
+	}
+
+	// checkConfigFormat defines the output format of the check-config subcommand
+	checkConfigFormat = cli.StringFlag{
+		Name:  "format",
+		Usage: "The `format` of the diagnostics: text or json.",
+		Value: "text",
+	}
+
+	// seedDB defines the database populated by the seed subcommand
+	seedDB = cli.StringFlag{
+		Name:  "db",
+		Usage: "The database `path`. Defaults to the service database in the working directory.",
+	}
+	// seedAgents defines the number of synthetic agents generated by the seed subcommand
+	seedAgents = cli.IntFlag{
+		Name:  "agents",
+		Usage: "The `number` of synthetic agents, VM1 to VM<number>, the last one being offline.",
+		Value: 3,
+	}
+	// seedPoints defines the length of the metric histories generated by the seed subcommand
+	seedPoints = cli.IntFlag{
+		Name:  "points",
+		Usage: "The `number` of values generated for each metric.",
+		Value: 100,
+	}
+	// seedInterval defines the time between the generated values of the seed subcommand
+	seedInterval = cli.DurationFlag{
+		Name:  "interval",
+		Usage: "The `duration` between the generated values, as if the agents reported at this interval.",
+		Value: defaultDemoPollingIntervalInSec * time.Second,
+	}
 
 	envFileContents = map[string]*commonGo.EnvValue{
 		common.EnvServiceKey:       {Value: "", Required: true},
@@ -90,6 +161,12 @@ VERSION:
 		common.EnvSMTPHost:         {Value: "", Required: false},
 		common.EnvTelegramBotToken: {Value: "", Required: false},
 		common.EnvTelegramChatId:   {Value: "", Required: false},
+		common.EnvInfluxToken:      {Value: "", Required: false},
+		common.EnvNatsToken:        {Value: "", Required: false},
+		common.EnvPagerDutyKey:     {Value: "", Required: false},
+		common.EnvOpsgenieKey:      {Value: "", Required: false},
+		common.EnvOIDCClientSecret: {Value: "", Required: false},
+		common.EnvFederationAPIKey: {Value: "", Required: false},
 	}
 )
 
@@ -101,8 +178,10 @@ func main() {
 	app.Usage = "This is the entry point for starting a new service for aggregating the data from the connected agents"
 	app.Flags = []cli.Flag{
 		logLevel,
+		logFormat,
 		logSaveFile,
 		workingDirectory,
+		demo,
 	}
 	app.Authors = []cli.Author{
 		{
@@ -112,6 +191,30 @@ func main() {
 	}
 
 	app.Action = run
+	app.Commands = []cli.Command{
+		{
+			Name: "healthcheck",
+			Usage: "Query the local health endpoint and exit with 0 if the service is ready, 1 otherwise, for the " +
+				"Docker HEALTHCHECK directives",
+			Flags:  []cli.Flag{healthCheckURL, healthCheckTimeout},
+			Action: healthCheck,
+		},
+		{
+			Name: "check-config",
+			Usage: "Check the config file, the .env values, the environment overrides and the paths the service writes " +
+				"to, print the diagnostics and exit with 0 if no problem was found, 1 otherwise, for the CI and " +
+				"pre-deploy checks",
+			Flags:  []cli.Flag{checkConfigFormat},
+			Action: checkConfig,
+		},
+		{
+			Name: "seed",
+			Usage: "Populate the database with synthetic agents and metric histories, as generated by the demo mode, " +
+				"for the frontend development and the demos without real agents. Stop the service first.",
+			Flags:  []cli.Flag{seedDB, seedAgents, seedPoints, seedInterval},
+			Action: seed,
+		},
+	}
 
 	defer func() {
 		if fileLogging != nil {
@@ -126,15 +229,108 @@ func main() {
 	}
 }
 
+func healthCheck(ctx *cli.Context) error {
+	url := ctx.String(healthCheckURL.Name)
+	if len(url) == 0 {
+		cfg, err := config.LoadConfig(configFile)
+		if err != nil {
+			return err
+		}
+		_, err = commonGo.ApplyEnvOverrides(cfg, envOverridesPrefix, os.LookupEnv)
+		if err != nil {
+			return err
+		}
+
+		url, err = commonGo.LocalHealthCheckURL(cfg.ListenAddress, cfg.BasePath)
+		if err != nil {
+			return err
+		}
+	}
+
+	return commonGo.CheckHealth(url, ctx.Duration(healthCheckTimeout.Name))
+}
+
+func checkConfig(ctx *cli.Context) error {
+	format := ctx.String(checkConfigFormat.Name)
+	if format != "text" && format != "json" {
+		return fmt.Errorf("unsupported format %s", format)
+	}
+
+	diagnostics := config.Check(config.ArgsCheck{
+		ConfigFile:         configFile,
+		EnvFile:            envFile,
+		EnvValues:          envFileContents,
+		EnvOverridesPrefix: envOverridesPrefix,
+		DBPath:             path.Join(ctx.GlobalString(workingDirectory.Name), defaultDataPath, dbFile),
+		LookupEnv:          os.LookupEnv,
+	})
+
+	numFailed := 0
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Status == config.DiagnosticFail {
+			numFailed++
+		}
+		if format == "text" {
+			fmt.Printf("%-4s %s: %s\n", strings.ToUpper(diagnostic.Status), diagnostic.Check, diagnostic.Message)
+		}
+	}
+	if format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err := encoder.Encode(struct {
+			Diagnostics []config.Diagnostic `json:"diagnostics"`
+			NumFailed   int                 `json:"numFailed"`
+		}{
+			Diagnostics: diagnostics,
+			NumFailed:   numFailed,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if numFailed > 0 && format == "json" {
+		// the exit code is enough, a logged error would break the JSON output
+		return cli.NewExitError("", 1)
+	}
+	if numFailed > 0 {
+		return fmt.Errorf("%d check(s) failed", numFailed)
+	}
+
+	return nil
+}
+
+func seed(ctx *cli.Context) error {
+	sqlitePath := ctx.String(seedDB.Name)
+	if len(sqlitePath) == 0 {
+		sqlitePath = path.Join(ctx.GlobalString(workingDirectory.Name), defaultDataPath, dbFile)
+	}
+
+	log.Info("seeding the database", "path", sqlitePath)
+
+	return factory.SeedDatabase(factory.ArgsSeedDatabase{
+		SQLitePath:       sqlitePath,
+		Interval:         ctx.Duration(seedInterval.Name),
+		NumAgents:        ctx.Int(seedAgents.Name),
+		NumHistoryPoints: ctx.Int(seedPoints.Name),
+	})
+}
+
 func run(ctx *cli.Context) error {
 	saveLogFile := ctx.GlobalBool(logSaveFile.Name)
 	workingDir := ctx.GlobalString(workingDirectory.Name)
+	demoMode := ctx.GlobalBool(demo.Name)
 
 	err := logger.SetLogLevel(ctx.GlobalString(logLevel.Name))
 	if err != nil {
 		return err
 	}
 
+	err = commonGo.SetLogFormat(ctx.GlobalString(logFormat.Name), logServiceName)
+	if err != nil {
+		return err
+	}
+
 	fileLogging, err = commonGo.AttachFileLogger(log, defaultLogsPath, logFilePrefix, saveLogFile, workingDir)
 	if err != nil {
 		return err
@@ -151,17 +347,47 @@ func run(ctx *cli.Context) error {
 
 	log.Info("Starting aggregation service", "version", appVersion, "pid", os.Getpid())
 
-	err = commonGo.ReadEnvFile(envFile, envFileContents)
+	errEnvFile := commonGo.ReadEnvFile(envFile, envFileContents)
+
+	cfg, err := config.LoadConfig(configFile)
 	if err != nil {
 		return err
 	}
 
-	cfg, err := config.LoadConfig(configFile)
+	overrides, err := commonGo.ApplyEnvOverrides(cfg, envOverridesPrefix, os.LookupEnv)
 	if err != nil {
 		return err
 	}
+	if len(overrides) > 0 {
+		log.Info("config values overridden from the environment", "variables", strings.Join(overrides, ", "))
+	}
 
+	// the demo mode, enabled by the flag or by the config, never writes its synthetic data to the service database
 	sqlitePath := path.Join(workingDir, defaultDataPath, dbFile)
+	if demoMode {
+		cfg.Demo.Enabled = true
+		if cfg.Demo.PollingIntervalInSec == 0 {
+			cfg.Demo.PollingIntervalInSec = defaultDemoPollingIntervalInSec
+		}
+	}
+	if cfg.Demo.Enabled {
+		sqlitePath = path.Join(workingDir, defaultDataPath, demoDBFile)
+	}
+
+	if errEnvFile != nil {
+		if !cfg.Demo.Enabled {
+			return errEnvFile
+		}
+
+		log.Warn("could not read the .env file, using the demo credentials", "error", errEnvFile,
+			"user", demoUser, "password", demoPassword)
+		setDemoEnvValues()
+	}
+
+	err = cfg.Validate()
+	if err != nil {
+		return err
+	}
 
 	components, err := factory.NewComponentsHandler(
 		sqlitePath,
@@ -189,3 +415,14 @@ func run(ctx *cli.Context) error {
 
 	return nil
 }
+
+func setDemoEnvValues() {
+	for _, envValue := range envFileContents {
+		envValue.Value = ""
+	}
+
+	envFileContents[common.EnvServiceKey].Value = demoServiceKey
+	envFileContents[common.EnvAuthUser].Value = demoUser
+	envFileContents[common.EnvAuthPassword].Value = demoPassword
+	envFileContents[common.EnvSMTPPort].Value = "0"
+}
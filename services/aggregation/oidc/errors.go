@@ -0,0 +1,17 @@
+package oidc
+
+import "errors"
+
+var (
+	errInvalidIssuerURL     = errors.New("invalid issuer URL")
+	errEmptyClientID        = errors.New("empty client ID")
+	errEmptyClientSecret    = errors.New("empty client secret")
+	errInvalidRedirectURL   = errors.New("invalid redirect URL")
+	errNoGroupMappings      = errors.New("no group mappings")
+	errInvalidRole          = errors.New("invalid role")
+	errNilTimeFunc          = errors.New("nil pointer for the current time function")
+	errInvalidIDToken       = errors.New("invalid ID token")
+	errUnsupportedAlgorithm = errors.New("unsupported ID token signing algorithm")
+	errUnknownSigningKey    = errors.New("unknown ID token signing key")
+	errNoMatchingGroup      = errors.New("the user is not a member of any mapped group")
+)
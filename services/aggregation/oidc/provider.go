@@ -0,0 +1,444 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/commonGo"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	logger "github.com/multiversx/mx-chain-logger-go"
+)
+
+var log = logger.GetOrCreate("oidc")
+
+const (
+	discoveryPath        = "/.well-known/openid-configuration"
+	defaultUsernameClaim = "email"
+	defaultGroupsClaim   = "groups"
+	defaultTimeout       = 10 * time.Second
+	// allowedClockSkew tolerates the small clock differences between the identity provider and this service
+	allowedClockSkew = time.Minute
+	// maxResponseSize bounds the identity provider responses
+	maxResponseSize = 1 << 20
+	// anyGroup maps all the authenticated users, regardless of their groups
+	anyGroup = "*"
+)
+
+var defaultScopes = []string{"openid", "email", "profile"}
+
+// GroupMapping grants a role, within a tenant, to the members of an identity provider group
+type GroupMapping struct {
+	Group  string
+	Role   string
+	Tenant string
+}
+
+// ArgsProvider represents the DTO used in the NewProvider constructor function
+type ArgsProvider struct {
+	IssuerURL     string
+	ClientID      string
+	ClientSecret  string
+	RedirectURL   string
+	Scopes        []string
+	UsernameClaim string
+	GroupsClaim   string
+	GroupMappings []GroupMapping
+	Timeout       time.Duration
+	TimeFunc      func() time.Time
+}
+
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type jsonWebKey struct {
+	KeyID     string `json:"kid"`
+	KeyType   string `json:"kty"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	Modulus   string `json:"n"`
+	Exponent  string `json:"e"`
+}
+
+type provider struct {
+	issuerURL     string
+	clientID      string
+	clientSecret  string
+	redirectURL   string
+	scopes        []string
+	usernameClaim string
+	groupsClaim   string
+	groupMappings []GroupMapping
+	timeFunc      func() time.Time
+	httpClient    *http.Client
+
+	mut       sync.Mutex
+	discovery *discoveryDocument
+	keys      map[string]*rsa.PublicKey
+}
+
+// NewProvider creates an OpenID Connect relying party that logs in the dashboard users through the authorization
+// code flow. The provider metadata is discovered, and cached, on the first login.
+func NewProvider(args ArgsProvider) (*provider, error) {
+	if !commonGo.IsHTTPURL(args.IssuerURL) {
+		return nil, fmt.Errorf("%w %q", errInvalidIssuerURL, args.IssuerURL)
+	}
+	if len(args.ClientID) == 0 {
+		return nil, errEmptyClientID
+	}
+	if len(args.ClientSecret) == 0 {
+		return nil, errEmptyClientSecret
+	}
+	if !commonGo.IsHTTPURL(args.RedirectURL) {
+		return nil, fmt.Errorf("%w %q", errInvalidRedirectURL, args.RedirectURL)
+	}
+	if len(args.GroupMappings) == 0 {
+		return nil, errNoGroupMappings
+	}
+	for _, mapping := range args.GroupMappings {
+		if mapping.Role != common.RoleAdmin && mapping.Role != common.RoleViewer {
+			return nil, fmt.Errorf("%w %q for group %q", errInvalidRole, mapping.Role, mapping.Group)
+		}
+	}
+	if args.TimeFunc == nil {
+		return nil, errNilTimeFunc
+	}
+
+	p := &provider{
+		issuerURL:     strings.TrimSuffix(args.IssuerURL, "/"),
+		clientID:      args.ClientID,
+		clientSecret:  args.ClientSecret,
+		redirectURL:   args.RedirectURL,
+		scopes:        args.Scopes,
+		usernameClaim: args.UsernameClaim,
+		groupsClaim:   args.GroupsClaim,
+		groupMappings: args.GroupMappings,
+		timeFunc:      args.TimeFunc,
+		httpClient:    &http.Client{Timeout: args.Timeout},
+	}
+	if len(p.scopes) == 0 {
+		p.scopes = defaultScopes
+	}
+	if len(p.usernameClaim) == 0 {
+		p.usernameClaim = defaultUsernameClaim
+	}
+	if len(p.groupsClaim) == 0 {
+		p.groupsClaim = defaultGroupsClaim
+	}
+	if args.Timeout <= 0 {
+		p.httpClient.Timeout = defaultTimeout
+	}
+
+	return p, nil
+}
+
+// AuthCodeURL returns the identity provider URL the user is redirected to for logging in
+func (p *provider) AuthCodeURL(ctx context.Context, state string, nonce string) (string, error) {
+	discovery, err := p.getDiscovery(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	values := url.Values{}
+	values.Set("response_type", "code")
+	values.Set("client_id", p.clientID)
+	values.Set("redirect_uri", p.redirectURL)
+	values.Set("scope", strings.Join(p.scopes, " "))
+	values.Set("state", state)
+	values.Set("nonce", nonce)
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return discovery.AuthorizationEndpoint + separator + values.Encode(), nil
+}
+
+// Exchange redeems the authorization code, verifies the returned ID token and maps the user groups to a role
+func (p *provider) Exchange(ctx context.Context, code string, nonce string) (*common.UserIdentity, error) {
+	discovery, err := p.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	values := url.Values{}
+	values.Set("grant_type", "authorization_code")
+	values.Set("code", code)
+	values.Set("redirect_uri", p.redirectURL)
+	values.Set("client_id", p.clientID)
+	values.Set("client_secret", p.clientSecret)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(values.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var tokenResponse struct {
+		IDToken string `json:"id_token"`
+	}
+	err = p.doJSONRequest(req, &tokenResponse)
+	if err != nil {
+		return nil, fmt.Errorf("token exchange failed: %w", err)
+	}
+	if len(tokenResponse.IDToken) == 0 {
+		return nil, fmt.Errorf("%w: missing from the token response", errInvalidIDToken)
+	}
+
+	claims, err := p.verifyIDToken(ctx, tokenResponse.IDToken, discovery.Issuer, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.mapIdentity(claims)
+}
+
+func (p *provider) verifyIDToken(ctx context.Context, idToken string, issuer string, nonce string) (map[string]interface{}, error) {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed", errInvalidIDToken)
+	}
+
+	var header struct {
+		Algorithm string `json:"alg"`
+		KeyID     string `json:"kid"`
+	}
+	err := decodeSegment(parts[0], &header)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidIDToken, err)
+	}
+	if header.Algorithm != "RS256" {
+		return nil, fmt.Errorf("%w %q", errUnsupportedAlgorithm, header.Algorithm)
+	}
+
+	key, err := p.getSigningKey(ctx, header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidIDToken, err)
+	}
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	err = rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], signature)
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad signature", errInvalidIDToken)
+	}
+
+	claims := make(map[string]interface{})
+	err = decodeSegment(parts[1], &claims)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidIDToken, err)
+	}
+
+	if claims["iss"] != issuer {
+		return nil, fmt.Errorf("%w: unexpected issuer %v", errInvalidIDToken, claims["iss"])
+	}
+	if !containsAudience(claims["aud"], p.clientID) {
+		return nil, fmt.Errorf("%w: not issued for this client", errInvalidIDToken)
+	}
+	exp, _ := claims["exp"].(float64)
+	if p.timeFunc().Add(-allowedClockSkew).Unix() > int64(exp) {
+		return nil, fmt.Errorf("%w: expired", errInvalidIDToken)
+	}
+	if claims["nonce"] != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", errInvalidIDToken)
+	}
+
+	return claims, nil
+}
+
+func containsAudience(audience interface{}, clientID string) bool {
+	switch value := audience.(type) {
+	case string:
+		return value == clientID
+	case []interface{}:
+		for _, item := range value {
+			if item == clientID {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// mapIdentity grants the role of the first group mapping matching the user groups
+func (p *provider) mapIdentity(claims map[string]interface{}) (*common.UserIdentity, error) {
+	username, _ := claims[p.usernameClaim].(string)
+	if len(username) == 0 {
+		username, _ = claims["sub"].(string)
+	}
+
+	groups := make(map[string]struct{})
+	switch value := claims[p.groupsClaim].(type) {
+	case string:
+		groups[value] = struct{}{}
+	case []interface{}:
+		for _, item := range value {
+			group, ok := item.(string)
+			if ok {
+				groups[group] = struct{}{}
+			}
+		}
+	}
+
+	for _, mapping := range p.groupMappings {
+		_, isMember := groups[mapping.Group]
+		if isMember || mapping.Group == anyGroup {
+			return &common.UserIdentity{
+				Username: username,
+				Role:     mapping.Role,
+				Tenant:   mapping.Tenant,
+			}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w, user %s", errNoMatchingGroup, username)
+}
+
+func (p *provider) getDiscovery(ctx context.Context) (*discoveryDocument, error) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.issuerURL+discoveryPath, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	discovery := &discoveryDocument{}
+	err = p.doJSONRequest(req, discovery)
+	if err != nil {
+		return nil, fmt.Errorf("provider discovery failed: %w", err)
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != p.issuerURL {
+		return nil, fmt.Errorf("provider discovery failed: issuer %q does not match %q", discovery.Issuer, p.issuerURL)
+	}
+	if len(discovery.AuthorizationEndpoint) == 0 || len(discovery.TokenEndpoint) == 0 || len(discovery.JWKSURI) == 0 {
+		return nil, fmt.Errorf("provider discovery failed: missing endpoints")
+	}
+
+	log.Debug("discovered the OIDC provider", "issuer", discovery.Issuer)
+	p.discovery = discovery
+	return discovery, nil
+}
+
+// getSigningKey returns the provider key with the provided ID, refreshing the key set if the key is not known
+// (e.g. after a key rotation)
+func (p *provider) getSigningKey(ctx context.Context, keyID string) (*rsa.PublicKey, error) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	key, found := p.keys[keyID]
+	if found {
+		return key, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.discovery.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var keySet struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	err = p.doJSONRequest(req, &keySet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the provider keys: %w", err)
+	}
+
+	p.keys = make(map[string]*rsa.PublicKey, len(keySet.Keys))
+	for _, jwk := range keySet.Keys {
+		if jwk.KeyType != "RSA" || (len(jwk.Use) > 0 && jwk.Use != "sig") {
+			continue
+		}
+
+		publicKey, errParse := parseRSAKey(jwk)
+		if errParse != nil {
+			log.Warn("skipping invalid provider key", "kid", jwk.KeyID, "error", errParse)
+			continue
+		}
+		p.keys[jwk.KeyID] = publicKey
+	}
+
+	key, found = p.keys[keyID]
+	if !found {
+		return nil, fmt.Errorf("%w %q", errUnknownSigningKey, keyID)
+	}
+
+	return key, nil
+}
+
+func parseRSAKey(jwk jsonWebKey) (*rsa.PublicKey, error) {
+	modulus, err := base64.RawURLEncoding.DecodeString(jwk.Modulus)
+	if err != nil {
+		return nil, err
+	}
+	exponent, err := base64.RawURLEncoding.DecodeString(jwk.Exponent)
+	if err != nil {
+		return nil, err
+	}
+	if len(modulus) == 0 || len(exponent) == 0 || len(exponent) > 4 {
+		return nil, fmt.Errorf("invalid modulus or exponent")
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(modulus),
+		E: int(new(big.Int).SetBytes(exponent).Int64()),
+	}, nil
+}
+
+func (p *provider) doJSONRequest(req *http.Request, result interface{}) error {
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return json.Unmarshal(body, result)
+}
+
+func decodeSegment(segment string, result interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, result)
+}
+
+// IsInterfaceNil returns true if there is no value under the interface
+func (p *provider) IsInterfaceNil() bool {
+	return p == nil
+}
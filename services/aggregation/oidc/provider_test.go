@@ -0,0 +1,268 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testNow = time.Unix(1700000000, 0)
+
+type testIdentityProvider struct {
+	*httptest.Server
+	key        *rsa.PrivateKey
+	keyID      string
+	idToken    string
+	tokenForms []url.Values
+	numKeySets int
+}
+
+func newTestIdentityProvider(t *testing.T) *testIdentityProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	idp := &testIdentityProvider{
+		key:   key,
+		keyID: "key-1",
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(discoveryPath, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(discoveryDocument{
+			Issuer:                idp.URL,
+			AuthorizationEndpoint: idp.URL + "/authorize",
+			TokenEndpoint:         idp.URL + "/token",
+			JWKSURI:               idp.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		idp.numKeySets++
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []jsonWebKey{{
+				KeyID:     idp.keyID,
+				KeyType:   "RSA",
+				Use:       "sig",
+				Modulus:   base64.RawURLEncoding.EncodeToString(idp.key.N.Bytes()),
+				Exponent:  base64.RawURLEncoding.EncodeToString(big.NewInt(int64(idp.key.E)).Bytes()),
+				Algorithm: "RS256",
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		idp.tokenForms = append(idp.tokenForms, r.PostForm)
+		if r.PostForm.Get("code") != "valid-code" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"id_token": idp.idToken})
+	})
+	idp.Server = httptest.NewServer(mux)
+
+	return idp
+}
+
+func (idp *testIdentityProvider) sign(t *testing.T, keyID string, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": keyID})
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+
+	message := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	hash := sha256.Sum256([]byte(message))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, idp.key, crypto.SHA256, hash[:])
+	require.NoError(t, err)
+
+	return message + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (idp *testIdentityProvider) claims() map[string]interface{} {
+	return map[string]interface{}{
+		"iss":    idp.URL,
+		"aud":    []string{"monitoring"},
+		"sub":    "12345",
+		"email":  "jane@example.com",
+		"groups": []string{"staff", "sre"},
+		"nonce":  "nonce-1",
+		"exp":    testNow.Add(time.Hour).Unix(),
+	}
+}
+
+func createMockArgs(issuerURL string) ArgsProvider {
+	return ArgsProvider{
+		IssuerURL:    issuerURL,
+		ClientID:     "monitoring",
+		ClientSecret: "secret",
+		RedirectURL:  "https://monitoring.example.com/api/auth/oidc/callback",
+		GroupMappings: []GroupMapping{
+			{Group: "sre", Role: common.RoleAdmin},
+			{Group: "acme-staff", Role: common.RoleViewer, Tenant: "acme"},
+		},
+		TimeFunc: func() time.Time {
+			return testNow
+		},
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	t.Parallel()
+
+	t.Run("invalid issuer URL should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgs("accounts.example.com")
+		p, err := NewProvider(args)
+		assert.Nil(t, p)
+		assert.True(t, errors.Is(err, errInvalidIssuerURL))
+	})
+	t.Run("empty client secret should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgs("https://accounts.example.com")
+		args.ClientSecret = ""
+		p, err := NewProvider(args)
+		assert.Nil(t, p)
+		assert.Equal(t, errEmptyClientSecret, err)
+	})
+	t.Run("invalid role should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgs("https://accounts.example.com")
+		args.GroupMappings[1].Role = "owner"
+		p, err := NewProvider(args)
+		assert.Nil(t, p)
+		assert.True(t, errors.Is(err, errInvalidRole))
+	})
+	t.Run("no group mappings should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgs("https://accounts.example.com")
+		args.GroupMappings = nil
+		p, err := NewProvider(args)
+		assert.Nil(t, p)
+		assert.Equal(t, errNoGroupMappings, err)
+	})
+	t.Run("should work", func(t *testing.T) {
+		t.Parallel()
+
+		p, err := NewProvider(createMockArgs("https://accounts.example.com/"))
+		assert.Nil(t, err)
+		assert.False(t, p.IsInterfaceNil())
+		assert.Equal(t, "https://accounts.example.com", p.issuerURL)
+		assert.Equal(t, defaultScopes, p.scopes)
+	})
+}
+
+func TestProvider_AuthCodeURL(t *testing.T) {
+	t.Parallel()
+
+	idp := newTestIdentityProvider(t)
+	defer idp.Close()
+
+	p, err := NewProvider(createMockArgs(idp.URL))
+	require.NoError(t, err)
+
+	authURL, err := p.AuthCodeURL(context.Background(), "state-1", "nonce-1")
+	require.NoError(t, err)
+	parsed, err := url.Parse(authURL)
+	require.NoError(t, err)
+	assert.Equal(t, idp.URL+"/authorize", parsed.Scheme+"://"+parsed.Host+parsed.Path)
+	assert.Equal(t, "code", parsed.Query().Get("response_type"))
+	assert.Equal(t, "monitoring", parsed.Query().Get("client_id"))
+	assert.Equal(t, "openid email profile", parsed.Query().Get("scope"))
+	assert.Equal(t, "state-1", parsed.Query().Get("state"))
+	assert.Equal(t, "nonce-1", parsed.Query().Get("nonce"))
+}
+
+func TestProvider_Exchange(t *testing.T) {
+	t.Parallel()
+
+	idp := newTestIdentityProvider(t)
+	defer idp.Close()
+
+	p, err := NewProvider(createMockArgs(idp.URL))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	t.Run("valid token should map the first matching group", func(t *testing.T) {
+		idp.idToken = idp.sign(t, idp.keyID, idp.claims())
+
+		identity, errExchange := p.Exchange(ctx, "valid-code", "nonce-1")
+		require.NoError(t, errExchange)
+		assert.Equal(t, &common.UserIdentity{Username: "jane@example.com", Role: common.RoleAdmin}, identity)
+
+		form := idp.tokenForms[len(idp.tokenForms)-1]
+		assert.Equal(t, "authorization_code", form.Get("grant_type"))
+		assert.Equal(t, "secret", form.Get("client_secret"))
+	})
+	t.Run("tenant group should map the tenant", func(t *testing.T) {
+		claims := idp.claims()
+		claims["groups"] = "acme-staff"
+		delete(claims, "email")
+		idp.idToken = idp.sign(t, idp.keyID, claims)
+
+		identity, errExchange := p.Exchange(ctx, "valid-code", "nonce-1")
+		require.NoError(t, errExchange)
+		assert.Equal(t, &common.UserIdentity{Username: "12345", Role: common.RoleViewer, Tenant: "acme"}, identity)
+	})
+	t.Run("invalid code should error", func(t *testing.T) {
+		_, errExchange := p.Exchange(ctx, "invalid-code", "nonce-1")
+		assert.ErrorContains(t, errExchange, "invalid_grant")
+	})
+	t.Run("invalid tokens should error", func(t *testing.T) {
+		claims := idp.claims()
+		claims["groups"] = []string{"staff"}
+		idp.idToken = idp.sign(t, idp.keyID, claims)
+		_, errExchange := p.Exchange(ctx, "valid-code", "nonce-1")
+		assert.True(t, errors.Is(errExchange, errNoMatchingGroup))
+
+		_, errExchange = p.Exchange(ctx, "valid-code", "nonce-2")
+		assert.ErrorContains(t, errExchange, "nonce mismatch")
+
+		claims = idp.claims()
+		claims["aud"] = "other-client"
+		idp.idToken = idp.sign(t, idp.keyID, claims)
+		_, errExchange = p.Exchange(ctx, "valid-code", "nonce-1")
+		assert.ErrorContains(t, errExchange, "not issued for this client")
+
+		claims = idp.claims()
+		claims["exp"] = testNow.Add(-time.Hour).Unix()
+		idp.idToken = idp.sign(t, idp.keyID, claims)
+		_, errExchange = p.Exchange(ctx, "valid-code", "nonce-1")
+		assert.ErrorContains(t, errExchange, "expired")
+
+		claims = idp.claims()
+		claims["iss"] = "https://evil.example.com"
+		idp.idToken = idp.sign(t, idp.keyID, claims)
+		_, errExchange = p.Exchange(ctx, "valid-code", "nonce-1")
+		assert.ErrorContains(t, errExchange, "unexpected issuer")
+
+		token := idp.sign(t, idp.keyID, idp.claims())
+		parts := strings.Split(token, ".")
+		tampered, _ := json.Marshal(map[string]interface{}{"iss": idp.URL, "aud": "monitoring", "email": "admin@example.com"})
+		idp.idToken = parts[0] + "." + base64.RawURLEncoding.EncodeToString(tampered) + "." + parts[2]
+		_, errExchange = p.Exchange(ctx, "valid-code", "nonce-1")
+		assert.ErrorContains(t, errExchange, "bad signature")
+
+		numKeySets := idp.numKeySets
+		idp.idToken = idp.sign(t, "key-2", idp.claims())
+		_, errExchange = p.Exchange(ctx, "valid-code", "nonce-1")
+		assert.True(t, errors.Is(errExchange, errUnknownSigningKey))
+		assert.Equal(t, numKeySets+1, idp.numKeySets)
+	})
+}
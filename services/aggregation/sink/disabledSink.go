@@ -0,0 +1,25 @@
+package sink
+
+import "github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+
+type disabledSink struct {
+}
+
+// NewDisabledSink creates a sink that does nothing
+func NewDisabledSink() *disabledSink {
+	return &disabledSink{}
+}
+
+// Forward does nothing
+func (sink *disabledSink) Forward(_ []common.MetricRecord) {
+}
+
+// Close does nothing and returns nil
+func (sink *disabledSink) Close() error {
+	return nil
+}
+
+// IsInterfaceNil returns true if there is no value under the interface
+func (sink *disabledSink) IsInterfaceNil() bool {
+	return sink == nil
+}
@@ -0,0 +1,15 @@
+package sink
+
+import "errors"
+
+var (
+	errEmptyURL            = errors.New("empty sink URL")
+	errEmptyTarget         = errors.New("empty bucket and database, one of them is required")
+	errUnexpectedStatus    = errors.New("unexpected status code")
+	errInvalidQueueSetting = errors.New("invalid queue setting")
+	errInvalidURL          = errors.New("invalid URL")
+	errEmptySubject        = errors.New("empty subject")
+	errInvalidSubject      = errors.New("invalid subject, it can not contain whitespaces or wildcards")
+	errServerError         = errors.New("NATS server error")
+	errEmptyPrefix         = errors.New("empty federation prefix")
+)
@@ -0,0 +1,311 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/commonGo"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+)
+
+const (
+	defaultFederationFlushInterval = 10 * time.Second
+	defaultFederationCapacity      = 100000
+	federationReportPath           = "/api/report"
+	federationPrefixSeparator      = "."
+)
+
+// ArgsFederationForwarder defines the DTO struct for the NewFederationForwarder constructor function
+type ArgsFederationForwarder struct {
+	// ParentURL is the base URL of the parent aggregation service, e.g. https://global.example.com
+	ParentURL string
+	// APIKey is the service API key (or a tenant API key) of the parent aggregation service
+	APIKey string
+	// Prefix is prepended to the forwarded metric names, e.g. DC1 => DC1.VM1.Node1.nonce
+	Prefix        string
+	QueueCapacity int
+	FlushInterval time.Duration
+	Timeout       time.Duration
+}
+
+// federatedMetric is the value of a metric in the report sent to the parent, the parent /api/report format
+type federatedMetric struct {
+	Value          string            `json:"value"`
+	Type           string            `json:"type"`
+	NumAggregation int               `json:"numAggregation"`
+	Tags           map[string]string `json:"tags,omitempty"`
+	Interval       int               `json:"interval,omitempty"`
+}
+
+type federatedReport struct {
+	Metrics map[string]federatedMetric `json:"metrics"`
+}
+
+// federationForwarder rolls up the metrics of this instance into a parent aggregation service, reporting them as an
+// agent would, under a prefix. Only the latest value of each metric is kept between two flushes, so the pending
+// values are bounded by the number of distinct metrics; the values that could not be sent are retried with the next
+// flush unless a newer value arrived meanwhile. The metrics of the tenants are not forwarded.
+type federationForwarder struct {
+	reportURL     string
+	apiKey        string
+	prefix        string
+	capacity      int
+	flushInterval time.Duration
+	client        *http.Client
+	mutPending    sync.Mutex
+	pending       map[string]federatedMetric
+	cancel        context.CancelFunc
+	wg            sync.WaitGroup
+	mutStats      sync.Mutex
+	numSent       uint64
+	numDropped    uint64
+}
+
+// NewFederationForwarder creates a new federation forwarder and starts its background sender
+func NewFederationForwarder(args ArgsFederationForwarder) (*federationForwarder, error) {
+	reportURL, err := createReportURL(args.ParentURL)
+	if err != nil {
+		return nil, err
+	}
+	prefix := strings.Trim(strings.TrimSpace(args.Prefix), federationPrefixSeparator)
+	if len(prefix) == 0 {
+		return nil, errEmptyPrefix
+	}
+	if args.QueueCapacity < 0 {
+		return nil, errInvalidQueueSetting
+	}
+
+	forwarder := &federationForwarder{
+		reportURL:     reportURL,
+		apiKey:        args.APIKey,
+		prefix:        prefix,
+		capacity:      args.QueueCapacity,
+		flushInterval: args.FlushInterval,
+		client:        &http.Client{Timeout: args.Timeout},
+		pending:       make(map[string]federatedMetric),
+	}
+	if forwarder.capacity == 0 {
+		forwarder.capacity = defaultFederationCapacity
+	}
+	if forwarder.flushInterval <= 0 {
+		forwarder.flushInterval = defaultFederationFlushInterval
+	}
+	if forwarder.client.Timeout <= 0 {
+		forwarder.client.Timeout = defaultTimeout
+	}
+
+	var ctx context.Context
+	ctx, forwarder.cancel = context.WithCancel(context.Background())
+	forwarder.wg.Add(1)
+	go forwarder.processLoop(ctx)
+
+	return forwarder, nil
+}
+
+func createReportURL(parentURL string) (string, error) {
+	baseURL := strings.TrimSuffix(strings.TrimSpace(parentURL), "/")
+	if len(baseURL) == 0 {
+		return "", errEmptyURL
+	}
+
+	parsed, err := url.Parse(baseURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || len(parsed.Host) == 0 {
+		return "", fmt.Errorf("%w: %s", errInvalidURL, parentURL)
+	}
+
+	return baseURL + federationReportPath, nil
+}
+
+// Forward stores the latest values of the records, under the prefixed names, until the next flush
+func (forwarder *federationForwarder) Forward(records []common.MetricRecord) {
+	numDropped := uint64(0)
+
+	forwarder.mutPending.Lock()
+	for _, record := range records {
+		if len(record.Tenant) > 0 {
+			continue
+		}
+
+		name := forwarder.prefix + federationPrefixSeparator + record.Name
+		_, exists := forwarder.pending[name]
+		if !exists && len(forwarder.pending) >= forwarder.capacity {
+			numDropped++
+			continue
+		}
+		forwarder.pending[name] = forwarder.createFederatedMetric(record)
+	}
+	forwarder.mutPending.Unlock()
+
+	if numDropped > 0 {
+		forwarder.addStats(0, numDropped)
+		log.Debug("federation forwarder is full, dropped metric values", "num dropped", numDropped)
+	}
+}
+
+// createFederatedMetric moves the vm tag under the prefix, so the panels of the parent dashboard stay distinct
+// between the children, and marks the values with the source tag
+func (forwarder *federationForwarder) createFederatedMetric(record common.MetricRecord) federatedMetric {
+	tags := make(map[string]string, len(record.Tags)+1)
+	for key, value := range record.Tags {
+		tags[key] = value
+	}
+	if vm, found := tags[common.TagVM]; found {
+		tags[common.TagVM] = forwarder.prefix + federationPrefixSeparator + vm
+	}
+	tags[common.TagSource] = forwarder.prefix
+
+	return federatedMetric{
+		Value:          record.Value,
+		Type:           record.Type,
+		NumAggregation: record.NumAggregation,
+		Tags:           tags,
+		Interval:       record.ExpectedInterval,
+	}
+}
+
+func (forwarder *federationForwarder) processLoop(ctx context.Context) {
+	defer forwarder.wg.Done()
+
+	ticker := time.NewTicker(forwarder.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// send what is left before exiting, without retrying
+			batch := forwarder.takePending()
+			if len(batch) > 0 && !forwarder.sendBatch(batch) {
+				forwarder.addStats(0, uint64(len(batch)))
+			}
+			return
+		case <-ticker.C:
+			forwarder.flush()
+		}
+	}
+}
+
+func (forwarder *federationForwarder) flush() {
+	batch := forwarder.takePending()
+	if len(batch) == 0 {
+		return
+	}
+
+	if !forwarder.sendBatch(batch) {
+		forwarder.restorePending(batch)
+	}
+}
+
+func (forwarder *federationForwarder) takePending() map[string]federatedMetric {
+	forwarder.mutPending.Lock()
+	defer forwarder.mutPending.Unlock()
+
+	batch := forwarder.pending
+	forwarder.pending = make(map[string]federatedMetric, len(batch))
+
+	return batch
+}
+
+// restorePending puts back the values that could not be sent, unless newer values arrived meanwhile
+func (forwarder *federationForwarder) restorePending(batch map[string]federatedMetric) {
+	numDropped := uint64(0)
+
+	forwarder.mutPending.Lock()
+	for name, metric := range batch {
+		_, hasNewer := forwarder.pending[name]
+		if hasNewer {
+			continue
+		}
+		if len(forwarder.pending) >= forwarder.capacity {
+			numDropped++
+			continue
+		}
+		forwarder.pending[name] = metric
+	}
+	forwarder.mutPending.Unlock()
+
+	forwarder.addStats(0, numDropped)
+}
+
+func (forwarder *federationForwarder) sendBatch(batch map[string]federatedMetric) bool {
+	err := forwarder.send(batch)
+	if err != nil {
+		log.Warn("failed to forward the metric values to the parent aggregation service",
+			"URL", forwarder.reportURL, "num values", len(batch), "error", err)
+		return false
+	}
+
+	forwarder.addStats(uint64(len(batch)), 0)
+	return true
+}
+
+func (forwarder *federationForwarder) send(batch map[string]federatedMetric) error {
+	body, err := json.Marshal(federatedReport{Metrics: batch})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), forwarder.client.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, forwarder.reportURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	requestID := commonGo.NewRequestID()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Api-Key", forwarder.apiKey)
+	req.Header.Set(commonGo.RequestIDHeader, requestID)
+
+	resp, err := forwarder.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w, request ID %s", err, requestID)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if !common.IsHttpStatusCodeSuccess(resp.StatusCode) {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%w %d: %s, request ID %s", errUnexpectedStatus, resp.StatusCode,
+			strings.TrimSpace(string(respBody)), requestID)
+	}
+
+	return nil
+}
+
+func (forwarder *federationForwarder) addStats(numSent uint64, numDropped uint64) {
+	forwarder.mutStats.Lock()
+	defer forwarder.mutStats.Unlock()
+
+	forwarder.numSent += numSent
+	forwarder.numDropped += numDropped
+}
+
+// Stats returns the number of values forwarded and the number of values dropped
+func (forwarder *federationForwarder) Stats() (uint64, uint64) {
+	forwarder.mutStats.Lock()
+	defer forwarder.mutStats.Unlock()
+
+	return forwarder.numSent, forwarder.numDropped
+}
+
+// Close sends the pending values and stops the background sender
+func (forwarder *federationForwarder) Close() error {
+	forwarder.cancel()
+	forwarder.wg.Wait()
+
+	return nil
+}
+
+// IsInterfaceNil returns true if there is no value under the interface
+func (forwarder *federationForwarder) IsInterfaceNil() bool {
+	return forwarder == nil
+}
@@ -0,0 +1,174 @@
+package sink
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/commonGo"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createMockFederationArgs(parentURL string) ArgsFederationForwarder {
+	return ArgsFederationForwarder{
+		ParentURL:     parentURL,
+		APIKey:        "parent-key",
+		Prefix:        "DC1",
+		FlushInterval: time.Hour,
+	}
+}
+
+func decodeFederatedReport(t *testing.T, body string) federatedReport {
+	report := federatedReport{}
+	require.NoError(t, json.Unmarshal([]byte(body), &report))
+
+	return report
+}
+
+func TestNewFederationForwarder(t *testing.T) {
+	t.Parallel()
+
+	t.Run("empty URL should error", func(t *testing.T) {
+		t.Parallel()
+
+		forwarder, err := NewFederationForwarder(createMockFederationArgs(""))
+		assert.Nil(t, forwarder)
+		assert.Equal(t, errEmptyURL, err)
+	})
+	t.Run("invalid URL should error", func(t *testing.T) {
+		t.Parallel()
+
+		forwarder, err := NewFederationForwarder(createMockFederationArgs("global.example.com"))
+		assert.Nil(t, forwarder)
+		assert.True(t, errors.Is(err, errInvalidURL))
+	})
+	t.Run("empty prefix should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockFederationArgs("https://global.example.com")
+		args.Prefix = " . "
+		forwarder, err := NewFederationForwarder(args)
+		assert.Nil(t, forwarder)
+		assert.Equal(t, errEmptyPrefix, err)
+	})
+	t.Run("negative capacity should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockFederationArgs("https://global.example.com")
+		args.QueueCapacity = -1
+		forwarder, err := NewFederationForwarder(args)
+		assert.Nil(t, forwarder)
+		assert.Equal(t, errInvalidQueueSetting, err)
+	})
+	t.Run("should work", func(t *testing.T) {
+		t.Parallel()
+
+		forwarder, err := NewFederationForwarder(createMockFederationArgs("https://global.example.com/monitoring/"))
+		require.Nil(t, err)
+		assert.False(t, forwarder.IsInterfaceNil())
+		assert.Equal(t, "https://global.example.com/monitoring/api/report", forwarder.reportURL)
+		assert.Equal(t, defaultFederationCapacity, forwarder.capacity)
+		assert.Nil(t, forwarder.Close())
+	})
+}
+
+func TestFederationForwarder_Forward(t *testing.T) {
+	t.Parallel()
+
+	mock := &influxServerMock{status: http.StatusOK}
+	server := httptest.NewServer(mock)
+	defer server.Close()
+
+	forwarder, err := NewFederationForwarder(createMockFederationArgs(server.URL))
+	require.Nil(t, err)
+
+	forwarder.Forward([]common.MetricRecord{
+		{Name: "VM1.Node1.nonce", Type: common.MetricTypeUint64, NumAggregation: 10, Value: "10",
+			Tags: common.ParseTagsFromName("VM1.Node1.nonce")},
+		{Name: "VM1.Active", Type: common.MetricTypeBool, NumAggregation: 1, Value: "true"},
+		{Name: "Acme.Node1.nonce", Type: common.MetricTypeUint64, NumAggregation: 1, Value: "5", Tenant: "acme"},
+	})
+	// only the latest value is forwarded
+	forwarder.Forward([]common.MetricRecord{
+		{Name: "VM1.Node1.nonce", Type: common.MetricTypeUint64, NumAggregation: 10, Value: "11",
+			Tags: common.ParseTagsFromName("VM1.Node1.nonce")},
+	})
+	forwarder.flush()
+	forwarder.flush()
+
+	bodies := mock.getBodies()
+	require.Len(t, bodies, 1)
+	report := decodeFederatedReport(t, bodies[0])
+	assert.Equal(t, map[string]federatedMetric{
+		"DC1.VM1.Node1.nonce": {
+			Value:          "11",
+			Type:           common.MetricTypeUint64,
+			NumAggregation: 10,
+			Tags:           map[string]string{"vm": "DC1.VM1", "node": "Node1", "kind": "nonce", "source": "DC1"},
+		},
+		"DC1.VM1.Active": {
+			Value:          "true",
+			Type:           common.MetricTypeBool,
+			NumAggregation: 1,
+			Tags:           map[string]string{"source": "DC1"},
+		},
+	}, report.Metrics)
+
+	request := mock.getRequests()[0]
+	assert.Equal(t, "/api/report", request.URL.Path)
+	assert.Equal(t, "parent-key", request.Header.Get("X-Api-Key"))
+	assert.True(t, commonGo.IsValidRequestID(request.Header.Get(commonGo.RequestIDHeader)))
+
+	numSent, numDropped := forwarder.Stats()
+	assert.Equal(t, uint64(2), numSent)
+	assert.Equal(t, uint64(0), numDropped)
+	assert.Nil(t, forwarder.Close())
+}
+
+func TestFederationForwarder_RetryAndCapacity(t *testing.T) {
+	t.Parallel()
+
+	mock := &influxServerMock{status: http.StatusServiceUnavailable}
+	server := httptest.NewServer(mock)
+	defer server.Close()
+
+	args := createMockFederationArgs(server.URL)
+	args.QueueCapacity = 2
+	forwarder, err := NewFederationForwarder(args)
+	require.Nil(t, err)
+
+	forwarder.Forward([]common.MetricRecord{
+		{Name: "VM1.a", Value: "1"},
+		{Name: "VM1.b", Value: "1"},
+		{Name: "VM1.c", Value: "1"},
+	})
+	numSent, numDropped := forwarder.Stats()
+	assert.Equal(t, uint64(0), numSent)
+	assert.Equal(t, uint64(1), numDropped)
+
+	// the failed values are retried, the newer values win
+	forwarder.flush()
+	forwarder.Forward([]common.MetricRecord{{Name: "VM1.a", Value: "2"}})
+
+	mock.mut.Lock()
+	mock.status = http.StatusOK
+	mock.mut.Unlock()
+	forwarder.flush()
+
+	bodies := mock.getBodies()
+	require.Len(t, bodies, 2)
+	report := decodeFederatedReport(t, bodies[1])
+	require.Len(t, report.Metrics, 2)
+	assert.Equal(t, "2", report.Metrics["DC1.VM1.a"].Value)
+	assert.Equal(t, "1", report.Metrics["DC1.VM1.b"].Value)
+
+	numSent, numDropped = forwarder.Stats()
+	assert.Equal(t, uint64(2), numSent)
+	assert.Equal(t, uint64(1), numDropped)
+	assert.Nil(t, forwarder.Close())
+}
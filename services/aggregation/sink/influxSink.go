@@ -0,0 +1,262 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	logger "github.com/multiversx/mx-chain-logger-go"
+)
+
+var log = logger.GetOrCreate("sink")
+
+const (
+	defaultMeasurement   = "metrics"
+	defaultQueueCapacity = 100000
+	defaultBatchSize     = 5000
+	defaultFlushInterval = 5 * time.Second
+	defaultTimeout       = 10 * time.Second
+)
+
+// ArgsInfluxSink defines the DTO struct for the NewInfluxSink constructor function
+type ArgsInfluxSink struct {
+	// URL is the InfluxDB base URL, e.g. http://127.0.0.1:8086
+	URL string
+	// Token, Org and Bucket are used with the InfluxDB 2.x write API
+	Token  string
+	Org    string
+	Bucket string
+	// Database is used with the InfluxDB 1.x write API, when no bucket is provided
+	Database      string
+	Measurement   string
+	QueueCapacity int
+	BatchSize     int
+	FlushInterval time.Duration
+	Timeout       time.Duration
+}
+
+// influxSink forwards the metric values to InfluxDB asynchronously. The values are buffered in a bounded queue and
+// written in batches; when the queue is full or the write fails, the values are dropped (and counted) so the sink
+// never slows down the reports ingestion.
+type influxSink struct {
+	writeURL      string
+	token         string
+	measurement   string
+	batchSize     int
+	flushInterval time.Duration
+	client        *http.Client
+	queue         chan common.MetricRecord
+	cancel        context.CancelFunc
+	wg            sync.WaitGroup
+	mutStats      sync.Mutex
+	numWritten    uint64
+	numDropped    uint64
+}
+
+// NewInfluxSink creates a new InfluxDB sink and starts its background writer
+func NewInfluxSink(args ArgsInfluxSink) (*influxSink, error) {
+	writeURL, err := createWriteURL(args)
+	if err != nil {
+		return nil, err
+	}
+	if args.QueueCapacity < 0 || args.BatchSize < 0 {
+		return nil, errInvalidQueueSetting
+	}
+
+	sink := &influxSink{
+		writeURL:      writeURL,
+		token:         args.Token,
+		measurement:   args.Measurement,
+		batchSize:     args.BatchSize,
+		flushInterval: args.FlushInterval,
+		client:        &http.Client{Timeout: args.Timeout},
+	}
+	if len(sink.measurement) == 0 {
+		sink.measurement = defaultMeasurement
+	}
+	if sink.batchSize == 0 {
+		sink.batchSize = defaultBatchSize
+	}
+	if sink.flushInterval <= 0 {
+		sink.flushInterval = defaultFlushInterval
+	}
+	if sink.client.Timeout <= 0 {
+		sink.client.Timeout = defaultTimeout
+	}
+	queueCapacity := args.QueueCapacity
+	if queueCapacity == 0 {
+		queueCapacity = defaultQueueCapacity
+	}
+	sink.queue = make(chan common.MetricRecord, queueCapacity)
+
+	var ctx context.Context
+	ctx, sink.cancel = context.WithCancel(context.Background())
+	sink.wg.Add(1)
+	go sink.processLoop(ctx)
+
+	return sink, nil
+}
+
+func createWriteURL(args ArgsInfluxSink) (string, error) {
+	baseURL := strings.TrimSuffix(strings.TrimSpace(args.URL), "/")
+	if len(baseURL) == 0 {
+		return "", errEmptyURL
+	}
+
+	if len(args.Bucket) > 0 {
+		query := url.Values{
+			"org":       {args.Org},
+			"bucket":    {args.Bucket},
+			"precision": {"s"},
+		}
+		return baseURL + "/api/v2/write?" + query.Encode(), nil
+	}
+	if len(args.Database) > 0 {
+		query := url.Values{
+			"db":        {args.Database},
+			"precision": {"s"},
+		}
+		return baseURL + "/write?" + query.Encode(), nil
+	}
+
+	return "", errEmptyTarget
+}
+
+// Forward queues the records to be written, dropping them if the queue is full
+func (sink *influxSink) Forward(records []common.MetricRecord) {
+	numDropped := uint64(0)
+	for _, record := range records {
+		select {
+		case sink.queue <- record:
+		default:
+			numDropped++
+		}
+	}
+
+	if numDropped > 0 {
+		sink.addStats(0, numDropped)
+		log.Debug("influx sink queue is full, dropped metric values", "num dropped", numDropped)
+	}
+}
+
+func (sink *influxSink) processLoop(ctx context.Context) {
+	defer sink.wg.Done()
+
+	ticker := time.NewTicker(sink.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]common.MetricRecord, 0, sink.batchSize)
+	for {
+		select {
+		case <-ctx.Done():
+			// write what is left in the queue before exiting
+			for {
+				select {
+				case record := <-sink.queue:
+					batch = append(batch, record)
+					if len(batch) >= sink.batchSize {
+						batch = sink.writeBatch(batch)
+					}
+				default:
+					sink.writeBatch(batch)
+					return
+				}
+			}
+		case record := <-sink.queue:
+			batch = append(batch, record)
+			if len(batch) >= sink.batchSize {
+				batch = sink.writeBatch(batch)
+			}
+		case <-ticker.C:
+			batch = sink.writeBatch(batch)
+		}
+	}
+}
+
+// writeBatch writes the batch and returns it emptied, ready to be reused
+func (sink *influxSink) writeBatch(batch []common.MetricRecord) []common.MetricRecord {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	err := sink.write(batch)
+	if err != nil {
+		log.Warn("failed to write the metric values to InfluxDB, they will be discarded",
+			"num values", len(batch), "error", err)
+		sink.addStats(0, uint64(len(batch)))
+	} else {
+		sink.addStats(uint64(len(batch)), 0)
+	}
+
+	return batch[:0]
+}
+
+func (sink *influxSink) write(batch []common.MetricRecord) error {
+	builder := &strings.Builder{}
+	for _, record := range batch {
+		appendLine(builder, sink.measurement, record)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sink.client.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sink.writeURL, bytes.NewBufferString(builder.String()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if len(sink.token) > 0 {
+		req.Header.Set("Authorization", "Token "+sink.token)
+	}
+
+	resp, err := sink.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if !common.IsHttpStatusCodeSuccess(resp.StatusCode) {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%w %d: %s", errUnexpectedStatus, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
+
+func (sink *influxSink) addStats(numWritten uint64, numDropped uint64) {
+	sink.mutStats.Lock()
+	defer sink.mutStats.Unlock()
+
+	sink.numWritten += numWritten
+	sink.numDropped += numDropped
+}
+
+// Stats returns the number of values written and the number of values dropped
+func (sink *influxSink) Stats() (uint64, uint64) {
+	sink.mutStats.Lock()
+	defer sink.mutStats.Unlock()
+
+	return sink.numWritten, sink.numDropped
+}
+
+// Close writes the queued values and stops the background writer
+func (sink *influxSink) Close() error {
+	sink.cancel()
+	sink.wg.Wait()
+
+	return nil
+}
+
+// IsInterfaceNil returns true if there is no value under the interface
+func (sink *influxSink) IsInterfaceNil() bool {
+	return sink == nil
+}
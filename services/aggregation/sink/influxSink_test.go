@@ -0,0 +1,262 @@
+package sink
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type influxServerMock struct {
+	mut      sync.Mutex
+	requests []*http.Request
+	bodies   []string
+	status   int
+}
+
+func (mock *influxServerMock) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	mock.mut.Lock()
+	mock.requests = append(mock.requests, r)
+	mock.bodies = append(mock.bodies, string(body))
+	status := mock.status
+	mock.mut.Unlock()
+
+	if status == 0 {
+		status = http.StatusNoContent
+	}
+	w.WriteHeader(status)
+}
+
+func (mock *influxServerMock) getBodies() []string {
+	mock.mut.Lock()
+	defer mock.mut.Unlock()
+
+	return append([]string{}, mock.bodies...)
+}
+
+func (mock *influxServerMock) getRequests() []*http.Request {
+	mock.mut.Lock()
+	defer mock.mut.Unlock()
+
+	return append([]*http.Request{}, mock.requests...)
+}
+
+func createTestRecords(num int) []common.MetricRecord {
+	records := make([]common.MetricRecord, 0, num)
+	for i := 0; i < num; i++ {
+		records = append(records, common.MetricRecord{
+			Name:       "VM1.Node1.nonce",
+			Type:       common.MetricTypeUint64,
+			Value:      "10",
+			RecordedAt: 1000,
+		})
+	}
+
+	return records
+}
+
+func TestNewInfluxSink(t *testing.T) {
+	t.Parallel()
+
+	t.Run("empty URL should error", func(t *testing.T) {
+		t.Parallel()
+
+		sink, err := NewInfluxSink(ArgsInfluxSink{Bucket: "metrics"})
+		assert.Nil(t, sink)
+		assert.Equal(t, errEmptyURL, err)
+	})
+	t.Run("empty bucket and database should error", func(t *testing.T) {
+		t.Parallel()
+
+		sink, err := NewInfluxSink(ArgsInfluxSink{URL: "http://127.0.0.1:8086"})
+		assert.Nil(t, sink)
+		assert.Equal(t, errEmptyTarget, err)
+	})
+	t.Run("negative queue capacity should error", func(t *testing.T) {
+		t.Parallel()
+
+		sink, err := NewInfluxSink(ArgsInfluxSink{URL: "http://127.0.0.1:8086", Database: "metrics", QueueCapacity: -1})
+		assert.Nil(t, sink)
+		assert.Equal(t, errInvalidQueueSetting, err)
+	})
+	t.Run("InfluxDB 2.x write URL", func(t *testing.T) {
+		t.Parallel()
+
+		sink, err := NewInfluxSink(ArgsInfluxSink{URL: "http://127.0.0.1:8086/", Org: "my org", Bucket: "metrics"})
+		require.Nil(t, err)
+		defer func() {
+			_ = sink.Close()
+		}()
+
+		assert.False(t, sink.IsInterfaceNil())
+		assert.Equal(t, "http://127.0.0.1:8086/api/v2/write?bucket=metrics&org=my+org&precision=s", sink.writeURL)
+		assert.Equal(t, defaultMeasurement, sink.measurement)
+		assert.Equal(t, defaultBatchSize, sink.batchSize)
+		assert.Equal(t, defaultQueueCapacity, cap(sink.queue))
+	})
+	t.Run("InfluxDB 1.x write URL", func(t *testing.T) {
+		t.Parallel()
+
+		sink, err := NewInfluxSink(ArgsInfluxSink{URL: "http://127.0.0.1:8086", Database: "metrics"})
+		require.Nil(t, err)
+		defer func() {
+			_ = sink.Close()
+		}()
+
+		assert.Equal(t, "http://127.0.0.1:8086/write?db=metrics&precision=s", sink.writeURL)
+	})
+}
+
+func TestInfluxSink_Forward(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should write the values in batches", func(t *testing.T) {
+		t.Parallel()
+
+		mock := &influxServerMock{}
+		testServer := httptest.NewServer(mock)
+		defer testServer.Close()
+
+		sink, _ := NewInfluxSink(ArgsInfluxSink{
+			URL:           testServer.URL,
+			Token:         "secret",
+			Org:           "org",
+			Bucket:        "metrics",
+			BatchSize:     2,
+			FlushInterval: time.Hour,
+		})
+
+		sink.Forward(createTestRecords(3))
+
+		// the full batch is written without waiting for the flush interval
+		require.Eventually(t, func() bool {
+			return len(mock.getBodies()) == 1
+		}, time.Second, time.Millisecond*10)
+
+		// the remaining value is written on close
+		_ = sink.Close()
+
+		bodies := mock.getBodies()
+		require.Len(t, bodies, 2)
+		assert.Equal(t, strings.Repeat("metrics,metric=VM1.Node1.nonce value=10 1000\n", 2), bodies[0])
+		assert.Equal(t, "metrics,metric=VM1.Node1.nonce value=10 1000\n", bodies[1])
+		assert.Equal(t, "Token secret", mock.getRequests()[0].Header.Get("Authorization"))
+		assert.Equal(t, "/api/v2/write", mock.getRequests()[0].URL.Path)
+
+		numWritten, numDropped := sink.Stats()
+		assert.Equal(t, uint64(3), numWritten)
+		assert.Equal(t, uint64(0), numDropped)
+	})
+	t.Run("should flush on the interval", func(t *testing.T) {
+		t.Parallel()
+
+		mock := &influxServerMock{}
+		testServer := httptest.NewServer(mock)
+		defer testServer.Close()
+
+		sink, _ := NewInfluxSink(ArgsInfluxSink{
+			URL:           testServer.URL,
+			Database:      "metrics",
+			FlushInterval: time.Millisecond * 10,
+		})
+		defer func() {
+			_ = sink.Close()
+		}()
+
+		sink.Forward(createTestRecords(1))
+
+		require.Eventually(t, func() bool {
+			return len(mock.getBodies()) == 1
+		}, time.Second, time.Millisecond*10)
+		assert.Empty(t, mock.getRequests()[0].Header.Get("Authorization"))
+	})
+	t.Run("failed writes should be counted as dropped", func(t *testing.T) {
+		t.Parallel()
+
+		mock := &influxServerMock{status: http.StatusInternalServerError}
+		testServer := httptest.NewServer(mock)
+		defer testServer.Close()
+
+		sink, _ := NewInfluxSink(ArgsInfluxSink{
+			URL:           testServer.URL,
+			Database:      "metrics",
+			FlushInterval: time.Hour,
+		})
+
+		sink.Forward(createTestRecords(2))
+		_ = sink.Close()
+
+		numWritten, numDropped := sink.Stats()
+		assert.Equal(t, uint64(0), numWritten)
+		assert.Equal(t, uint64(2), numDropped)
+	})
+	t.Run("full queue should drop the values", func(t *testing.T) {
+		t.Parallel()
+
+		sink := &influxSink{
+			queue: make(chan common.MetricRecord, 2),
+		}
+
+		sink.Forward(createTestRecords(5))
+
+		numWritten, numDropped := sink.Stats()
+		assert.Equal(t, uint64(0), numWritten)
+		assert.Equal(t, uint64(3), numDropped)
+		assert.Len(t, sink.queue, 2)
+	})
+}
+
+func TestAppendLine(t *testing.T) {
+	t.Parallel()
+
+	builder := &strings.Builder{}
+	appendLine(builder, "my metrics", common.MetricRecord{
+		Name:       "VM1.Node 1.nonce",
+		Type:       common.MetricTypeUint64,
+		Value:      "10",
+		Tags:       map[string]string{"vm": "VM1", "kind": "a=b,c", "empty": ""},
+		RecordedAt: 1000,
+	})
+	appendLine(builder, "metrics", common.MetricRecord{
+		Name:       "VM1.Node1.version",
+		Type:       common.MetricTypeString,
+		Value:      `v1 "beta"`,
+		RecordedAt: 1001,
+	})
+	appendLine(builder, "metrics", common.MetricRecord{
+		Name:       "VM1.Node1.synced",
+		Type:       common.MetricTypeBool,
+		Value:      "true",
+		RecordedAt: 1002,
+	})
+	appendLine(builder, "metrics", common.MetricRecord{
+		Name:       "VM1.Node1.ratio",
+		Type:       common.MetricTypeFloat64,
+		Value:      "not a number",
+		RecordedAt: 1003,
+	})
+
+	expected := `my\ metrics,metric=VM1.Node\ 1.nonce,kind=a\=b\,c,vm=VM1 value=10 1000` + "\n" +
+		`metrics,metric=VM1.Node1.version value="v1 \"beta\"" 1001` + "\n" +
+		`metrics,metric=VM1.Node1.synced value=true 1002` + "\n" +
+		`metrics,metric=VM1.Node1.ratio value="not a number" 1003` + "\n"
+	assert.Equal(t, expected, builder.String())
+}
+
+func TestDisabledSink(t *testing.T) {
+	t.Parallel()
+
+	sink := NewDisabledSink()
+	assert.False(t, sink.IsInterfaceNil())
+	sink.Forward(createTestRecords(1))
+	assert.Nil(t, sink.Close())
+}
@@ -0,0 +1,10 @@
+package sink
+
+import "github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+
+// MetricsSink defines the operations of a secondary destination of the accepted metric values
+type MetricsSink interface {
+	Forward(records []common.MetricRecord)
+	Close() error
+	IsInterfaceNil() bool
+}
@@ -0,0 +1,62 @@
+package sink
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+)
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	tagEscaper         = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+	stringFieldEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+)
+
+// appendLine appends the record in the InfluxDB line protocol, with second precision:
+// <measurement>,metric=<name>,<tags> value=<value> <timestamp>
+// The numeric metrics are written as float fields, the bool ones as boolean fields and the rest as string fields.
+func appendLine(builder *strings.Builder, measurement string, record common.MetricRecord) {
+	builder.WriteString(measurementEscaper.Replace(measurement))
+	builder.WriteString(",metric=")
+	builder.WriteString(tagEscaper.Replace(record.Name))
+
+	keys := make([]string, 0, len(record.Tags))
+	for key := range record.Tags {
+		if key == "metric" || len(key) == 0 || len(record.Tags[key]) == 0 {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		builder.WriteString(",")
+		builder.WriteString(tagEscaper.Replace(key))
+		builder.WriteString("=")
+		builder.WriteString(tagEscaper.Replace(record.Tags[key]))
+	}
+
+	builder.WriteString(" value=")
+	builder.WriteString(fieldValue(record))
+	builder.WriteString(" ")
+	builder.WriteString(strconv.FormatInt(record.RecordedAt, 10))
+	builder.WriteString("\n")
+}
+
+func fieldValue(record common.MetricRecord) string {
+	if common.IsNumericMetricType(record.Type) {
+		value, err := strconv.ParseFloat(record.Value, 64)
+		if err == nil {
+			return strconv.FormatFloat(value, 'f', -1, 64)
+		}
+	}
+	if record.Type == common.MetricTypeBool {
+		value, err := strconv.ParseBool(record.Value)
+		if err == nil {
+			return strconv.FormatBool(value)
+		}
+	}
+
+	return `"` + stringFieldEscaper.Replace(record.Value) + `"`
+}
@@ -0,0 +1,371 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+)
+
+const (
+	defaultNatsPort          = "4222"
+	defaultNatsQueueCapacity = 10000
+	natsClientName           = "api-monitoring-aggregation"
+	natsLineTerminator       = "\r\n"
+)
+
+// ArgsNatsPublisher defines the DTO struct for the NewNatsPublisher constructor function
+type ArgsNatsPublisher struct {
+	// URL is the NATS server address, e.g. nats://127.0.0.1:4222
+	URL     string
+	Subject string
+	// Token is the optional NATS authentication token
+	Token         string
+	QueueCapacity int
+	Timeout       time.Duration
+}
+
+// reportEvent is the message published for each accepted report
+type reportEvent struct {
+	RecordedAt int64                 `json:"recordedAt"`
+	Metrics    []common.MetricRecord `json:"metrics"`
+}
+
+// natsPublisher publishes every accepted report, as a JSON message, on a NATS subject. It speaks the NATS text
+// protocol directly (CONNECT, PUB, PING/PONG) over a single connection that is re-established on failures. The
+// messages are buffered in a bounded queue and dropped (and counted) when the server can not be reached, so the
+// reports ingestion is never slowed down.
+type natsPublisher struct {
+	address     string
+	subject     string
+	token       string
+	timeout     time.Duration
+	queue       chan []byte
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
+	mutConn     sync.Mutex
+	conn        net.Conn
+	mutStats    sync.Mutex
+	numSent     uint64
+	numDropped  uint64
+	dialContext func(ctx context.Context, network string, address string) (net.Conn, error)
+}
+
+// NewNatsPublisher creates a new NATS publisher and starts its background sender
+func NewNatsPublisher(args ArgsNatsPublisher) (*natsPublisher, error) {
+	address, err := parseNatsAddress(args.URL)
+	if err != nil {
+		return nil, err
+	}
+	if len(args.Subject) == 0 {
+		return nil, errEmptySubject
+	}
+	if strings.ContainsAny(args.Subject, " \t\r\n*>") {
+		return nil, errInvalidSubject
+	}
+	if args.QueueCapacity < 0 {
+		return nil, errInvalidQueueSetting
+	}
+
+	publisher := &natsPublisher{
+		address: address,
+		subject: args.Subject,
+		token:   args.Token,
+		timeout: args.Timeout,
+	}
+	if publisher.timeout <= 0 {
+		publisher.timeout = defaultTimeout
+	}
+	dialer := &net.Dialer{Timeout: publisher.timeout}
+	publisher.dialContext = dialer.DialContext
+
+	queueCapacity := args.QueueCapacity
+	if queueCapacity == 0 {
+		queueCapacity = defaultNatsQueueCapacity
+	}
+	publisher.queue = make(chan []byte, queueCapacity)
+
+	var ctx context.Context
+	ctx, publisher.cancel = context.WithCancel(context.Background())
+	publisher.wg.Add(1)
+	go publisher.processLoop(ctx)
+
+	return publisher, nil
+}
+
+func parseNatsAddress(rawURL string) (string, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	if len(rawURL) == 0 {
+		return "", errEmptyURL
+	}
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "nats://" + rawURL
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	if parsed.Scheme != "nats" || len(parsed.Hostname()) == 0 {
+		return "", fmt.Errorf("%w: %s", errInvalidURL, rawURL)
+	}
+
+	port := parsed.Port()
+	if len(port) == 0 {
+		port = defaultNatsPort
+	}
+
+	return net.JoinHostPort(parsed.Hostname(), port), nil
+}
+
+// Forward queues the report to be published, dropping it if the queue is full
+func (publisher *natsPublisher) Forward(records []common.MetricRecord) {
+	if len(records) == 0 {
+		return
+	}
+
+	message, err := json.Marshal(reportEvent{
+		RecordedAt: records[0].RecordedAt,
+		Metrics:    records,
+	})
+	if err != nil {
+		log.Warn("failed to marshal the report event", "error", err)
+		publisher.addStats(0, 1)
+		return
+	}
+
+	select {
+	case publisher.queue <- message:
+	default:
+		publisher.addStats(0, 1)
+		log.Debug("NATS publisher queue is full, dropped report", "num metrics", len(records))
+	}
+}
+
+func (publisher *natsPublisher) processLoop(ctx context.Context) {
+	defer publisher.wg.Done()
+	defer publisher.closeConnection()
+
+	for {
+		select {
+		case <-ctx.Done():
+			publisher.drainQueue()
+			return
+		case message := <-publisher.queue:
+			publisher.publish(message)
+		}
+	}
+}
+
+// drainQueue publishes what is left in the queue, giving up on the first failure so the shutdown is not delayed
+func (publisher *natsPublisher) drainQueue() {
+	for {
+		select {
+		case message := <-publisher.queue:
+			if !publisher.publish(message) {
+				numLeft := uint64(len(publisher.queue))
+				publisher.addStats(0, numLeft)
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (publisher *natsPublisher) publish(message []byte) bool {
+	err := publisher.send(message)
+	if err != nil {
+		log.Warn("failed to publish the report on NATS, it will be discarded",
+			"subject", publisher.subject, "error", err)
+		publisher.closeConnection()
+		publisher.addStats(0, 1)
+		return false
+	}
+
+	publisher.addStats(1, 0)
+	return true
+}
+
+func (publisher *natsPublisher) send(message []byte) error {
+	conn, err := publisher.getConnection()
+	if err != nil {
+		return err
+	}
+
+	buff := make([]byte, 0, len(publisher.subject)+len(message)+32)
+	buff = append(buff, fmt.Sprintf("PUB %s %d%s", publisher.subject, len(message), natsLineTerminator)...)
+	buff = append(buff, message...)
+	buff = append(buff, natsLineTerminator...)
+
+	return publisher.write(conn, buff)
+}
+
+func (publisher *natsPublisher) write(conn net.Conn, data []byte) error {
+	publisher.mutConn.Lock()
+	defer publisher.mutConn.Unlock()
+
+	_ = conn.SetWriteDeadline(time.Now().Add(publisher.timeout))
+	_, err := conn.Write(data)
+
+	return err
+}
+
+// getConnection returns the current connection, establishing a new one if needed. The handshake reads the server
+// INFO line, sends CONNECT and waits for the PONG reply to a PING, so the authentication errors are detected here.
+func (publisher *natsPublisher) getConnection() (net.Conn, error) {
+	publisher.mutConn.Lock()
+	conn := publisher.conn
+	publisher.mutConn.Unlock()
+	if conn != nil {
+		return conn, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), publisher.timeout)
+	defer cancel()
+
+	conn, err := publisher.dialContext(ctx, "tcp", publisher.address)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	err = publisher.handshake(conn, reader)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	publisher.mutConn.Lock()
+	publisher.conn = conn
+	publisher.mutConn.Unlock()
+
+	go publisher.readLoop(conn, reader)
+
+	log.Debug("connected to the NATS server", "address", publisher.address)
+
+	return conn, nil
+}
+
+func (publisher *natsPublisher) handshake(conn net.Conn, reader *bufio.Reader) error {
+	_ = conn.SetDeadline(time.Now().Add(publisher.timeout))
+	defer func() {
+		_ = conn.SetDeadline(time.Time{})
+	}()
+
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "INFO") {
+		return fmt.Errorf("%w: unexpected greeting %q", errServerError, strings.TrimSpace(line))
+	}
+
+	options := map[string]interface{}{
+		"verbose":  false,
+		"pedantic": false,
+		"name":     natsClientName,
+		"lang":     "go",
+		"version":  "1.0.0",
+		"protocol": 0,
+	}
+	if len(publisher.token) > 0 {
+		options["auth_token"] = publisher.token
+	}
+	connectOptions, err := json.Marshal(options)
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.Write([]byte("CONNECT " + string(connectOptions) + natsLineTerminator + "PING" + natsLineTerminator))
+	if err != nil {
+		return err
+	}
+
+	line, err = reader.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	line = strings.TrimSpace(line)
+	if line != "PONG" {
+		return fmt.Errorf("%w: %s", errServerError, line)
+	}
+
+	return nil
+}
+
+// readLoop answers the server keep-alive PINGs and logs the server errors until the connection is closed
+func (publisher *natsPublisher) readLoop(conn net.Conn, reader *bufio.Reader) {
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			publisher.dropConnection(conn)
+			return
+		}
+
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PING":
+			_ = publisher.write(conn, []byte("PONG"+natsLineTerminator))
+		case strings.HasPrefix(line, "-ERR"):
+			log.Warn("NATS server error", "message", line)
+		}
+	}
+}
+
+// dropConnection forgets the connection, if it is still the current one, so the next message reconnects
+func (publisher *natsPublisher) dropConnection(conn net.Conn) {
+	publisher.mutConn.Lock()
+	defer publisher.mutConn.Unlock()
+
+	_ = conn.Close()
+	if publisher.conn == conn {
+		publisher.conn = nil
+	}
+}
+
+func (publisher *natsPublisher) closeConnection() {
+	publisher.mutConn.Lock()
+	defer publisher.mutConn.Unlock()
+
+	if publisher.conn != nil {
+		_ = publisher.conn.Close()
+		publisher.conn = nil
+	}
+}
+
+func (publisher *natsPublisher) addStats(numSent uint64, numDropped uint64) {
+	publisher.mutStats.Lock()
+	defer publisher.mutStats.Unlock()
+
+	publisher.numSent += numSent
+	publisher.numDropped += numDropped
+}
+
+// Stats returns the number of reports published and the number of reports dropped
+func (publisher *natsPublisher) Stats() (uint64, uint64) {
+	publisher.mutStats.Lock()
+	defer publisher.mutStats.Unlock()
+
+	return publisher.numSent, publisher.numDropped
+}
+
+// Close publishes the queued reports and closes the connection
+func (publisher *natsPublisher) Close() error {
+	publisher.cancel()
+	publisher.wg.Wait()
+
+	return nil
+}
+
+// IsInterfaceNil returns true if there is no value under the interface
+func (publisher *natsPublisher) IsInterfaceNil() bool {
+	return publisher == nil
+}
@@ -0,0 +1,281 @@
+package sink
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// natsServerMock implements the server side of the NATS text protocol subset used by the publisher
+type natsServerMock struct {
+	listener     net.Listener
+	mut          sync.Mutex
+	connects     []string
+	messages     map[string][]string
+	pongReceived bool
+	connectReply string
+}
+
+func newNatsServerMock(t *testing.T) *natsServerMock {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+
+	mock := &natsServerMock{
+		listener:     listener,
+		messages:     make(map[string][]string),
+		connectReply: "PONG",
+	}
+	go mock.acceptLoop()
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+
+	return mock
+}
+
+func (mock *natsServerMock) url() string {
+	return "nats://" + mock.listener.Addr().String()
+}
+
+func (mock *natsServerMock) acceptLoop() {
+	for {
+		conn, err := mock.listener.Accept()
+		if err != nil {
+			return
+		}
+		go mock.serve(conn)
+	}
+}
+
+func (mock *natsServerMock) serve(conn net.Conn) {
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	_, _ = conn.Write([]byte(`INFO {"server_id":"mock","max_payload":1048576}` + "\r\n"))
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimSpace(line)
+
+		switch {
+		case strings.HasPrefix(line, "CONNECT "):
+			mock.mut.Lock()
+			mock.connects = append(mock.connects, strings.TrimPrefix(line, "CONNECT "))
+			reply := mock.connectReply
+			mock.mut.Unlock()
+			if reply != "PONG" {
+				// the reply is sent instead of the PONG answering the handshake PING
+				_, _ = conn.Write([]byte(reply + "\r\n"))
+				return
+			}
+		case line == "PING":
+			_, _ = conn.Write([]byte("PONG\r\n"))
+			// check that the client answers the server keep-alive
+			_, _ = conn.Write([]byte("PING\r\n"))
+		case line == "PONG":
+			mock.mut.Lock()
+			mock.pongReceived = true
+			mock.mut.Unlock()
+		case strings.HasPrefix(line, "PUB "):
+			parts := strings.Fields(line)
+			size, _ := strconv.Atoi(parts[2])
+			payload := make([]byte, size+2)
+			_, err = io.ReadFull(reader, payload)
+			if err != nil {
+				return
+			}
+
+			mock.mut.Lock()
+			mock.messages[parts[1]] = append(mock.messages[parts[1]], string(payload[:size]))
+			mock.mut.Unlock()
+		}
+	}
+}
+
+func (mock *natsServerMock) getMessages(subject string) []string {
+	mock.mut.Lock()
+	defer mock.mut.Unlock()
+
+	return append([]string{}, mock.messages[subject]...)
+}
+
+func TestNewNatsPublisher(t *testing.T) {
+	t.Parallel()
+
+	t.Run("empty URL should error", func(t *testing.T) {
+		t.Parallel()
+
+		publisher, err := NewNatsPublisher(ArgsNatsPublisher{Subject: "reports"})
+		assert.Nil(t, publisher)
+		assert.Equal(t, errEmptyURL, err)
+	})
+	t.Run("invalid URL should error", func(t *testing.T) {
+		t.Parallel()
+
+		publisher, err := NewNatsPublisher(ArgsNatsPublisher{URL: "http://127.0.0.1:4222", Subject: "reports"})
+		assert.Nil(t, publisher)
+		assert.ErrorIs(t, err, errInvalidURL)
+	})
+	t.Run("empty subject should error", func(t *testing.T) {
+		t.Parallel()
+
+		publisher, err := NewNatsPublisher(ArgsNatsPublisher{URL: "nats://127.0.0.1:4222"})
+		assert.Nil(t, publisher)
+		assert.Equal(t, errEmptySubject, err)
+	})
+	t.Run("wildcard subject should error", func(t *testing.T) {
+		t.Parallel()
+
+		publisher, err := NewNatsPublisher(ArgsNatsPublisher{URL: "nats://127.0.0.1:4222", Subject: "reports.>"})
+		assert.Nil(t, publisher)
+		assert.Equal(t, errInvalidSubject, err)
+	})
+	t.Run("should use the default port", func(t *testing.T) {
+		t.Parallel()
+
+		publisher, err := NewNatsPublisher(ArgsNatsPublisher{URL: "127.0.0.1", Subject: "reports"})
+		require.Nil(t, err)
+		defer func() {
+			_ = publisher.Close()
+		}()
+
+		assert.False(t, publisher.IsInterfaceNil())
+		assert.Equal(t, "127.0.0.1:4222", publisher.address)
+		assert.Equal(t, defaultNatsQueueCapacity, cap(publisher.queue))
+	})
+}
+
+func TestNatsPublisher_Forward(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should publish each report", func(t *testing.T) {
+		t.Parallel()
+
+		mock := newNatsServerMock(t)
+		publisher, err := NewNatsPublisher(ArgsNatsPublisher{
+			URL:     mock.url(),
+			Subject: "monitoring.reports",
+			Token:   "secret",
+			Timeout: time.Second,
+		})
+		require.Nil(t, err)
+
+		publisher.Forward(createTestRecords(2))
+		publisher.Forward(nil)
+		publisher.Forward(createTestRecords(1))
+
+		require.Eventually(t, func() bool {
+			return len(mock.getMessages("monitoring.reports")) == 2
+		}, time.Second, time.Millisecond*10)
+		require.Eventually(t, func() bool {
+			mock.mut.Lock()
+			defer mock.mut.Unlock()
+
+			return mock.pongReceived
+		}, time.Second, time.Millisecond*10)
+
+		_ = publisher.Close()
+
+		event := reportEvent{}
+		require.Nil(t, json.Unmarshal([]byte(mock.getMessages("monitoring.reports")[0]), &event))
+		assert.Equal(t, int64(1000), event.RecordedAt)
+		require.Len(t, event.Metrics, 2)
+		assert.Equal(t, "VM1.Node1.nonce", event.Metrics[0].Name)
+
+		mock.mut.Lock()
+		require.Len(t, mock.connects, 1)
+		assert.Contains(t, mock.connects[0], `"auth_token":"secret"`)
+		mock.mut.Unlock()
+
+		numSent, numDropped := publisher.Stats()
+		assert.Equal(t, uint64(2), numSent)
+		assert.Equal(t, uint64(0), numDropped)
+	})
+	t.Run("rejected connection should drop the reports", func(t *testing.T) {
+		t.Parallel()
+
+		mock := newNatsServerMock(t)
+		mock.connectReply = "-ERR 'Authorization Violation'"
+		publisher, _ := NewNatsPublisher(ArgsNatsPublisher{
+			URL:     mock.url(),
+			Subject: "monitoring.reports",
+			Timeout: time.Second,
+		})
+
+		publisher.Forward(createTestRecords(1))
+		require.Eventually(t, func() bool {
+			_, numDropped := publisher.Stats()
+			return numDropped == 1
+		}, time.Second, time.Millisecond*10)
+		_ = publisher.Close()
+
+		assert.Empty(t, mock.getMessages("monitoring.reports"))
+	})
+	t.Run("unreachable server should drop the reports", func(t *testing.T) {
+		t.Parallel()
+
+		listener, _ := net.Listen("tcp", "127.0.0.1:0")
+		address := listener.Addr().String()
+		_ = listener.Close()
+
+		publisher, _ := NewNatsPublisher(ArgsNatsPublisher{
+			URL:     address,
+			Subject: "monitoring.reports",
+			Timeout: time.Second,
+		})
+		publisher.Forward(createTestRecords(1))
+		_ = publisher.Close()
+
+		numSent, numDropped := publisher.Stats()
+		assert.Equal(t, uint64(0), numSent)
+		assert.Equal(t, uint64(1), numDropped)
+	})
+	t.Run("full queue should drop the reports", func(t *testing.T) {
+		t.Parallel()
+
+		publisher := &natsPublisher{
+			queue: make(chan []byte, 1),
+		}
+		publisher.Forward(createTestRecords(1))
+		publisher.Forward(createTestRecords(1))
+
+		_, numDropped := publisher.Stats()
+		assert.Equal(t, uint64(1), numDropped)
+		assert.Len(t, publisher.queue, 1)
+	})
+}
+
+func TestSinksCollection(t *testing.T) {
+	t.Parallel()
+
+	mock := newNatsServerMock(t)
+	publisher, _ := NewNatsPublisher(ArgsNatsPublisher{
+		URL:     mock.url(),
+		Subject: "monitoring.reports",
+		Timeout: time.Second,
+	})
+
+	var nilSink *influxSink
+	collection := NewSinksCollection(NewDisabledSink(), nilSink, publisher)
+	assert.False(t, collection.IsInterfaceNil())
+	assert.Len(t, collection.sinks, 2)
+
+	collection.Forward(createTestRecords(1))
+	assert.Nil(t, collection.Close())
+	require.Eventually(t, func() bool {
+		return len(mock.getMessages("monitoring.reports")) == 1
+	}, time.Second, time.Millisecond*10)
+}
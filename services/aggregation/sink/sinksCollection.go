@@ -0,0 +1,50 @@
+package sink
+
+import (
+	"errors"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/multiversx/mx-chain-core-go/core/check"
+)
+
+// sinksCollection forwards the accepted metric values to all the contained sinks
+type sinksCollection struct {
+	sinks []MetricsSink
+}
+
+// NewSinksCollection creates a sink that fans out the records to all the provided sinks, skipping the nil ones
+func NewSinksCollection(sinks ...MetricsSink) *sinksCollection {
+	collection := &sinksCollection{
+		sinks: make([]MetricsSink, 0, len(sinks)),
+	}
+	for _, sink := range sinks {
+		if check.IfNil(sink) {
+			continue
+		}
+		collection.sinks = append(collection.sinks, sink)
+	}
+
+	return collection
+}
+
+// Forward hands over the records to all the contained sinks
+func (collection *sinksCollection) Forward(records []common.MetricRecord) {
+	for _, sink := range collection.sinks {
+		sink.Forward(records)
+	}
+}
+
+// Close closes all the contained sinks, returning the joined errors
+func (collection *sinksCollection) Close() error {
+	var errs []error
+	for _, sink := range collection.sinks {
+		errs = append(errs, sink.Close())
+	}
+
+	return errors.Join(errs...)
+}
+
+// IsInterfaceNil returns true if there is no value under the interface
+func (collection *sinksCollection) IsInterfaceNil() bool {
+	return collection == nil
+}
@@ -0,0 +1,134 @@
+package sla
+
+import (
+	"sort"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+)
+
+// Window is a time window the availability is computed for
+type Window struct {
+	Name    string
+	Seconds int64
+}
+
+// Windows are the time windows the availability is computed for by default
+var Windows = []Window{
+	{Name: "24h", Seconds: 24 * 3600},
+	{Name: "7d", Seconds: 7 * 24 * 3600},
+	{Name: "30d", Seconds: common.MaxAvailabilityWindowSeconds},
+}
+
+// availabilityHistory holds the buckets in which a metric, or an agent, reported, mapped to their up status
+type availabilityHistory struct {
+	firstBucket int64
+	buckets     map[int64]bool
+}
+
+func newAvailabilityHistory() *availabilityHistory {
+	return &availabilityHistory{
+		buckets: make(map[int64]bool),
+	}
+}
+
+func (history *availabilityHistory) add(bucketStart int64, isUp bool) {
+	if len(history.buckets) == 0 || bucketStart < history.firstBucket {
+		history.firstBucket = bucketStart
+	}
+	history.buckets[bucketStart] = history.buckets[bucketStart] || isUp
+}
+
+// compute returns the availability over the complete buckets of the window. The buckets without any report count
+// as down, except the ones before the first report.
+func (history *availabilityHistory) compute(window Window, now int64) common.Availability {
+	end := now - now%common.AvailabilityBucketSeconds
+	start := end - window.Seconds
+	if history.firstBucket > start {
+		start = history.firstBucket
+	}
+
+	availability := common.Availability{
+		WindowInSec: window.Seconds,
+	}
+	if start >= end {
+		return availability
+	}
+
+	numUp := int64(0)
+	for bucketStart, isUp := range history.buckets {
+		if isUp && bucketStart >= start && bucketStart < end {
+			numUp++
+		}
+	}
+
+	availability.ObservedSeconds = end - start
+	availability.UpSeconds = numUp * common.AvailabilityBucketSeconds
+	availability.Percentage = float64(availability.UpSeconds) * 100 / float64(availability.ObservedSeconds)
+
+	return availability
+}
+
+// ComputeReport groups the metrics by agent (VM). An agent is up during a bucket if its heartbeat metric was true
+// or, for the agents without a heartbeat metric, if any of its metrics reported. An empty agent filter includes all the agents.
+func ComputeReport(buckets []common.AvailabilityBucket, windows []Window, agentFilter string, now int64) *common.SLAReport {
+	metrics := make(map[string]*availabilityHistory)
+	agents := make(map[string]*availabilityHistory)
+	heartbeats := make(map[string]*availabilityHistory)
+	for _, bucket := range buckets {
+		vm := common.ParseTagsFromName(bucket.MetricName)[common.TagVM]
+		if len(agentFilter) > 0 && vm != agentFilter {
+			continue
+		}
+
+		metric, found := metrics[bucket.MetricName]
+		if !found {
+			metric = newAvailabilityHistory()
+			metrics[bucket.MetricName] = metric
+		}
+		metric.add(bucket.BucketStart, bucket.NumSuccess > 0)
+
+		if vm == "" {
+			continue
+		}
+		if bucket.MetricName == vm+"."+common.HeartbeatMetricSuffix {
+			heartbeats[vm] = metric
+			continue
+		}
+		agent, found := agents[vm]
+		if !found {
+			agent = newAvailabilityHistory()
+			agents[vm] = agent
+		}
+		agent.add(bucket.BucketStart, bucket.NumSuccess > 0)
+	}
+	for vm, heartbeat := range heartbeats {
+		agents[vm] = heartbeat
+	}
+
+	return &common.SLAReport{
+		GeneratedAt:   now,
+		BucketSeconds: common.AvailabilityBucketSeconds,
+		Agents:        createSLAEntries(agents, windows, now),
+		Metrics:       createSLAEntries(metrics, windows, now),
+	}
+}
+
+func createSLAEntries(histories map[string]*availabilityHistory, windows []Window, now int64) []common.SLAEntry {
+	entries := make([]common.SLAEntry, 0, len(histories))
+	for name, history := range histories {
+		entry := common.SLAEntry{
+			Name:         name,
+			Availability: make(map[string]common.Availability, len(windows)),
+		}
+		for _, window := range windows {
+			entry.Availability[window.Name] = history.compute(window, now)
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+
+	return entries
+}
@@ -0,0 +1,53 @@
+package sla
+
+import (
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputeReport(t *testing.T) {
+	t.Parallel()
+
+	buckets := []common.AvailabilityBucket{
+		{MetricName: "VM1.Active", BucketStart: 29100, NumSamples: 50, NumSuccess: 50},
+		{MetricName: "VM1.Active", BucketStart: 29400, NumSamples: 50, NumSuccess: 1},
+		{MetricName: "VM1.Active", BucketStart: 29700, NumSamples: 50, NumSuccess: 0},
+		{MetricName: "VM1.Node1.nonce", BucketStart: 29700, NumSamples: 50, NumSuccess: 50},
+		{MetricName: "VM2.Node1.nonce", BucketStart: 28800, NumSamples: 50, NumSuccess: 50},
+		{MetricName: "VM2.Node1.nonce", BucketStart: 29700, NumSamples: 50, NumSuccess: 50},
+		// the current bucket is not complete
+		{MetricName: "VM2.Node1.nonce", BucketStart: 30000, NumSamples: 1, NumSuccess: 1},
+	}
+	day := []Window{Windows[0]}
+	availability := func(observed int64, up int64, percentage float64) map[string]common.Availability {
+		return map[string]common.Availability{
+			"24h": {WindowInSec: 86400, ObservedSeconds: observed, UpSeconds: up, Percentage: percentage},
+		}
+	}
+
+	report := ComputeReport(buckets, day, "", 30017)
+	assert.Equal(t, int64(30017), report.GeneratedAt)
+	assert.Equal(t, int64(common.AvailabilityBucketSeconds), report.BucketSeconds)
+	assert.Equal(t, []common.SLAEntry{
+		{Name: "VM1", Availability: availability(900, 600, float64(600)*100/900)},
+		{Name: "VM2", Availability: availability(1200, 600, 50)},
+	}, report.Agents)
+	assert.Equal(t, []common.SLAEntry{
+		{Name: "VM1.Active", Availability: availability(900, 600, float64(600)*100/900)},
+		{Name: "VM1.Node1.nonce", Availability: availability(300, 300, 100)},
+		{Name: "VM2.Node1.nonce", Availability: availability(1200, 600, 50)},
+	}, report.Metrics)
+
+	report = ComputeReport(buckets, Windows, "VM2", 30017)
+	require.Len(t, report.Agents, 1)
+	assert.Equal(t, "VM2", report.Agents[0].Name)
+	assert.Len(t, report.Agents[0].Availability, 3)
+	require.Len(t, report.Metrics, 1)
+
+	// nothing observed yet
+	report = ComputeReport(buckets[6:], day, "", 30017)
+	assert.Equal(t, availability(0, 0, 0), report.Agents[0].Availability)
+}
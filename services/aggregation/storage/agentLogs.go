@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+)
+
+// SaveAgentLogs stores the log entries shipped by the agents, keeping only the newest maxEntriesPerAgent entries of
+// each agent
+func (s *sqliteStorage) SaveAgentLogs(ctx context.Context, entries []common.AgentLogEntry, maxEntriesPerAgent int) error {
+	ctx, finish := s.startOperation(ctx, "SaveAgentLogs")
+	defer finish()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	agents := make(map[string]struct{})
+	for _, entry := range entries {
+		fields, err := json.Marshal(entry.Fields)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO agent_logs (agent, tenant, level, component, message, fields, recorded_at) VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, entry.Agent, entry.Tenant, entry.Level, entry.Component, entry.Message, string(fields), entry.RecordedAt)
+		if err != nil {
+			return fmt.Errorf("failed to save the agent log entry: %w", err)
+		}
+		agents[entry.Agent] = struct{}{}
+	}
+
+	for agent := range agents {
+		_, err = tx.ExecContext(ctx, `
+			DELETE FROM agent_logs WHERE agent = ? AND id NOT IN (
+				SELECT id FROM agent_logs WHERE agent = ? ORDER BY id DESC LIMIT ?
+			)
+		`, agent, agent, maxEntriesPerAgent)
+		if err != nil {
+			return fmt.Errorf("failed to trim the agent log entries: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetAgentLogs returns the log entries of an agent, newest first. The empty tenant returns the entries of any tenant.
+func (s *sqliteStorage) GetAgentLogs(ctx context.Context, tenant string, agent string) ([]common.AgentLogEntry, error) {
+	ctx, finish := s.startOperation(ctx, "GetAgentLogs")
+	defer finish()
+
+	query := "SELECT id, agent, tenant, level, component, message, fields, recorded_at FROM agent_logs WHERE agent = ?"
+	args := []interface{}{agent}
+	if len(tenant) > 0 {
+		query += " AND tenant = ?"
+		args = append(args, tenant)
+	}
+	query += " ORDER BY id DESC"
+
+	rows, err := s.readDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the agent log entries: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	entries := make([]common.AgentLogEntry, 0)
+	for rows.Next() {
+		var entry common.AgentLogEntry
+		var fields string
+		err = rows.Scan(&entry.ID, &entry.Agent, &entry.Tenant, &entry.Level, &entry.Component, &entry.Message,
+			&fields, &entry.RecordedAt)
+		if err != nil {
+			return nil, err
+		}
+		_ = json.Unmarshal([]byte(fields), &entry.Fields)
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
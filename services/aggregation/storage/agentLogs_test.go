@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteStorage_AgentLogs(t *testing.T) {
+	t.Parallel()
+
+	s, err := NewSQLiteStorage(":memory:", 3600, SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = s.Close()
+	}()
+
+	ctx := context.Background()
+
+	entries, err := s.GetAgentLogs(ctx, "", "VM1")
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	require.NoError(t, s.SaveAgentLogs(ctx, []common.AgentLogEntry{
+		{Agent: "VM1", Level: "warn", Component: "poller", Message: "endpoint poll failed", RecordedAt: 100,
+			Fields: map[string]string{"metric": "VM1.Node1.nonce", "error": "connection refused"}},
+		{Agent: "VM1", Level: "error", Component: "reporter", Message: "report failed", RecordedAt: 101},
+		{Agent: "VM2", Level: "warn", Component: "poller", Message: "endpoint poll failed", RecordedAt: 102},
+	}, 2))
+	// the oldest entries are removed
+	require.NoError(t, s.SaveAgentLogs(ctx, []common.AgentLogEntry{
+		{Agent: "VM1", Level: "warn", Component: "engine", Message: "slow cycle", RecordedAt: 103},
+	}, 2))
+	require.NoError(t, s.SaveAgentLogs(ctx, []common.AgentLogEntry{
+		{Agent: "VM1", Tenant: "acme", Level: "warn", Component: "engine", Message: "other tenant", RecordedAt: 104},
+	}, 3))
+
+	entries, err = s.GetAgentLogs(ctx, "", "VM1")
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	require.Equal(t, "other tenant", entries[0].Message)
+	require.Equal(t, "slow cycle", entries[1].Message)
+	require.Equal(t, common.AgentLogEntry{
+		ID:         2,
+		Agent:      "VM1",
+		Level:      "error",
+		Component:  "reporter",
+		Message:    "report failed",
+		RecordedAt: 101,
+	}, entries[2])
+
+	entries, err = s.GetAgentLogs(ctx, "acme", "VM1")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "acme", entries[0].Tenant)
+
+	entries, err = s.GetAgentLogs(ctx, "", "VM2")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "endpoint poll failed", entries[0].Message)
+}
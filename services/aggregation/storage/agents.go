@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+)
+
+// SaveAgentInfo stores the build and configuration details reported by an agent, replacing the previous ones. The
+// update time is kept if the details did not change and the details of an agent owned by another tenant are not
+// replaced.
+func (s *sqliteStorage) SaveAgentInfo(ctx context.Context, info common.AgentInfo) error {
+	ctx, finish := s.startOperation(ctx, "SaveAgentInfo")
+	defer finish()
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO agents (name, tenant, version, commit_id, config_hash, updated_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET
+			updated_at = CASE
+				WHEN agents.version = excluded.version
+					AND agents.commit_id = excluded.commit_id
+					AND agents.config_hash = excluded.config_hash
+				THEN agents.updated_at
+				ELSE excluded.updated_at
+			END,
+			version = excluded.version,
+			commit_id = excluded.commit_id,
+			config_hash = excluded.config_hash
+		WHERE agents.tenant = excluded.tenant
+	`, info.Name, info.Tenant, info.Version, info.Commit, info.ConfigHash, info.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save the agent info: %w", err)
+	}
+
+	return nil
+}
+
+// GetAgents returns the details of the agents of the tenant, sorted by name. The empty tenant returns all the agents.
+func (s *sqliteStorage) GetAgents(ctx context.Context, tenant string) ([]common.AgentInfo, error) {
+	ctx, finish := s.startOperation(ctx, "GetAgents")
+	defer finish()
+
+	query := "SELECT name, tenant, version, commit_id, config_hash, updated_at FROM agents"
+	args := make([]interface{}, 0, 1)
+	if len(tenant) > 0 {
+		query += " WHERE tenant = ?"
+		args = append(args, tenant)
+	}
+	query += " ORDER BY name"
+
+	rows, err := s.readDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the agents: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	agents := make([]common.AgentInfo, 0)
+	for rows.Next() {
+		var info common.AgentInfo
+		err = rows.Scan(&info.Name, &info.Tenant, &info.Version, &info.Commit, &info.ConfigHash, &info.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		agents = append(agents, info)
+	}
+
+	return agents, rows.Err()
+}
@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteStorage_Agents(t *testing.T) {
+	t.Parallel()
+
+	s, err := NewSQLiteStorage(":memory:", 3600, SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = s.Close()
+	}()
+
+	ctx := context.Background()
+
+	agents, err := s.GetAgents(ctx, "")
+	require.NoError(t, err)
+	require.Empty(t, agents)
+
+	vm2 := common.AgentInfo{Name: "VM2", Version: "v1.0.0", Commit: "aaa", ConfigHash: "h1", UpdatedAt: 100}
+	require.NoError(t, s.SaveAgentInfo(ctx, vm2))
+	vm1 := common.AgentInfo{Name: "VM1", Version: "v1.0.0", Commit: "aaa", ConfigHash: "h1", UpdatedAt: 100}
+	require.NoError(t, s.SaveAgentInfo(ctx, vm1))
+	acme := common.AgentInfo{Name: "Acme", Tenant: "acme", Version: "v1.0.0", Commit: "aaa", ConfigHash: "h2", UpdatedAt: 100}
+	require.NoError(t, s.SaveAgentInfo(ctx, acme))
+
+	// the same details keep the update time
+	require.NoError(t, s.SaveAgentInfo(ctx, common.AgentInfo{Name: "VM2", Version: "v1.0.0", Commit: "aaa", ConfigHash: "h1", UpdatedAt: 150}))
+	// an upgrade replaces the details
+	vm1 = common.AgentInfo{Name: "VM1", Version: "v1.1.0", Commit: "bbb", ConfigHash: "h3", UpdatedAt: 200}
+	require.NoError(t, s.SaveAgentInfo(ctx, vm1))
+	// another tenant can not replace the details
+	require.NoError(t, s.SaveAgentInfo(ctx, common.AgentInfo{Name: "VM2", Tenant: "acme", Version: "v0.1.0", UpdatedAt: 300}))
+
+	agents, err = s.GetAgents(ctx, "")
+	require.NoError(t, err)
+	require.Equal(t, []common.AgentInfo{acme, vm1, vm2}, agents)
+
+	agents, err = s.GetAgents(ctx, "acme")
+	require.NoError(t, err)
+	require.Equal(t, []common.AgentInfo{acme}, agents)
+}
@@ -0,0 +1,283 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+)
+
+// alertEventSilenced is the history action recorded when the notifications of an alert are muted
+const alertEventSilenced = "silenced"
+
+const alertColumns = `id, metric_name, state, problem, started_at, fired_at, acknowledged_at, acknowledged_by,
+	resolved_at, silenced_until`
+
+// CreateAlert stores a new pending alert for a metric, returning its ID
+func (s *sqliteStorage) CreateAlert(ctx context.Context, metricName string, problem string, startedAt int64) (int64, error) {
+	ctx, finish := s.startOperation(ctx, "CreateAlert")
+	defer finish()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO alerts (metric_name, state, problem, started_at) VALUES (?, ?, ?, ?)
+	`, metricName, common.AlertStatePending, problem, startedAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert the alert: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	err = addAlertEvent(ctx, tx, id, string(common.AlertStatePending), "", problem, startedAt)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, tx.Commit()
+}
+
+// UpdateAlertState moves an alert to a new state, recording the transition in the alert history. The actor is the
+// user acknowledging the alert, empty for the automatic transitions.
+func (s *sqliteStorage) UpdateAlertState(ctx context.Context, id int64, state common.AlertState, actor string, note string, timestamp int64) error {
+	ctx, finish := s.startOperation(ctx, "UpdateAlertState")
+	defer finish()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	currentState, err := getAlertState(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if !currentState.CanTransitionTo(state) {
+		return fmt.Errorf("%w from %s to %s", common.ErrInvalidAlertTransition, currentState, state)
+	}
+
+	query := "UPDATE alerts SET state = ? WHERE id = ?"
+	args := []interface{}{state, id}
+	switch state {
+	case common.AlertStateFiring:
+		query = "UPDATE alerts SET state = ?, fired_at = ? WHERE id = ?"
+		args = []interface{}{state, timestamp, id}
+	case common.AlertStateAcknowledged:
+		query = "UPDATE alerts SET state = ?, acknowledged_at = ?, acknowledged_by = ? WHERE id = ?"
+		args = []interface{}{state, timestamp, actor, id}
+	case common.AlertStateResolved:
+		query = "UPDATE alerts SET state = ?, resolved_at = ? WHERE id = ?"
+		args = []interface{}{state, timestamp, id}
+	}
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update the alert: %w", err)
+	}
+
+	err = addAlertEvent(ctx, tx, id, string(state), actor, note, timestamp)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SilenceAlert mutes the notifications of an active alert until the provided timestamp
+func (s *sqliteStorage) SilenceAlert(ctx context.Context, id int64, until int64, actor string, note string, timestamp int64) error {
+	ctx, finish := s.startOperation(ctx, "SilenceAlert")
+	defer finish()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	currentState, err := getAlertState(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if !currentState.IsActive() {
+		return fmt.Errorf("%w: can not silence a %s alert", common.ErrInvalidAlertTransition, currentState)
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE alerts SET silenced_until = ? WHERE id = ?", until, id)
+	if err != nil {
+		return fmt.Errorf("failed to update the alert: %w", err)
+	}
+
+	err = addAlertEvent(ctx, tx, id, alertEventSilenced, actor, note, timestamp)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func getAlertState(ctx context.Context, tx *sql.Tx, id int64) (common.AlertState, error) {
+	var state common.AlertState
+	err := tx.QueryRowContext(ctx, "SELECT state FROM alerts WHERE id = ?", id).Scan(&state)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", common.ErrAlertNotFound
+	}
+
+	return state, err
+}
+
+func addAlertEvent(ctx context.Context, tx *sql.Tx, id int64, action string, actor string, note string, timestamp int64) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO alert_events (alert_id, action, note, actor, recorded_at) VALUES (?, ?, ?, ?, ?)
+	`, id, action, note, actor, timestamp)
+	if err != nil {
+		return fmt.Errorf("failed to record the alert event: %w", err)
+	}
+
+	return nil
+}
+
+// AddAlertEvent records an event in the history of an alert without changing its state
+func (s *sqliteStorage) AddAlertEvent(ctx context.Context, id int64, action string, actor string, note string, timestamp int64) error {
+	ctx, finish := s.startOperation(ctx, "AddAlertEvent")
+	defer finish()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	_, err = getAlertState(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	err = addAlertEvent(ctx, tx, id, action, actor, note, timestamp)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetActiveAlerts returns all the alerts that are not resolved yet
+func (s *sqliteStorage) GetActiveAlerts(ctx context.Context) ([]common.Alert, error) {
+	ctx, finish := s.startOperation(ctx, "GetActiveAlerts")
+	defer finish()
+
+	return s.queryAlerts(ctx, "SELECT "+alertColumns+" FROM alerts WHERE state <> ? ORDER BY id", common.AlertStateResolved)
+}
+
+// GetAlerts returns the alerts matching the filter, the most recent first
+func (s *sqliteStorage) GetAlerts(ctx context.Context, filter common.AlertsFilter) ([]common.Alert, error) {
+	ctx, finish := s.startOperation(ctx, "GetAlerts")
+	defer finish()
+
+	conditions := make([]string, 0, 4)
+	args := make([]interface{}, 0, 5)
+	if len(filter.State) > 0 {
+		conditions = append(conditions, "state = ?")
+		args = append(args, filter.State)
+	}
+	if len(filter.MetricName) > 0 {
+		conditions = append(conditions, "metric_name = ?")
+		args = append(args, filter.MetricName)
+	}
+	if filter.ActiveSince > 0 {
+		conditions = append(conditions, "(resolved_at = 0 OR resolved_at >= ?)")
+		args = append(args, filter.ActiveSince)
+	}
+	if len(filter.Tenant) > 0 {
+		conditions = append(conditions, "metric_name IN (SELECT name FROM metrics WHERE tenant = ?)")
+		args = append(args, filter.Tenant)
+	}
+
+	query := "SELECT " + alertColumns + " FROM alerts"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY started_at DESC, id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	return s.queryAlerts(ctx, query, args...)
+}
+
+// GetAlert returns an alert together with its history
+func (s *sqliteStorage) GetAlert(ctx context.Context, id int64) (*common.Alert, error) {
+	ctx, finish := s.startOperation(ctx, "GetAlert")
+	defer finish()
+
+	alerts, err := s.queryAlerts(ctx, "SELECT "+alertColumns+" FROM alerts WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(alerts) == 0 {
+		return nil, common.ErrAlertNotFound
+	}
+
+	alert := &alerts[0]
+	rows, err := s.readDB.QueryContext(ctx, `
+		SELECT action, note, actor, recorded_at FROM alert_events WHERE alert_id = ? ORDER BY recorded_at, id
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("alert events query failed: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	alert.Events = make([]common.AlertEvent, 0)
+	for rows.Next() {
+		var event common.AlertEvent
+		err = rows.Scan(&event.Action, &event.Note, &event.Actor, &event.RecordedAt)
+		if err != nil {
+			return nil, err
+		}
+		alert.Events = append(alert.Events, event)
+	}
+
+	return alert, rows.Err()
+}
+
+func (s *sqliteStorage) queryAlerts(ctx context.Context, query string, args ...interface{}) ([]common.Alert, error) {
+	rows, err := s.readDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("alerts query failed: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	alerts := make([]common.Alert, 0)
+	for rows.Next() {
+		var alert common.Alert
+		err = rows.Scan(&alert.ID, &alert.MetricName, &alert.State, &alert.Problem, &alert.StartedAt, &alert.FiredAt,
+			&alert.AcknowledgedAt, &alert.AcknowledgedBy, &alert.ResolvedAt, &alert.SilencedUntil)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, alert)
+	}
+
+	return alerts, rows.Err()
+}
@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteStorage_Alerts(t *testing.T) {
+	s, err := NewSQLiteStorage(":memory:", 3600, SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = s.Close()
+	}()
+
+	ctx := context.Background()
+
+	id1, err := s.CreateAlert(ctx, "VM1.Node1.nonce", "Host appears offline", 1000)
+	require.NoError(t, err)
+	id2, err := s.CreateAlert(ctx, "VM2.Node1.nonce", "Host appears offline", 1010)
+	require.NoError(t, err)
+
+	// invalid transitions
+	err = s.UpdateAlertState(ctx, id1, common.AlertStateAcknowledged, "admin", "", 1001)
+	require.True(t, errors.Is(err, common.ErrInvalidAlertTransition))
+	err = s.UpdateAlertState(ctx, 100, common.AlertStateFiring, "", "", 1001)
+	require.True(t, errors.Is(err, common.ErrAlertNotFound))
+
+	require.NoError(t, s.UpdateAlertState(ctx, id1, common.AlertStateFiring, "", "", 1002))
+	require.NoError(t, s.UpdateAlertState(ctx, id1, common.AlertStateAcknowledged, "admin", "looking into it", 1003))
+	require.NoError(t, s.SilenceAlert(ctx, id1, 5000, "admin", "node upgrade", 1004))
+
+	active, err := s.GetActiveAlerts(ctx)
+	require.NoError(t, err)
+	require.Len(t, active, 2)
+	require.Equal(t, id1, active[0].ID)
+	require.Equal(t, common.AlertStateAcknowledged, active[0].State)
+	require.Equal(t, common.AlertStatePending, active[1].State)
+
+	require.NoError(t, s.UpdateAlertState(ctx, id1, common.AlertStateResolved, "", "Host is back online", 1005))
+	err = s.SilenceAlert(ctx, id1, 6000, "admin", "", 1006)
+	require.True(t, errors.Is(err, common.ErrInvalidAlertTransition))
+	err = s.UpdateAlertState(ctx, id1, common.AlertStateResolved, "", "", 1006)
+	require.True(t, errors.Is(err, common.ErrInvalidAlertTransition))
+
+	alert, err := s.GetAlert(ctx, id1)
+	require.NoError(t, err)
+	require.Equal(t, common.Alert{
+		ID:             id1,
+		MetricName:     "VM1.Node1.nonce",
+		State:          common.AlertStateResolved,
+		Problem:        "Host appears offline",
+		StartedAt:      1000,
+		FiredAt:        1002,
+		AcknowledgedAt: 1003,
+		AcknowledgedBy: "admin",
+		ResolvedAt:     1005,
+		SilencedUntil:  5000,
+		Events: []common.AlertEvent{
+			{Action: "pending", Note: "Host appears offline", RecordedAt: 1000},
+			{Action: "firing", RecordedAt: 1002},
+			{Action: "acknowledged", Note: "looking into it", Actor: "admin", RecordedAt: 1003},
+			{Action: "silenced", Note: "node upgrade", Actor: "admin", RecordedAt: 1004},
+			{Action: "resolved", Note: "Host is back online", RecordedAt: 1005},
+		},
+	}, *alert)
+
+	_, err = s.GetAlert(ctx, 100)
+	require.True(t, errors.Is(err, common.ErrAlertNotFound))
+	err = s.AddAlertEvent(ctx, 100, "suppressed", "", "", 1007)
+	require.True(t, errors.Is(err, common.ErrAlertNotFound))
+	require.NoError(t, s.AddAlertEvent(ctx, id2, "suppressed", "", "firing notification suppressed", 1011))
+	alert, err = s.GetAlert(ctx, id2)
+	require.NoError(t, err)
+	require.Equal(t, common.AlertStatePending, alert.State)
+	require.Equal(t, common.AlertEvent{Action: "suppressed", Note: "firing notification suppressed", RecordedAt: 1011}, alert.Events[1])
+
+	alerts, err := s.GetAlerts(ctx, common.AlertsFilter{})
+	require.NoError(t, err)
+	require.Len(t, alerts, 2)
+	require.Equal(t, id2, alerts[0].ID)
+	require.Nil(t, alerts[0].Events)
+
+	alerts, err = s.GetAlerts(ctx, common.AlertsFilter{State: common.AlertStateResolved})
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	require.Equal(t, id1, alerts[0].ID)
+
+	alerts, err = s.GetAlerts(ctx, common.AlertsFilter{ActiveSince: 1006})
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	require.Equal(t, id2, alerts[0].ID)
+
+	alerts, err = s.GetAlerts(ctx, common.AlertsFilter{MetricName: "VM2.Node1.nonce", Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	require.Equal(t, id2, alerts[0].ID)
+
+	active, err = s.GetActiveAlerts(ctx)
+	require.NoError(t, err)
+	require.Len(t, active, 1)
+	require.Equal(t, id2, active[0].ID)
+}
@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/multiversx/mx-chain-core-go/core/check"
+)
+
+// archiveMetrics writes the full history of the metrics matching the condition to the archiver, if any, before they
+// are removed in the same transaction. A failing archival aborts the removal, retried by the next cleanup.
+func (s *sqliteStorage) archiveMetrics(ctx context.Context, tx *sql.Tx, condition string, args ...interface{}) error {
+	if check.IfNil(s.archiver) {
+		return nil
+	}
+
+	metrics, err := loadArchivedMetrics(ctx, tx, condition, args...)
+	if err != nil {
+		return fmt.Errorf("failed to load the metrics to archive: %w", err)
+	}
+
+	return s.archiver.Archive(common.ArchiveReasonDeleted, metrics)
+}
+
+// archiveExpiredValues writes the values recorded before the cutoff, together with the already removed packed ones, to
+// the archiver, if any, before they are removed in the same transaction
+func (s *sqliteStorage) archiveExpiredValues(ctx context.Context, tx *sql.Tx, cutoff int64, expiredChunks []common.ArchivedMetric) error {
+	if check.IfNil(s.archiver) {
+		return nil
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT m.name, m.type, m.tenant, v.value, v.recorded_at, v.last_seen_at
+		FROM metrics_values v
+		JOIN metrics m ON m.name = v.metric_name
+		WHERE v.recorded_at < ? AND v.last_seen_at < ?
+		ORDER BY m.name, v.recorded_at
+	`, cutoff, cutoff)
+	if err != nil {
+		return fmt.Errorf("failed to load the expired values: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var metrics []common.ArchivedMetric
+	for rows.Next() {
+		var metric common.ArchivedMetric
+		var value common.MetricValue
+		err = rows.Scan(&metric.Name, &metric.Type, &metric.Tenant, &value.Value, &value.RecordedAt, &value.LastSeenAt)
+		if err != nil {
+			return err
+		}
+
+		if len(metrics) == 0 || metrics[len(metrics)-1].Name != metric.Name {
+			metrics = append(metrics, metric)
+		}
+		last := &metrics[len(metrics)-1]
+		last.History = append(last.History, value)
+	}
+	err = rows.Err()
+	if err != nil {
+		return err
+	}
+
+	metrics = mergeArchivedMetrics(metrics, expiredChunks)
+
+	return s.archiver.Archive(common.ArchiveReasonExpired, metrics)
+}
+
+// loadArchivedMetrics returns the definition, the tags, the annotations and the values of the metrics matching the
+// condition on the metrics table, ordered by name
+func loadArchivedMetrics(ctx context.Context, tx *sql.Tx, condition string, args ...interface{}) ([]common.ArchivedMetric, error) {
+	metrics := make([]common.ArchivedMetric, 0)
+	indexes := make(map[string]int)
+	err := queryRows(ctx, tx, func(rows *sql.Rows) error {
+		var metric common.ArchivedMetric
+		errScan := rows.Scan(&metric.Name, &metric.Type, &metric.Tenant)
+		if errScan != nil {
+			return errScan
+		}
+		indexes[metric.Name] = len(metrics)
+		metrics = append(metrics, metric)
+		return nil
+	}, fmt.Sprintf("SELECT name, type, tenant FROM metrics WHERE %s ORDER BY name", condition), args...)
+	if err != nil || len(metrics) == 0 {
+		return metrics, err
+	}
+
+	selected := fmt.Sprintf("metric_name IN (SELECT name FROM metrics WHERE %s)", condition)
+	err = queryRows(ctx, tx, func(rows *sql.Rows) error {
+		var name string
+		var value common.MetricValue
+		errScan := rows.Scan(&name, &value.Value, &value.RecordedAt, &value.LastSeenAt)
+		if errScan != nil {
+			return errScan
+		}
+		metric := &metrics[indexes[name]]
+		metric.History = append(metric.History, value)
+		return nil
+	}, "SELECT metric_name, value, recorded_at, last_seen_at FROM metrics_values WHERE "+selected+" ORDER BY recorded_at", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	packed, err := loadChunkValues(ctx, tx, "SELECT metric_name, data FROM metric_value_chunks WHERE "+selected, args...)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range packed {
+		metric := &metrics[indexes[name]]
+		metric.History = mergeValues(metric.History, values)
+	}
+
+	err = queryRows(ctx, tx, func(rows *sql.Rows) error {
+		var name, key, value string
+		errScan := rows.Scan(&name, &key, &value)
+		if errScan != nil {
+			return errScan
+		}
+		metric := &metrics[indexes[name]]
+		if metric.Tags == nil {
+			metric.Tags = make(map[string]string)
+		}
+		metric.Tags[key] = value
+		return nil
+	}, "SELECT metric_name, tag_key, tag_value FROM metric_tags WHERE "+selected, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	err = queryRows(ctx, tx, func(rows *sql.Rows) error {
+		var name string
+		var annotation common.MetricAnnotation
+		errScan := rows.Scan(&name, &annotation.ID, &annotation.Text, &annotation.RecordedAt)
+		if errScan != nil {
+			return errScan
+		}
+		metric := &metrics[indexes[name]]
+		metric.Annotations = append(metric.Annotations, annotation)
+		return nil
+	}, "SELECT metric_name, id, text, recorded_at FROM metric_annotations WHERE "+selected+" ORDER BY recorded_at, id", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return metrics, nil
+}
+
+func queryRows(ctx context.Context, tx *sql.Tx, handler func(rows *sql.Rows) error, query string, args ...interface{}) error {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	for rows.Next() {
+		err = handler(rows)
+		if err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
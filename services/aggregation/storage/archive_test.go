@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/testsCommon"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteStorage_Archive(t *testing.T) {
+	t.Parallel()
+
+	var archiveErr error
+	archived := make(map[string][]common.ArchivedMetric)
+	archiver := &testsCommon.MetricsArchiverStub{
+		ArchiveHandler: func(reason string, metrics []common.ArchivedMetric) error {
+			if archiveErr != nil {
+				return archiveErr
+			}
+			archived[reason] = append(archived[reason], metrics...)
+			return nil
+		},
+	}
+	s, err := NewSQLiteStorage(":memory:", 3600, SQLiteTuning{Archiver: archiver})
+	require.NoError(t, err)
+	defer func() {
+		_ = s.Close()
+	}()
+
+	ctx := context.Background()
+	require.NoError(t, s.SaveMetrics(ctx, []common.MetricRecord{
+		{Name: "VM1.Node1.nonce", Type: common.MetricTypeUint64, NumAggregation: 5, Value: "10", RecordedAt: 1000, Tags: map[string]string{"vm": "VM1"}},
+		{Name: "VM1.Node1.nonce", Type: common.MetricTypeUint64, NumAggregation: 5, Value: "11", RecordedAt: 1030},
+		{Name: "VM1.Node1.nonce", Type: common.MetricTypeUint64, NumAggregation: 5, Value: "12", RecordedAt: 1060},
+		{Name: "VM2.Active", Type: common.MetricTypeBool, NumAggregation: 5, Value: "true", RecordedAt: 1000, Tenant: "acme"},
+		{Name: "VM2.Active", Type: common.MetricTypeBool, NumAggregation: 5, Value: "false", RecordedAt: 1060, Tenant: "acme"},
+	}))
+	annotationID, err := s.AddMetricAnnotation(ctx, "VM1.Node1.nonce", "upgraded", 1010)
+	require.NoError(t, err)
+
+	t.Run("the expired values are archived before their removal", func(t *testing.T) {
+		require.NoError(t, s.removeExpiredValues(ctx, 1030))
+		require.Equal(t, []common.ArchivedMetric{
+			{Name: "VM1.Node1.nonce", Type: common.MetricTypeUint64, History: []common.MetricValue{{Value: "10", RecordedAt: 1000}}},
+			{Name: "VM2.Active", Type: common.MetricTypeBool, Tenant: "acme", History: []common.MetricValue{{Value: "true", RecordedAt: 1000}}},
+		}, archived[common.ArchiveReasonExpired])
+
+		hist, errGet := s.GetMetricHistory(ctx, "VM1.Node1.nonce")
+		require.NoError(t, errGet)
+		require.Len(t, hist.History, 2)
+	})
+	t.Run("a failing archival aborts the removal", func(t *testing.T) {
+		archiveErr = errors.New("expected error")
+		defer func() {
+			archiveErr = nil
+		}()
+
+		require.ErrorIs(t, s.removeExpiredValues(ctx, 1100), archiveErr)
+		_, errDelete := s.DeleteMetricsByPrefix(ctx, "VM1.")
+		require.ErrorIs(t, errDelete, archiveErr)
+
+		hist, errGet := s.GetMetricHistory(ctx, "VM1.Node1.nonce")
+		require.NoError(t, errGet)
+		require.Len(t, hist.History, 2)
+	})
+	t.Run("the metrics deleted by prefix are archived", func(t *testing.T) {
+		numDeleted, errDelete := s.DeleteMetricsByPrefix(ctx, "VM1.")
+		require.NoError(t, errDelete)
+		require.Equal(t, int64(1), numDeleted)
+		require.Equal(t, []common.ArchivedMetric{
+			{
+				Name:        "VM1.Node1.nonce",
+				Type:        common.MetricTypeUint64,
+				Tags:        map[string]string{"vm": "VM1"},
+				Annotations: []common.MetricAnnotation{{ID: annotationID, Text: "upgraded", RecordedAt: 1010}},
+				History:     []common.MetricValue{{Value: "11", RecordedAt: 1030}, {Value: "12", RecordedAt: 1060}},
+			},
+		}, archived[common.ArchiveReasonDeleted])
+	})
+	t.Run("the purged metrics are archived", func(t *testing.T) {
+		require.NoError(t, s.DeleteMetric(ctx, "VM2.Active"))
+		require.NoError(t, s.purgeTrashedMetrics(ctx, 0))
+		require.Len(t, archived[common.ArchiveReasonDeleted], 1)
+
+		require.NoError(t, s.purgeTrashedMetrics(ctx, 1<<62))
+		require.Len(t, archived[common.ArchiveReasonDeleted], 2)
+		require.Equal(t, "VM2.Active", archived[common.ArchiveReasonDeleted][1].Name)
+		require.Equal(t, "acme", archived[common.ArchiveReasonDeleted][1].Tenant)
+
+		trashed, errGet := s.GetTrashedMetrics(ctx, "")
+		require.NoError(t, errGet)
+		require.Empty(t, trashed)
+	})
+}
@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+)
+
+// GetAvailabilityBuckets returns the availability buckets starting at or after the provided timestamp, sorted by
+// metric name and bucket start
+func (s *sqliteStorage) GetAvailabilityBuckets(ctx context.Context, since int64) ([]common.AvailabilityBucket, error) {
+	ctx, finish := s.startOperation(ctx, "GetAvailabilityBuckets")
+	defer finish()
+
+	rows, err := s.readDB.QueryContext(ctx, `
+		SELECT metric_name, bucket_start, num_samples, num_success FROM metric_availability
+		WHERE bucket_start >= ?
+		  AND metric_name IN (SELECT name FROM metrics WHERE deleted_at = 0)
+		ORDER BY metric_name, bucket_start
+	`, since)
+	if err != nil {
+		return nil, fmt.Errorf("availability query failed: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	buckets := make([]common.AvailabilityBucket, 0)
+	for rows.Next() {
+		var bucket common.AvailabilityBucket
+		err = rows.Scan(&bucket.MetricName, &bucket.BucketStart, &bucket.NumSamples, &bucket.NumSuccess)
+		if err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, bucket)
+	}
+
+	return buckets, rows.Err()
+}
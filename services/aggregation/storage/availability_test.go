@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteStorage_GetAvailabilityBuckets(t *testing.T) {
+	s, err := NewSQLiteStorage(":memory:", 3600, SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = s.Close()
+	}()
+
+	ctx := context.Background()
+
+	require.NoError(t, s.SaveMetric(ctx, "VM1.Active", common.MetricTypeBool, 1, "true", 3000))
+	require.NoError(t, s.SaveMetric(ctx, "VM1.Active", common.MetricTypeBool, 1, "false", 3010))
+	require.NoError(t, s.SaveMetric(ctx, "VM1.Active", common.MetricTypeBool, 1, "false", 3300))
+	require.NoError(t, s.SaveMetric(ctx, "VM1.Node1.nonce", common.MetricTypeUint64, 1, "10", 2990))
+
+	buckets, err := s.GetAvailabilityBuckets(ctx, 0)
+	require.NoError(t, err)
+	require.Equal(t, []common.AvailabilityBucket{
+		{MetricName: "VM1.Active", BucketStart: 3000, NumSamples: 2, NumSuccess: 1},
+		{MetricName: "VM1.Active", BucketStart: 3300, NumSamples: 1, NumSuccess: 0},
+		{MetricName: "VM1.Node1.nonce", BucketStart: 2700, NumSamples: 1, NumSuccess: 1},
+	}, buckets)
+
+	// the availability survives the values trimming and follows the metric renames
+	require.NoError(t, s.RenameMetric(ctx, "VM1.Node1.nonce", "VM1.Node1.erd_nonce"))
+	buckets, err = s.GetAvailabilityBuckets(ctx, 3000)
+	require.NoError(t, err)
+	require.Len(t, buckets, 2)
+
+	require.NoError(t, s.DeleteMetric(ctx, "VM1.Active"))
+	buckets, err = s.GetAvailabilityBuckets(ctx, 0)
+	require.NoError(t, err)
+	require.Equal(t, []common.AvailabilityBucket{
+		{MetricName: "VM1.Node1.erd_nonce", BucketStart: 2700, NumSamples: 1, NumSuccess: 1},
+	}, buckets)
+}
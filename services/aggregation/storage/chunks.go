@@ -0,0 +1,528 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+)
+
+// valuesPerChunk is the number of values packed in a chunk. The values of a compressed metric are packed once it holds
+// twice as many raw values, so its latest values are always stored raw.
+const valuesPerChunk = 256
+
+// the encodings of the chunk values, chosen as the most compact one fitting all the values of the chunk
+const (
+	chunkEncodingInteger byte = 1 // zig-zag varint deltas
+	chunkEncodingFloat   byte = 2 // varint of the XOR with the previous value bits
+	chunkEncodingText    byte = 3 // length prefixed strings
+)
+
+// queryer is implemented by both the database and the transactions
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// valueChunk is a row of the metric_value_chunks table, holding delta encoded values of a metric together with their
+// numeric aggregates, so the aggregated values do not require decoding the chunks
+type valueChunk struct {
+	firstRecordedAt int64
+	lastSeenAt      int64
+	numValues       int
+	numNumeric      int
+	sum             sql.NullFloat64
+	min             sql.NullFloat64
+	max             sql.NullFloat64
+	data            []byte
+}
+
+// newValueChunk encodes the values, sorted ascending by their recorded timestamp, and computes their aggregates
+func newValueChunk(metricType string, values []common.MetricValue) valueChunk {
+	chunk := valueChunk{
+		firstRecordedAt: values[0].RecordedAt,
+		numValues:       len(values),
+		data:            encodeChunk(values),
+	}
+	for _, value := range values {
+		chunk.lastSeenAt = max(chunk.lastSeenAt, value.RecordedAt, value.LastSeenAt)
+
+		numeric, isNumeric := floatValue(numericValue(metricType, value.Value))
+		if !isNumeric {
+			continue
+		}
+		if chunk.numNumeric == 0 {
+			chunk.min = sql.NullFloat64{Float64: numeric, Valid: true}
+			chunk.max = sql.NullFloat64{Float64: numeric, Valid: true}
+		}
+		chunk.numNumeric++
+		chunk.sum = sql.NullFloat64{Float64: chunk.sum.Float64 + numeric, Valid: true}
+		chunk.min.Float64 = math.Min(chunk.min.Float64, numeric)
+		chunk.max.Float64 = math.Max(chunk.max.Float64, numeric)
+	}
+
+	return chunk
+}
+
+func floatValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+// encodeChunk delta encodes the timestamps and the values. Each value is followed by the number of seconds it was
+// seen after being recorded, for the metrics stored on change.
+func encodeChunk(values []common.MetricValue) []byte {
+	encoding := chunkValuesEncoding(values)
+	data := []byte{encoding}
+	data = binary.AppendUvarint(data, uint64(len(values)))
+
+	previousRecordedAt := int64(0)
+	for _, value := range values {
+		data = binary.AppendVarint(data, value.RecordedAt-previousRecordedAt)
+		data = binary.AppendUvarint(data, uint64(max(value.LastSeenAt-value.RecordedAt, 0)))
+		previousRecordedAt = value.RecordedAt
+	}
+
+	previousInteger := int64(0)
+	previousBits := uint64(0)
+	for _, value := range values {
+		switch encoding {
+		case chunkEncodingInteger:
+			integer, _ := strconv.ParseInt(value.Value, 10, 64)
+			data = binary.AppendVarint(data, integer-previousInteger)
+			previousInteger = integer
+		case chunkEncodingFloat:
+			numeric, _ := strconv.ParseFloat(value.Value, 64)
+			bits := math.Float64bits(numeric)
+			data = binary.AppendUvarint(data, bits^previousBits)
+			previousBits = bits
+		default:
+			data = binary.AppendUvarint(data, uint64(len(value.Value)))
+			data = append(data, value.Value...)
+		}
+	}
+
+	return data
+}
+
+// chunkValuesEncoding returns the numeric encodings only if all the values are restored identically from them
+func chunkValuesEncoding(values []common.MetricValue) byte {
+	isInteger, isFloat := true, true
+	for _, value := range values {
+		integer, err := strconv.ParseInt(value.Value, 10, 64)
+		isInteger = isInteger && err == nil && strconv.FormatInt(integer, 10) == value.Value
+		numeric, err := strconv.ParseFloat(value.Value, 64)
+		isFloat = isFloat && err == nil && formatFloat(numeric) == value.Value
+	}
+
+	switch {
+	case isInteger:
+		return chunkEncodingInteger
+	case isFloat:
+		return chunkEncodingFloat
+	default:
+		return chunkEncodingText
+	}
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// decodeChunk restores the values encoded by encodeChunk
+func decodeChunk(data []byte) ([]common.MetricValue, error) {
+	if len(data) == 0 {
+		return nil, errInvalidChunk
+	}
+	reader := &chunkReader{data: data[1:]}
+	encoding := data[0]
+	numValues := reader.uvarint()
+	if reader.err != nil || numValues > uint64(len(data)) {
+		return nil, errInvalidChunk
+	}
+
+	values := make([]common.MetricValue, numValues)
+	recordedAt := int64(0)
+	for i := range values {
+		recordedAt += reader.varint()
+		values[i].RecordedAt = recordedAt
+		seenAfter := int64(reader.uvarint())
+		if seenAfter > 0 {
+			values[i].LastSeenAt = recordedAt + seenAfter
+		}
+	}
+
+	integer := int64(0)
+	bits := uint64(0)
+	for i := range values {
+		switch encoding {
+		case chunkEncodingInteger:
+			integer += reader.varint()
+			values[i].Value = strconv.FormatInt(integer, 10)
+		case chunkEncodingFloat:
+			bits ^= reader.uvarint()
+			values[i].Value = formatFloat(math.Float64frombits(bits))
+		case chunkEncodingText:
+			values[i].Value = string(reader.bytes(reader.uvarint()))
+		default:
+			return nil, fmt.Errorf("%w: unknown encoding %d", errInvalidChunk, encoding)
+		}
+	}
+	if reader.err != nil {
+		return nil, reader.err
+	}
+
+	return values, nil
+}
+
+// chunkReader reads the varints of a chunk, the first failure being kept in err
+type chunkReader struct {
+	data []byte
+	err  error
+}
+
+func (reader *chunkReader) uvarint() uint64 {
+	value, n := binary.Uvarint(reader.data)
+	if n <= 0 {
+		reader.err = errInvalidChunk
+		return 0
+	}
+	reader.data = reader.data[n:]
+
+	return value
+}
+
+func (reader *chunkReader) varint() int64 {
+	value, n := binary.Varint(reader.data)
+	if n <= 0 {
+		reader.err = errInvalidChunk
+		return 0
+	}
+	reader.data = reader.data[n:]
+
+	return value
+}
+
+func (reader *chunkReader) bytes(length uint64) []byte {
+	if length > uint64(len(reader.data)) {
+		reader.err = errInvalidChunk
+		return nil
+	}
+	value := reader.data[:length]
+	reader.data = reader.data[length:]
+
+	return value
+}
+
+func insertValueChunk(ctx context.Context, tx *sql.Tx, name string, chunk valueChunk) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO metric_value_chunks (metric_name, first_recorded_at, last_seen_at, num_values, num_numeric, sum_value, min_value, max_value, data)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, name, chunk.firstRecordedAt, chunk.lastSeenAt, chunk.numValues, chunk.numNumeric, chunk.sum, chunk.min, chunk.max, chunk.data)
+	if err != nil {
+		return fmt.Errorf("failed to insert the value chunk: %w", err)
+	}
+
+	return nil
+}
+
+// replaceValueChunk replaces the values of a chunk, the chunk being removed if none is left
+func replaceValueChunk(ctx context.Context, tx *sql.Tx, rowID int64, metricType string, values []common.MetricValue) error {
+	if len(values) == 0 {
+		_, err := tx.ExecContext(ctx, "DELETE FROM metric_value_chunks WHERE rowid = ?", rowID)
+		return err
+	}
+
+	chunk := newValueChunk(metricType, values)
+	_, err := tx.ExecContext(ctx, `
+		UPDATE metric_value_chunks
+		SET first_recorded_at = ?, last_seen_at = ?, num_values = ?, num_numeric = ?, sum_value = ?, min_value = ?, max_value = ?, data = ?
+		WHERE rowid = ?
+	`, chunk.firstRecordedAt, chunk.lastSeenAt, chunk.numValues, chunk.numNumeric, chunk.sum, chunk.min, chunk.max, chunk.data, rowID)
+	if err != nil {
+		return fmt.Errorf("failed to update the value chunk: %w", err)
+	}
+
+	return nil
+}
+
+// packValues moves the oldest raw values of a compressed metric in a chunk once the metric holds at least two chunks
+// worth of raw values
+func packValues(ctx context.Context, tx *sql.Tx, name string, metricType string) error {
+	var numRaw int
+	err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM metrics_values WHERE metric_name = ?", name).Scan(&numRaw)
+	if err != nil {
+		return fmt.Errorf("failed to count the raw values: %w", err)
+	}
+	if numRaw < 2*valuesPerChunk {
+		return nil
+	}
+
+	values := make([]common.MetricValue, 0, valuesPerChunk)
+	err = queryRows(ctx, tx, func(rows *sql.Rows) error {
+		var value common.MetricValue
+		errScan := rows.Scan(&value.Value, &value.RecordedAt, &value.LastSeenAt)
+		values = append(values, value)
+		return errScan
+	}, "SELECT value, recorded_at, last_seen_at FROM metrics_values WHERE metric_name = ? ORDER BY recorded_at, rowid LIMIT ?", name, valuesPerChunk)
+	if err != nil {
+		return fmt.Errorf("failed to load the values to pack: %w", err)
+	}
+
+	err = insertValueChunk(ctx, tx, name, newValueChunk(metricType, values))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM metrics_values
+		WHERE rowid IN (
+			SELECT rowid FROM metrics_values
+			WHERE metric_name = ?
+			ORDER BY recorded_at, rowid
+			LIMIT ?
+		)
+	`, name, valuesPerChunk)
+	if err != nil {
+		return fmt.Errorf("failed to delete the packed values: %w", err)
+	}
+
+	return nil
+}
+
+// trimValueChunks removes the oldest packed values of a compressed metric exceeding its aggregation window, together
+// with the raw values. The chunks only hold values older than the raw ones.
+func trimValueChunks(ctx context.Context, tx *sql.Tx, name string, metricType string, numAggregation int) error {
+	var numRaw, numPacked int
+	err := tx.QueryRowContext(ctx, `
+		SELECT (SELECT COUNT(*) FROM metrics_values WHERE metric_name = ?),
+			(SELECT COALESCE(SUM(num_values), 0) FROM metric_value_chunks WHERE metric_name = ?)
+	`, name, name).Scan(&numRaw, &numPacked)
+	if err != nil {
+		return fmt.Errorf("failed to count the metric values: %w", err)
+	}
+
+	excess := numRaw + numPacked - numAggregation
+	if numPacked == 0 || excess <= 0 {
+		return nil
+	}
+	if numRaw >= numAggregation {
+		_, err = tx.ExecContext(ctx, "DELETE FROM metric_value_chunks WHERE metric_name = ?", name)
+		return err
+	}
+
+	type chunkRow struct {
+		rowID     int64
+		numValues int
+	}
+	chunks := make([]chunkRow, 0)
+	err = queryRows(ctx, tx, func(rows *sql.Rows) error {
+		var chunk chunkRow
+		errScan := rows.Scan(&chunk.rowID, &chunk.numValues)
+		chunks = append(chunks, chunk)
+		return errScan
+	}, "SELECT rowid, num_values FROM metric_value_chunks WHERE metric_name = ? ORDER BY first_recorded_at", name)
+	if err != nil {
+		return err
+	}
+
+	for _, chunk := range chunks {
+		if excess <= 0 {
+			return nil
+		}
+		if chunk.numValues <= excess {
+			_, err = tx.ExecContext(ctx, "DELETE FROM metric_value_chunks WHERE rowid = ?", chunk.rowID)
+			if err != nil {
+				return err
+			}
+			excess -= chunk.numValues
+			continue
+		}
+
+		var data []byte
+		err = tx.QueryRowContext(ctx, "SELECT data FROM metric_value_chunks WHERE rowid = ?", chunk.rowID).Scan(&data)
+		if err != nil {
+			return err
+		}
+		values, errDecode := decodeChunk(data)
+		if errDecode != nil {
+			return fmt.Errorf("%w for metric %s", errDecode, name)
+		}
+
+		return replaceValueChunk(ctx, tx, chunk.rowID, metricType, values[excess:])
+	}
+
+	return nil
+}
+
+// unpackValues moves back the packed values of a metric in the raw values
+func unpackValues(ctx context.Context, tx *sql.Tx, name string, metricType string) error {
+	values, err := loadChunkValues(ctx, tx, "SELECT metric_name, data FROM metric_value_chunks WHERE metric_name = ?", name)
+	if err != nil {
+		return err
+	}
+
+	for _, value := range values[name] {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO metrics_values (metric_name, value, value_num, recorded_at, received_at, last_seen_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, name, value.Value, numericValue(metricType, value.Value), value.RecordedAt, value.RecordedAt, value.LastSeenAt)
+		if err != nil {
+			return fmt.Errorf("failed to unpack the metric value: %w", err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM metric_value_chunks WHERE metric_name = ?", name)
+	return err
+}
+
+// loadChunkValues decodes the chunks returned by the query, selecting the metric name and the chunk data, and returns
+// their values grouped by metric name, sorted ascending by their recorded timestamp
+func loadChunkValues(ctx context.Context, q queryer, query string, args ...interface{}) (map[string][]common.MetricValue, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("chunks query failed: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	values := make(map[string][]common.MetricValue)
+	for rows.Next() {
+		var name string
+		var data []byte
+		err = rows.Scan(&name, &data)
+		if err != nil {
+			return nil, err
+		}
+
+		decoded, errDecode := decodeChunk(data)
+		if errDecode != nil {
+			return nil, fmt.Errorf("%w for metric %s", errDecode, name)
+		}
+		values[name] = append(values[name], decoded...)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	for name := range values {
+		sortValues(values[name])
+	}
+
+	return values, nil
+}
+
+// mergeValues returns the raw and the packed values of a metric sorted ascending by their recorded timestamp
+func mergeValues(raw []common.MetricValue, packed []common.MetricValue) []common.MetricValue {
+	if len(packed) == 0 {
+		return raw
+	}
+
+	merged := make([]common.MetricValue, 0, len(packed)+len(raw))
+	merged = append(merged, packed...)
+	merged = append(merged, raw...)
+	sortValues(merged)
+
+	return merged
+}
+
+func sortValues(values []common.MetricValue) {
+	sort.SliceStable(values, func(i, j int) bool {
+		return values[i].RecordedAt < values[j].RecordedAt
+	})
+}
+
+// removeExpiredChunks removes the packed values last seen before the cutoff and returns them grouped by metric. The
+// chunks partially expired are rewritten with their retained values.
+func removeExpiredChunks(ctx context.Context, tx *sql.Tx, cutoff int64) ([]common.ArchivedMetric, error) {
+	type expiredChunk struct {
+		rowID  int64
+		metric common.ArchivedMetric
+		data   []byte
+	}
+	chunks := make([]expiredChunk, 0)
+	err := queryRows(ctx, tx, func(rows *sql.Rows) error {
+		var chunk expiredChunk
+		errScan := rows.Scan(&chunk.rowID, &chunk.metric.Name, &chunk.metric.Type, &chunk.metric.Tenant, &chunk.data)
+		chunks = append(chunks, chunk)
+		return errScan
+	}, `
+		SELECT c.rowid, m.name, m.type, m.tenant, c.data
+		FROM metric_value_chunks c
+		JOIN metrics m ON m.name = c.metric_name
+		WHERE c.first_recorded_at < ?
+		ORDER BY m.name, c.first_recorded_at
+	`, cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the expired chunks: %w", err)
+	}
+
+	var metrics []common.ArchivedMetric
+	for _, chunk := range chunks {
+		values, errDecode := decodeChunk(chunk.data)
+		if errDecode != nil {
+			return nil, fmt.Errorf("%w for metric %s", errDecode, chunk.metric.Name)
+		}
+
+		retained := make([]common.MetricValue, 0, len(values))
+		for _, value := range values {
+			if value.RecordedAt >= cutoff || value.LastSeenAt >= cutoff {
+				retained = append(retained, value)
+				continue
+			}
+			if len(metrics) == 0 || metrics[len(metrics)-1].Name != chunk.metric.Name {
+				metrics = append(metrics, chunk.metric)
+			}
+			last := &metrics[len(metrics)-1]
+			last.History = append(last.History, value)
+		}
+		if len(retained) == len(values) {
+			continue
+		}
+
+		err = replaceValueChunk(ctx, tx, chunk.rowID, chunk.metric.Type, retained)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return metrics, nil
+}
+
+// mergeArchivedMetrics adds the packed values to the archived metrics, sorted by name
+func mergeArchivedMetrics(metrics []common.ArchivedMetric, packed []common.ArchivedMetric) []common.ArchivedMetric {
+	if len(packed) == 0 {
+		return metrics
+	}
+
+	indexes := make(map[string]int, len(metrics))
+	for i, metric := range metrics {
+		indexes[metric.Name] = i
+	}
+	for _, metric := range packed {
+		index, found := indexes[metric.Name]
+		if !found {
+			indexes[metric.Name] = len(metrics)
+			metrics = append(metrics, metric)
+			continue
+		}
+		metrics[index].History = mergeValues(metrics[index].History, metric.History)
+	}
+	sort.SliceStable(metrics, func(i, j int) bool {
+		return metrics[i].Name < metrics[j].Name
+	})
+
+	return metrics
+}
@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeChunk(t *testing.T) {
+	t.Parallel()
+
+	t.Run("the values should be restored", func(t *testing.T) {
+		t.Parallel()
+
+		testCases := map[byte][]common.MetricValue{
+			chunkEncodingInteger: {{Value: "100", RecordedAt: 1000}, {Value: "-5", RecordedAt: 1006, LastSeenAt: 1030}, {Value: "9223372036854775807", RecordedAt: 1031}},
+			chunkEncodingFloat:   {{Value: "1.5", RecordedAt: 1000}, {Value: "2", RecordedAt: 1006}, {Value: "-0.25", RecordedAt: 1012}},
+			chunkEncodingText:    {{Value: "v1.0.0", RecordedAt: 1000}, {Value: "", RecordedAt: 1006}, {Value: "1.50", RecordedAt: 1012}},
+		}
+		for encoding, values := range testCases {
+			data := encodeChunk(values)
+			assert.Equal(t, encoding, data[0])
+
+			decoded, err := decodeChunk(data)
+			require.NoError(t, err)
+			assert.Equal(t, values, decoded)
+		}
+	})
+	t.Run("the regular values should be compact", func(t *testing.T) {
+		t.Parallel()
+
+		values := make([]common.MetricValue, valuesPerChunk)
+		for i := range values {
+			values[i] = common.MetricValue{Value: strconv.Itoa(12345600 + i), RecordedAt: 1700000000 + int64(i)*6}
+		}
+		// the first value and timestamp, then a byte for each timestamp delta, last seen and value delta
+		assert.Less(t, len(encodeChunk(values)), 3*valuesPerChunk+16)
+	})
+	t.Run("the invalid data should error", func(t *testing.T) {
+		t.Parallel()
+
+		data := encodeChunk([]common.MetricValue{{Value: "abc", RecordedAt: 1000}})
+		for _, invalid := range [][]byte{nil, {chunkEncodingText}, data[:len(data)-1], append([]byte{9}, data[1:]...)} {
+			_, err := decodeChunk(invalid)
+			assert.ErrorIs(t, err, errInvalidChunk)
+		}
+	})
+}
+
+func TestSQLiteStorage_UpdateMetricCompression(t *testing.T) {
+	t.Parallel()
+
+	s, err := NewSQLiteStorage(":memory:", 3600, SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = s.Close()
+	}()
+
+	ctx := context.Background()
+	name := "VM1.nonce"
+	numAggregation := 3 * valuesPerChunk
+	start := time.Now().Unix() - 2*int64(numAggregation)
+	save := func(from int, to int) {
+		for i := from; i < to; i++ {
+			require.NoError(t, s.SaveMetric(ctx, name, common.MetricTypeUint64, numAggregation, strconv.Itoa(i), start+int64(i)))
+		}
+	}
+	countRows := func(table string) int {
+		var numRows int
+		require.NoError(t, s.db.QueryRow("SELECT COUNT(*) FROM "+table+" WHERE metric_name = ?", name).Scan(&numRows))
+		return numRows
+	}
+	checkHistory := func(from int, to int) {
+		hist, errHistory := s.GetMetricHistory(ctx, name)
+		require.NoError(t, errHistory)
+		require.Len(t, hist.History, to-from)
+		for i, value := range hist.History {
+			require.Equal(t, strconv.Itoa(from+i), value.Value)
+			require.Equal(t, start+int64(from+i), value.RecordedAt)
+		}
+	}
+
+	save(0, 1)
+	require.NoError(t, s.UpdateMetricCompression(ctx, name, true))
+	require.NoError(t, s.UpdateMetricAggregationMode(ctx, name, common.AggregationModeAvg))
+
+	// the oldest values are packed, the latest ones are kept raw, the window being trimmed from the oldest chunk
+	save(1, numAggregation+10)
+	require.Equal(t, 2, countRows("metric_value_chunks"))
+	require.Equal(t, numAggregation+10-2*valuesPerChunk, countRows("metrics_values"))
+	checkHistory(10, numAggregation+10)
+
+	latest, err := s.GetLatestMetricsFiltered(ctx, common.MetricsFilter{Name: name})
+	require.NoError(t, err)
+	require.True(t, latest[0].Compressed)
+	require.Equal(t, strconv.Itoa(numAggregation+9), latest[0].History[0].Value)
+	expectedAvg := strconv.FormatFloat(float64(10+numAggregation+9)/2, 'f', -1, 64)
+	require.Equal(t, expectedAvg, latest[0].AggregatedValue)
+	s.latest.invalidate()
+	latest, err = s.GetLatestMetricsFiltered(ctx, common.MetricsFilter{Name: name})
+	require.NoError(t, err)
+	require.Equal(t, expectedAvg, latest[0].AggregatedValue)
+
+	histories, err := s.GetMetricsHistoriesFiltered(ctx, common.MetricsFilter{Name: name})
+	require.NoError(t, err)
+	require.Len(t, histories[0].History, numAggregation)
+
+	stats, err := s.GetMetricStats(ctx, name, start+10, start+19)
+	require.NoError(t, err)
+	require.Equal(t, 10, stats.NumValues)
+	require.Equal(t, 10.0, *stats.Min)
+	require.Equal(t, 14.0, *stats.P50)
+	require.Equal(t, 19.0, *stats.Max)
+
+	// the retention removes the expired part of a chunk
+	require.NoError(t, s.removeExpiredValues(ctx, start+100))
+	checkHistory(100, numAggregation+10)
+	hist, err := s.GetMetricHistorySince(ctx, name, start+int64(numAggregation))
+	require.NoError(t, err)
+	require.Len(t, hist.History, 9)
+
+	require.NoError(t, s.UpdateMetricCompression(ctx, name, false))
+	require.Zero(t, countRows("metric_value_chunks"))
+	checkHistory(100, numAggregation+10)
+}
@@ -0,0 +1,7 @@
+package storage
+
+import "errors"
+
+var errEmptyPrefix = errors.New("empty prefix")
+
+var errInvalidChunk = errors.New("invalid value chunk")
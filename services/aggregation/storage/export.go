@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+)
+
+// StreamMetricValues calls the handler, metric after metric in the provided order, for each value recorded between
+// from and to, inclusive, in chronological order. The raw values are read from the database cursor and only the packed
+// values of the metric being exported are held in memory, so exporting millions of values does not load them all. A
+// handler error stops the export and is returned. The export lasts as long as the client takes to receive it, so it
+// is bound by the request context instead of the storage operations timeout.
+func (s *sqliteStorage) StreamMetricValues(ctx context.Context, names []string, from int64, to int64, handler func(value common.ExportedValue) error) error {
+	for _, name := range names {
+		err := s.streamMetricValues(ctx, name, from, to, handler)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *sqliteStorage) streamMetricValues(ctx context.Context, name string, from int64, to int64, handler func(value common.ExportedValue) error) error {
+	packed, err := loadChunkValues(ctx, s.readDB, `
+		SELECT metric_name, data FROM metric_value_chunks
+		WHERE metric_name = ? AND first_recorded_at <= ? AND last_seen_at >= ?
+	`, name, to, from)
+	if err != nil {
+		return err
+	}
+	packedInRange := make([]common.MetricValue, 0, len(packed[name]))
+	for _, value := range packed[name] {
+		if value.RecordedAt >= from && value.RecordedAt <= to {
+			packedInRange = append(packedInRange, value)
+		}
+	}
+
+	rows, err := s.readDB.QueryContext(ctx, `
+		SELECT m.type, v.value, v.recorded_at, v.last_seen_at
+		FROM metrics_values v
+		JOIN metrics m ON m.name = v.metric_name
+		WHERE v.metric_name = ? AND m.deleted_at = 0 AND v.recorded_at >= ? AND v.recorded_at <= ?
+		ORDER BY v.recorded_at
+	`, name, from, to)
+	if err != nil {
+		return fmt.Errorf("export query failed: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var metricType string
+	exportValue := func(value common.MetricValue) error {
+		return handler(common.ExportedValue{
+			Name:       name,
+			Type:       metricType,
+			Value:      value.Value,
+			RecordedAt: value.RecordedAt,
+			LastSeenAt: value.LastSeenAt,
+		})
+	}
+
+	// the raw and the packed values are merged in chronological order
+	for rows.Next() {
+		var value common.MetricValue
+		err = rows.Scan(&metricType, &value.Value, &value.RecordedAt, &value.LastSeenAt)
+		if err != nil {
+			return err
+		}
+
+		for len(packedInRange) > 0 && packedInRange[0].RecordedAt <= value.RecordedAt {
+			err = exportValue(packedInRange[0])
+			if err != nil {
+				return err
+			}
+			packedInRange = packedInRange[1:]
+		}
+
+		err = exportValue(value)
+		if err != nil {
+			return err
+		}
+	}
+	err = rows.Err()
+	if err != nil {
+		return err
+	}
+	if len(packedInRange) == 0 {
+		return nil
+	}
+
+	// the packed values of a metric without raw values in the range
+	if len(metricType) == 0 {
+		err = s.readDB.QueryRowContext(ctx, "SELECT type FROM metrics WHERE name = ? AND deleted_at = 0", name).Scan(&metricType)
+		if errors.Is(err, sql.ErrNoRows) {
+			// the metric was deleted meanwhile
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, value := range packedInRange {
+		err = exportValue(value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
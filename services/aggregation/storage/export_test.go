@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteStorage_StreamMetricValues(t *testing.T) {
+	t.Parallel()
+
+	s, err := NewSQLiteStorage(":memory:", 3600, SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = s.Close()
+	}()
+
+	ctx := context.Background()
+	numValues := 3 * valuesPerChunk
+	start := s.clock.Now().Unix() - int64(numValues)
+	require.NoError(t, s.SaveMetric(ctx, "VM1.nonce", common.MetricTypeUint64, numValues, "0", start))
+	require.NoError(t, s.UpdateMetricCompression(ctx, "VM1.nonce", true))
+	for i := 1; i < numValues; i++ {
+		require.NoError(t, s.SaveMetric(ctx, "VM1.nonce", common.MetricTypeUint64, numValues, strconv.Itoa(i), start+int64(i)))
+	}
+	require.NoError(t, s.SaveMetric(ctx, "VM1.version", common.MetricTypeString, 1, "v1.0.0", start))
+
+	var exported []common.ExportedValue
+	collect := func(value common.ExportedValue) error {
+		exported = append(exported, value)
+		return nil
+	}
+
+	// the packed and the raw values are merged in chronological order, within the range
+	err = s.StreamMetricValues(ctx, []string{"VM1.version", "VM1.nonce", "missing"}, start, start+int64(numValues-11), collect)
+	require.NoError(t, err)
+	require.Len(t, exported, 1+numValues-10)
+	require.Equal(t, common.ExportedValue{Name: "VM1.version", Type: common.MetricTypeString, Value: "v1.0.0", RecordedAt: start}, exported[0])
+	for i, value := range exported[1:] {
+		require.Equal(t, common.ExportedValue{Name: "VM1.nonce", Type: common.MetricTypeUint64, Value: strconv.Itoa(i), RecordedAt: start + int64(i)}, value)
+	}
+
+	// the packed values are exported without raw values in the range
+	exported = nil
+	err = s.StreamMetricValues(ctx, []string{"VM1.nonce"}, start+10, start+19, collect)
+	require.NoError(t, err)
+	require.Len(t, exported, 10)
+	require.Equal(t, "10", exported[0].Value)
+	require.Equal(t, common.MetricTypeUint64, exported[0].Type)
+
+	expectedErr := errors.New("expected error")
+	numCalls := 0
+	err = s.StreamMetricValues(ctx, []string{"VM1.nonce", "VM1.version"}, 0, start+int64(numValues), func(value common.ExportedValue) error {
+		numCalls++
+		return expectedErr
+	})
+	require.Equal(t, expectedErr, err)
+	require.Equal(t, 1, numCalls)
+}
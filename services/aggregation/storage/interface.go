@@ -0,0 +1,21 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/commonGo/clock"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+)
+
+// MetricsArchiver defines the destination of the metrics history removed from the database
+type MetricsArchiver interface {
+	Archive(reason string, metrics []common.ArchivedMetric) error
+	IsInterfaceNil() bool
+}
+
+// Clock defines the time source of the retention cleaner
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) clock.Timer
+	IsInterfaceNil() bool
+}
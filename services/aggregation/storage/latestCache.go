@@ -0,0 +1,261 @@
+package storage
+
+import (
+	"cmp"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+)
+
+// latestValue is a value saved for a metric, applied to the cache once its transaction is committed
+type latestValue struct {
+	record common.MetricRecord
+	// aggregatedValue is the value of the aggregation mode of the metric over its window, after the save
+	aggregatedValue string
+	// restored is set when the value restored a deleted metric, whose definition is not cached
+	restored bool
+}
+
+// latestCache holds the definition and the latest value of each metric, as returned by GetLatestMetricsFiltered, so
+// the dashboard refreshes do not run the window function over all the stored values. It is loaded from the database
+// on the first read, kept up to date by the saves of the reported values and dropped by the other changes of the
+// metrics (definitions, tags, deletions, retention), the next read loading it again.
+type latestCache struct {
+	mut     sync.RWMutex
+	loaded  bool
+	metrics map[string]common.MetricHistory
+}
+
+func newLatestCache() *latestCache {
+	return &latestCache{
+		metrics: make(map[string]common.MetricHistory),
+	}
+}
+
+// get returns the cached metrics matching the filter, false if the cache is not loaded
+func (cache *latestCache) get(filter common.MetricsFilter) ([]common.MetricHistory, bool, error) {
+	cache.mut.RLock()
+	defer cache.mut.RUnlock()
+
+	if !cache.loaded {
+		return nil, false, nil
+	}
+
+	results, err := cache.filter(filter)
+	return results, true, err
+}
+
+// load fills the cache with the metrics returned by the loader, unless already loaded. The lock is held while
+// loading, so the values saved meanwhile are applied on top of the loaded ones.
+func (cache *latestCache) load(filter common.MetricsFilter, loader func() ([]common.MetricHistory, error)) ([]common.MetricHistory, error) {
+	cache.mut.Lock()
+	defer cache.mut.Unlock()
+
+	if !cache.loaded {
+		metrics, err := loader()
+		if err != nil {
+			return nil, err
+		}
+
+		cache.metrics = make(map[string]common.MetricHistory, len(metrics))
+		for _, metric := range metrics {
+			cache.metrics[metric.Name] = metric
+		}
+		cache.loaded = true
+		log.Debug("loaded the latest values cache", "num metrics", len(metrics))
+	}
+
+	return cache.filter(filter)
+}
+
+// apply updates the cached metrics with the committed values, the values older than the cached ones only update the
+// definition and the aggregated value of the metric
+func (cache *latestCache) apply(values []latestValue) {
+	cache.mut.Lock()
+	defer cache.mut.Unlock()
+
+	if !cache.loaded {
+		return
+	}
+
+	for _, value := range values {
+		if value.restored {
+			cache.reset()
+			return
+		}
+
+		record := value.record
+		metric, found := cache.metrics[record.Name]
+		if !found {
+			metric = common.MetricHistory{
+				Name:            record.Name,
+				GapMode:         common.GapModeNone,
+				Tenant:          record.Tenant,
+				AggregationMode: common.AggregationModeLast,
+				History:         []common.MetricValue{{}},
+			}
+		}
+
+		metric.Type = record.Type
+		metric.NumAggregation = record.NumAggregation
+		metric.AggregatedValue = value.aggregatedValue
+		if record.ExpectedInterval > 0 {
+			metric.ExpectedInterval = record.ExpectedInterval
+		}
+		if len(record.Tags) > 0 {
+			metric.Tags = mergeCachedTags(metric.Tags, record.Tags)
+		}
+		if record.RecordedAt >= metric.History[0].RecordedAt {
+			metric.History = []common.MetricValue{{Value: record.Value, RecordedAt: record.RecordedAt}}
+		}
+
+		cache.metrics[record.Name] = metric
+	}
+}
+
+// applyTags merges the committed tags in the tags of the cached metric
+func (cache *latestCache) applyTags(name string, tags map[string]string) {
+	cache.update(name, func(metric *common.MetricHistory) {
+		metric.Tags = mergeCachedTags(metric.Tags, tags)
+	})
+}
+
+// applyInterval sets the committed expected interval of the cached metric
+func (cache *latestCache) applyInterval(name string, intervalInSeconds int) {
+	cache.update(name, func(metric *common.MetricHistory) {
+		metric.ExpectedInterval = intervalInSeconds
+	})
+}
+
+// update changes the cached metric, the cache is dropped if the metric is not cached
+func (cache *latestCache) update(name string, handler func(metric *common.MetricHistory)) {
+	cache.mut.Lock()
+	defer cache.mut.Unlock()
+
+	if !cache.loaded {
+		return
+	}
+
+	metric, found := cache.metrics[name]
+	if !found {
+		cache.reset()
+		return
+	}
+
+	handler(&metric)
+	cache.metrics[name] = metric
+}
+
+// invalidate drops the cached metrics, the next read loads them from the database
+func (cache *latestCache) invalidate() {
+	cache.mut.Lock()
+	defer cache.mut.Unlock()
+
+	cache.reset()
+}
+
+// reset drops the cached metrics, the lock must be held
+func (cache *latestCache) reset() {
+	cache.loaded = false
+	cache.metrics = make(map[string]common.MetricHistory)
+}
+
+// filter returns copies of the cached metrics matching the filter, sorted as the SQL query would, the lock must be held
+func (cache *latestCache) filter(filter common.MetricsFilter) ([]common.MetricHistory, error) {
+	less, err := latestMetricsOrder(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []common.MetricHistory
+	for _, metric := range cache.metrics {
+		if !matchesMetricsFilter(metric, filter) {
+			continue
+		}
+
+		metric.History = []common.MetricValue{metric.History[0]}
+		metric.Tags = copyTags(metric.Tags)
+		results = append(results, metric)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return less(results[i], results[j])
+	})
+
+	return results, nil
+}
+
+// matchesMetricsFilter mirrors metricsFilterClause
+func matchesMetricsFilter(metric common.MetricHistory, filter common.MetricsFilter) bool {
+	if filter.Name != "" && metric.Name != filter.Name {
+		return false
+	}
+	if filter.Prefix != "" && !strings.HasPrefix(metric.Name, filter.Prefix) {
+		return false
+	}
+	if filter.Type != "" && metric.Type != filter.Type {
+		return false
+	}
+
+	return filter.Tenant == "" || metric.Tenant == filter.Tenant
+}
+
+// latestMetricsOrder returns the ordering of the sort field of the filter, the ties being ordered by name. The
+// metrics are ordered by name if no sort field is requested.
+func latestMetricsOrder(filter common.MetricsFilter) (func(a, b common.MetricHistory) bool, error) {
+	var compare func(a, b common.MetricHistory) int
+	switch filter.SortBy {
+	case "", common.SortByName:
+		compare = func(a, b common.MetricHistory) int { return 0 }
+	case common.SortByType:
+		compare = func(a, b common.MetricHistory) int { return strings.Compare(a.Type, b.Type) }
+	case common.SortByDisplayOrder:
+		compare = func(a, b common.MetricHistory) int { return cmp.Compare(a.DisplayOrder, b.DisplayOrder) }
+	case common.SortByRecordedAt:
+		compare = func(a, b common.MetricHistory) int {
+			return cmp.Compare(a.History[0].RecordedAt, b.History[0].RecordedAt)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported sort field %s", filter.SortBy)
+	}
+
+	return func(a, b common.MetricHistory) bool {
+		result := compare(a, b)
+		if result == 0 {
+			result = strings.Compare(a.Name, b.Name)
+		}
+		if filter.Descending && filter.SortBy != "" {
+			return result > 0
+		}
+
+		return result < 0
+	}, nil
+}
+
+func mergeCachedTags(tags map[string]string, newTags map[string]string) map[string]string {
+	merged := copyTags(tags)
+	if merged == nil {
+		merged = make(map[string]string, len(newTags))
+	}
+	for key, value := range newTags {
+		merged[key] = value
+	}
+
+	return merged
+}
+
+func copyTags(tags map[string]string) map[string]string {
+	if tags == nil {
+		return nil
+	}
+
+	copied := make(map[string]string, len(tags))
+	for key, value := range tags {
+		copied[key] = value
+	}
+
+	return copied
+}
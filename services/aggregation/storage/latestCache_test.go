@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteStorage_LatestCache(t *testing.T) {
+	t.Parallel()
+
+	s, err := NewSQLiteStorage(":memory:", 3600, SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = s.Close()
+	}()
+
+	ctx := context.Background()
+	records := []common.MetricRecord{
+		{Name: "VM1.Node1.nonce", Type: "uint64", NumAggregation: 3, Value: "10", Tags: map[string]string{"shard": "0"}, RecordedAt: 100, ExpectedInterval: 30},
+		{Name: "VM1.Active", Type: "bool", NumAggregation: 1, Value: "true", RecordedAt: 100},
+		{Name: "VM2.Active", Type: "bool", NumAggregation: 1, Value: "true", RecordedAt: 90, Tenant: "tenant1"},
+	}
+	require.NoError(t, s.SaveMetrics(ctx, records))
+	require.False(t, s.latest.loaded)
+
+	latest, err := s.GetLatestMetrics(ctx)
+	require.NoError(t, err)
+	require.Len(t, latest, 3)
+	require.True(t, s.latest.loaded)
+
+	requireSameAsDatabase := func(filter common.MetricsFilter) {
+		cached, errGet := s.GetLatestMetricsFiltered(ctx, filter)
+		require.NoError(t, errGet)
+		// the cached metrics are ordered by name when no sort field is requested
+		databaseFilter := filter
+		if databaseFilter.SortBy == "" {
+			databaseFilter.SortBy = common.SortByName
+		}
+		fromDatabase, errGet := s.getLatestMetricsFiltered(ctx, databaseFilter)
+		require.NoError(t, errGet)
+		require.Equal(t, fromDatabase, cached)
+	}
+
+	t.Run("saved values update the cache", func(t *testing.T) {
+		require.NoError(t, s.SaveMetric(ctx, "VM1.Node1.nonce", "uint64", 3, "11", 130))
+		require.NoError(t, s.SaveMetrics(ctx, []common.MetricRecord{
+			{Name: "VM1.Node1.nonce", Type: "uint64", NumAggregation: 3, Value: "12", Tags: map[string]string{"vm": "VM1"}, RecordedAt: 160},
+			// a delayed value does not replace the latest one
+			{Name: "VM1.Active", Type: "bool", NumAggregation: 1, Value: "false", RecordedAt: 50},
+			{Name: "VM3.Active", Type: "bool", NumAggregation: 1, Value: "true", RecordedAt: 170},
+		}))
+		require.NoError(t, s.SaveMetricInterval(ctx, "VM1.Active", 60))
+		require.NoError(t, s.SaveMetricTags(ctx, "VM1.Active", map[string]string{"vm": "VM1"}))
+		require.True(t, s.latest.loaded)
+
+		metrics, errGet := s.GetLatestMetricsFiltered(ctx, common.MetricsFilter{Name: "VM1.Node1.nonce"})
+		require.NoError(t, errGet)
+		require.Len(t, metrics, 1)
+		require.Equal(t, []common.MetricValue{{Value: "12", RecordedAt: 160}}, metrics[0].History)
+		require.Equal(t, map[string]string{"shard": "0", "vm": "VM1"}, metrics[0].Tags)
+
+		requireSameAsDatabase(common.MetricsFilter{})
+	})
+	t.Run("the aggregated values are updated", func(t *testing.T) {
+		require.NoError(t, s.UpdateMetricAggregationMode(ctx, "VM1.Node1.nonce", common.AggregationModeAvg))
+		require.False(t, s.latest.loaded)
+		requireSameAsDatabase(common.MetricsFilter{})
+
+		require.NoError(t, s.SaveMetric(ctx, "VM1.Node1.nonce", "uint64", 3, "16", 190))
+		metrics, errGet := s.GetLatestMetricsFiltered(ctx, common.MetricsFilter{Name: "VM1.Node1.nonce"})
+		require.NoError(t, errGet)
+		require.Equal(t, "13", metrics[0].AggregatedValue) // (11 + 12 + 16) / 3
+		requireSameAsDatabase(common.MetricsFilter{})
+	})
+	t.Run("filters and sorting match the database", func(t *testing.T) {
+		require.NoError(t, s.UpdateMetricOrder(ctx, "VM3.Active", 1))
+		requireSameAsDatabase(common.MetricsFilter{Prefix: "VM1."})
+		requireSameAsDatabase(common.MetricsFilter{Type: "bool", SortBy: common.SortByRecordedAt})
+		requireSameAsDatabase(common.MetricsFilter{Tenant: "tenant1"})
+		requireSameAsDatabase(common.MetricsFilter{SortBy: common.SortByDisplayOrder, Descending: true})
+		requireSameAsDatabase(common.MetricsFilter{SortBy: common.SortByType})
+
+		_, errGet := s.GetLatestMetricsFiltered(ctx, common.MetricsFilter{SortBy: "unknown"})
+		require.Error(t, errGet)
+	})
+	t.Run("the returned metrics are copies", func(t *testing.T) {
+		metrics, errGet := s.GetLatestMetricsFiltered(ctx, common.MetricsFilter{Name: "VM1.Node1.nonce"})
+		require.NoError(t, errGet)
+		metrics[0].Tags["shard"] = "changed"
+		metrics[0].History[0].Value = "changed"
+
+		metrics, errGet = s.GetLatestMetricsFiltered(ctx, common.MetricsFilter{Name: "VM1.Node1.nonce"})
+		require.NoError(t, errGet)
+		require.Equal(t, "0", metrics[0].Tags["shard"])
+		require.Equal(t, "16", metrics[0].History[0].Value)
+	})
+	t.Run("deletions drop the cache", func(t *testing.T) {
+		require.NoError(t, s.DeleteMetric(ctx, "VM3.Active"))
+		metrics, errGet := s.GetLatestMetricsFiltered(ctx, common.MetricsFilter{Name: "VM3.Active"})
+		require.NoError(t, errGet)
+		require.Empty(t, metrics)
+	})
+}
@@ -0,0 +1,287 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+)
+
+// migration is a versioned schema change applied on top of the base schema. The base schema (createSchema) always
+// holds the latest definition of the tables, so the steps must be no-ops on a freshly created database: the columns
+// are added only when missing. A nil apply marks a table introduced by the base schema itself.
+type migration struct {
+	name  string
+	apply func(tx *sql.Tx) error
+}
+
+// schemaMigrations lists, in order, the schema changes applied on top of the initial schema. The schema version is
+// the number of these migrations. New migrations are appended, the existing ones are never renamed nor reordered.
+var schemaMigrations = []migration{
+	{name: "metrics_display_order", apply: addColumn("metrics", "display_order", "INTEGER NOT NULL DEFAULT 0")},
+	{name: "metrics_is_alarm_enabled", apply: addColumn("metrics", "is_alarm_enabled", "INTEGER NOT NULL DEFAULT 0")},
+	{name: "metrics_values_value_num", apply: addNumericValues},
+	{name: "metrics_gap_mode", apply: addColumn("metrics", "gap_mode", "TEXT NOT NULL DEFAULT ''")},
+	{name: "metric_tags"},
+	{name: "metric_annotations"},
+	{name: "dashboards"},
+	{name: "alerts"},
+	{name: "silences"},
+	{name: "metric_availability"},
+	{name: "metrics_tenant", apply: addMetricsTenant},
+	{name: "totp_enrollments"},
+	{name: "sessions"},
+	// the interval of the existing metrics is unknown until they are reported again
+	{name: "metrics_expected_interval", apply: addColumn("metrics", "expected_interval", "INTEGER NOT NULL DEFAULT 0")},
+	{name: "agents"},
+	{name: "agent_logs"},
+	// the existing metrics expose their latest value
+	{name: "metrics_aggregation_mode", apply: addColumn("metrics", "aggregation_mode", "TEXT NOT NULL DEFAULT 'last'")},
+	{name: "metrics_values_received_at", apply: addReceivedAt},
+	{name: "metrics_deleted_at", apply: addColumn("metrics", "deleted_at", "INTEGER NOT NULL DEFAULT 0")},
+	{name: "custom_panel_metrics"},
+	{name: "metric_thresholds"},
+	{name: "metrics_store_on_change", apply: addColumn("metrics", "store_on_change", "INTEGER NOT NULL DEFAULT 0")},
+	{name: "metrics_values_last_seen_at", apply: addColumn("metrics_values", "last_seen_at", "INTEGER NOT NULL DEFAULT 0")},
+	{name: "metrics_compressed", apply: addColumn("metrics", "compressed", "INTEGER NOT NULL DEFAULT 0")},
+	{name: "metric_value_chunks"},
+	{name: "metrics_tenant_namespace", apply: moveTenantMetrics},
+}
+
+// applyMigrations runs, each in its own transaction, the migrations not yet recorded in schema_migrations. A failing
+// migration is rolled back and aborts the startup, the service must not run on a partially migrated schema.
+func applyMigrations(db *sql.DB) error {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return err
+	}
+
+	known := make(map[string]struct{}, len(schemaMigrations))
+	numApplied := 0
+	for i, m := range schemaMigrations {
+		known[m.name] = struct{}{}
+		_, isApplied := applied[m.name]
+		if isApplied {
+			continue
+		}
+
+		err = applyMigration(db, m)
+		if err != nil {
+			return fmt.Errorf("failed to apply the schema migration %d (%s): %w", i+1, m.name, err)
+		}
+		log.Debug("applied the schema migration", "version", i+1, "name", m.name)
+		numApplied++
+	}
+	for name := range applied {
+		_, isKnown := known[name]
+		if !isKnown {
+			log.Warn("the database holds a schema migration unknown to this version, it was created by a newer release", "name", name)
+		}
+	}
+
+	_, err = db.Exec(fmt.Sprintf("PRAGMA user_version = %d;", len(schemaMigrations)))
+	if err != nil {
+		return fmt.Errorf("failed to set the schema version: %w", err)
+	}
+
+	if numApplied > 0 {
+		log.Info("migrated the database schema", "num migrations", numApplied, "version", len(schemaMigrations))
+	}
+
+	return nil
+}
+
+func appliedMigrations(db *sql.DB) (map[string]struct{}, error) {
+	rows, err := db.Query("SELECT name FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the applied migrations: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	applied := make(map[string]struct{})
+	for rows.Next() {
+		var name string
+		err = rows.Scan(&name)
+		if err != nil {
+			return nil, err
+		}
+		applied[name] = struct{}{}
+	}
+
+	return applied, rows.Err()
+}
+
+func applyMigration(db *sql.DB, m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if m.apply != nil {
+		err = m.apply(tx)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec("INSERT INTO schema_migrations (name, applied_at) VALUES (?, ?)", m.name, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// addColumn returns the migration step adding the column to the table, if missing
+func addColumn(table string, column string, definition string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		exists, err := columnExists(tx, table, column)
+		if err != nil || exists {
+			return err
+		}
+
+		_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", table, column, definition))
+		return err
+	}
+}
+
+func columnExists(tx *sql.Tx, table string, column string) (bool, error) {
+	rows, err := tx.Query(fmt.Sprintf(`PRAGMA table_info("%s");`, strings.ReplaceAll(table, `"`, `""`)))
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	for rows.Next() {
+		var cid, notNull, primaryKey int
+		var name, columnType string
+		var defaultValue interface{}
+		err = rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &primaryKey)
+		if err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+
+	return false, rows.Err()
+}
+
+// addNumericValues adds the numeric value column of metrics_values and backfills it for the numeric metrics
+func addNumericValues(tx *sql.Tx) error {
+	err := addColumn("metrics_values", "value_num", "NUMERIC")(tx)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE metrics_values
+		SET value_num = CAST(value AS NUMERIC)
+		WHERE value_num IS NULL
+		  AND value <> ''
+		  AND value NOT GLOB '*[^0-9.-]*'
+		  AND metric_name IN (SELECT name FROM metrics WHERE type IN (?, ?))
+	`, common.MetricTypeUint64, common.MetricTypeFloat64)
+	if err != nil {
+		return fmt.Errorf("failed to backfill the numeric values: %w", err)
+	}
+
+	return nil
+}
+
+// addMetricsTenant assigns the existing metrics to the default tenant
+func addMetricsTenant(tx *sql.Tx) error {
+	err := addColumn("metrics", "tenant", "TEXT NOT NULL DEFAULT ''")(tx)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("CREATE INDEX IF NOT EXISTS idx_metrics_tenant ON metrics(tenant);")
+	if err != nil {
+		return fmt.Errorf("failed to create the tenant index: %w", err)
+	}
+
+	return nil
+}
+
+// moveTenantMetrics moves the metrics of the tenants, reported before the tenants had their own metric namespace, to
+// their stored name prefixed by the tenant
+func moveTenantMetrics(tx *sql.Tx) error {
+	rows, err := tx.Query(`
+		SELECT name, tenant FROM metrics
+		WHERE tenant <> '' AND substr(name, 1, length(tenant) + 1) <> tenant || ?
+	`, common.TenantNameSeparator)
+	if err != nil {
+		return fmt.Errorf("failed to list the tenant metrics: %w", err)
+	}
+
+	names := make(map[string]string)
+	for rows.Next() {
+		var name, tenant string
+		err = rows.Scan(&name, &tenant)
+		if err != nil {
+			_ = rows.Close()
+			return err
+		}
+		names[name] = common.TenantMetricName(tenant, name)
+	}
+	_ = rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	for name, newName := range names {
+		_, err = tx.Exec(`
+			INSERT INTO metrics (name, type, num_aggregation, display_order, is_alarm_enabled, gap_mode, tenant, expected_interval, aggregation_mode, deleted_at, store_on_change, compressed)
+			SELECT ?, type, num_aggregation, display_order, is_alarm_enabled, gap_mode, tenant, expected_interval, aggregation_mode, deleted_at, store_on_change, compressed
+			FROM metrics WHERE name = ?
+		`, newName, name)
+		if err != nil {
+			return fmt.Errorf("failed to move the tenant metric %s to %s: %w", name, newName, err)
+		}
+
+		err = renameMetricReferences(ctx, tx, name, newName)
+		if err != nil {
+			return fmt.Errorf("failed to move the tenant metric %s to %s: %w", name, newName, err)
+		}
+
+		_, err = tx.Exec("UPDATE alerts SET metric_name = ? WHERE metric_name = ?", newName, name)
+		if err != nil {
+			return fmt.Errorf("failed to move the alerts of the tenant metric %s: %w", name, err)
+		}
+
+		_, err = tx.Exec("DELETE FROM metrics WHERE name = ?", name)
+		if err != nil {
+			return fmt.Errorf("failed to move the tenant metric %s to %s: %w", name, newName, err)
+		}
+	}
+
+	return nil
+}
+
+// addReceivedAt adds the reception timestamp of the values, the existing values were recorded on reception
+func addReceivedAt(tx *sql.Tx) error {
+	err := addColumn("metrics_values", "received_at", "INTEGER NOT NULL DEFAULT 0")(tx)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE metrics_values SET received_at = recorded_at WHERE received_at = 0;")
+	if err != nil {
+		return fmt.Errorf("failed to backfill the reception timestamps: %w", err)
+	}
+
+	return nil
+}
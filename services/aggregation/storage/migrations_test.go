@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyMigrations(t *testing.T) {
+	t.Parallel()
+
+	dbPath := filepath.Join(t.TempDir(), "legacy.db")
+	legacyDB, err := sql.Open("sqlite3", dbPath)
+	require.NoError(t, err)
+	_, err = legacyDB.Exec(`
+	CREATE TABLE metrics (
+		name               TEXT    NOT NULL PRIMARY KEY,
+		type               TEXT    NOT NULL,
+		num_aggregation    INTEGER NOT NULL DEFAULT 1
+	);
+	CREATE TABLE metrics_values (
+		metric_name TEXT    NOT NULL REFERENCES metrics(name) ON DELETE CASCADE,
+		value       TEXT    NOT NULL,
+		recorded_at INTEGER NOT NULL
+	);
+	`)
+	require.NoError(t, err)
+	_ = legacyDB.Close()
+
+	s, err := NewSQLiteStorage(dbPath, 3600, SQLiteTuning{})
+	require.NoError(t, err)
+
+	tx, err := s.db.Begin()
+	require.NoError(t, err)
+	for _, column := range []string{"display_order", "is_alarm_enabled", "gap_mode", "tenant", "expected_interval", "aggregation_mode"} {
+		exists, errExists := columnExists(tx, "metrics", column)
+		require.NoError(t, errExists)
+		require.True(t, exists, column)
+	}
+	for _, column := range []string{"value_num", "received_at"} {
+		exists, errExists := columnExists(tx, "metrics_values", column)
+		require.NoError(t, errExists)
+		require.True(t, exists, column)
+	}
+	_ = tx.Rollback()
+
+	var numApplied int
+	var lastAppliedAt int64
+	err = s.db.QueryRow("SELECT COUNT(*), MAX(applied_at) FROM schema_migrations").Scan(&numApplied, &lastAppliedAt)
+	require.NoError(t, err)
+	require.Equal(t, len(schemaMigrations), numApplied)
+	_ = s.Close()
+
+	// reopening does not apply the migrations again
+	s, err = NewSQLiteStorage(dbPath, 3600, SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = s.Close()
+	}()
+
+	var numReapplied int
+	err = s.db.QueryRow("SELECT COUNT(*) FROM schema_migrations WHERE applied_at > ?", lastAppliedAt).Scan(&numReapplied)
+	require.NoError(t, err)
+	require.Zero(t, numReapplied)
+}
+
+func TestApplyMigration(t *testing.T) {
+	t.Parallel()
+
+	s, err := NewSQLiteStorage(":memory:", 3600, SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = s.Close()
+	}()
+
+	expectedErr := errors.New("expected error")
+	failing := migration{
+		name: "failing",
+		apply: func(tx *sql.Tx) error {
+			err := addColumn("metrics", "failing_column", "INTEGER NOT NULL DEFAULT 0")(tx)
+			require.NoError(t, err)
+
+			return expectedErr
+		},
+	}
+	err = applyMigration(s.db, failing)
+	require.Equal(t, expectedErr, err)
+
+	// the failing migration is rolled back
+	tx, err := s.db.Begin()
+	require.NoError(t, err)
+	exists, err := columnExists(tx, "metrics", "failing_column")
+	require.NoError(t, err)
+	require.False(t, exists)
+	_ = tx.Rollback()
+
+	applied, err := appliedMigrations(s.db)
+	require.NoError(t, err)
+	require.NotContains(t, applied, "failing")
+	require.Len(t, applied, len(schemaMigrations))
+
+	// the steps adding a column are no-ops on the base schema
+	err = applyMigration(s.db, migration{name: "added_twice", apply: addColumn("metrics", "tenant", "TEXT NOT NULL DEFAULT ''")})
+	require.NoError(t, err)
+}
+
+func TestMoveTenantMetrics(t *testing.T) {
+	t.Parallel()
+
+	s, err := NewSQLiteStorage(":memory:", 3600, SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = s.Close()
+	}()
+
+	// the tenant metrics reported before the tenant namespaces
+	ctx := context.Background()
+	require.NoError(t, s.SaveMetrics(ctx, []common.MetricRecord{
+		{Name: "VM1.Node1.nonce", Type: "uint64", NumAggregation: 2, Value: "10", RecordedAt: 100, Tenant: "acme",
+			Tags: map[string]string{"vm": "VM1", "node": "Node1", "kind": "nonce", "shard": "0"}},
+		{Name: "acme:VM2.Node1.nonce", Type: "uint64", NumAggregation: 2, Value: "20", RecordedAt: 100, Tenant: "acme"},
+	}))
+	require.NoError(t, s.SaveMetric(ctx, "VM3.Node1.nonce", "uint64", 2, "30", 100))
+	_, err = s.db.Exec("INSERT INTO alerts (metric_name, state, started_at) VALUES ('VM1.Node1.nonce', 'firing', 100)")
+	require.NoError(t, err)
+
+	err = applyMigration(s.db, migration{name: "moved_again", apply: moveTenantMetrics})
+	require.NoError(t, err)
+	s.latest.invalidate()
+
+	_, err = s.GetMetricTenant(ctx, "VM1.Node1.nonce")
+	require.Equal(t, common.ErrMetricNotFound, err)
+	history, err := s.GetMetricHistory(ctx, "acme:VM1.Node1.nonce")
+	require.NoError(t, err)
+	require.Equal(t, "acme", history.Tenant)
+	require.Len(t, history.History, 1)
+	require.Equal(t, map[string]string{"vm": "acme:VM1", "node": "Node1", "kind": "nonce", "shard": "0"}, history.Tags)
+
+	// the already prefixed and the default tenant metrics are kept
+	tenant, err := s.GetMetricTenant(ctx, "acme:VM2.Node1.nonce")
+	require.NoError(t, err)
+	require.Equal(t, "acme", tenant)
+	tenant, err = s.GetMetricTenant(ctx, "VM3.Node1.nonce")
+	require.NoError(t, err)
+	require.Empty(t, tenant)
+
+	var alertMetric string
+	err = s.db.QueryRow("SELECT metric_name FROM alerts").Scan(&alertMetric)
+	require.NoError(t, err)
+	require.Equal(t, "acme:VM1.Node1.nonce", alertMetric)
+}
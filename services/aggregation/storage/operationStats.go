@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+)
+
+const (
+	defaultOperationTimeout   = 30 * time.Second
+	defaultSlowQueryThreshold = 500 * time.Millisecond
+)
+
+// operationStats counts the storage operations, the slow ones and the ones that ran out of time
+type operationStats struct {
+	mut                   sync.Mutex
+	numOperations         uint64
+	numSlowOperations     uint64
+	numTimedOutOperations uint64
+	maxDuration           time.Duration
+}
+
+// startOperation bounds the operation with the configured timeout. The returned function must be called when the
+// operation ends: it logs the operation if it was slower than the threshold and updates the counters.
+func (s *sqliteStorage) startOperation(ctx context.Context, name string) (context.Context, func()) {
+	ctx, cancel := context.WithTimeout(ctx, s.operationTimeout)
+	start := time.Now()
+
+	return ctx, func() {
+		duration := time.Since(start)
+		isTimedOut := errors.Is(ctx.Err(), context.DeadlineExceeded)
+		cancel()
+
+		isSlow := duration > s.slowQueryThreshold
+		if isSlow {
+			log.Warn("slow storage operation", "operation", name, "duration", duration, "timed out", isTimedOut,
+				"request ID", common.RequestIDFromContext(ctx))
+		}
+
+		s.opStats.mut.Lock()
+		defer s.opStats.mut.Unlock()
+
+		s.opStats.numOperations++
+		if isSlow {
+			s.opStats.numSlowOperations++
+		}
+		if isTimedOut {
+			s.opStats.numTimedOutOperations++
+		}
+		if duration > s.opStats.maxDuration {
+			s.opStats.maxDuration = duration
+		}
+	}
+}
+
+// GetStorageStats returns the counters of the storage operations
+func (s *sqliteStorage) GetStorageStats() common.StorageStats {
+	s.opStats.mut.Lock()
+	defer s.opStats.mut.Unlock()
+
+	return common.StorageStats{
+		NumOperations:          s.opStats.numOperations,
+		NumSlowOperations:      s.opStats.numSlowOperations,
+		NumTimedOutOperations:  s.opStats.numTimedOutOperations,
+		MaxOperationMs:         float64(s.opStats.maxDuration) / float64(time.Millisecond),
+		SlowQueryThresholdInMs: s.slowQueryThreshold.Milliseconds(),
+	}
+}
@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+)
+
+const sessionColumns = "id, username, tenant, role, oidc, user_agent, remote_addr, created_at, expires_at, revoked_at"
+
+// CreateSession stores a new frontend session
+func (s *sqliteStorage) CreateSession(ctx context.Context, session common.Session) error {
+	ctx, finish := s.startOperation(ctx, "CreateSession")
+	defer finish()
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO sessions (`+sessionColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, session.ID, session.Username, session.Tenant, session.Role, session.OIDC, session.UserAgent, session.RemoteAddr,
+		session.CreatedAt, session.ExpiresAt, session.RevokedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert the session: %w", err)
+	}
+
+	return nil
+}
+
+// GetSession returns the session with the provided ID
+func (s *sqliteStorage) GetSession(ctx context.Context, id string) (*common.Session, error) {
+	ctx, finish := s.startOperation(ctx, "GetSession")
+	defer finish()
+
+	session := &common.Session{}
+	err := s.readDB.QueryRowContext(ctx, "SELECT "+sessionColumns+" FROM sessions WHERE id = ?", id).Scan(
+		&session.ID, &session.Username, &session.Tenant, &session.Role, &session.OIDC, &session.UserAgent,
+		&session.RemoteAddr, &session.CreatedAt, &session.ExpiresAt, &session.RevokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, common.ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the session: %w", err)
+	}
+
+	return session, nil
+}
+
+// GetActiveSessions returns the sessions neither expired nor revoked at the provided timestamp, the most recent first
+func (s *sqliteStorage) GetActiveSessions(ctx context.Context, timestamp int64) ([]common.Session, error) {
+	ctx, finish := s.startOperation(ctx, "GetActiveSessions")
+	defer finish()
+
+	rows, err := s.readDB.QueryContext(ctx, "SELECT "+sessionColumns+" FROM sessions WHERE revoked_at = 0 AND expires_at >= ? ORDER BY created_at DESC, id",
+		timestamp)
+	if err != nil {
+		return nil, fmt.Errorf("sessions query failed: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	sessions := make([]common.Session, 0)
+	for rows.Next() {
+		var session common.Session
+		err = rows.Scan(&session.ID, &session.Username, &session.Tenant, &session.Role, &session.OIDC, &session.UserAgent,
+			&session.RemoteAddr, &session.CreatedAt, &session.ExpiresAt, &session.RevokedAt)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// RevokeSession invalidates the tokens of the session at the provided timestamp
+func (s *sqliteStorage) RevokeSession(ctx context.Context, id string, timestamp int64) error {
+	ctx, finish := s.startOperation(ctx, "RevokeSession")
+	defer finish()
+
+	result, err := s.db.ExecContext(ctx, "UPDATE sessions SET revoked_at = ? WHERE id = ? AND revoked_at = 0", timestamp, id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke the session: %w", err)
+	}
+
+	numRows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if numRows == 0 {
+		return common.ErrSessionNotFound
+	}
+
+	return nil
+}
+
+// RevokeUserSessions invalidates all the sessions of the user at the provided timestamp, returning their number
+func (s *sqliteStorage) RevokeUserSessions(ctx context.Context, username string, timestamp int64) (int64, error) {
+	ctx, finish := s.startOperation(ctx, "RevokeUserSessions")
+	defer finish()
+
+	result, err := s.db.ExecContext(ctx, "UPDATE sessions SET revoked_at = ? WHERE username = ? AND revoked_at = 0", timestamp, username)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke the sessions: %w", err)
+	}
+
+	return result.RowsAffected()
+}
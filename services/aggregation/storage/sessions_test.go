@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteStorage_Sessions(t *testing.T) {
+	s, err := NewSQLiteStorage(":memory:", 3600, SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = s.Close()
+	}()
+
+	ctx := context.Background()
+
+	session1 := common.Session{
+		ID:         "session-1",
+		Username:   "admin",
+		Role:       common.RoleAdmin,
+		UserAgent:  "Mozilla/5.0",
+		RemoteAddr: "10.0.0.1",
+		CreatedAt:  1000,
+		ExpiresAt:  5000,
+	}
+	require.NoError(t, s.CreateSession(ctx, session1))
+	require.NoError(t, s.CreateSession(ctx, common.Session{ID: "session-2", Username: "admin", Role: common.RoleAdmin, CreatedAt: 1100, ExpiresAt: 5100}))
+	require.NoError(t, s.CreateSession(ctx, common.Session{ID: "session-3", Username: "jane", Tenant: "acme", Role: common.RoleViewer, OIDC: true, CreatedAt: 1200, ExpiresAt: 2000}))
+	require.Error(t, s.CreateSession(ctx, session1))
+
+	session, err := s.GetSession(ctx, "session-1")
+	require.NoError(t, err)
+	require.Equal(t, &session1, session)
+	_, err = s.GetSession(ctx, "missing")
+	require.True(t, errors.Is(err, common.ErrSessionNotFound))
+
+	sessions, err := s.GetActiveSessions(ctx, 1500)
+	require.NoError(t, err)
+	require.Len(t, sessions, 3)
+	require.Equal(t, "session-3", sessions[0].ID)
+	require.True(t, sessions[0].OIDC)
+	require.Equal(t, "acme", sessions[0].Tenant)
+
+	sessions, err = s.GetActiveSessions(ctx, 3000)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+
+	require.NoError(t, s.RevokeSession(ctx, "session-2", 1600))
+	err = s.RevokeSession(ctx, "session-2", 1700)
+	require.True(t, errors.Is(err, common.ErrSessionNotFound))
+	session, err = s.GetSession(ctx, "session-2")
+	require.NoError(t, err)
+	require.Equal(t, int64(1600), session.RevokedAt)
+	require.False(t, session.IsActive(1700))
+
+	numRevoked, err := s.RevokeUserSessions(ctx, "admin", 1800)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), numRevoked)
+	sessions, err = s.GetActiveSessions(ctx, 1900)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	require.Equal(t, "session-3", sessions[0].ID)
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+)
+
+const silenceColumns = "id, pattern, starts_at, ends_at, comment, created_by, created_at"
+
+// CreateSilence stores a new silence, returning its ID
+func (s *sqliteStorage) CreateSilence(ctx context.Context, silence common.Silence) (int64, error) {
+	ctx, finish := s.startOperation(ctx, "CreateSilence")
+	defer finish()
+
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO silences (pattern, starts_at, ends_at, comment, created_by, created_at) VALUES (?, ?, ?, ?, ?, ?)
+	`, silence.Pattern, silence.StartsAt, silence.EndsAt, silence.Comment, silence.CreatedBy, silence.CreatedAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert the silence: %w", err)
+	}
+
+	return result.LastInsertId()
+}
+
+// GetSilences returns the silences that did not end before the provided timestamp (all of them if 0), the most
+// recent first
+func (s *sqliteStorage) GetSilences(ctx context.Context, endsAfter int64) ([]common.Silence, error) {
+	ctx, finish := s.startOperation(ctx, "GetSilences")
+	defer finish()
+
+	return s.querySilences(ctx, "SELECT "+silenceColumns+" FROM silences WHERE ends_at > ? ORDER BY starts_at DESC, id DESC", endsAfter)
+}
+
+// GetActiveSilences returns the silences applying at the provided timestamp
+func (s *sqliteStorage) GetActiveSilences(ctx context.Context, timestamp int64) ([]common.Silence, error) {
+	ctx, finish := s.startOperation(ctx, "GetActiveSilences")
+	defer finish()
+
+	return s.querySilences(ctx, "SELECT "+silenceColumns+" FROM silences WHERE starts_at <= ? AND ends_at > ? ORDER BY id",
+		timestamp, timestamp)
+}
+
+// ExpireSilence ends a silence at the provided timestamp, keeping it in the history. A silence that did not start
+// yet ends as soon as it starts.
+func (s *sqliteStorage) ExpireSilence(ctx context.Context, id int64, timestamp int64) error {
+	ctx, finish := s.startOperation(ctx, "ExpireSilence")
+	defer finish()
+
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE silences SET ends_at = MAX(starts_at, MIN(ends_at, ?)) WHERE id = ?
+	`, timestamp, id)
+	if err != nil {
+		return fmt.Errorf("failed to expire the silence: %w", err)
+	}
+
+	numRows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if numRows == 0 {
+		return common.ErrSilenceNotFound
+	}
+
+	return nil
+}
+
+func (s *sqliteStorage) querySilences(ctx context.Context, query string, args ...interface{}) ([]common.Silence, error) {
+	rows, err := s.readDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("silences query failed: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	silences := make([]common.Silence, 0)
+	for rows.Next() {
+		var silence common.Silence
+		err = rows.Scan(&silence.ID, &silence.Pattern, &silence.StartsAt, &silence.EndsAt, &silence.Comment,
+			&silence.CreatedBy, &silence.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		silences = append(silences, silence)
+	}
+
+	return silences, rows.Err()
+}
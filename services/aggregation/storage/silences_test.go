@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteStorage_Silences(t *testing.T) {
+	s, err := NewSQLiteStorage(":memory:", 3600, SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = s.Close()
+	}()
+
+	ctx := context.Background()
+
+	id1, err := s.CreateSilence(ctx, common.Silence{
+		Pattern:   "VM1.*",
+		StartsAt:  1000,
+		EndsAt:    2000,
+		Comment:   "node upgrade",
+		CreatedBy: "admin",
+		CreatedAt: 900,
+	})
+	require.NoError(t, err)
+	id2, err := s.CreateSilence(ctx, common.Silence{Pattern: "*.nonce", StartsAt: 1500, EndsAt: 3000, CreatedAt: 900})
+	require.NoError(t, err)
+
+	active, err := s.GetActiveSilences(ctx, 999)
+	require.NoError(t, err)
+	require.Empty(t, active)
+
+	active, err = s.GetActiveSilences(ctx, 1500)
+	require.NoError(t, err)
+	require.Len(t, active, 2)
+	require.Equal(t, common.Silence{
+		ID:        id1,
+		Pattern:   "VM1.*",
+		StartsAt:  1000,
+		EndsAt:    2000,
+		Comment:   "node upgrade",
+		CreatedBy: "admin",
+		CreatedAt: 900,
+	}, active[0])
+
+	active, err = s.GetActiveSilences(ctx, 2000)
+	require.NoError(t, err)
+	require.Len(t, active, 1)
+	require.Equal(t, id2, active[0].ID)
+
+	silences, err := s.GetSilences(ctx, 0)
+	require.NoError(t, err)
+	require.Len(t, silences, 2)
+	require.Equal(t, id2, silences[0].ID)
+
+	silences, err = s.GetSilences(ctx, 2500)
+	require.NoError(t, err)
+	require.Len(t, silences, 1)
+
+	require.NoError(t, s.ExpireSilence(ctx, id2, 1600))
+	active, err = s.GetActiveSilences(ctx, 1700)
+	require.NoError(t, err)
+	require.Len(t, active, 1)
+	require.Equal(t, id1, active[0].ID)
+
+	err = s.ExpireSilence(ctx, 100, 1600)
+	require.True(t, errors.Is(err, common.ErrSilenceNotFound))
+}
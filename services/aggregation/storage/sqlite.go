@@ -3,301 +3,2009 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/iulianpascalau/api-monitoring/commonGo/clock"
 	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
-	_ "github.com/mattn/go-sqlite3"
+	sqlite3 "github.com/mattn/go-sqlite3"
+	"github.com/multiversx/mx-chain-core-go/core/check"
 	logger "github.com/multiversx/mx-chain-logger-go"
 )
 
 var log = logger.GetOrCreate("storage")
 
-// sqliteStorage is the sqlite implementation for metrics storage
+const (
+	defaultBusyTimeoutInMs    = 5000
+	defaultCheckpointInterval = 5 * time.Minute
+	defaultMaxReadConnections = 4
+	defaultTrashRetention     = 7 * 24 * time.Hour
+	inMemoryDatabasePath      = ":memory:"
+)
+
+// SQLiteTuning holds the SQLite connection and maintenance settings. The zero values keep the defaults.
+type SQLiteTuning struct {
+	BusyTimeoutInMs         int
+	SynchronousMode         string
+	CacheSizeInKB           int
+	WALAutoCheckpointPages  int
+	CheckpointIntervalInSec int
+	OperationTimeoutInMs    int
+	SlowQueryThresholdInMs  int
+	MaxReadConnections      int
+	// TrashRetentionSeconds is how long the deleted metrics are kept, restorable, before being purged
+	TrashRetentionSeconds int
+	// Archiver receives the history of the purged metrics and the expired values before their removal, nil disables
+	// the archival
+	Archiver MetricsArchiver
+	// Clock drives the retention cleaner and its cutoffs, nil uses the system clock
+	Clock Clock
+}
+
+// sqliteStorage is the sqlite implementation for metrics storage. The writes go through db, a single connection, so
+// they queue in the process instead of contending for the database lock, while the reads go through the readDB pool
+// and are not blocked by the writes (WAL mode). The in-memory databases can not be shared between connections, so
+// both handles are the same single connection.
 type sqliteStorage struct {
-	db               *sql.DB
-	retentionSeconds int
-	cancelFunc       context.CancelFunc
-	wg               sync.WaitGroup
+	db                 *sql.DB
+	readDB             *sql.DB
+	statements         *saveStatements
+	latest             *latestCache
+	retentionSeconds   int
+	trashRetention     time.Duration
+	archiver           MetricsArchiver
+	clock              Clock
+	checkpointInterval time.Duration
+	operationTimeout   time.Duration
+	slowQueryThreshold time.Duration
+	opStats            operationStats
+	cancelFunc         context.CancelFunc
+	wg                 sync.WaitGroup
+
+	// mutSave orders the saves of the reported values, so their changes are applied to the cache in commit order
+	mutSave sync.Mutex
+}
+
+// NewSQLiteStorage creates the database, schema, and starts the retention cleaner and the WAL checkpointer
+func NewSQLiteStorage(dbPath string, retentionSeconds int, tuning SQLiteTuning) (*sqliteStorage, error) {
+	err := prepareDirectories(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create initial empty DB file: %w", err)
+	}
+
+	db, err := sql.Open(sqliteDriverName(tuning.WALAutoCheckpointPages), dbPath+createDSNParameters(tuning))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	err = createSchema(db)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	readDB, err := openReadDB(db, dbPath, tuning)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	statements, err := prepareSaveStatements(db)
+	if err != nil {
+		closeDatabases(db, readDB)
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &sqliteStorage{
+		db:                 db,
+		readDB:             readDB,
+		statements:         statements,
+		latest:             newLatestCache(),
+		retentionSeconds:   retentionSeconds,
+		trashRetention:     time.Duration(tuning.TrashRetentionSeconds) * time.Second,
+		archiver:           tuning.Archiver,
+		clock:              tuning.Clock,
+		checkpointInterval: time.Duration(tuning.CheckpointIntervalInSec) * time.Second,
+		operationTimeout:   time.Duration(tuning.OperationTimeoutInMs) * time.Millisecond,
+		slowQueryThreshold: time.Duration(tuning.SlowQueryThresholdInMs) * time.Millisecond,
+		cancelFunc:         cancel,
+	}
+	if check.IfNil(s.clock) {
+		s.clock = clock.NewSystemClock()
+	}
+	if s.checkpointInterval <= 0 {
+		s.checkpointInterval = defaultCheckpointInterval
+	}
+	if s.trashRetention <= 0 {
+		s.trashRetention = defaultTrashRetention
+	}
+	if s.operationTimeout <= 0 {
+		s.operationTimeout = defaultOperationTimeout
+	}
+	if s.slowQueryThreshold <= 0 {
+		s.slowQueryThreshold = defaultSlowQueryThreshold
+	}
+
+	s.startRetentionCleaner(ctx)
+	s.startWALCheckpointer(ctx)
+
+	return s, nil
+}
+
+func createDSNParameters(tuning SQLiteTuning) string {
+	busyTimeout := tuning.BusyTimeoutInMs
+	if busyTimeout <= 0 {
+		busyTimeout = defaultBusyTimeoutInMs
+	}
+
+	parameters := fmt.Sprintf("?_journal_mode=WAL&_busy_timeout=%d", busyTimeout)
+	if len(tuning.SynchronousMode) > 0 {
+		parameters += "&_synchronous=" + strings.ToUpper(tuning.SynchronousMode)
+	}
+	if tuning.CacheSizeInKB > 0 {
+		// negative values are interpreted by SQLite as KiB instead of pages
+		parameters += fmt.Sprintf("&_cache_size=-%d", tuning.CacheSizeInKB)
+	}
+
+	return parameters
+}
+
+// createReadDSNParameters returns the connection string parameters of the read connections. The journal mode is set by
+// the write connection, the read connections can not run write statements.
+func createReadDSNParameters(tuning SQLiteTuning) string {
+	busyTimeout := tuning.BusyTimeoutInMs
+	if busyTimeout <= 0 {
+		busyTimeout = defaultBusyTimeoutInMs
+	}
+
+	parameters := fmt.Sprintf("?_busy_timeout=%d&_query_only=true", busyTimeout)
+	if tuning.CacheSizeInKB > 0 {
+		parameters += fmt.Sprintf("&_cache_size=-%d", tuning.CacheSizeInKB)
+	}
+
+	return parameters
+}
+
+func openReadDB(db *sql.DB, dbPath string, tuning SQLiteTuning) (*sql.DB, error) {
+	if isInMemoryDatabase(dbPath) {
+		return db, nil
+	}
+
+	readDB, err := sql.Open("sqlite3", dbPath+createReadDSNParameters(tuning))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the read connections: %w", err)
+	}
+
+	maxReadConnections := tuning.MaxReadConnections
+	if maxReadConnections <= 0 {
+		maxReadConnections = defaultMaxReadConnections
+	}
+	readDB.SetMaxOpenConns(maxReadConnections)
+	readDB.SetMaxIdleConns(maxReadConnections)
+
+	return readDB, nil
+}
+
+func isInMemoryDatabase(dbPath string) bool {
+	return dbPath == inMemoryDatabasePath || strings.Contains(dbPath, "mode=memory")
+}
+
+func closeDatabases(db *sql.DB, readDB *sql.DB) {
+	if readDB != db {
+		_ = readDB.Close()
+	}
+	_ = db.Close()
+}
+
+var (
+	mutDrivers        sync.Mutex
+	registeredDrivers = make(map[int]string)
+)
+
+// sqliteDriverName returns the driver applying the WAL auto-checkpoint setting on each new connection, as the
+// setting is not supported in the connection string. The drivers can not be unregistered, so one is registered
+// for each distinct value.
+func sqliteDriverName(walAutoCheckpointPages int) string {
+	if walAutoCheckpointPages <= 0 {
+		return "sqlite3"
+	}
+
+	mutDrivers.Lock()
+	defer mutDrivers.Unlock()
+
+	name, found := registeredDrivers[walAutoCheckpointPages]
+	if found {
+		return name
+	}
+
+	name = fmt.Sprintf("sqlite3_wal_autocheckpoint_%d", walAutoCheckpointPages)
+	sql.Register(name, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			_, err := conn.Exec(fmt.Sprintf("PRAGMA wal_autocheckpoint = %d;", walAutoCheckpointPages), nil)
+			return err
+		},
+	})
+	registeredDrivers[walAutoCheckpointPages] = name
+
+	return name
+}
+
+func prepareDirectories(dbPath string) error {
+	return os.MkdirAll(filepath.Dir(dbPath), os.ModePerm)
+}
+
+// CleanRetainedMetrics executes the retention cleanup query synchronously.
+func (s *sqliteStorage) cleanRetainedMetrics(ctx context.Context) error {
+	ctx, finish := s.startOperation(ctx, "cleanRetainedMetrics")
+	defer finish()
+
+	nowSec := s.clock.Now().Unix()
+	err := s.removeExpiredValues(ctx, nowSec-int64(s.retentionSeconds))
+	if err != nil {
+		return err
+	}
+
+	// the availability data is kept for the longest SLA window, regardless of the metrics retention
+	availabilityCutoff := nowSec - common.MaxAvailabilityWindowSeconds - common.AvailabilityBucketSeconds
+	_, err = s.db.ExecContext(ctx, "DELETE FROM metric_availability WHERE bucket_start < ?", availabilityCutoff)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, "DELETE FROM sessions WHERE expires_at < ?", nowSec)
+	if err != nil {
+		return err
+	}
+
+	return s.purgeTrashedMetrics(ctx, nowSec-int64(s.trashRetention.Seconds()))
+}
+
+// removeExpiredValues deletes, after archiving them, the values recorded before the cutoff
+func (s *sqliteStorage) removeExpiredValues(ctx context.Context, cutoff int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	expiredChunks, err := removeExpiredChunks(ctx, tx, cutoff)
+	if err != nil {
+		return fmt.Errorf("failed to remove the expired chunks: %w", err)
+	}
+
+	err = s.archiveExpiredValues(ctx, tx, cutoff, expiredChunks)
+	if err != nil {
+		return fmt.Errorf("failed to archive the expired values: %w", err)
+	}
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM metrics_values WHERE recorded_at < ? AND last_seen_at < ?", cutoff, cutoff)
+	if err != nil {
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	numDeleted, err := result.RowsAffected()
+	if (err == nil && numDeleted > 0) || len(expiredChunks) > 0 {
+		// the latest or the aggregated values of the metrics may have changed
+		s.latest.invalidate()
+	}
+
+	return nil
+}
+
+func createSchema(db *sql.DB) error {
+
+	schema := `
+	CREATE TABLE IF NOT EXISTS metrics (
+		name               TEXT    NOT NULL PRIMARY KEY,
+		type               TEXT    NOT NULL,
+		num_aggregation    INTEGER NOT NULL DEFAULT 1,
+		display_order      INTEGER NOT NULL DEFAULT 0,
+		is_alarm_enabled   INTEGER NOT NULL DEFAULT 0,
+		gap_mode           TEXT    NOT NULL DEFAULT '',
+		tenant             TEXT    NOT NULL DEFAULT '',
+		expected_interval  INTEGER NOT NULL DEFAULT 0,
+		aggregation_mode   TEXT    NOT NULL DEFAULT 'last',
+		deleted_at         INTEGER NOT NULL DEFAULT 0,
+		store_on_change    INTEGER NOT NULL DEFAULT 0,
+		compressed         INTEGER NOT NULL DEFAULT 0
+	);
+
+	CREATE TABLE IF NOT EXISTS panel_configs (
+		name            TEXT    NOT NULL PRIMARY KEY,
+		display_order   INTEGER NOT NULL DEFAULT 0
+	);
+
+	CREATE TABLE IF NOT EXISTS custom_panel_metrics (
+		panel_name    TEXT    NOT NULL,
+		metric_name   TEXT    NOT NULL,
+		display_order INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (panel_name, metric_name)
+	);
+
+	CREATE TABLE IF NOT EXISTS metrics_values (
+		metric_name TEXT    NOT NULL REFERENCES metrics(name) ON DELETE CASCADE,
+		value       TEXT    NOT NULL,
+		value_num   NUMERIC,
+		recorded_at INTEGER NOT NULL,
+		received_at INTEGER NOT NULL DEFAULT 0,
+		last_seen_at INTEGER NOT NULL DEFAULT 0
+	);
+
+	CREATE TABLE IF NOT EXISTS metric_value_chunks (
+		metric_name       TEXT    NOT NULL REFERENCES metrics(name) ON DELETE CASCADE,
+		first_recorded_at INTEGER NOT NULL,
+		last_seen_at      INTEGER NOT NULL,
+		num_values        INTEGER NOT NULL,
+		num_numeric       INTEGER NOT NULL DEFAULT 0,
+		sum_value         REAL,
+		min_value         REAL,
+		max_value         REAL,
+		data              BLOB    NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS metric_tags (
+		metric_name TEXT NOT NULL REFERENCES metrics(name) ON DELETE CASCADE,
+		tag_key     TEXT NOT NULL,
+		tag_value   TEXT NOT NULL,
+		PRIMARY KEY (metric_name, tag_key)
+	);
+
+	CREATE TABLE IF NOT EXISTS metric_thresholds (
+		metric_name TEXT    NOT NULL PRIMARY KEY REFERENCES metrics(name) ON DELETE CASCADE,
+		warn        REAL,
+		crit        REAL,
+		below       INTEGER NOT NULL DEFAULT 0,
+		expected    TEXT    NOT NULL DEFAULT ''
+	);
+
+	CREATE TABLE IF NOT EXISTS metric_annotations (
+		id          INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		metric_name TEXT    NOT NULL REFERENCES metrics(name) ON DELETE CASCADE,
+		text        TEXT    NOT NULL,
+		recorded_at INTEGER NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS dashboards (
+		id   INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		name TEXT    NOT NULL UNIQUE
+	);
+
+	CREATE TABLE IF NOT EXISTS dashboard_panels (
+		dashboard_id  INTEGER NOT NULL REFERENCES dashboards(id) ON DELETE CASCADE,
+		panel_name    TEXT    NOT NULL,
+		display_order INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (dashboard_id, panel_name)
+	);
+
+	CREATE TABLE IF NOT EXISTS dashboard_metrics (
+		dashboard_id  INTEGER NOT NULL REFERENCES dashboards(id) ON DELETE CASCADE,
+		metric_name   TEXT    NOT NULL,
+		display_order INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (dashboard_id, metric_name)
+	);
+
+	CREATE TABLE IF NOT EXISTS alerts (
+		id              INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		metric_name     TEXT    NOT NULL,
+		state           TEXT    NOT NULL,
+		problem         TEXT    NOT NULL DEFAULT '',
+		started_at      INTEGER NOT NULL,
+		fired_at        INTEGER NOT NULL DEFAULT 0,
+		acknowledged_at INTEGER NOT NULL DEFAULT 0,
+		acknowledged_by TEXT    NOT NULL DEFAULT '',
+		resolved_at     INTEGER NOT NULL DEFAULT 0,
+		silenced_until  INTEGER NOT NULL DEFAULT 0
+	);
+
+	CREATE TABLE IF NOT EXISTS alert_events (
+		id          INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		alert_id    INTEGER NOT NULL REFERENCES alerts(id) ON DELETE CASCADE,
+		action      TEXT    NOT NULL,
+		note        TEXT    NOT NULL DEFAULT '',
+		actor       TEXT    NOT NULL DEFAULT '',
+		recorded_at INTEGER NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS metric_availability (
+		metric_name  TEXT    NOT NULL,
+		bucket_start INTEGER NOT NULL,
+		num_samples  INTEGER NOT NULL DEFAULT 0,
+		num_success  INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (metric_name, bucket_start)
+	) WITHOUT ROWID;
+
+	CREATE TABLE IF NOT EXISTS silences (
+		id         INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		pattern    TEXT    NOT NULL,
+		starts_at  INTEGER NOT NULL,
+		ends_at    INTEGER NOT NULL,
+		comment    TEXT    NOT NULL DEFAULT '',
+		created_by TEXT    NOT NULL DEFAULT '',
+		created_at INTEGER NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS sessions (
+		id          TEXT    NOT NULL PRIMARY KEY,
+		username    TEXT    NOT NULL,
+		tenant      TEXT    NOT NULL DEFAULT '',
+		role        TEXT    NOT NULL,
+		oidc        INTEGER NOT NULL DEFAULT 0,
+		user_agent  TEXT    NOT NULL DEFAULT '',
+		remote_addr TEXT    NOT NULL DEFAULT '',
+		created_at  INTEGER NOT NULL,
+		expires_at  INTEGER NOT NULL,
+		revoked_at  INTEGER NOT NULL DEFAULT 0
+	);
+
+	CREATE TABLE IF NOT EXISTS agents (
+		name        TEXT    NOT NULL PRIMARY KEY,
+		tenant      TEXT    NOT NULL DEFAULT '',
+		version     TEXT    NOT NULL DEFAULT '',
+		commit_id   TEXT    NOT NULL DEFAULT '',
+		config_hash TEXT    NOT NULL DEFAULT '',
+		updated_at  INTEGER NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS agent_logs (
+		id          INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		agent       TEXT    NOT NULL,
+		tenant      TEXT    NOT NULL DEFAULT '',
+		level       TEXT    NOT NULL,
+		component   TEXT    NOT NULL DEFAULT '',
+		message     TEXT    NOT NULL,
+		fields      TEXT    NOT NULL DEFAULT '{}',
+		recorded_at INTEGER NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS totp_enrollments (
+		username       TEXT    NOT NULL PRIMARY KEY,
+		secret         TEXT    NOT NULL,
+		enabled        INTEGER NOT NULL DEFAULT 0,
+		last_step      INTEGER NOT NULL DEFAULT 0,
+		recovery_codes TEXT    NOT NULL DEFAULT '[]',
+		created_at     INTEGER NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		name       TEXT    NOT NULL PRIMARY KEY,
+		applied_at INTEGER NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_metrics_values_name ON metrics_values(metric_name);
+	CREATE INDEX IF NOT EXISTS idx_metrics_values_recorded_at ON metrics_values(recorded_at);
+	CREATE INDEX IF NOT EXISTS idx_metrics_values_name_recorded_at ON metrics_values(metric_name, recorded_at);
+	CREATE INDEX IF NOT EXISTS idx_metric_value_chunks_name_first_recorded_at ON metric_value_chunks(metric_name, first_recorded_at);
+	CREATE INDEX IF NOT EXISTS idx_metric_tags_key_value ON metric_tags(tag_key, tag_value);
+	CREATE INDEX IF NOT EXISTS idx_metric_annotations_name_recorded_at ON metric_annotations(metric_name, recorded_at);
+	CREATE INDEX IF NOT EXISTS idx_alerts_state ON alerts(state);
+	CREATE INDEX IF NOT EXISTS idx_alerts_metric_name_started_at ON alerts(metric_name, started_at);
+	CREATE INDEX IF NOT EXISTS idx_alert_events_alert_id ON alert_events(alert_id);
+	CREATE INDEX IF NOT EXISTS idx_silences_ends_at ON silences(ends_at);
+	CREATE INDEX IF NOT EXISTS idx_metric_availability_bucket_start ON metric_availability(bucket_start);
+	CREATE INDEX IF NOT EXISTS idx_sessions_username ON sessions(username);
+	CREATE INDEX IF NOT EXISTS idx_sessions_expires_at ON sessions(expires_at);
+	CREATE INDEX IF NOT EXISTS idx_agent_logs_agent ON agent_logs(agent, id);
+	`
+
+	_, err := db.Exec(schema)
+	if err != nil {
+		return fmt.Errorf("failed to create schema: %w", err)
+	}
+
+	err = applyMigrations(db)
+	if err != nil {
+		return err
+	}
+
+	// Make sure ON DELETE CASCADE works if enabled globally
+	_, _ = db.Exec("PRAGMA foreign_keys = ON;")
+
+	return nil
+}
+
+// Backup writes a consistent snapshot of the database to the provided path, which must not exist. The snapshot is
+// taken with VACUUM INTO on a read connection, as a read transaction, so the writes of the reports are not blocked
+// while it is written. It is not bound by the operations timeout, as copying a large database can take longer: only
+// the provided context can interrupt it.
+func (s *sqliteStorage) Backup(ctx context.Context, path string) error {
+	conn, err := s.readDB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to back up the database: %w", err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	if s.readDB != s.db {
+		// the read connections are query only, which also rejects the file written by VACUUM INTO
+		_, err = conn.ExecContext(ctx, "PRAGMA query_only = OFF;")
+		if err != nil {
+			return fmt.Errorf("failed to back up the database: %w", err)
+		}
+		defer restoreQueryOnly(conn)
+	}
+
+	_, err = conn.ExecContext(ctx, "VACUUM INTO ?", path)
+	if err != nil {
+		return fmt.Errorf("failed to back up the database: %w", err)
+	}
+
+	return nil
+}
+
+// restoreQueryOnly makes the read connection query only again, discarding it if that fails so it is never reused
+// as a read connection able to write
+func restoreQueryOnly(conn *sql.Conn) {
+	_, err := conn.ExecContext(context.Background(), "PRAGMA query_only = ON;")
+	if err == nil {
+		return
+	}
+
+	log.Warn("failed to restore the query only read connection, discarding it", "error", err)
+	_ = conn.Raw(func(driverConn any) error {
+		return driver.ErrBadConn
+	})
+}
+
+// GetSchemaInfo returns the schema version, the applied migrations and the row count of each table
+func (s *sqliteStorage) GetSchemaInfo(ctx context.Context) (*common.SchemaInfo, error) {
+	ctx, finish := s.startOperation(ctx, "GetSchemaInfo")
+	defer finish()
+
+	info := &common.SchemaInfo{
+		Migrations: make([]common.MigrationInfo, 0),
+		Tables:     make([]common.TableInfo, 0),
+	}
+
+	err := s.readDB.QueryRowContext(ctx, "PRAGMA user_version;").Scan(&info.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the schema version: %w", err)
+	}
+
+	rows, err := s.readDB.QueryContext(ctx, "SELECT name, applied_at FROM schema_migrations ORDER BY applied_at, rowid")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the applied migrations: %w", err)
+	}
+	for rows.Next() {
+		var migration common.MigrationInfo
+		err = rows.Scan(&migration.Name, &migration.AppliedAt)
+		if err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		info.Migrations = append(info.Migrations, migration)
+	}
+	_ = rows.Close()
+
+	rows, err = s.readDB.QueryContext(ctx, "SELECT name, sql FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the tables: %w", err)
+	}
+	for rows.Next() {
+		var table common.TableInfo
+		err = rows.Scan(&table.Name, &table.Definition)
+		if err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		info.Tables = append(info.Tables, table)
+	}
+	_ = rows.Close()
+
+	for i := range info.Tables {
+		query := fmt.Sprintf(`SELECT COUNT(*) FROM "%s"`, strings.ReplaceAll(info.Tables[i].Name, `"`, `""`))
+		err = s.readDB.QueryRowContext(ctx, query).Scan(&info.Tables[i].NumRows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count the rows of table %s: %w", info.Tables[i].Name, err)
+		}
+	}
+
+	return info, nil
 }
 
-// NewSQLiteStorage creates the database, schema, and starts the retention cleaner
-func NewSQLiteStorage(dbPath string, retentionSeconds int) (*sqliteStorage, error) {
-	err := prepareDirectories(dbPath)
+// SaveMetric upserts the metric definition, inserts the value, and prunes old entries based on numAggregation
+func (s *sqliteStorage) SaveMetric(ctx context.Context, name string, metricType string, numAggregation int, valString string, recordedAt int64) error {
+	ctx, finish := s.startOperation(ctx, "SaveMetric")
+	defer finish()
+
+	s.mutSave.Lock()
+	defer s.mutSave.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	record := common.MetricRecord{
+		Name:           name,
+		Type:           metricType,
+		NumAggregation: numAggregation,
+		Value:          valString,
+		RecordedAt:     recordedAt,
+		ReceivedAt:     recordedAt,
+	}
+	value, err := s.saveMetricValue(ctx, tx, record)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	s.latest.apply([]latestValue{value})
+
+	return nil
+}
+
+// SaveMetrics persists a batch of metric values and their tags in a single transaction. If one of the records
+// fails, none of them is saved.
+func (s *sqliteStorage) SaveMetrics(ctx context.Context, records []common.MetricRecord) error {
+	ctx, finish := s.startOperation(ctx, "SaveMetrics")
+	defer finish()
+
+	if len(records) == 0 {
+		return nil
+	}
+
+	s.mutSave.Lock()
+	defer s.mutSave.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	values := make([]latestValue, 0, len(records))
+	for _, record := range records {
+		if record.ReceivedAt == 0 {
+			record.ReceivedAt = record.RecordedAt
+		}
+		value, err := s.saveMetricValue(ctx, tx, record)
+		if err != nil {
+			return fmt.Errorf("%w for metric %s", err, record.Name)
+		}
+		values = append(values, value)
+
+		err = saveMetricTags(ctx, tx, record.Name, record.Tags)
+		if err != nil {
+			return fmt.Errorf("%w for metric %s", err, record.Name)
+		}
+
+		err = saveMetricInterval(ctx, tx, record.Name, record.ExpectedInterval)
+		if err != nil {
+			return fmt.Errorf("%w for metric %s", err, record.Name)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	s.latest.apply(values)
+
+	return nil
+}
+
+// SaveMetricInterval stores the number of seconds between two values of a metric, as reported by its agent. An unknown
+// (0) interval keeps the stored one.
+func (s *sqliteStorage) SaveMetricInterval(ctx context.Context, name string, intervalInSeconds int) error {
+	ctx, finish := s.startOperation(ctx, "SaveMetricInterval")
+	defer finish()
+
+	if intervalInSeconds <= 0 {
+		return nil
+	}
+
+	s.mutSave.Lock()
+	defer s.mutSave.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	err = saveMetricInterval(ctx, tx, name, intervalInSeconds)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	s.latest.applyInterval(name, intervalInSeconds)
+
+	return nil
+}
+
+func saveMetricInterval(ctx context.Context, tx *sql.Tx, name string, intervalInSeconds int) error {
+	if intervalInSeconds <= 0 {
+		return nil
+	}
+
+	_, err := tx.ExecContext(ctx, "UPDATE metrics SET expected_interval = ? WHERE name = ? AND expected_interval <> ?",
+		intervalInSeconds, name, intervalInSeconds)
+	if err != nil {
+		return fmt.Errorf("failed to update the metric interval: %w", err)
+	}
+
+	return nil
+}
+
+// saveMetricValue appends a value to a metric of the tenant. The metric names are unique across tenants, so a value
+// reported for a metric owned by another tenant is rejected. The value is placed in the history by RecordedAt, the
+// agent poll timestamp, while ReceivedAt keeps the time the server got it. A value reported for a deleted metric
+// restores it. For the metrics stored on change, a value identical to the latest stored one only extends its last seen
+// timestamp. It returns the change of the latest value, applied to the cache once the transaction is committed.
+func (s *sqliteStorage) saveMetricValue(ctx context.Context, tx *sql.Tx, record common.MetricRecord) (latestValue, error) {
+	name, metricType, valString, recordedAt := record.Name, record.Type, record.Value, record.RecordedAt
+	value := latestValue{record: record}
+
+	var aggregationMode common.AggregationMode
+	var storeOnChange, compressed int
+	var deletedAt int64
+	err := tx.StmtContext(ctx, s.statements.upsertMetric).QueryRowContext(ctx, name, metricType, record.NumAggregation, record.Tenant).Scan(&aggregationMode, &storeOnChange, &compressed, &deletedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return value, common.ErrMetricTenantMismatch
+	}
+	if err != nil {
+		return value, fmt.Errorf("failed to upsert metric definition: %w", err)
+	}
+	if deletedAt > 0 {
+		_, err = tx.ExecContext(ctx, "UPDATE metrics SET deleted_at = 0 WHERE name = ?", name)
+		if err != nil {
+			return value, fmt.Errorf("failed to restore the deleted metric: %w", err)
+		}
+		log.Debug("restored the deleted metric reported again", "metric", name)
+		value.restored = true
+	}
+
+	compacted := false
+	if storeOnChange == 1 {
+		compacted, err = s.touchUnchangedValue(ctx, tx, name, valString, recordedAt)
+		if err != nil {
+			return value, err
+		}
+	}
+	if !compacted {
+		_, err = tx.StmtContext(ctx, s.statements.insertValue).ExecContext(ctx, name, valString, numericValue(metricType, valString), recordedAt, record.ReceivedAt)
+		if err != nil {
+			return value, fmt.Errorf("failed to insert metric value: %w", err)
+		}
+
+		if compressed == 1 {
+			err = trimValueChunks(ctx, tx, name, metricType, record.NumAggregation)
+			if err != nil {
+				return value, fmt.Errorf("failed to trim metric aggregation window: %w", err)
+			}
+		}
+
+		_, err = tx.StmtContext(ctx, s.statements.trimValues).ExecContext(ctx, name, name, record.NumAggregation)
+		if err != nil {
+			return value, fmt.Errorf("failed to trim metric aggregation window: %w", err)
+		}
+
+		if compressed == 1 {
+			err = packValues(ctx, tx, name, metricType)
+			if err != nil {
+				return value, fmt.Errorf("failed to pack the metric values: %w", err)
+			}
+		}
+	}
+
+	numSuccess := 1
+	if metricType == common.MetricTypeBool && valString != "true" {
+		numSuccess = 0
+	}
+	_, err = tx.StmtContext(ctx, s.statements.upsertAvailability).ExecContext(ctx, name, recordedAt-recordedAt%common.AvailabilityBucketSeconds, numSuccess)
+	if err != nil {
+		return value, fmt.Errorf("failed to update the metric availability: %w", err)
+	}
+
+	if aggregationMode == common.AggregationModeLast {
+		return value, nil
+	}
+
+	var aggregated sql.NullFloat64
+	err = tx.StmtContext(ctx, s.statements.aggregateValues).QueryRowContext(ctx, string(aggregationMode), name, name).Scan(&aggregated)
+	if err != nil {
+		return value, fmt.Errorf("failed to aggregate the metric values: %w", err)
+	}
+	if aggregated.Valid {
+		value.aggregatedValue = strconv.FormatFloat(aggregated.Float64, 'f', -1, 64)
+	}
+
+	return value, nil
+}
+
+// touchUnchangedValue extends the last seen timestamp of the latest stored value of the metric if it equals the
+// reported one. A value reported out of order is never compacted, it is inserted in its place of the history.
+func (s *sqliteStorage) touchUnchangedValue(ctx context.Context, tx *sql.Tx, name string, valString string, recordedAt int64) (bool, error) {
+	var rowID, latestRecordedAt int64
+	var latestValue string
+	err := tx.StmtContext(ctx, s.statements.latestValue).QueryRowContext(ctx, name).Scan(&rowID, &latestValue, &latestRecordedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to load the latest metric value: %w", err)
+	}
+	if latestValue != valString || recordedAt < latestRecordedAt {
+		return false, nil
+	}
+
+	_, err = tx.StmtContext(ctx, s.statements.touchValue).ExecContext(ctx, recordedAt, rowID)
+	if err != nil {
+		return false, fmt.Errorf("failed to update the last seen timestamp: %w", err)
+	}
+
+	return true, nil
+}
+
+// numericValue returns the native numeric representation of the value for the numeric metric types or nil otherwise.
+// Values that do not fit into a signed 64-bit integer are stored as REAL.
+func numericValue(metricType string, valString string) interface{} {
+	if !common.IsNumericMetricType(metricType) {
+		return nil
+	}
+
+	valUint, err := strconv.ParseUint(valString, 10, 64)
+	if err == nil && valUint <= math.MaxInt64 {
+		return int64(valUint)
+	}
+
+	valFloat, err := strconv.ParseFloat(valString, 64)
+	if err != nil {
+		return nil
+	}
+
+	return valFloat
+}
+
+// sortColumns maps the supported sort fields to their SQL columns
+var sortColumns = map[common.MetricsSortField]string{
+	common.SortByName:         "m.name",
+	common.SortByType:         "m.type",
+	common.SortByDisplayOrder: "m.display_order",
+	common.SortByRecordedAt:   "v.latest_at",
+}
+
+// GetLatestMetrics fetches the most recent value for each metric
+func (s *sqliteStorage) GetLatestMetrics(ctx context.Context) ([]common.MetricHistory, error) {
+	return s.GetLatestMetricsFiltered(ctx, common.MetricsFilter{})
+}
+
+// GetLatestMetricsFiltered fetches the most recent value for each metric matching the provided filter. The metrics are
+// served from the latest values cache, the database being queried only when the cache is not loaded.
+func (s *sqliteStorage) GetLatestMetricsFiltered(ctx context.Context, filter common.MetricsFilter) ([]common.MetricHistory, error) {
+	ctx, finish := s.startOperation(ctx, "GetLatestMetricsFiltered")
+	defer finish()
+
+	results, found, err := s.latest.get(filter)
+	if found {
+		return results, err
+	}
+
+	return s.latest.load(filter, func() ([]common.MetricHistory, error) {
+		return s.getLatestMetricsFiltered(ctx, common.MetricsFilter{})
+	})
+}
+
+func (s *sqliteStorage) getLatestMetricsFiltered(ctx context.Context, filter common.MetricsFilter) ([]common.MetricHistory, error) {
+	query := `
+		SELECT m.name, m.type, m.num_aggregation, m.display_order, m.is_alarm_enabled, m.gap_mode, m.tenant, m.expected_interval, v.value, v.latest_at,
+			m.aggregation_mode, m.store_on_change, m.compressed,
+			CASE m.aggregation_mode
+				WHEN 'avg' THEN a.avg_value
+				WHEN 'min' THEN a.min_value
+				WHEN 'max' THEN a.max_value
+				WHEN 'sum' THEN a.sum_value
+			END
+		FROM metrics m
+		LEFT JOIN (
+			SELECT metric_name, value, MAX(recorded_at, last_seen_at) AS latest_at,
+				ROW_NUMBER() OVER(PARTITION BY metric_name ORDER BY recorded_at DESC) as rn
+			FROM metrics_values
+		) v ON m.name = v.metric_name AND v.rn = 1
+		LEFT JOIN (
+			SELECT metric_name, SUM(sum_value) * 1.0 / SUM(num_numeric) AS avg_value, MIN(min_value) AS min_value,
+				MAX(max_value) AS max_value, SUM(sum_value) AS sum_value
+			FROM (
+				SELECT metric_name, SUM(value_num) AS sum_value, COUNT(value_num) AS num_numeric,
+					MIN(value_num) AS min_value, MAX(value_num) AS max_value
+				FROM metrics_values
+				WHERE metric_name IN (SELECT name FROM metrics WHERE aggregation_mode <> 'last')
+				GROUP BY metric_name
+				UNION ALL
+				SELECT metric_name, sum_value, num_numeric, min_value, max_value
+				FROM metric_value_chunks
+				WHERE metric_name IN (SELECT name FROM metrics WHERE aggregation_mode <> 'last')
+			)
+			GROUP BY metric_name
+		) a ON m.name = a.metric_name
+	`
+
+	where, args := metricsFilterClause(filter)
+	query += where
+
+	if filter.SortBy != "" {
+		column, found := sortColumns[filter.SortBy]
+		if !found {
+			return nil, fmt.Errorf("unsupported sort field %s", filter.SortBy)
+		}
+
+		direction := "ASC"
+		if filter.Descending {
+			direction = "DESC"
+		}
+		query += fmt.Sprintf(" ORDER BY %s %s, m.name %s", column, direction, direction)
+	}
+
+	rows, err := s.readDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var results []common.MetricHistory
+
+	for rows.Next() {
+		var h common.MetricHistory
+		var val sql.NullString
+		var recAt sql.NullInt64
+		var isAlarm int
+		var storeOnChange, compressed int
+		var aggregated sql.NullFloat64
+
+		err = rows.Scan(&h.Name, &h.Type, &h.NumAggregation, &h.DisplayOrder, &isAlarm, &h.GapMode, &h.Tenant, &h.ExpectedInterval, &val, &recAt,
+			&h.AggregationMode, &storeOnChange, &compressed, &aggregated)
+		if err != nil {
+			return nil, err
+		}
+
+		h.IsAlarmEnabled = isAlarm == 1
+		h.StoreOnChange = storeOnChange == 1
+		h.Compressed = compressed == 1
+		if aggregated.Valid {
+			h.AggregatedValue = strconv.FormatFloat(aggregated.Float64, 'f', -1, 64)
+		}
+
+		v := ""
+		if val.Valid {
+			v = val.String
+		}
+		var r int64
+		if recAt.Valid {
+			r = recAt.Int64
+		}
+
+		h.History = []common.MetricValue{
+			{
+				Value:      v,
+				RecordedAt: r,
+			},
+		}
+		results = append(results, h)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := s.getAllTags(ctx)
+	if err != nil {
+		return nil, err
+	}
+	thresholds, err := s.getAllThresholds(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		results[i].Tags = tags[results[i].Name]
+		results[i].Thresholds = thresholds[results[i].Name]
+	}
+
+	return results, nil
+}
+
+// metricsFilterClause returns the WHERE clause, on the metrics table aliased m, selecting the metrics of the filter
+func metricsFilterClause(filter common.MetricsFilter) (string, []interface{}) {
+	// the deleted metrics are hidden until restored or purged
+	conditions := []string{"m.deleted_at = 0"}
+	args := make([]interface{}, 0)
+	if filter.Name != "" {
+		conditions = append(conditions, "m.name = ?")
+		args = append(args, filter.Name)
+	}
+	if filter.Prefix != "" {
+		// substr instead of LIKE so the '%' and '_' characters in names are not treated as wildcards
+		conditions = append(conditions, "substr(m.name, 1, length(?)) = ?")
+		args = append(args, filter.Prefix, filter.Prefix)
+	}
+	if filter.Type != "" {
+		conditions = append(conditions, "m.type = ?")
+		args = append(args, filter.Type)
+	}
+	if filter.Tenant != "" {
+		conditions = append(conditions, "m.tenant = ?")
+		args = append(args, filter.Tenant)
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// GetMetricsHistoriesFiltered returns the definitions of the metrics matching the filter, as GetLatestMetricsFiltered
+// does, each with all its retained values in chronological order instead of the latest one. The values are fetched
+// with a single query.
+func (s *sqliteStorage) GetMetricsHistoriesFiltered(ctx context.Context, filter common.MetricsFilter) ([]common.MetricHistory, error) {
+	ctx, finish := s.startOperation(ctx, "GetMetricsHistoriesFiltered")
+	defer finish()
+
+	results, err := s.getLatestMetricsFiltered(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	where, args := metricsFilterClause(filter)
+	rows, err := s.readDB.QueryContext(ctx, `
+		SELECT v.metric_name, v.value, v.recorded_at, v.last_seen_at
+		FROM metrics_values v
+		JOIN metrics m ON m.name = v.metric_name`+where+`
+		ORDER BY v.metric_name, v.recorded_at
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("values query failed: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	values := make(map[string][]common.MetricValue, len(results))
+	for rows.Next() {
+		var name string
+		var value common.MetricValue
+		err = rows.Scan(&name, &value.Value, &value.RecordedAt, &value.LastSeenAt)
+		if err != nil {
+			return nil, err
+		}
+		values[name] = append(values[name], value)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	packed, err := loadChunkValues(ctx, s.readDB, `
+		SELECT c.metric_name, c.data
+		FROM metric_value_chunks c
+		JOIN metrics m ON m.name = c.metric_name`+where, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range results {
+		results[i].History = mergeValues(values[results[i].Name], packed[results[i].Name])
+	}
+
+	return results, nil
+}
+
+func (s *sqliteStorage) getAllTags(ctx context.Context) (map[string]map[string]string, error) {
+	rows, err := s.readDB.QueryContext(ctx, "SELECT metric_name, tag_key, tag_value FROM metric_tags")
+	if err != nil {
+		return nil, fmt.Errorf("tags query failed: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	tags := make(map[string]map[string]string)
+	for rows.Next() {
+		var name, key, value string
+		err = rows.Scan(&name, &key, &value)
+		if err != nil {
+			return nil, err
+		}
+
+		if tags[name] == nil {
+			tags[name] = make(map[string]string)
+		}
+		tags[name][key] = value
+	}
+
+	return tags, rows.Err()
+}
+
+func (s *sqliteStorage) getMetricTags(ctx context.Context, name string) (map[string]string, error) {
+	rows, err := s.readDB.QueryContext(ctx, "SELECT tag_key, tag_value FROM metric_tags WHERE metric_name = ?", name)
+	if err != nil {
+		return nil, fmt.Errorf("tags query failed: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var tags map[string]string
+	for rows.Next() {
+		var key, value string
+		err = rows.Scan(&key, &value)
+		if err != nil {
+			return nil, err
+		}
+
+		if tags == nil {
+			tags = make(map[string]string)
+		}
+		tags[key] = value
+	}
+
+	return tags, rows.Err()
+}
+
+// SaveMetricTags upserts the provided tags of a metric. Existing tags with other keys are kept.
+func (s *sqliteStorage) SaveMetricTags(ctx context.Context, name string, tags map[string]string) error {
+	ctx, finish := s.startOperation(ctx, "SaveMetricTags")
+	defer finish()
+
+	if len(tags) == 0 {
+		return nil
+	}
+
+	s.mutSave.Lock()
+	defer s.mutSave.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	err = saveMetricTags(ctx, tx, name, tags)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	s.latest.applyTags(name, tags)
+
+	return nil
+}
+
+func saveMetricTags(ctx context.Context, tx *sql.Tx, name string, tags map[string]string) error {
+	for key, value := range tags {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO metric_tags (metric_name, tag_key, tag_value)
+			VALUES (?, ?, ?)
+			ON CONFLICT(metric_name, tag_key) DO UPDATE SET
+				tag_value=excluded.tag_value
+			WHERE tag_value <> excluded.tag_value
+		`, name, key, value)
+		if err != nil {
+			return fmt.Errorf("failed to upsert metric tag %s: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+// GetMetricHistory returns the metric configuration and up to 'num_aggregation' historical values
+func (s *sqliteStorage) GetMetricHistory(ctx context.Context, name string) (*common.MetricHistory, error) {
+	ctx, finish := s.startOperation(ctx, "GetMetricHistory")
+	defer finish()
+
+	return s.getMetricHistory(ctx, name, -1)
+}
+
+// GetMetricHistorySince returns the definition and the values recorded after the provided timestamp for a specific
+// metric, together with the annotations recorded after it
+func (s *sqliteStorage) GetMetricHistorySince(ctx context.Context, name string, since int64) (*common.MetricHistory, error) {
+	ctx, finish := s.startOperation(ctx, "GetMetricHistorySince")
+	defer finish()
+
+	return s.getMetricHistory(ctx, name, since)
+}
+
+// getMetricHistory returns the values recorded after the provided timestamp, all of them if it is negative
+func (s *sqliteStorage) getMetricHistory(ctx context.Context, name string, since int64) (*common.MetricHistory, error) {
+	var h common.MetricHistory
+	var isAlarm, storeOnChange, compressed int
+
+	err := s.readDB.QueryRowContext(ctx, "SELECT name, type, num_aggregation, display_order, is_alarm_enabled, gap_mode, tenant, expected_interval, aggregation_mode, store_on_change, compressed FROM metrics WHERE name = ? AND deleted_at = 0", name).Scan(&h.Name, &h.Type, &h.NumAggregation, &h.DisplayOrder, &isAlarm, &h.GapMode, &h.Tenant, &h.ExpectedInterval, &h.AggregationMode, &storeOnChange, &compressed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, common.ErrMetricNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	h.IsAlarmEnabled = isAlarm == 1
+	h.StoreOnChange = storeOnChange == 1
+	h.Compressed = compressed == 1
+
+	h.Tags, err = s.getMetricTags(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.readDB.QueryContext(ctx, `
+		SELECT value, recorded_at, last_seen_at
+		FROM metrics_values 
+		WHERE metric_name = ? AND MAX(recorded_at, last_seen_at) > ?
+		ORDER BY recorded_at
+	`, name, since)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	for rows.Next() {
+		var val string
+		var recAt, lastSeenAt int64
+
+		err = rows.Scan(&val, &recAt, &lastSeenAt)
+		if err != nil {
+			return nil, err
+		}
+
+		h.History = append(h.History, common.MetricValue{Value: val, RecordedAt: recAt, LastSeenAt: lastSeenAt})
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	packed, err := loadChunkValues(ctx, s.readDB, "SELECT metric_name, data FROM metric_value_chunks WHERE metric_name = ? AND last_seen_at > ?", name, since)
+	if err != nil {
+		return nil, err
+	}
+	packedSince := make([]common.MetricValue, 0, len(packed[name]))
+	for _, value := range packed[name] {
+		if max(value.RecordedAt, value.LastSeenAt) > since {
+			packedSince = append(packedSince, value)
+		}
+	}
+	h.History = mergeValues(h.History, packedSince)
+
+	// only the annotations overlapping the returned history are relevant for the charts
+	annotationsSince := since + 1
+	if since < 0 {
+		annotationsSince = 0
+		if len(h.History) > 0 {
+			annotationsSince = h.History[0].RecordedAt
+		}
+	}
+	h.Annotations, err = s.getMetricAnnotations(ctx, name, annotationsSince)
+	if err != nil {
+		return nil, err
+	}
+
+	return &h, nil
+}
+
+// GetMetricTenant returns the tenant owning a metric, empty for the default tenant
+func (s *sqliteStorage) GetMetricTenant(ctx context.Context, name string) (string, error) {
+	ctx, finish := s.startOperation(ctx, "GetMetricTenant")
+	defer finish()
+
+	var tenant string
+	err := s.readDB.QueryRowContext(ctx, "SELECT tenant FROM metrics WHERE name = ?", name).Scan(&tenant)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", common.ErrMetricNotFound
+	}
+
+	return tenant, err
+}
+
+// AddMetricAnnotation attaches a timestamped note to an existing metric and returns its ID
+func (s *sqliteStorage) AddMetricAnnotation(ctx context.Context, name string, text string, recordedAt int64) (int64, error) {
+	ctx, finish := s.startOperation(ctx, "AddMetricAnnotation")
+	defer finish()
+
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO metric_annotations (metric_name, text, recorded_at)
+		SELECT name, ?, ? FROM metrics WHERE name = ? AND deleted_at = 0
+	`, text, recordedAt, name)
+	if err != nil {
+		return 0, err
+	}
+
+	numInserted, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if numInserted == 0 {
+		return 0, common.ErrMetricNotFound
+	}
+
+	return result.LastInsertId()
+}
+
+// GetMetricAnnotations returns all the annotations of a metric, ordered by their timestamp
+func (s *sqliteStorage) GetMetricAnnotations(ctx context.Context, name string) ([]common.MetricAnnotation, error) {
+	ctx, finish := s.startOperation(ctx, "GetMetricAnnotations")
+	defer finish()
+
+	return s.getMetricAnnotations(ctx, name, 0)
+}
+
+func (s *sqliteStorage) getMetricAnnotations(ctx context.Context, name string, since int64) ([]common.MetricAnnotation, error) {
+	rows, err := s.readDB.QueryContext(ctx, `
+		SELECT id, text, recorded_at
+		FROM metric_annotations
+		WHERE metric_name = ? AND recorded_at >= ?
+		  AND metric_name IN (SELECT name FROM metrics WHERE deleted_at = 0)
+		ORDER BY recorded_at, id
+	`, name, since)
+	if err != nil {
+		return nil, fmt.Errorf("annotations query failed: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	annotations := make([]common.MetricAnnotation, 0)
+	for rows.Next() {
+		var annotation common.MetricAnnotation
+		err = rows.Scan(&annotation.ID, &annotation.Text, &annotation.RecordedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		annotations = append(annotations, annotation)
+	}
+
+	return annotations, rows.Err()
+}
+
+// DeleteMetricAnnotation removes an annotation of a metric
+func (s *sqliteStorage) DeleteMetricAnnotation(ctx context.Context, name string, id int64) error {
+	ctx, finish := s.startOperation(ctx, "DeleteMetricAnnotation")
+	defer finish()
+
+	result, err := s.db.ExecContext(ctx, "DELETE FROM metric_annotations WHERE metric_name = ? AND id = ?", name, id)
+	if err != nil {
+		return err
+	}
+
+	numDeleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if numDeleted == 0 {
+		return common.ErrAnnotationNotFound
+	}
+
+	return nil
+}
+
+// DeleteMetric moves a metric to the trash: it is hidden, with its history, until restored or purged after the trash
+// retention
+func (s *sqliteStorage) DeleteMetric(ctx context.Context, name string) error {
+	ctx, finish := s.startOperation(ctx, "DeleteMetric")
+	defer finish()
+	defer s.latest.invalidate()
+
+	_, err := s.db.ExecContext(ctx, "UPDATE metrics SET deleted_at = ? WHERE name = ? AND deleted_at = 0", s.clock.Now().Unix(), name)
+	return err
+}
+
+// DeleteMetricsByPrefix deletes, after archiving them, all the metrics whose names start with the provided prefix,
+// together with their values and tags. Returns the number of deleted metrics.
+func (s *sqliteStorage) DeleteMetricsByPrefix(ctx context.Context, prefix string) (int64, error) {
+	ctx, finish := s.startOperation(ctx, "DeleteMetricsByPrefix")
+	defer finish()
+	defer s.latest.invalidate()
+
+	if len(prefix) == 0 {
+		return 0, errEmptyPrefix
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// substr instead of LIKE so the '%' and '_' characters in names are not treated as wildcards
+	err = s.archiveMetrics(ctx, tx, "substr(name, 1, length(?)) = ?", prefix, prefix)
+	if err != nil {
+		return 0, fmt.Errorf("failed to archive the metrics: %w", err)
+	}
+
+	for _, table := range []string{"metric_tags", "metric_annotations", "dashboard_metrics", "custom_panel_metrics", "metric_thresholds", "metric_availability", "metrics_values", "metric_value_chunks"} {
+		query := fmt.Sprintf("DELETE FROM %s WHERE substr(metric_name, 1, length(?)) = ?", table)
+		_, err = tx.ExecContext(ctx, query, prefix, prefix)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM metrics WHERE substr(name, 1, length(?)) = ?", prefix, prefix)
+	if err != nil {
+		return 0, err
+	}
+
+	numDeleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return numDeleted, tx.Commit()
+}
+
+// RenameMetric renames a metric, preserving its configuration, tags and history
+func (s *sqliteStorage) RenameMetric(ctx context.Context, name string, newName string) error {
+	ctx, finish := s.startOperation(ctx, "RenameMetric")
+	defer finish()
+	defer s.latest.invalidate()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var exists int
+	err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM metrics WHERE name = ?", newName).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists > 0 {
+		return fmt.Errorf("%w: %s", common.ErrMetricAlreadyExists, newName)
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO metrics (name, type, num_aggregation, display_order, is_alarm_enabled, gap_mode, tenant, expected_interval, aggregation_mode, store_on_change, compressed)
+		SELECT ?, type, num_aggregation, display_order, is_alarm_enabled, gap_mode, tenant, expected_interval, aggregation_mode, store_on_change, compressed
+		FROM metrics WHERE name = ? AND deleted_at = 0
+	`, newName, name)
+	if err != nil {
+		return err
+	}
+	numInserted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if numInserted == 0 {
+		return common.ErrMetricNotFound
+	}
+
+	err = renameMetricReferences(ctx, tx, name, newName)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM metrics WHERE name = ?", name)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// renameMetricReferences moves the values, the annotations, the dashboard entries, the thresholds, the availability
+// and the tags of a metric to its new name. The new metric row must already exist.
+func renameMetricReferences(ctx context.Context, tx *sql.Tx, name string, newName string) error {
+	_, err := tx.ExecContext(ctx, "UPDATE metrics_values SET metric_name = ? WHERE metric_name = ?", newName, name)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE metric_value_chunks SET metric_name = ? WHERE metric_name = ?", newName, name)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE metric_annotations SET metric_name = ? WHERE metric_name = ?", newName, name)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE dashboard_metrics SET metric_name = ? WHERE metric_name = ?", newName, name)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE custom_panel_metrics SET metric_name = ? WHERE metric_name = ?", newName, name)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE metric_thresholds SET metric_name = ? WHERE metric_name = ?", newName, name)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE metric_availability SET metric_name = ? WHERE metric_name = ?", newName, name)
+	if err != nil {
+		return err
+	}
+
+	return renameMetricTags(ctx, tx, name, newName)
+}
+
+// renameMetricTags moves the tags to the new name. The explicitly reported tags are kept, even the vm, node and kind
+// ones, while the tags derived from the old name are derived again from the new one.
+func renameMetricTags(ctx context.Context, tx *sql.Tx, name string, newName string) error {
+	oldDerived := common.ParseTagsFromName(name)
+	newDerived := common.ParseTagsFromName(newName)
+
+	_, err := tx.ExecContext(ctx, "UPDATE metric_tags SET metric_name = ? WHERE metric_name = ?", newName, name)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range []string{common.TagVM, common.TagNode, common.TagKind} {
+		var storedValue string
+		err = tx.QueryRowContext(ctx, "SELECT tag_value FROM metric_tags WHERE metric_name = ? AND tag_key = ?", newName, key).Scan(&storedValue)
+		isStored := err == nil
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		oldValue, isDerived := oldDerived[key]
+		if isStored && (!isDerived || storedValue != oldValue) {
+			continue
+		}
+
+		newValue, found := newDerived[key]
+		if !found {
+			_, err = tx.ExecContext(ctx, "DELETE FROM metric_tags WHERE metric_name = ? AND tag_key = ?", newName, key)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO metric_tags (metric_name, tag_key, tag_value) VALUES (?, ?, ?)
+			ON CONFLICT(metric_name, tag_key) DO UPDATE SET tag_value = excluded.tag_value
+		`, newName, key, newValue)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UpdateMetricOrder updates the display order of a specific metric
+func (s *sqliteStorage) UpdateMetricOrder(ctx context.Context, name string, order int) error {
+	ctx, finish := s.startOperation(ctx, "UpdateMetricOrder")
+	defer finish()
+	defer s.latest.invalidate()
+
+	_, err := s.db.ExecContext(ctx, "UPDATE metrics SET display_order = ? WHERE name = ?", order, name)
+	return err
+}
+
+// UpdateMetricAlarm updates the alarm status of a specific metric
+func (s *sqliteStorage) UpdateMetricAlarm(ctx context.Context, name string, enabled bool) error {
+	ctx, finish := s.startOperation(ctx, "UpdateMetricAlarm")
+	defer finish()
+	defer s.latest.invalidate()
+
+	val := 0
+	if enabled {
+		val = 1
+	}
+	_, err := s.db.ExecContext(ctx, "UPDATE metrics SET is_alarm_enabled = ? WHERE name = ?", val, name)
+	return err
+}
+
+// UpdateMetricGapMode updates the way the missing intervals are represented in the history of a specific metric
+func (s *sqliteStorage) UpdateMetricGapMode(ctx context.Context, name string, gapMode common.GapMode) error {
+	ctx, finish := s.startOperation(ctx, "UpdateMetricGapMode")
+	defer finish()
+	defer s.latest.invalidate()
+
+	_, err := s.db.ExecContext(ctx, "UPDATE metrics SET gap_mode = ? WHERE name = ?", string(gapMode), name)
+	return err
+}
+
+// UpdateMetricAggregationMode updates the way the aggregated value of a specific metric is computed over its window
+func (s *sqliteStorage) UpdateMetricAggregationMode(ctx context.Context, name string, aggregationMode common.AggregationMode) error {
+	ctx, finish := s.startOperation(ctx, "UpdateMetricAggregationMode")
+	defer finish()
+	defer s.latest.invalidate()
+
+	_, err := s.db.ExecContext(ctx, "UPDATE metrics SET aggregation_mode = ? WHERE name = ?", string(aggregationMode), name)
+	return err
+}
+
+// UpdateMetricStoreOnChange enables or disables storing a value of a specific metric only when it differs from the
+// latest stored one, the identical values only extending its last seen timestamp
+func (s *sqliteStorage) UpdateMetricStoreOnChange(ctx context.Context, name string, enabled bool) error {
+	ctx, finish := s.startOperation(ctx, "UpdateMetricStoreOnChange")
+	defer finish()
+	defer s.latest.invalidate()
+
+	storeOnChange := 0
+	if enabled {
+		storeOnChange = 1
+	}
+	_, err := s.db.ExecContext(ctx, "UPDATE metrics SET store_on_change = ? WHERE name = ?", storeOnChange, name)
+	return err
+}
+
+// UpdateMetricCompression enables or disables packing the older values of a specific metric in delta encoded chunks.
+// The values are packed by the next reports of the metric, disabling the compression unpacks them.
+func (s *sqliteStorage) UpdateMetricCompression(ctx context.Context, name string, enabled bool) error {
+	ctx, finish := s.startOperation(ctx, "UpdateMetricCompression")
+	defer finish()
+	defer s.latest.invalidate()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	compressed := 0
+	if enabled {
+		compressed = 1
+	}
+	var metricType string
+	err = tx.QueryRowContext(ctx, "UPDATE metrics SET compressed = ? WHERE name = ? RETURNING type", compressed, name).Scan(&metricType)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if !enabled {
+		err = unpackValues(ctx, tx, name, metricType)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UpdatePanelOrder updates the display order of a specific panel (VM)
+func (s *sqliteStorage) UpdatePanelOrder(ctx context.Context, name string, order int) error {
+	ctx, finish := s.startOperation(ctx, "UpdatePanelOrder")
+	defer finish()
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO panel_configs (name, display_order) 
+		VALUES (?, ?) 
+		ON CONFLICT(name) DO UPDATE SET display_order=excluded.display_order
+	`, name, order)
+	return err
+}
+
+// GetPanelsConfigs returns the display configurations for all panels
+func (s *sqliteStorage) GetPanelsConfigs(ctx context.Context) (map[string]int, error) {
+	ctx, finish := s.startOperation(ctx, "GetPanelsConfigs")
+	defer finish()
+
+	rows, err := s.readDB.QueryContext(ctx, "SELECT name, display_order FROM panel_configs")
 	if err != nil {
-		return nil, fmt.Errorf("failed to create initial empty DB file: %w", err)
+		return nil, err
 	}
+	defer func() { _ = rows.Close() }()
 
-	db, err := sql.Open("sqlite3", dbPath+"?_journal_mode=WAL&_busy_timeout=5000")
-	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+	res := make(map[string]int)
+	for rows.Next() {
+		var name string
+		var order int
+		if err := rows.Scan(&name, &order); err != nil {
+			return nil, err
+		}
+		res[name] = order
 	}
+	return res, rows.Err()
+}
 
-	err = createSchema(db)
+// SetPanelMetrics replaces the metrics assigned to a custom panel, kept in the provided order. An empty list removes
+// the panel.
+func (s *sqliteStorage) SetPanelMetrics(ctx context.Context, panel string, metrics []string) error {
+	ctx, finish := s.startOperation(ctx, "SetPanelMetrics")
+	defer finish()
+
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		_ = db.Close()
-		return nil, err
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer func() { _ = tx.Rollback() }()
 
-	ctx, cancel := context.WithCancel(context.Background())
-	s := &sqliteStorage{
-		db:               db,
-		retentionSeconds: retentionSeconds,
-		cancelFunc:       cancel,
+	_, err = tx.ExecContext(ctx, "DELETE FROM custom_panel_metrics WHERE panel_name = ?", panel)
+	if err != nil {
+		return err
 	}
 
-	s.startRetentionCleaner(ctx)
-
-	return s, nil
-}
-
-func prepareDirectories(dbPath string) error {
-	return os.MkdirAll(filepath.Dir(dbPath), os.ModePerm)
-}
+	for i, metric := range metrics {
+		_, err = tx.ExecContext(ctx, "INSERT OR IGNORE INTO custom_panel_metrics (panel_name, metric_name, display_order) VALUES (?, ?, ?)", panel, metric, i)
+		if err != nil {
+			return err
+		}
+	}
 
-// CleanRetainedMetrics executes the retention cleanup query synchronously.
-func (s *sqliteStorage) cleanRetainedMetrics(ctx context.Context) error {
-	nowSec := time.Now().Unix()
-	cutoff := nowSec - int64(s.retentionSeconds)
-	_, err := s.db.ExecContext(ctx, "DELETE FROM metrics_values WHERE recorded_at < ?", cutoff)
-	return err
+	return tx.Commit()
 }
 
-func createSchema(db *sql.DB) error {
+// GetCustomPanels returns the metrics assigned to each custom panel, in their assignment order
+func (s *sqliteStorage) GetCustomPanels(ctx context.Context) (map[string][]string, error) {
+	ctx, finish := s.startOperation(ctx, "GetCustomPanels")
+	defer finish()
 
-	schema := `
-	CREATE TABLE IF NOT EXISTS metrics (
-		name               TEXT    NOT NULL PRIMARY KEY,
-		type               TEXT    NOT NULL,
-		num_aggregation    INTEGER NOT NULL DEFAULT 1,
-		display_order      INTEGER NOT NULL DEFAULT 0,
-		is_alarm_enabled   INTEGER NOT NULL DEFAULT 0
-	);
+	rows, err := s.readDB.QueryContext(ctx, "SELECT panel_name, metric_name FROM custom_panel_metrics ORDER BY panel_name, display_order")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
 
-	CREATE TABLE IF NOT EXISTS panel_configs (
-		name            TEXT    NOT NULL PRIMARY KEY,
-		display_order   INTEGER NOT NULL DEFAULT 0
-	);
+	res := make(map[string][]string)
+	for rows.Next() {
+		var panel, metric string
+		if err := rows.Scan(&panel, &metric); err != nil {
+			return nil, err
+		}
+		res[panel] = append(res[panel], metric)
+	}
+	return res, rows.Err()
+}
 
-	CREATE TABLE IF NOT EXISTS metrics_values (
-		metric_name TEXT    NOT NULL REFERENCES metrics(name) ON DELETE CASCADE,
-		value       TEXT    NOT NULL,
-		recorded_at INTEGER NOT NULL
-	);
+// CreateDashboard stores a new dashboard and returns its ID
+func (s *sqliteStorage) CreateDashboard(ctx context.Context, dashboard common.Dashboard) (int64, error) {
+	ctx, finish := s.startOperation(ctx, "CreateDashboard")
+	defer finish()
 
-	CREATE INDEX IF NOT EXISTS idx_metrics_values_name ON metrics_values(metric_name);
-	CREATE INDEX IF NOT EXISTS idx_metrics_values_recorded_at ON metrics_values(recorded_at);
-	`
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
 
-	_, err := db.Exec(schema)
+	err = checkDashboardNameIsFree(ctx, tx, dashboard.Name, 0)
 	if err != nil {
-		return fmt.Errorf("failed to create schema: %w", err)
+		return 0, err
 	}
 
-	// Migration: ensure display_order and is_alarm_enabled columns exist in metrics
-	_, _ = db.Exec("ALTER TABLE metrics ADD COLUMN display_order INTEGER NOT NULL DEFAULT 0;")
-	_, _ = db.Exec("ALTER TABLE metrics ADD COLUMN is_alarm_enabled INTEGER NOT NULL DEFAULT 0;")
+	result, err := tx.ExecContext(ctx, "INSERT INTO dashboards (name) VALUES (?)", dashboard.Name)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
 
-	// Make sure ON DELETE CASCADE works if enabled globally
-	_, _ = db.Exec("PRAGMA foreign_keys = ON;")
+	err = saveDashboardSelections(ctx, tx, id, dashboard)
+	if err != nil {
+		return 0, err
+	}
 
-	return nil
+	return id, tx.Commit()
 }
 
-// SaveMetric upserts the metric definition, inserts the value, and prunes old entries based on numAggregation
-func (s *sqliteStorage) SaveMetric(ctx context.Context, name string, metricType string, numAggregation int, valString string, recordedAt int64) error {
+// UpdateDashboard replaces the name and the selections of an existing dashboard
+func (s *sqliteStorage) UpdateDashboard(ctx context.Context, dashboard common.Dashboard) error {
+	ctx, finish := s.startOperation(ctx, "UpdateDashboard")
+	defer finish()
+
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer func() { _ = tx.Rollback() }()
 
-	_, err = tx.ExecContext(ctx, `
-		INSERT INTO metrics (name, type, num_aggregation) 
-		VALUES (?, ?, ?) 
-		ON CONFLICT(name) DO UPDATE SET 
-			type=excluded.type, 
-			num_aggregation=excluded.num_aggregation
-	`, name, metricType, numAggregation)
+	err = checkDashboardNameIsFree(ctx, tx, dashboard.Name, dashboard.ID)
 	if err != nil {
-		return fmt.Errorf("failed to upsert metric definition: %w", err)
+		return err
 	}
 
-	_, err = tx.ExecContext(ctx, `
-		INSERT INTO metrics_values (metric_name, value, recorded_at)
-		VALUES (?, ?, ?)
-	`, name, valString, recordedAt)
+	result, err := tx.ExecContext(ctx, "UPDATE dashboards SET name = ? WHERE id = ?", dashboard.Name, dashboard.ID)
+	if err != nil {
+		return err
+	}
+	numUpdated, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to insert metric value: %w", err)
+		return err
+	}
+	if numUpdated == 0 {
+		return common.ErrDashboardNotFound
+	}
+
+	for _, table := range []string{"dashboard_panels", "dashboard_metrics"} {
+		query := fmt.Sprintf("DELETE FROM %s WHERE dashboard_id = ?", table)
+		_, err = tx.ExecContext(ctx, query, dashboard.ID)
+		if err != nil {
+			return err
+		}
 	}
 
-	_, err = tx.ExecContext(ctx, `
-		DELETE FROM metrics_values
-		WHERE metric_name = ?
-		  AND rowid NOT IN (
-			  SELECT rowid FROM metrics_values
-			  WHERE metric_name = ?
-			  ORDER BY recorded_at DESC
-			  LIMIT ?
-		  )
-	`, name, name, numAggregation)
+	err = saveDashboardSelections(ctx, tx, dashboard.ID, dashboard)
 	if err != nil {
-		return fmt.Errorf("failed to trim metric aggregation window: %w", err)
+		return err
 	}
 
 	return tx.Commit()
 }
 
-// GetLatestMetrics fetches the most recent value for each metric
-func (s *sqliteStorage) GetLatestMetrics(ctx context.Context) ([]common.MetricHistory, error) {
-	rows, err := s.db.QueryContext(ctx, `
-		SELECT m.name, m.type, m.num_aggregation, m.display_order, m.is_alarm_enabled, v.value, v.recorded_at
-		FROM metrics m
-		LEFT JOIN (
-			SELECT metric_name, value, recorded_at,
-				ROW_NUMBER() OVER(PARTITION BY metric_name ORDER BY recorded_at DESC) as rn
-			FROM metrics_values
-		) v ON m.name = v.metric_name AND v.rn = 1
-	`)
+func checkDashboardNameIsFree(ctx context.Context, tx *sql.Tx, name string, id int64) error {
+	var exists int
+	err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM dashboards WHERE name = ? AND id <> ?", name, id).Scan(&exists)
 	if err != nil {
-		return nil, fmt.Errorf("query failed: %w", err)
+		return err
+	}
+	if exists > 0 {
+		return fmt.Errorf("%w: %s", common.ErrDashboardAlreadyExists, name)
 	}
-	defer func() {
-		_ = rows.Close()
-	}()
-
-	var results []common.MetricHistory
 
-	for rows.Next() {
-		var h common.MetricHistory
-		var val sql.NullString
-		var recAt sql.NullInt64
-		var isAlarm int
+	return nil
+}
 
-		err = rows.Scan(&h.Name, &h.Type, &h.NumAggregation, &h.DisplayOrder, &isAlarm, &val, &recAt)
+func saveDashboardSelections(ctx context.Context, tx *sql.Tx, id int64, dashboard common.Dashboard) error {
+	for i, panel := range dashboard.Panels {
+		_, err := tx.ExecContext(ctx, "INSERT OR IGNORE INTO dashboard_panels (dashboard_id, panel_name, display_order) VALUES (?, ?, ?)", id, panel, i)
 		if err != nil {
-			return nil, err
+			return err
+		}
+	}
+	for i, metric := range dashboard.Metrics {
+		_, err := tx.ExecContext(ctx, "INSERT OR IGNORE INTO dashboard_metrics (dashboard_id, metric_name, display_order) VALUES (?, ?, ?)", id, metric, i)
+		if err != nil {
+			return err
 		}
+	}
 
-		h.IsAlarmEnabled = isAlarm == 1
+	return nil
+}
 
-		v := ""
-		if val.Valid {
-			v = val.String
-		}
-		var r int64
-		if recAt.Valid {
-			r = recAt.Int64
+// GetDashboards returns all the dashboards, ordered by name
+func (s *sqliteStorage) GetDashboards(ctx context.Context) ([]common.Dashboard, error) {
+	ctx, finish := s.startOperation(ctx, "GetDashboards")
+	defer finish()
+
+	rows, err := s.readDB.QueryContext(ctx, "SELECT id, name FROM dashboards ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("dashboards query failed: %w", err)
+	}
+
+	dashboards := make([]common.Dashboard, 0)
+	for rows.Next() {
+		var dashboard common.Dashboard
+		err = rows.Scan(&dashboard.ID, &dashboard.Name)
+		if err != nil {
+			_ = rows.Close()
+			return nil, err
 		}
+		dashboards = append(dashboards, dashboard)
+	}
+	_ = rows.Close()
 
-		h.History = []common.MetricValue{
-			{
-				Value:      v,
-				RecordedAt: r,
-			},
+	for i := range dashboards {
+		err = s.loadDashboardSelections(ctx, &dashboards[i])
+		if err != nil {
+			return nil, err
 		}
-		results = append(results, h)
 	}
 
-	return results, rows.Err()
+	return dashboards, nil
 }
 
-// GetMetricHistory returns the metric configuration and up to 'num_aggregation' historical values
-func (s *sqliteStorage) GetMetricHistory(ctx context.Context, name string) (*common.MetricHistory, error) {
-	var h common.MetricHistory
-	var isAlarm int
+// GetDashboard returns the dashboard with the provided ID
+func (s *sqliteStorage) GetDashboard(ctx context.Context, id int64) (*common.Dashboard, error) {
+	ctx, finish := s.startOperation(ctx, "GetDashboard")
+	defer finish()
 
-	err := s.db.QueryRowContext(ctx, "SELECT name, type, num_aggregation, display_order, is_alarm_enabled FROM metrics WHERE name = ?", name).Scan(&h.Name, &h.Type, &h.NumAggregation, &h.DisplayOrder, &isAlarm)
+	dashboard := &common.Dashboard{}
+	err := s.readDB.QueryRowContext(ctx, "SELECT id, name FROM dashboards WHERE id = ?", id).Scan(&dashboard.ID, &dashboard.Name)
 	if errors.Is(err, sql.ErrNoRows) {
-		return nil, fmt.Errorf("metric not found")
+		return nil, common.ErrDashboardNotFound
 	}
 	if err != nil {
 		return nil, err
 	}
-	h.IsAlarmEnabled = isAlarm == 1
 
-	rows, err := s.db.QueryContext(ctx, `
-		SELECT value, recorded_at 
-		FROM metrics_values 
-		WHERE metric_name = ? 
-		ORDER BY recorded_at
-	`, name)
+	err = s.loadDashboardSelections(ctx, dashboard)
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		_ = rows.Close()
-	}()
-
-	for rows.Next() {
-		var val string
-		var recAt int64
-
-		err = rows.Scan(&val, &recAt)
-		if err != nil {
-			return nil, err
-		}
-
-		h.History = append(h.History, common.MetricValue{Value: val, RecordedAt: recAt})
-	}
-
-	return &h, rows.Err()
-}
 
-// DeleteMetric forcefully deletes a metric and all its values from the database
-func (s *sqliteStorage) DeleteMetric(ctx context.Context, name string) error {
-	_, err := s.db.ExecContext(ctx, "DELETE FROM metrics WHERE name = ?", name)
-	return err
+	return dashboard, nil
 }
 
-// UpdateMetricOrder updates the display order of a specific metric
-func (s *sqliteStorage) UpdateMetricOrder(ctx context.Context, name string, order int) error {
-	_, err := s.db.ExecContext(ctx, "UPDATE metrics SET display_order = ? WHERE name = ?", order, name)
-	return err
-}
+func (s *sqliteStorage) loadDashboardSelections(ctx context.Context, dashboard *common.Dashboard) error {
+	var err error
+	dashboard.Panels, err = s.queryNames(ctx, "SELECT panel_name FROM dashboard_panels WHERE dashboard_id = ? ORDER BY display_order", dashboard.ID)
+	if err != nil {
+		return fmt.Errorf("dashboard panels query failed: %w", err)
+	}
 
-// UpdateMetricAlarm updates the alarm status of a specific metric
-func (s *sqliteStorage) UpdateMetricAlarm(ctx context.Context, name string, enabled bool) error {
-	val := 0
-	if enabled {
-		val = 1
+	dashboard.Metrics, err = s.queryNames(ctx, `
+		SELECT metric_name FROM dashboard_metrics
+		WHERE dashboard_id = ? AND metric_name IN (SELECT name FROM metrics WHERE deleted_at = 0)
+		ORDER BY display_order
+	`, dashboard.ID)
+	if err != nil {
+		return fmt.Errorf("dashboard metrics query failed: %w", err)
 	}
-	_, err := s.db.ExecContext(ctx, "UPDATE metrics SET is_alarm_enabled = ? WHERE name = ?", val, name)
-	return err
-}
 
-// UpdatePanelOrder updates the display order of a specific panel (VM)
-func (s *sqliteStorage) UpdatePanelOrder(ctx context.Context, name string, order int) error {
-	_, err := s.db.ExecContext(ctx, `
-		INSERT INTO panel_configs (name, display_order) 
-		VALUES (?, ?) 
-		ON CONFLICT(name) DO UPDATE SET display_order=excluded.display_order
-	`, name, order)
-	return err
+	return nil
 }
 
-// GetPanelsConfigs returns the display configurations for all panels
-func (s *sqliteStorage) GetPanelsConfigs(ctx context.Context) (map[string]int, error) {
-	rows, err := s.db.QueryContext(ctx, "SELECT name, display_order FROM panel_configs")
+func (s *sqliteStorage) queryNames(ctx context.Context, query string, args ...interface{}) ([]string, error) {
+	rows, err := s.readDB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer func() { _ = rows.Close() }()
 
-	res := make(map[string]int)
+	names := make([]string, 0)
 	for rows.Next() {
 		var name string
-		var order int
-		if err := rows.Scan(&name, &order); err != nil {
+		err = rows.Scan(&name)
+		if err != nil {
 			return nil, err
 		}
-		res[name] = order
+		names = append(names, name)
 	}
-	return res, rows.Err()
+
+	return names, rows.Err()
+}
+
+// DeleteDashboard removes a dashboard together with its selections
+func (s *sqliteStorage) DeleteDashboard(ctx context.Context, id int64) error {
+	ctx, finish := s.startOperation(ctx, "DeleteDashboard")
+	defer finish()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, table := range []string{"dashboard_panels", "dashboard_metrics"} {
+		query := fmt.Sprintf("DELETE FROM %s WHERE dashboard_id = ?", table)
+		_, err = tx.ExecContext(ctx, query, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM dashboards WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	numDeleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if numDeleted == 0 {
+		return common.ErrDashboardNotFound
+	}
+
+	return tx.Commit()
 }
 
 func (s *sqliteStorage) startRetentionCleaner(ctx context.Context) {
@@ -309,23 +2017,65 @@ func (s *sqliteStorage) startRetentionCleaner(ctx context.Context) {
 		intervalSec = 60
 	}
 
-	ticker := time.NewTicker(time.Duration(intervalSec) * time.Second)
+	interval := time.Duration(intervalSec) * time.Second
+	timer := s.clock.NewTimer(interval)
 
 	go func() {
 		defer s.wg.Done()
-		defer ticker.Stop()
+		defer timer.Stop()
 
 		for {
 			select {
 			case <-ctx.Done():
 				return
-			case <-ticker.C:
+			case <-timer.C():
 				log.Debug("running retention cleanup")
 
 				err := s.cleanRetainedMetrics(ctx)
 				if err != nil {
 					log.Warn("failed to cleanup retained metrics", "error", err)
 				}
+				timer.Reset(interval)
+			}
+		}
+	}()
+}
+
+// checkpointWAL moves the WAL content into the database file and truncates the WAL file, so it doesn't grow
+// unbounded when the automatic checkpoints are starved by the continuous readers
+func (s *sqliteStorage) checkpointWAL(ctx context.Context) error {
+	ctx, finish := s.startOperation(ctx, "checkpointWAL")
+	defer finish()
+
+	var busy, numLogFrames, numCheckpointedFrames int
+	err := s.db.QueryRowContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE);").Scan(&busy, &numLogFrames, &numCheckpointedFrames)
+	if err != nil {
+		return err
+	}
+
+	log.Debug("WAL checkpoint", "busy", busy == 1, "log frames", numLogFrames, "checkpointed frames", numCheckpointedFrames)
+
+	return nil
+}
+
+func (s *sqliteStorage) startWALCheckpointer(ctx context.Context) {
+	s.wg.Add(1)
+
+	ticker := time.NewTicker(s.checkpointInterval)
+
+	go func() {
+		defer s.wg.Done()
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := s.checkpointWAL(ctx)
+				if err != nil {
+					log.Warn("failed to checkpoint the WAL file", "error", err)
+				}
 			}
 		}
 	}()
@@ -335,6 +2085,12 @@ func (s *sqliteStorage) startRetentionCleaner(ctx context.Context) {
 func (s *sqliteStorage) Close() error {
 	s.cancelFunc()
 	s.wg.Wait()
+
+	s.statements.close()
+	if s.readDB != s.db {
+		_ = s.readDB.Close()
+	}
+
 	return s.db.Close()
 }
 
@@ -2,15 +2,21 @@ package storage
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
 	"testing"
 	"time"
 
+	"github.com/iulianpascalau/api-monitoring/commonGo/clock"
 	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
 	"github.com/stretchr/testify/require"
 )
 
 func TestSQLiteStorage_SaveAndGet(t *testing.T) {
-	s, err := NewSQLiteStorage(":memory:", 3600)
+	s, err := NewSQLiteStorage(":memory:", 3600, SQLiteTuning{})
 	require.NoError(t, err)
 	require.False(t, s.IsInterfaceNil())
 	defer func() {
@@ -75,7 +81,7 @@ func TestSQLiteStorage_SaveAndGet(t *testing.T) {
 
 func TestSQLiteStorage_RetentionCleaner(t *testing.T) {
 	// Set retention very low (3 seconds) to trigger cleaner fast in memory
-	s, err := NewSQLiteStorage(":memory:", 3)
+	s, err := NewSQLiteStorage(":memory:", 3, SQLiteTuning{})
 	require.NoError(t, err)
 	defer func() {
 		_ = s.Close()
@@ -99,8 +105,45 @@ func TestSQLiteStorage_RetentionCleaner(t *testing.T) {
 	require.Equal(t, 0, len(hist.History))    // But values should be gone
 }
 
+func TestSQLiteStorage_RetentionCleanerWithClock(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	clk := clock.NewManualClock(start)
+	// the cleaner runs every 60 seconds, the minimum interval
+	s, err := NewSQLiteStorage(":memory:", 600, SQLiteTuning{Clock: clk})
+	require.NoError(t, err)
+	defer func() {
+		_ = s.Close()
+	}()
+
+	ctx := context.Background()
+	cleanupTime := start.Add(time.Minute).Unix()
+	require.NoError(t, s.SaveMetric(ctx, "m1", "uint64", 10, "1", cleanupTime-601))
+	require.NoError(t, s.SaveMetric(ctx, "m1", "uint64", 10, "2", cleanupTime-600))
+	require.NoError(t, s.SaveMetric(ctx, "m1", "uint64", 10, "3", cleanupTime))
+
+	waitCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	require.NoError(t, clk.WaitTimers(waitCtx, 1))
+	clk.Advance(59 * time.Second)
+	hist, err := s.GetMetricHistory(ctx, "m1")
+	require.NoError(t, err)
+	require.Len(t, hist.History, 3)
+
+	clk.Advance(time.Second)
+	// the timer is armed again after the cleanup
+	require.NoError(t, clk.WaitTimers(waitCtx, 1))
+	hist, err = s.GetMetricHistory(ctx, "m1")
+	require.NoError(t, err)
+	values := make([]string, 0, len(hist.History))
+	for _, value := range hist.History {
+		values = append(values, value.Value)
+	}
+	// exactly the values older than the retention are removed
+	require.Equal(t, []string{"2", "3"}, values)
+}
+
 func TestSQLiteStorage_Ordering(t *testing.T) {
-	s, err := NewSQLiteStorage(":memory:", 3600)
+	s, err := NewSQLiteStorage(":memory:", 3600, SQLiteTuning{})
 	require.NoError(t, err)
 	defer func() {
 		_ = s.Close()
@@ -150,10 +193,23 @@ func TestSQLiteStorage_Ordering(t *testing.T) {
 	configs, err = s.GetPanelsConfigs(ctx)
 	require.NoError(t, err)
 	require.Equal(t, 0, configs["VM1"])
+
+	// 3. Test the custom panels
+	require.NoError(t, s.SetPanelMetrics(ctx, "Critical", []string{"m2", "m1"}))
+	require.NoError(t, s.SetPanelMetrics(ctx, "Other", []string{"m1"}))
+	customPanels, err := s.GetCustomPanels(ctx)
+	require.NoError(t, err)
+	require.Equal(t, map[string][]string{"Critical": {"m2", "m1"}, "Other": {"m1"}}, customPanels)
+
+	require.NoError(t, s.RenameMetric(ctx, "m1", "m3"))
+	require.NoError(t, s.SetPanelMetrics(ctx, "Other", nil))
+	customPanels, err = s.GetCustomPanels(ctx)
+	require.NoError(t, err)
+	require.Equal(t, map[string][]string{"Critical": {"m2", "m3"}}, customPanels)
 }
 
 func TestSQLiteStorage_GetLatestMetrics_EmptyValues(t *testing.T) {
-	s, err := NewSQLiteStorage(":memory:", 3600)
+	s, err := NewSQLiteStorage(":memory:", 3600, SQLiteTuning{})
 	require.NoError(t, err)
 	defer func() {
 		_ = s.Close()
@@ -181,7 +237,7 @@ func TestSQLiteStorage_GetLatestMetrics_EmptyValues(t *testing.T) {
 }
 
 func TestSQLiteStorage_UpdateMetricAlarm(t *testing.T) {
-	s, err := NewSQLiteStorage(":memory:", 3600)
+	s, err := NewSQLiteStorage(":memory:", 3600, SQLiteTuning{})
 	require.NoError(t, err)
 	defer func() {
 		_ = s.Close()
@@ -218,3 +274,1033 @@ func TestSQLiteStorage_UpdateMetricAlarm(t *testing.T) {
 	require.NoError(t, err)
 	require.False(t, hist.IsAlarmEnabled)
 }
+
+func TestSQLiteStorage_UpdateMetricGapMode(t *testing.T) {
+	s, err := NewSQLiteStorage(":memory:", 3600, SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = s.Close()
+	}()
+
+	ctx := context.Background()
+
+	err = s.SaveMetric(ctx, "VM1.nonce", "uint64", 10, "1", time.Now().Unix())
+	require.NoError(t, err)
+
+	hist, err := s.GetMetricHistory(ctx, "VM1.nonce")
+	require.NoError(t, err)
+	require.Equal(t, common.GapModeNone, hist.GapMode)
+
+	err = s.UpdateMetricGapMode(ctx, "VM1.nonce", common.GapModeHold)
+	require.NoError(t, err)
+
+	hist, err = s.GetMetricHistory(ctx, "VM1.nonce")
+	require.NoError(t, err)
+	require.Equal(t, common.GapModeHold, hist.GapMode)
+
+	latest, err := s.GetLatestMetrics(ctx)
+	require.NoError(t, err)
+	require.Len(t, latest, 1)
+	require.Equal(t, common.GapModeHold, latest[0].GapMode)
+}
+
+func TestSQLiteStorage_UpdateMetricAggregationMode(t *testing.T) {
+	s, err := NewSQLiteStorage(":memory:", 3600, SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = s.Close()
+	}()
+
+	ctx := context.Background()
+	now := time.Now().Unix()
+	for i, value := range []string{"10", "25", "40", "5"} {
+		err = s.SaveMetric(ctx, "VM1.latency", "float64", 3, value, now+int64(i))
+		require.NoError(t, err)
+	}
+	err = s.SaveMetric(ctx, "VM1.version", "string", 3, "v1.0.0", now)
+	require.NoError(t, err)
+
+	hist, err := s.GetMetricHistory(ctx, "VM1.latency")
+	require.NoError(t, err)
+	require.Equal(t, common.AggregationModeLast, hist.AggregationMode)
+	latest, err := s.GetLatestMetricsFiltered(ctx, common.MetricsFilter{Name: "VM1.latency"})
+	require.NoError(t, err)
+	require.Empty(t, latest[0].AggregatedValue)
+
+	// the window holds the last 3 values: 25, 40 and 5
+	expected := map[common.AggregationMode]string{
+		common.AggregationModeAvg: "23.333333333333332",
+		common.AggregationModeMin: "5",
+		common.AggregationModeMax: "40",
+		common.AggregationModeSum: "70",
+	}
+	for mode, value := range expected {
+		err = s.UpdateMetricAggregationMode(ctx, "VM1.latency", mode)
+		require.NoError(t, err)
+
+		latest, err = s.GetLatestMetricsFiltered(ctx, common.MetricsFilter{Name: "VM1.latency"})
+		require.NoError(t, err)
+		require.Len(t, latest, 1)
+		require.Equal(t, mode, latest[0].AggregationMode)
+		require.Equal(t, value, latest[0].AggregatedValue, mode)
+		require.Equal(t, "5", latest[0].History[0].Value)
+	}
+
+	// the non numeric metrics have no aggregated value
+	err = s.UpdateMetricAggregationMode(ctx, "VM1.version", common.AggregationModeMax)
+	require.NoError(t, err)
+	latest, err = s.GetLatestMetricsFiltered(ctx, common.MetricsFilter{Name: "VM1.version"})
+	require.NoError(t, err)
+	require.Equal(t, common.AggregationModeMax, latest[0].AggregationMode)
+	require.Empty(t, latest[0].AggregatedValue)
+}
+
+func TestSQLiteStorage_UpdateMetricStoreOnChange(t *testing.T) {
+	t.Parallel()
+
+	s, err := NewSQLiteStorage(":memory:", 3600, SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = s.Close()
+	}()
+
+	ctx := context.Background()
+	now := time.Now().Unix()
+	require.NoError(t, s.SaveMetric(ctx, "VM1.Active", common.MetricTypeBool, 10, "true", now))
+	require.NoError(t, s.UpdateMetricStoreOnChange(ctx, "VM1.Active", true))
+
+	// the identical consecutive values only extend the last seen timestamp
+	require.NoError(t, s.SaveMetric(ctx, "VM1.Active", common.MetricTypeBool, 10, "true", now+1))
+	require.NoError(t, s.SaveMetric(ctx, "VM1.Active", common.MetricTypeBool, 10, "true", now+2))
+	hist, err := s.GetMetricHistory(ctx, "VM1.Active")
+	require.NoError(t, err)
+	require.True(t, hist.StoreOnChange)
+	require.Equal(t, []common.MetricValue{{Value: "true", RecordedAt: now, LastSeenAt: now + 2}}, hist.History)
+
+	latest, err := s.GetLatestMetricsFiltered(ctx, common.MetricsFilter{Name: "VM1.Active"})
+	require.NoError(t, err)
+	require.True(t, latest[0].StoreOnChange)
+	require.Equal(t, now+2, latest[0].History[0].RecordedAt)
+
+	// a change is stored, as a value reported out of order
+	require.NoError(t, s.SaveMetric(ctx, "VM1.Active", common.MetricTypeBool, 10, "false", now+3))
+	require.NoError(t, s.SaveMetric(ctx, "VM1.Active", common.MetricTypeBool, 10, "false", now+4))
+	require.NoError(t, s.SaveMetric(ctx, "VM1.Active", common.MetricTypeBool, 10, "false", now+1))
+	hist, err = s.GetMetricHistory(ctx, "VM1.Active")
+	require.NoError(t, err)
+	expected := []common.MetricValue{
+		{Value: "true", RecordedAt: now, LastSeenAt: now + 2},
+		{Value: "false", RecordedAt: now + 1},
+		{Value: "false", RecordedAt: now + 3, LastSeenAt: now + 4},
+	}
+	require.Equal(t, expected, hist.History)
+
+	// the values of a compacted row seen after the cutoff are retained
+	hist, err = s.GetMetricHistorySince(ctx, "VM1.Active", now+3)
+	require.NoError(t, err)
+	require.Equal(t, expected[2:], hist.History)
+	require.NoError(t, s.removeExpiredValues(ctx, now+3))
+	hist, err = s.GetMetricHistory(ctx, "VM1.Active")
+	require.NoError(t, err)
+	require.Equal(t, expected[2:], hist.History)
+
+	// the cache is reloaded from the database
+	s.latest.invalidate()
+	latest, err = s.GetLatestMetricsFiltered(ctx, common.MetricsFilter{Name: "VM1.Active"})
+	require.NoError(t, err)
+	require.Equal(t, now+4, latest[0].History[0].RecordedAt)
+
+	require.NoError(t, s.UpdateMetricStoreOnChange(ctx, "VM1.Active", false))
+	require.NoError(t, s.SaveMetric(ctx, "VM1.Active", common.MetricTypeBool, 10, "false", now+5))
+	hist, err = s.GetMetricHistory(ctx, "VM1.Active")
+	require.NoError(t, err)
+	require.False(t, hist.StoreOnChange)
+	require.Len(t, hist.History, 2)
+}
+
+func TestSQLiteStorage_SaveMetricInterval(t *testing.T) {
+	t.Parallel()
+
+	s, err := NewSQLiteStorage(":memory:", 3600, SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = s.Close()
+	}()
+
+	ctx := context.Background()
+
+	err = s.SaveMetric(ctx, "VM1.nonce", "uint64", 10, "1", time.Now().Unix())
+	require.NoError(t, err)
+
+	hist, err := s.GetMetricHistory(ctx, "VM1.nonce")
+	require.NoError(t, err)
+	require.Equal(t, 0, hist.ExpectedInterval)
+
+	require.NoError(t, s.SaveMetricInterval(ctx, "VM1.nonce", 30))
+	// an unknown interval keeps the stored one
+	require.NoError(t, s.SaveMetricInterval(ctx, "VM1.nonce", 0))
+
+	hist, err = s.GetMetricHistory(ctx, "VM1.nonce")
+	require.NoError(t, err)
+	require.Equal(t, 30, hist.ExpectedInterval)
+
+	latest, err := s.GetLatestMetrics(ctx)
+	require.NoError(t, err)
+	require.Len(t, latest, 1)
+	require.Equal(t, 30, latest[0].ExpectedInterval)
+
+	// the batch writes store the interval too, and the renamed metrics keep it
+	err = s.SaveMetrics(ctx, []common.MetricRecord{
+		{Name: "VM1.nonce", Type: "uint64", NumAggregation: 10, Value: "2", RecordedAt: time.Now().Unix(), ExpectedInterval: 60},
+	})
+	require.NoError(t, err)
+	require.NoError(t, s.RenameMetric(ctx, "VM1.nonce", "VM1.Node1.nonce"))
+
+	hist, err = s.GetMetricHistory(ctx, "VM1.Node1.nonce")
+	require.NoError(t, err)
+	require.Equal(t, 60, hist.ExpectedInterval)
+}
+
+func TestSQLiteStorage_MetricTags(t *testing.T) {
+	s, err := NewSQLiteStorage(":memory:", 3600, SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = s.Close()
+	}()
+
+	ctx := context.Background()
+
+	err = s.SaveMetric(ctx, "VM1.Node1.nonce", "uint64", 10, "1", time.Now().Unix())
+	require.NoError(t, err)
+	err = s.SaveMetric(ctx, "VM2.Node1.nonce", "uint64", 10, "1", time.Now().Unix())
+	require.NoError(t, err)
+
+	hist, err := s.GetMetricHistory(ctx, "VM1.Node1.nonce")
+	require.NoError(t, err)
+	require.Nil(t, hist.Tags)
+
+	err = s.SaveMetricTags(ctx, "VM1.Node1.nonce", map[string]string{"vm": "VM1", "shard": "0"})
+	require.NoError(t, err)
+	err = s.SaveMetricTags(ctx, "VM1.Node1.nonce", map[string]string{"shard": "1"})
+	require.NoError(t, err)
+
+	hist, err = s.GetMetricHistory(ctx, "VM1.Node1.nonce")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"vm": "VM1", "shard": "1"}, hist.Tags)
+
+	latest, err := s.GetLatestMetrics(ctx)
+	require.NoError(t, err)
+	require.Len(t, latest, 2)
+	for _, metric := range latest {
+		if metric.Name == "VM1.Node1.nonce" {
+			require.Equal(t, map[string]string{"vm": "VM1", "shard": "1"}, metric.Tags)
+		} else {
+			require.Nil(t, metric.Tags)
+		}
+	}
+
+	// a deleted metric reported again is restored with its tags
+	err = s.DeleteMetric(ctx, "VM1.Node1.nonce")
+	require.NoError(t, err)
+	err = s.SaveMetric(ctx, "VM1.Node1.nonce", "uint64", 10, "1", time.Now().Unix())
+	require.NoError(t, err)
+
+	hist, err = s.GetMetricHistory(ctx, "VM1.Node1.nonce")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"vm": "VM1", "shard": "1"}, hist.Tags)
+}
+
+func TestSQLiteStorage_NumericValues(t *testing.T) {
+	s, err := NewSQLiteStorage(":memory:", 3600, SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = s.Close()
+	}()
+
+	ctx := context.Background()
+	now := time.Now().Unix()
+
+	err = s.SaveMetric(ctx, "VM1.Node1.nonce", "uint64", 10, "100", now-2)
+	require.NoError(t, err)
+	err = s.SaveMetric(ctx, "VM1.Node1.nonce", "uint64", 10, "18446744073709551615", now-1)
+	require.NoError(t, err)
+	err = s.SaveMetric(ctx, "VM1.Node1.nonce", "uint64", 10, "not a number", now)
+	require.NoError(t, err)
+	err = s.SaveMetric(ctx, "VM1.Node1.status", "string", 1, "42", now)
+	require.NoError(t, err)
+
+	rows, err := s.db.QueryContext(ctx, "SELECT value, value_num, typeof(value_num) FROM metrics_values ORDER BY recorded_at, metric_name")
+	require.NoError(t, err)
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	type numericRow struct {
+		value    string
+		valueNum interface{}
+		typeOf   string
+	}
+	var results []numericRow
+	for rows.Next() {
+		var r numericRow
+		err = rows.Scan(&r.value, &r.valueNum, &r.typeOf)
+		require.NoError(t, err)
+		results = append(results, r)
+	}
+	require.NoError(t, rows.Err())
+	require.Len(t, results, 4)
+
+	require.Equal(t, numericRow{value: "100", valueNum: int64(100), typeOf: "integer"}, results[0])
+	require.Equal(t, "real", results[1].typeOf)
+	require.Equal(t, numericRow{value: "not a number", valueNum: nil, typeOf: "null"}, results[2])
+	require.Equal(t, numericRow{value: "42", valueNum: nil, typeOf: "null"}, results[3])
+}
+
+func TestSQLiteStorage_NumericValuesMigration(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "legacy.db")
+
+	legacyDB, err := sql.Open("sqlite3", dbPath)
+	require.NoError(t, err)
+	_, err = legacyDB.Exec(`
+	CREATE TABLE metrics (
+		name               TEXT    NOT NULL PRIMARY KEY,
+		type               TEXT    NOT NULL,
+		num_aggregation    INTEGER NOT NULL DEFAULT 1
+	);
+	CREATE TABLE metrics_values (
+		metric_name TEXT    NOT NULL REFERENCES metrics(name) ON DELETE CASCADE,
+		value       TEXT    NOT NULL,
+		recorded_at INTEGER NOT NULL
+	);
+	INSERT INTO metrics (name, type, num_aggregation) VALUES ('m.nonce', 'uint64', 10), ('m.status', 'string', 10);
+	INSERT INTO metrics_values (metric_name, value, recorded_at) VALUES ('m.nonce', '37', 1), ('m.nonce', '', 2), ('m.status', '38', 1);
+	`)
+	require.NoError(t, err)
+	_ = legacyDB.Close()
+
+	s, err := NewSQLiteStorage(dbPath, 3600, SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = s.Close()
+	}()
+
+	var numNonNull int
+	var sum int64
+	err = s.db.QueryRow("SELECT COUNT(value_num), TOTAL(value_num) FROM metrics_values WHERE value_num IS NOT NULL").Scan(&numNonNull, &sum)
+	require.NoError(t, err)
+	require.Equal(t, 1, numNonNull)
+	require.Equal(t, int64(37), sum)
+
+	// the legacy values were received when recorded
+	var numReceived int
+	err = s.db.QueryRow("SELECT COUNT(*) FROM metrics_values WHERE received_at = recorded_at").Scan(&numReceived)
+	require.NoError(t, err)
+	require.Equal(t, 3, numReceived)
+}
+
+func TestSQLiteStorage_Backup(t *testing.T) {
+	s, err := NewSQLiteStorage(":memory:", 3600, SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = s.Close()
+	}()
+
+	ctx := context.Background()
+	require.NoError(t, s.SaveMetric(ctx, "VM1.nonce", "uint64", 10, "1", 1000))
+	require.NoError(t, s.SaveMetric(ctx, "VM1.nonce", "uint64", 10, "2", 1001))
+
+	backupPath := filepath.Join(t.TempDir(), "backup.db")
+	require.NoError(t, s.Backup(ctx, backupPath))
+	// an existing file is not overwritten
+	require.Error(t, s.Backup(ctx, backupPath))
+
+	restored, err := NewSQLiteStorage(backupPath, 0, SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = restored.Close()
+	}()
+
+	hist, err := restored.GetMetricHistory(ctx, "VM1.nonce")
+	require.NoError(t, err)
+	require.Equal(t, []common.MetricValue{{Value: "1", RecordedAt: 1000}, {Value: "2", RecordedAt: 1001}}, hist.History)
+}
+
+func TestSQLiteStorage_BackupDoesNotHoldTheWriteConnection(t *testing.T) {
+	s, err := NewSQLiteStorage(filepath.Join(t.TempDir(), "monitoring.db"), 3600, SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = s.Close()
+	}()
+
+	ctx := context.Background()
+	require.NoError(t, s.SaveMetric(ctx, "VM1.nonce", "uint64", 10, "1", 1000))
+
+	// the only write connection is busy with a write in progress, the backup is taken on a read connection
+	tx, err := s.db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	_, err = tx.ExecContext(ctx, "UPDATE metrics SET display_order = 1 WHERE name = 'VM1.nonce'")
+	require.NoError(t, err)
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	backupCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	backupPath := filepath.Join(t.TempDir(), "backup.db")
+	require.NoError(t, s.Backup(backupCtx, backupPath))
+	require.NoError(t, tx.Commit())
+	// the read connection used by the backup is query only again
+	_, err = s.readDB.ExecContext(ctx, "UPDATE metrics SET display_order = 2")
+	require.Error(t, err)
+
+	restored, err := NewSQLiteStorage(backupPath, 0, SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = restored.Close()
+	}()
+
+	hist, err := restored.GetMetricHistory(ctx, "VM1.nonce")
+	require.NoError(t, err)
+	require.Equal(t, []common.MetricValue{{Value: "1", RecordedAt: 1000}}, hist.History)
+	require.Zero(t, hist.DisplayOrder)
+}
+
+func TestSQLiteStorage_GetSchemaInfo(t *testing.T) {
+	s, err := NewSQLiteStorage(":memory:", 3600, SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = s.Close()
+	}()
+
+	ctx := context.Background()
+	now := time.Now().Unix()
+	err = s.SaveMetric(ctx, "VM1.nonce", "uint64", 10, "1", now)
+	require.NoError(t, err)
+	err = s.SaveMetric(ctx, "VM1.nonce", "uint64", 10, "2", now)
+	require.NoError(t, err)
+
+	info, err := s.GetSchemaInfo(ctx)
+	require.NoError(t, err)
+	require.Equal(t, len(schemaMigrations), info.Version)
+	require.Len(t, info.Migrations, len(schemaMigrations))
+	for i, migration := range info.Migrations {
+		require.Equal(t, schemaMigrations[i].name, migration.Name)
+		require.NotZero(t, migration.AppliedAt)
+	}
+
+	numRows := make(map[string]int64)
+	for _, table := range info.Tables {
+		require.Contains(t, table.Definition, "CREATE TABLE")
+		numRows[table.Name] = table.NumRows
+	}
+	require.Equal(t, map[string]int64{
+		"metrics":              1,
+		"metrics_values":       2,
+		"metric_value_chunks":  0,
+		"metric_tags":          0,
+		"metric_annotations":   0,
+		"metric_thresholds":    0,
+		"metric_availability":  1,
+		"panel_configs":        0,
+		"custom_panel_metrics": 0,
+		"dashboards":           0,
+		"dashboard_panels":     0,
+		"dashboard_metrics":    0,
+		"alerts":               0,
+		"alert_events":         0,
+		"silences":             0,
+		"totp_enrollments":     0,
+		"sessions":             0,
+		"agents":               0,
+		"agent_logs":           0,
+		"schema_migrations":    int64(len(schemaMigrations)),
+	}, numRows)
+}
+
+func TestSQLiteStorage_GetLatestMetricsFiltered(t *testing.T) {
+	s, err := NewSQLiteStorage(":memory:", 3600, SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = s.Close()
+	}()
+
+	ctx := context.Background()
+	require.NoError(t, s.SaveMetric(ctx, "VM1.Node1.nonce", "uint64", 10, "1", 1003))
+	require.NoError(t, s.SaveMetric(ctx, "VM1.Active", "bool", 1, "true", 1001))
+	require.NoError(t, s.SaveMetric(ctx, "VM2.Node1.nonce", "uint64", 10, "2", 1002))
+	require.NoError(t, s.SaveMetric(ctx, "VM1_Node1.nonce", "uint64", 10, "3", 1000))
+
+	getNames := func(filter common.MetricsFilter) []string {
+		results, errGet := s.GetLatestMetricsFiltered(ctx, filter)
+		require.NoError(t, errGet)
+
+		names := make([]string, 0, len(results))
+		for _, result := range results {
+			names = append(names, result.Name)
+		}
+		return names
+	}
+
+	require.Equal(t, []string{"VM1.Node1.nonce"}, getNames(common.MetricsFilter{Name: "VM1.Node1.nonce"}))
+	require.ElementsMatch(t, []string{"VM1.Node1.nonce", "VM1.Active"}, getNames(common.MetricsFilter{Prefix: "VM1."}))
+	require.ElementsMatch(t, []string{"VM1.Node1.nonce", "VM2.Node1.nonce", "VM1_Node1.nonce"}, getNames(common.MetricsFilter{Type: "uint64"}))
+	require.Equal(t, []string{"VM1.Node1.nonce"}, getNames(common.MetricsFilter{Prefix: "VM1.", Type: "uint64"}))
+	require.Empty(t, getNames(common.MetricsFilter{Prefix: "VM3"}))
+
+	require.Equal(t,
+		[]string{"VM1.Active", "VM1.Node1.nonce", "VM1_Node1.nonce", "VM2.Node1.nonce"},
+		getNames(common.MetricsFilter{SortBy: common.SortByName}),
+	)
+	require.Equal(t,
+		[]string{"VM1.Node1.nonce", "VM2.Node1.nonce", "VM1.Active", "VM1_Node1.nonce"},
+		getNames(common.MetricsFilter{SortBy: common.SortByRecordedAt, Descending: true}),
+	)
+
+	_, err = s.GetLatestMetricsFiltered(ctx, common.MetricsFilter{SortBy: "value"})
+	require.Error(t, err)
+}
+
+func TestSQLiteStorage_GetMetricsHistoriesFiltered(t *testing.T) {
+	s, err := NewSQLiteStorage(":memory:", 3600, SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = s.Close()
+	}()
+
+	ctx := context.Background()
+	now := time.Now().Unix()
+	require.NoError(t, s.SaveMetric(ctx, "VM1.Node1.nonce", "uint64", 2, "1", now-30))
+	require.NoError(t, s.SaveMetric(ctx, "VM1.Node1.nonce", "uint64", 2, "2", now-20))
+	require.NoError(t, s.SaveMetric(ctx, "VM1.Node1.nonce", "uint64", 2, "3", now-10))
+	require.NoError(t, s.SaveMetric(ctx, "VM1.Active", "bool", 1, "true", now-5))
+	require.NoError(t, s.SaveMetric(ctx, "VM2.Node1.nonce", "uint64", 10, "7", now-15))
+	require.NoError(t, s.SaveMetricTags(ctx, "VM1.Node1.nonce", map[string]string{"vm": "VM1"}))
+
+	results, err := s.GetMetricsHistoriesFiltered(ctx, common.MetricsFilter{Prefix: "VM1.", SortBy: common.SortByName})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	require.Equal(t, "VM1.Active", results[0].Name)
+	require.Equal(t, []common.MetricValue{{Value: "true", RecordedAt: now - 5}}, results[0].History)
+
+	// the values are trimmed to NumAggregation and returned in chronological order
+	require.Equal(t, "VM1.Node1.nonce", results[1].Name)
+	require.Equal(t, 2, results[1].NumAggregation)
+	require.Equal(t, map[string]string{"vm": "VM1"}, results[1].Tags)
+	require.Equal(t, []common.MetricValue{
+		{Value: "2", RecordedAt: now - 20},
+		{Value: "3", RecordedAt: now - 10},
+	}, results[1].History)
+
+	results, err = s.GetMetricsHistoriesFiltered(ctx, common.MetricsFilter{})
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	results, err = s.GetMetricsHistoriesFiltered(ctx, common.MetricsFilter{Prefix: "VM3"})
+	require.NoError(t, err)
+	require.Empty(t, results)
+}
+
+func TestSQLiteStorage_DeleteMetricsByPrefix(t *testing.T) {
+	s, err := NewSQLiteStorage(":memory:", 3600, SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = s.Close()
+	}()
+
+	ctx := context.Background()
+	now := time.Now().Unix()
+	require.NoError(t, s.SaveMetric(ctx, "VM1.Active", "bool", 1, "true", now))
+	require.NoError(t, s.SaveMetric(ctx, "VM1.Node1.nonce", "uint64", 10, "1", now))
+	require.NoError(t, s.SaveMetric(ctx, "VM10.Active", "bool", 1, "true", now))
+	require.NoError(t, s.SaveMetric(ctx, "VM1_Node1.nonce", "uint64", 10, "1", now))
+	require.NoError(t, s.SaveMetricTags(ctx, "VM1.Node1.nonce", map[string]string{"vm": "VM1"}))
+
+	_, err = s.DeleteMetricsByPrefix(ctx, "")
+	require.Equal(t, errEmptyPrefix, err)
+
+	numDeleted, err := s.DeleteMetricsByPrefix(ctx, "VM1.")
+	require.NoError(t, err)
+	require.Equal(t, int64(2), numDeleted)
+
+	latest, err := s.GetLatestMetrics(ctx)
+	require.NoError(t, err)
+	names := make([]string, 0, len(latest))
+	for _, metric := range latest {
+		names = append(names, metric.Name)
+	}
+	require.ElementsMatch(t, []string{"VM10.Active", "VM1_Node1.nonce"}, names)
+
+	info, err := s.GetSchemaInfo(ctx)
+	require.NoError(t, err)
+	for _, table := range info.Tables {
+		switch table.Name {
+		case "metrics_values":
+			require.Equal(t, int64(2), table.NumRows)
+		case "metric_tags":
+			require.Equal(t, int64(0), table.NumRows)
+		}
+	}
+}
+
+func TestSQLiteStorage_RenameMetric(t *testing.T) {
+	s, err := NewSQLiteStorage(":memory:", 3600, SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = s.Close()
+	}()
+
+	ctx := context.Background()
+	require.NoError(t, s.SaveMetric(ctx, "VM1.Node1.nonce", "uint64", 10, "1", 1000))
+	require.NoError(t, s.SaveMetric(ctx, "VM1.Node1.nonce", "uint64", 10, "2", 1001))
+	require.NoError(t, s.SaveMetric(ctx, "VM2.Node1.nonce", "uint64", 10, "3", 1000))
+	require.NoError(t, s.UpdateMetricAlarm(ctx, "VM1.Node1.nonce", true))
+	require.NoError(t, s.SaveMetricTags(ctx, "VM1.Node1.nonce", common.MergeTags("VM1.Node1.nonce", map[string]string{"shard": "0"})))
+
+	err = s.RenameMetric(ctx, "VM3.Node1.nonce", "VM4.Node1.nonce")
+	require.True(t, errors.Is(err, common.ErrMetricNotFound))
+
+	err = s.RenameMetric(ctx, "VM1.Node1.nonce", "VM2.Node1.nonce")
+	require.True(t, errors.Is(err, common.ErrMetricAlreadyExists))
+
+	err = s.RenameMetric(ctx, "VM1.Node1.nonce", "VM5.Validator.nonce")
+	require.NoError(t, err)
+
+	_, err = s.GetMetricHistory(ctx, "VM1.Node1.nonce")
+	require.True(t, errors.Is(err, common.ErrMetricNotFound))
+
+	hist, err := s.GetMetricHistory(ctx, "VM5.Validator.nonce")
+	require.NoError(t, err)
+	require.True(t, hist.IsAlarmEnabled)
+	require.Equal(t, 10, hist.NumAggregation)
+	require.Equal(t, []common.MetricValue{{Value: "1", RecordedAt: 1000}, {Value: "2", RecordedAt: 1001}}, hist.History)
+	require.Equal(t, map[string]string{"vm": "VM5", "node": "Validator", "kind": "nonce", "shard": "0"}, hist.Tags)
+
+	// the explicitly reported vm, node and kind tags are not derived from the name, the rename keeps them
+	require.NoError(t, s.SaveMetric(ctx, "VM6.Node1.nonce", "uint64", 10, "1", 1000))
+	require.NoError(t, s.SaveMetricTags(ctx, "VM6.Node1.nonce", common.MergeTags("VM6.Node1.nonce", map[string]string{"node": "observer-0"})))
+	require.NoError(t, s.RenameMetric(ctx, "VM6.Node1.nonce", "VM7.nonce"))
+
+	hist, err = s.GetMetricHistory(ctx, "VM7.nonce")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"vm": "VM7", "node": "observer-0", "kind": "nonce"}, hist.Tags)
+
+	// the derived node tag is removed when the new name has no node
+	require.NoError(t, s.RenameMetric(ctx, "VM5.Validator.nonce", "VM5.nonce"))
+	hist, err = s.GetMetricHistory(ctx, "VM5.nonce")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"vm": "VM5", "kind": "nonce", "shard": "0"}, hist.Tags)
+}
+
+func TestSQLiteStorage_MetricAnnotations(t *testing.T) {
+	s, err := NewSQLiteStorage(":memory:", 3600, SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = s.Close()
+	}()
+
+	ctx := context.Background()
+	require.NoError(t, s.SaveMetric(ctx, "VM1.Node1.nonce", "uint64", 2, "1", 1000))
+	require.NoError(t, s.SaveMetric(ctx, "VM1.Node1.nonce", "uint64", 2, "2", 1010))
+
+	_, err = s.AddMetricAnnotation(ctx, "VM2.Node1.nonce", "missing metric", 1000)
+	require.True(t, errors.Is(err, common.ErrMetricNotFound))
+
+	oldID, err := s.AddMetricAnnotation(ctx, "VM1.Node1.nonce", "old event", 900)
+	require.NoError(t, err)
+	id, err := s.AddMetricAnnotation(ctx, "VM1.Node1.nonce", "upgraded node to v1.7.0", 1005)
+	require.NoError(t, err)
+	require.NotEqual(t, oldID, id)
+
+	annotations, err := s.GetMetricAnnotations(ctx, "VM1.Node1.nonce")
+	require.NoError(t, err)
+	require.Equal(t, []common.MetricAnnotation{
+		{ID: oldID, Text: "old event", RecordedAt: 900},
+		{ID: id, Text: "upgraded node to v1.7.0", RecordedAt: 1005},
+	}, annotations)
+
+	// the history only contains the annotations overlapping the retained values
+	hist, err := s.GetMetricHistory(ctx, "VM1.Node1.nonce")
+	require.NoError(t, err)
+	require.Equal(t, []common.MetricAnnotation{{ID: id, Text: "upgraded node to v1.7.0", RecordedAt: 1005}}, hist.Annotations)
+
+	err = s.DeleteMetricAnnotation(ctx, "VM1.Node1.nonce", oldID)
+	require.NoError(t, err)
+	err = s.DeleteMetricAnnotation(ctx, "VM1.Node1.nonce", oldID)
+	require.Equal(t, common.ErrAnnotationNotFound, err)
+
+	// renaming the metric moves the annotations
+	require.NoError(t, s.RenameMetric(ctx, "VM1.Node1.nonce", "VM3.Node1.nonce"))
+	annotations, err = s.GetMetricAnnotations(ctx, "VM3.Node1.nonce")
+	require.NoError(t, err)
+	require.Len(t, annotations, 1)
+
+	// deleting the metric hides the annotations
+	require.NoError(t, s.DeleteMetric(ctx, "VM3.Node1.nonce"))
+	annotations, err = s.GetMetricAnnotations(ctx, "VM3.Node1.nonce")
+	require.NoError(t, err)
+	require.Empty(t, annotations)
+}
+
+func TestSQLiteStorage_GetMetricHistorySince(t *testing.T) {
+	s, err := NewSQLiteStorage(":memory:", 3600, SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = s.Close()
+	}()
+
+	ctx := context.Background()
+	require.NoError(t, s.SaveMetric(ctx, "VM1.Node1.nonce", "uint64", 3, "1", 1000))
+	require.NoError(t, s.SaveMetric(ctx, "VM1.Node1.nonce", "uint64", 3, "2", 1010))
+	require.NoError(t, s.SaveMetric(ctx, "VM1.Node1.nonce", "uint64", 3, "3", 1020))
+	_, err = s.AddMetricAnnotation(ctx, "VM1.Node1.nonce", "restarted", 1010)
+	require.NoError(t, err)
+	id, err := s.AddMetricAnnotation(ctx, "VM1.Node1.nonce", "upgraded", 1015)
+	require.NoError(t, err)
+
+	_, err = s.GetMetricHistorySince(ctx, "VM2.Node1.nonce", 1000)
+	require.True(t, errors.Is(err, common.ErrMetricNotFound))
+
+	// only the values and the annotations recorded after the provided timestamp are returned
+	hist, err := s.GetMetricHistorySince(ctx, "VM1.Node1.nonce", 1010)
+	require.NoError(t, err)
+	require.Equal(t, "VM1.Node1.nonce", hist.Name)
+	require.Equal(t, []common.MetricValue{{Value: "3", RecordedAt: 1020}}, hist.History)
+	require.Equal(t, []common.MetricAnnotation{{ID: id, Text: "upgraded", RecordedAt: 1015}}, hist.Annotations)
+
+	hist, err = s.GetMetricHistorySince(ctx, "VM1.Node1.nonce", 1020)
+	require.NoError(t, err)
+	require.Empty(t, hist.History)
+	require.Empty(t, hist.Annotations)
+
+	hist, err = s.GetMetricHistorySince(ctx, "VM1.Node1.nonce", 0)
+	require.NoError(t, err)
+	require.Len(t, hist.History, 3)
+	require.Len(t, hist.Annotations, 2)
+}
+
+func TestSQLiteStorage_Dashboards(t *testing.T) {
+	s, err := NewSQLiteStorage(":memory:", 3600, SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = s.Close()
+	}()
+
+	ctx := context.Background()
+	require.NoError(t, s.SaveMetric(ctx, "VM1.Node1.nonce", "uint64", 1, "1", 1000))
+
+	mainnetID, err := s.CreateDashboard(ctx, common.Dashboard{
+		Name:    "mainnet",
+		Panels:  []string{"VM2", "VM1"},
+		Metrics: []string{"VM1.Node1.nonce"},
+	})
+	require.NoError(t, err)
+	testnetID, err := s.CreateDashboard(ctx, common.Dashboard{Name: "testnet"})
+	require.NoError(t, err)
+
+	_, err = s.CreateDashboard(ctx, common.Dashboard{Name: "mainnet"})
+	require.True(t, errors.Is(err, common.ErrDashboardAlreadyExists))
+
+	dashboard, err := s.GetDashboard(ctx, mainnetID)
+	require.NoError(t, err)
+	require.Equal(t, &common.Dashboard{
+		ID:      mainnetID,
+		Name:    "mainnet",
+		Panels:  []string{"VM2", "VM1"},
+		Metrics: []string{"VM1.Node1.nonce"},
+	}, dashboard)
+
+	_, err = s.GetDashboard(ctx, 1000)
+	require.Equal(t, common.ErrDashboardNotFound, err)
+
+	err = s.UpdateDashboard(ctx, common.Dashboard{ID: testnetID, Name: "mainnet"})
+	require.True(t, errors.Is(err, common.ErrDashboardAlreadyExists))
+	err = s.UpdateDashboard(ctx, common.Dashboard{ID: 1000, Name: "devnet"})
+	require.Equal(t, common.ErrDashboardNotFound, err)
+	err = s.UpdateDashboard(ctx, common.Dashboard{ID: testnetID, Name: "devnet", Panels: []string{"VM3"}})
+	require.NoError(t, err)
+
+	dashboards, err := s.GetDashboards(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []common.Dashboard{
+		{ID: testnetID, Name: "devnet", Panels: []string{"VM3"}, Metrics: []string{}},
+		{ID: mainnetID, Name: "mainnet", Panels: []string{"VM2", "VM1"}, Metrics: []string{"VM1.Node1.nonce"}},
+	}, dashboards)
+
+	// the metric selections follow the renamed and deleted metrics
+	require.NoError(t, s.RenameMetric(ctx, "VM1.Node1.nonce", "VM1.Node2.nonce"))
+	dashboard, _ = s.GetDashboard(ctx, mainnetID)
+	require.Equal(t, []string{"VM1.Node2.nonce"}, dashboard.Metrics)
+	require.NoError(t, s.DeleteMetric(ctx, "VM1.Node2.nonce"))
+	dashboard, _ = s.GetDashboard(ctx, mainnetID)
+	require.Empty(t, dashboard.Metrics)
+
+	require.NoError(t, s.DeleteDashboard(ctx, mainnetID))
+	require.Equal(t, common.ErrDashboardNotFound, s.DeleteDashboard(ctx, mainnetID))
+	dashboards, err = s.GetDashboards(ctx)
+	require.NoError(t, err)
+	require.Len(t, dashboards, 1)
+}
+
+func TestSQLiteStorage_Tuning(t *testing.T) {
+	t.Parallel()
+
+	dbPath := filepath.Join(t.TempDir(), "tuning.db")
+	tuning := SQLiteTuning{
+		BusyTimeoutInMs:         1234,
+		SynchronousMode:         "normal",
+		CacheSizeInKB:           4096,
+		WALAutoCheckpointPages:  500,
+		CheckpointIntervalInSec: 3600,
+	}
+	s, err := NewSQLiteStorage(dbPath, 3600, tuning)
+	require.NoError(t, err)
+	defer func() {
+		_ = s.Close()
+	}()
+	require.Equal(t, time.Hour, s.checkpointInterval)
+
+	ctx := context.Background()
+	conn, err := s.db.Conn(ctx)
+	require.NoError(t, err)
+
+	readPragma := func(pragma string) int64 {
+		var value int64
+		errQuery := conn.QueryRowContext(ctx, "PRAGMA "+pragma+";").Scan(&value)
+		require.NoError(t, errQuery)
+		return value
+	}
+	require.Equal(t, int64(1234), readPragma("busy_timeout"))
+	require.Equal(t, int64(1), readPragma("synchronous")) // NORMAL
+	require.Equal(t, int64(-4096), readPragma("cache_size"))
+	require.Equal(t, int64(500), readPragma("wal_autocheckpoint"))
+	_ = conn.Close()
+
+	for i := 0; i < 10; i++ {
+		err = s.SaveMetric(ctx, "VM1.Node1.nonce", "uint64", 100, strconv.Itoa(i), time.Now().Unix())
+		require.NoError(t, err)
+	}
+
+	walInfo, err := os.Stat(dbPath + "-wal")
+	require.NoError(t, err)
+	require.Greater(t, walInfo.Size(), int64(0))
+
+	err = s.checkpointWAL(ctx)
+	require.NoError(t, err)
+
+	walInfo, err = os.Stat(dbPath + "-wal")
+	require.NoError(t, err)
+	require.Equal(t, int64(0), walInfo.Size())
+}
+
+func TestSQLiteStorage_ReadConnections(t *testing.T) {
+	t.Parallel()
+
+	dbPath := filepath.Join(t.TempDir(), "connections.db")
+	s, err := NewSQLiteStorage(dbPath, 3600, SQLiteTuning{MaxReadConnections: 2})
+	require.NoError(t, err)
+	defer func() {
+		_ = s.Close()
+	}()
+	require.NotEqual(t, s.db, s.readDB)
+	require.Equal(t, 1, s.db.Stats().MaxOpenConnections)
+	require.Equal(t, 2, s.readDB.Stats().MaxOpenConnections)
+
+	ctx := context.Background()
+	err = s.SaveMetric(ctx, "VM1.Node1.nonce", "uint64", 10, "1", time.Now().Unix())
+	require.NoError(t, err)
+
+	// the read connections can not write
+	_, err = s.readDB.ExecContext(ctx, "DELETE FROM metrics")
+	require.Error(t, err)
+
+	// the reads are not blocked by an open write transaction
+	tx, err := s.db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	_, err = tx.ExecContext(ctx, "DELETE FROM metrics_values")
+	require.NoError(t, err)
+
+	history, err := s.GetMetricHistory(ctx, "VM1.Node1.nonce")
+	require.NoError(t, err)
+	require.Len(t, history.History, 1)
+	require.NoError(t, tx.Commit())
+
+	history, err = s.GetMetricHistory(ctx, "VM1.Node1.nonce")
+	require.NoError(t, err)
+	require.Empty(t, history.History)
+
+	// the in-memory databases share the write connection
+	memStorage, err := NewSQLiteStorage(":memory:", 3600, SQLiteTuning{})
+	require.NoError(t, err)
+	require.Equal(t, memStorage.db, memStorage.readDB)
+	require.NoError(t, memStorage.Close())
+}
+
+func TestSQLiteStorage_SaveMetrics(t *testing.T) {
+	t.Parallel()
+
+	s, err := NewSQLiteStorage(":memory:", 3600, SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = s.Close()
+	}()
+
+	ctx := context.Background()
+	require.NoError(t, s.SaveMetrics(ctx, nil))
+
+	records := []common.MetricRecord{
+		{Name: "VM1.Node1.nonce", Type: "uint64", NumAggregation: 2, Value: "10", Tags: map[string]string{"shard": "0"}, RecordedAt: 100},
+		{Name: "VM1.Node1.nonce", Type: "uint64", NumAggregation: 2, Value: "11", RecordedAt: 101},
+		{Name: "VM1.Node1.nonce", Type: "uint64", NumAggregation: 2, Value: "12", RecordedAt: 102},
+		{Name: "VM1.Active", Type: "bool", NumAggregation: 1, Value: "true", RecordedAt: 102, ReceivedAt: 110},
+	}
+	require.NoError(t, s.SaveMetrics(ctx, records))
+
+	// the values without a reception timestamp were received when recorded
+	var receivedAt int64
+	err = s.db.QueryRow("SELECT received_at FROM metrics_values WHERE metric_name = 'VM1.Active'").Scan(&receivedAt)
+	require.NoError(t, err)
+	require.Equal(t, int64(110), receivedAt)
+	err = s.db.QueryRow("SELECT received_at FROM metrics_values WHERE metric_name = 'VM1.Node1.nonce' AND recorded_at = 102").Scan(&receivedAt)
+	require.NoError(t, err)
+	require.Equal(t, int64(102), receivedAt)
+
+	history, err := s.GetMetricHistory(ctx, "VM1.Node1.nonce")
+	require.NoError(t, err)
+	require.Len(t, history.History, 2)
+	require.Equal(t, "0", history.Tags["shard"])
+
+	_, err = s.GetMetricHistory(ctx, "VM1.Active")
+	require.NoError(t, err)
+
+	// a failing record rolls back the whole batch
+	_, err = s.db.Exec("CREATE TRIGGER reject_vm2 BEFORE INSERT ON metrics_values WHEN NEW.metric_name = 'VM2.Active' BEGIN SELECT RAISE(ABORT, 'rejected'); END;")
+	require.NoError(t, err)
+
+	records = []common.MetricRecord{
+		{Name: "VM3.Active", Type: "bool", NumAggregation: 1, Value: "true", RecordedAt: 103},
+		{Name: "VM2.Active", Type: "bool", NumAggregation: 1, Value: "true", RecordedAt: 103},
+	}
+	err = s.SaveMetrics(ctx, records)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "VM2.Active")
+
+	_, err = s.GetMetricHistory(ctx, "VM3.Active")
+	require.Equal(t, common.ErrMetricNotFound, err)
+}
+
+func TestSQLiteStorage_Tenants(t *testing.T) {
+	t.Parallel()
+
+	s, err := NewSQLiteStorage(":memory:", 3600, SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = s.Close()
+	}()
+
+	ctx := context.Background()
+	records := []common.MetricRecord{
+		{Name: "VM1.Node1.nonce", Type: "uint64", NumAggregation: 2, Value: "10", RecordedAt: 100, Tenant: "acme"},
+		{Name: "VM2.Node1.nonce", Type: "uint64", NumAggregation: 2, Value: "20", RecordedAt: 100, Tenant: "globex"},
+	}
+	require.NoError(t, s.SaveMetrics(ctx, records))
+	require.NoError(t, s.SaveMetric(ctx, "VM3.Node1.nonce", "uint64", 2, "30", 100))
+
+	// the metric names are unique across tenants
+	err = s.SaveMetrics(ctx, []common.MetricRecord{{Name: "VM1.Node1.nonce", Type: "uint64", NumAggregation: 2, Value: "11", RecordedAt: 101, Tenant: "globex"}})
+	require.True(t, errors.Is(err, common.ErrMetricTenantMismatch))
+	err = s.SaveMetric(ctx, "VM2.Node1.nonce", "uint64", 2, "21", 101)
+	require.True(t, errors.Is(err, common.ErrMetricTenantMismatch))
+	require.NoError(t, s.SaveMetrics(ctx, []common.MetricRecord{{Name: "VM1.Node1.nonce", Type: "uint64", NumAggregation: 2, Value: "11", RecordedAt: 101, Tenant: "acme"}}))
+
+	history, err := s.GetMetricHistory(ctx, "VM1.Node1.nonce")
+	require.NoError(t, err)
+	require.Equal(t, "acme", history.Tenant)
+	require.Len(t, history.History, 2)
+	tenant, err := s.GetMetricTenant(ctx, "VM3.Node1.nonce")
+	require.NoError(t, err)
+	require.Empty(t, tenant)
+	_, err = s.GetMetricTenant(ctx, "VM4.Node1.nonce")
+	require.Equal(t, common.ErrMetricNotFound, err)
+	history, err = s.GetMetricHistory(ctx, "VM2.Node1.nonce")
+	require.NoError(t, err)
+	require.Len(t, history.History, 1)
+
+	latest, err := s.GetLatestMetricsFiltered(ctx, common.MetricsFilter{Tenant: "acme"})
+	require.NoError(t, err)
+	require.Len(t, latest, 1)
+	require.Equal(t, "VM1.Node1.nonce", latest[0].Name)
+	require.Equal(t, "acme", latest[0].Tenant)
+
+	latest, err = s.GetLatestMetrics(ctx)
+	require.NoError(t, err)
+	require.Len(t, latest, 3)
+
+	// the renamed metric stays with its tenant
+	require.NoError(t, s.RenameMetric(ctx, "VM1.Node1.nonce", "VM1.Node2.nonce"))
+	history, err = s.GetMetricHistory(ctx, "VM1.Node2.nonce")
+	require.NoError(t, err)
+	require.Equal(t, "acme", history.Tenant)
+
+	_, err = s.CreateAlert(ctx, "VM1.Node2.nonce", "Host appears offline", 1000)
+	require.NoError(t, err)
+	_, err = s.CreateAlert(ctx, "VM2.Node1.nonce", "Host appears offline", 1000)
+	require.NoError(t, err)
+	alerts, err := s.GetAlerts(ctx, common.AlertsFilter{Tenant: "acme"})
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	require.Equal(t, "VM1.Node2.nonce", alerts[0].MetricName)
+}
+
+func TestSQLiteStorage_OperationStats(t *testing.T) {
+	t.Parallel()
+
+	t.Run("defaults", func(t *testing.T) {
+		t.Parallel()
+
+		s, err := NewSQLiteStorage(":memory:", 3600, SQLiteTuning{})
+		require.NoError(t, err)
+		defer func() {
+			_ = s.Close()
+		}()
+
+		require.Equal(t, defaultOperationTimeout, s.operationTimeout)
+		require.Equal(t, defaultSlowQueryThreshold, s.slowQueryThreshold)
+
+		ctx := context.Background()
+		require.NoError(t, s.SaveMetric(ctx, "VM1.Node1.nonce", "uint64", 10, "1", 100))
+		_, err = s.GetMetricHistory(ctx, "VM1.Node1.nonce")
+		require.NoError(t, err)
+
+		stats := s.GetStorageStats()
+		require.Equal(t, uint64(2), stats.NumOperations)
+		require.Equal(t, uint64(0), stats.NumSlowOperations)
+		require.Equal(t, uint64(0), stats.NumTimedOutOperations)
+		require.Equal(t, int64(500), stats.SlowQueryThresholdInMs)
+	})
+	t.Run("slow and timed out operations should be counted", func(t *testing.T) {
+		t.Parallel()
+
+		s, err := NewSQLiteStorage(":memory:", 3600, SQLiteTuning{OperationTimeoutInMs: 1000, SlowQueryThresholdInMs: 1})
+		require.NoError(t, err)
+		defer func() {
+			_ = s.Close()
+		}()
+		require.Equal(t, time.Second, s.operationTimeout)
+
+		_, finish := s.startOperation(context.Background(), "test")
+		time.Sleep(time.Millisecond * 5)
+		finish()
+
+		expiredCtx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		defer cancel()
+		_, err = s.GetMetricHistory(expiredCtx, "VM1.Node1.nonce")
+		require.Error(t, err)
+
+		stats := s.GetStorageStats()
+		require.Equal(t, uint64(2), stats.NumOperations)
+		require.GreaterOrEqual(t, stats.NumSlowOperations, uint64(1))
+		require.Equal(t, uint64(1), stats.NumTimedOutOperations)
+		require.GreaterOrEqual(t, stats.MaxOperationMs, float64(5))
+	})
+}
@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+const (
+	upsertMetricQuery = `
+		INSERT INTO metrics (name, type, num_aggregation, tenant) 
+		VALUES (?, ?, ?, ?) 
+		ON CONFLICT(name) DO UPDATE SET 
+			type=excluded.type, 
+			num_aggregation=excluded.num_aggregation
+		WHERE metrics.tenant = excluded.tenant
+		RETURNING aggregation_mode, store_on_change, compressed, deleted_at
+	`
+	insertValueQuery = `
+		INSERT INTO metrics_values (metric_name, value, value_num, recorded_at, received_at)
+		VALUES (?, ?, ?, ?, ?)
+	`
+	latestValueQuery = `
+		SELECT rowid, value, recorded_at FROM metrics_values
+		WHERE metric_name = ?
+		ORDER BY recorded_at DESC
+		LIMIT 1
+	`
+	touchValueQuery = `
+		UPDATE metrics_values SET last_seen_at = MAX(last_seen_at, ?) WHERE rowid = ?
+	`
+	trimValuesQuery = `
+		DELETE FROM metrics_values
+		WHERE metric_name = ?
+		  AND rowid NOT IN (
+			  SELECT rowid FROM metrics_values
+			  WHERE metric_name = ?
+			  ORDER BY recorded_at DESC
+			  LIMIT ?
+		  )
+	`
+	aggregateValuesQuery = `
+		SELECT CASE ?
+			WHEN 'avg' THEN SUM(sum_value) * 1.0 / SUM(num_numeric)
+			WHEN 'min' THEN MIN(min_value)
+			WHEN 'max' THEN MAX(max_value)
+			WHEN 'sum' THEN SUM(sum_value)
+		END
+		FROM (
+			SELECT SUM(value_num) AS sum_value, COUNT(value_num) AS num_numeric, MIN(value_num) AS min_value,
+				MAX(value_num) AS max_value
+			FROM metrics_values
+			WHERE metric_name = ?
+			UNION ALL
+			SELECT sum_value, num_numeric, min_value, max_value
+			FROM metric_value_chunks
+			WHERE metric_name = ?
+		)
+	`
+	upsertAvailabilityQuery = `
+		INSERT INTO metric_availability (metric_name, bucket_start, num_samples, num_success)
+		VALUES (?, ?, 1, ?)
+		ON CONFLICT(metric_name, bucket_start) DO UPDATE SET
+			num_samples = num_samples + 1,
+			num_success = num_success + excluded.num_success
+	`
+)
+
+// saveStatements holds the statements run for each reported value, prepared once on the write connection instead of
+// being parsed by SQLite on each execution
+type saveStatements struct {
+	upsertMetric       *sql.Stmt
+	insertValue        *sql.Stmt
+	latestValue        *sql.Stmt
+	touchValue         *sql.Stmt
+	trimValues         *sql.Stmt
+	aggregateValues    *sql.Stmt
+	upsertAvailability *sql.Stmt
+}
+
+func prepareSaveStatements(db *sql.DB) (*saveStatements, error) {
+	statements := &saveStatements{}
+	queries := []struct {
+		stmt  **sql.Stmt
+		query string
+	}{
+		{stmt: &statements.upsertMetric, query: upsertMetricQuery},
+		{stmt: &statements.insertValue, query: insertValueQuery},
+		{stmt: &statements.latestValue, query: latestValueQuery},
+		{stmt: &statements.touchValue, query: touchValueQuery},
+		{stmt: &statements.trimValues, query: trimValuesQuery},
+		{stmt: &statements.aggregateValues, query: aggregateValuesQuery},
+		{stmt: &statements.upsertAvailability, query: upsertAvailabilityQuery},
+	}
+
+	for _, q := range queries {
+		stmt, err := db.Prepare(q.query)
+		if err != nil {
+			statements.close()
+			return nil, fmt.Errorf("failed to prepare the statement: %w", err)
+		}
+		*q.stmt = stmt
+	}
+
+	return statements, nil
+}
+
+func (statements *saveStatements) close() {
+	all := []*sql.Stmt{statements.upsertMetric, statements.insertValue, statements.latestValue, statements.touchValue,
+		statements.trimValues, statements.aggregateValues, statements.upsertAvailability}
+	for _, stmt := range all {
+		if stmt != nil {
+			_ = stmt.Close()
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+)
+
+// GetMetricStats returns the statistics of the values of a numeric metric recorded between from and to, inclusive.
+// The percentiles are computed in SQL with the nearest-rank method: the k-th smallest value, k = ceil(n * p / 100).
+func (s *sqliteStorage) GetMetricStats(ctx context.Context, name string, from int64, to int64) (*common.MetricStats, error) {
+	ctx, finish := s.startOperation(ctx, "GetMetricStats")
+	defer finish()
+
+	var metricType string
+	err := s.readDB.QueryRowContext(ctx, "SELECT type FROM metrics WHERE name = ? AND deleted_at = 0", name).Scan(&metricType)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, common.ErrMetricNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !common.IsNumericMetricType(metricType) {
+		return nil, fmt.Errorf("%w: %s", common.ErrMetricNotNumeric, name)
+	}
+
+	stats := &common.MetricStats{
+		Name: name,
+		From: from,
+		To:   to,
+	}
+	packed, err := loadChunkValues(ctx, s.readDB, `
+		SELECT metric_name, data FROM metric_value_chunks
+		WHERE metric_name = ? AND first_recorded_at <= ? AND last_seen_at >= ?
+	`, name, to, from)
+	if err != nil {
+		return nil, err
+	}
+	if len(packed[name]) > 0 {
+		return s.getPackedMetricStats(ctx, stats, metricType, packed[name])
+	}
+
+	var minValue, maxValue, mean, p50, p95, p99 sql.NullFloat64
+	err = s.readDB.QueryRowContext(ctx, `
+		WITH ranked AS (
+			SELECT value_num,
+				ROW_NUMBER() OVER (ORDER BY value_num) AS rn,
+				COUNT(*) OVER () AS cnt
+			FROM metrics_values
+			WHERE metric_name = ? AND value_num IS NOT NULL AND recorded_at >= ? AND recorded_at <= ?
+		)
+		SELECT COUNT(*), MIN(value_num), MAX(value_num), AVG(value_num),
+			MAX(CASE WHEN rn = (cnt * 50 + 99) / 100 THEN value_num END),
+			MAX(CASE WHEN rn = (cnt * 95 + 99) / 100 THEN value_num END),
+			MAX(CASE WHEN rn = (cnt * 99 + 99) / 100 THEN value_num END)
+		FROM ranked
+	`, name, from, to).Scan(&stats.NumValues, &minValue, &maxValue, &mean, &p50, &p95, &p99)
+	if err != nil {
+		return nil, fmt.Errorf("stats query failed: %w", err)
+	}
+
+	stats.Min = nullableFloat(minValue)
+	stats.Max = nullableFloat(maxValue)
+	stats.Mean = nullableFloat(mean)
+	stats.P50 = nullableFloat(p50)
+	stats.P95 = nullableFloat(p95)
+	stats.P99 = nullableFloat(p99)
+
+	return stats, nil
+}
+
+// getPackedMetricStats computes in memory the statistics of a compressed metric, over its raw and packed values
+func (s *sqliteStorage) getPackedMetricStats(ctx context.Context, stats *common.MetricStats, metricType string, packed []common.MetricValue) (*common.MetricStats, error) {
+	values := make([]float64, 0, len(packed))
+	for _, value := range packed {
+		numeric, isNumeric := floatValue(numericValue(metricType, value.Value))
+		if isNumeric && value.RecordedAt >= stats.From && value.RecordedAt <= stats.To {
+			values = append(values, numeric)
+		}
+	}
+
+	rows, err := s.readDB.QueryContext(ctx, `
+		SELECT value_num FROM metrics_values
+		WHERE metric_name = ? AND value_num IS NOT NULL AND recorded_at >= ? AND recorded_at <= ?
+	`, stats.Name, stats.From, stats.To)
+	if err != nil {
+		return nil, fmt.Errorf("stats query failed: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	for rows.Next() {
+		var value float64
+		err = rows.Scan(&value)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return stats, nil
+	}
+
+	sort.Float64s(values)
+	total := 0.0
+	for _, value := range values {
+		total += value
+	}
+	mean := total / float64(len(values))
+	stats.NumValues = len(values)
+	stats.Min = &values[0]
+	stats.Max = &values[len(values)-1]
+	stats.Mean = &mean
+	stats.P50 = nearestRank(values, 50)
+	stats.P95 = nearestRank(values, 95)
+	stats.P99 = nearestRank(values, 99)
+
+	return stats, nil
+}
+
+// nearestRank returns the k-th smallest of the sorted values, k = ceil(n * p / 100), as the SQL stats query does
+func nearestRank(sorted []float64, p int) *float64 {
+	value := sorted[max((len(sorted)*p+99)/100, 1)-1]
+	return &value
+}
+
+func nullableFloat(value sql.NullFloat64) *float64 {
+	if !value.Valid {
+		return nil
+	}
+
+	return &value.Float64
+}
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteStorage_GetMetricStats(t *testing.T) {
+	s, err := NewSQLiteStorage(":memory:", 3600, SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = s.Close()
+	}()
+
+	ctx := context.Background()
+
+	// the values 1..100, in reverse order, recorded at 1001..1100
+	for i := 100; i >= 1; i-- {
+		require.NoError(t, s.SaveMetric(ctx, "VM1.latency", common.MetricTypeFloat64, 1000, strconv.Itoa(i), int64(1101-i)))
+	}
+	require.NoError(t, s.SaveMetric(ctx, "VM1.version", common.MetricTypeString, 1, "v1.0.0", 1000))
+
+	_, err = s.GetMetricStats(ctx, "VM1.missing", 0, 2000)
+	require.True(t, errors.Is(err, common.ErrMetricNotFound))
+	_, err = s.GetMetricStats(ctx, "VM1.version", 0, 2000)
+	require.True(t, errors.Is(err, common.ErrMetricNotNumeric))
+
+	stats, err := s.GetMetricStats(ctx, "VM1.latency", 0, 2000)
+	require.NoError(t, err)
+	require.Equal(t, 100, stats.NumValues)
+	require.Equal(t, 1.0, *stats.Min)
+	require.Equal(t, 100.0, *stats.Max)
+	require.Equal(t, 50.5, *stats.Mean)
+	require.Equal(t, 50.0, *stats.P50)
+	require.Equal(t, 95.0, *stats.P95)
+	require.Equal(t, 99.0, *stats.P99)
+
+	// the range holds the values 91..100
+	stats, err = s.GetMetricStats(ctx, "VM1.latency", 1001, 1010)
+	require.NoError(t, err)
+	require.Equal(t, int64(1001), stats.From)
+	require.Equal(t, 10, stats.NumValues)
+	require.Equal(t, 91.0, *stats.Min)
+	require.Equal(t, 95.0, *stats.P50)
+	require.Equal(t, 100.0, *stats.P95)
+	require.Equal(t, 100.0, *stats.P99)
+
+	stats, err = s.GetMetricStats(ctx, "VM1.latency", 3000, 4000)
+	require.NoError(t, err)
+	require.Equal(t, 0, stats.NumValues)
+	require.Nil(t, stats.Min)
+	require.Nil(t, stats.P99)
+}
@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+)
+
+// SetMetricThresholds stores the display thresholds of a metric, nil or empty thresholds remove them
+func (s *sqliteStorage) SetMetricThresholds(ctx context.Context, name string, thresholds *common.MetricThresholds) error {
+	ctx, finish := s.startOperation(ctx, "SetMetricThresholds")
+	defer finish()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var exists int
+	err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM metrics WHERE name = ? AND deleted_at = 0", name).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists == 0 {
+		return common.ErrMetricNotFound
+	}
+
+	if thresholds == nil || thresholds.IsEmpty() {
+		thresholds = nil
+		_, err = tx.ExecContext(ctx, "DELETE FROM metric_thresholds WHERE metric_name = ?", name)
+	} else {
+		below := 0
+		if thresholds.Below {
+			below = 1
+		}
+		expected := ""
+		if len(thresholds.Expected) > 0 {
+			buff, errMarshal := json.Marshal(thresholds.Expected)
+			if errMarshal != nil {
+				return errMarshal
+			}
+			expected = string(buff)
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO metric_thresholds (metric_name, warn, crit, below, expected)
+			VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT(metric_name) DO UPDATE SET warn=excluded.warn, crit=excluded.crit, below=excluded.below, expected=excluded.expected
+		`, name, thresholds.Warn, thresholds.Crit, below, expected)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	s.latest.update(name, func(metric *common.MetricHistory) {
+		metric.Thresholds = thresholds
+	})
+
+	return nil
+}
+
+func (s *sqliteStorage) getAllThresholds(ctx context.Context) (map[string]*common.MetricThresholds, error) {
+	rows, err := s.readDB.QueryContext(ctx, "SELECT metric_name, warn, crit, below, expected FROM metric_thresholds")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	res := make(map[string]*common.MetricThresholds)
+	for rows.Next() {
+		var name, expected string
+		var warn, crit sql.NullFloat64
+		var below int
+		err = rows.Scan(&name, &warn, &crit, &below, &expected)
+		if err != nil {
+			return nil, err
+		}
+
+		thresholds := &common.MetricThresholds{Below: below == 1}
+		if warn.Valid {
+			thresholds.Warn = &warn.Float64
+		}
+		if crit.Valid {
+			thresholds.Crit = &crit.Float64
+		}
+		if len(expected) > 0 {
+			err = json.Unmarshal([]byte(expected), &thresholds.Expected)
+			if err != nil {
+				return nil, fmt.Errorf("invalid expected values of metric %s: %w", name, err)
+			}
+		}
+		res[name] = thresholds
+	}
+
+	return res, rows.Err()
+}
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteStorage_SetMetricThresholds(t *testing.T) {
+	t.Parallel()
+
+	s, err := NewSQLiteStorage(":memory:", 3600, SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = s.Close()
+	}()
+
+	ctx := context.Background()
+	require.NoError(t, s.SaveMetric(ctx, "VM1.cpu", common.MetricTypeUint64, 1, "50", 1000))
+	require.NoError(t, s.SaveMetric(ctx, "VM1.status", common.MetricTypeString, 1, "synced", 1000))
+
+	getThresholds := func() map[string]*common.MetricThresholds {
+		latest, errGet := s.GetLatestMetrics(ctx)
+		require.NoError(t, errGet)
+
+		res := make(map[string]*common.MetricThresholds)
+		for _, metric := range latest {
+			res[metric.Name] = metric.Thresholds
+		}
+		return res
+	}
+
+	warn, crit := 80.0, 90.0
+	require.ErrorIs(t, s.SetMetricThresholds(ctx, "VM1.missing", &common.MetricThresholds{Warn: &warn}), common.ErrMetricNotFound)
+
+	// load the latest values cache before the changes, so both the cache and the database are checked
+	require.Nil(t, getThresholds()["VM1.cpu"])
+	cpuThresholds := &common.MetricThresholds{Warn: &warn, Crit: &crit}
+	statusThresholds := &common.MetricThresholds{Expected: []string{"synced", "syncing"}}
+	require.NoError(t, s.SetMetricThresholds(ctx, "VM1.cpu", cpuThresholds))
+	require.NoError(t, s.SetMetricThresholds(ctx, "VM1.status", statusThresholds))
+	expected := map[string]*common.MetricThresholds{"VM1.cpu": cpuThresholds, "VM1.status": statusThresholds}
+	require.Equal(t, expected, getThresholds())
+
+	s.latest.invalidate()
+	require.Equal(t, expected, getThresholds())
+
+	require.NoError(t, s.RenameMetric(ctx, "VM1.cpu", "VM1.load"))
+	require.Equal(t, cpuThresholds, getThresholds()["VM1.load"])
+
+	require.NoError(t, s.SetMetricThresholds(ctx, "VM1.load", &common.MetricThresholds{}))
+	require.NoError(t, s.SetMetricThresholds(ctx, "VM1.status", nil))
+	require.Equal(t, map[string]*common.MetricThresholds{"VM1.load": nil, "VM1.status": nil}, getThresholds())
+
+	s.latest.invalidate()
+	require.Equal(t, map[string]*common.MetricThresholds{"VM1.load": nil, "VM1.status": nil}, getThresholds())
+}
@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+)
+
+// GetTOTPEnrollment returns the two-factor authentication settings of the account
+func (s *sqliteStorage) GetTOTPEnrollment(ctx context.Context, username string) (*common.TOTPEnrollment, error) {
+	ctx, finish := s.startOperation(ctx, "GetTOTPEnrollment")
+	defer finish()
+
+	enrollment := &common.TOTPEnrollment{}
+	var recoveryCodes string
+	err := s.readDB.QueryRowContext(ctx, `
+		SELECT username, secret, enabled, last_step, recovery_codes, created_at FROM totp_enrollments WHERE username = ?
+	`, username).Scan(&enrollment.Username, &enrollment.Secret, &enrollment.Enabled, &enrollment.LastStep, &recoveryCodes, &enrollment.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, common.ErrTOTPNotEnrolled
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the TOTP enrollment: %w", err)
+	}
+
+	err = json.Unmarshal([]byte(recoveryCodes), &enrollment.RecoveryCodes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode the recovery codes: %w", err)
+	}
+
+	return enrollment, nil
+}
+
+// SaveTOTPEnrollment stores the two-factor authentication settings of the account, replacing the existing ones
+func (s *sqliteStorage) SaveTOTPEnrollment(ctx context.Context, enrollment common.TOTPEnrollment) error {
+	ctx, finish := s.startOperation(ctx, "SaveTOTPEnrollment")
+	defer finish()
+
+	recoveryCodes := enrollment.RecoveryCodes
+	if recoveryCodes == nil {
+		recoveryCodes = make([]string, 0)
+	}
+	recoveryCodesBytes, err := json.Marshal(recoveryCodes)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO totp_enrollments (username, secret, enabled, last_step, recovery_codes, created_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(username) DO UPDATE SET
+			secret = excluded.secret,
+			enabled = excluded.enabled,
+			last_step = excluded.last_step,
+			recovery_codes = excluded.recovery_codes,
+			created_at = excluded.created_at
+	`, enrollment.Username, enrollment.Secret, enrollment.Enabled, enrollment.LastStep, string(recoveryCodesBytes), enrollment.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save the TOTP enrollment: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteTOTPEnrollment removes the two-factor authentication settings of the account
+func (s *sqliteStorage) DeleteTOTPEnrollment(ctx context.Context, username string) error {
+	ctx, finish := s.startOperation(ctx, "DeleteTOTPEnrollment")
+	defer finish()
+
+	result, err := s.db.ExecContext(ctx, "DELETE FROM totp_enrollments WHERE username = ?", username)
+	if err != nil {
+		return fmt.Errorf("failed to delete the TOTP enrollment: %w", err)
+	}
+
+	numRows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if numRows == 0 {
+		return common.ErrTOTPNotEnrolled
+	}
+
+	return nil
+}
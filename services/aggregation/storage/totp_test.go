@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteStorage_TOTPEnrollments(t *testing.T) {
+	s, err := NewSQLiteStorage(":memory:", 3600, SQLiteTuning{})
+	require.NoError(t, err)
+	defer func() {
+		_ = s.Close()
+	}()
+
+	ctx := context.Background()
+
+	_, err = s.GetTOTPEnrollment(ctx, "admin")
+	require.True(t, errors.Is(err, common.ErrTOTPNotEnrolled))
+
+	pending := common.TOTPEnrollment{Username: "admin", Secret: "JBSWY3DPEHPK3PXP", CreatedAt: 1000}
+	require.NoError(t, s.SaveTOTPEnrollment(ctx, pending))
+	enrollment, err := s.GetTOTPEnrollment(ctx, "admin")
+	require.NoError(t, err)
+	pending.RecoveryCodes = make([]string, 0)
+	require.Equal(t, &pending, enrollment)
+
+	enabled := common.TOTPEnrollment{
+		Username:      "admin",
+		Secret:        "JBSWY3DPEHPK3PXP",
+		Enabled:       true,
+		LastStep:      56666666,
+		RecoveryCodes: []string{"hash1", "hash2"},
+		CreatedAt:     1000,
+	}
+	require.NoError(t, s.SaveTOTPEnrollment(ctx, enabled))
+	enrollment, err = s.GetTOTPEnrollment(ctx, "admin")
+	require.NoError(t, err)
+	require.Equal(t, &enabled, enrollment)
+
+	require.NoError(t, s.DeleteTOTPEnrollment(ctx, "admin"))
+	_, err = s.GetTOTPEnrollment(ctx, "admin")
+	require.True(t, errors.Is(err, common.ErrTOTPNotEnrolled))
+	err = s.DeleteTOTPEnrollment(ctx, "admin")
+	require.True(t, errors.Is(err, common.ErrTOTPNotEnrolled))
+}
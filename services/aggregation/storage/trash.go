@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+)
+
+// RestoreMetric brings back a deleted metric, with its history
+func (s *sqliteStorage) RestoreMetric(ctx context.Context, name string) error {
+	ctx, finish := s.startOperation(ctx, "RestoreMetric")
+	defer finish()
+	defer s.latest.invalidate()
+
+	result, err := s.db.ExecContext(ctx, "UPDATE metrics SET deleted_at = 0 WHERE name = ? AND deleted_at > 0", name)
+	if err != nil {
+		return err
+	}
+
+	numRestored, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if numRestored == 0 {
+		return common.ErrMetricNotFound
+	}
+
+	return nil
+}
+
+// GetTrashedMetrics returns the deleted metrics of the tenant not yet purged, the most recently deleted first. All the
+// deleted metrics are returned if the tenant is empty.
+func (s *sqliteStorage) GetTrashedMetrics(ctx context.Context, tenant string) ([]common.TrashedMetric, error) {
+	ctx, finish := s.startOperation(ctx, "GetTrashedMetrics")
+	defer finish()
+
+	query := "SELECT name, type, tenant, deleted_at FROM metrics WHERE deleted_at > 0"
+	args := make([]interface{}, 0, 1)
+	if len(tenant) > 0 {
+		query += " AND tenant = ?"
+		args = append(args, tenant)
+	}
+	query += " ORDER BY deleted_at DESC, name"
+
+	rows, err := s.readDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	trashRetention := int64(s.trashRetention.Seconds())
+	metrics := make([]common.TrashedMetric, 0)
+	for rows.Next() {
+		var metric common.TrashedMetric
+		err = rows.Scan(&metric.Name, &metric.Type, &metric.Tenant, &metric.DeletedAt)
+		if err != nil {
+			return nil, err
+		}
+		metric.PurgeAt = metric.DeletedAt + trashRetention
+		metrics = append(metrics, metric)
+	}
+
+	return metrics, rows.Err()
+}
+
+// purgeTrashedMetrics permanently deletes, after archiving them, the metrics deleted before the cutoff, with their
+// values, tags, annotations and availability data
+func (s *sqliteStorage) purgeTrashedMetrics(ctx context.Context, cutoff int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	err = s.archiveMetrics(ctx, tx, "deleted_at > 0 AND deleted_at < ?", cutoff)
+	if err != nil {
+		return fmt.Errorf("failed to archive the purged metrics: %w", err)
+	}
+
+	for _, table := range []string{"metric_tags", "metric_annotations", "dashboard_metrics", "custom_panel_metrics", "metric_thresholds", "metric_availability", "metrics_values", "metric_value_chunks"} {
+		query := fmt.Sprintf("DELETE FROM %s WHERE metric_name IN (SELECT name FROM metrics WHERE deleted_at > 0 AND deleted_at < ?)", table)
+		_, err = tx.ExecContext(ctx, query, cutoff)
+		if err != nil {
+			return err
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM metrics WHERE deleted_at > 0 AND deleted_at < ?", cutoff)
+	if err != nil {
+		return err
+	}
+	numPurged, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	if numPurged > 0 {
+		log.Info("purged the deleted metrics", "num metrics", numPurged)
+	}
+
+	return nil
+}
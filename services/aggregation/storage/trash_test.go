@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteStorage_Trash(t *testing.T) {
+	t.Parallel()
+
+	s, err := NewSQLiteStorage(":memory:", 3600, SQLiteTuning{TrashRetentionSeconds: 3600})
+	require.NoError(t, err)
+	defer func() {
+		_ = s.Close()
+	}()
+
+	ctx := context.Background()
+	now := time.Now().Unix()
+	require.NoError(t, s.SaveMetrics(ctx, []common.MetricRecord{
+		{Name: "VM1.Node1.nonce", Type: common.MetricTypeUint64, NumAggregation: 1, Value: "10", RecordedAt: now, Tags: map[string]string{"vm": "VM1"}},
+		{Name: "VM2.Node1.nonce", Type: common.MetricTypeUint64, NumAggregation: 1, Value: "20", RecordedAt: now, Tenant: "tenant1"},
+	}))
+
+	t.Run("deleting hides the metric", func(t *testing.T) {
+		require.NoError(t, s.DeleteMetric(ctx, "VM1.Node1.nonce"))
+
+		_, errGet := s.GetMetricHistory(ctx, "VM1.Node1.nonce")
+		require.Equal(t, common.ErrMetricNotFound, errGet)
+		latest, errGet := s.GetLatestMetrics(ctx)
+		require.NoError(t, errGet)
+		require.Len(t, latest, 1)
+
+		trashed, errGet := s.GetTrashedMetrics(ctx, "")
+		require.NoError(t, errGet)
+		require.Len(t, trashed, 1)
+		require.Equal(t, "VM1.Node1.nonce", trashed[0].Name)
+		require.Equal(t, trashed[0].DeletedAt+3600, trashed[0].PurgeAt)
+
+		trashed, errGet = s.GetTrashedMetrics(ctx, "tenant1")
+		require.NoError(t, errGet)
+		require.Empty(t, trashed)
+	})
+	t.Run("restoring brings back the metric with its history", func(t *testing.T) {
+		require.NoError(t, s.RestoreMetric(ctx, "VM1.Node1.nonce"))
+		require.Equal(t, common.ErrMetricNotFound, s.RestoreMetric(ctx, "VM1.Node1.nonce"))
+		require.Equal(t, common.ErrMetricNotFound, s.RestoreMetric(ctx, "missing"))
+
+		hist, errGet := s.GetMetricHistory(ctx, "VM1.Node1.nonce")
+		require.NoError(t, errGet)
+		require.Equal(t, []common.MetricValue{{Value: "10", RecordedAt: now}}, hist.History)
+		require.Equal(t, map[string]string{"vm": "VM1"}, hist.Tags)
+	})
+	t.Run("a new report restores the metric", func(t *testing.T) {
+		_, errGet := s.GetLatestMetrics(ctx)
+		require.NoError(t, errGet)
+		require.NoError(t, s.DeleteMetric(ctx, "VM2.Node1.nonce"))
+		require.NoError(t, s.SaveMetrics(ctx, []common.MetricRecord{
+			{Name: "VM2.Node1.nonce", Type: common.MetricTypeUint64, NumAggregation: 1, Value: "21", RecordedAt: now + 1, Tenant: "tenant1"},
+		}))
+
+		latest, errGet := s.GetLatestMetricsFiltered(ctx, common.MetricsFilter{Name: "VM2.Node1.nonce"})
+		require.NoError(t, errGet)
+		require.Len(t, latest, 1)
+		require.Equal(t, "tenant1", latest[0].Tenant)
+		trashed, errGet := s.GetTrashedMetrics(ctx, "")
+		require.NoError(t, errGet)
+		require.Empty(t, trashed)
+	})
+	t.Run("purging removes the metrics deleted before the cutoff", func(t *testing.T) {
+		require.NoError(t, s.DeleteMetric(ctx, "VM1.Node1.nonce"))
+		require.NoError(t, s.purgeTrashedMetrics(ctx, now-3600))
+		trashed, errGet := s.GetTrashedMetrics(ctx, "")
+		require.NoError(t, errGet)
+		require.Len(t, trashed, 1)
+
+		require.NoError(t, s.purgeTrashedMetrics(ctx, time.Now().Unix()+1))
+		trashed, errGet = s.GetTrashedMetrics(ctx, "")
+		require.NoError(t, errGet)
+		require.Empty(t, trashed)
+		require.Equal(t, common.ErrMetricNotFound, s.RestoreMetric(ctx, "VM1.Node1.nonce"))
+
+		// a purged metric reported again starts without history nor tags
+		require.NoError(t, s.SaveMetric(ctx, "VM1.Node1.nonce", common.MetricTypeUint64, 1, "11", now+1))
+		hist, errGet := s.GetMetricHistory(ctx, "VM1.Node1.nonce")
+		require.NoError(t, errGet)
+		require.Equal(t, []common.MetricValue{{Value: "11", RecordedAt: now + 1}}, hist.History)
+		require.Nil(t, hist.Tags)
+	})
+}
@@ -0,0 +1,12 @@
+package summaries
+
+import "errors"
+
+var (
+	errNilStorage                = errors.New("nil storage")
+	errNilOutputNotifiersHandler = errors.New("nil output notifiers handler")
+	errNilTimeFunc               = errors.New("nil pointer for the current time function")
+	errInvalidPeriod             = errors.New("invalid period")
+	errInvalidNumTopIncidents    = errors.New("invalid number of top incidents")
+	errInvalidKeyMetricPattern   = errors.New("invalid key metric pattern")
+)
@@ -0,0 +1,30 @@
+package summaries
+
+import (
+	"context"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+)
+
+// Storage defines the storage operations required by the summary reporter
+type Storage interface {
+	// GetLatestMetrics returns the single latest recorded value for every known metric
+	GetLatestMetrics(ctx context.Context) ([]common.MetricHistory, error)
+
+	// GetMetricHistory returns the definition and all retained values (up to NumAggregation) for a specific metric
+	GetMetricHistory(ctx context.Context, name string) (*common.MetricHistory, error)
+
+	// GetAlerts returns the alerts matching the filter, the most recent first
+	GetAlerts(ctx context.Context, filter common.AlertsFilter) ([]common.Alert, error)
+
+	// GetAvailabilityBuckets returns the availability buckets starting at or after the provided timestamp
+	GetAvailabilityBuckets(ctx context.Context, since int64) ([]common.AvailabilityBucket, error)
+
+	IsInterfaceNil() bool
+}
+
+// OutputNotifiersHandler defines the behavior of a component that is able to notify all notifiers
+type OutputNotifiersHandler interface {
+	NotifyWithRetry(caller string, messages ...common.OutputMessage) error
+	IsInterfaceNil() bool
+}
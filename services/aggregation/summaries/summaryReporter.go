@@ -0,0 +1,299 @@
+package summaries
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/sla"
+	"github.com/multiversx/mx-chain-core-go/core/check"
+	logger "github.com/multiversx/mx-chain-logger-go"
+)
+
+var log = logger.GetOrCreate("summaries")
+
+const timeLayout = "01-02-2006 15:04"
+
+// ArgsSummaryReporter represents the DTO used in the NewSummaryReporter constructor function
+type ArgsSummaryReporter struct {
+	Name             string
+	Period           time.Duration
+	Storage          Storage
+	NotifiersHandler OutputNotifiersHandler
+	NumTopIncidents  int
+	KeyMetrics       []string
+	TimeFunc         func() time.Time
+}
+
+type summaryReporter struct {
+	name             string
+	period           time.Duration
+	storage          Storage
+	notifiersHandler OutputNotifiersHandler
+	numTopIncidents  int
+	keyMetrics       []string
+	timeFunc         func() time.Time
+}
+
+// NewSummaryReporter creates a component that sends, on each execution, the summary of the period that just ended:
+// the agents uptime, the top incidents and the min/max values of the key metrics
+func NewSummaryReporter(args ArgsSummaryReporter) (*summaryReporter, error) {
+	if check.IfNil(args.Storage) {
+		return nil, errNilStorage
+	}
+	if check.IfNil(args.NotifiersHandler) {
+		return nil, errNilOutputNotifiersHandler
+	}
+	if args.TimeFunc == nil {
+		return nil, errNilTimeFunc
+	}
+	if args.Period < time.Hour {
+		return nil, fmt.Errorf("%w, provided %v, minimum 1h", errInvalidPeriod, args.Period)
+	}
+	if args.NumTopIncidents < 0 {
+		return nil, fmt.Errorf("%w, provided %d", errInvalidNumTopIncidents, args.NumTopIncidents)
+	}
+	for _, pattern := range args.KeyMetrics {
+		_, err := path.Match(pattern, "")
+		if len(pattern) == 0 || err != nil {
+			return nil, fmt.Errorf("%w %q", errInvalidKeyMetricPattern, pattern)
+		}
+	}
+
+	return &summaryReporter{
+		name:             args.Name,
+		period:           args.Period,
+		storage:          args.Storage,
+		notifiersHandler: args.NotifiersHandler,
+		numTopIncidents:  args.NumTopIncidents,
+		keyMetrics:       args.KeyMetrics,
+		timeFunc:         args.TimeFunc,
+	}, nil
+}
+
+// Execute builds and sends the summary of the period that ends now
+func (reporter *summaryReporter) Execute(ctx context.Context) error {
+	now := reporter.timeFunc()
+	since := now.Add(-reporter.period)
+
+	messages := []common.OutputMessage{
+		{
+			Type:         common.InfoMessageOutputType,
+			ExecutorName: common.ExecutorName,
+			Identifier:   fmt.Sprintf("%s: %s - %s", reporter.name, since.Format(timeLayout), now.Format(timeLayout)),
+		},
+	}
+
+	uptimeMessage, err := reporter.createUptimeMessage(ctx, now.Unix())
+	if err != nil {
+		return err
+	}
+	messages = append(messages, uptimeMessage)
+
+	incidentsMessage, err := reporter.createIncidentsMessage(ctx, since.Unix(), now.Unix())
+	if err != nil {
+		return err
+	}
+	messages = append(messages, incidentsMessage)
+
+	if len(reporter.keyMetrics) > 0 {
+		keyMetricsMessage, errKeyMetrics := reporter.createKeyMetricsMessage(ctx, since.Unix())
+		if errKeyMetrics != nil {
+			return errKeyMetrics
+		}
+		messages = append(messages, keyMetricsMessage)
+	}
+
+	log.Debug("sending summary", "name", reporter.name, "since", since, "num messages", len(messages))
+
+	return reporter.notifiersHandler.NotifyWithRetry(reporter.name, messages...)
+}
+
+func (reporter *summaryReporter) createUptimeMessage(ctx context.Context, nowSec int64) (common.OutputMessage, error) {
+	periodSeconds := int64(reporter.period.Seconds())
+	buckets, err := reporter.storage.GetAvailabilityBuckets(ctx, nowSec-periodSeconds-common.AvailabilityBucketSeconds)
+	if err != nil {
+		return common.OutputMessage{}, err
+	}
+
+	window := sla.Window{Name: reporter.name, Seconds: periodSeconds}
+	report := sla.ComputeReport(buckets, []sla.Window{window}, "", nowSec)
+
+	msg := common.OutputMessage{
+		Type:         common.InfoMessageOutputType,
+		ExecutorName: common.ExecutorName,
+		Identifier:   "Agents uptime",
+	}
+	if len(report.Agents) == 0 {
+		msg.ProblemEncountered = "no data"
+		return msg, nil
+	}
+
+	lines := make([]string, 0, len(report.Agents))
+	for _, agent := range report.Agents {
+		availability := agent.Availability[window.Name]
+		if availability.ObservedSeconds > 0 && availability.UpSeconds < availability.ObservedSeconds {
+			msg.Type = common.WarningMessageOutputType
+		}
+		lines = append(lines, fmt.Sprintf("%s: %.2f%%", agent.Name, availability.Percentage))
+	}
+	msg.ProblemEncountered = strings.Join(lines, "\n")
+
+	return msg, nil
+}
+
+// incident is an alert that fired during the period, with the time it spent firing in the period
+type incident struct {
+	alert    common.Alert
+	duration time.Duration
+}
+
+func (reporter *summaryReporter) createIncidentsMessage(ctx context.Context, sinceSec int64, nowSec int64) (common.OutputMessage, error) {
+	alerts, err := reporter.storage.GetAlerts(ctx, common.AlertsFilter{ActiveSince: sinceSec})
+	if err != nil {
+		return common.OutputMessage{}, err
+	}
+
+	incidents := make([]incident, 0, len(alerts))
+	for _, alert := range alerts {
+		if alert.FiredAt == 0 || alert.FiredAt > nowSec {
+			// never notified
+			continue
+		}
+
+		start := alert.FiredAt
+		if start < sinceSec {
+			start = sinceSec
+		}
+		end := nowSec
+		if alert.ResolvedAt > 0 && alert.ResolvedAt < end {
+			end = alert.ResolvedAt
+		}
+		incidents = append(incidents, incident{
+			alert:    alert,
+			duration: time.Duration(end-start) * time.Second,
+		})
+	}
+
+	msg := common.OutputMessage{
+		Type:         common.InfoMessageOutputType,
+		ExecutorName: common.ExecutorName,
+		Identifier:   "No incidents",
+	}
+	if len(incidents) == 0 {
+		return msg, nil
+	}
+
+	sort.SliceStable(incidents, func(i, j int) bool {
+		return incidents[i].duration > incidents[j].duration
+	})
+
+	msg.Type = common.WarningMessageOutputType
+	msg.Identifier = fmt.Sprintf("%d incident(s)", len(incidents))
+	if len(incidents) > reporter.numTopIncidents {
+		// 0 top incidents only reports the count
+		if reporter.numTopIncidents > 0 {
+			msg.Identifier += fmt.Sprintf(", top %d", reporter.numTopIncidents)
+		}
+		incidents = incidents[:reporter.numTopIncidents]
+	}
+
+	lines := make([]string, 0, len(incidents))
+	for _, inc := range incidents {
+		status := "ongoing"
+		if inc.alert.State == common.AlertStateResolved {
+			status = "resolved"
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s, %v (%s)", inc.alert.MetricName, inc.alert.Problem, inc.duration, status))
+	}
+	msg.ProblemEncountered = strings.Join(lines, "\n")
+
+	return msg, nil
+}
+
+func (reporter *summaryReporter) createKeyMetricsMessage(ctx context.Context, sinceSec int64) (common.OutputMessage, error) {
+	latest, err := reporter.storage.GetLatestMetrics(ctx)
+	if err != nil {
+		return common.OutputMessage{}, err
+	}
+
+	names := make([]string, 0)
+	for _, metric := range latest {
+		if common.IsNumericMetricType(metric.Type) && reporter.isKeyMetric(metric.Name) {
+			names = append(names, metric.Name)
+		}
+	}
+	sort.Strings(names)
+
+	msg := common.OutputMessage{
+		Type:               common.InfoMessageOutputType,
+		ExecutorName:       common.ExecutorName,
+		Identifier:         "Key metrics",
+		ProblemEncountered: "no data",
+	}
+	lines := make([]string, 0, len(names))
+	for _, name := range names {
+		history, errHistory := reporter.storage.GetMetricHistory(ctx, name)
+		if errHistory != nil {
+			return common.OutputMessage{}, errHistory
+		}
+
+		lines = append(lines, createMinMaxLine(name, history.History, sinceSec))
+	}
+	if len(lines) > 0 {
+		msg.ProblemEncountered = strings.Join(lines, "\n")
+	}
+
+	return msg, nil
+}
+
+func (reporter *summaryReporter) isKeyMetric(metricName string) bool {
+	for _, pattern := range reporter.keyMetrics {
+		matched, _ := path.Match(pattern, metricName)
+		if matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// createMinMaxLine computes the min/max over the retained values recorded in the period
+func createMinMaxLine(name string, history []common.MetricValue, sinceSec int64) string {
+	var minValue, maxValue float64
+	numValues := 0
+	for _, value := range history {
+		if value.RecordedAt < sinceSec {
+			continue
+		}
+		parsed, err := strconv.ParseFloat(value.Value, 64)
+		if err != nil {
+			continue
+		}
+
+		if numValues == 0 || parsed < minValue {
+			minValue = parsed
+		}
+		if numValues == 0 || parsed > maxValue {
+			maxValue = parsed
+		}
+		numValues++
+	}
+
+	if numValues == 0 {
+		return fmt.Sprintf("%s: no values", name)
+	}
+
+	return fmt.Sprintf("%s: min %s, max %s", name,
+		strconv.FormatFloat(minValue, 'f', -1, 64), strconv.FormatFloat(maxValue, 'f', -1, 64))
+}
+
+// IsInterfaceNil returns true if there is no value under the interface
+func (reporter *summaryReporter) IsInterfaceNil() bool {
+	return reporter == nil
+}
@@ -0,0 +1,191 @@
+package summaries
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/testsCommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createMockArgs() ArgsSummaryReporter {
+	return ArgsSummaryReporter{
+		Name:             "Daily summary",
+		Period:           24 * time.Hour,
+		Storage:          &testsCommon.StoreStub{},
+		NotifiersHandler: &testsCommon.OutputNotifiersHandlerStub{},
+		NumTopIncidents:  2,
+		KeyMetrics:       []string{"*.nonce"},
+		TimeFunc:         time.Now,
+	}
+}
+
+func TestNewSummaryReporter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("nil storage should error", func(t *testing.T) {
+		args := createMockArgs()
+		args.Storage = nil
+
+		reporter, err := NewSummaryReporter(args)
+		assert.Nil(t, reporter)
+		assert.Equal(t, errNilStorage, err)
+	})
+	t.Run("nil notifiers handler should error", func(t *testing.T) {
+		args := createMockArgs()
+		args.NotifiersHandler = nil
+
+		reporter, err := NewSummaryReporter(args)
+		assert.Nil(t, reporter)
+		assert.Equal(t, errNilOutputNotifiersHandler, err)
+	})
+	t.Run("nil time func should error", func(t *testing.T) {
+		args := createMockArgs()
+		args.TimeFunc = nil
+
+		reporter, err := NewSummaryReporter(args)
+		assert.Nil(t, reporter)
+		assert.Equal(t, errNilTimeFunc, err)
+	})
+	t.Run("invalid period should error", func(t *testing.T) {
+		args := createMockArgs()
+		args.Period = time.Minute
+
+		reporter, err := NewSummaryReporter(args)
+		assert.Nil(t, reporter)
+		assert.ErrorIs(t, err, errInvalidPeriod)
+	})
+	t.Run("negative number of top incidents should error", func(t *testing.T) {
+		args := createMockArgs()
+		args.NumTopIncidents = -1
+
+		reporter, err := NewSummaryReporter(args)
+		assert.Nil(t, reporter)
+		assert.ErrorIs(t, err, errInvalidNumTopIncidents)
+	})
+	t.Run("invalid key metric pattern should error", func(t *testing.T) {
+		args := createMockArgs()
+		args.KeyMetrics = []string{"["}
+
+		reporter, err := NewSummaryReporter(args)
+		assert.Nil(t, reporter)
+		assert.ErrorIs(t, err, errInvalidKeyMetricPattern)
+	})
+	t.Run("should work", func(t *testing.T) {
+		reporter, err := NewSummaryReporter(createMockArgs())
+		assert.NotNil(t, reporter)
+		assert.Nil(t, err)
+		assert.False(t, reporter.IsInterfaceNil())
+	})
+}
+
+func TestSummaryReporter_Execute(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1000*common.AvailabilityBucketSeconds, 0)
+	nowSec := now.Unix()
+	daySec := int64(24 * 3600)
+
+	t.Run("storage error should error", func(t *testing.T) {
+		expectedErr := errors.New("expected error")
+		args := createMockArgs()
+		args.Storage = &testsCommon.StoreStub{
+			GetAlertsHandler: func(ctx context.Context, filter common.AlertsFilter) ([]common.Alert, error) {
+				return nil, expectedErr
+			},
+		}
+		args.NotifiersHandler = &testsCommon.OutputNotifiersHandlerStub{
+			NotifyWithRetryHandler: func(caller string, messages ...common.OutputMessage) error {
+				assert.Fail(t, "should not notify")
+				return nil
+			},
+		}
+		reporter, _ := NewSummaryReporter(args)
+
+		err := reporter.Execute(context.Background())
+		assert.Equal(t, expectedErr, err)
+	})
+	t.Run("should send the summary", func(t *testing.T) {
+		var sentMessages []common.OutputMessage
+		args := createMockArgs()
+		args.TimeFunc = func() time.Time {
+			return now
+		}
+		args.Storage = &testsCommon.StoreStub{
+			GetAvailabilityBucketsHandler: func(ctx context.Context, since int64) ([]common.AvailabilityBucket, error) {
+				return []common.AvailabilityBucket{
+					{MetricName: "VM1.Active", BucketStart: nowSec - 2*common.AvailabilityBucketSeconds, NumSuccess: 1},
+					{MetricName: "VM1.Active", BucketStart: nowSec - common.AvailabilityBucketSeconds, NumSuccess: 0},
+					{MetricName: "VM2.Active", BucketStart: nowSec - common.AvailabilityBucketSeconds, NumSuccess: 1},
+				}, nil
+			},
+			GetAlertsHandler: func(ctx context.Context, filter common.AlertsFilter) ([]common.Alert, error) {
+				assert.Equal(t, nowSec-daySec, filter.ActiveSince)
+				return []common.Alert{
+					{MetricName: "VM1.Node1.nonce", Problem: "Host appears offline", State: common.AlertStateFiring, FiredAt: nowSec - 60},
+					{MetricName: "VM2.Node1.nonce", Problem: "Host appears offline", State: common.AlertStatePending},
+					{MetricName: "VM3.Node1.nonce", Problem: "Host appears offline", State: common.AlertStateResolved,
+						FiredAt: nowSec - 2*daySec, ResolvedAt: nowSec - daySec + 3600},
+					{MetricName: "VM4.Node1.nonce", Problem: "Host appears offline", State: common.AlertStateResolved,
+						FiredAt: nowSec - 100, ResolvedAt: nowSec - 90},
+				}, nil
+			},
+			GetLatestMetricsHandler: func(ctx context.Context) ([]common.MetricHistory, error) {
+				return []common.MetricHistory{
+					{Name: "VM1.Node1.nonce", Type: common.MetricTypeUint64},
+					{Name: "VM1.Node1.version", Type: common.MetricTypeString},
+					{Name: "VM2.Node1.nonce", Type: common.MetricTypeUint64},
+				}, nil
+			},
+			GetMetricHistoryHandler: func(ctx context.Context, name string) (*common.MetricHistory, error) {
+				if name == "VM2.Node1.nonce" {
+					return &common.MetricHistory{Name: name}, nil
+				}
+				return &common.MetricHistory{
+					Name: name,
+					History: []common.MetricValue{
+						{Value: "1", RecordedAt: nowSec - 2*daySec},
+						{Value: "10", RecordedAt: nowSec - 20},
+						{Value: "12.5", RecordedAt: nowSec - 10},
+					},
+				}, nil
+			},
+		}
+		args.NotifiersHandler = &testsCommon.OutputNotifiersHandlerStub{
+			NotifyWithRetryHandler: func(caller string, messages ...common.OutputMessage) error {
+				assert.Equal(t, "Daily summary", caller)
+				sentMessages = messages
+				return nil
+			},
+		}
+		reporter, _ := NewSummaryReporter(args)
+
+		err := reporter.Execute(context.Background())
+		require.Nil(t, err)
+		require.Len(t, sentMessages, 4)
+		assert.Equal(t, now.Add(-24*time.Hour).Format(timeLayout), sentMessages[0].Identifier[len("Daily summary: "):len("Daily summary: ")+len(timeLayout)])
+		assert.Equal(t, common.OutputMessage{
+			Type:               common.WarningMessageOutputType,
+			ExecutorName:       common.ExecutorName,
+			Identifier:         "Agents uptime",
+			ProblemEncountered: "VM1: 50.00%\nVM2: 100.00%",
+		}, sentMessages[1])
+		assert.Equal(t, common.OutputMessage{
+			Type:         common.WarningMessageOutputType,
+			ExecutorName: common.ExecutorName,
+			Identifier:   "3 incident(s), top 2",
+			ProblemEncountered: "VM3.Node1.nonce: Host appears offline, 1h0m0s (resolved)\n" +
+				"VM1.Node1.nonce: Host appears offline, 1m0s (ongoing)",
+		}, sentMessages[2])
+		assert.Equal(t, common.OutputMessage{
+			Type:               common.InfoMessageOutputType,
+			ExecutorName:       common.ExecutorName,
+			Identifier:         "Key metrics",
+			ProblemEncountered: "VM1.Node1.nonce: min 10, max 12.5\nVM2.Node1.nonce: no values",
+		}, sentMessages[3])
+	})
+}
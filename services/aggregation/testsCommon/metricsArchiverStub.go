@@ -0,0 +1,22 @@
+package testsCommon
+
+import "github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+
+// MetricsArchiverStub -
+type MetricsArchiverStub struct {
+	ArchiveHandler func(reason string, metrics []common.ArchivedMetric) error
+}
+
+// Archive -
+func (stub *MetricsArchiverStub) Archive(reason string, metrics []common.ArchivedMetric) error {
+	if stub.ArchiveHandler != nil {
+		return stub.ArchiveHandler(reason, metrics)
+	}
+
+	return nil
+}
+
+// IsInterfaceNil -
+func (stub *MetricsArchiverStub) IsInterfaceNil() bool {
+	return stub == nil
+}
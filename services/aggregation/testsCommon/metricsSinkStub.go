@@ -0,0 +1,30 @@
+package testsCommon
+
+import "github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+
+// MetricsSinkStub -
+type MetricsSinkStub struct {
+	ForwardHandler func(records []common.MetricRecord)
+	CloseHandler   func() error
+}
+
+// Forward -
+func (stub *MetricsSinkStub) Forward(records []common.MetricRecord) {
+	if stub.ForwardHandler != nil {
+		stub.ForwardHandler(records)
+	}
+}
+
+// Close -
+func (stub *MetricsSinkStub) Close() error {
+	if stub.CloseHandler != nil {
+		return stub.CloseHandler()
+	}
+
+	return nil
+}
+
+// IsInterfaceNil -
+func (stub *MetricsSinkStub) IsInterfaceNil() bool {
+	return stub == nil
+}
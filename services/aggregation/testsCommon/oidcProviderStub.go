@@ -0,0 +1,36 @@
+package testsCommon
+
+import (
+	"context"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+)
+
+// OIDCProviderStub -
+type OIDCProviderStub struct {
+	AuthCodeURLHandler func(ctx context.Context, state string, nonce string) (string, error)
+	ExchangeHandler    func(ctx context.Context, code string, nonce string) (*common.UserIdentity, error)
+}
+
+// AuthCodeURL -
+func (stub *OIDCProviderStub) AuthCodeURL(ctx context.Context, state string, nonce string) (string, error) {
+	if stub.AuthCodeURLHandler != nil {
+		return stub.AuthCodeURLHandler(ctx, state, nonce)
+	}
+
+	return "", nil
+}
+
+// Exchange -
+func (stub *OIDCProviderStub) Exchange(ctx context.Context, code string, nonce string) (*common.UserIdentity, error) {
+	if stub.ExchangeHandler != nil {
+		return stub.ExchangeHandler(ctx, code, nonce)
+	}
+
+	return &common.UserIdentity{}, nil
+}
+
+// IsInterfaceNil -
+func (stub *OIDCProviderStub) IsInterfaceNil() bool {
+	return stub == nil
+}
@@ -2,21 +2,77 @@ package testsCommon
 
 import (
 	"context"
+	"math"
 
 	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
 )
 
 // StoreStub -
 type StoreStub struct {
-	SaveMetricHandler        func(ctx context.Context, name string, metricType string, numAggregation int, valString string, recordedAt int64) error
-	GetLatestMetricsHandler  func(ctx context.Context) ([]common.MetricHistory, error)
-	GetMetricHistoryHandler  func(ctx context.Context, name string) (*common.MetricHistory, error)
-	DeleteMetricHandler      func(ctx context.Context, name string) error
-	UpdateMetricOrderHandler func(ctx context.Context, name string, order int) error
-	UpdatePanelOrderHandler  func(ctx context.Context, name string, order int) error
-	GetPanelsConfigsHandler  func(ctx context.Context) (map[string]int, error)
-	UpdateMetricAlarmHandler func(ctx context.Context, name string, enabled bool) error
-	CloseHandler             func() error
+	SaveMetricHandler                  func(ctx context.Context, name string, metricType string, numAggregation int, valString string, recordedAt int64) error
+	SaveMetricIntervalHandler          func(ctx context.Context, name string, intervalInSeconds int) error
+	SaveMetricTagsHandler              func(ctx context.Context, name string, tags map[string]string) error
+	SaveMetricsHandler                 func(ctx context.Context, records []common.MetricRecord) error
+	GetLatestMetricsHandler            func(ctx context.Context) ([]common.MetricHistory, error)
+	GetLatestMetricsFilteredHandler    func(ctx context.Context, filter common.MetricsFilter) ([]common.MetricHistory, error)
+	GetMetricsHistoriesFilteredHandler func(ctx context.Context, filter common.MetricsFilter) ([]common.MetricHistory, error)
+	GetMetricHistoryHandler            func(ctx context.Context, name string) (*common.MetricHistory, error)
+	GetMetricHistorySinceHandler       func(ctx context.Context, name string, since int64) (*common.MetricHistory, error)
+	GetMetricStatsHandler              func(ctx context.Context, name string, from int64, to int64) (*common.MetricStats, error)
+	StreamMetricValuesHandler          func(ctx context.Context, names []string, from int64, to int64, handler func(value common.ExportedValue) error) error
+	GetMetricTenantHandler             func(ctx context.Context, name string) (string, error)
+	DeleteMetricHandler                func(ctx context.Context, name string) error
+	RestoreMetricHandler               func(ctx context.Context, name string) error
+	GetTrashedMetricsHandler           func(ctx context.Context, tenant string) ([]common.TrashedMetric, error)
+	DeleteMetricsByPrefixHandler       func(ctx context.Context, prefix string) (int64, error)
+	RenameMetricHandler                func(ctx context.Context, name string, newName string) error
+	AddMetricAnnotationHandler         func(ctx context.Context, name string, text string, recordedAt int64) (int64, error)
+	GetMetricAnnotationsHandler        func(ctx context.Context, name string) ([]common.MetricAnnotation, error)
+	DeleteMetricAnnotationHandler      func(ctx context.Context, name string, id int64) error
+	UpdateMetricOrderHandler           func(ctx context.Context, name string, order int) error
+	UpdatePanelOrderHandler            func(ctx context.Context, name string, order int) error
+	GetPanelsConfigsHandler            func(ctx context.Context) (map[string]int, error)
+	SetPanelMetricsHandler             func(ctx context.Context, panel string, metrics []string) error
+	GetCustomPanelsHandler             func(ctx context.Context) (map[string][]string, error)
+	UpdateMetricAlarmHandler           func(ctx context.Context, name string, enabled bool) error
+	UpdateMetricGapModeHandler         func(ctx context.Context, name string, gapMode common.GapMode) error
+	UpdateMetricAggregationModeHandler func(ctx context.Context, name string, aggregationMode common.AggregationMode) error
+	UpdateMetricStoreOnChangeHandler   func(ctx context.Context, name string, enabled bool) error
+	UpdateMetricCompressionHandler     func(ctx context.Context, name string, enabled bool) error
+	SetMetricThresholdsHandler         func(ctx context.Context, name string, thresholds *common.MetricThresholds) error
+	CreateDashboardHandler             func(ctx context.Context, dashboard common.Dashboard) (int64, error)
+	GetDashboardsHandler               func(ctx context.Context) ([]common.Dashboard, error)
+	GetDashboardHandler                func(ctx context.Context, id int64) (*common.Dashboard, error)
+	UpdateDashboardHandler             func(ctx context.Context, dashboard common.Dashboard) error
+	DeleteDashboardHandler             func(ctx context.Context, id int64) error
+	GetSchemaInfoHandler               func(ctx context.Context) (*common.SchemaInfo, error)
+	BackupHandler                      func(ctx context.Context, path string) error
+	GetStorageStatsHandler             func() common.StorageStats
+	CreateAlertHandler                 func(ctx context.Context, metricName string, problem string, startedAt int64) (int64, error)
+	UpdateAlertStateHandler            func(ctx context.Context, id int64, state common.AlertState, actor string, note string, timestamp int64) error
+	SilenceAlertHandler                func(ctx context.Context, id int64, until int64, actor string, note string, timestamp int64) error
+	GetActiveAlertsHandler             func(ctx context.Context) ([]common.Alert, error)
+	GetAlertsHandler                   func(ctx context.Context, filter common.AlertsFilter) ([]common.Alert, error)
+	GetAlertHandler                    func(ctx context.Context, id int64) (*common.Alert, error)
+	AddAlertEventHandler               func(ctx context.Context, id int64, action string, actor string, note string, timestamp int64) error
+	CreateSilenceHandler               func(ctx context.Context, silence common.Silence) (int64, error)
+	GetSilencesHandler                 func(ctx context.Context, endsAfter int64) ([]common.Silence, error)
+	GetActiveSilencesHandler           func(ctx context.Context, timestamp int64) ([]common.Silence, error)
+	ExpireSilenceHandler               func(ctx context.Context, id int64, timestamp int64) error
+	GetAvailabilityBucketsHandler      func(ctx context.Context, since int64) ([]common.AvailabilityBucket, error)
+	CreateSessionHandler               func(ctx context.Context, session common.Session) error
+	GetSessionHandler                  func(ctx context.Context, id string) (*common.Session, error)
+	GetActiveSessionsHandler           func(ctx context.Context, timestamp int64) ([]common.Session, error)
+	RevokeSessionHandler               func(ctx context.Context, id string, timestamp int64) error
+	RevokeUserSessionsHandler          func(ctx context.Context, username string, timestamp int64) (int64, error)
+	GetTOTPEnrollmentHandler           func(ctx context.Context, username string) (*common.TOTPEnrollment, error)
+	SaveTOTPEnrollmentHandler          func(ctx context.Context, enrollment common.TOTPEnrollment) error
+	DeleteTOTPEnrollmentHandler        func(ctx context.Context, username string) error
+	SaveAgentInfoHandler               func(ctx context.Context, info common.AgentInfo) error
+	GetAgentsHandler                   func(ctx context.Context, tenant string) ([]common.AgentInfo, error)
+	SaveAgentLogsHandler               func(ctx context.Context, entries []common.AgentLogEntry, maxEntriesPerAgent int) error
+	GetAgentLogsHandler                func(ctx context.Context, tenant string, agent string) ([]common.AgentLogEntry, error)
+	CloseHandler                       func() error
 }
 
 // SaveMetric -
@@ -28,6 +84,33 @@ func (stub *StoreStub) SaveMetric(ctx context.Context, name string, metricType s
 	return nil
 }
 
+// SaveMetricTags -
+func (stub *StoreStub) SaveMetricTags(ctx context.Context, name string, tags map[string]string) error {
+	if stub.SaveMetricTagsHandler != nil {
+		return stub.SaveMetricTagsHandler(ctx, name, tags)
+	}
+
+	return nil
+}
+
+// SaveMetricInterval -
+func (stub *StoreStub) SaveMetricInterval(ctx context.Context, name string, intervalInSeconds int) error {
+	if stub.SaveMetricIntervalHandler != nil {
+		return stub.SaveMetricIntervalHandler(ctx, name, intervalInSeconds)
+	}
+
+	return nil
+}
+
+// SaveMetrics -
+func (stub *StoreStub) SaveMetrics(ctx context.Context, records []common.MetricRecord) error {
+	if stub.SaveMetricsHandler != nil {
+		return stub.SaveMetricsHandler(ctx, records)
+	}
+
+	return nil
+}
+
 // GetLatestMetrics -
 func (stub *StoreStub) GetLatestMetrics(ctx context.Context) ([]common.MetricHistory, error) {
 	if stub.GetLatestMetricsHandler != nil {
@@ -37,6 +120,24 @@ func (stub *StoreStub) GetLatestMetrics(ctx context.Context) ([]common.MetricHis
 	return make([]common.MetricHistory, 0), nil
 }
 
+// GetLatestMetricsFiltered -
+func (stub *StoreStub) GetLatestMetricsFiltered(ctx context.Context, filter common.MetricsFilter) ([]common.MetricHistory, error) {
+	if stub.GetLatestMetricsFilteredHandler != nil {
+		return stub.GetLatestMetricsFilteredHandler(ctx, filter)
+	}
+
+	return stub.GetLatestMetrics(ctx)
+}
+
+// GetMetricsHistoriesFiltered -
+func (stub *StoreStub) GetMetricsHistoriesFiltered(ctx context.Context, filter common.MetricsFilter) ([]common.MetricHistory, error) {
+	if stub.GetMetricsHistoriesFilteredHandler != nil {
+		return stub.GetMetricsHistoriesFilteredHandler(ctx, filter)
+	}
+
+	return make([]common.MetricHistory, 0), nil
+}
+
 // GetMetricHistory -
 func (stub *StoreStub) GetMetricHistory(ctx context.Context, name string) (*common.MetricHistory, error) {
 	if stub.GetMetricHistoryHandler != nil {
@@ -46,6 +147,42 @@ func (stub *StoreStub) GetMetricHistory(ctx context.Context, name string) (*comm
 	return &common.MetricHistory{}, nil
 }
 
+// GetMetricStats -
+func (stub *StoreStub) GetMetricStats(ctx context.Context, name string, from int64, to int64) (*common.MetricStats, error) {
+	if stub.GetMetricStatsHandler != nil {
+		return stub.GetMetricStatsHandler(ctx, name, from, to)
+	}
+
+	return &common.MetricStats{}, nil
+}
+
+// StreamMetricValues -
+func (stub *StoreStub) StreamMetricValues(ctx context.Context, names []string, from int64, to int64, handler func(value common.ExportedValue) error) error {
+	if stub.StreamMetricValuesHandler != nil {
+		return stub.StreamMetricValuesHandler(ctx, names, from, to, handler)
+	}
+
+	return nil
+}
+
+// GetMetricHistorySince -
+func (stub *StoreStub) GetMetricHistorySince(ctx context.Context, name string, since int64) (*common.MetricHistory, error) {
+	if stub.GetMetricHistorySinceHandler != nil {
+		return stub.GetMetricHistorySinceHandler(ctx, name, since)
+	}
+
+	return &common.MetricHistory{}, nil
+}
+
+// GetMetricTenant -
+func (stub *StoreStub) GetMetricTenant(ctx context.Context, name string) (string, error) {
+	if stub.GetMetricTenantHandler != nil {
+		return stub.GetMetricTenantHandler(ctx, name)
+	}
+
+	return "", nil
+}
+
 // DeleteMetric -
 func (stub *StoreStub) DeleteMetric(ctx context.Context, name string) error {
 	if stub.DeleteMetricHandler != nil {
@@ -55,6 +192,69 @@ func (stub *StoreStub) DeleteMetric(ctx context.Context, name string) error {
 	return nil
 }
 
+// RestoreMetric -
+func (stub *StoreStub) RestoreMetric(ctx context.Context, name string) error {
+	if stub.RestoreMetricHandler != nil {
+		return stub.RestoreMetricHandler(ctx, name)
+	}
+
+	return nil
+}
+
+// GetTrashedMetrics -
+func (stub *StoreStub) GetTrashedMetrics(ctx context.Context, tenant string) ([]common.TrashedMetric, error) {
+	if stub.GetTrashedMetricsHandler != nil {
+		return stub.GetTrashedMetricsHandler(ctx, tenant)
+	}
+
+	return make([]common.TrashedMetric, 0), nil
+}
+
+// DeleteMetricsByPrefix -
+func (stub *StoreStub) DeleteMetricsByPrefix(ctx context.Context, prefix string) (int64, error) {
+	if stub.DeleteMetricsByPrefixHandler != nil {
+		return stub.DeleteMetricsByPrefixHandler(ctx, prefix)
+	}
+
+	return 0, nil
+}
+
+// RenameMetric -
+func (stub *StoreStub) RenameMetric(ctx context.Context, name string, newName string) error {
+	if stub.RenameMetricHandler != nil {
+		return stub.RenameMetricHandler(ctx, name, newName)
+	}
+
+	return nil
+}
+
+// AddMetricAnnotation -
+func (stub *StoreStub) AddMetricAnnotation(ctx context.Context, name string, text string, recordedAt int64) (int64, error) {
+	if stub.AddMetricAnnotationHandler != nil {
+		return stub.AddMetricAnnotationHandler(ctx, name, text, recordedAt)
+	}
+
+	return 0, nil
+}
+
+// GetMetricAnnotations -
+func (stub *StoreStub) GetMetricAnnotations(ctx context.Context, name string) ([]common.MetricAnnotation, error) {
+	if stub.GetMetricAnnotationsHandler != nil {
+		return stub.GetMetricAnnotationsHandler(ctx, name)
+	}
+
+	return make([]common.MetricAnnotation, 0), nil
+}
+
+// DeleteMetricAnnotation -
+func (stub *StoreStub) DeleteMetricAnnotation(ctx context.Context, name string, id int64) error {
+	if stub.DeleteMetricAnnotationHandler != nil {
+		return stub.DeleteMetricAnnotationHandler(ctx, name, id)
+	}
+
+	return nil
+}
+
 // UpdateMetricOrder -
 func (stub *StoreStub) UpdateMetricOrder(ctx context.Context, name string, order int) error {
 	if stub.UpdateMetricOrderHandler != nil {
@@ -82,6 +282,24 @@ func (stub *StoreStub) GetPanelsConfigs(ctx context.Context) (map[string]int, er
 	return make(map[string]int), nil
 }
 
+// SetPanelMetrics -
+func (stub *StoreStub) SetPanelMetrics(ctx context.Context, panel string, metrics []string) error {
+	if stub.SetPanelMetricsHandler != nil {
+		return stub.SetPanelMetricsHandler(ctx, panel, metrics)
+	}
+
+	return nil
+}
+
+// GetCustomPanels -
+func (stub *StoreStub) GetCustomPanels(ctx context.Context) (map[string][]string, error) {
+	if stub.GetCustomPanelsHandler != nil {
+		return stub.GetCustomPanelsHandler(ctx)
+	}
+
+	return make(map[string][]string), nil
+}
+
 // UpdateMetricAlarm -
 func (stub *StoreStub) UpdateMetricAlarm(ctx context.Context, name string, enabled bool) error {
 	if stub.UpdateMetricAlarmHandler != nil {
@@ -91,6 +309,339 @@ func (stub *StoreStub) UpdateMetricAlarm(ctx context.Context, name string, enabl
 	return nil
 }
 
+// UpdateMetricAggregationMode -
+func (stub *StoreStub) UpdateMetricAggregationMode(ctx context.Context, name string, aggregationMode common.AggregationMode) error {
+	if stub.UpdateMetricAggregationModeHandler != nil {
+		return stub.UpdateMetricAggregationModeHandler(ctx, name, aggregationMode)
+	}
+
+	return nil
+}
+
+// UpdateMetricStoreOnChange -
+func (stub *StoreStub) UpdateMetricStoreOnChange(ctx context.Context, name string, enabled bool) error {
+	if stub.UpdateMetricStoreOnChangeHandler != nil {
+		return stub.UpdateMetricStoreOnChangeHandler(ctx, name, enabled)
+	}
+
+	return nil
+}
+
+// UpdateMetricCompression -
+func (stub *StoreStub) UpdateMetricCompression(ctx context.Context, name string, enabled bool) error {
+	if stub.UpdateMetricCompressionHandler != nil {
+		return stub.UpdateMetricCompressionHandler(ctx, name, enabled)
+	}
+
+	return nil
+}
+
+// SetMetricThresholds -
+func (stub *StoreStub) SetMetricThresholds(ctx context.Context, name string, thresholds *common.MetricThresholds) error {
+	if stub.SetMetricThresholdsHandler != nil {
+		return stub.SetMetricThresholdsHandler(ctx, name, thresholds)
+	}
+
+	return nil
+}
+
+// UpdateMetricGapMode -
+func (stub *StoreStub) UpdateMetricGapMode(ctx context.Context, name string, gapMode common.GapMode) error {
+	if stub.UpdateMetricGapModeHandler != nil {
+		return stub.UpdateMetricGapModeHandler(ctx, name, gapMode)
+	}
+
+	return nil
+}
+
+// CreateDashboard -
+func (stub *StoreStub) CreateDashboard(ctx context.Context, dashboard common.Dashboard) (int64, error) {
+	if stub.CreateDashboardHandler != nil {
+		return stub.CreateDashboardHandler(ctx, dashboard)
+	}
+
+	return 0, nil
+}
+
+// GetDashboards -
+func (stub *StoreStub) GetDashboards(ctx context.Context) ([]common.Dashboard, error) {
+	if stub.GetDashboardsHandler != nil {
+		return stub.GetDashboardsHandler(ctx)
+	}
+
+	return make([]common.Dashboard, 0), nil
+}
+
+// GetDashboard -
+func (stub *StoreStub) GetDashboard(ctx context.Context, id int64) (*common.Dashboard, error) {
+	if stub.GetDashboardHandler != nil {
+		return stub.GetDashboardHandler(ctx, id)
+	}
+
+	return &common.Dashboard{ID: id}, nil
+}
+
+// UpdateDashboard -
+func (stub *StoreStub) UpdateDashboard(ctx context.Context, dashboard common.Dashboard) error {
+	if stub.UpdateDashboardHandler != nil {
+		return stub.UpdateDashboardHandler(ctx, dashboard)
+	}
+
+	return nil
+}
+
+// DeleteDashboard -
+func (stub *StoreStub) DeleteDashboard(ctx context.Context, id int64) error {
+	if stub.DeleteDashboardHandler != nil {
+		return stub.DeleteDashboardHandler(ctx, id)
+	}
+
+	return nil
+}
+
+// GetSchemaInfo -
+func (stub *StoreStub) GetSchemaInfo(ctx context.Context) (*common.SchemaInfo, error) {
+	if stub.GetSchemaInfoHandler != nil {
+		return stub.GetSchemaInfoHandler(ctx)
+	}
+
+	return &common.SchemaInfo{}, nil
+}
+
+// Backup -
+func (stub *StoreStub) Backup(ctx context.Context, path string) error {
+	if stub.BackupHandler != nil {
+		return stub.BackupHandler(ctx, path)
+	}
+
+	return nil
+}
+
+// GetStorageStats -
+func (stub *StoreStub) GetStorageStats() common.StorageStats {
+	if stub.GetStorageStatsHandler != nil {
+		return stub.GetStorageStatsHandler()
+	}
+
+	return common.StorageStats{}
+}
+
+// CreateAlert -
+func (stub *StoreStub) CreateAlert(ctx context.Context, metricName string, problem string, startedAt int64) (int64, error) {
+	if stub.CreateAlertHandler != nil {
+		return stub.CreateAlertHandler(ctx, metricName, problem, startedAt)
+	}
+
+	return 0, nil
+}
+
+// UpdateAlertState -
+func (stub *StoreStub) UpdateAlertState(ctx context.Context, id int64, state common.AlertState, actor string, note string, timestamp int64) error {
+	if stub.UpdateAlertStateHandler != nil {
+		return stub.UpdateAlertStateHandler(ctx, id, state, actor, note, timestamp)
+	}
+
+	return nil
+}
+
+// SilenceAlert -
+func (stub *StoreStub) SilenceAlert(ctx context.Context, id int64, until int64, actor string, note string, timestamp int64) error {
+	if stub.SilenceAlertHandler != nil {
+		return stub.SilenceAlertHandler(ctx, id, until, actor, note, timestamp)
+	}
+
+	return nil
+}
+
+// GetActiveAlerts -
+func (stub *StoreStub) GetActiveAlerts(ctx context.Context) ([]common.Alert, error) {
+	if stub.GetActiveAlertsHandler != nil {
+		return stub.GetActiveAlertsHandler(ctx)
+	}
+
+	return make([]common.Alert, 0), nil
+}
+
+// GetAlerts -
+func (stub *StoreStub) GetAlerts(ctx context.Context, filter common.AlertsFilter) ([]common.Alert, error) {
+	if stub.GetAlertsHandler != nil {
+		return stub.GetAlertsHandler(ctx, filter)
+	}
+
+	return make([]common.Alert, 0), nil
+}
+
+// GetAlert -
+func (stub *StoreStub) GetAlert(ctx context.Context, id int64) (*common.Alert, error) {
+	if stub.GetAlertHandler != nil {
+		return stub.GetAlertHandler(ctx, id)
+	}
+
+	return nil, common.ErrAlertNotFound
+}
+
+// AddAlertEvent -
+func (stub *StoreStub) AddAlertEvent(ctx context.Context, id int64, action string, actor string, note string, timestamp int64) error {
+	if stub.AddAlertEventHandler != nil {
+		return stub.AddAlertEventHandler(ctx, id, action, actor, note, timestamp)
+	}
+
+	return nil
+}
+
+// CreateSilence -
+func (stub *StoreStub) CreateSilence(ctx context.Context, silence common.Silence) (int64, error) {
+	if stub.CreateSilenceHandler != nil {
+		return stub.CreateSilenceHandler(ctx, silence)
+	}
+
+	return 0, nil
+}
+
+// GetSilences -
+func (stub *StoreStub) GetSilences(ctx context.Context, endsAfter int64) ([]common.Silence, error) {
+	if stub.GetSilencesHandler != nil {
+		return stub.GetSilencesHandler(ctx, endsAfter)
+	}
+
+	return make([]common.Silence, 0), nil
+}
+
+// GetActiveSilences -
+func (stub *StoreStub) GetActiveSilences(ctx context.Context, timestamp int64) ([]common.Silence, error) {
+	if stub.GetActiveSilencesHandler != nil {
+		return stub.GetActiveSilencesHandler(ctx, timestamp)
+	}
+
+	return make([]common.Silence, 0), nil
+}
+
+// GetAvailabilityBuckets -
+func (stub *StoreStub) GetAvailabilityBuckets(ctx context.Context, since int64) ([]common.AvailabilityBucket, error) {
+	if stub.GetAvailabilityBucketsHandler != nil {
+		return stub.GetAvailabilityBucketsHandler(ctx, since)
+	}
+
+	return make([]common.AvailabilityBucket, 0), nil
+}
+
+// ExpireSilence -
+func (stub *StoreStub) ExpireSilence(ctx context.Context, id int64, timestamp int64) error {
+	if stub.ExpireSilenceHandler != nil {
+		return stub.ExpireSilenceHandler(ctx, id, timestamp)
+	}
+
+	return nil
+}
+
+// CreateSession -
+func (stub *StoreStub) CreateSession(ctx context.Context, session common.Session) error {
+	if stub.CreateSessionHandler != nil {
+		return stub.CreateSessionHandler(ctx, session)
+	}
+
+	return nil
+}
+
+// GetSession -
+func (stub *StoreStub) GetSession(ctx context.Context, id string) (*common.Session, error) {
+	if stub.GetSessionHandler != nil {
+		return stub.GetSessionHandler(ctx, id)
+	}
+
+	return &common.Session{ID: id, ExpiresAt: math.MaxInt64}, nil
+}
+
+// GetActiveSessions -
+func (stub *StoreStub) GetActiveSessions(ctx context.Context, timestamp int64) ([]common.Session, error) {
+	if stub.GetActiveSessionsHandler != nil {
+		return stub.GetActiveSessionsHandler(ctx, timestamp)
+	}
+
+	return make([]common.Session, 0), nil
+}
+
+// RevokeSession -
+func (stub *StoreStub) RevokeSession(ctx context.Context, id string, timestamp int64) error {
+	if stub.RevokeSessionHandler != nil {
+		return stub.RevokeSessionHandler(ctx, id, timestamp)
+	}
+
+	return nil
+}
+
+// RevokeUserSessions -
+func (stub *StoreStub) RevokeUserSessions(ctx context.Context, username string, timestamp int64) (int64, error) {
+	if stub.RevokeUserSessionsHandler != nil {
+		return stub.RevokeUserSessionsHandler(ctx, username, timestamp)
+	}
+
+	return 0, nil
+}
+
+// GetTOTPEnrollment -
+func (stub *StoreStub) GetTOTPEnrollment(ctx context.Context, username string) (*common.TOTPEnrollment, error) {
+	if stub.GetTOTPEnrollmentHandler != nil {
+		return stub.GetTOTPEnrollmentHandler(ctx, username)
+	}
+
+	return nil, common.ErrTOTPNotEnrolled
+}
+
+// SaveTOTPEnrollment -
+func (stub *StoreStub) SaveTOTPEnrollment(ctx context.Context, enrollment common.TOTPEnrollment) error {
+	if stub.SaveTOTPEnrollmentHandler != nil {
+		return stub.SaveTOTPEnrollmentHandler(ctx, enrollment)
+	}
+
+	return nil
+}
+
+// DeleteTOTPEnrollment -
+func (stub *StoreStub) DeleteTOTPEnrollment(ctx context.Context, username string) error {
+	if stub.DeleteTOTPEnrollmentHandler != nil {
+		return stub.DeleteTOTPEnrollmentHandler(ctx, username)
+	}
+
+	return nil
+}
+
+// SaveAgentInfo -
+func (stub *StoreStub) SaveAgentInfo(ctx context.Context, info common.AgentInfo) error {
+	if stub.SaveAgentInfoHandler != nil {
+		return stub.SaveAgentInfoHandler(ctx, info)
+	}
+
+	return nil
+}
+
+// GetAgents -
+func (stub *StoreStub) GetAgents(ctx context.Context, tenant string) ([]common.AgentInfo, error) {
+	if stub.GetAgentsHandler != nil {
+		return stub.GetAgentsHandler(ctx, tenant)
+	}
+
+	return make([]common.AgentInfo, 0), nil
+}
+
+// SaveAgentLogs -
+func (stub *StoreStub) SaveAgentLogs(ctx context.Context, entries []common.AgentLogEntry, maxEntriesPerAgent int) error {
+	if stub.SaveAgentLogsHandler != nil {
+		return stub.SaveAgentLogsHandler(ctx, entries, maxEntriesPerAgent)
+	}
+
+	return nil
+}
+
+// GetAgentLogs -
+func (stub *StoreStub) GetAgentLogs(ctx context.Context, tenant string, agent string) ([]common.AgentLogEntry, error) {
+	if stub.GetAgentLogsHandler != nil {
+		return stub.GetAgentLogsHandler(ctx, tenant, agent)
+	}
+
+	return make([]common.AgentLogEntry, 0), nil
+}
+
 // Close -
 func (stub *StoreStub) Close() error {
 	if stub.CloseHandler != nil {
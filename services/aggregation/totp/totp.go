@@ -0,0 +1,154 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// Period is the validity of a code, the RFC 6238 default supported by all the authenticator apps
+	Period = 30 * time.Second
+	// NumDigits is the length of the generated codes
+	NumDigits = 6
+	// allowedDrift is the number of periods accepted before and after the current one, tolerating the small clock
+	// differences between the server and the authenticator device
+	allowedDrift = 1
+	secretSize   = 20
+	// recoveryCodeSize is the number of random bytes of a recovery code, hex encoded and split in two groups
+	recoveryCodeSize = 5
+)
+
+var base32NoPadding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+var errInvalidSecret = errors.New("invalid TOTP secret")
+
+// GenerateSecret returns a new random secret, base32 encoded as expected by the authenticator apps
+func GenerateSecret() (string, error) {
+	secret := make([]byte, secretSize)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", err
+	}
+
+	return base32NoPadding.EncodeToString(secret), nil
+}
+
+// KeyURI returns the otpauth:// URI the authenticator apps enroll from, usually rendered as a QR code
+func KeyURI(issuer string, account string, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprintf("%d", NumDigits))
+	query.Set("period", fmt.Sprintf("%d", int(Period.Seconds())))
+
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// GenerateCode returns the code of the period containing the provided time
+func GenerateCode(secret string, t time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+
+	return codeForStep(key, Step(t)), nil
+}
+
+// Step returns the index of the period containing the provided time
+func Step(t time.Time) int64 {
+	return t.Unix() / int64(Period.Seconds())
+}
+
+// Validate checks the code against the periods around the provided time, ignoring the periods not after lastStep so
+// that a code can not be used twice. It returns the period of the matching code.
+func Validate(secret string, code string, t time.Time, lastStep int64) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != NumDigits {
+		return 0, false
+	}
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return 0, false
+	}
+
+	current := Step(t)
+	for step := current - allowedDrift; step <= current+allowedDrift; step++ {
+		if step <= lastStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(codeForStep(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+// GenerateRecoveryCodes returns the provided number of single use recovery codes, formatted as xxxxx-xxxxx
+func GenerateRecoveryCodes(numCodes int) ([]string, error) {
+	codes := make([]string, 0, numCodes)
+	for i := 0; i < numCodes; i++ {
+		buff := make([]byte, recoveryCodeSize)
+		_, err := rand.Read(buff)
+		if err != nil {
+			return nil, err
+		}
+
+		code := hex.EncodeToString(buff)
+		codes = append(codes, code[:len(code)/2]+"-"+code[len(code)/2:])
+	}
+
+	return codes, nil
+}
+
+// HashRecoveryCode returns the hash the recovery codes are stored as. The hash ignores the case, the spaces and the
+// dashes the users might type.
+func HashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	hash := sha256.Sum256([]byte(normalized))
+
+	return hex.EncodeToString(hash[:])
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	key, err := base32NoPadding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil || len(key) == 0 {
+		return nil, errInvalidSecret
+	}
+
+	return key, nil
+}
+
+// codeForStep implements the HOTP algorithm (RFC 4226) with the period index as counter
+func codeForStep(key []byte, step int64) string {
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(step))
+
+	macd := hmac.New(sha1.New, key)
+	macd.Write(counter)
+	sum := macd.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	modulo := uint32(1)
+	for i := 0; i < NumDigits; i++ {
+		modulo *= 10
+	}
+
+	return fmt.Sprintf("%0*d", NumDigits, value%modulo)
+}
@@ -0,0 +1,95 @@
+package totp
+
+import (
+	"encoding/base32"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret is the SHA1 secret of the RFC 6238 test vectors
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestGenerateCode(t *testing.T) {
+	t.Parallel()
+
+	// the RFC 6238 test vectors, truncated to 6 digits
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+	for timestamp, expected := range vectors {
+		code, err := GenerateCode(rfcSecret, time.Unix(timestamp, 0))
+		require.NoError(t, err)
+		assert.Equal(t, expected, code, "timestamp %d", timestamp)
+	}
+
+	_, err := GenerateCode("not base32!", time.Unix(59, 0))
+	assert.Equal(t, errInvalidSecret, err)
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+	now := time.Unix(1700000000, 0)
+	code, err := GenerateCode(secret, now)
+	require.NoError(t, err)
+
+	step, ok := Validate(secret, code, now, 0)
+	assert.True(t, ok)
+	assert.Equal(t, Step(now), step)
+
+	// the clock drift of a period is tolerated
+	_, ok = Validate(secret, code, now.Add(Period), 0)
+	assert.True(t, ok)
+	_, ok = Validate(secret, code, now.Add(-Period), 0)
+	assert.True(t, ok)
+	_, ok = Validate(secret, code, now.Add(2*Period), 0)
+	assert.False(t, ok)
+
+	// a code can not be used twice
+	_, ok = Validate(secret, code, now, step)
+	assert.False(t, ok)
+
+	_, ok = Validate(secret, "12345", now, 0)
+	assert.False(t, ok)
+	_, ok = Validate("not base32!", code, now, 0)
+	assert.False(t, ok)
+}
+
+func TestKeyURI(t *testing.T) {
+	t.Parallel()
+
+	uri := KeyURI("API Monitoring", "admin", "JBSWY3DPEHPK3PXP")
+	parsed, err := url.Parse(uri)
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", parsed.Scheme)
+	assert.Equal(t, "totp", parsed.Host)
+	assert.Equal(t, "/API Monitoring:admin", parsed.Path)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", parsed.Query().Get("secret"))
+	assert.Equal(t, "API Monitoring", parsed.Query().Get("issuer"))
+	assert.Equal(t, "6", parsed.Query().Get("digits"))
+	assert.Equal(t, "30", parsed.Query().Get("period"))
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	t.Parallel()
+
+	codes, err := GenerateRecoveryCodes(10)
+	require.NoError(t, err)
+	require.Len(t, codes, 10)
+	assert.Regexp(t, `^[0-9a-f]{5}-[0-9a-f]{5}$`, codes[0])
+	assert.NotEqual(t, codes[0], codes[1])
+
+	assert.Equal(t, HashRecoveryCode(codes[0]), HashRecoveryCode(" "+codes[0][:5]+codes[0][6:]))
+	assert.NotEqual(t, HashRecoveryCode(codes[0]), HashRecoveryCode(codes[1]))
+}
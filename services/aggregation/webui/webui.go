@@ -0,0 +1,33 @@
+package webui
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// content holds the frontend build copied in the dist directory before compiling the binary (make embed-frontend).
+// Without it, only the placeholder file is embedded.
+//
+//go:embed all:dist
+var content embed.FS
+
+const indexFile = "index.html"
+
+// FS returns the frontend build embedded in the binary, nil if the binary was built without it
+func FS() fs.FS {
+	return buildFS(content)
+}
+
+func buildFS(fsys fs.FS) fs.FS {
+	dist, err := fs.Sub(fsys, "dist")
+	if err != nil {
+		return nil
+	}
+
+	_, err = fs.Stat(dist, indexFile)
+	if err != nil {
+		return nil
+	}
+
+	return dist
+}
@@ -0,0 +1,37 @@
+package webui
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildFS(t *testing.T) {
+	t.Parallel()
+
+	t.Run("without the frontend build should return nil", func(t *testing.T) {
+		t.Parallel()
+
+		fsys := fstest.MapFS{
+			"dist/README.md": {Data: []byte("placeholder")},
+		}
+		assert.Nil(t, buildFS(fsys))
+	})
+	t.Run("should return the build directory", func(t *testing.T) {
+		t.Parallel()
+
+		fsys := fstest.MapFS{
+			"dist/index.html":         {Data: []byte("<html></html>")},
+			"dist/_expo/static/js.js": {Data: []byte("js")},
+		}
+		dist := buildFS(fsys)
+		require.NotNil(t, dist)
+
+		data, err := fs.ReadFile(dist, "_expo/static/js.js")
+		require.NoError(t, err)
+		assert.Equal(t, "js", string(data))
+	})
+}
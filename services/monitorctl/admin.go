@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/urfave/cli"
+)
+
+const partialBackupSuffix = ".partial"
+
+var backupOutput = cli.StringFlag{
+	Name:  "output",
+	Usage: "The `path` of the backup file, which must not exist. Raise --timeout for the large databases.",
+}
+
+func listSessions(ctx *cli.Context) error {
+	aggregationClient, err := createClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	sessions, err := aggregationClient.GetSessions(context.Background())
+	if err != nil {
+		return err
+	}
+	if ctx.GlobalBool(jsonOutput.Name) {
+		return printJSON(os.Stdout, sessions)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ID\tUSER\tTENANT\tROLE\tREMOTE ADDRESS\tCREATED AT\tEXPIRES AT")
+	for _, session := range sessions {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", session.ID, session.Username, session.Tenant, session.Role,
+			session.RemoteAddr, formatTimestamp(session.CreatedAt), formatTimestamp(session.ExpiresAt))
+	}
+
+	return w.Flush()
+}
+
+func revokeSession(ctx *cli.Context) error {
+	err := requireArgs(ctx, 1)
+	if err != nil {
+		return err
+	}
+	aggregationClient, err := createClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = aggregationClient.RevokeSession(context.Background(), ctx.Args().Get(0))
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("revoked session", ctx.Args().Get(0))
+
+	return nil
+}
+
+func listAPIKeys(ctx *cli.Context) error {
+	aggregationClient, err := createClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	apiKeys, err := aggregationClient.GetAPIKeys(context.Background())
+	if err != nil {
+		return err
+	}
+	if ctx.GlobalBool(jsonOutput.Name) {
+		return printJSON(os.Stdout, apiKeys)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "NAME\tSCOPE\tTENANT")
+	for _, apiKey := range apiKeys {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", apiKey.Name, apiKey.Scope, apiKey.Tenant)
+	}
+
+	return w.Flush()
+}
+
+// backupDatabase downloads a snapshot of the service database. It is written next to the output file and renamed once
+// complete, so an interrupted backup never leaves a truncated file under the output path.
+func backupDatabase(ctx *cli.Context) error {
+	output := ctx.String(backupOutput.Name)
+	if len(output) == 0 {
+		return errors.New("the --output flag is required")
+	}
+	_, err := os.Stat(output)
+	if err == nil {
+		return fmt.Errorf("%s already exists", output)
+	}
+	aggregationClient, err := createClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	partialPath := output + partialBackupSuffix
+	file, err := os.OpenFile(partialPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	numBytes, err := aggregationClient.Backup(context.Background(), file)
+	errClose := file.Close()
+	if err == nil {
+		err = errClose
+	}
+	if err == nil {
+		err = os.Rename(partialPath, output)
+	}
+	if err != nil {
+		_ = os.Remove(partialPath)
+		return err
+	}
+
+	fmt.Println("backup of", numBytes, "bytes written to", output)
+
+	return nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/urfave/cli"
+)
+
+var (
+	alertState = cli.StringFlag{
+		Name:  "state",
+		Usage: "Only the alerts in this `state` (pending, firing, acknowledged, resolved).",
+	}
+	alertMetric = cli.StringFlag{
+		Name:  "metric",
+		Usage: "Only the alerts of the metric with this `name`.",
+	}
+	follow = cli.BoolFlag{
+		Name:  "follow",
+		Usage: "Boolean option for printing the new and the changed alerts as they happen, until interrupted.",
+	}
+	followInterval = cli.DurationFlag{
+		Name:  "interval",
+		Usage: "The `duration` between the polls of the alerts, with --follow.",
+		Value: 10 * time.Second,
+	}
+)
+
+func listAlerts(ctx *cli.Context) error {
+	aggregationClient, err := createClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	if len(ctx.String(alertState.Name)) > 0 {
+		query.Set("state", ctx.String(alertState.Name))
+	}
+	if len(ctx.String(alertMetric.Name)) > 0 {
+		query.Set("metric", ctx.String(alertMetric.Name))
+	}
+
+	if ctx.Bool(follow.Name) {
+		signalCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		return followAlerts(signalCtx, aggregationClient, query, ctx.Duration(followInterval.Name), ctx.GlobalBool(jsonOutput.Name), os.Stdout)
+	}
+
+	alerts, err := aggregationClient.GetAlerts(context.Background(), query)
+	if err != nil {
+		return err
+	}
+	if ctx.GlobalBool(jsonOutput.Name) {
+		return printJSON(os.Stdout, alerts)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ID\tSTATE\tMETRIC\tPROBLEM\tSTARTED AT\tACKNOWLEDGED BY")
+	for _, alert := range alerts {
+		_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", alert.ID, alert.State, alert.MetricName, alert.Problem,
+			formatTimestamp(alert.StartedAt), alert.AcknowledgedBy)
+	}
+
+	return w.Flush()
+}
+
+// followAlerts polls the alerts and prints, oldest first, the ones that appeared or changed since the previous poll,
+// the first poll printing the current ones. A failed poll is reported and retried at the next interval.
+func followAlerts(
+	ctx context.Context,
+	aggregationClient AggregationClient,
+	query url.Values,
+	interval time.Duration,
+	isJSON bool,
+	writer io.Writer,
+) error {
+	if interval <= 0 {
+		return fmt.Errorf("invalid --%s value %s", followInterval.Name, interval)
+	}
+
+	printed := make(map[int64]common.Alert)
+	for {
+		alerts, err := aggregationClient.GetAlerts(ctx, query)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, "error:", err)
+		}
+
+		changed := make([]common.Alert, 0)
+		for _, alert := range alerts {
+			previous, found := printed[alert.ID]
+			if found && isSameAlertState(previous, alert) {
+				continue
+			}
+			printed[alert.ID] = alert
+			changed = append(changed, alert)
+		}
+		sort.Slice(changed, func(i, j int) bool {
+			return lastAlertChange(changed[i]) < lastAlertChange(changed[j])
+		})
+		for _, alert := range changed {
+			printAlertLine(writer, alert, isJSON)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+func isSameAlertState(previous common.Alert, current common.Alert) bool {
+	return previous.State == current.State && previous.AcknowledgedBy == current.AcknowledgedBy &&
+		previous.SilencedUntil == current.SilencedUntil
+}
+
+// lastAlertChange returns the timestamp of the latest transition of the alert
+func lastAlertChange(alert common.Alert) int64 {
+	last := alert.StartedAt
+	for _, timestamp := range []int64{alert.FiredAt, alert.AcknowledgedAt, alert.ResolvedAt} {
+		if timestamp > last {
+			last = timestamp
+		}
+	}
+
+	return last
+}
+
+func printAlertLine(writer io.Writer, alert common.Alert, isJSON bool) {
+	if isJSON {
+		_ = json.NewEncoder(writer).Encode(alert)
+		return
+	}
+
+	line := fmt.Sprintf("%s  #%d  %-12s  %s: %s", formatTimestamp(lastAlertChange(alert)), alert.ID, alert.State,
+		alert.MetricName, alert.Problem)
+	if len(alert.AcknowledgedBy) > 0 {
+		line += " (acknowledged by " + alert.AcknowledgedBy + ")"
+	}
+	if alert.IsSilenced(time.Now().Unix()) {
+		line += " (silenced until " + formatTimestamp(alert.SilencedUntil) + ")"
+	}
+	_, _ = fmt.Fprintln(writer, line)
+}
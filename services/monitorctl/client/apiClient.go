@@ -0,0 +1,407 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+)
+
+// ArgsAPIClient defines the DTO struct for the NewAPIClient constructor function
+type ArgsAPIClient struct {
+	URL      string
+	Username string
+	Password string
+	Timeout  time.Duration
+}
+
+// Metric is the latest value of a metric, as returned by the metrics listing
+type Metric struct {
+	Name           string            `json:"name"`
+	Value          string            `json:"value"`
+	Type           string            `json:"type"`
+	NumAggregation int               `json:"numAggregation"`
+	DisplayOrder   int               `json:"displayOrder"`
+	IsAlarmEnabled bool              `json:"isAlarmEnabled"`
+	GapMode        common.GapMode    `json:"gapMode"`
+	Tags           map[string]string `json:"tags,omitempty"`
+	RecordedAt     int64             `json:"recordedAt"`
+}
+
+// APIKey describes a configured API key, as returned by the API keys listing (the key itself is never returned)
+type APIKey struct {
+	Name   string `json:"name"`
+	Scope  string `json:"scope"`
+	Tenant string `json:"tenant"`
+}
+
+// Agent holds the build and configuration details of an agent, as returned by the agents listing
+type Agent struct {
+	common.AgentInfo
+	LastReportAt int64 `json:"lastReportAt"`
+	Stale        bool  `json:"stale"`
+}
+
+type apiClient struct {
+	baseURL  string
+	username string
+	password string
+	client   *http.Client
+	token    string
+}
+
+// NewAPIClient creates a new client for the aggregation service API. The session token is obtained on the first
+// request that requires authentication.
+func NewAPIClient(args ArgsAPIClient) (*apiClient, error) {
+	if len(strings.TrimSpace(args.URL)) == 0 {
+		return nil, errEmptyURL
+	}
+
+	return &apiClient{
+		baseURL:  strings.TrimSuffix(args.URL, "/"),
+		username: args.Username,
+		password: args.Password,
+		client: &http.Client{
+			Timeout: args.Timeout,
+		},
+	}, nil
+}
+
+// GetMetrics returns the latest value of the metrics matching the query (name, prefix, type, tag, dashboard...)
+func (ac *apiClient) GetMetrics(ctx context.Context, query url.Values) ([]Metric, error) {
+	var resp struct {
+		Metrics []Metric `json:"metrics"`
+	}
+	err := ac.doAuthenticated(ctx, http.MethodGet, "/api/metrics", query, nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Metrics, nil
+}
+
+// GetMetricHistory returns the retained history of a metric
+func (ac *apiClient) GetMetricHistory(ctx context.Context, name string) (*common.MetricHistory, error) {
+	resp := &common.MetricHistory{}
+	err := ac.doAuthenticated(ctx, http.MethodGet, "/api/metrics/"+url.PathEscape(name)+"/history", nil, nil, resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// DeleteMetric moves a metric and its history to the trash
+func (ac *apiClient) DeleteMetric(ctx context.Context, name string) error {
+	return ac.doAuthenticated(ctx, http.MethodDelete, "/api/metrics/"+url.PathEscape(name), nil, nil, nil)
+}
+
+// RestoreMetric brings back a deleted metric from the trash
+func (ac *apiClient) RestoreMetric(ctx context.Context, name string) error {
+	return ac.doAuthenticated(ctx, http.MethodPost, "/api/metrics/"+url.PathEscape(name)+"/restore", nil, nil, nil)
+}
+
+// GetTrashedMetrics returns the deleted metrics that can still be restored, the most recently deleted first
+func (ac *apiClient) GetTrashedMetrics(ctx context.Context) ([]common.TrashedMetric, error) {
+	var resp struct {
+		Metrics []common.TrashedMetric `json:"metrics"`
+	}
+	err := ac.doAuthenticated(ctx, http.MethodGet, "/api/metrics/trash", nil, nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Metrics, nil
+}
+
+// DeleteMetricsByPrefix deletes all the metrics whose names start with the prefix, returning their number
+func (ac *apiClient) DeleteMetricsByPrefix(ctx context.Context, prefix string) (int64, error) {
+	var resp struct {
+		Deleted int64 `json:"deleted"`
+	}
+	err := ac.doAuthenticated(ctx, http.MethodDelete, "/api/metrics", url.Values{"prefix": {prefix}}, nil, &resp)
+	if err != nil {
+		return 0, err
+	}
+
+	return resp.Deleted, nil
+}
+
+// RenameMetric renames a metric, preserving its history
+func (ac *apiClient) RenameMetric(ctx context.Context, name string, newName string) error {
+	body := map[string]string{"newName": newName}
+	return ac.doAuthenticated(ctx, http.MethodPost, "/api/metrics/"+url.PathEscape(name)+"/rename", nil, body, nil)
+}
+
+// AddMetricAnnotation attaches a note to a metric, returning the annotation ID
+func (ac *apiClient) AddMetricAnnotation(ctx context.Context, name string, text string) (int64, error) {
+	var resp struct {
+		ID int64 `json:"id"`
+	}
+	body := map[string]string{"text": text}
+	err := ac.doAuthenticated(ctx, http.MethodPost, "/api/metrics/"+url.PathEscape(name)+"/annotations", nil, body, &resp)
+	if err != nil {
+		return 0, err
+	}
+
+	return resp.ID, nil
+}
+
+// GetDashboards returns all the dashboards
+func (ac *apiClient) GetDashboards(ctx context.Context) ([]common.Dashboard, error) {
+	var resp struct {
+		Dashboards []common.Dashboard `json:"dashboards"`
+	}
+	err := ac.doAuthenticated(ctx, http.MethodGet, "/api/dashboards", nil, nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Dashboards, nil
+}
+
+// CreateShareToken generates a read-only share token, returning the token and its expiry timestamp
+func (ac *apiClient) CreateShareToken(ctx context.Context, dashboardID int64, ttl time.Duration) (string, int64, error) {
+	var resp struct {
+		Token     string `json:"token"`
+		ExpiresAt int64  `json:"expiresAt"`
+	}
+	body := map[string]int64{
+		"dashboardId": dashboardID,
+		"ttlInSec":    int64(ttl.Seconds()),
+	}
+	err := ac.doAuthenticated(ctx, http.MethodPost, "/api/share", nil, body, &resp)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return resp.Token, resp.ExpiresAt, nil
+}
+
+// GetSchemaInfo returns the database schema version, the applied migrations and the tables
+func (ac *apiClient) GetSchemaInfo(ctx context.Context) (*common.SchemaInfo, error) {
+	resp := &common.SchemaInfo{}
+	err := ac.doAuthenticated(ctx, http.MethodGet, "/api/admin/schema", nil, nil, resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// Drain stops the service from accepting new reports and waits for the in-flight ones
+func (ac *apiClient) Drain(ctx context.Context) error {
+	return ac.doAuthenticated(ctx, http.MethodPost, "/api/admin/drain", nil, nil, nil)
+}
+
+// GetAgents returns the build and configuration details of the agents
+func (ac *apiClient) GetAgents(ctx context.Context) ([]Agent, error) {
+	var resp struct {
+		Agents []Agent `json:"agents"`
+	}
+	err := ac.doAuthenticated(ctx, http.MethodGet, "/api/agents", nil, nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Agents, nil
+}
+
+// GetAgentLogs returns the warnings and the errors last shipped by an agent, newest first
+func (ac *apiClient) GetAgentLogs(ctx context.Context, name string) ([]common.AgentLogEntry, error) {
+	var resp struct {
+		Logs []common.AgentLogEntry `json:"logs"`
+	}
+	err := ac.doAuthenticated(ctx, http.MethodGet, "/api/agents/"+url.PathEscape(name)+"/logs", nil, nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Logs, nil
+}
+
+// GetAlerts returns the alerts matching the query (state, metric, limit), the most recent first
+func (ac *apiClient) GetAlerts(ctx context.Context, query url.Values) ([]common.Alert, error) {
+	var resp struct {
+		Alerts []common.Alert `json:"alerts"`
+	}
+	err := ac.doAuthenticated(ctx, http.MethodGet, "/api/alerts", query, nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Alerts, nil
+}
+
+// GetSessions returns the active user sessions
+func (ac *apiClient) GetSessions(ctx context.Context) ([]common.Session, error) {
+	var resp struct {
+		Sessions []common.Session `json:"sessions"`
+	}
+	err := ac.doAuthenticated(ctx, http.MethodGet, "/api/sessions", nil, nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Sessions, nil
+}
+
+// RevokeSession logs out a user session
+func (ac *apiClient) RevokeSession(ctx context.Context, id string) error {
+	return ac.doAuthenticated(ctx, http.MethodDelete, "/api/sessions/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// GetAPIKeys returns the API keys configured on the service
+func (ac *apiClient) GetAPIKeys(ctx context.Context) ([]APIKey, error) {
+	var resp struct {
+		APIKeys []APIKey `json:"apiKeys"`
+	}
+	err := ac.doAuthenticated(ctx, http.MethodGet, "/api/admin/api-keys", nil, nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.APIKeys, nil
+}
+
+// Backup writes a snapshot of the service database to the writer, returning the number of written bytes
+func (ac *apiClient) Backup(ctx context.Context, writer io.Writer) (int64, error) {
+	err := ac.login(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := ac.send(ctx, http.MethodPost, "/api/admin/backup", nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	return io.Copy(writer, resp.Body)
+}
+
+// GetStatus returns the public status page summary. Requires the status page to be enabled on the service.
+func (ac *apiClient) GetStatus(ctx context.Context) (*common.StatusSummary, error) {
+	resp := &common.StatusSummary{}
+	err := ac.do(ctx, http.MethodGet, "/status", nil, nil, resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (ac *apiClient) login(ctx context.Context) error {
+	if len(ac.token) > 0 {
+		return nil
+	}
+	if len(ac.username) == 0 || len(ac.password) == 0 {
+		return errEmptyCredentials
+	}
+
+	var resp struct {
+		Token string `json:"token"`
+	}
+	body := map[string]string{
+		"username": ac.username,
+		"password": ac.password,
+	}
+	err := ac.do(ctx, http.MethodPost, "/api/auth/login", nil, body, &resp)
+	if err != nil {
+		return fmt.Errorf("%w while logging in", err)
+	}
+
+	ac.token = resp.Token
+
+	return nil
+}
+
+func (ac *apiClient) doAuthenticated(ctx context.Context, method string, path string, query url.Values, body interface{}, response interface{}) error {
+	err := ac.login(ctx)
+	if err != nil {
+		return err
+	}
+
+	return ac.do(ctx, method, path, query, body, response)
+}
+
+func (ac *apiClient) do(ctx context.Context, method string, path string, query url.Values, body interface{}, response interface{}) error {
+	resp, err := ac.send(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if response == nil {
+		return nil
+	}
+
+	return json.Unmarshal(data, response)
+}
+
+// send issues the request and returns the response of a successful one, whose body must be closed by the caller
+func (ac *apiClient) send(ctx context.Context, method string, path string, query url.Values, body interface{}) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		buff, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(buff)
+	}
+
+	endpoint := ac.baseURL + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if len(ac.token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+ac.token)
+	}
+
+	resp, err := ac.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices {
+		return resp, nil
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var apiErr struct {
+		Error string `json:"error"`
+	}
+	data, _ := io.ReadAll(resp.Body)
+	_ = json.Unmarshal(data, &apiErr)
+	if len(apiErr.Error) == 0 {
+		apiErr.Error = http.StatusText(resp.StatusCode)
+	}
+
+	return nil, fmt.Errorf("%w: %s %s returned %d: %s", errRequestFailed, method, path, resp.StatusCode, apiErr.Error)
+}
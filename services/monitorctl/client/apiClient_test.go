@@ -0,0 +1,252 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createMockServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/auth/login", func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req["username"] != "admin" || req["password"] != "password" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"invalid credentials"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"token":"session-token"}`))
+	})
+	mux.HandleFunc("/api/metrics", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer session-token", r.Header.Get("Authorization"))
+		if r.Method == http.MethodDelete {
+			require.Equal(t, "VM1.", r.URL.Query().Get("prefix"))
+			_, _ = w.Write([]byte(`{"ok":true,"deleted":3}`))
+			return
+		}
+
+		require.Equal(t, "VM1.", r.URL.Query().Get("prefix"))
+		require.Equal(t, []string{"vm:VM1", "kind:nonce"}, r.URL.Query()["tag"])
+		_, _ = w.Write([]byte(`{"metrics":[{"name":"VM1.Node1.nonce","value":"10","type":"uint64","recordedAt":1000}]}`))
+	})
+	mux.HandleFunc("/api/metrics/VM1.Node1.nonce/history", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"name":"VM1.Node1.nonce","history":[{"value":"10","recordedAt":1000}]}`))
+	})
+	mux.HandleFunc("/api/metrics/missing", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error":"metric not found"}`))
+	})
+	mux.HandleFunc("/api/metrics/VM2.Node1.nonce/restore", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	})
+	mux.HandleFunc("/api/metrics/trash", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer session-token", r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte(`{"metrics":[{"name":"VM2.Node1.nonce","type":"uint64","deletedAt":1000,"purgeAt":605800}]}`))
+	})
+	mux.HandleFunc("/api/share", func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]int64
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		require.Equal(t, int64(2), req["dashboardId"])
+		require.Equal(t, int64(3600), req["ttlInSec"])
+		_, _ = w.Write([]byte(`{"token":"share-token","expiresAt":5000}`))
+	})
+	mux.HandleFunc("/api/agents", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer session-token", r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte(`{"agents":[{"name":"VM1","version":"v1.0.0","commit":"abc","configHash":"0123","updatedAt":900,"lastReportAt":1000}]}`))
+	})
+	mux.HandleFunc("/api/agents/VM1/logs", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer session-token", r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte(`{"logs":[{"id":2,"agent":"VM1","level":"warn","component":"poller","message":"endpoint failed","fields":{"metric":"VM1.Active"},"recordedAt":1000}]}`))
+	})
+	mux.HandleFunc("/api/alerts", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer session-token", r.Header.Get("Authorization"))
+		require.Equal(t, "firing", r.URL.Query().Get("state"))
+		_, _ = w.Write([]byte(`{"alerts":[{"id":7,"metricName":"VM1.Active","state":"firing","problem":"Host appears offline","startedAt":1000,"firedAt":1060}]}`))
+	})
+	mux.HandleFunc("/api/sessions", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer session-token", r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte(`{"sessions":[{"id":"s1","username":"admin","role":"admin","createdAt":1000,"expiresAt":2000}],"current":"s1"}`))
+	})
+	mux.HandleFunc("/api/sessions/s2", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodDelete, r.Method)
+		_, _ = w.Write([]byte(`{"status":"ok"}`))
+	})
+	mux.HandleFunc("/api/admin/api-keys", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer session-token", r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte(`{"apiKeys":[{"name":"grafana","scope":"read","tenant":""}]}`))
+	})
+	mux.HandleFunc("/api/admin/backup", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "Bearer session-token", r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte("SQLite format 3"))
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		require.Empty(t, r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte(`{"status":"up","agents":[{"name":"VM1","status":"up"}]}`))
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func createMockArgsAPIClient(serverURL string) ArgsAPIClient {
+	return ArgsAPIClient{
+		URL:      serverURL + "/",
+		Username: "admin",
+		Password: "password",
+		Timeout:  time.Second,
+	}
+}
+
+func TestNewAPIClient(t *testing.T) {
+	t.Parallel()
+
+	t.Run("empty URL should error", func(t *testing.T) {
+		t.Parallel()
+
+		apiClient, err := NewAPIClient(ArgsAPIClient{URL: " "})
+		assert.Nil(t, apiClient)
+		assert.Equal(t, errEmptyURL, err)
+	})
+	t.Run("should work", func(t *testing.T) {
+		t.Parallel()
+
+		apiClient, err := NewAPIClient(createMockArgsAPIClient("http://localhost:8080"))
+		assert.NotNil(t, apiClient)
+		assert.Nil(t, err)
+		assert.Equal(t, "http://localhost:8080", apiClient.baseURL)
+	})
+}
+
+func TestAPIClient_Requests(t *testing.T) {
+	t.Parallel()
+
+	server := createMockServer(t)
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+
+	t.Run("missing credentials should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsAPIClient(server.URL)
+		args.Password = ""
+		apiClient, _ := NewAPIClient(args)
+		_, err := apiClient.GetMetrics(ctx, nil)
+		assert.Equal(t, errEmptyCredentials, err)
+	})
+	t.Run("invalid credentials should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsAPIClient(server.URL)
+		args.Password = "wrong"
+		apiClient, _ := NewAPIClient(args)
+		_, err := apiClient.GetMetrics(ctx, nil)
+		assert.True(t, errors.Is(err, errRequestFailed))
+		assert.Contains(t, err.Error(), "invalid credentials")
+	})
+	t.Run("should query the API", func(t *testing.T) {
+		t.Parallel()
+
+		apiClient, _ := NewAPIClient(createMockArgsAPIClient(server.URL))
+
+		metrics, err := apiClient.GetMetrics(ctx, url.Values{"prefix": {"VM1."}, "tag": {"vm:VM1", "kind:nonce"}})
+		require.NoError(t, err)
+		require.Len(t, metrics, 1)
+		assert.Equal(t, "VM1.Node1.nonce", metrics[0].Name)
+		assert.Equal(t, "10", metrics[0].Value)
+
+		history, err := apiClient.GetMetricHistory(ctx, "VM1.Node1.nonce")
+		require.NoError(t, err)
+		require.Len(t, history.History, 1)
+		assert.Equal(t, int64(1000), history.History[0].RecordedAt)
+
+		numDeleted, err := apiClient.DeleteMetricsByPrefix(ctx, "VM1.")
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), numDeleted)
+
+		err = apiClient.DeleteMetric(ctx, "missing")
+		assert.True(t, errors.Is(err, errRequestFailed))
+		assert.Contains(t, err.Error(), "404: metric not found")
+
+		trashed, err := apiClient.GetTrashedMetrics(ctx)
+		require.NoError(t, err)
+		require.Len(t, trashed, 1)
+		assert.Equal(t, int64(605800), trashed[0].PurgeAt)
+		require.NoError(t, apiClient.RestoreMetric(ctx, "VM2.Node1.nonce"))
+
+		token, expiresAt, err := apiClient.CreateShareToken(ctx, 2, time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, "share-token", token)
+		assert.Equal(t, int64(5000), expiresAt)
+
+		agents, err := apiClient.GetAgents(ctx)
+		require.NoError(t, err)
+		require.Len(t, agents, 1)
+		assert.Equal(t, "v1.0.0", agents[0].Version)
+		assert.Equal(t, int64(1000), agents[0].LastReportAt)
+
+		logs, err := apiClient.GetAgentLogs(ctx, "VM1")
+		require.NoError(t, err)
+		require.Len(t, logs, 1)
+		assert.Equal(t, "warn", logs[0].Level)
+		assert.Equal(t, "endpoint failed", logs[0].Message)
+		assert.Equal(t, map[string]string{"metric": "VM1.Active"}, logs[0].Fields)
+
+		alerts, err := apiClient.GetAlerts(ctx, url.Values{"state": {"firing"}})
+		require.NoError(t, err)
+		require.Len(t, alerts, 1)
+		assert.Equal(t, int64(7), alerts[0].ID)
+		assert.Equal(t, int64(1060), alerts[0].FiredAt)
+
+		sessions, err := apiClient.GetSessions(ctx)
+		require.NoError(t, err)
+		require.Len(t, sessions, 1)
+		assert.Equal(t, "admin", sessions[0].Username)
+		require.NoError(t, apiClient.RevokeSession(ctx, "s2"))
+
+		apiKeys, err := apiClient.GetAPIKeys(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []APIKey{{Name: "grafana", Scope: "read"}}, apiKeys)
+
+		buff := &bytes.Buffer{}
+		numBytes, err := apiClient.Backup(ctx, buff)
+		require.NoError(t, err)
+		assert.Equal(t, int64(15), numBytes)
+		assert.Equal(t, "SQLite format 3", buff.String())
+	})
+	t.Run("failed backup should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsAPIClient(server.URL)
+		args.Password = "wrong"
+		apiClient, _ := NewAPIClient(args)
+
+		buff := &bytes.Buffer{}
+		_, err := apiClient.Backup(ctx, buff)
+		assert.True(t, errors.Is(err, errRequestFailed))
+		assert.Empty(t, buff.Bytes())
+	})
+	t.Run("status should not require authentication", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsAPIClient(server.URL)
+		args.Username = ""
+		apiClient, _ := NewAPIClient(args)
+
+		status, err := apiClient.GetStatus(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "up", status.Status)
+		require.Len(t, status.Agents, 1)
+	})
+}
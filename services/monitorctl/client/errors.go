@@ -0,0 +1,9 @@
+package client
+
+import "errors"
+
+var errEmptyURL = errors.New("empty aggregation service URL")
+
+var errEmptyCredentials = errors.New("empty username or password")
+
+var errRequestFailed = errors.New("request failed")
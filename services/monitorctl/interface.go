@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/aggregation/common"
+	"github.com/iulianpascalau/api-monitoring/services/monitorctl/client"
+)
+
+// AggregationClient defines the aggregation service operations available from the command line
+type AggregationClient interface {
+	GetMetrics(ctx context.Context, query url.Values) ([]client.Metric, error)
+	GetMetricHistory(ctx context.Context, name string) (*common.MetricHistory, error)
+	DeleteMetric(ctx context.Context, name string) error
+	RestoreMetric(ctx context.Context, name string) error
+	GetTrashedMetrics(ctx context.Context) ([]common.TrashedMetric, error)
+	DeleteMetricsByPrefix(ctx context.Context, prefix string) (int64, error)
+	RenameMetric(ctx context.Context, name string, newName string) error
+	AddMetricAnnotation(ctx context.Context, name string, text string) (int64, error)
+	GetDashboards(ctx context.Context) ([]common.Dashboard, error)
+	CreateShareToken(ctx context.Context, dashboardID int64, ttl time.Duration) (string, int64, error)
+	GetSchemaInfo(ctx context.Context) (*common.SchemaInfo, error)
+	Drain(ctx context.Context) error
+	GetStatus(ctx context.Context) (*common.StatusSummary, error)
+	GetAgents(ctx context.Context) ([]client.Agent, error)
+	GetAgentLogs(ctx context.Context, name string) ([]common.AgentLogEntry, error)
+	GetAlerts(ctx context.Context, query url.Values) ([]common.Alert, error)
+	GetSessions(ctx context.Context) ([]common.Session, error)
+	RevokeSession(ctx context.Context, id string) error
+	GetAPIKeys(ctx context.Context) ([]client.APIKey, error)
+	Backup(ctx context.Context, writer io.Writer) (int64, error)
+}
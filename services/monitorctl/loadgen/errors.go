@@ -0,0 +1,31 @@
+package loadgen
+
+import (
+	"errors"
+	"strconv"
+)
+
+var errEmptyURL = errors.New("empty aggregation service URL")
+
+var errEmptyAPIKey = errors.New("empty service API key")
+
+var errEmptyAgentPrefix = errors.New("empty agent prefix")
+
+var errInvalidNumAgents = errors.New("invalid number of agents")
+
+var errInvalidNumMetrics = errors.New("invalid number of metrics")
+
+var errInvalidInterval = errors.New("invalid report interval")
+
+var errInvalidDuration = errors.New("invalid test duration")
+
+var errNetwork = errors.New("network error")
+
+var errTimeout = errors.New("timeout")
+
+type errStatusNotOK int
+
+// Error returns the status code of the rejected report
+func (e errStatusNotOK) Error() string {
+	return "status code " + strconv.Itoa(int(e))
+}
@@ -0,0 +1,288 @@
+package loadgen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/commonGo"
+	"github.com/iulianpascalau/api-monitoring/services/agent/common"
+)
+
+const (
+	maxNumAgents  = 100000
+	maxNumMetrics = 10000
+	reportPath    = "/api/report"
+)
+
+// ArgsLoadGenerator defines the DTO struct for the NewLoadGenerator constructor function
+type ArgsLoadGenerator struct {
+	URL    string
+	APIKey string
+	// AgentPrefix names the simulated agents <AgentPrefix>1 to <AgentPrefix><NumAgents>, so their metrics can be
+	// deleted by prefix after the test
+	AgentPrefix string
+	NumAgents   int
+	// NumMetrics is the number of metrics in each report, besides the heartbeat
+	NumMetrics int
+	// Interval is the time between two reports of an agent
+	Interval time.Duration
+	Duration time.Duration
+	Timeout  time.Duration
+}
+
+// Statistics summarizes the reports sent during a load test
+type Statistics struct {
+	Duration         time.Duration `json:"durationNs"`
+	NumReports       int           `json:"numReports"`
+	NumFailed        int           `json:"numFailed"`
+	ReportsPerSecond float64       `json:"reportsPerSecond"`
+	MetricsPerSecond float64       `json:"metricsPerSecond"`
+	// the latencies are measured on the successful reports
+	MinLatency  time.Duration `json:"minLatencyNs"`
+	MeanLatency time.Duration `json:"meanLatencyNs"`
+	P50Latency  time.Duration `json:"p50LatencyNs"`
+	P90Latency  time.Duration `json:"p90LatencyNs"`
+	P99Latency  time.Duration `json:"p99LatencyNs"`
+	MaxLatency  time.Duration `json:"maxLatencyNs"`
+	// Failures counts the failed reports by reason: network error, timeout or the status code
+	Failures map[string]int `json:"failures"`
+}
+
+type loadGenerator struct {
+	endpoint    string
+	apiKey      string
+	agentPrefix string
+	numAgents   int
+	numMetrics  int
+	interval    time.Duration
+	duration    time.Duration
+	client      *http.Client
+
+	mut       sync.Mutex
+	latencies []time.Duration
+	failures  map[string]int
+}
+
+// NewLoadGenerator creates a generator simulating agents that report to an aggregation service at a fixed rate
+func NewLoadGenerator(args ArgsLoadGenerator) (*loadGenerator, error) {
+	if len(strings.TrimSpace(args.URL)) == 0 {
+		return nil, errEmptyURL
+	}
+	if len(args.APIKey) == 0 {
+		return nil, errEmptyAPIKey
+	}
+	if len(strings.TrimSpace(args.AgentPrefix)) == 0 {
+		return nil, errEmptyAgentPrefix
+	}
+	if args.NumAgents < 1 || args.NumAgents > maxNumAgents {
+		return nil, fmt.Errorf("%w: %d, expected 1..%d", errInvalidNumAgents, args.NumAgents, maxNumAgents)
+	}
+	if args.NumMetrics < 0 || args.NumMetrics > maxNumMetrics {
+		return nil, fmt.Errorf("%w: %d, expected 0..%d", errInvalidNumMetrics, args.NumMetrics, maxNumMetrics)
+	}
+	if args.Interval <= 0 {
+		return nil, fmt.Errorf("%w: %v", errInvalidInterval, args.Interval)
+	}
+	if args.Duration <= 0 {
+		return nil, fmt.Errorf("%w: %v", errInvalidDuration, args.Duration)
+	}
+
+	return &loadGenerator{
+		endpoint:    strings.TrimSuffix(args.URL, "/") + reportPath,
+		apiKey:      args.APIKey,
+		agentPrefix: args.AgentPrefix,
+		numAgents:   args.NumAgents,
+		numMetrics:  args.NumMetrics,
+		interval:    args.Interval,
+		duration:    args.Duration,
+		client: &http.Client{
+			Timeout: args.Timeout,
+			Transport: &http.Transport{
+				MaxIdleConnsPerHost: args.NumAgents,
+			},
+		},
+		failures: make(map[string]int),
+	}, nil
+}
+
+// Run sends the reports of all the simulated agents for the configured duration, or until the context is done, and
+// returns the statistics of the sent reports. The agents start spread over the first interval, as the real ones.
+func (lg *loadGenerator) Run(ctx context.Context) *Statistics {
+	ctx, cancel := context.WithTimeout(ctx, lg.duration)
+	defer cancel()
+
+	start := time.Now()
+	wg := sync.WaitGroup{}
+	for i := 1; i <= lg.numAgents; i++ {
+		wg.Add(1)
+		go func(agentName string, offset time.Duration) {
+			defer wg.Done()
+			lg.runAgent(ctx, agentName, offset)
+		}(lg.agentPrefix+strconv.Itoa(i), time.Duration(rand.Int63n(int64(lg.interval))))
+	}
+	wg.Wait()
+
+	return lg.statistics(time.Since(start))
+}
+
+func (lg *loadGenerator) runAgent(ctx context.Context, agentName string, offset time.Duration) {
+	timer := time.NewTimer(offset)
+	defer timer.Stop()
+
+	value := uint64(0)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		timer.Reset(lg.interval)
+
+		value++
+		latency, err := lg.sendReport(ctx, agentName, value)
+		if ctx.Err() != nil {
+			// the reports interrupted by the end of the test are not accounted
+			return
+		}
+		lg.record(latency, err)
+	}
+}
+
+func (lg *loadGenerator) sendReport(ctx context.Context, agentName string, value uint64) (time.Duration, error) {
+	body, err := json.Marshal(lg.createPayload(agentName, value))
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, lg.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", commonGo.ContentTypeJSON)
+	req.Header.Set("X-Api-Key", lg.apiKey)
+
+	start := time.Now()
+	resp, err := lg.client.Do(req)
+	if err != nil {
+		if os.IsTimeout(err) {
+			return time.Since(start), errTimeout
+		}
+		return time.Since(start), errNetwork
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	latency := time.Since(start)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return latency, errStatusNotOK(resp.StatusCode)
+	}
+
+	return latency, nil
+}
+
+func (lg *loadGenerator) createPayload(agentName string, value uint64) common.ReportPayload {
+	intervalInSeconds := int(lg.interval / time.Second)
+	now := time.Now().Unix()
+	payload := common.ReportPayload{
+		Metrics: make(map[string]common.MetricPayload, lg.numMetrics+1),
+		CycleID: commonGo.NewRequestID(),
+		SentAt:  now,
+	}
+	for i := 1; i <= lg.numMetrics; i++ {
+		payload.Metrics[fmt.Sprintf("%s.metric%d", agentName, i)] = common.MetricPayload{
+			Value:          strconv.FormatUint(value*uint64(i), 10),
+			Type:           "uint64",
+			NumAggregation: 10,
+			Interval:       intervalInSeconds,
+			RecordedAt:     now,
+		}
+	}
+	payload.Metrics[agentName+".Active"] = common.MetricPayload{
+		Value:          "true",
+		Type:           "bool",
+		NumAggregation: 1,
+		Interval:       intervalInSeconds,
+	}
+
+	return payload
+}
+
+func (lg *loadGenerator) record(latency time.Duration, err error) {
+	lg.mut.Lock()
+	defer lg.mut.Unlock()
+
+	if err != nil {
+		lg.failures[err.Error()]++
+		return
+	}
+	lg.latencies = append(lg.latencies, latency)
+}
+
+// statistics computes the latency percentiles of the successful reports
+func (lg *loadGenerator) statistics(duration time.Duration) *Statistics {
+	lg.mut.Lock()
+	defer lg.mut.Unlock()
+
+	stats := &Statistics{
+		Duration:   duration,
+		NumReports: len(lg.latencies),
+		Failures:   make(map[string]int, len(lg.failures)),
+	}
+	for reason, num := range lg.failures {
+		stats.NumFailed += num
+		stats.Failures[reason] = num
+	}
+	stats.NumReports += stats.NumFailed
+	if duration > 0 {
+		stats.ReportsPerSecond = float64(stats.NumReports) / duration.Seconds()
+		stats.MetricsPerSecond = stats.ReportsPerSecond * float64(lg.numMetrics+1)
+	}
+	if len(lg.latencies) == 0 {
+		return stats
+	}
+
+	latencies := make([]time.Duration, len(lg.latencies))
+	copy(latencies, lg.latencies)
+	sort.Slice(latencies, func(i, j int) bool {
+		return latencies[i] < latencies[j]
+	})
+
+	total := time.Duration(0)
+	for _, latency := range latencies {
+		total += latency
+	}
+	stats.MinLatency = latencies[0]
+	stats.MeanLatency = total / time.Duration(len(latencies))
+	stats.P50Latency = percentile(latencies, 50)
+	stats.P90Latency = percentile(latencies, 90)
+	stats.P99Latency = percentile(latencies, 99)
+	stats.MaxLatency = latencies[len(latencies)-1]
+
+	return stats
+}
+
+// percentile returns the nearest-rank percentile of the sorted values
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+
+	return sorted[rank-1]
+}
+
+// IsInterfaceNil returns true if there is no value under the interface
+func (lg *loadGenerator) IsInterfaceNil() bool {
+	return lg == nil
+}
@@ -0,0 +1,152 @@
+package loadgen
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/agent/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createArgs(url string) ArgsLoadGenerator {
+	return ArgsLoadGenerator{
+		URL:         url,
+		APIKey:      "key",
+		AgentPrefix: "LoadTest",
+		NumAgents:   3,
+		NumMetrics:  5,
+		Interval:    50 * time.Millisecond,
+		Duration:    300 * time.Millisecond,
+		Timeout:     time.Second,
+	}
+}
+
+func TestNewLoadGenerator(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		mutate      func(args *ArgsLoadGenerator)
+		expectedErr error
+	}{
+		{name: "empty URL", mutate: func(args *ArgsLoadGenerator) { args.URL = " " }, expectedErr: errEmptyURL},
+		{name: "empty API key", mutate: func(args *ArgsLoadGenerator) { args.APIKey = "" }, expectedErr: errEmptyAPIKey},
+		{name: "empty agent prefix", mutate: func(args *ArgsLoadGenerator) { args.AgentPrefix = "" }, expectedErr: errEmptyAgentPrefix},
+		{name: "no agents", mutate: func(args *ArgsLoadGenerator) { args.NumAgents = 0 }, expectedErr: errInvalidNumAgents},
+		{name: "too many metrics", mutate: func(args *ArgsLoadGenerator) { args.NumMetrics = maxNumMetrics + 1 }, expectedErr: errInvalidNumMetrics},
+		{name: "zero interval", mutate: func(args *ArgsLoadGenerator) { args.Interval = 0 }, expectedErr: errInvalidInterval},
+		{name: "zero duration", mutate: func(args *ArgsLoadGenerator) { args.Duration = 0 }, expectedErr: errInvalidDuration},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+" should error", func(t *testing.T) {
+			t.Parallel()
+
+			args := createArgs("http://localhost:8080")
+			tt.mutate(&args)
+			generator, err := NewLoadGenerator(args)
+			assert.Nil(t, generator)
+			assert.True(t, errors.Is(err, tt.expectedErr))
+		})
+	}
+	t.Run("should work", func(t *testing.T) {
+		t.Parallel()
+
+		generator, err := NewLoadGenerator(createArgs("http://localhost:8080/"))
+		require.Nil(t, err)
+		assert.False(t, generator.IsInterfaceNil())
+		assert.Equal(t, "http://localhost:8080/api/report", generator.endpoint)
+	})
+}
+
+func TestLoadGenerator_Run(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should send the reports of all agents", func(t *testing.T) {
+		t.Parallel()
+
+		mut := sync.Mutex{}
+		agents := make(map[string]int)
+		cycleIDs := make(map[string]struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/report", r.URL.Path)
+			assert.Equal(t, "key", r.Header.Get("X-Api-Key"))
+
+			payload := common.ReportPayload{}
+			assert.Nil(t, json.NewDecoder(r.Body).Decode(&payload))
+			assert.Len(t, payload.Metrics, 6)
+
+			mut.Lock()
+			for name := range payload.Metrics {
+				if strings.HasSuffix(name, ".Active") {
+					agents[strings.TrimSuffix(name, ".Active")]++
+				}
+			}
+			cycleIDs[payload.CycleID] = struct{}{}
+			mut.Unlock()
+			_, _ = w.Write([]byte(`{"ok":true}`))
+		}))
+		defer server.Close()
+
+		generator, _ := NewLoadGenerator(createArgs(server.URL))
+		stats := generator.Run(context.Background())
+
+		mut.Lock()
+		defer mut.Unlock()
+		assert.Len(t, agents, 3)
+		assert.Contains(t, agents, "LoadTest1")
+		assert.Contains(t, agents, "LoadTest3")
+		// a report interrupted by the end of the test reaches the server without being accounted
+		assert.GreaterOrEqual(t, len(cycleIDs), stats.NumReports)
+		assert.GreaterOrEqual(t, stats.NumReports, 3)
+		assert.Zero(t, stats.NumFailed)
+		assert.Empty(t, stats.Failures)
+		assert.True(t, stats.MinLatency <= stats.P50Latency)
+		assert.True(t, stats.P50Latency <= stats.P99Latency)
+		assert.True(t, stats.P99Latency <= stats.MaxLatency)
+		assert.InDelta(t, stats.ReportsPerSecond*6, stats.MetricsPerSecond, 0.001)
+	})
+	t.Run("should count the failures by reason", func(t *testing.T) {
+		t.Parallel()
+
+		numRequests := int32(0)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&numRequests, 1)%2 == 0 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+		defer server.Close()
+
+		args := createArgs(server.URL)
+		args.NumAgents = 1
+		generator, _ := NewLoadGenerator(args)
+		stats := generator.Run(context.Background())
+
+		assert.GreaterOrEqual(t, int(atomic.LoadInt32(&numRequests)), stats.NumReports)
+		assert.Equal(t, stats.NumReports/2, stats.NumFailed)
+		assert.Positive(t, stats.NumFailed)
+		assert.Equal(t, stats.NumFailed, stats.Failures["status code 503"])
+	})
+}
+
+func TestPercentile(t *testing.T) {
+	t.Parallel()
+
+	sorted := make([]time.Duration, 0, 100)
+	for i := 1; i <= 100; i++ {
+		sorted = append(sorted, time.Duration(i))
+	}
+
+	assert.Equal(t, time.Duration(50), percentile(sorted, 50))
+	assert.Equal(t, time.Duration(99), percentile(sorted, 99))
+	assert.Equal(t, time.Duration(1), percentile(sorted[:1], 99))
+	assert.Equal(t, time.Duration(2), percentile(sorted[:3], 50))
+}
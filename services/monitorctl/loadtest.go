@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/monitorctl/loadgen"
+	"github.com/urfave/cli"
+)
+
+var (
+	// apiKey is the service key the simulated agents report with. Prefer the environment variable so it does not end
+	// up in the shell history.
+	apiKey = cli.StringFlag{
+		Name:   "api-key",
+		Usage:  "The service `key` the simulated agents report with (SERVICE_KEY of the aggregation service).",
+		EnvVar: "MONITORCTL_API_KEY",
+	}
+	agentPrefix = cli.StringFlag{
+		Name:  "agent-prefix",
+		Usage: "The simulated agents are named <prefix>1 to <prefix><agents>, the `prefix` their metrics are deleted by.",
+		Value: "LoadTest",
+	}
+	numAgents = cli.IntFlag{
+		Name:  "agents",
+		Usage: "The `number` of simulated agents.",
+		Value: 10,
+	}
+	numMetrics = cli.IntFlag{
+		Name:  "metrics",
+		Usage: "The `number` of metrics in each report, besides the heartbeat.",
+		Value: 20,
+	}
+	reportInterval = cli.DurationFlag{
+		Name:  "interval",
+		Usage: "The `duration` between two reports of an agent.",
+		Value: 10 * time.Second,
+	}
+	testDuration = cli.DurationFlag{
+		Name:  "duration",
+		Usage: "The `duration` of the test.",
+		Value: time.Minute,
+	}
+)
+
+// runLoadTest simulates agents reporting to the aggregation service and prints the latency and error statistics. The
+// test stops early on SIGINT/SIGTERM, printing the statistics gathered so far.
+func runLoadTest(ctx *cli.Context) error {
+	generator, err := loadgen.NewLoadGenerator(loadgen.ArgsLoadGenerator{
+		URL:         ctx.GlobalString(serviceURL.Name),
+		APIKey:      ctx.String(apiKey.Name),
+		AgentPrefix: ctx.String(agentPrefix.Name),
+		NumAgents:   ctx.Int(numAgents.Name),
+		NumMetrics:  ctx.Int(numMetrics.Name),
+		Interval:    ctx.Duration(reportInterval.Name),
+		Duration:    ctx.Duration(testDuration.Name),
+		Timeout:     ctx.GlobalDuration(timeout.Name),
+	})
+	if err != nil {
+		return err
+	}
+
+	runCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	_, _ = fmt.Fprintf(os.Stderr, "simulating %d agent(s) reporting %d metric(s) every %v for %v...\n",
+		ctx.Int(numAgents.Name), ctx.Int(numMetrics.Name)+1, ctx.Duration(reportInterval.Name), ctx.Duration(testDuration.Name))
+	stats := generator.Run(runCtx)
+	if ctx.GlobalBool(jsonOutput.Name) {
+		return printJSON(os.Stdout, stats)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "duration\t%v\n", stats.Duration.Round(time.Millisecond))
+	_, _ = fmt.Fprintf(w, "reports\t%d (%.2f/s, %.2f metrics/s)\n", stats.NumReports, stats.ReportsPerSecond, stats.MetricsPerSecond)
+	_, _ = fmt.Fprintf(w, "failed\t%d\n", stats.NumFailed)
+	_, _ = fmt.Fprintf(w, "latency min/mean/max\t%v / %v / %v\n", stats.MinLatency, stats.MeanLatency, stats.MaxLatency)
+	_, _ = fmt.Fprintf(w, "latency p50/p90/p99\t%v / %v / %v\n", stats.P50Latency, stats.P90Latency, stats.P99Latency)
+	reasons := make([]string, 0, len(stats.Failures))
+	for reason := range stats.Failures {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		_, _ = fmt.Fprintf(w, "failed: %s\t%d\n", reason, stats.Failures[reason])
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,652 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/iulianpascalau/api-monitoring/services/monitorctl/client"
+	"github.com/urfave/cli"
+)
+
+// appVersion should be populated at build time using ldflags
+// Usage examples:
+// Linux/macOS:
+//
+//	go build -v -ldflags="-X main.appVersion=$(git describe --all | cut -c7-32)
+var appVersion = "undefined"
+
+var (
+	// serviceURL is the base URL of the aggregation service
+	serviceURL = cli.StringFlag{
+		Name:   "url",
+		Usage:  "The aggregation service base `URL`.",
+		Value:  "http://localhost:8080",
+		EnvVar: "MONITORCTL_URL",
+	}
+	// username is the frontend user
+	username = cli.StringFlag{
+		Name:   "user",
+		Usage:  "The `username` used to log in the aggregation service.",
+		EnvVar: "MONITORCTL_USER",
+	}
+	// password is the frontend user password. Prefer the environment variable so it does not end up in the shell history.
+	password = cli.StringFlag{
+		Name:   "password",
+		Usage:  "The `password` used to log in the aggregation service.",
+		EnvVar: "MONITORCTL_PASSWORD",
+	}
+	// timeout is the timeout of each request
+	timeout = cli.DurationFlag{
+		Name:  "timeout",
+		Usage: "The `duration` after which a request is abandoned.",
+		Value: 30 * time.Second,
+	}
+	// jsonOutput prints the raw JSON responses instead of the tables
+	jsonOutput = cli.BoolFlag{
+		Name:  "json",
+		Usage: "Boolean option for printing the results as JSON.",
+	}
+
+	prefix = cli.StringFlag{
+		Name:  "prefix",
+		Usage: "Only the metrics whose names start with this `prefix`.",
+	}
+	name = cli.StringFlag{
+		Name:  "name",
+		Usage: "Only the metric with exactly this `name`.",
+	}
+	metricType = cli.StringFlag{
+		Name:  "type",
+		Usage: "Only the metrics of this `type` (uint64, float64, string, bool).",
+	}
+	tag = cli.StringSliceFlag{
+		Name:  "tag",
+		Usage: "Only the metrics having this `key:value` tag. Can be repeated.",
+	}
+	dashboard = cli.Int64Flag{
+		Name:  "dashboard",
+		Usage: "Only the metrics selected by the dashboard with this `ID`.",
+	}
+	yes = cli.BoolFlag{
+		Name:  "yes",
+		Usage: "Boolean option for confirming a destructive operation.",
+	}
+	ttl = cli.DurationFlag{
+		Name:  "ttl",
+		Usage: "The `duration` the share token is valid for.",
+		Value: 7 * 24 * time.Hour,
+	}
+)
+
+func main() {
+	app := cli.NewApp()
+	app.Name = "mx-api-monitorctl"
+	app.Version = fmt.Sprintf("%s/%s/%s-%s", appVersion, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	app.Usage = "Command line administration tool for the API metrics aggregation service"
+	app.Flags = []cli.Flag{
+		serviceURL,
+		username,
+		password,
+		timeout,
+		jsonOutput,
+	}
+	app.Authors = []cli.Author{
+		{
+			Name:  "Iulian Pascalau",
+			Email: "iulian.pascalau@gmail.com",
+		},
+	}
+	app.Commands = []cli.Command{
+		{
+			Name:   "metrics",
+			Usage:  "List the latest value of the metrics",
+			Flags:  []cli.Flag{prefix, name, metricType, tag, dashboard},
+			Action: listMetrics,
+		},
+		{
+			Name:      "history",
+			Usage:     "Show the retained history of a metric",
+			ArgsUsage: "NAME",
+			Action:    showHistory,
+		},
+		{
+			Name:      "delete",
+			Usage:     "Move a metric and its history to the trash, restorable until purged",
+			ArgsUsage: "NAME",
+			Action:    deleteMetric,
+		},
+		{
+			Name:      "restore",
+			Usage:     "Restore a deleted metric from the trash",
+			ArgsUsage: "NAME",
+			Action:    restoreMetric,
+		},
+		{
+			Name:   "trash",
+			Usage:  "List the deleted metrics and when they are purged",
+			Action: listTrashedMetrics,
+		},
+		{
+			Name:      "delete-prefix",
+			Usage:     "Delete all the metrics whose names start with the prefix",
+			ArgsUsage: "PREFIX",
+			Flags:     []cli.Flag{yes},
+			Action:    deleteMetricsByPrefix,
+		},
+		{
+			Name:      "rename",
+			Usage:     "Rename a metric, preserving its history",
+			ArgsUsage: "NAME NEW_NAME",
+			Action:    renameMetric,
+		},
+		{
+			Name:      "annotate",
+			Usage:     "Attach a note to a metric (e.g. \"upgraded node to v1.7.0\")",
+			ArgsUsage: "NAME TEXT",
+			Action:    annotateMetric,
+		},
+		{
+			Name:   "dashboards",
+			Usage:  "List the dashboards",
+			Action: listDashboards,
+		},
+		{
+			Name:   "share",
+			Usage:  "Generate a read-only share token",
+			Flags:  []cli.Flag{dashboard, ttl},
+			Action: createShareToken,
+		},
+		{
+			Name:   "schema",
+			Usage:  "Show the database schema version, the applied migrations and the tables",
+			Action: showSchema,
+		},
+		{
+			Name:   "drain",
+			Usage:  "Stop the service from accepting new reports, before a restart",
+			Action: drain,
+		},
+		{
+			Name:   "status",
+			Usage:  "Show the health of each agent, as displayed on the public status page",
+			Action: showStatus,
+		},
+		{
+			Name:   "agents",
+			Usage:  "List the version, commit and configuration hash of each agent, to spot the outdated ones",
+			Action: listAgents,
+		},
+		{
+			Name:      "agent-logs",
+			Usage:     "Show the warnings and the errors last shipped by an agent, newest first",
+			ArgsUsage: "NAME",
+			Action:    showAgentLogs,
+		},
+		{
+			Name:   "alerts",
+			Usage:  "List the alerts, the most recent first, or follow them as they fire, get acknowledged and resolve",
+			Flags:  []cli.Flag{alertState, alertMetric, follow, followInterval},
+			Action: listAlerts,
+		},
+		{
+			Name:   "sessions",
+			Usage:  "List the active user sessions (the users themselves are defined in the service configuration)",
+			Action: listSessions,
+		},
+		{
+			Name:      "revoke-session",
+			Usage:     "Log out a user session",
+			ArgsUsage: "ID",
+			Action:    revokeSession,
+		},
+		{
+			Name:   "api-keys",
+			Usage:  "List the API keys defined in the service configuration, with their scope and tenant",
+			Action: listAPIKeys,
+		},
+		{
+			Name:   "backup",
+			Usage:  "Download a consistent snapshot of the service database",
+			Flags:  []cli.Flag{backupOutput},
+			Action: backupDatabase,
+		},
+		{
+			Name:   "release-keygen",
+			Usage:  "Generate the ed25519 key signing the agent releases installed by the agents' self update",
+			Flags:  []cli.Flag{keyFile},
+			Action: generateReleaseKey,
+		},
+		{
+			Name:      "release-manifest",
+			Usage:     "Sign the agent binaries and print the release manifest polled by the agents' self update",
+			ArgsUsage: "PLATFORM=PATH [PLATFORM=PATH...] (e.g. linux-amd64=./agent)",
+			Flags:     []cli.Flag{keyFile, releaseVersion},
+			Action:    createReleaseManifest,
+		},
+		{
+			Name: "loadtest",
+			Usage: "Simulate agents reporting to the aggregation service at a fixed rate and print the latency and " +
+				"error statistics, to validate the sizing before a rollout",
+			Flags:  []cli.Flag{apiKey, agentPrefix, numAgents, numMetrics, reportInterval, testDuration},
+			Action: runLoadTest,
+		},
+	}
+
+	err := app.Run(os.Args)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func createClient(ctx *cli.Context) (AggregationClient, error) {
+	return client.NewAPIClient(client.ArgsAPIClient{
+		URL:      ctx.GlobalString(serviceURL.Name),
+		Username: ctx.GlobalString(username.Name),
+		Password: ctx.GlobalString(password.Name),
+		Timeout:  ctx.GlobalDuration(timeout.Name),
+	})
+}
+
+func requireArgs(ctx *cli.Context, num int) error {
+	if ctx.NArg() != num {
+		return fmt.Errorf("expected arguments: %s", ctx.Command.ArgsUsage)
+	}
+
+	return nil
+}
+
+func listMetrics(ctx *cli.Context) error {
+	aggregationClient, err := createClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	for _, flag := range []string{prefix.Name, name.Name, metricType.Name} {
+		if len(ctx.String(flag)) > 0 {
+			query.Set(flag, ctx.String(flag))
+		}
+	}
+	for _, value := range ctx.StringSlice(tag.Name) {
+		query.Add(tag.Name, value)
+	}
+	if ctx.Int64(dashboard.Name) != 0 {
+		query.Set(dashboard.Name, strconv.FormatInt(ctx.Int64(dashboard.Name), 10))
+	}
+
+	metrics, err := aggregationClient.GetMetrics(context.Background(), query)
+	if err != nil {
+		return err
+	}
+	if ctx.GlobalBool(jsonOutput.Name) {
+		return printJSON(os.Stdout, metrics)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "NAME\tTYPE\tVALUE\tAGE")
+	now := time.Now().Unix()
+	for _, metric := range metrics {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", metric.Name, metric.Type, metric.Value, formatAge(now, metric.RecordedAt))
+	}
+
+	return w.Flush()
+}
+
+func showHistory(ctx *cli.Context) error {
+	err := requireArgs(ctx, 1)
+	if err != nil {
+		return err
+	}
+	aggregationClient, err := createClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	history, err := aggregationClient.GetMetricHistory(context.Background(), ctx.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	if ctx.GlobalBool(jsonOutput.Name) {
+		return printJSON(os.Stdout, history)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "RECORDED AT\tVALUE")
+	for _, value := range history.History {
+		_, _ = fmt.Fprintf(w, "%s\t%s\n", formatTimestamp(value.RecordedAt), value.Value)
+	}
+	for _, annotation := range history.Annotations {
+		_, _ = fmt.Fprintf(w, "%s\t# %s\n", formatTimestamp(annotation.RecordedAt), annotation.Text)
+	}
+
+	return w.Flush()
+}
+
+func deleteMetric(ctx *cli.Context) error {
+	err := requireArgs(ctx, 1)
+	if err != nil {
+		return err
+	}
+	aggregationClient, err := createClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = aggregationClient.DeleteMetric(context.Background(), ctx.Args().Get(0))
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("moved", ctx.Args().Get(0), "to the trash")
+
+	return nil
+}
+
+func restoreMetric(ctx *cli.Context) error {
+	err := requireArgs(ctx, 1)
+	if err != nil {
+		return err
+	}
+	aggregationClient, err := createClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = aggregationClient.RestoreMetric(context.Background(), ctx.Args().Get(0))
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("restored", ctx.Args().Get(0))
+
+	return nil
+}
+
+func listTrashedMetrics(ctx *cli.Context) error {
+	aggregationClient, err := createClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	metrics, err := aggregationClient.GetTrashedMetrics(context.Background())
+	if err != nil {
+		return err
+	}
+	if ctx.GlobalBool(jsonOutput.Name) {
+		return printJSON(os.Stdout, metrics)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "NAME\tTYPE\tDELETED AT\tPURGED AT")
+	for _, metric := range metrics {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", metric.Name, metric.Type, formatTimestamp(metric.DeletedAt),
+			formatTimestamp(metric.PurgeAt))
+	}
+
+	return w.Flush()
+}
+
+func deleteMetricsByPrefix(ctx *cli.Context) error {
+	err := requireArgs(ctx, 1)
+	if err != nil {
+		return err
+	}
+	if !ctx.Bool(yes.Name) {
+		return errors.New("deleting metrics by prefix requires the --yes flag")
+	}
+	aggregationClient, err := createClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	numDeleted, err := aggregationClient.DeleteMetricsByPrefix(context.Background(), ctx.Args().Get(0))
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("deleted", numDeleted, "metric(s)")
+
+	return nil
+}
+
+func renameMetric(ctx *cli.Context) error {
+	err := requireArgs(ctx, 2)
+	if err != nil {
+		return err
+	}
+	aggregationClient, err := createClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = aggregationClient.RenameMetric(context.Background(), ctx.Args().Get(0), ctx.Args().Get(1))
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("renamed", ctx.Args().Get(0), "to", ctx.Args().Get(1))
+
+	return nil
+}
+
+func annotateMetric(ctx *cli.Context) error {
+	if ctx.NArg() < 2 {
+		return fmt.Errorf("expected arguments: %s", ctx.Command.ArgsUsage)
+	}
+	aggregationClient, err := createClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	text := strings.Join(ctx.Args().Tail(), " ")
+	id, err := aggregationClient.AddMetricAnnotation(context.Background(), ctx.Args().First(), text)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("added annotation", id)
+
+	return nil
+}
+
+func listDashboards(ctx *cli.Context) error {
+	aggregationClient, err := createClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	dashboards, err := aggregationClient.GetDashboards(context.Background())
+	if err != nil {
+		return err
+	}
+	if ctx.GlobalBool(jsonOutput.Name) {
+		return printJSON(os.Stdout, dashboards)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ID\tNAME\tPANELS\tMETRICS")
+	for _, d := range dashboards {
+		_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", d.ID, d.Name, strings.Join(d.Panels, ","), strings.Join(d.Metrics, ","))
+	}
+
+	return w.Flush()
+}
+
+func createShareToken(ctx *cli.Context) error {
+	aggregationClient, err := createClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	token, expiresAt, err := aggregationClient.CreateShareToken(context.Background(), ctx.Int64(dashboard.Name), ctx.Duration(ttl.Name))
+	if err != nil {
+		return err
+	}
+	if ctx.GlobalBool(jsonOutput.Name) {
+		return printJSON(os.Stdout, map[string]interface{}{"token": token, "expiresAt": expiresAt})
+	}
+
+	fmt.Println(token)
+	_, _ = fmt.Fprintln(os.Stderr, "expires at", formatTimestamp(expiresAt))
+
+	return nil
+}
+
+func showSchema(ctx *cli.Context) error {
+	aggregationClient, err := createClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	info, err := aggregationClient.GetSchemaInfo(context.Background())
+	if err != nil {
+		return err
+	}
+	if ctx.GlobalBool(jsonOutput.Name) {
+		return printJSON(os.Stdout, info)
+	}
+
+	fmt.Println("schema version:", info.Version)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "TABLE\tROWS")
+	for _, table := range info.Tables {
+		_, _ = fmt.Fprintf(w, "%s\t%d\n", table.Name, table.NumRows)
+	}
+
+	return w.Flush()
+}
+
+func drain(ctx *cli.Context) error {
+	aggregationClient, err := createClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = aggregationClient.Drain(context.Background())
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("drained")
+
+	return nil
+}
+
+func showStatus(ctx *cli.Context) error {
+	aggregationClient, err := createClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	status, err := aggregationClient.GetStatus(context.Background())
+	if err != nil {
+		return err
+	}
+	if ctx.GlobalBool(jsonOutput.Name) {
+		return printJSON(os.Stdout, status)
+	}
+
+	fmt.Println("overall:", status.Status)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "AGENT\tSTATUS\tLAST REPORT")
+	for _, agent := range status.Agents {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%ds ago\n", agent.Name, agent.Status, agent.LastReportAgeInSec)
+	}
+
+	return w.Flush()
+}
+
+func listAgents(ctx *cli.Context) error {
+	aggregationClient, err := createClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	agents, err := aggregationClient.GetAgents(context.Background())
+	if err != nil {
+		return err
+	}
+	if ctx.GlobalBool(jsonOutput.Name) {
+		return printJSON(os.Stdout, agents)
+	}
+
+	now := time.Now().Unix()
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "AGENT\tVERSION\tCOMMIT\tCONFIG HASH\tLAST REPORT")
+	for _, agent := range agents {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", agent.Name, agent.Version, agent.Commit, agent.ConfigHash,
+			formatAge(now, agent.LastReportAt))
+	}
+
+	return w.Flush()
+}
+
+func showAgentLogs(ctx *cli.Context) error {
+	err := requireArgs(ctx, 1)
+	if err != nil {
+		return err
+	}
+	aggregationClient, err := createClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	logs, err := aggregationClient.GetAgentLogs(context.Background(), ctx.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	if ctx.GlobalBool(jsonOutput.Name) {
+		return printJSON(os.Stdout, logs)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "TIME\tLEVEL\tCOMPONENT\tMESSAGE\tFIELDS")
+	for _, entry := range logs {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", formatTimestamp(entry.RecordedAt), entry.Level, entry.Component,
+			entry.Message, formatFields(entry.Fields))
+	}
+
+	return w.Flush()
+}
+
+// formatFields returns the key=value pairs sorted by key
+func formatFields(fields map[string]string) string {
+	pairs := make([]string, 0, len(fields))
+	for key, value := range fields {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, " ")
+}
+
+func printJSON(w io.Writer, value interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(value)
+}
+
+func formatTimestamp(timestamp int64) string {
+	return time.Unix(timestamp, 0).Format(time.RFC3339)
+}
+
+func formatAge(now int64, timestamp int64) string {
+	if timestamp == 0 {
+		return "never"
+	}
+
+	return (time.Duration(now-timestamp) * time.Second).String()
+}
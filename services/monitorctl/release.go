@@ -0,0 +1,117 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/iulianpascalau/api-monitoring/commonGo/release"
+	"github.com/urfave/cli"
+)
+
+var (
+	keyFile = cli.StringFlag{
+		Name:  "key-file",
+		Usage: "The `path` of the file holding the hex encoded ed25519 release signing key.",
+		Value: "release.key",
+	}
+	releaseVersion = cli.StringFlag{
+		Name:  "version",
+		Usage: "The `version` the agent binaries were built with (main.appVersion).",
+	}
+)
+
+func generateReleaseKey(ctx *cli.Context) error {
+	path := ctx.String(keyFile.Name)
+	publicKey, privateKey, err := release.GenerateKey()
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(file, privateKey)
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	err = file.Close()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("signing key written to", path, "(keep it offline)")
+	fmt.Println("public key, to be set as SelfUpdate.PublicKey in the agent config:", publicKey)
+
+	return nil
+}
+
+// createReleaseManifest signs the agent binaries given as PLATFORM=PATH arguments and prints the release manifest.
+// The binary URLs are the file names, resolved by the agents against the manifest URL, so the manifest and the
+// binaries are published in the same directory.
+func createReleaseManifest(ctx *cli.Context) error {
+	version := strings.TrimSpace(ctx.String(releaseVersion.Name))
+	if len(version) == 0 {
+		return errors.New("the --version flag is required")
+	}
+	if ctx.NArg() == 0 {
+		return fmt.Errorf("expected arguments: %s", ctx.Command.ArgsUsage)
+	}
+
+	keyBytes, err := os.ReadFile(ctx.String(keyFile.Name))
+	if err != nil {
+		return err
+	}
+	privateKey, err := release.ParsePrivateKey(string(keyBytes))
+	if err != nil {
+		return err
+	}
+
+	manifest := release.Manifest{
+		Version:  version,
+		Binaries: make(map[string]release.Binary, ctx.NArg()),
+	}
+	for _, arg := range ctx.Args() {
+		platform, path, found := strings.Cut(arg, "=")
+		if !found || len(platform) == 0 || len(path) == 0 {
+			return fmt.Errorf("invalid argument %q, expected PLATFORM=PATH", arg)
+		}
+
+		sha256Hex, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		manifest.Binaries[platform] = release.Binary{
+			URL:       filepath.Base(path),
+			SHA256:    sha256Hex,
+			Signature: release.Sign(privateKey, version, platform, sha256Hex),
+		}
+	}
+
+	return printJSON(os.Stdout, manifest)
+}
+
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	hasher := sha256.New()
+	_, err = io.Copy(hasher, file)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}